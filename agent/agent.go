@@ -691,10 +691,14 @@ func (a *Agent) runAggregators(
 	ctx, cancel := context.WithCancel(context.Background())
 
 	// Before calling Add, initialize the aggregation window.  This ensures
-	// that any metric created after start time will be aggregated.
+	// that any metric created after start time will be aggregated. Sliding
+	// windows are aligned to the hop size with the first window of each
+	// period ending at the next hop.
 	for _, agg := range a.Config.Aggregators {
-		since, until := updateWindow(startTime, a.Config.Agent.RoundInterval, agg.Period())
-		agg.UpdateWindow(since, until)
+		for _, period := range agg.Periods() {
+			_, until := updateWindow(startTime, a.Config.Agent.RoundInterval, agg.Hop(period))
+			agg.UpdateWindow(until.Add(-period), until)
+		}
 	}
 
 	var wg sync.WaitGroup
@@ -756,7 +760,7 @@ func updateWindow(start time.Time, roundInterval bool, period time.Duration) (si
 	return since, until
 }
 
-// push runs the push for a single aggregator every period.
+// push runs the push for a single aggregator whenever one of its windows ends.
 func (*Agent) push(ctx context.Context, aggregator *models.RunningAggregator, acc telegraf.Accumulator) {
 	for {
 		// Ensures that Push will be called for each period, even if it has
@@ -769,7 +773,7 @@ func (*Agent) push(ctx context.Context, aggregator *models.RunningAggregator, ac
 		case <-time.After(until):
 			aggregator.Push(acc)
 		case <-ctx.Done():
-			aggregator.Push(acc)
+			aggregator.Flush(acc)
 			return
 		}
 	}
@@ -835,8 +839,13 @@ func (a *Agent) runOutputs(
 	interval := time.Duration(a.Config.Agent.FlushInterval)
 	jitter := time.Duration(a.Config.Agent.FlushJitter)
 
+	// Outputs receiving the rejected metrics of other outputs are stopped
+	// last to be able to write the metrics rejected on shutdown
 	ctx, cancel := context.WithCancel(context.Background())
+	sinkCtx, sinkCancel := context.WithCancel(context.Background())
+	var sinkWg sync.WaitGroup
 
+	outputs := make([]*models.RunningOutput, 0, len(unit.outputs))
 	for _, output := range unit.outputs {
 		interval := interval
 		// Overwrite agent flush_interval if this plugin has its own.
@@ -850,20 +859,28 @@ func (a *Agent) runOutputs(
 			jitter = output.Config.FlushJitter
 		}
 
-		wg.Add(1)
+		// Dead-letter outputs do not receive the metrics of the inputs
+		loopCtx, loopWg := ctx, &wg
+		if output.IsDeadLetterSink() {
+			loopCtx, loopWg = sinkCtx, &sinkWg
+		} else {
+			outputs = append(outputs, output)
+		}
+
+		loopWg.Add(1)
 		go func(output *models.RunningOutput) {
-			defer wg.Done()
+			defer loopWg.Done()
 
 			ticker := NewRollingTicker(interval, jitter)
 			defer ticker.Stop()
 
-			a.flushLoop(ctx, output, ticker)
+			a.flushLoop(loopCtx, output, ticker)
 		}(output)
 	}
 
 	for metric := range unit.src {
-		for i, output := range unit.outputs {
-			if i == len(unit.outputs)-1 {
+		for i, output := range outputs {
+			if i == len(outputs)-1 {
 				output.AddMetricNoCopy(metric)
 			} else {
 				output.AddMetric(metric)
@@ -874,6 +891,8 @@ func (a *Agent) runOutputs(
 	log.Println("I! [agent] Hang on, flushing any cached metrics before shutdown")
 	cancel()
 	wg.Wait()
+	sinkCancel()
+	sinkWg.Wait()
 
 	log.Println("I! [agent] Stopping running outputs")
 	stopRunningOutputs(unit.outputs)
@@ -901,14 +920,14 @@ func (a *Agent) flushLoop(
 		// Favor shutdown over other methods.
 		select {
 		case <-ctx.Done():
-			logError(a.flushOnce(output, ticker, output.Write))
+			logError(a.flushOnce(output, ticker, output.WriteFinal))
 			return
 		default:
 		}
 
 		select {
 		case <-ctx.Done():
-			logError(a.flushOnce(output, ticker, output.Write))
+			logError(a.flushOnce(output, ticker, output.WriteFinal))
 			return
 		case <-ticker.Elapsed():
 			logError(a.flushOnce(output, ticker, output.Write))
@@ -564,6 +564,11 @@ func (c *Config) LoadAll(configFiles ...string) error {
 	sort.Stable(c.Processors)
 	sort.Stable(c.AggProcessors)
 
+	// Connect the outputs to the outputs receiving their rejected metrics
+	if err := c.linkDeadLetters(); err != nil {
+		return err
+	}
+
 	// Set snmp agent translator default
 	if c.Agent.SnmpTranslator == "" {
 		c.Agent.SnmpTranslator = "netsnmp"
@@ -581,6 +586,39 @@ func (c *Config) LoadAll(configFiles ...string) error {
 	return c.LinkSecrets()
 }
 
+// linkDeadLetters resolves the dead-letter outputs referenced by alias. Outputs
+// receiving rejected metrics must not forward their rejected metrics again to
+// avoid loops.
+func (c *Config) linkDeadLetters() error {
+	for _, output := range c.Outputs {
+		if output.Config.DeadLetter == "" {
+			continue
+		}
+
+		var target *models.RunningOutput
+		for _, candidate := range c.Outputs {
+			if candidate.Config.Alias != output.Config.DeadLetter {
+				continue
+			}
+			if target != nil {
+				return fmt.Errorf("dead-letter alias %q of %s is ambiguous", output.Config.DeadLetter, output.LogName())
+			}
+			target = candidate
+		}
+
+		switch {
+		case target == nil:
+			return fmt.Errorf("dead-letter output %q of %s not found", output.Config.DeadLetter, output.LogName())
+		case target == output:
+			return fmt.Errorf("%s cannot be its own dead-letter output", output.LogName())
+		case target.Config.DeadLetter != "":
+			return fmt.Errorf("dead-letter output %s of %s must not have a dead-letter output itself", target.LogName(), output.LogName())
+		}
+		output.SetDeadLetter(target)
+	}
+	return nil
+}
+
 type cfgDataOptions struct {
 	sourcePath string
 }
@@ -971,7 +1009,18 @@ func (c *Config) addAggregator(name, source string, table *ast.Table) error {
 		return err
 	}
 
-	c.Aggregators = append(c.Aggregators, models.NewRunningAggregator(aggregator, conf))
+	// Sliding windows and multiple periods require a separate instance of
+	// the plugin for each window
+	instances := make([]telegraf.Aggregator, 0, conf.Instances()-1)
+	for range conf.Instances() - 1 {
+		instance := creator()
+		if err := c.toml.UnmarshalTable(table, instance); err != nil {
+			return err
+		}
+		instances = append(instances, instance)
+	}
+
+	c.Aggregators = append(c.Aggregators, models.NewRunningAggregator(aggregator, conf, instances...))
 	return nil
 }
 
@@ -1350,6 +1399,10 @@ func (c *Config) addOutput(name, source string, table *ast.Table) error {
 		return err
 	}
 
+	if _, ok := output.(telegraf.ConcurrentOutput); !ok && outputConfig.MaxInflightBatches > 1 {
+		return fmt.Errorf("plugin outputs.%s does not support concurrent writes, max_inflight_batches must not exceed 1", name)
+	}
+
 	if err := c.toml.UnmarshalTable(table, output); err != nil {
 		return err
 	}
@@ -1494,6 +1547,27 @@ func (c *Config) buildAggregator(name, source string, tbl *ast.Table) (*models.A
 	if grace, found := c.getFieldDuration(tbl, "grace"); found {
 		conf.Grace = grace
 	}
+	if hop, found := c.getFieldDuration(tbl, "hop"); found {
+		conf.Hop = hop
+	}
+	for _, p := range c.getFieldStringSlice(tbl, "periods") {
+		period, err := time.ParseDuration(p)
+		if err != nil {
+			c.addError(tbl, fmt.Errorf("error parsing duration: %w", err))
+			break
+		}
+		conf.Periods = append(conf.Periods, period)
+	}
+	if len(conf.Periods) > 0 {
+		if _, found := tbl.Fields["period"]; found {
+			c.addError(tbl, errors.New("cannot use both 'period' and 'periods'"))
+		}
+		conf.Period = conf.Periods[0]
+	}
+	conf.WindowTag = c.getFieldString(tbl, "window_tag")
+	if conf.WindowTag == "" {
+		conf.WindowTag = "window"
+	}
 
 	conf.DropOriginal = c.getFieldBool(tbl, "drop_original")
 	conf.MeasurementPrefix = c.getFieldString(tbl, "name_prefix")
@@ -1514,6 +1588,9 @@ func (c *Config) buildAggregator(name, source string, tbl *ast.Table) (*models.A
 	if c.hasErrs() {
 		return nil, c.firstErr()
 	}
+	if err := conf.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid window settings for aggregator %s: %w", name, err)
+	}
 
 	var err error
 	conf.Filter, err = c.buildFilter("aggregators."+name, tbl)
@@ -1710,17 +1787,34 @@ func (c *Config) buildOutput(name, source string, tbl *ast.Table) (*models.Outpu
 	oc.FlushJitter, _ = c.getFieldDuration(tbl, "flush_jitter")
 	oc.MetricBufferLimit = c.getFieldInt(tbl, "metric_buffer_limit")
 	oc.MetricBatchSize = c.getFieldInt(tbl, "metric_batch_size")
+	oc.MaxInflightBatches = c.getFieldInt(tbl, "max_inflight_batches")
+	oc.RetryInitialInterval, _ = c.getFieldDuration(tbl, "retry_initial_interval")
+	oc.RetryMaxInterval, _ = c.getFieldDuration(tbl, "retry_max_interval")
+	oc.RetryMaxAttempts = c.getFieldInt(tbl, "retry_max_attempts")
+	oc.CircuitBreakerThreshold = c.getFieldInt(tbl, "circuit_breaker_threshold")
+	oc.CircuitBreakerProbeInterval, _ = c.getFieldDuration(tbl, "circuit_breaker_probe_interval")
 	oc.Alias = c.getFieldString(tbl, "alias")
 	oc.NameOverride = c.getFieldString(tbl, "name_override")
 	oc.NameSuffix = c.getFieldString(tbl, "name_suffix")
 	oc.NamePrefix = c.getFieldString(tbl, "name_prefix")
 	oc.StartupErrorBehavior = c.getFieldString(tbl, "startup_error_behavior")
+	oc.DeadLetter = c.getFieldString(tbl, "dead_letter")
 	oc.LogLevel = c.getFieldString(tbl, "log_level")
 
 	if c.hasErrs() {
 		return nil, c.firstErr()
 	}
 
+	if oc.MaxInflightBatches < 0 {
+		return nil, fmt.Errorf("invalid max_inflight_batches %d for plugin outputs.%s", oc.MaxInflightBatches, name)
+	}
+	if oc.RetryMaxAttempts < 0 {
+		return nil, fmt.Errorf("invalid retry_max_attempts %d for plugin outputs.%s", oc.RetryMaxAttempts, name)
+	}
+	if oc.CircuitBreakerThreshold < 0 {
+		return nil, fmt.Errorf("invalid circuit_breaker_threshold %d for plugin outputs.%s", oc.CircuitBreakerThreshold, name)
+	}
+
 	if oc.BufferStrategy == "disk_write_through" {
 		log.Printf("W! Using disk-write-through buffer strategy for plugin outputs.%s, this is an experimental feature", name)
 	}
@@ -1735,17 +1829,21 @@ func (c *Config) missingTomlField(_ reflect.Type, key string) error {
 	// General options to ignore
 	case "alias", "always_include_local_tags",
 		"buffer_strategy", "buffer_directory",
+		"circuit_breaker_probe_interval", "circuit_breaker_threshold",
 		"collection_jitter", "collection_offset",
-		"data_format", "delay", "drop", "drop_original",
+		"data_format", "dead_letter", "delay", "drop", "drop_original",
 		"fielddrop", "fieldexclude", "fieldinclude", "fieldpass", "flush_interval", "flush_jitter",
 		"grace",
+		"hop",
 		"interval",
 		"log_level", "lvm", // What is this used for?
-		"metric_batch_size", "metric_buffer_limit", "metricpass",
+		"max_inflight_batches", "metric_batch_size", "metric_buffer_limit", "metricpass",
 		"name_override", "name_prefix", "name_suffix", "namedrop", "namedrop_separator", "namepass", "namepass_separator",
 		"order",
-		"pass", "period", "precision",
-		"tagdrop", "tagexclude", "taginclude", "tagpass", "tags", "startup_error_behavior", "labels":
+		"pass", "period", "periods", "precision",
+		"retry_initial_interval", "retry_max_attempts", "retry_max_interval",
+		"tagdrop", "tagexclude", "taginclude", "tagpass", "tags", "startup_error_behavior", "labels",
+		"window_tag":
 
 	// Secret-store options to ignore
 	case "id":
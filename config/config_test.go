@@ -554,6 +554,64 @@ func TestConfig_URLLikeFileName(t *testing.T) {
 	}
 }
 
+func TestConfig_DeadLetter(t *testing.T) {
+	c := config.NewConfig()
+	require.NoError(t, c.LoadAll("./testdata/dead_letter.toml"))
+	require.Len(t, c.Outputs, 2)
+	require.False(t, c.Outputs[0].IsDeadLetterSink())
+	require.True(t, c.Outputs[1].IsDeadLetterSink())
+}
+
+func TestConfig_DeadLetterInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		expected string
+	}{
+		{
+			name:     "missing",
+			filename: "./testdata/dead_letter_missing.toml",
+			expected: `dead-letter output "rejected" of outputs.http not found`,
+		},
+		{
+			name:     "self",
+			filename: "./testdata/dead_letter_self.toml",
+			expected: "cannot be its own dead-letter output",
+		},
+		{
+			name:     "chained",
+			filename: "./testdata/dead_letter_chained.toml",
+			expected: "must not have a dead-letter output itself",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := config.NewConfig()
+			require.ErrorContains(t, c.LoadAll(tt.filename), tt.expected)
+		})
+	}
+}
+
+func TestConfig_OutputRetryPolicy(t *testing.T) {
+	c := config.NewConfig()
+	require.NoError(t, c.LoadAll("./testdata/output_retry_policy.toml"))
+	require.Len(t, c.Outputs, 1)
+
+	cfg := c.Outputs[0].Config
+	require.Equal(t, 5*time.Second, cfg.RetryInitialInterval)
+	require.Equal(t, 5*time.Minute, cfg.RetryMaxInterval)
+	require.Equal(t, 3, cfg.RetryMaxAttempts)
+	require.Equal(t, 10, cfg.CircuitBreakerThreshold)
+	require.Equal(t, 30*time.Second, cfg.CircuitBreakerProbeInterval)
+}
+
+func TestConfig_OutputMaxInflightBatchesUnsupported(t *testing.T) {
+	c := config.NewConfig()
+	require.ErrorContains(t, c.LoadAll("./testdata/output_max_inflight_batches.toml"),
+		"plugin outputs.http does not support concurrent writes")
+}
+
 func TestConfig_Filtering(t *testing.T) {
 	c := config.NewConfig()
 	require.NoError(t, c.LoadAll("./testdata/filter_metricpass.toml"))
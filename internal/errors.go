@@ -1,6 +1,10 @@
 package internal
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
 
 var (
 	ErrNotConnected     = errors.New("not connected")
@@ -61,3 +65,43 @@ func (e *PartialWriteError) Error() string {
 func (e *PartialWriteError) Unwrap() error {
 	return e.Err
 }
+
+// Reject marks the metric with the given index as rejected due to the error.
+func (e *PartialWriteError) Reject(idx int, err error) {
+	e.MetricsReject = append(e.MetricsReject, idx)
+	e.MetricsRejectErrors = append(e.MetricsRejectErrors, err)
+}
+
+// Result returns the error to report for the write. The metrics neither
+// accepted nor rejected are kept for retrying with the given error. Without
+// error and rejected metrics the write succeeded and nil is returned.
+func (e *PartialWriteError) Result(err error) error {
+	if err == nil && len(e.MetricsReject) == 0 {
+		return nil
+	}
+	e.Err = err
+	if e.Err == nil {
+		e.Err = fmt.Errorf("dropped %d metrics due to permanent errors", len(e.MetricsReject))
+	}
+	return e
+}
+
+// WriteError classifies an error of an output write. Permanent errors denote
+// that the metrics can never be written successfully, e.g. due to invalid
+// values, so the metrics are rejected instead of being retried. All other
+// errors are retryable and the 'RetryAfter' duration can be used to hint at
+// the minimum time to wait before retrying the write, e.g. when being
+// rate-limited by the endpoint.
+type WriteError struct {
+	Err        error
+	Permanent  bool
+	RetryAfter time.Duration
+}
+
+func (e *WriteError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *WriteError) Unwrap() error {
+	return e.Err
+}
@@ -7,7 +7,9 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
@@ -225,3 +227,19 @@ func OnClientError(client *http.Client, err error) {
 		client.CloseIdleConnections()
 	}
 }
+
+// ParseRetryAfter returns the delay given by a 'Retry-After' header value
+// either specified in seconds or as HTTP date. Invalid values or dates in the
+// past result in a zero delay.
+func ParseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(time.Until(t), 0)
+	}
+	return 0
+}
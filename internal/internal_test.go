@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"compress/gzip"
 	"crypto/rand"
+	"errors"
 	"io"
 	"log"
+	"net/http"
 	"os/exec"
 	"regexp"
 	"sync"
@@ -782,3 +784,29 @@ func TestProductToken(t *testing.T) {
 	re := regexp.MustCompile(`^Telegraf/[^\s]+ Go/\d+.\d+(.\d+)?$`)
 	require.True(t, re.MatchString(token), token)
 }
+
+func TestParseRetryAfter(t *testing.T) {
+	require.Equal(t, 120*time.Second, ParseRetryAfter("120"))
+	require.Zero(t, ParseRetryAfter(""))
+	require.Zero(t, ParseRetryAfter("-5"))
+	require.Zero(t, ParseRetryAfter("soon"))
+	require.Zero(t, ParseRetryAfter("Wed, 21 Oct 2015 07:28:00 GMT"))
+
+	delay := ParseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	require.Greater(t, delay, 59*time.Minute)
+	require.LessOrEqual(t, delay, time.Hour)
+}
+
+func TestPartialWriteErrorResult(t *testing.T) {
+	writeErr := &PartialWriteError{MetricsAccept: []int{0}}
+	require.NoError(t, writeErr.Result(nil))
+
+	writeErr.Reject(1, errors.New("invalid"))
+	require.Equal(t, []int{1}, writeErr.MetricsReject)
+	require.Len(t, writeErr.MetricsRejectErrors, 1)
+	require.EqualError(t, writeErr.Result(nil), "dropped 1 metrics due to permanent errors")
+
+	err := writeErr.Result(ErrNotConnected)
+	require.ErrorIs(t, err, ErrNotConnected)
+	require.ErrorAs(t, err, &writeErr)
+}
@@ -23,8 +23,9 @@ type DiskBuffer struct {
 	file *wal.Log
 	path string
 
-	batchFirst uint64 // Index of the first metric in the batch
-	batchSize  uint64 // Number of metrics currently in the batch
+	// Indices of the metrics currently in unfinished transactions. Those
+	// metrics must not be contained in new batches.
+	inflight map[uint64]bool
 
 	// Ending point of metrics read from disk on telegraf launch.
 	// Used to know whether to discard tracking metrics.
@@ -52,6 +53,7 @@ func NewDiskBuffer(id, path string, stats BufferStats) (*DiskBuffer, error) {
 		BufferStats: stats,
 		file:        walFile,
 		path:        filePath,
+		inflight:    make(map[uint64]bool),
 	}
 	if buf.Len() > 0 {
 		buf.originalEnd = buf.writeIndex()
@@ -133,24 +135,28 @@ func (b *DiskBuffer) BeginTransaction(batchSize int) *Transaction {
 	if b.length() == 0 {
 		return &Transaction{}
 	}
-	b.batchFirst = b.readIndex()
-	b.batchSize = 0
 
 	metrics := make([]telegraf.Metric, 0, batchSize)
-	offsets := make([]int, 0, batchSize)
-	readIndex := b.batchFirst
+	indices := make([]uint64, 0, batchSize)
+	readIndex := b.readIndex()
 	endIndex := b.writeIndex()
 	for offset := 0; batchSize > 0 && readIndex < endIndex; offset++ {
-		data, err := b.file.Read(readIndex)
-		if err != nil {
-			panic(err)
-		}
+		index := readIndex
 		readIndex++
 
 		if slices.Contains(b.mask, offset) {
 			// Metric is masked by a previous write and is scheduled for removal
 			continue
 		}
+		if b.inflight[index] {
+			// Metric is part of another unfinished transaction
+			continue
+		}
+
+		data, err := b.file.Read(index)
+		if err != nil {
+			panic(err)
+		}
 
 		// Validate that a tracking metric is from this instance of telegraf and skip ones from older instances.
 		// A tracking metric can be skipped here because metric.Accept() is only called once data is successfully
@@ -181,11 +187,11 @@ func (b *DiskBuffer) BeginTransaction(batchSize int) *Transaction {
 		}
 
 		metrics = append(metrics, m)
-		offsets = append(offsets, offset)
-		b.batchSize++
+		indices = append(indices, index)
+		b.inflight[index] = true
 		batchSize--
 	}
-	return &Transaction{Batch: metrics, valid: true, state: offsets}
+	return &Transaction{Batch: metrics, valid: true, state: indices}
 }
 
 func (b *DiskBuffer) EndTransaction(tx *Transaction) {
@@ -199,21 +205,30 @@ func (b *DiskBuffer) EndTransaction(tx *Transaction) {
 	}
 	tx.valid = false
 
-	// Get the metric offsets from the transaction
-	offsets := tx.state.([]int)
+	// Get the metric indices from the transaction
+	indices := tx.state.([]uint64)
 
 	b.Lock()
 	defer b.Unlock()
 
-	// Mark metrics which should be removed in the internal mask
+	// Release the metrics of the transaction so kept metrics are contained in
+	// new batches again
+	for _, index := range indices {
+		delete(b.inflight, index)
+	}
+
+	// Mark metrics which should be removed in the internal mask. The offsets
+	// are relative to the current front of the WAL file as other transactions
+	// might have truncated the file in the meantime.
+	readIndex := b.readIndex()
 	remove := make([]int, 0, len(tx.Accept)+len(tx.Reject))
 	for _, idx := range tx.Accept {
 		b.metricWritten(tx.Batch[idx])
-		remove = append(remove, offsets[idx])
+		remove = append(remove, int(indices[idx]-readIndex))
 	}
 	for _, idx := range tx.Reject {
 		b.metricRejected(tx.Batch[idx])
-		remove = append(remove, offsets[idx])
+		remove = append(remove, int(indices[idx]-readIndex))
 	}
 	b.mask = append(b.mask, remove...)
 	sort.Ints(b.mask)
@@ -227,18 +242,13 @@ func (b *DiskBuffer) EndTransaction(tx *Transaction) {
 	}
 
 	// Determine up to which index we can remove the entries from the WAL file
-	var correction int
+	var removeIdx int
 	for i, offset := range b.mask {
 		if offset != i {
 			break
 		}
-		correction = offset
+		removeIdx = offset + 1
 	}
-	// The 'correction' denotes the offset to subtract from the remaining mask
-	// (if any) and the 'removalIdx' denotes the index to use when truncating
-	// the file and mask. Keep them separate to be able to handle the special
-	// "the file cannot be empty" property of the WAL file.
-	removeIdx := correction + 1
 
 	// Remove the metrics in front from the WAL file
 	b.isEmpty = b.entries()-removeIdx <= 0
@@ -247,15 +257,16 @@ func (b *DiskBuffer) EndTransaction(tx *Transaction) {
 		// item to not throw an error
 		removeIdx--
 	}
-	if err := b.file.TruncateFront(b.batchFirst + uint64(removeIdx)); err != nil {
-		log.Printf("E! batch length: %d, first: %d, size: %d", len(tx.Batch), b.batchFirst, b.batchSize)
+	if err := b.file.TruncateFront(readIndex + uint64(removeIdx)); err != nil {
+		log.Printf("E! batch length: %d, first: %d, size: %d", len(tx.Batch), readIndex, len(indices))
 		panic(err)
 	}
 
-	// Truncate the mask and update the relative offsets
+	// Truncate the mask and update the relative offsets to the new front of
+	// the WAL file
 	b.mask = b.mask[removeIdx:]
 	for i := range b.mask {
-		b.mask[i] -= correction
+		b.mask[i] -= removeIdx
 	}
 
 	// check if the original end index is still valid, clear if not
@@ -263,7 +274,6 @@ func (b *DiskBuffer) EndTransaction(tx *Transaction) {
 		b.originalEnd = 0
 	}
 
-	b.resetBatch()
 	b.BufferSize.Set(int64(b.length()))
 }
 
@@ -288,11 +298,6 @@ func (b *DiskBuffer) Close() error {
 	return nil
 }
 
-func (b *DiskBuffer) resetBatch() {
-	b.batchFirst = 0
-	b.batchSize = 0
-}
-
 // This is very messy and not ideal, but serves as the only way I can find currently
 // to actually treat the walfile as empty if needed, since Truncate() calls require
 // that at least one entry remains in them otherwise they return an error.
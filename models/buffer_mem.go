@@ -17,8 +17,7 @@ type MemoryBuffer struct {
 	size  int // number of metrics currently in the buffer
 	cap   int // the capacity of the buffer
 
-	batchFirst int // index of the first metric in the batch
-	batchSize  int // number of metrics currently in the batch
+	inflight int // number of metrics currently in unfinished transactions
 }
 
 func NewMemoryBuffer(capacity int, stats BufferStats) (*MemoryBuffer, error) {
@@ -60,9 +59,7 @@ func (b *MemoryBuffer) BeginTransaction(batchSize int) *Transaction {
 		return &Transaction{}
 	}
 
-	b.batchFirst = b.first
-	b.batchSize = outLen
-	batchIndex := b.batchFirst
+	batchIndex := b.first
 	batch := make([]telegraf.Metric, outLen)
 	for i := range batch {
 		batch[i] = b.buf[batchIndex]
@@ -70,8 +67,9 @@ func (b *MemoryBuffer) BeginTransaction(batchSize int) *Transaction {
 		batchIndex = b.next(batchIndex)
 	}
 
-	b.first = b.nextby(b.first, b.batchSize)
+	b.first = b.nextby(b.first, outLen)
 	b.size -= outLen
+	b.inflight += outLen
 	return &Transaction{Batch: batch, valid: true}
 }
 
@@ -84,6 +82,7 @@ func (b *MemoryBuffer) EndTransaction(tx *Transaction) {
 		return
 	}
 	tx.valid = false
+	b.inflight -= len(tx.Batch)
 
 	// Accept metrics
 	for _, idx := range tx.Accept {
@@ -95,7 +94,9 @@ func (b *MemoryBuffer) EndTransaction(tx *Transaction) {
 		b.metricRejected(tx.Batch[idx])
 	}
 
-	// Keep metrics
+	// Keep metrics by putting them back to the front of the buffer. In case
+	// multiple transactions finish out of order, the kept metrics of the
+	// later finishing transaction end up in front.
 	keep := tx.InferKeep()
 	if len(keep) > 0 {
 		restore := min(len(keep), b.cap-b.size)
@@ -115,7 +116,6 @@ func (b *MemoryBuffer) EndTransaction(tx *Transaction) {
 		}
 	}
 
-	b.resetBatch()
 	b.BufferSize.Set(int64(b.length()))
 }
 
@@ -128,7 +128,7 @@ func (b *MemoryBuffer) Stats() BufferStats {
 }
 
 func (b *MemoryBuffer) length() int {
-	return min(b.size+b.batchSize, b.cap)
+	return min(b.size+b.inflight, b.cap)
 }
 
 func (b *MemoryBuffer) addMetric(m telegraf.Metric) int {
@@ -137,11 +137,6 @@ func (b *MemoryBuffer) addMetric(m telegraf.Metric) int {
 	if b.size == b.cap {
 		b.metricDropped(b.buf[b.last])
 		dropped++
-
-		if b.batchSize > 0 {
-			b.batchSize--
-			b.batchFirst = b.next(b.batchFirst)
-		}
 	}
 
 	b.metricAdded()
@@ -183,8 +178,3 @@ func (b *MemoryBuffer) prevby(index, count int) int {
 	index %= b.cap
 	return index
 }
-
-func (b *MemoryBuffer) resetBatch() {
-	b.batchFirst = 0
-	b.batchSize = 0
-}
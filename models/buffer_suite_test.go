@@ -934,6 +934,114 @@ func (s *BufferSuiteTest) TestPartialWriteBackToFront() {
 	s.Equal(int64(0), buf.Stats().MetricsDropped.Get(), "metrics dropped")
 }
 
+func (s *BufferSuiteTest) TestPartialWriteNonContiguous() {
+	buf := s.newTestBuffer(10)
+	defer buf.Close()
+
+	metrics := make([]telegraf.Metric, 0, 8)
+	for i := range 8 {
+		m := metric.New("cpu", map[string]string{}, map[string]interface{}{"value": 42.0}, time.Unix(int64(i), 0))
+		buf.Add(m)
+		metrics = append(metrics, m)
+	}
+
+	// Accept a contiguous block at the front and another metric with a gap
+	tx := buf.BeginTransaction(6)
+	tx.Accept = []int{0, 1, 2, 5}
+	buf.EndTransaction(tx)
+	s.Equal(4, buf.Len())
+
+	// The next batch must only contain the metrics not yet accepted
+	tx = buf.BeginTransaction(10)
+	testutil.RequireMetricsEqual(s.T(), []telegraf.Metric{metrics[3], metrics[4], metrics[6], metrics[7]}, tx.Batch)
+	tx.AcceptAll()
+	buf.EndTransaction(tx)
+	s.Equal(0, buf.Len())
+}
+
+func (s *BufferSuiteTest) TestConcurrentTransactions() {
+	buf := s.newTestBuffer(10)
+	defer buf.Close()
+
+	metrics := make([]telegraf.Metric, 0, 6)
+	for i := range 6 {
+		m := metric.New("cpu", map[string]string{}, map[string]interface{}{"value": 42.0}, time.Unix(int64(i), 0))
+		buf.Add(m)
+		metrics = append(metrics, m)
+	}
+
+	// Start multiple transactions which must not overlap
+	tx1 := buf.BeginTransaction(2)
+	testutil.RequireMetricsEqual(s.T(), metrics[0:2], tx1.Batch)
+	tx2 := buf.BeginTransaction(2)
+	testutil.RequireMetricsEqual(s.T(), metrics[2:4], tx2.Batch)
+	s.Equal(6, buf.Len())
+
+	// Finish the transactions out of order
+	tx2.AcceptAll()
+	buf.EndTransaction(tx2)
+	s.Equal(4, buf.Len())
+
+	tx1.Reject = []int{0}
+	buf.EndTransaction(tx1)
+	s.Equal(3, buf.Len())
+
+	// The kept metric must be available again together with the remaining ones
+	tx := buf.BeginTransaction(10)
+	testutil.RequireMetricsEqual(s.T(), []telegraf.Metric{metrics[1], metrics[4], metrics[5]}, tx.Batch)
+	tx.AcceptAll()
+	buf.EndTransaction(tx)
+	s.Equal(0, buf.Len())
+
+	s.Equal(int64(6), buf.Stats().MetricsAdded.Get(), "metrics added")
+	s.Equal(int64(5), buf.Stats().MetricsWritten.Get(), "metrics written")
+	s.Equal(int64(1), buf.Stats().MetricsRejected.Get(), "metrics rejected")
+	s.Equal(int64(0), buf.Stats().MetricsDropped.Get(), "metrics dropped")
+}
+
+func (s *BufferSuiteTest) TestConcurrentTransactionsKeepOutOfOrder() {
+	buf := s.newTestBuffer(10)
+	defer buf.Close()
+
+	metrics := make([]telegraf.Metric, 0, 6)
+	for i := range 6 {
+		m := metric.New("cpu", map[string]string{}, map[string]interface{}{"value": 42.0}, time.Unix(int64(i), 0))
+		buf.Add(m)
+		metrics = append(metrics, m)
+	}
+
+	tx1 := buf.BeginTransaction(2)
+	tx2 := buf.BeginTransaction(2)
+	tx3 := buf.BeginTransaction(2)
+	testutil.RequireMetricsEqual(s.T(), metrics[4:6], tx3.Batch)
+
+	// A transaction started while all metrics are in flight must be empty
+	tx := buf.BeginTransaction(2)
+	s.Empty(tx.Batch)
+	buf.EndTransaction(tx)
+
+	// Keep the metrics of the first and last transaction and accept the
+	// middle one with new metrics arriving in between
+	tx1.KeepAll()
+	buf.EndTransaction(tx1)
+	m := metric.New("cpu", map[string]string{}, map[string]interface{}{"value": 42.0}, time.Unix(6, 0))
+	buf.Add(m)
+	tx2.AcceptAll()
+	buf.EndTransaction(tx2)
+	tx3.KeepAll()
+	buf.EndTransaction(tx3)
+	s.Equal(5, buf.Len())
+
+	// All kept metrics must be retried exactly once
+	expected := []telegraf.Metric{metrics[0], metrics[1], metrics[4], metrics[5], m}
+	tx = buf.BeginTransaction(10)
+	testutil.RequireMetricsEqual(s.T(), expected, tx.Batch, testutil.SortMetrics())
+	tx.AcceptAll()
+	buf.EndTransaction(tx)
+	s.Equal(0, buf.Len())
+	s.Equal(int64(7), buf.Stats().MetricsWritten.Get(), "metrics written")
+}
+
 type mockMetric struct {
 	telegraf.Metric
 	AcceptF func()
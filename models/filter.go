@@ -142,12 +142,7 @@ func (f *Filter) Select(metric telegraf.Metric) (bool, error) {
 	}
 
 	if f.metricFilter != nil {
-		result, _, err := f.metricFilter.Eval(map[string]interface{}{
-			"name":   metric.Name(),
-			"tags":   metric.Tags(),
-			"fields": metric.Fields(),
-			"time":   metric.Time(),
-		})
+		result, _, err := f.metricFilter.Eval(MetricActivation(metric))
 		if err != nil {
 			return true, err
 		}
@@ -232,21 +227,12 @@ func (f *Filter) filterTags(metric telegraf.Metric) {
 	}
 }
 
-// Compile the metric filter
-func (f *Filter) compileMetricFilter() error {
-	// Reset internal state
-	f.metricFilter = nil
-
-	// Initialize the expression
-	expression := f.MetricPass
-
-	// Check if we need to call into CEL at all and quit early
-	if expression == "" {
-		return nil
-	}
-
-	// Declare the computation environment for the filter including custom functions
-	env, err := cel.NewEnv(
+// NewMetricEnvironment creates the CEL environment used for metric expressions
+// declaring the "name", "tags", "fields" and "time" variables of the metric
+// as well as the available extension functions. Options can be used to
+// further extend the environment.
+func NewMetricEnvironment(opts ...cel.EnvOption) (*cel.Env, error) {
+	options := []cel.EnvOption{
 		cel.VariableDecls(
 			decls.NewVariable("name", types.StringType),
 			decls.NewVariable("tags", types.NewMapType(types.StringType, types.StringType)),
@@ -261,7 +247,36 @@ func (f *Filter) compileMetricFilter() error {
 		ext.Encoders(),
 		ext.Math(),
 		ext.Strings(),
-	)
+	}
+	return cel.NewEnv(append(options, opts...)...)
+}
+
+// MetricActivation returns the variables of the given metric for evaluating
+// programs compiled in an environment created by NewMetricEnvironment
+func MetricActivation(metric telegraf.Metric) map[string]interface{} {
+	return map[string]interface{}{
+		"name":   metric.Name(),
+		"tags":   metric.Tags(),
+		"fields": metric.Fields(),
+		"time":   metric.Time(),
+	}
+}
+
+// Compile the metric filter
+func (f *Filter) compileMetricFilter() error {
+	// Reset internal state
+	f.metricFilter = nil
+
+	// Initialize the expression
+	expression := f.MetricPass
+
+	// Check if we need to call into CEL at all and quit early
+	if expression == "" {
+		return nil
+	}
+
+	// Declare the computation environment for the filter including custom functions
+	env, err := NewMetricEnvironment()
 	if err != nil {
 		return fmt.Errorf("creating environment failed: %w", err)
 	}
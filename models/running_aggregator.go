@@ -1,6 +1,8 @@
 package models
 
 import (
+	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -12,11 +14,13 @@ import (
 
 type RunningAggregator struct {
 	sync.Mutex
-	Aggregator  telegraf.Aggregator
-	Config      *AggregatorConfig
-	periodStart time.Time
-	periodEnd   time.Time
-	log         telegraf.Logger
+	Aggregator telegraf.Aggregator
+	Config     *AggregatorConfig
+	instances  []telegraf.Aggregator
+	windows    []*aggregationWindow
+	windowTag  string
+	pushTag    string
+	log        telegraf.Logger
 
 	MetricsPushed   selfstat.Stat
 	MetricsFiltered selfstat.Stat
@@ -24,7 +28,24 @@ type RunningAggregator struct {
 	PushTime        selfstat.Stat
 }
 
-func NewRunningAggregator(aggregator telegraf.Aggregator, config *AggregatorConfig) *RunningAggregator {
+// aggregationWindow is a single aggregation range of the given period handled
+// by one instance of the aggregator plugin. Sliding windows of a period are
+// staggered by the hop size, i.e. slot n of a period ends n hops after slot 0.
+type aggregationWindow struct {
+	aggregator telegraf.Aggregator
+	period     time.Duration
+	hop        time.Duration
+	slot       int
+	start      time.Time
+	end        time.Time
+	tag        string
+}
+
+// NewRunningAggregator creates a new running aggregator. For sliding windows
+// or multiple periods the aggregator needs one plugin instance per window, see
+// AggregatorConfig.Instances; the instances are passed in addition to the
+// primary aggregator instance.
+func NewRunningAggregator(aggregator telegraf.Aggregator, config *AggregatorConfig, instances ...telegraf.Aggregator) *RunningAggregator {
 	tags := map[string]string{
 		"_id":        config.ID,
 		"aggregator": config.Name,
@@ -42,10 +63,42 @@ func NewRunningAggregator(aggregator telegraf.Aggregator, config *AggregatorConf
 		logger.Error(err)
 	}
 	SetLoggerOnPlugin(aggregator, logger)
+	for _, instance := range instances {
+		SetLoggerOnPlugin(instance, logger)
+	}
+
+	// Distribute the instances over the windows of all periods
+	instances = append([]telegraf.Aggregator{aggregator}, instances...)
+	windows := make([]*aggregationWindow, 0, len(instances))
+	for _, period := range config.AllPeriods() {
+		hop := config.HopSize(period)
+		for slot := range config.slots(period) {
+			if len(windows) == len(instances) {
+				break
+			}
+			windows = append(windows, &aggregationWindow{
+				aggregator: instances[len(windows)],
+				period:     period,
+				hop:        hop,
+				slot:       slot,
+				tag:        formatWindow(period),
+			})
+		}
+	}
+
+	// Only tag the output if there is more than a single tumbling window
+	// to stay compatible with the plain period setting.
+	var windowTag string
+	if len(config.Periods) > 1 || config.Hop > 0 {
+		windowTag = config.WindowTag
+	}
 
 	return &RunningAggregator{
 		Aggregator: aggregator,
 		Config:     config,
+		instances:  instances,
+		windows:    windows,
+		windowTag:  windowTag,
 		MetricsPushed: selfstat.Register(
 			"aggregate",
 			"metrics_pushed",
@@ -70,6 +123,10 @@ func NewRunningAggregator(aggregator telegraf.Aggregator, config *AggregatorConf
 	}
 }
 
+// maxAggregatorWindows limits the number of windows, i.e. aggregator plugin
+// instances, of a single aggregator as each instance keeps its own state.
+const maxAggregatorWindows = 100
+
 // AggregatorConfig is the common config for all aggregators.
 type AggregatorConfig struct {
 	Name         string
@@ -78,6 +135,9 @@ type AggregatorConfig struct {
 	ID           string
 	DropOriginal bool
 	Period       time.Duration
+	Periods      []time.Duration
+	Hop          time.Duration
+	WindowTag    string
 	Delay        time.Duration
 	Grace        time.Duration
 	LogLevel     string
@@ -89,15 +149,85 @@ type AggregatorConfig struct {
 	Filter            Filter
 }
 
+// AllPeriods returns the window sizes of the aggregator, i.e. the configured
+// periods or the single period if no periods are given.
+func (c *AggregatorConfig) AllPeriods() []time.Duration {
+	if len(c.Periods) > 0 {
+		return c.Periods
+	}
+	return []time.Duration{c.Period}
+}
+
+// HopSize returns the hop size of the windows with the given period. Without
+// a hop, windows are tumbling and the hop is equal to the period.
+func (c *AggregatorConfig) HopSize(period time.Duration) time.Duration {
+	if c.Hop <= 0 {
+		return period
+	}
+	return c.Hop
+}
+
+// Instances returns the number of aggregator plugin instances required to
+// handle all windows, i.e. one per sliding window of each period.
+func (c *AggregatorConfig) Instances() int {
+	var n int
+	for _, period := range c.AllPeriods() {
+		n += c.slots(period)
+	}
+	return n
+}
+
+// slots returns the number of sliding windows of the given period.
+func (c *AggregatorConfig) slots(period time.Duration) int {
+	hop := c.HopSize(period)
+	if hop <= 0 || period < hop {
+		return 1
+	}
+	return int(period / hop)
+}
+
+// Validate checks the window settings of the aggregator.
+func (c *AggregatorConfig) Validate() error {
+	seen := make(map[time.Duration]bool, len(c.AllPeriods()))
+	for _, period := range c.AllPeriods() {
+		if period <= 0 {
+			return fmt.Errorf("period %s must be positive", period)
+		}
+		if seen[period] {
+			return fmt.Errorf("duplicate period %s", period)
+		}
+		seen[period] = true
+
+		if c.Hop < 0 {
+			return fmt.Errorf("hop %s must not be negative", c.Hop)
+		}
+		if hop := c.HopSize(period); hop > period || period%hop != 0 {
+			return fmt.Errorf("period %s is not a multiple of hop %s", period, hop)
+		}
+	}
+	if n := c.Instances(); n > maxAggregatorWindows {
+		return fmt.Errorf("%d windows exceed the maximum of %d, increase the hop or reduce the periods", n, maxAggregatorWindows)
+	}
+	return nil
+}
+
 func (r *RunningAggregator) LogName() string {
 	return logName("aggregators", r.Config.Name, r.Config.Alias)
 }
 
 func (r *RunningAggregator) Init() error {
-	if p, ok := r.Aggregator.(telegraf.Initializer); ok {
-		err := p.Init()
-		if err != nil {
-			return err
+	if err := r.Config.Validate(); err != nil {
+		return err
+	}
+	if expected := r.Config.Instances(); len(r.instances) != expected {
+		return fmt.Errorf("expected %d aggregator instances but got %d", expected, len(r.instances))
+	}
+
+	for _, instance := range r.instances {
+		if p, ok := instance.(telegraf.Initializer); ok {
+			if err := p.Init(); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
@@ -114,14 +244,47 @@ func (r *RunningAggregator) Period() time.Duration {
 	return r.Config.Period
 }
 
+// Periods returns the window sizes of the aggregator.
+func (r *RunningAggregator) Periods() []time.Duration {
+	return r.Config.AllPeriods()
+}
+
+// Hop returns the hop size of the windows with the given period.
+func (r *RunningAggregator) Hop(period time.Duration) time.Duration {
+	return r.Config.HopSize(period)
+}
+
+// EndPeriod returns the end of the window finishing first.
 func (r *RunningAggregator) EndPeriod() time.Time {
-	return r.periodEnd
+	r.Lock()
+	defer r.Unlock()
+
+	return r.endPeriod()
+}
+
+func (r *RunningAggregator) endPeriod() time.Time {
+	var end time.Time
+	for _, w := range r.windows {
+		if end.IsZero() || w.end.Before(end) {
+			end = w.end
+		}
+	}
+	return end
 }
 
+// UpdateWindow sets the aggregation range of the first window with the
+// period of the given range to [start, until]. For sliding windows, the
+// remaining windows of that period are staggered by the hop size.
 func (r *RunningAggregator) UpdateWindow(start, until time.Time) {
-	r.periodStart = start
-	r.periodEnd = until
-	r.log.Debugf("Updated aggregation range [%s, %s]", start, until)
+	period := until.Sub(start)
+	for _, w := range r.windows {
+		if w.period != period {
+			continue
+		}
+		w.end = until.Add(time.Duration(w.slot) * w.hop)
+		w.start = w.end.Add(-period)
+		r.log.Debugf("Updated aggregation range [%s, %s]", w.start, w.end)
+	}
 }
 
 func (r *RunningAggregator) MakeMetric(telegrafMetric telegraf.Metric) telegraf.Metric {
@@ -133,6 +296,11 @@ func (r *RunningAggregator) MakeMetric(telegrafMetric telegraf.Metric) telegraf.
 		r.Config.Tags,
 		nil)
 
+	// Tag the metric with the size of the window currently pushed
+	if r.windowTag != "" && r.pushTag != "" {
+		m.AddTag(r.windowTag, r.pushTag)
+	}
+
 	r.MetricsPushed.Incr(1)
 
 	return m
@@ -163,23 +331,75 @@ func (r *RunningAggregator) Add(m telegraf.Metric) bool {
 	r.Lock()
 	defer r.Unlock()
 
-	if m.Time().Before(r.periodStart.Add(-r.Config.Grace)) || m.Time().After(r.periodEnd.Add(r.Config.Delay)) {
+	// Add the metric to all windows containing its timestamp. All but the
+	// first window get a copy as aggregators might keep the metric.
+	var added bool
+	for _, w := range r.windows {
+		if m.Time().Before(w.start.Add(-r.Config.Grace)) || m.Time().After(w.end.Add(r.Config.Delay)) {
+			continue
+		}
+		if added {
+			w.aggregator.Add(m.Copy())
+		} else {
+			w.aggregator.Add(m)
+			added = true
+		}
+	}
+
+	if !added {
 		r.log.Debugf("Metric is outside aggregation window; discarding. %s: m: %s e: %s g: %s",
-			m.Time(), r.periodStart, r.periodEnd, r.Config.Grace)
+			m.Time(), r.startPeriod(), r.endPeriod(), r.Config.Grace)
 		r.MetricsDropped.Incr(1)
-		return r.Config.DropOriginal
 	}
-
-	r.Aggregator.Add(m)
 	return r.Config.DropOriginal
 }
 
+func (r *RunningAggregator) startPeriod() time.Time {
+	var start time.Time
+	for _, w := range r.windows {
+		if start.IsZero() || w.start.Before(start) {
+			start = w.start
+		}
+	}
+	return start
+}
+
+// Push emits and resets all windows finishing first and moves them to their
+// next aggregation range.
 func (r *RunningAggregator) Push(acc telegraf.Accumulator) {
 	r.Lock()
 	defer r.Unlock()
 
-	since := r.periodEnd
-	until := r.periodEnd.Add(r.Config.Period)
+	end := r.endPeriod()
+	for _, w := range r.windows {
+		if !w.end.After(end) {
+			r.push(w, acc)
+		}
+	}
+}
+
+// Flush emits and resets the window finishing first for each period, e.g. on
+// shutdown, so that every period reports its partial aggregation range.
+func (r *RunningAggregator) Flush(acc telegraf.Accumulator) {
+	r.Lock()
+	defer r.Unlock()
+
+	first := make(map[time.Duration]*aggregationWindow, len(r.windows))
+	for _, w := range r.windows {
+		if f, found := first[w.period]; !found || w.end.Before(f.end) {
+			first[w.period] = w
+		}
+	}
+	for _, w := range r.windows {
+		if first[w.period] == w {
+			r.push(w, acc)
+		}
+	}
+}
+
+func (r *RunningAggregator) push(w *aggregationWindow, acc telegraf.Accumulator) {
+	since := w.end
+	until := w.end.Add(w.period)
 
 	// Check if the next aggregation window will contain "now". This might
 	// not be the case if the machine's clock was adjusted or the machine
@@ -187,19 +407,36 @@ func (r *RunningAggregator) Push(acc telegraf.Accumulator) {
 	// after the initial aggregation window.
 	nowWall := time.Now().Truncate(-1)
 	if nowWall.Before(since.Truncate(-1)) || nowWall.After(until.Truncate(-1)) {
-		since = nowWall.Truncate(r.Config.Period)
-		until = since.Add(r.Config.Period)
+		until = nowWall.Truncate(w.hop).Add(time.Duration(w.slot+1) * w.hop)
+		since = until.Add(-w.period)
 	}
 
-	r.UpdateWindow(since, until)
+	w.start, w.end = since, until
+	r.log.Debugf("Updated aggregation range [%s, %s]", since, until)
+
+	r.pushTag = w.tag
+	defer func() { r.pushTag = "" }()
 
 	start := time.Now()
-	r.Aggregator.Push(acc)
+	w.aggregator.Push(acc)
 	elapsed := time.Since(start)
 	r.PushTime.Incr(elapsed.Nanoseconds())
-	r.Aggregator.Reset()
+	w.aggregator.Reset()
 }
 
 func (r *RunningAggregator) Log() telegraf.Logger {
 	return r.log
 }
+
+// formatWindow returns the window size in a compact form, e.g. "1m" instead
+// of "1m0s" or "1h" instead of "1h0m0s".
+func formatWindow(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = s[:len(s)-2]
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = s[:len(s)-2]
+	}
+	return s
+}
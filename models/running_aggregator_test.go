@@ -7,6 +7,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
 	"github.com/influxdata/telegraf/testutil"
 )
 
@@ -239,6 +240,181 @@ func TestRunningAggregatorAddDoesNotModifyMetric(t *testing.T) {
 	testutil.RequireMetricEqual(t, expected, m)
 }
 
+func TestRunningAggregatorInitWindows(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    *AggregatorConfig
+		instances int
+		expected  string
+	}{
+		{
+			name:      "hop not dividing period",
+			config:    &AggregatorConfig{Period: 5 * time.Second, Hop: 2 * time.Second},
+			instances: 2,
+			expected:  "period 5s is not a multiple of hop 2s",
+		},
+		{
+			name:      "hop larger than period",
+			config:    &AggregatorConfig{Period: time.Second, Hop: 2 * time.Second},
+			instances: 1,
+			expected:  "period 1s is not a multiple of hop 2s",
+		},
+		{
+			name:      "duplicate period",
+			config:    &AggregatorConfig{Periods: []time.Duration{time.Minute, time.Minute}},
+			instances: 2,
+			expected:  "duplicate period 1m0s",
+		},
+		{
+			name:      "too many windows",
+			config:    &AggregatorConfig{Period: time.Hour, Hop: 10 * time.Second},
+			instances: 1,
+			expected:  "360 windows exceed the maximum of 100",
+		},
+		{
+			name:      "missing instances",
+			config:    &AggregatorConfig{Periods: []time.Duration{time.Minute, 5 * time.Minute}, Hop: time.Minute},
+			instances: 5,
+			expected:  "expected 6 aggregator instances but got 5",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instances := make([]telegraf.Aggregator, 0, tt.instances-1)
+			for range tt.instances - 1 {
+				instances = append(instances, &mockAggregator{})
+			}
+			ra := NewRunningAggregator(&mockAggregator{}, tt.config, instances...)
+			require.ErrorContains(t, ra.Init(), tt.expected)
+		})
+	}
+}
+
+func TestRunningAggregatorSlidingWindows(t *testing.T) {
+	first := &mockAggregator{}
+	second := &mockAggregator{}
+	ra := NewRunningAggregator(first, &AggregatorConfig{
+		Name:      "TestRunningAggregator",
+		Period:    2 * time.Second,
+		Hop:       time.Second,
+		WindowTag: "window",
+	}, second)
+	require.NoError(t, ra.Init())
+	acc := &makerAccumulator{maker: ra}
+
+	// The first window covers [now-1s, now+1s], the second [now, now+2s]
+	now := time.Now()
+	ra.UpdateWindow(now.Add(-time.Second), now.Add(time.Second))
+	require.Equal(t, now.Add(time.Second), ra.EndPeriod())
+
+	// The first metric only falls into the first window, the second one into
+	// both windows
+	m := testutil.MustMetric("RITest", map[string]string{}, map[string]interface{}{"value": int64(1)}, now.Add(-500*time.Millisecond))
+	require.False(t, ra.Add(m))
+	m = testutil.MustMetric("RITest", map[string]string{}, map[string]interface{}{"value": int64(2)}, now.Add(500*time.Millisecond))
+	require.False(t, ra.Add(m))
+
+	// Only the window ending first is pushed and reset
+	ra.Push(acc)
+	metrics := acc.GetTelegrafMetrics()
+	require.Len(t, metrics, 1)
+	require.Equal(t, map[string]string{"window": "2s"}, metrics[0].Tags())
+	require.Equal(t, map[string]interface{}{"sum": int64(3)}, metrics[0].Fields())
+	require.Zero(t, first.sum)
+	require.Equal(t, int64(2), second.sum)
+}
+
+func TestRunningAggregatorFlushSlidingWindows(t *testing.T) {
+	first := &mockAggregator{}
+	second := &mockAggregator{}
+	ra := NewRunningAggregator(first, &AggregatorConfig{
+		Name:      "TestRunningAggregator",
+		Period:    2 * time.Second,
+		Hop:       time.Second,
+		WindowTag: "window",
+	}, second)
+	require.NoError(t, ra.Init())
+	acc := &makerAccumulator{maker: ra}
+
+	now := time.Now()
+	ra.UpdateWindow(now.Add(-time.Second), now.Add(time.Second))
+
+	m := testutil.MustMetric("RITest", map[string]string{}, map[string]interface{}{"value": int64(1)}, now.Add(-500*time.Millisecond))
+	require.False(t, ra.Add(m))
+	m = testutil.MustMetric("RITest", map[string]string{}, map[string]interface{}{"value": int64(2)}, now.Add(500*time.Millisecond))
+	require.False(t, ra.Add(m))
+
+	// On shutdown, only the partial window ending first is emitted as the
+	// later windows overlap with it
+	ra.Flush(acc)
+	metrics := acc.GetTelegrafMetrics()
+	require.Len(t, metrics, 1)
+	require.Equal(t, map[string]string{"window": "2s"}, metrics[0].Tags())
+	require.Equal(t, map[string]interface{}{"sum": int64(3)}, metrics[0].Fields())
+}
+
+func TestRunningAggregatorMultiplePeriods(t *testing.T) {
+	short := &mockAggregator{}
+	long := &mockAggregator{}
+	ra := NewRunningAggregator(short, &AggregatorConfig{
+		Name:      "TestRunningAggregator",
+		Periods:   []time.Duration{time.Second, time.Hour},
+		WindowTag: "window",
+	}, long)
+	require.NoError(t, ra.Init())
+	acc := &makerAccumulator{maker: ra}
+
+	now := time.Now()
+	ra.UpdateWindow(now, now.Add(time.Second))
+	ra.UpdateWindow(now, now.Add(time.Hour))
+
+	m := testutil.MustMetric("RITest", map[string]string{}, map[string]interface{}{"value": int64(5)}, now.Add(500*time.Millisecond))
+	require.False(t, ra.Add(m))
+
+	// Push only emits the short window while the long one keeps its state
+	ra.Push(acc)
+	metrics := acc.GetTelegrafMetrics()
+	require.Len(t, metrics, 1)
+	require.Equal(t, map[string]string{"window": "1s"}, metrics[0].Tags())
+	require.Equal(t, map[string]interface{}{"sum": int64(5)}, metrics[0].Fields())
+	require.Equal(t, int64(5), long.sum)
+
+	// Flushing emits the current window of each period
+	acc.ClearMetrics()
+	ra.Flush(acc)
+	metrics = acc.GetTelegrafMetrics()
+	require.Len(t, metrics, 2)
+	require.Equal(t, map[string]string{"window": "1s"}, metrics[0].Tags())
+	require.Equal(t, map[string]interface{}{"sum": int64(0)}, metrics[0].Fields())
+	require.Equal(t, map[string]string{"window": "1h"}, metrics[1].Tags())
+	require.Equal(t, map[string]interface{}{"sum": int64(5)}, metrics[1].Fields())
+}
+
+func TestFormatWindow(t *testing.T) {
+	require.Equal(t, "500ms", formatWindow(500*time.Millisecond))
+	require.Equal(t, "10s", formatWindow(10*time.Second))
+	require.Equal(t, "1m", formatWindow(time.Minute))
+	require.Equal(t, "1m30s", formatWindow(90*time.Second))
+	require.Equal(t, "1h", formatWindow(time.Hour))
+	require.Equal(t, "1h30m", formatWindow(90*time.Minute))
+}
+
+// makerAccumulator passes the pushed metrics through the aggregator's
+// MakeMetric like the agent's accumulator does.
+type makerAccumulator struct {
+	testutil.Accumulator
+	maker *RunningAggregator
+}
+
+func (a *makerAccumulator) AddFields(measurement string, fields map[string]interface{}, tags map[string]string, t ...time.Time) {
+	ts := time.Now()
+	if len(t) > 0 {
+		ts = t[0]
+	}
+	a.AddMetric(a.maker.MakeMetric(metric.New(measurement, tags, fields, ts)))
+}
+
 type mockAggregator struct {
 	sum int64
 }
@@ -42,6 +42,19 @@ type OutputConfig struct {
 	BufferStrategy  string
 	BufferDirectory string
 
+	// Alias of the output receiving the metrics rejected by this output
+	DeadLetter string
+
+	// Maximum number of batches written concurrently
+	MaxInflightBatches int
+
+	// Retry policy for failed writes
+	RetryInitialInterval        time.Duration
+	RetryMaxInterval            time.Duration
+	RetryMaxAttempts            int
+	CircuitBreakerThreshold     int
+	CircuitBreakerProbeInterval time.Duration
+
 	LogLevel string
 }
 
@@ -51,21 +64,37 @@ type RunningOutput struct {
 	droppedMetrics  atomic.Int64
 	writeInFlight   atomic.Bool
 	lastWriteFailed atomic.Bool
+	stopping        atomic.Bool
 
 	Output            telegraf.Output
 	Config            *OutputConfig
 	MetricBufferLimit int
 	MetricBatchSize   int
 
-	MetricsFiltered selfstat.Stat
-	WriteTime       selfstat.Stat
-	StartupErrors   selfstat.Stat
+	MetricsFiltered     selfstat.Stat
+	MetricsDeadLettered selfstat.Stat
+	WriteTime           selfstat.Stat
+	StartupErrors       selfstat.Stat
+	ConsecutiveFailures selfstat.Stat
+	CircuitState        selfstat.Stat
+	CircuitTrips        selfstat.Stat
 
 	BatchReady chan time.Time
 
 	buffer Buffer
 	log    telegraf.Logger
 
+	// Output receiving the rejected metrics and flag denoting that this
+	// output only receives rejected metrics of other outputs
+	deadLetter       *RunningOutput
+	isDeadLetterSink bool
+
+	maxInflightBatches int
+
+	retry            retryState
+	retryMaxInterval time.Duration
+	probeInterval    time.Duration
+
 	started bool
 	retries uint64
 
@@ -104,6 +133,20 @@ func NewRunningOutput(output telegraf.Output, config *OutputConfig, batchSize, b
 	if batchSize == 0 {
 		batchSize = DefaultMetricBatchSize
 	}
+	// Outputs not supporting concurrent writes are rejected on config load
+	maxInflightBatches := max(config.MaxInflightBatches, 1)
+	if _, ok := output.(telegraf.ConcurrentOutput); !ok {
+		maxInflightBatches = 1
+	}
+
+	retryMaxInterval := config.RetryMaxInterval
+	if retryMaxInterval <= 0 {
+		retryMaxInterval = DefaultRetryMaxInterval
+	}
+	probeInterval := config.CircuitBreakerProbeInterval
+	if probeInterval <= 0 {
+		probeInterval = DefaultCircuitBreakerProbeInterval
+	}
 
 	b, err := NewBuffer(config.Name, config.ID, config.Alias, bufferLimit, config.BufferStrategy, config.BufferDirectory)
 	if err != nil {
@@ -111,17 +154,26 @@ func NewRunningOutput(output telegraf.Output, config *OutputConfig, batchSize, b
 	}
 
 	ro := &RunningOutput{
-		buffer:            b,
-		BatchReady:        make(chan time.Time, 1),
-		Output:            output,
-		Config:            config,
-		MetricBufferLimit: bufferLimit,
-		MetricBatchSize:   batchSize,
+		buffer:             b,
+		BatchReady:         make(chan time.Time, 1),
+		Output:             output,
+		Config:             config,
+		MetricBufferLimit:  bufferLimit,
+		MetricBatchSize:    batchSize,
+		maxInflightBatches: maxInflightBatches,
+		retry:              retryState{attempts: make(map[uint64]int)},
+		retryMaxInterval:   retryMaxInterval,
+		probeInterval:      probeInterval,
 		MetricsFiltered: selfstat.Register(
 			"write",
 			"metrics_filtered",
 			tags,
 		),
+		MetricsDeadLettered: selfstat.Register(
+			"write",
+			"metrics_dead_lettered",
+			tags,
+		),
 		WriteTime: selfstat.RegisterTiming(
 			"write",
 			"write_time_ns",
@@ -132,6 +184,21 @@ func NewRunningOutput(output telegraf.Output, config *OutputConfig, batchSize, b
 			"startup_errors",
 			tags,
 		),
+		ConsecutiveFailures: selfstat.Register(
+			"write",
+			"consecutive_failures",
+			tags,
+		),
+		CircuitState: selfstat.Register(
+			"write",
+			"circuit_state",
+			tags,
+		),
+		CircuitTrips: selfstat.Register(
+			"write",
+			"circuit_trips",
+			tags,
+		),
 		log: logger,
 	}
 
@@ -154,6 +221,19 @@ func (r *RunningOutput) ID() string {
 	return r.Config.ID
 }
 
+// SetDeadLetter sets the output receiving the metrics rejected by this output.
+// The given output will only receive rejected metrics from now on.
+func (r *RunningOutput) SetDeadLetter(output *RunningOutput) {
+	r.deadLetter = output
+	output.isDeadLetterSink = true
+}
+
+// IsDeadLetterSink returns true if the output only receives metrics rejected
+// by other outputs
+func (r *RunningOutput) IsDeadLetterSink() bool {
+	return r.isDeadLetterSink
+}
+
 func (r *RunningOutput) Init() error {
 	switch r.Config.StartupErrorBehavior {
 	case "", "error", "retry", "ignore":
@@ -239,6 +319,14 @@ func (r *RunningOutput) AddMetricNoCopy(metric telegraf.Metric) {
 	r.add(metric)
 }
 
+// AddDeadLetter adds a metric rejected by another output. Filters and
+// modifications of this output are not applied to keep the metric as it was
+// rejected.
+func (r *RunningOutput) AddDeadLetter(metric telegraf.Metric) {
+	r.droppedMetrics.Add(int64(r.buffer.Add(metric)))
+	r.triggerBatchCheck()
+}
+
 func (r *RunningOutput) add(metric telegraf.Metric) {
 	r.Config.Filter.Modify(metric)
 	if len(metric.FieldList()) == 0 {
@@ -323,17 +411,24 @@ func (r *RunningOutput) Write() error {
 		r.aggMutex.Unlock()
 	}
 
+	// Hold back writing while backing off or with the circuit breaker open
+	if !r.writeAllowed() {
+		return nil
+	}
+
 	// Only process the metrics in the buffer now. Metrics added while we are
 	// writing will be sent on the next call. We can safely add one more write
 	// because 'doTransaction' will abort early for empty batches.
 	nBuffer := r.buffer.Len()
 	nBatches := nBuffer/r.MetricBatchSize + 1
-	for i := 0; i < nBatches; i++ {
-		if err := r.doTransaction(); err != nil {
-			return err
-		}
-	}
-	return nil
+	return r.doTransactions(nBatches)
+}
+
+// WriteFinal writes all metrics to the output ignoring the retry policy. This
+// should be used when shutting down to attempt sending the remaining metrics.
+func (r *RunningOutput) WriteFinal() error {
+	r.stopping.Store(true)
+	return r.Write()
 }
 
 // WriteBatch writes a single batch of metrics to the output.
@@ -357,16 +452,77 @@ func (r *RunningOutput) WriteBatch() error {
 		r.triggerBatchCheck()
 	}()
 
-	return r.doTransaction()
+	// Hold back writing while backing off or with the circuit breaker open
+	if !r.writeAllowed() {
+		return nil
+	}
+
+	// Write all full batches up to the number of allowed in-flight batches
+	nBatches := min(r.buffer.Len()/r.MetricBatchSize, r.maxInflightBatches)
+	return r.doTransactions(max(nBatches, 1))
+}
+
+// doTransactions writes the given number of batches with up to
+// 'max_inflight_batches' transactions being written concurrently. No new batches
+// are started after the first error, which is returned after all in-flight
+// transactions finished.
+func (r *RunningOutput) doTransactions(nBatches int) error {
+	cycle := &writeCycle{}
+
+	// Only send a single probe at a time if the circuit breaker is half-open
+	workers := min(nBatches, r.maxInflightBatches)
+	if workers <= 1 || r.circuitHalfOpen() {
+		for range nBatches {
+			if err := r.doTransaction(cycle); err != nil {
+				return err
+			}
+		}
+		r.cycleSucceeded(cycle)
+		return nil
+	}
+
+	var remaining atomic.Int64
+	remaining.Store(int64(nBatches))
+
+	var mu sync.Mutex
+	var firstErr error
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for remaining.Add(-1) >= 0 {
+				err := r.doTransaction(cycle)
+				if err == nil {
+					continue
+				}
+
+				// Prevent starting new batches
+				remaining.Store(0)
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+				return
+			}
+		}()
+	}
+	wg.Wait()
+
+	if firstErr == nil {
+		r.cycleSucceeded(cycle)
+	}
+	return firstErr
 }
 
-func (r *RunningOutput) doTransaction() error {
+func (r *RunningOutput) doTransaction(cycle *writeCycle) error {
 	tx := r.buffer.BeginTransaction(r.MetricBatchSize)
 	if len(tx.Batch) == 0 {
 		return nil
 	}
 	err := r.writeMetrics(tx.Batch)
-	r.updateTransaction(tx, err)
+	r.updateTransaction(cycle, tx, err)
 	r.buffer.EndTransaction(tx)
 
 	return err
@@ -389,19 +545,42 @@ func (r *RunningOutput) writeMetrics(metrics []telegraf.Metric) error {
 	return err
 }
 
-func (r *RunningOutput) updateTransaction(tx *Transaction, err error) {
+func (r *RunningOutput) updateTransaction(cycle *writeCycle, tx *Transaction, err error) {
+	key := batchKey(tx)
+
 	// No error indicates all metrics were written successfully
 	if err == nil {
 		r.lastWriteFailed.Store(false)
+		r.writeSucceeded(key)
 		tx.AcceptAll()
 		return
 	}
 
-	// A non-partial-write-error indicated none of the metrics were written
-	// successfully and we should keep them for the next write cycle
 	var writeErr *internal.PartialWriteError
 	if !errors.As(err, &writeErr) {
+		// A permanent error indicates that none of the metrics can ever be
+		// written so reject them instead of retrying
+		var werr *internal.WriteError
+		if errors.As(err, &werr) && werr.Permanent {
+			r.lastWriteFailed.Store(false)
+			r.writeRejected(key)
+			tx.Reject = tx.InferKeep()
+			r.sendDeadLetters(tx.Batch, tx.Reject, []error{err})
+			return
+		}
+
+		// A non-partial-write-error indicated none of the metrics were written
+		// successfully and we should keep them for the next write cycle unless
+		// the retry attempts are exhausted
 		r.lastWriteFailed.Store(true)
+		var retryAfter time.Duration
+		if werr != nil {
+			retryAfter = werr.RetryAfter
+		}
+		if r.writeFailed(cycle, key, retryAfter) {
+			r.rejectExhausted(tx, err)
+			return
+		}
 		tx.KeepAll()
 		return
 	}
@@ -412,6 +591,53 @@ func (r *RunningOutput) updateTransaction(tx *Transaction, err error) {
 	r.lastWriteFailed.Store(len(writeErr.MetricsAccept) == 0)
 	tx.Accept = writeErr.MetricsAccept
 	tx.Reject = writeErr.MetricsReject
+
+	r.sendDeadLetters(tx.Batch, tx.Reject, writeErr.MetricsRejectErrors)
+
+	// Consider the write as failed if the output did not make any progress
+	if len(tx.Accept) > 0 || len(tx.Reject) > 0 {
+		r.writeSucceeded(key)
+	} else if r.writeFailed(cycle, key, 0) {
+		r.rejectExhausted(tx, err)
+	}
+}
+
+// rejectExhausted rejects all metrics of the transaction not yet accepted or
+// rejected as the maximum number of retry attempts is reached
+func (r *RunningOutput) rejectExhausted(tx *Transaction, err error) {
+	keep := tx.InferKeep()
+	r.log.Errorf("Rejecting %d metrics after %d failed attempts: %v", len(keep), r.Config.RetryMaxAttempts, err)
+	tx.Reject = append(tx.Reject, keep...)
+	r.sendDeadLetters(tx.Batch, keep, []error{err})
+}
+
+// sendDeadLetters passes copies of the rejected metrics at the given indices
+// to the dead-letter output if any. The given reasons are either one per
+// metric or a single one for all metrics. The copies are not tracked so the
+// rejected metrics are still reported as not delivered to the input.
+func (r *RunningOutput) sendDeadLetters(batch []telegraf.Metric, indices []int, reasons []error) {
+	if r.deadLetter == nil {
+		return
+	}
+
+	for i, idx := range indices {
+		var reason error
+		switch {
+		case i < len(reasons):
+			reason = reasons[i]
+		case len(reasons) == 1:
+			reason = reasons[0]
+		}
+		if reason != nil {
+			r.log.Debugf("Passing rejected metric to dead-letter output: %v", reason)
+		}
+		m := batch[idx]
+		if wm, ok := m.(telegraf.UnwrappableMetric); ok {
+			m = wm.Unwrap()
+		}
+		r.deadLetter.AddDeadLetter(m.Copy())
+		r.MetricsDeadLettered.Incr(1)
+	}
 }
 
 func (r *RunningOutput) LogBufferStatus() {
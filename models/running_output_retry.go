@@ -0,0 +1,186 @@
+package models
+
+import (
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// States of the circuit breaker as exposed in the 'circuit_state' statistic
+const (
+	circuitClosed int64 = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+const (
+	DefaultRetryMaxInterval            = time.Minute
+	DefaultCircuitBreakerProbeInterval = time.Minute
+)
+
+// retryState keeps track of the consecutive write failures of an output in
+// order to back off retries and to open the circuit breaker
+type retryState struct {
+	sync.Mutex
+
+	failures    int            // number of consecutive failed write cycles
+	attempts    map[uint64]int // number of failed attempts per batch
+	nextAttempt time.Time      // earliest time allowed for the next write
+	circuit     int64          // state of the circuit breaker
+}
+
+// writeCycle denotes a single write of the output potentially consisting of
+// multiple, concurrently written batches. Concurrent failures in one cycle
+// only count as a single consecutive failure.
+type writeCycle struct {
+	failed bool
+}
+
+// batchKey identifies a batch across write attempts by its oldest metric, as
+// kept metrics are put back to the front of the buffer.
+func batchKey(tx *Transaction) uint64 {
+	m := tx.Batch[0]
+	return m.HashID() ^ uint64(m.Time().UnixNano())
+}
+
+// writeAllowed checks if the retry policy allows to write now. If the circuit
+// breaker is open and the probe interval elapsed, the circuit transitions
+// into half-open state allowing a single probe write.
+func (r *RunningOutput) writeAllowed() bool {
+	if r.stopping.Load() {
+		return true
+	}
+
+	r.retry.Lock()
+	defer r.retry.Unlock()
+
+	now := time.Now()
+	if now.Before(r.retry.nextAttempt) {
+		r.log.Tracef("Skipping write, next attempt in %s", r.retry.nextAttempt.Sub(now))
+		return false
+	}
+	if r.retry.circuit == circuitOpen {
+		r.log.Debug("Probing output with circuit breaker half-open")
+		r.setCircuit(circuitHalfOpen)
+	}
+	return true
+}
+
+// circuitHalfOpen returns true if the circuit breaker is probing the output
+func (r *RunningOutput) circuitHalfOpen() bool {
+	r.retry.Lock()
+	defer r.retry.Unlock()
+	return r.retry.circuit == circuitHalfOpen
+}
+
+// writeSucceeded resets the retry state after the output made progress
+// writing the batch with the given key
+func (r *RunningOutput) writeSucceeded(key uint64) {
+	r.retry.Lock()
+	defer r.retry.Unlock()
+
+	if r.retry.circuit != circuitClosed {
+		r.log.Infof("Circuit breaker closed after %d consecutive failures", r.retry.failures)
+		r.setCircuit(circuitClosed)
+	}
+	r.retry.failures = 0
+	r.retry.nextAttempt = time.Time{}
+	delete(r.retry.attempts, key)
+	r.ConsecutiveFailures.Set(0)
+}
+
+// writeRejected forgets the attempts of the batch with the given key after
+// the output permanently rejected it. This is neither a success nor a failure
+// of the output so the circuit breaker state is kept.
+func (r *RunningOutput) writeRejected(key uint64) {
+	r.retry.Lock()
+	defer r.retry.Unlock()
+
+	delete(r.retry.attempts, key)
+}
+
+// cycleSucceeded drops the attempts of all batches after a write cycle without
+// any failure, as all previously failed batches were written in that cycle.
+func (r *RunningOutput) cycleSucceeded(cycle *writeCycle) {
+	r.retry.Lock()
+	defer r.retry.Unlock()
+
+	if !cycle.failed {
+		clear(r.retry.attempts)
+	}
+}
+
+// writeFailed updates the retry state after a failed write of the batch with
+// the given key and determines the time of the next write attempt. The
+// returned flag denotes if the maximum number of attempts for the batch is
+// exhausted.
+func (r *RunningOutput) writeFailed(cycle *writeCycle, key uint64, retryAfter time.Duration) bool {
+	r.retry.Lock()
+	defer r.retry.Unlock()
+
+	r.retry.attempts[key]++
+	attempts := r.retry.attempts[key]
+
+	maxAttempts := r.Config.RetryMaxAttempts
+	exhausted := maxAttempts > 0 && attempts >= maxAttempts
+	if exhausted {
+		delete(r.retry.attempts, key)
+	}
+
+	// Only count the first failure of a cycle as concurrently written batches
+	// usually fail for the same reason
+	if cycle.failed {
+		if next := time.Now().Add(retryAfter); next.After(r.retry.nextAttempt) {
+			r.retry.nextAttempt = next
+		}
+		return exhausted
+	}
+	cycle.failed = true
+	r.retry.failures++
+	r.ConsecutiveFailures.Set(int64(r.retry.failures))
+
+	now := time.Now()
+	threshold := r.Config.CircuitBreakerThreshold
+	switch {
+	case r.retry.circuit == circuitHalfOpen:
+		r.log.Debug("Probing output failed, circuit breaker open")
+		r.setCircuit(circuitOpen)
+		r.retry.nextAttempt = now.Add(r.probeInterval)
+	case threshold > 0 && r.retry.failures >= threshold:
+		r.log.Warnf("Circuit breaker opened after %d consecutive failures, probing every %s",
+			r.retry.failures, r.probeInterval)
+		r.setCircuit(circuitOpen)
+		r.CircuitTrips.Incr(1)
+		r.retry.nextAttempt = now.Add(r.probeInterval)
+	case r.Config.RetryInitialInterval > 0:
+		wait := r.backoff(r.retry.failures)
+		r.log.Debugf("Retrying write in %s", wait)
+		r.retry.nextAttempt = now.Add(wait)
+	}
+	if next := now.Add(retryAfter); next.After(r.retry.nextAttempt) {
+		r.retry.nextAttempt = next
+	}
+
+	return exhausted
+}
+
+// backoff computes the exponential backoff for the given number of failures
+// with the upper half of the interval being randomized
+func (r *RunningOutput) backoff(failures int) time.Duration {
+	interval := r.Config.RetryInitialInterval
+	for i := 1; i < failures && interval < r.retryMaxInterval; i++ {
+		interval *= 2
+	}
+	interval = min(interval, r.retryMaxInterval)
+
+	half := interval / 2
+	if half <= 0 {
+		return interval
+	}
+	return half + rand.N(half)
+}
+
+func (r *RunningOutput) setCircuit(state int64) {
+	r.retry.circuit = state
+	r.CircuitState.Set(state)
+}
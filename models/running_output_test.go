@@ -797,16 +797,20 @@ func TestRunningOutputInternalMetrics(t *testing.T) {
 				"alias":  "test_alias",
 			},
 			map[string]interface{}{
-				"buffer_limit":     10,
-				"buffer_size":      0,
-				"errors":           0,
-				"metrics_added":    0,
-				"metrics_rejected": 0,
-				"metrics_dropped":  0,
-				"metrics_filtered": 0,
-				"metrics_written":  0,
-				"write_time_ns":    0,
-				"startup_errors":   0,
+				"buffer_limit":          10,
+				"buffer_size":           0,
+				"errors":                0,
+				"metrics_added":         0,
+				"metrics_rejected":      0,
+				"metrics_dropped":       0,
+				"metrics_dead_lettered": 0,
+				"metrics_filtered":      0,
+				"metrics_written":       0,
+				"write_time_ns":         0,
+				"startup_errors":        0,
+				"consecutive_failures":  0,
+				"circuit_state":         0,
+				"circuit_trips":         0,
 			},
 			time.Unix(0, 0),
 		),
@@ -1271,6 +1275,306 @@ func BenchmarkRunningOutputAddFailWrites(b *testing.B) {
 	}
 }
 
+func TestRunningOutputWritePartialSuccessDeadLetter(t *testing.T) {
+	rejected := 0
+	plugin := &mockOutput{
+		batchAcceptSize:  4,
+		metricFatalIndex: &rejected,
+	}
+	model := NewRunningOutput(plugin, &OutputConfig{DeadLetter: "sink"}, 5, 10)
+	require.NoError(t, model.Init())
+	require.NoError(t, model.Connect())
+	defer model.Close()
+
+	sinkPlugin := &mockOutput{}
+	sink := NewRunningOutput(sinkPlugin, &OutputConfig{Alias: "sink"}, 5, 10)
+	require.NoError(t, sink.Init())
+	require.NoError(t, sink.Connect())
+	defer sink.Close()
+
+	model.SetDeadLetter(sink)
+	require.True(t, sink.IsDeadLetterSink())
+	require.False(t, model.IsDeadLetterSink())
+
+	for _, metric := range first5 {
+		model.AddMetric(metric)
+	}
+
+	// The rejected metric should end up in the dead-letter output only while
+	// the non-accepted metric is kept for retrying
+	require.ErrorIs(t, model.Write(), internal.ErrSizeLimitReached)
+	testutil.RequireMetricsEqual(t, first5[1:4], plugin.Metrics())
+	require.Equal(t, 1, model.buffer.Len())
+	require.Equal(t, int64(1), model.MetricsDeadLettered.Get())
+
+	require.Equal(t, 1, sink.buffer.Len())
+	require.NoError(t, sink.Write())
+	testutil.RequireMetricsEqual(t, first5[:1], sinkPlugin.Metrics())
+}
+
+func TestRunningOutputConcurrentWrites(t *testing.T) {
+	plugin := &concurrentOutput{expected: 3}
+	model := NewRunningOutput(plugin, &OutputConfig{MaxInflightBatches: 3}, 2, 10)
+	require.NoError(t, model.Init())
+	require.NoError(t, model.Connect())
+	defer model.Close()
+
+	for _, metric := range first5 {
+		model.AddMetric(metric)
+	}
+	model.AddMetric(next5[0])
+
+	// All batches should be written concurrently
+	require.NoError(t, model.Write())
+	require.Equal(t, int32(3), plugin.maxInflight.Load())
+	testutil.RequireMetricsEqual(t, append(first5, next5[0]), plugin.Metrics(), testutil.SortMetrics())
+	require.Zero(t, model.buffer.Len())
+}
+
+func TestRunningOutputConcurrentWritesUnsupported(t *testing.T) {
+	model := NewRunningOutput(&mockOutput{}, &OutputConfig{MaxInflightBatches: 3}, 2, 10)
+	require.Equal(t, 1, model.maxInflightBatches)
+}
+
+func TestRunningOutputPermanentWriteError(t *testing.T) {
+	plugin := &mockOutput{
+		preWriteHook: func([]telegraf.Metric) error {
+			return &internal.WriteError{Err: errors.New("invalid metrics"), Permanent: true}
+		},
+	}
+	model := NewRunningOutput(plugin, &OutputConfig{Name: "permanent"}, 5, 10)
+	require.NoError(t, model.Init())
+	require.NoError(t, model.Connect())
+	defer model.Close()
+
+	sinkPlugin := &mockOutput{}
+	sink := NewRunningOutput(sinkPlugin, &OutputConfig{Name: "permanent", Alias: "sink"}, 5, 10)
+	model.SetDeadLetter(sink)
+
+	for _, metric := range first5 {
+		model.AddMetric(metric)
+	}
+
+	// All metrics should be rejected without being retried
+	require.ErrorContains(t, model.Write(), "invalid metrics")
+	require.Zero(t, model.buffer.Len())
+	require.Equal(t, int64(5), model.MetricsDeadLettered.Get())
+	require.Equal(t, int64(0), model.ConsecutiveFailures.Get())
+
+	require.NoError(t, sink.Write())
+	testutil.RequireMetricsEqual(t, first5, sinkPlugin.Metrics())
+}
+
+func TestRunningOutputRetryBackoff(t *testing.T) {
+	plugin := &mockOutput{batchAcceptSize: -1}
+	model := NewRunningOutput(plugin, &OutputConfig{
+		Name:                 "backoff",
+		RetryInitialInterval: time.Hour,
+		RetryMaxInterval:     2 * time.Hour,
+	}, 5, 10)
+	require.NoError(t, model.Init())
+	require.NoError(t, model.Connect())
+	defer model.Close()
+
+	for _, metric := range first5 {
+		model.AddMetric(metric)
+	}
+
+	// The failed write should schedule the retry with jitter applied
+	require.ErrorContains(t, model.Write(), "failed write")
+	require.Equal(t, int64(1), model.ConsecutiveFailures.Get())
+	wait := time.Until(model.retry.nextAttempt)
+	require.Greater(t, wait, 29*time.Minute)
+	require.LessOrEqual(t, wait, time.Hour)
+
+	// Writes should be skipped while backing off
+	require.NoError(t, model.Write())
+	require.Equal(t, uint32(1), plugin.writes.Load())
+	require.Equal(t, 5, model.buffer.Len())
+
+	// After the backoff elapsed, the write should be retried
+	plugin.batchAcceptSize = 0
+	model.retry.nextAttempt = time.Now()
+	require.NoError(t, model.Write())
+	require.Equal(t, uint32(2), plugin.writes.Load())
+	require.Equal(t, int64(0), model.ConsecutiveFailures.Get())
+	testutil.RequireMetricsEqual(t, first5, plugin.Metrics())
+}
+
+func TestRunningOutputRetryBackoffLimit(t *testing.T) {
+	model := NewRunningOutput(&mockOutput{}, &OutputConfig{
+		RetryInitialInterval: time.Second,
+		RetryMaxInterval:     10 * time.Second,
+	}, 5, 10)
+
+	for failures, expected := range []time.Duration{time.Second, time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second} {
+		wait := model.backoff(failures)
+		require.GreaterOrEqual(t, wait, expected/2)
+		require.Less(t, wait, expected)
+	}
+	for _, failures := range []int{5, 10, 100} {
+		wait := model.backoff(failures)
+		require.GreaterOrEqual(t, wait, 5*time.Second)
+		require.Less(t, wait, 10*time.Second)
+	}
+}
+
+func TestRunningOutputCircuitBreaker(t *testing.T) {
+	plugin := &mockOutput{batchAcceptSize: -1}
+	model := NewRunningOutput(plugin, &OutputConfig{
+		Name:                        "circuit",
+		CircuitBreakerThreshold:     2,
+		CircuitBreakerProbeInterval: time.Hour,
+	}, 5, 10)
+	require.NoError(t, model.Init())
+	require.NoError(t, model.Connect())
+	defer model.Close()
+
+	for _, metric := range first5 {
+		model.AddMetric(metric)
+	}
+
+	// The circuit should open after the configured number of failures
+	require.Error(t, model.Write())
+	require.Equal(t, circuitClosed, model.CircuitState.Get())
+	require.Error(t, model.Write())
+	require.Equal(t, circuitOpen, model.CircuitState.Get())
+	require.Equal(t, int64(1), model.CircuitTrips.Get())
+
+	// No writes should happen with the circuit being open
+	require.NoError(t, model.Write())
+	require.Equal(t, uint32(2), plugin.writes.Load())
+
+	// A failing probe should open the circuit again
+	model.retry.nextAttempt = time.Now()
+	require.Error(t, model.Write())
+	require.Equal(t, uint32(3), plugin.writes.Load())
+	require.Equal(t, circuitOpen, model.CircuitState.Get())
+	require.Equal(t, int64(1), model.CircuitTrips.Get())
+	require.Greater(t, time.Until(model.retry.nextAttempt), 59*time.Minute)
+
+	// A successful probe should close the circuit
+	plugin.batchAcceptSize = 0
+	model.retry.nextAttempt = time.Now()
+	require.NoError(t, model.Write())
+	require.Equal(t, circuitClosed, model.CircuitState.Get())
+	require.Equal(t, int64(0), model.ConsecutiveFailures.Get())
+	testutil.RequireMetricsEqual(t, first5, plugin.Metrics())
+}
+
+func TestRunningOutputRetryMaxAttempts(t *testing.T) {
+	plugin := &mockOutput{batchAcceptSize: -1}
+	model := NewRunningOutput(plugin, &OutputConfig{Name: "attempts", RetryMaxAttempts: 2}, 5, 10)
+	require.NoError(t, model.Init())
+	require.NoError(t, model.Connect())
+	defer model.Close()
+
+	sinkPlugin := &mockOutput{}
+	sink := NewRunningOutput(sinkPlugin, &OutputConfig{Name: "attempts", Alias: "sink"}, 5, 10)
+	model.SetDeadLetter(sink)
+
+	for _, metric := range first5 {
+		model.AddMetric(metric)
+	}
+
+	// The batch should be kept after the first attempt...
+	require.Error(t, model.Write())
+	require.Equal(t, 5, model.buffer.Len())
+
+	// ... and be passed to the dead-letter output after the last attempt
+	require.Error(t, model.Write())
+	require.Zero(t, model.buffer.Len())
+	require.Equal(t, int64(5), model.MetricsDeadLettered.Get())
+	require.Equal(t, int64(2), model.ConsecutiveFailures.Get())
+
+	require.NoError(t, sink.Write())
+	testutil.RequireMetricsEqual(t, first5, sinkPlugin.Metrics())
+}
+
+func TestRunningOutputRetryMaxAttemptsConcurrent(t *testing.T) {
+	plugin := &concurrentOutput{mockOutput: mockOutput{batchAcceptSize: -1}, expected: 3}
+	model := NewRunningOutput(plugin, &OutputConfig{
+		Name:                    "attempts_concurrent",
+		MaxInflightBatches:      3,
+		RetryMaxAttempts:        2,
+		CircuitBreakerThreshold: 2,
+	}, 2, 10)
+	require.NoError(t, model.Init())
+	require.NoError(t, model.Connect())
+	defer model.Close()
+
+	sink := NewRunningOutput(&mockOutput{}, &OutputConfig{Name: "attempts_concurrent", Alias: "sink"}, 10, 10)
+	model.SetDeadLetter(sink)
+
+	for _, metric := range append(first5, next5[0]) {
+		model.AddMetric(metric)
+	}
+
+	// Concurrently failing batches should count as a single attempt for each
+	// batch and as a single failure of the output
+	require.Error(t, model.Write())
+	require.Equal(t, int32(3), plugin.maxInflight.Load())
+	require.Equal(t, 6, model.buffer.Len())
+	require.Zero(t, model.MetricsDeadLettered.Get())
+	require.Equal(t, int64(1), model.ConsecutiveFailures.Get())
+	require.Equal(t, circuitClosed, model.CircuitState.Get())
+
+	// All batches should be rejected after their last attempt
+	plugin.maxInflight.Store(0)
+	require.Error(t, model.Write())
+	require.Zero(t, model.buffer.Len())
+	require.Equal(t, int64(6), model.MetricsDeadLettered.Get())
+	require.Equal(t, int64(2), model.ConsecutiveFailures.Get())
+	require.Equal(t, circuitOpen, model.CircuitState.Get())
+}
+
+func TestRunningOutputPermanentWriteErrorKeepsCircuit(t *testing.T) {
+	plugin := &mockOutput{batchAcceptSize: -1}
+	model := NewRunningOutput(plugin, &OutputConfig{
+		Name:                        "permanent_circuit",
+		CircuitBreakerThreshold:     2,
+		CircuitBreakerProbeInterval: time.Hour,
+	}, 5, 10)
+	require.NoError(t, model.Init())
+	require.NoError(t, model.Connect())
+	defer model.Close()
+
+	for _, metric := range first5 {
+		model.AddMetric(metric)
+	}
+	require.Error(t, model.Write())
+	require.Error(t, model.Write())
+	require.Equal(t, circuitOpen, model.CircuitState.Get())
+
+	// A permanently rejected probe is no success so the circuit must not close
+	plugin.preWriteHook = func([]telegraf.Metric) error {
+		return &internal.WriteError{Err: errors.New("invalid metrics"), Permanent: true}
+	}
+	model.retry.nextAttempt = time.Now()
+	require.Error(t, model.Write())
+	require.Zero(t, model.buffer.Len())
+	require.NotEqual(t, circuitClosed, model.CircuitState.Get())
+	require.Equal(t, int64(2), model.ConsecutiveFailures.Get())
+}
+
+func TestRunningOutputWriteFinalIgnoresRetryPolicy(t *testing.T) {
+	plugin := &mockOutput{batchAcceptSize: -1}
+	model := NewRunningOutput(plugin, &OutputConfig{RetryInitialInterval: time.Hour}, 5, 10)
+	require.NoError(t, model.Init())
+	require.NoError(t, model.Connect())
+	defer model.Close()
+
+	for _, metric := range first5 {
+		model.AddMetric(metric)
+	}
+	require.Error(t, model.Write())
+
+	// The final write should be attempted despite backing off
+	plugin.batchAcceptSize = 0
+	require.NoError(t, model.WriteFinal())
+	testutil.RequireMetricsEqual(t, first5, plugin.Metrics())
+}
+
 type mockOutput struct {
 	sync.Mutex
 
@@ -1361,6 +1665,35 @@ func (m *mockOutput) Metrics() []telegraf.Metric {
 	return m.metrics
 }
 
+type concurrentOutput struct {
+	mockOutput
+
+	expected    int32
+	inflight    atomic.Int32
+	maxInflight atomic.Int32
+}
+
+func (*concurrentOutput) SupportsConcurrentWrites() {}
+
+func (o *concurrentOutput) Write(metrics []telegraf.Metric) error {
+	n := o.inflight.Add(1)
+	defer o.inflight.Add(-1)
+	for {
+		current := o.maxInflight.Load()
+		if n <= current || o.maxInflight.CompareAndSwap(current, n) {
+			break
+		}
+	}
+
+	// Wait for the expected number of concurrent writes to simulate latency
+	start := time.Now()
+	for o.maxInflight.Load() < o.expected && time.Since(start) < time.Second {
+		time.Sleep(time.Millisecond)
+	}
+
+	return o.mockOutput.Write(metrics)
+}
+
 type perfOutput struct {
 	// if true, mock write failure
 	failWrite bool
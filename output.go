@@ -27,3 +27,12 @@ type AggregatingOutput interface {
 	// Reset signals that the aggregator period is completed.
 	Reset()
 }
+
+// ConcurrentOutput is an Output supporting concurrent calls to Write. Only
+// such outputs are written with multiple in-flight batches.
+type ConcurrentOutput interface {
+	Output
+
+	// SupportsConcurrentWrites marks the output as safe for concurrent writes
+	SupportsConcurrentWrites()
+}
@@ -0,0 +1,5 @@
+//go:build !custom || aggregators || aggregators.sketch
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/aggregators/sketch" // register plugin
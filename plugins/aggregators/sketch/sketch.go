@@ -0,0 +1,176 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package sketch
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+
+	"github.com/DataDog/sketches-go/ddsketch"
+	"github.com/axiomhq/hyperloglog"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/filter"
+	"github.com/influxdata/telegraf/plugins/aggregators"
+	common "github.com/influxdata/telegraf/plugins/common/sketch"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+type Sketch struct {
+	Fields           []string        `toml:"fields"`
+	Quantiles        []float64       `toml:"quantiles"`
+	RelativeAccuracy float64         `toml:"relative_accuracy"`
+	MaxBins          int             `toml:"max_bins"`
+	DistinctTags     []string        `toml:"distinct_tags"`
+	DistinctFields   []string        `toml:"distinct_fields"`
+	Precision        int             `toml:"precision"`
+	Log              telegraf.Logger `toml:"-"`
+
+	accept   filter.Filter
+	suffixes []string
+	cache    map[uint64]*aggregate
+}
+
+type aggregate struct {
+	name      string
+	tags      map[string]string
+	quantiles map[string]*ddsketch.DDSketch
+	distinct  map[string]*hyperloglog.Sketch
+}
+
+func (*Sketch) SampleConfig() string {
+	return sampleConfig
+}
+
+func (s *Sketch) Init() error {
+	if len(s.Fields) == 0 && len(s.DistinctTags) == 0 && len(s.DistinctFields) == 0 {
+		return errors.New("no fields, distinct_tags or distinct_fields specified")
+	}
+
+	f, err := filter.Compile(s.Fields)
+	if err != nil {
+		return fmt.Errorf("failed to create new field filter: %w", err)
+	}
+	s.accept = f
+
+	suffixes, err := common.QuantileSuffixes(s.Quantiles)
+	if err != nil {
+		return err
+	}
+	s.suffixes = suffixes
+
+	if s.MaxBins < 1 {
+		return errors.New("max_bins must be positive")
+	}
+	// Check the accuracy by creating a sketch
+	if _, err := ddsketch.LogCollapsingLowestDenseDDSketch(s.RelativeAccuracy, s.MaxBins); err != nil {
+		return fmt.Errorf("invalid relative accuracy: %w", err)
+	}
+	if s.Precision < 4 || s.Precision > 18 {
+		return errors.New("precision must be between 4 and 18")
+	}
+
+	s.Reset()
+
+	return nil
+}
+
+func (s *Sketch) Add(in telegraf.Metric) {
+	// The tags counted are not part of the series
+	id := common.SeriesID(in, s.DistinctTags)
+	a, found := s.cache[id]
+	if !found {
+		a = &aggregate{
+			name:      in.Name(),
+			tags:      common.SeriesTags(in, s.DistinctTags),
+			quantiles: make(map[string]*ddsketch.DDSketch),
+			distinct:  make(map[string]*hyperloglog.Sketch),
+		}
+		s.cache[id] = a
+	}
+
+	if s.accept != nil {
+		for _, field := range in.FieldList() {
+			if !s.accept.Match(field.Key) {
+				continue
+			}
+			v, ok := convert(field.Value)
+			if !ok {
+				continue
+			}
+			sketch, found := a.quantiles[field.Key]
+			if !found {
+				// The settings are checked on Init so this cannot fail
+				sketch, _ = ddsketch.LogCollapsingLowestDenseDDSketch(s.RelativeAccuracy, s.MaxBins)
+				a.quantiles[field.Key] = sketch
+			}
+			if err := sketch.Add(v); err != nil {
+				s.Log.Debugf("Adding value %v of field %q failed: %v", v, field.Key, err)
+			}
+		}
+	}
+
+	for _, key := range s.DistinctTags {
+		if v, found := in.GetTag(key); found {
+			s.distinct(a, key).Insert([]byte(v))
+		}
+	}
+	for _, key := range s.DistinctFields {
+		if v, found := in.GetField(key); found {
+			s.distinct(a, key).Insert(fmt.Appendf(nil, "%v", v))
+		}
+	}
+}
+
+func (s *Sketch) Push(acc telegraf.Accumulator) {
+	for _, a := range s.cache {
+		fields, err := common.Fields(a.quantiles, a.distinct, s.Quantiles, s.suffixes)
+		if err != nil {
+			acc.AddError(err)
+			continue
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		acc.AddFields(a.name, fields, a.tags)
+	}
+}
+
+func (s *Sketch) Reset() {
+	s.cache = make(map[uint64]*aggregate)
+}
+
+func (s *Sketch) distinct(a *aggregate, key string) *hyperloglog.Sketch {
+	sketch, found := a.distinct[key]
+	if !found {
+		// The precision is checked on Init so this cannot fail
+		sketch, _ = hyperloglog.NewSketch(uint8(s.Precision), true)
+		a.distinct[key] = sketch
+	}
+	return sketch
+}
+
+func convert(in interface{}) (float64, bool) {
+	switch v := in.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
+
+func init() {
+	aggregators.Add("sketch", func() telegraf.Aggregator {
+		return &Sketch{
+			RelativeAccuracy: 0.01,
+			MaxBins:          2048,
+			Precision:        14,
+		}
+	})
+}
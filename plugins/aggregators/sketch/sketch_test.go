@@ -0,0 +1,132 @@
+package sketch
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf/metric"
+	common "github.com/influxdata/telegraf/plugins/common/sketch"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInit(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *Sketch
+		expected string
+	}{
+		{
+			name:     "nothing to do",
+			plugin:   &Sketch{RelativeAccuracy: 0.01, MaxBins: 2048, Precision: 14},
+			expected: "no fields, distinct_tags or distinct_fields specified",
+		},
+		{
+			name:     "invalid quantile",
+			plugin:   &Sketch{Fields: []string{"*"}, Quantiles: []float64{2}, RelativeAccuracy: 0.01, MaxBins: 2048, Precision: 14},
+			expected: "quantile 2 out of range",
+		},
+		{
+			name:     "invalid accuracy",
+			plugin:   &Sketch{Fields: []string{"*"}, RelativeAccuracy: 1.5, MaxBins: 2048, Precision: 14},
+			expected: "invalid relative accuracy",
+		},
+		{
+			name:     "invalid bins",
+			plugin:   &Sketch{Fields: []string{"*"}, RelativeAccuracy: 0.01, Precision: 14},
+			expected: "max_bins must be positive",
+		},
+		{
+			name:     "invalid precision",
+			plugin:   &Sketch{DistinctTags: []string{"user"}, RelativeAccuracy: 0.01, MaxBins: 2048, Precision: 20},
+			expected: "precision must be between 4 and 18",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestQuantiles(t *testing.T) {
+	plugin := &Sketch{
+		Fields:           []string{"latency"},
+		Quantiles:        []float64{0.5, 0.99},
+		RelativeAccuracy: 0.01,
+		MaxBins:          2048,
+		Precision:        14,
+		Log:              testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	for i := 1; i <= 100; i++ {
+		plugin.Add(metric.New(
+			"http",
+			map[string]string{"host": "a"},
+			map[string]interface{}{"latency": i, "status": "ok", "size": 10},
+			time.Unix(int64(i), 0),
+		))
+	}
+
+	var acc testutil.Accumulator
+	plugin.Push(&acc)
+	require.Len(t, acc.Metrics, 1)
+
+	m := acc.Metrics[0]
+	require.Equal(t, "http", m.Measurement)
+	require.Equal(t, map[string]string{"host": "a"}, m.Tags)
+	require.Len(t, m.Fields, 3)
+	require.InEpsilon(t, 50.0, m.Fields["latency_050"], 0.01)
+	require.InEpsilon(t, 99.0, m.Fields["latency_099"], 0.01)
+
+	sketch, err := common.DecodeDDSketch(m.Fields["latency_ddsketch"].(string))
+	require.NoError(t, err)
+	require.InDelta(t, 100.0, sketch.GetCount(), 1e-9)
+
+	// The next period starts empty
+	plugin.Reset()
+	acc.ClearMetrics()
+	plugin.Push(&acc)
+	require.Empty(t, acc.Metrics)
+}
+
+func TestDistinct(t *testing.T) {
+	plugin := &Sketch{
+		DistinctTags:     []string{"user"},
+		DistinctFields:   []string{"session"},
+		RelativeAccuracy: 0.01,
+		MaxBins:          2048,
+		Precision:        14,
+		Log:              testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	// Each user has two sessions
+	for i := range 2000 {
+		plugin.Add(metric.New(
+			"login",
+			map[string]string{"host": "a", "user": "user" + strconv.Itoa(i/2)},
+			map[string]interface{}{"session": int64(i)},
+			time.Unix(0, 0),
+		))
+	}
+
+	var acc testutil.Accumulator
+	plugin.Push(&acc)
+	require.Len(t, acc.Metrics, 1)
+
+	// The counted tag is not part of the series
+	m := acc.Metrics[0]
+	require.Equal(t, map[string]string{"host": "a"}, m.Tags)
+	require.Len(t, m.Fields, 4)
+	require.InEpsilon(t, 1000, m.Fields["user_distinct"], 0.02)
+	require.InEpsilon(t, 2000, m.Fields["session_distinct"], 0.02)
+
+	sketch, err := common.DecodeHLL(m.Fields["user_hll"].(string))
+	require.NoError(t, err)
+	require.Equal(t, m.Fields["user_distinct"], sketch.Estimate())
+}
@@ -0,0 +1,42 @@
+package kube
+
+import (
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/influxdata/telegraf/plugins/common/tls"
+)
+
+// DefaultServiceAccountPath is the location of the service-account token
+// mounted into pods
+const DefaultServiceAccountPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// NewConfig creates the configuration for accessing the Kubernetes API at the
+// given URL. If the URL is empty, the in-cluster configuration is used.
+func NewConfig(baseURL, bearerTokenFile string, tlsConfig tls.ClientConfig) (*rest.Config, error) {
+	if baseURL == "" {
+		return rest.InClusterConfig()
+	}
+
+	return &rest.Config{
+		TLSClientConfig: rest.TLSClientConfig{
+			ServerName: tlsConfig.ServerName,
+			Insecure:   tlsConfig.InsecureSkipVerify,
+			CAFile:     tlsConfig.TLSCA,
+			CertFile:   tlsConfig.TLSCert,
+			KeyFile:    tlsConfig.TLSKey,
+		},
+		Host:            baseURL,
+		ContentConfig:   rest.ContentConfig{},
+		BearerTokenFile: bearerTokenFile,
+	}, nil
+}
+
+// NewClientset creates a Kubernetes client for the API at the given URL
+func NewClientset(baseURL, bearerTokenFile string, tlsConfig tls.ClientConfig) (*kubernetes.Clientset, error) {
+	clientConfig, err := NewConfig(baseURL, bearerTokenFile, tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(clientConfig)
+}
@@ -0,0 +1,62 @@
+package pulsar
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/plugins/common/tls"
+)
+
+// ClientConfig contains the settings common to all Pulsar clients
+type ClientConfig struct {
+	URL               string          `toml:"url"`
+	Token             config.Secret   `toml:"token"`
+	ConnectionTimeout config.Duration `toml:"connection_timeout"`
+	OperationTimeout  config.Duration `toml:"operation_timeout"`
+	tls.ClientConfig
+}
+
+// NewClient creates a Pulsar client using the configured settings. Token
+// authentication takes precedence over TLS authentication which is used if a
+// client certificate is configured.
+func (c *ClientConfig) NewClient(log telegraf.Logger) (pulsar.Client, error) {
+	if c.URL == "" {
+		return nil, errors.New("url required")
+	}
+
+	tlsCfg, err := c.ClientConfig.TLSConfig()
+	if err != nil {
+		return nil, fmt.Errorf("creating TLS config failed: %w", err)
+	}
+
+	options := pulsar.ClientOptions{
+		URL:               c.URL,
+		ConnectionTimeout: time.Duration(c.ConnectionTimeout),
+		OperationTimeout:  time.Duration(c.OperationTimeout),
+		TLSConfig:         tlsCfg,
+		Logger:            &logger{log},
+	}
+
+	switch {
+	case !c.Token.Empty():
+		// Query the secret for each (re-)authentication to avoid keeping the
+		// token in memory
+		options.Authentication = pulsar.NewAuthenticationTokenFromSupplier(func() (string, error) {
+			token, err := c.Token.Get()
+			if err != nil {
+				return "", fmt.Errorf("getting token failed: %w", err)
+			}
+			defer token.Destroy()
+			return token.String(), nil
+		})
+	case c.TLSCert != "" && c.TLSKey != "":
+		options.Authentication = pulsar.NewAuthenticationTLS(c.TLSCert, c.TLSKey)
+	}
+
+	return pulsar.NewClient(options)
+}
@@ -0,0 +1,127 @@
+package pulsar
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/apache/pulsar-client-go/pulsar/log"
+
+	"github.com/influxdata/telegraf"
+)
+
+var (
+	_ log.Logger = (*logger)(nil)
+	_ log.Logger = (*subLogger)(nil)
+	_ log.Entry  = (*entry)(nil)
+)
+
+// logger forwards the messages of the Pulsar client to the Telegraf logger
+// with the client's fields appended to the message
+type logger struct {
+	telegraf.Logger
+}
+
+// entry is a logger with additional fields
+type entry struct {
+	log    telegraf.Logger
+	fields log.Fields
+}
+
+func (l *logger) SubLogger(fields log.Fields) log.Logger {
+	return &subLogger{entry{log: l.Logger, fields: fields}}
+}
+
+func (l *logger) WithFields(fields log.Fields) log.Entry {
+	return &entry{log: l.Logger, fields: fields}
+}
+
+func (l *logger) WithField(name string, value interface{}) log.Entry {
+	return &entry{log: l.Logger, fields: log.Fields{name: value}}
+}
+
+func (l *logger) WithError(err error) log.Entry {
+	return &entry{log: l.Logger, fields: log.Fields{"error": err}}
+}
+
+// The Pulsar client is very chatty on info level, so report those messages
+// as debug messages only
+func (l *logger) Info(args ...interface{}) {
+	l.Logger.Debug(args...)
+}
+
+func (l *logger) Infof(format string, args ...interface{}) {
+	l.Logger.Debugf(format, args...)
+}
+
+// subLogger is a logger with additional fields
+type subLogger struct {
+	entry
+}
+
+func (l *subLogger) SubLogger(fields log.Fields) log.Logger {
+	return &subLogger{entry{log: l.log, fields: l.merge(fields)}}
+}
+
+func (l *subLogger) WithError(err error) log.Entry {
+	return l.WithField("error", err)
+}
+
+func (e *entry) WithFields(fields log.Fields) log.Entry {
+	return &entry{log: e.log, fields: e.merge(fields)}
+}
+
+func (e *entry) WithField(name string, value interface{}) log.Entry {
+	return &entry{log: e.log, fields: e.merge(log.Fields{name: value})}
+}
+
+func (e *entry) Debug(args ...interface{}) {
+	e.log.Debug(e.format(fmt.Sprint(args...)))
+}
+
+func (e *entry) Info(args ...interface{}) {
+	e.log.Debug(e.format(fmt.Sprint(args...)))
+}
+
+func (e *entry) Warn(args ...interface{}) {
+	e.log.Warn(e.format(fmt.Sprint(args...)))
+}
+
+func (e *entry) Error(args ...interface{}) {
+	e.log.Error(e.format(fmt.Sprint(args...)))
+}
+
+func (e *entry) Debugf(format string, args ...interface{}) {
+	e.log.Debug(e.format(fmt.Sprintf(format, args...)))
+}
+
+func (e *entry) Infof(format string, args ...interface{}) {
+	e.log.Debug(e.format(fmt.Sprintf(format, args...)))
+}
+
+func (e *entry) Warnf(format string, args ...interface{}) {
+	e.log.Warn(e.format(fmt.Sprintf(format, args...)))
+}
+
+func (e *entry) Errorf(format string, args ...interface{}) {
+	e.log.Error(e.format(fmt.Sprintf(format, args...)))
+}
+
+func (e *entry) merge(fields log.Fields) log.Fields {
+	merged := make(log.Fields, len(e.fields)+len(fields))
+	maps.Copy(merged, e.fields)
+	maps.Copy(merged, fields)
+	return merged
+}
+
+func (e *entry) format(msg string) string {
+	if len(e.fields) == 0 {
+		return msg
+	}
+	parts := make([]string, 0, len(e.fields))
+	for _, k := range slices.Sorted(maps.Keys(e.fields)) {
+		parts = append(parts, fmt.Sprintf("%s=%v", k, e.fields[k]))
+	}
+	return msg + " [" + strings.Join(parts, " ") + "]"
+}
@@ -0,0 +1,157 @@
+package sketch
+
+import (
+	"encoding/base64"
+	"fmt"
+	"hash/fnv"
+	"slices"
+
+	"github.com/DataDog/sketches-go/ddsketch"
+	"github.com/DataDog/sketches-go/ddsketch/pb/sketchpb"
+	"github.com/axiomhq/hyperloglog"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/influxdata/telegraf"
+)
+
+// Suffixes of the fields containing the serialized sketches
+const (
+	QuantileSuffix = "_ddsketch"
+	DistinctSuffix = "_hll"
+)
+
+// Suffix of the fields containing the distinct count estimate
+const CountSuffix = "_distinct"
+
+// SeriesID returns the hash of the metric name and the tags not contained in
+// the exclude list
+func SeriesID(m telegraf.Metric, exclude []string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(m.Name()))
+	h.Write([]byte("\n"))
+	for _, tag := range m.TagList() {
+		if slices.Contains(exclude, tag.Key) {
+			continue
+		}
+		h.Write([]byte(tag.Key))
+		h.Write([]byte("\n"))
+		h.Write([]byte(tag.Value))
+		h.Write([]byte("\n"))
+	}
+	return h.Sum64()
+}
+
+// SeriesTags returns the tags of the metric not contained in the exclude list
+func SeriesTags(m telegraf.Metric, exclude []string) map[string]string {
+	tags := make(map[string]string, len(m.TagList()))
+	for _, tag := range m.TagList() {
+		if !slices.Contains(exclude, tag.Key) {
+			tags[tag.Key] = tag.Value
+		}
+	}
+	return tags
+}
+
+// QuantileSuffixes checks the given quantiles and returns the suffixes of the
+// fields containing the quantile values
+func QuantileSuffixes(quantiles []float64) ([]string, error) {
+	seen := make(map[string]bool, len(quantiles))
+	suffixes := make([]string, 0, len(quantiles))
+	for _, q := range quantiles {
+		if q < 0.0 || q > 1.0 {
+			return nil, fmt.Errorf("quantile %v out of range", q)
+		}
+		suffix := fmt.Sprintf("_%03d", int(q*100.0))
+		if seen[suffix] {
+			return nil, fmt.Errorf("duplicate quantile %v", q)
+		}
+		seen[suffix] = true
+		suffixes = append(suffixes, suffix)
+	}
+	return suffixes, nil
+}
+
+// EncodeDDSketch serializes the sketch in its protocol-buffer representation
+// encoded as base64 string
+func EncodeDDSketch(s *ddsketch.DDSketch) (string, error) {
+	buf, err := proto.Marshal(s.ToProto())
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf), nil
+}
+
+// DecodeDDSketch deserializes a sketch encoded by EncodeDDSketch
+func DecodeDDSketch(s string) (*ddsketch.DDSketch, error) {
+	buf, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("decoding base64 failed: %w", err)
+	}
+	var pb sketchpb.DDSketch
+	if err := proto.Unmarshal(buf, &pb); err != nil {
+		return nil, fmt.Errorf("unmarshalling sketch failed: %w", err)
+	}
+	return ddsketch.FromProto(&pb)
+}
+
+// EncodeHLL serializes the HyperLogLog sketch encoded as base64 string
+func EncodeHLL(s *hyperloglog.Sketch) (string, error) {
+	buf, err := s.MarshalBinary()
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf), nil
+}
+
+// DecodeHLL deserializes a HyperLogLog sketch encoded by EncodeHLL
+func DecodeHLL(s string) (*hyperloglog.Sketch, error) {
+	buf, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("decoding base64 failed: %w", err)
+	}
+	var sketch hyperloglog.Sketch
+	if err := sketch.UnmarshalBinary(buf); err != nil {
+		return nil, fmt.Errorf("unmarshalling sketch failed: %w", err)
+	}
+	return &sketch, nil
+}
+
+// Fields returns the fields for the given DDSketches and HyperLogLog sketches
+// containing the serialized sketches, the quantiles and distinct counts. The
+// sketches are named by the original field or tag and the quantile suffixes
+// must match the given quantiles.
+func Fields(
+	quantiles map[string]*ddsketch.DDSketch,
+	distinct map[string]*hyperloglog.Sketch,
+	qs []float64,
+	suffixes []string,
+) (map[string]interface{}, error) {
+	fields := make(map[string]interface{}, len(quantiles)*(len(qs)+1)+2*len(distinct))
+	for key, s := range quantiles {
+		if s.IsEmpty() {
+			continue
+		}
+		encoded, err := EncodeDDSketch(s)
+		if err != nil {
+			return nil, fmt.Errorf("encoding sketch of %q failed: %w", key, err)
+		}
+		fields[key+QuantileSuffix] = encoded
+
+		values, err := s.GetValuesAtQuantiles(qs)
+		if err != nil {
+			return nil, fmt.Errorf("computing quantiles of %q failed: %w", key, err)
+		}
+		for i, v := range values {
+			fields[key+suffixes[i]] = v
+		}
+	}
+	for key, s := range distinct {
+		encoded, err := EncodeHLL(s)
+		if err != nil {
+			return nil, fmt.Errorf("encoding sketch of %q failed: %w", key, err)
+		}
+		fields[key+DistinctSuffix] = encoded
+		fields[key+CountSuffix] = s.Estimate()
+	}
+	return fields, nil
+}
@@ -0,0 +1,71 @@
+package sketch
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/DataDog/sketches-go/ddsketch"
+	"github.com/axiomhq/hyperloglog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf/metric"
+)
+
+func TestQuantileSuffixes(t *testing.T) {
+	suffixes, err := QuantileSuffixes([]float64{0.5, 0.9, 0.99})
+	require.NoError(t, err)
+	require.Equal(t, []string{"_050", "_090", "_099"}, suffixes)
+
+	_, err = QuantileSuffixes([]float64{1.5})
+	require.ErrorContains(t, err, "quantile 1.5 out of range")
+
+	_, err = QuantileSuffixes([]float64{0.99, 0.995})
+	require.ErrorContains(t, err, "duplicate quantile 0.995")
+}
+
+func TestSeries(t *testing.T) {
+	a := metric.New("http", map[string]string{"host": "a", "path": "/"}, map[string]interface{}{"value": 1}, time.Unix(0, 0))
+	b := metric.New("http", map[string]string{"host": "b", "path": "/"}, map[string]interface{}{"value": 1}, time.Unix(0, 0))
+
+	require.NotEqual(t, SeriesID(a, nil), SeriesID(b, nil))
+	require.Equal(t, SeriesID(a, []string{"host"}), SeriesID(b, []string{"host"}))
+	require.Equal(t, map[string]string{"path": "/"}, SeriesTags(a, []string{"host"}))
+}
+
+func TestDDSketchRoundtrip(t *testing.T) {
+	sketch, err := ddsketch.LogCollapsingLowestDenseDDSketch(0.01, 2048)
+	require.NoError(t, err)
+	for i := 1; i <= 100; i++ {
+		require.NoError(t, sketch.Add(float64(i)))
+	}
+
+	encoded, err := EncodeDDSketch(sketch)
+	require.NoError(t, err)
+	decoded, err := DecodeDDSketch(encoded)
+	require.NoError(t, err)
+
+	require.InDelta(t, 100.0, decoded.GetCount(), 1e-9)
+	median, err := decoded.GetValueAtQuantile(0.5)
+	require.NoError(t, err)
+	require.InEpsilon(t, 50.0, median, 0.01)
+
+	_, err = DecodeDDSketch("not base64!")
+	require.ErrorContains(t, err, "decoding base64 failed")
+}
+
+func TestHLLRoundtrip(t *testing.T) {
+	sketch := hyperloglog.New14()
+	for i := range 1000 {
+		sketch.Insert([]byte(strconv.Itoa(i)))
+	}
+
+	encoded, err := EncodeHLL(sketch)
+	require.NoError(t, err)
+	decoded, err := DecodeHLL(encoded)
+	require.NoError(t, err)
+	require.Equal(t, sketch.Estimate(), decoded.Estimate())
+
+	_, err = DecodeHLL("AAAA")
+	require.ErrorContains(t, err, "unmarshalling sketch failed")
+}
@@ -19,8 +19,9 @@ var (
 )
 
 type Decoder struct {
-	modules   map[string]*yang.Module
-	rootNodes map[string][]yang.Node
+	modules    map[string]*yang.Module
+	namespaces map[string]string
+	rootNodes  map[string][]yang.Node
 }
 
 func NewDecoder(paths ...string) (*Decoder, error) {
@@ -89,6 +90,7 @@ func NewDecoder(paths ...string) (*Decoder, error) {
 	// Get all root nodes defined in models with their origin. We require
 	// those nodes to later resolve paths to YANG model leaf nodes...
 	moduleLUT := make(map[string]*yang.Module)
+	namespaceLUT := make(map[string]string)
 	moduleRootNodes := make(map[string][]yang.Node)
 	for _, m := range modules.Modules {
 		// Check if we processed the module already
@@ -98,6 +100,11 @@ func NewDecoder(paths ...string) (*Decoder, error) {
 		// Create a module mapping for easily finding modules by name
 		moduleLUT[m.Name] = m
 
+		// Create a namespace mapping for finding modules by their XML namespace
+		if m.Namespace != nil {
+			namespaceLUT[m.Namespace.Name] = m.Name
+		}
+
 		// Determine the origin defined in the module
 		var prefix string
 		for _, imp := range m.Import {
@@ -128,7 +135,13 @@ func NewDecoder(paths ...string) (*Decoder, error) {
 		}
 	}
 
-	return &Decoder{modules: moduleLUT, rootNodes: moduleRootNodes}, nil
+	return &Decoder{modules: moduleLUT, namespaces: namespaceLUT, rootNodes: moduleRootNodes}, nil
+}
+
+// ModuleName returns the name of the module defining the given XML namespace
+func (d *Decoder) ModuleName(namespace string) (string, bool) {
+	name, found := d.namespaces[namespace]
+	return name, found
 }
 
 func (d *Decoder) FindLeaf(name, identifier string) (*yang.Leaf, error) {
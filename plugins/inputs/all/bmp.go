@@ -0,0 +1,5 @@
+//go:build !custom || inputs || inputs.bmp
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/inputs/bmp" // register plugin
@@ -0,0 +1,5 @@
+//go:build !custom || inputs || inputs.kube_events
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/inputs/kube_events" // register plugin
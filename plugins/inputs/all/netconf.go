@@ -0,0 +1,5 @@
+//go:build !custom || inputs || inputs.netconf
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/inputs/netconf" // register plugin
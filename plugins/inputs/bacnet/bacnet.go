@@ -0,0 +1,649 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package bacnet
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/inputs"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+// Identifier used for all COV subscriptions of this plugin
+const subscriberProcessID = 1
+
+type BACnet struct {
+	LocalAddress            string             `toml:"local_address"`
+	BroadcastAddress        string             `toml:"broadcast_address"`
+	Timeout                 config.Duration    `toml:"timeout"`
+	Retries                 int                `toml:"retries"`
+	ReadMethod              string             `toml:"read_method"`
+	MaxPropertiesPerRequest int                `toml:"max_properties_per_request"`
+	ObjectTags              []string           `toml:"object_tags"`
+	COVLifetime             config.Duration    `toml:"cov_lifetime"`
+	Devices                 []deviceDefinition `toml:"device"`
+	Log                     telegraf.Logger    `toml:"-"`
+
+	devices   []*device
+	objTags   []uint32
+	client    *client
+	acc       telegraf.Accumulator
+	cancel    chan struct{}
+	wg        sync.WaitGroup
+	lifetime  uint32
+	hasCOV    bool
+	broadcast *net.UDPAddr
+}
+
+type fieldDefinition struct {
+	Name        string  `toml:"name"`
+	ObjectType  string  `toml:"object_type"`
+	Instance    uint32  `toml:"instance"`
+	Property    string  `toml:"property"`
+	Scale       float64 `toml:"scale"`
+	Measurement string  `toml:"measurement"`
+	COV         bool    `toml:"cov"`
+}
+
+type deviceDefinition struct {
+	DeviceID    uint32            `toml:"device_id"`
+	Address     string            `toml:"address"`
+	Measurement string            `toml:"measurement"`
+	Fields      []fieldDefinition `toml:"fields"`
+	Tags        map[string]string `toml:"tags"`
+}
+
+type field struct {
+	name        string
+	measurement string
+	object      objectID
+	property    uint32
+	scale       float64
+}
+
+type device struct {
+	id      uint32
+	address *net.UDPAddr
+	tags    map[string]string
+	polled  []*field
+	cov     map[objectID][]*field
+	objects []objectID
+
+	// State of the device, the object tags are protected by a separate
+	// mutex as they are accessed on COV notifications
+	remote         *remote
+	rpmUnsupported bool
+	objectTags     map[objectID]map[string]string
+	tagsMu         sync.RWMutex
+	mu             sync.Mutex
+}
+
+func (*BACnet) SampleConfig() string {
+	return sampleConfig
+}
+
+func (b *BACnet) Init() error {
+	// Check options
+	if b.LocalAddress == "" {
+		b.LocalAddress = ":47808"
+	}
+	if b.BroadcastAddress == "" {
+		b.BroadcastAddress = "255.255.255.255:47808"
+	}
+	broadcast, err := net.ResolveUDPAddr("udp4", b.BroadcastAddress)
+	if err != nil {
+		return fmt.Errorf("invalid broadcast address %q: %w", b.BroadcastAddress, err)
+	}
+	b.broadcast = broadcast
+	if b.Timeout <= 0 {
+		b.Timeout = config.Duration(3 * time.Second)
+	}
+	if b.Retries < 0 {
+		return errors.New("'retries' cannot be negative")
+	}
+	switch b.ReadMethod {
+	case "":
+		b.ReadMethod = "multiple"
+	case "single", "multiple":
+	default:
+		return fmt.Errorf("invalid 'read_method' %q", b.ReadMethod)
+	}
+	if b.MaxPropertiesPerRequest <= 0 {
+		b.MaxPropertiesPerRequest = 16
+	}
+	if b.COVLifetime <= 0 {
+		b.COVLifetime = config.Duration(5 * time.Minute)
+	}
+	if time.Duration(b.COVLifetime) < 2*time.Second {
+		return errors.New("'cov_lifetime' must be at least two seconds")
+	}
+	b.lifetime = uint32(time.Duration(b.COVLifetime).Seconds())
+
+	if b.ObjectTags == nil {
+		b.ObjectTags = []string{"object-name", "units"}
+	}
+	for _, name := range b.ObjectTags {
+		switch name {
+		case "object-name", "units", "description":
+		default:
+			return fmt.Errorf("invalid object tag %q", name)
+		}
+		property, err := parseProperty(name)
+		if err != nil {
+			return err
+		}
+		b.objTags = append(b.objTags, property)
+	}
+
+	// Check and prepare the devices
+	if len(b.Devices) == 0 {
+		return errors.New("no devices defined")
+	}
+	seen := make(map[uint32]bool, len(b.Devices))
+	for _, def := range b.Devices {
+		if seen[def.DeviceID] {
+			return fmt.Errorf("duplicate device %d", def.DeviceID)
+		}
+		seen[def.DeviceID] = true
+
+		d, err := def.newDevice()
+		if err != nil {
+			return fmt.Errorf("device %d: %w", def.DeviceID, err)
+		}
+		if len(d.cov) > 0 {
+			b.hasCOV = true
+		}
+		b.devices = append(b.devices, d)
+	}
+
+	return nil
+}
+
+func (def *deviceDefinition) newDevice() (*device, error) {
+	if def.DeviceID > 0x3ffffe {
+		return nil, fmt.Errorf("invalid device instance %d", def.DeviceID)
+	}
+	if def.Measurement == "" {
+		def.Measurement = "bacnet"
+	}
+	if len(def.Fields) == 0 {
+		return nil, errors.New("no fields defined")
+	}
+
+	d := &device{
+		id:   def.DeviceID,
+		tags: def.Tags,
+		cov:  make(map[objectID][]*field),
+	}
+	if def.Address != "" {
+		address := def.Address
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			address = net.JoinHostPort(address, "47808")
+		}
+		addr, err := net.ResolveUDPAddr("udp4", address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %q: %w", def.Address, err)
+		}
+		d.address = addr
+	}
+
+	seenObjects := make(map[objectID]bool)
+	seenFields := make(map[string]bool)
+	for i, fdef := range def.Fields {
+		typ, err := parseObjectType(fdef.ObjectType)
+		if err != nil {
+			return nil, fmt.Errorf("field %d: %w", i+1, err)
+		}
+		if fdef.Instance > 0x3ffffe {
+			return nil, fmt.Errorf("field %d: invalid instance %d", i+1, fdef.Instance)
+		}
+		if fdef.Property == "" {
+			fdef.Property = "present-value"
+		}
+		property, err := parseProperty(fdef.Property)
+		if err != nil {
+			return nil, fmt.Errorf("field %d: %w", i+1, err)
+		}
+		if fdef.Name == "" {
+			fdef.Name = strings.ReplaceAll(fdef.Property, "-", "_")
+		}
+		if fdef.Measurement == "" {
+			fdef.Measurement = def.Measurement
+		}
+
+		f := &field{
+			name:        fdef.Name,
+			measurement: fdef.Measurement,
+			object:      objectID{typ: typ, instance: fdef.Instance},
+			property:    property,
+			scale:       fdef.Scale,
+		}
+
+		// Fields of an object end up in the same metric, so check for
+		// duplicate names
+		key := fdef.Measurement + "|" + f.object.String() + "|" + f.name
+		if seenFields[key] {
+			return nil, fmt.Errorf("field %d: duplicate field %q for object %s", i+1, f.name, f.object)
+		}
+		seenFields[key] = true
+
+		if !seenObjects[f.object] {
+			seenObjects[f.object] = true
+			d.objects = append(d.objects, f.object)
+		}
+		if fdef.COV {
+			d.cov[f.object] = append(d.cov[f.object], f)
+		} else {
+			d.polled = append(d.polled, f)
+		}
+	}
+
+	return d, nil
+}
+
+func (b *BACnet) Start(acc telegraf.Accumulator) error {
+	b.acc = acc
+
+	addr, err := net.ResolveUDPAddr("udp4", b.LocalAddress)
+	if err != nil {
+		return fmt.Errorf("invalid local address %q: %w", b.LocalAddress, err)
+	}
+	conn, err := net.ListenUDP("udp4", addr)
+	if err != nil {
+		return err
+	}
+	b.Log.Debugf("Listening on %s", conn.LocalAddr())
+
+	b.client = newClient(conn, b.broadcast, time.Duration(b.Timeout), b.Retries, b.Log)
+	b.client.onCOV = b.handleCOV
+	b.client.start()
+
+	// Keep the COV subscriptions alive by renewing them in time
+	b.cancel = make(chan struct{})
+	if b.hasCOV {
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+
+			interval := time.Duration(b.COVLifetime) / 2
+			for {
+				b.subscribe()
+				select {
+				case <-b.cancel:
+					return
+				case <-time.After(interval):
+				}
+			}
+		}()
+	}
+
+	return nil
+}
+
+func (b *BACnet) Gather(acc telegraf.Accumulator) error {
+	var wg sync.WaitGroup
+	for _, d := range b.devices {
+		if len(d.polled) == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(d *device) {
+			defer wg.Done()
+			if err := b.poll(acc, d); err != nil {
+				acc.AddError(fmt.Errorf("device %d: %w", d.id, err))
+			}
+		}(d)
+	}
+	wg.Wait()
+
+	return nil
+}
+
+func (b *BACnet) Stop() {
+	if b.cancel != nil {
+		close(b.cancel)
+	}
+	b.wg.Wait()
+
+	// Cancel the subscriptions
+	for _, d := range b.devices {
+		d.mu.Lock()
+		if d.remote != nil {
+			for obj := range d.cov {
+				data := encodeSubscribeCOV(subscriberProcessID, obj, 0)
+				if _, err := b.client.request(d.remote, serviceSubscribeCOV, data); err != nil {
+					b.Log.Debugf("Cancelling subscription for %s on device %d failed: %v", obj, d.id, err)
+				}
+			}
+		}
+		d.mu.Unlock()
+	}
+
+	if b.client != nil {
+		b.client.close()
+	}
+}
+
+// connect determines the address of the device and reads the object
+// properties used as tags if not done already, the device must be locked
+func (b *BACnet) connect(d *device) error {
+	if d.remote == nil {
+		if d.address != nil {
+			d.remote = &remote{addr: d.address}
+		} else {
+			r, err := b.client.discover(d.id)
+			if err != nil {
+				return fmt.Errorf("discovery failed: %w", err)
+			}
+			d.remote = r
+		}
+	}
+
+	d.tagsMu.RLock()
+	initialized := d.objectTags != nil
+	d.tagsMu.RUnlock()
+	if initialized {
+		return nil
+	}
+
+	refs := make([]propertyRef, 0, len(d.objects)*len(b.objTags))
+	for _, obj := range d.objects {
+		for _, property := range b.objTags {
+			refs = append(refs, propertyRef{object: obj, property: property})
+		}
+	}
+	results, err := b.read(d, refs)
+	if err != nil {
+		return fmt.Errorf("reading object tags failed: %w", err)
+	}
+
+	objectTags := make(map[objectID]map[string]string, len(d.objects))
+	for _, obj := range d.objects {
+		tags := map[string]string{
+			"source":          d.remote.addr.IP.String(),
+			"device_id":       strconv.FormatUint(uint64(d.id), 10),
+			"object_type":     objectTypeName(obj.typ),
+			"object_instance": strconv.FormatUint(uint64(obj.instance), 10),
+		}
+		for k, v := range d.tags {
+			tags[k] = v
+		}
+		for i, property := range b.objTags {
+			result := results[propertyRef{object: obj, property: property}]
+			if result.err != nil {
+				// Not all objects have units or a description
+				var serr *serviceError
+				if !errors.As(result.err, &serr) || serr.code != errorCodeUnknownProperty {
+					b.Log.Warnf("Reading %s of %s on device %d failed: %v", b.ObjectTags[i], obj, d.id, result.err)
+				}
+				continue
+			}
+			if len(result.values) != 1 {
+				continue
+			}
+			name := strings.ReplaceAll(b.ObjectTags[i], "-", "_")
+			switch v := result.values[0].(type) {
+			case uint64:
+				if property == propertyUnits {
+					tags[name] = unitName(v)
+				} else {
+					tags[name] = strconv.FormatUint(v, 10)
+				}
+			case string:
+				tags[name] = v
+			default:
+				tags[name] = fmt.Sprintf("%v", v)
+			}
+		}
+		objectTags[obj] = tags
+	}
+
+	d.tagsMu.Lock()
+	d.objectTags = objectTags
+	d.tagsMu.Unlock()
+
+	return nil
+}
+
+// reset forces a rediscovery of the device and rereading the object tags
+// the device must be locked
+func (b *BACnet) reset(d *device) {
+	if d.address == nil {
+		b.client.forget(d.id)
+	}
+	d.remote = nil
+	d.tagsMu.Lock()
+	d.objectTags = nil
+	d.tagsMu.Unlock()
+}
+
+func (b *BACnet) poll(acc telegraf.Accumulator, d *device) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := b.connect(d); err != nil {
+		b.reset(d)
+		return err
+	}
+
+	refs := make([]propertyRef, 0, len(d.polled))
+	for _, f := range d.polled {
+		refs = append(refs, propertyRef{object: f.object, property: f.property})
+	}
+	timestamp := time.Now()
+	results, err := b.read(d, refs)
+	if err != nil {
+		b.reset(d)
+		return err
+	}
+
+	d.tagsMu.RLock()
+	defer d.tagsMu.RUnlock()
+
+	grouper := metric.NewSeriesGrouper()
+	for _, f := range d.polled {
+		result := results[propertyRef{object: f.object, property: f.property}]
+		if result.err != nil {
+			acc.AddError(fmt.Errorf("reading %s of %s on device %d failed: %w", f.name, f.object, d.id, result.err))
+			continue
+		}
+		value, err := f.convert(result.values)
+		if err != nil {
+			acc.AddError(fmt.Errorf("converting %s of %s on device %d failed: %w", f.name, f.object, d.id, err))
+			continue
+		}
+		if value == nil {
+			continue
+		}
+		grouper.Add(f.measurement, d.objectTags[f.object], timestamp, f.name, value)
+	}
+	for _, m := range grouper.Metrics() {
+		acc.AddMetric(m)
+	}
+
+	return nil
+}
+
+// read requests the given properties from the device using the configured
+// method and returns a result for each of the properties
+func (b *BACnet) read(d *device, refs []propertyRef) (map[propertyRef]*propertyResult, error) {
+	if b.ReadMethod == "multiple" && !d.rpmUnsupported {
+		results, err := b.readMultiple(d, refs)
+		if err == nil {
+			return results, nil
+		}
+
+		// Fall back to single reads if the device does not support
+		// ReadPropertyMultiple
+		var serr *serviceError
+		var rerr rejectError
+		if !errors.As(err, &rerr) && (!errors.As(err, &serr) || serr.class != 5) {
+			return nil, err
+		}
+		b.Log.Infof("Device %d does not support ReadPropertyMultiple (%v), using ReadProperty instead", d.id, err)
+		d.rpmUnsupported = true
+	}
+
+	results := make(map[propertyRef]*propertyResult, len(refs))
+	for _, ref := range refs {
+		data, err := b.client.request(d.remote, serviceReadProperty, encodeReadProperty(ref.object, ref.property))
+		if err != nil {
+			var serr *serviceError
+			if !errors.As(err, &serr) {
+				return nil, err
+			}
+			results[ref] = &propertyResult{err: err}
+			continue
+		}
+		values, err := decodeReadPropertyAck(data)
+		if err != nil {
+			return nil, fmt.Errorf("decoding response failed: %w", err)
+		}
+		results[ref] = &propertyResult{values: values}
+	}
+	return results, nil
+}
+
+func (b *BACnet) readMultiple(d *device, refs []propertyRef) (map[propertyRef]*propertyResult, error) {
+	results := make(map[propertyRef]*propertyResult, len(refs))
+	for start := 0; start < len(refs); start += b.MaxPropertiesPerRequest {
+		end := min(start+b.MaxPropertiesPerRequest, len(refs))
+		chunk := refs[start:end]
+
+		data, err := b.client.request(d.remote, serviceReadPropertyMultiple, encodeReadPropertyMultiple(chunk))
+		if err != nil {
+			return nil, err
+		}
+		partial, err := decodeReadPropertyMultipleAck(data)
+		if err != nil {
+			return nil, fmt.Errorf("decoding response failed: %w", err)
+		}
+		for _, ref := range chunk {
+			result, found := partial[ref]
+			if !found {
+				result = &propertyResult{err: errors.New("missing in response")}
+			}
+			results[ref] = result
+		}
+	}
+	return results, nil
+}
+
+// subscribe creates or renews the COV subscriptions of all devices
+func (b *BACnet) subscribe() {
+	for _, d := range b.devices {
+		if len(d.cov) == 0 {
+			continue
+		}
+
+		d.mu.Lock()
+		if err := b.connect(d); err != nil {
+			b.reset(d)
+			d.mu.Unlock()
+			b.acc.AddError(fmt.Errorf("device %d: %w", d.id, err))
+			continue
+		}
+		for obj := range d.cov {
+			data := encodeSubscribeCOV(subscriberProcessID, obj, b.lifetime)
+			if _, err := b.client.request(d.remote, serviceSubscribeCOV, data); err != nil {
+				b.acc.AddError(fmt.Errorf("subscribing to %s on device %d failed: %w", obj, d.id, err))
+			}
+		}
+		d.mu.Unlock()
+	}
+}
+
+func (b *BACnet) handleCOV(n *covNotification) {
+	if n.processID != subscriberProcessID {
+		return
+	}
+
+	var d *device
+	for _, candidate := range b.devices {
+		if candidate.id == n.device {
+			d = candidate
+			break
+		}
+	}
+	if d == nil || len(d.cov[n.object]) == 0 {
+		b.Log.Debugf("Ignoring notification for %s on unknown device %d", n.object, n.device)
+		return
+	}
+
+	d.tagsMu.RLock()
+	tags := d.objectTags[n.object]
+	d.tagsMu.RUnlock()
+	if tags == nil {
+		b.Log.Debugf("Ignoring notification for %s on device %d before subscription", n.object, n.device)
+		return
+	}
+
+	timestamp := time.Now()
+	grouper := metric.NewSeriesGrouper()
+	for _, f := range d.cov[n.object] {
+		values, found := n.values[f.property]
+		if !found {
+			continue
+		}
+		value, err := f.convert(values)
+		if err != nil {
+			b.acc.AddError(fmt.Errorf("converting %s of %s on device %d failed: %w", f.name, f.object, d.id, err))
+			continue
+		}
+		if value == nil {
+			continue
+		}
+		grouper.Add(f.measurement, tags, timestamp, f.name, value)
+	}
+	for _, m := range grouper.Metrics() {
+		b.acc.AddMetric(m)
+	}
+}
+
+// convert returns the field value for the given property values
+func (f *field) convert(values []interface{}) (interface{}, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	if len(values) > 1 {
+		return nil, fmt.Errorf("cannot convert %d values", len(values))
+	}
+
+	var value interface{}
+	switch v := values[0].(type) {
+	case float32:
+		value = float64(v)
+	default:
+		value = v
+	}
+	if f.scale == 0 {
+		return value, nil
+	}
+
+	switch v := value.(type) {
+	case float64:
+		return v * f.scale, nil
+	case uint64:
+		return float64(v) * f.scale, nil
+	case int64:
+		return float64(v) * f.scale, nil
+	}
+	return nil, fmt.Errorf("cannot scale value of type %T", value)
+}
+
+func init() {
+	inputs.Add("bacnet", func() telegraf.Input {
+		return &BACnet{}
+	})
+}
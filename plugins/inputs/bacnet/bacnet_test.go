@@ -0,0 +1,617 @@
+package bacnet
+
+import (
+	"encoding/binary"
+	"math"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *BACnet
+		expected string
+	}{
+		{
+			name:     "no devices",
+			plugin:   &BACnet{},
+			expected: "no devices defined",
+		},
+		{
+			name:     "invalid read method",
+			plugin:   &BACnet{ReadMethod: "all"},
+			expected: `invalid 'read_method' "all"`,
+		},
+		{
+			name:     "invalid object tag",
+			plugin:   &BACnet{ObjectTags: []string{"present-value"}},
+			expected: `invalid object tag "present-value"`,
+		},
+		{
+			name: "no fields",
+			plugin: &BACnet{
+				Devices: []deviceDefinition{{DeviceID: 1}},
+			},
+			expected: "device 1: no fields defined",
+		},
+		{
+			name: "unknown object type",
+			plugin: &BACnet{
+				Devices: []deviceDefinition{
+					{DeviceID: 1, Fields: []fieldDefinition{{ObjectType: "analog-thing"}}},
+				},
+			},
+			expected: `field 1: unknown object type "analog-thing"`,
+		},
+		{
+			name: "unknown property",
+			plugin: &BACnet{
+				Devices: []deviceDefinition{
+					{DeviceID: 1, Fields: []fieldDefinition{{ObjectType: "analog-input", Property: "foo"}}},
+				},
+			},
+			expected: `field 1: unknown property "foo"`,
+		},
+		{
+			name: "duplicate field",
+			plugin: &BACnet{
+				Devices: []deviceDefinition{
+					{
+						DeviceID: 1,
+						Fields: []fieldDefinition{
+							{ObjectType: "analog-input", Instance: 1},
+							{ObjectType: "0", Instance: 1},
+						},
+					},
+				},
+			},
+			expected: `field 2: duplicate field "present_value" for object analog-input:1`,
+		},
+		{
+			name: "duplicate device",
+			plugin: &BACnet{
+				Devices: []deviceDefinition{
+					{DeviceID: 1, Fields: []fieldDefinition{{ObjectType: "analog-input"}}},
+					{DeviceID: 1, Fields: []fieldDefinition{{ObjectType: "analog-input"}}},
+				},
+			},
+			expected: "duplicate device 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.plugin.Log = &testutil.Logger{}
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestGather(t *testing.T) {
+	for _, method := range []string{"multiple", "single"} {
+		t.Run(method, func(t *testing.T) {
+			sim := newSimulator(t, 4711, false)
+
+			plugin := &BACnet{
+				LocalAddress:            "127.0.0.1:0",
+				BroadcastAddress:        sim.address(),
+				Timeout:                 config.Duration(time.Second),
+				ReadMethod:              method,
+				MaxPropertiesPerRequest: 2,
+				Devices: []deviceDefinition{
+					{
+						DeviceID: 4711,
+						Fields: []fieldDefinition{
+							{Name: "temperature", ObjectType: "analog-input", Instance: 1},
+							{Name: "status", ObjectType: "analog-input", Instance: 1, Property: "status-flags"},
+							{Name: "running", ObjectType: "binary-input", Instance: 2},
+							{Name: "energy", ObjectType: "analog-value", Instance: 3, Scale: 0.001, Measurement: "meter"},
+						},
+						Tags: map[string]string{"building": "A"},
+					},
+				},
+				Log: &testutil.Logger{},
+			}
+			require.NoError(t, plugin.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, plugin.Start(&acc))
+			defer plugin.Stop()
+
+			require.NoError(t, plugin.Gather(&acc))
+			require.Empty(t, acc.Errors)
+
+			expected := []telegraf.Metric{
+				metric.New(
+					"bacnet",
+					map[string]string{
+						"source":          "127.0.0.1",
+						"device_id":       "4711",
+						"object_type":     "analog-input",
+						"object_instance": "1",
+						"object_name":     "Supply Air Temperature",
+						"units":           "degrees-celsius",
+						"building":        "A",
+					},
+					map[string]interface{}{
+						"temperature": float64(21.5),
+						"status":      "0100",
+					},
+					time.Unix(0, 0),
+				),
+				metric.New(
+					"bacnet",
+					map[string]string{
+						"source":          "127.0.0.1",
+						"device_id":       "4711",
+						"object_type":     "binary-input",
+						"object_instance": "2",
+						"object_name":     "Fan Status",
+						"building":        "A",
+					},
+					map[string]interface{}{
+						"running": uint64(1),
+					},
+					time.Unix(0, 0),
+				),
+				metric.New(
+					"meter",
+					map[string]string{
+						"source":          "127.0.0.1",
+						"device_id":       "4711",
+						"object_type":     "analog-value",
+						"object_instance": "3",
+						"object_name":     "Energy",
+						"units":           "watt-hours",
+						"building":        "A",
+					},
+					map[string]interface{}{
+						"energy": float64(12.5),
+					},
+					time.Unix(0, 0),
+				),
+			}
+			testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.SortMetrics(), testutil.IgnoreTime())
+
+			// Check the device was discovered and the configured method was used
+			services := sim.received()
+			require.Contains(t, services, "who-is")
+			if method == "multiple" {
+				require.Contains(t, services, "read-property-multiple")
+				require.NotContains(t, services, "read-property")
+			} else {
+				require.Contains(t, services, "read-property")
+				require.NotContains(t, services, "read-property-multiple")
+			}
+		})
+	}
+}
+
+func TestGatherFallback(t *testing.T) {
+	sim := newSimulator(t, 1, true)
+
+	plugin := &BACnet{
+		LocalAddress: "127.0.0.1:0",
+		Timeout:      config.Duration(time.Second),
+		ObjectTags:   []string{},
+		Devices: []deviceDefinition{
+			{
+				DeviceID: 1,
+				Address:  sim.address(),
+				Fields: []fieldDefinition{
+					{Name: "temperature", ObjectType: "analog-input", Instance: 1},
+					{Name: "unknown", ObjectType: "analog-input", Instance: 1, Property: "high-limit"},
+				},
+			},
+		},
+		Log: &testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	require.NoError(t, plugin.Gather(&acc))
+	require.Len(t, acc.Errors, 1)
+	require.ErrorContains(t, acc.Errors[0], "property error unknown-property")
+
+	expected := []telegraf.Metric{
+		metric.New(
+			"bacnet",
+			map[string]string{
+				"source":          "127.0.0.1",
+				"device_id":       "1",
+				"object_type":     "analog-input",
+				"object_instance": "1",
+			},
+			map[string]interface{}{
+				"temperature": float64(21.5),
+			},
+			time.Unix(0, 0),
+		),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.IgnoreTime())
+
+	// The device was addressed directly and the fallback is remembered
+	require.NoError(t, plugin.Gather(&acc))
+	services := sim.received()
+	require.NotContains(t, services, "who-is")
+	require.Equal(t, 1, countOf(services, "read-property-multiple"))
+}
+
+func TestCOV(t *testing.T) {
+	sim := newSimulator(t, 4711, false)
+
+	plugin := &BACnet{
+		LocalAddress:     "127.0.0.1:0",
+		BroadcastAddress: sim.address(),
+		Timeout:          config.Duration(time.Second),
+		Devices: []deviceDefinition{
+			{
+				DeviceID: 4711,
+				Fields: []fieldDefinition{
+					{Name: "temperature", ObjectType: "analog-input", Instance: 1, COV: true},
+					{Name: "running", ObjectType: "binary-input", Instance: 2},
+				},
+			},
+		},
+		Log: &testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	expected := []telegraf.Metric{
+		metric.New(
+			"bacnet",
+			map[string]string{
+				"source":          "127.0.0.1",
+				"device_id":       "4711",
+				"object_type":     "analog-input",
+				"object_instance": "1",
+				"object_name":     "Supply Air Temperature",
+				"units":           "degrees-celsius",
+			},
+			map[string]interface{}{
+				"temperature": float64(22.25),
+			},
+			time.Unix(0, 0),
+		),
+	}
+	require.Eventually(t, func() bool {
+		return acc.NMetrics() >= uint64(len(expected))
+	}, 3*time.Second, 100*time.Millisecond)
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.IgnoreTime())
+	require.Empty(t, acc.Errors)
+
+	// COV fields must not be polled
+	acc.ClearMetrics()
+	require.NoError(t, plugin.Gather(&acc))
+	require.Len(t, acc.GetTelegrafMetrics(), 1)
+	require.Equal(t, "binary-input", acc.GetTelegrafMetrics()[0].Tags()["object_type"])
+	require.Contains(t, sim.received(), "subscribe-cov")
+}
+
+func TestDecodeTags(t *testing.T) {
+	// Extended tag number and length
+	buf := appendTag(nil, 20, true, 300)
+	buf = append(buf, make([]byte, 300)...)
+	tg, rest, err := readTag(buf)
+	require.NoError(t, err)
+	require.Empty(t, rest)
+	require.True(t, tg.context)
+	require.Equal(t, uint8(20), tg.number)
+	require.Len(t, tg.data, 300)
+
+	// Truncated data
+	_, _, err = readTag(buf[:10])
+	require.ErrorIs(t, err, errTruncated)
+
+	// Signed values
+	v, err := decodeSigned([]byte{0xff, 0x38})
+	require.NoError(t, err)
+	require.Equal(t, int64(-200), v)
+}
+
+func countOf(list []string, value string) int {
+	var n int
+	for _, v := range list {
+		if v == value {
+			n++
+		}
+	}
+	return n
+}
+
+// simulator is a BACnet/IP device responding to the services used by the
+// plugin
+type simulator struct {
+	conn      *net.UDPConn
+	device    uint32
+	objects   map[objectID]map[uint32][]byte
+	rejectRPM bool
+
+	services []string
+	mu       sync.Mutex
+	wg       sync.WaitGroup
+}
+
+func newSimulator(t *testing.T, device uint32, rejectRPM bool) *simulator {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+
+	ai1 := objectID{typ: 0, instance: 1}
+	bi2 := objectID{typ: 3, instance: 2}
+	av3 := objectID{typ: 2, instance: 3}
+	s := &simulator{
+		conn:      conn,
+		device:    device,
+		rejectRPM: rejectRPM,
+		objects: map[objectID]map[uint32][]byte{
+			ai1: {
+				propertyObjectName:   appCharacterString("Supply Air Temperature"),
+				propertyPresentValue: appReal(21.5),
+				propertyUnits:        appEnumerated(62),
+				111:                  appBitString(4, 0x40),
+			},
+			bi2: {
+				propertyObjectName:   appCharacterString("Fan Status"),
+				propertyPresentValue: appEnumerated(1),
+			},
+			av3: {
+				propertyObjectName:   appCharacterString("Energy"),
+				propertyPresentValue: appUnsigned(12500),
+				propertyUnits:        appEnumerated(18),
+			},
+		},
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			s.handle(addr, buf[:n])
+		}
+	}()
+
+	t.Cleanup(func() {
+		conn.Close()
+		s.wg.Wait()
+	})
+
+	return s
+}
+
+func (s *simulator) address() string {
+	return s.conn.LocalAddr().String()
+}
+
+func (s *simulator) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.services...)
+}
+
+func (s *simulator) record(service string) {
+	s.mu.Lock()
+	s.services = append(s.services, service)
+	s.mu.Unlock()
+}
+
+func (s *simulator) send(addr *net.UDPAddr, apdu []byte) {
+	npdu := append(appendNPDU(nil, nil, false), apdu...)
+	//nolint:errcheck // Ignore errors in simulator
+	s.conn.WriteToUDP(appendBVLC(bvlcOriginalUnicast, npdu), addr)
+}
+
+func (s *simulator) handle(addr *net.UDPAddr, buf []byte) {
+	if len(buf) < 4 {
+		return
+	}
+	_, apdu, err := parseNPDU(buf[4:])
+	if err != nil || len(apdu) < 2 {
+		return
+	}
+
+	if apdu[0]>>4 == pduUnconfirmedRequest {
+		if apdu[1] != serviceWhoIs {
+			return
+		}
+		s.record("who-is")
+		low, rest, err := expectContext(apdu[2:], 0)
+		if err != nil {
+			return
+		}
+		high, _, err := expectContext(rest, 1)
+		if err != nil {
+			return
+		}
+		l, _ := low.unsigned()
+		h, _ := high.unsigned()
+		if s.device < l || s.device > h {
+			return
+		}
+
+		// Respond with I-Am
+		resp := []byte{pduUnconfirmedRequest << 4, serviceIAm}
+		resp = append(resp, appObjectID(objectID{typ: objectTypeDevice, instance: s.device})...)
+		resp = append(resp, appUnsigned(1476)...)
+		resp = append(resp, appEnumerated(3)...)
+		resp = append(resp, appUnsigned(999)...)
+		s.send(addr, resp)
+		return
+	}
+
+	if apdu[0]>>4 != pduConfirmedRequest || len(apdu) < 4 {
+		return
+	}
+	invokeID, service, data := apdu[2], apdu[3], apdu[4:]
+	switch service {
+	case serviceReadProperty:
+		s.record("read-property")
+		t, rest, err := expectContext(data, 0)
+		if err != nil {
+			return
+		}
+		obj := decodeObjectID(binary.BigEndian.Uint32(t.data))
+		t, _, err = expectContext(rest, 1)
+		if err != nil {
+			return
+		}
+		property, _ := t.unsigned()
+		value, class, code := s.lookup(obj, property)
+		if value == nil {
+			resp := []byte{pduError << 4, invokeID, service}
+			resp = append(resp, appEnumerated(class)...)
+			resp = append(resp, appEnumerated(code)...)
+			s.send(addr, resp)
+			return
+		}
+		resp := []byte{pduComplexAck << 4, invokeID, service}
+		resp = appendContextObjectID(resp, 0, obj)
+		resp = appendContextUnsigned(resp, 1, property)
+		resp = appendOpeningTag(resp, 3)
+		resp = append(resp, value...)
+		resp = appendClosingTag(resp, 3)
+		s.send(addr, resp)
+	case serviceReadPropertyMultiple:
+		s.record("read-property-multiple")
+		if s.rejectRPM {
+			s.send(addr, []byte{pduReject << 4, invokeID, 9})
+			return
+		}
+		resp := []byte{pduComplexAck << 4, invokeID, service}
+		for len(data) > 0 {
+			t, rest, err := expectContext(data, 0)
+			if err != nil {
+				return
+			}
+			obj := decodeObjectID(binary.BigEndian.Uint32(t.data))
+			if rest, err = expectOpening(rest, 1); err != nil {
+				return
+			}
+			resp = appendContextObjectID(resp, 0, obj)
+			resp = appendOpeningTag(resp, 1)
+			for {
+				t, r, err := readTag(rest)
+				if err != nil {
+					return
+				}
+				rest = r
+				if t.closing {
+					break
+				}
+				property, _ := t.unsigned()
+				resp = appendContextUnsigned(resp, 2, property)
+				value, class, code := s.lookup(obj, property)
+				if value == nil {
+					resp = appendOpeningTag(resp, 5)
+					resp = append(resp, appEnumerated(class)...)
+					resp = append(resp, appEnumerated(code)...)
+					resp = appendClosingTag(resp, 5)
+					continue
+				}
+				resp = appendOpeningTag(resp, 4)
+				resp = append(resp, value...)
+				resp = appendClosingTag(resp, 4)
+			}
+			resp = appendClosingTag(resp, 1)
+			data = rest
+		}
+		s.send(addr, resp)
+	case serviceSubscribeCOV:
+		s.record("subscribe-cov")
+		t, rest, err := expectContext(data, 0)
+		if err != nil {
+			return
+		}
+		processID, _ := t.unsigned()
+		t, rest, err = expectContext(rest, 1)
+		if err != nil {
+			return
+		}
+		obj := decodeObjectID(binary.BigEndian.Uint32(t.data))
+		s.send(addr, []byte{pduSimpleAck << 4, invokeID, service})
+
+		// Ignore cancellations
+		if len(rest) == 0 {
+			return
+		}
+
+		// Send the initial notification with a changed value
+		resp := []byte{pduUnconfirmedRequest << 4, serviceUnconfirmedCOVNotification}
+		resp = appendContextUnsigned(resp, 0, processID)
+		resp = appendContextObjectID(resp, 1, objectID{typ: objectTypeDevice, instance: s.device})
+		resp = appendContextObjectID(resp, 2, obj)
+		resp = appendContextUnsigned(resp, 3, 300)
+		resp = appendOpeningTag(resp, 4)
+		resp = appendContextUnsigned(resp, 0, propertyPresentValue)
+		resp = appendOpeningTag(resp, 2)
+		resp = append(resp, appReal(22.25)...)
+		resp = appendClosingTag(resp, 2)
+		resp = appendContextUnsigned(resp, 0, 111)
+		resp = appendOpeningTag(resp, 2)
+		resp = append(resp, appBitString(4, 0x00)...)
+		resp = appendClosingTag(resp, 2)
+		resp = appendClosingTag(resp, 4)
+		s.send(addr, resp)
+	}
+}
+
+func (s *simulator) lookup(obj objectID, property uint32) (value []byte, class, code uint32) {
+	props, found := s.objects[obj]
+	if !found {
+		return nil, 1, 31
+	}
+	value, found = props[property]
+	if !found {
+		return nil, 2, errorCodeUnknownProperty
+	}
+	return value, 0, 0
+}
+
+func appUnsigned(v uint32) []byte {
+	data := encodeUnsigned(v)
+	return append(appendTag(nil, tagUnsigned, false, len(data)), data...)
+}
+
+func appEnumerated(v uint32) []byte {
+	data := encodeUnsigned(v)
+	return append(appendTag(nil, tagEnumerated, false, len(data)), data...)
+}
+
+func appReal(v float32) []byte {
+	return binary.BigEndian.AppendUint32(appendTag(nil, tagReal, false, 4), math.Float32bits(v))
+}
+
+func appCharacterString(v string) []byte {
+	buf := appendTag(nil, tagCharacterString, false, len(v)+1)
+	buf = append(buf, 0)
+	return append(buf, v...)
+}
+
+func appBitString(bits int, v byte) []byte {
+	return append(appendTag(nil, tagBitString, false, 2), byte(8-bits), v)
+}
+
+func appObjectID(obj objectID) []byte {
+	return binary.BigEndian.AppendUint32(appendTag(nil, tagObjectID, false, 4), obj.encode())
+}
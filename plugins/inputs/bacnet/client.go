@@ -0,0 +1,326 @@
+package bacnet
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+)
+
+// remote is the address of a BACnet device
+type remote struct {
+	addr  *net.UDPAddr
+	route *route
+}
+
+func (r *remote) key() string {
+	return remoteKey(r.addr, r.route)
+}
+
+func remoteKey(addr *net.UDPAddr, rt *route) string {
+	if rt == nil {
+		return addr.String()
+	}
+	return addr.String() + "/" + strconv.FormatUint(uint64(rt.network), 10) + "/" + hex.EncodeToString(rt.mac)
+}
+
+type response struct {
+	pduType uint8
+	data    []byte
+}
+
+type pendingKey struct {
+	remote   string
+	invokeID uint8
+}
+
+// client handles the BACnet/IP communication over a single UDP socket
+type client struct {
+	conn      *net.UDPConn
+	broadcast *net.UDPAddr
+	timeout   time.Duration
+	retries   int
+	onCOV     func(*covNotification)
+	log       telegraf.Logger
+
+	invokeID   uint8
+	pending    map[pendingKey]chan *response
+	discovered map[uint32]*remote
+	waiting    map[uint32][]chan struct{}
+	mu         sync.Mutex
+	wg         sync.WaitGroup
+}
+
+func newClient(conn *net.UDPConn, broadcast *net.UDPAddr, timeout time.Duration, retries int, log telegraf.Logger) *client {
+	return &client{
+		conn:       conn,
+		broadcast:  broadcast,
+		timeout:    timeout,
+		retries:    retries,
+		log:        log,
+		pending:    make(map[pendingKey]chan *response),
+		discovered: make(map[uint32]*remote),
+		waiting:    make(map[uint32][]chan struct{}),
+	}
+}
+
+func (c *client) start() {
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		buf := make([]byte, 65536)
+		for {
+			n, addr, err := c.conn.ReadFromUDP(buf)
+			if err != nil {
+				if errors.Is(err, net.ErrClosed) {
+					return
+				}
+				c.log.Errorf("Reading packet failed: %v", err)
+				continue
+			}
+			if err := c.handle(addr, buf[:n]); err != nil {
+				c.log.Debugf("Handling packet from %s failed: %v", addr, err)
+			}
+		}
+	}()
+}
+
+func (c *client) close() {
+	c.conn.Close()
+	c.wg.Wait()
+}
+
+func (c *client) handle(addr *net.UDPAddr, buf []byte) error {
+	if len(buf) < 4 || buf[0] != bvlcType {
+		return errors.New("not a BACnet/IP packet")
+	}
+	if int(binary.BigEndian.Uint16(buf[2:4])) != len(buf) {
+		return errors.New("invalid BVLC length")
+	}
+	function := buf[1]
+	buf = buf[4:]
+
+	switch function {
+	case bvlcOriginalUnicast, bvlcOriginalBroadcast:
+	case bvlcForwardedNPDU:
+		// Use the address of the originating device instead of the BBMD
+		if len(buf) < 6 {
+			return errTruncated
+		}
+		addr = &net.UDPAddr{
+			IP:   net.IPv4(buf[0], buf[1], buf[2], buf[3]),
+			Port: int(binary.BigEndian.Uint16(buf[4:6])),
+		}
+		buf = buf[6:]
+	default:
+		return nil
+	}
+
+	header, apdu, err := parseNPDU(buf)
+	if err != nil {
+		return err
+	}
+	if header.networkMessage || len(apdu) < 2 {
+		return nil
+	}
+	src := &remote{addr: addr, route: header.source}
+
+	pduType := apdu[0] >> 4
+	switch pduType {
+	case pduConfirmedRequest:
+		if len(apdu) < 4 {
+			return errTruncated
+		}
+		invokeID, service := apdu[2], apdu[3]
+		if apdu[0]&0x08 != 0 || service != serviceConfirmedCOVNotification {
+			return fmt.Errorf("unsupported confirmed service %d", service)
+		}
+		notification, err := decodeCOVNotification(apdu[4:])
+		if err != nil {
+			return err
+		}
+		if err := c.send(src, []byte{pduSimpleAck << 4, invokeID, service}, false); err != nil {
+			return err
+		}
+		if c.onCOV != nil {
+			c.onCOV(notification)
+		}
+	case pduUnconfirmedRequest:
+		switch apdu[1] {
+		case serviceIAm:
+			device, err := decodeIAm(apdu[2:])
+			if err != nil {
+				return err
+			}
+			c.log.Debugf("Discovered device %d at %s", device, src.key())
+
+			c.mu.Lock()
+			c.discovered[device] = src
+			for _, ch := range c.waiting[device] {
+				close(ch)
+			}
+			delete(c.waiting, device)
+			c.mu.Unlock()
+		case serviceUnconfirmedCOVNotification:
+			notification, err := decodeCOVNotification(apdu[2:])
+			if err != nil {
+				return err
+			}
+			if c.onCOV != nil {
+				c.onCOV(notification)
+			}
+		}
+	case pduSimpleAck, pduComplexAck, pduError, pduReject, pduAbort:
+		key := pendingKey{remote: src.key(), invokeID: apdu[1]}
+		c.mu.Lock()
+		ch, found := c.pending[key]
+		delete(c.pending, key)
+		c.mu.Unlock()
+		if !found {
+			return fmt.Errorf("unexpected response with invoke ID %d", apdu[1])
+		}
+		// Copy the data as the receive buffer is reused
+		ch <- &response{pduType: pduType, data: append([]byte(nil), apdu...)}
+	}
+	return nil
+}
+
+func (c *client) send(dst *remote, apdu []byte, expectReply bool) error {
+	npdu := appendNPDU(make([]byte, 0, 16+len(apdu)), dst.route, expectReply)
+	npdu = append(npdu, apdu...)
+	_, err := c.conn.WriteToUDP(appendBVLC(bvlcOriginalUnicast, npdu), dst.addr)
+	return err
+}
+
+// whoIs broadcasts a Who-Is request for the given device instance to all
+// networks
+func (c *client) whoIs(device uint32) error {
+	npdu := appendNPDU(nil, &route{network: 0xffff}, false)
+	npdu = append(npdu, pduUnconfirmedRequest<<4, serviceWhoIs)
+	npdu = append(npdu, encodeWhoIs(device, device)...)
+	_, err := c.conn.WriteToUDP(appendBVLC(bvlcOriginalBroadcast, npdu), c.broadcast)
+	return err
+}
+
+// discover returns the address of the given device, sending a Who-Is
+// request if the device is not known yet
+func (c *client) discover(device uint32) (*remote, error) {
+	for i := 0; i <= c.retries; i++ {
+		c.mu.Lock()
+		if r, found := c.discovered[device]; found {
+			c.mu.Unlock()
+			return r, nil
+		}
+		ch := make(chan struct{})
+		c.waiting[device] = append(c.waiting[device], ch)
+		c.mu.Unlock()
+
+		if err := c.whoIs(device); err != nil {
+			return nil, fmt.Errorf("sending Who-Is failed: %w", err)
+		}
+		select {
+		case <-ch:
+		case <-time.After(c.timeout):
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if r, found := c.discovered[device]; found {
+		return r, nil
+	}
+	return nil, errors.New("device not found")
+}
+
+// forget removes the device from the list of discovered devices, e.g. if it
+// does not respond anymore
+func (c *client) forget(device uint32) {
+	c.mu.Lock()
+	delete(c.discovered, device)
+	c.mu.Unlock()
+}
+
+// request sends a confirmed request to the device and waits for the
+// response. The service data of complex acknowledgements is returned.
+func (c *client) request(dst *remote, service uint8, data []byte) ([]byte, error) {
+	for i := 0; i <= c.retries; i++ {
+		c.mu.Lock()
+		var key pendingKey
+		for {
+			c.invokeID++
+			key = pendingKey{remote: dst.key(), invokeID: c.invokeID}
+			if _, found := c.pending[key]; !found {
+				break
+			}
+		}
+		ch := make(chan *response, 1)
+		c.pending[key] = ch
+		c.mu.Unlock()
+
+		// Maximum segments unspecified, maximum APDU length of 1476 bytes
+		apdu := make([]byte, 0, 4+len(data))
+		apdu = append(apdu, pduConfirmedRequest<<4, 0x05, key.invokeID, service)
+		apdu = append(apdu, data...)
+		if err := c.send(dst, apdu, true); err != nil {
+			c.mu.Lock()
+			delete(c.pending, key)
+			c.mu.Unlock()
+			return nil, err
+		}
+
+		select {
+		case resp := <-ch:
+			return parseResponse(resp, service)
+		case <-time.After(c.timeout):
+			c.mu.Lock()
+			delete(c.pending, key)
+			c.mu.Unlock()
+		}
+	}
+	return nil, errors.New("request timed out")
+}
+
+func parseResponse(resp *response, service uint8) ([]byte, error) {
+	apdu := resp.data
+	switch resp.pduType {
+	case pduSimpleAck:
+		return nil, nil
+	case pduComplexAck:
+		if apdu[0]&0x08 != 0 {
+			return nil, errors.New("segmented responses are not supported")
+		}
+		if len(apdu) < 3 {
+			return nil, errTruncated
+		}
+		if apdu[2] != service {
+			return nil, fmt.Errorf("unexpected service %d in response", apdu[2])
+		}
+		return apdu[3:], nil
+	case pduError:
+		if len(apdu) < 3 {
+			return nil, errTruncated
+		}
+		serr, _, err := decodeErrorSequence(apdu[3:])
+		if err != nil {
+			return nil, fmt.Errorf("decoding error failed: %w", err)
+		}
+		return nil, serr
+	case pduReject:
+		if len(apdu) < 3 {
+			return nil, errTruncated
+		}
+		return nil, rejectError(apdu[2])
+	case pduAbort:
+		if len(apdu) < 3 {
+			return nil, errTruncated
+		}
+		return nil, abortError(apdu[2])
+	}
+	return nil, fmt.Errorf("unexpected PDU type %d", resp.pduType)
+}
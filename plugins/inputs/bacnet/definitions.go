@@ -0,0 +1,211 @@
+package bacnet
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const objectTypeDevice = 8
+
+// Object types as defined in ASHRAE 135 clause 21 (BACnetObjectType)
+var objectTypes = map[string]uint16{
+	"analog-input":           0,
+	"analog-output":          1,
+	"analog-value":           2,
+	"binary-input":           3,
+	"binary-output":          4,
+	"binary-value":           5,
+	"calendar":               6,
+	"command":                7,
+	"device":                 8,
+	"event-enrollment":       9,
+	"file":                   10,
+	"group":                  11,
+	"loop":                   12,
+	"multi-state-input":      13,
+	"multi-state-output":     14,
+	"notification-class":     15,
+	"program":                16,
+	"schedule":               17,
+	"averaging":              18,
+	"multi-state-value":      19,
+	"trend-log":              20,
+	"life-safety-point":      21,
+	"life-safety-zone":       22,
+	"accumulator":            23,
+	"pulse-converter":        24,
+	"integer-value":          45,
+	"large-analog-value":     46,
+	"positive-integer-value": 48,
+}
+
+// Property identifiers as defined in ASHRAE 135 clause 21
+// (BACnetPropertyIdentifier)
+var properties = map[string]uint32{
+	"cov-increment":      22,
+	"description":        28,
+	"event-state":        36,
+	"firmware-revision":  44,
+	"high-limit":         45,
+	"low-limit":          59,
+	"max-pres-value":     65,
+	"min-pres-value":     69,
+	"model-name":         70,
+	"number-of-states":   74,
+	"object-name":        77,
+	"out-of-service":     81,
+	"present-value":      85,
+	"priority-array":     87,
+	"reliability":        103,
+	"relinquish-default": 104,
+	"state-text":         110,
+	"status-flags":       111,
+	"system-status":      112,
+	"units":              117,
+	"vendor-name":        121,
+}
+
+const (
+	propertyObjectName   = 77
+	propertyPresentValue = 85
+	propertyUnits        = 117
+)
+
+// Engineering units as defined in ASHRAE 135 clause 21 (BACnetEngineeringUnits)
+var units = []string{
+	"square-meters", "square-feet", "milliamperes", "amperes", "ohms", "volts",
+	"kilovolts", "megavolts", "volt-amperes", "kilovolt-amperes",
+	"megavolt-amperes", "volt-amperes-reactive", "kilovolt-amperes-reactive",
+	"megavolt-amperes-reactive", "degrees-phase", "power-factor", "joules",
+	"kilojoules", "watt-hours", "kilowatt-hours", "btus", "therms", "ton-hours",
+	"joules-per-kilogram-dry-air", "btus-per-pound-dry-air", "cycles-per-hour",
+	"cycles-per-minute", "hertz", "grams-of-water-per-kilogram-dry-air",
+	"percent-relative-humidity", "millimeters", "meters", "inches", "feet",
+	"watts-per-square-foot", "watts-per-square-meter", "lumens", "luxes",
+	"foot-candles", "kilograms", "pounds-mass", "tons", "kilograms-per-second",
+	"kilograms-per-minute", "kilograms-per-hour", "pounds-mass-per-minute",
+	"pounds-mass-per-hour", "watts", "kilowatts", "megawatts", "btus-per-hour",
+	"horsepower", "tons-refrigeration", "pascals", "kilopascals", "bars",
+	"pounds-force-per-square-inch", "centimeters-of-water", "inches-of-water",
+	"millimeters-of-mercury", "centimeters-of-mercury", "inches-of-mercury",
+	"degrees-celsius", "degrees-kelvin", "degrees-fahrenheit",
+	"degree-days-celsius", "degree-days-fahrenheit", "years", "months", "weeks",
+	"days", "hours", "minutes", "seconds", "meters-per-second",
+	"kilometers-per-hour", "feet-per-second", "feet-per-minute",
+	"miles-per-hour", "cubic-feet", "cubic-meters", "imperial-gallons", "liters",
+	"us-gallons", "cubic-feet-per-minute", "cubic-meters-per-second",
+	"imperial-gallons-per-minute", "liters-per-second", "liters-per-minute",
+	"us-gallons-per-minute", "degrees-angular", "degrees-celsius-per-hour",
+	"degrees-celsius-per-minute", "degrees-fahrenheit-per-hour",
+	"degrees-fahrenheit-per-minute", "no-units", "parts-per-million",
+	"parts-per-billion", "percent", "percent-per-second", "per-minute",
+	"per-second", "psi-per-degree-fahrenheit", "radians",
+	"revolutions-per-minute",
+}
+
+var errorClasses = []string{
+	"device", "object", "property", "resources", "security", "services", "vt", "communication",
+}
+
+var errorCodes = map[uint32]string{
+	0:  "other",
+	2:  "configuration-in-progress",
+	3:  "device-busy",
+	9:  "invalid-data-type",
+	25: "operational-problem",
+	27: "read-access-denied",
+	31: "unknown-object",
+	32: "unknown-property",
+	42: "invalid-array-index",
+	50: "property-is-not-an-array",
+}
+
+const errorCodeUnknownProperty = 32
+
+var rejectReasons = []string{
+	"other", "buffer-overflow", "inconsistent-parameters", "invalid-parameter-data-type",
+	"invalid-tag", "missing-required-parameter", "parameter-out-of-range",
+	"too-many-arguments", "undefined-enumeration", "unrecognized-service",
+}
+
+var abortReasons = []string{
+	"other", "buffer-overflow", "invalid-apdu-in-this-state",
+	"preempted-by-higher-priority-task", "segmentation-not-supported",
+	"security-error", "insufficient-security", "window-size-out-of-range",
+	"application-exceeded-reply-time", "out-of-resources", "tsm-timeout",
+	"apdu-too-long",
+}
+
+func objectTypeName(typ uint16) string {
+	for name, t := range objectTypes {
+		if t == typ {
+			return name
+		}
+	}
+	return strconv.FormatUint(uint64(typ), 10)
+}
+
+func unitName(v uint64) string {
+	if v < uint64(len(units)) {
+		return units[v]
+	}
+	return strconv.FormatUint(v, 10)
+}
+
+func enumName(names []string, v uint32) string {
+	if v < uint32(len(names)) {
+		return names[v]
+	}
+	return strconv.FormatUint(uint64(v), 10)
+}
+
+// parseObjectType accepts the name or the number of an object type
+func parseObjectType(s string) (uint16, error) {
+	if typ, found := objectTypes[strings.ToLower(s)]; found {
+		return typ, nil
+	}
+	typ, err := strconv.ParseUint(s, 10, 10)
+	if err != nil {
+		return 0, fmt.Errorf("unknown object type %q", s)
+	}
+	return uint16(typ), nil
+}
+
+// parseProperty accepts the name or the number of a property identifier
+func parseProperty(s string) (uint32, error) {
+	if property, found := properties[strings.ToLower(s)]; found {
+		return property, nil
+	}
+	property, err := strconv.ParseUint(s, 10, 22)
+	if err != nil {
+		return 0, fmt.Errorf("unknown property %q", s)
+	}
+	return uint32(property), nil
+}
+
+// serviceError represents an error returned by the device
+type serviceError struct {
+	class uint32
+	code  uint32
+}
+
+func (e *serviceError) Error() string {
+	code, found := errorCodes[e.code]
+	if !found {
+		code = strconv.FormatUint(uint64(e.code), 10)
+	}
+	return fmt.Sprintf("%s error %s", enumName(errorClasses, e.class), code)
+}
+
+type rejectError uint8
+
+func (e rejectError) Error() string {
+	return "request rejected: " + enumName(rejectReasons, uint32(e))
+}
+
+type abortError uint8
+
+func (e abortError) Error() string {
+	return "request aborted: " + enumName(abortReasons, uint32(e))
+}
@@ -0,0 +1,783 @@
+package bacnet
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// BACnet Virtual Link Control functions, see ASHRAE 135 Annex J
+const (
+	bvlcType              = 0x81
+	bvlcForwardedNPDU     = 0x04
+	bvlcOriginalUnicast   = 0x0a
+	bvlcOriginalBroadcast = 0x0b
+)
+
+// APDU types
+const (
+	pduConfirmedRequest   = 0
+	pduUnconfirmedRequest = 1
+	pduSimpleAck          = 2
+	pduComplexAck         = 3
+	pduError              = 5
+	pduReject             = 6
+	pduAbort              = 7
+)
+
+// Service choices
+const (
+	serviceConfirmedCOVNotification = 1
+	serviceSubscribeCOV             = 5
+	serviceReadProperty             = 12
+	serviceReadPropertyMultiple     = 14
+
+	serviceIAm                        = 0
+	serviceUnconfirmedCOVNotification = 2
+	serviceWhoIs                      = 8
+)
+
+// Application tag numbers
+const (
+	tagNull            = 0
+	tagBoolean         = 1
+	tagUnsigned        = 2
+	tagSigned          = 3
+	tagReal            = 4
+	tagDouble          = 5
+	tagOctetString     = 6
+	tagCharacterString = 7
+	tagBitString       = 8
+	tagEnumerated      = 9
+	tagDate            = 10
+	tagTime            = 11
+	tagObjectID        = 12
+)
+
+var errTruncated = errors.New("truncated data")
+
+type objectID struct {
+	typ      uint16
+	instance uint32
+}
+
+func (o objectID) String() string {
+	return objectTypeName(o.typ) + ":" + strconv.FormatUint(uint64(o.instance), 10)
+}
+
+func (o objectID) encode() uint32 {
+	return uint32(o.typ)<<22 | o.instance&0x3fffff
+}
+
+func decodeObjectID(v uint32) objectID {
+	return objectID{typ: uint16(v >> 22), instance: v & 0x3fffff}
+}
+
+// route contains the information to reach a device behind a BACnet router
+type route struct {
+	network uint16
+	mac     []byte
+}
+
+// appendBVLC wraps the given NPDU into a BACnet/IP frame
+func appendBVLC(function byte, npdu []byte) []byte {
+	buf := make([]byte, 0, 4+len(npdu))
+	buf = append(buf, bvlcType, function)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(4+len(npdu)))
+	return append(buf, npdu...)
+}
+
+// appendNPDU adds the network layer header for the given destination
+func appendNPDU(buf []byte, dst *route, expectReply bool) []byte {
+	var control byte
+	if expectReply {
+		control |= 0x04
+	}
+	if dst == nil {
+		return append(buf, 0x01, control)
+	}
+
+	control |= 0x20
+	buf = append(buf, 0x01, control)
+	buf = binary.BigEndian.AppendUint16(buf, dst.network)
+	buf = append(buf, byte(len(dst.mac)))
+	buf = append(buf, dst.mac...)
+	return append(buf, 255) // hop count
+}
+
+type npdu struct {
+	networkMessage bool
+	source         *route
+}
+
+// parseNPDU decodes the network layer header and returns the APDU
+func parseNPDU(buf []byte) (*npdu, []byte, error) {
+	if len(buf) < 2 {
+		return nil, nil, errTruncated
+	}
+	if buf[0] != 0x01 {
+		return nil, nil, fmt.Errorf("unsupported NPDU version %d", buf[0])
+	}
+	control := buf[1]
+	buf = buf[2:]
+
+	var hasHopCount bool
+	if control&0x20 != 0 {
+		if len(buf) < 3 || len(buf) < 3+int(buf[2]) {
+			return nil, nil, errTruncated
+		}
+		buf = buf[3+int(buf[2]):]
+		hasHopCount = true
+	}
+
+	n := &npdu{networkMessage: control&0x80 != 0}
+	if control&0x08 != 0 {
+		if len(buf) < 3 || len(buf) < 3+int(buf[2]) {
+			return nil, nil, errTruncated
+		}
+		n.source = &route{
+			network: binary.BigEndian.Uint16(buf[0:2]),
+			mac:     append([]byte(nil), buf[3:3+int(buf[2])]...),
+		}
+		buf = buf[3+int(buf[2]):]
+	}
+	if hasHopCount {
+		if len(buf) < 1 {
+			return nil, nil, errTruncated
+		}
+		buf = buf[1:]
+	}
+	if n.networkMessage {
+		// Skip the message type and ignore the content
+		return n, nil, nil
+	}
+
+	return n, buf, nil
+}
+
+// appendTag adds a tag header with the given tag number, class and length
+func appendTag(buf []byte, number uint8, context bool, length int) []byte {
+	var class byte
+	if context {
+		class = 0x08
+	}
+
+	var header byte
+	var extNumber bool
+	if number < 15 {
+		header = number << 4
+	} else {
+		header = 0xf0
+		extNumber = true
+	}
+	header |= class
+
+	switch {
+	case length < 5:
+		buf = append(buf, header|byte(length))
+		if extNumber {
+			buf = append(buf, number)
+		}
+	case length < 254:
+		buf = append(buf, header|5)
+		if extNumber {
+			buf = append(buf, number)
+		}
+		buf = append(buf, byte(length))
+	case length < 65536:
+		buf = append(buf, header|5)
+		if extNumber {
+			buf = append(buf, number)
+		}
+		buf = append(buf, 254)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(length))
+	default:
+		buf = append(buf, header|5)
+		if extNumber {
+			buf = append(buf, number)
+		}
+		buf = append(buf, 255)
+		buf = binary.BigEndian.AppendUint32(buf, uint32(length))
+	}
+	return buf
+}
+
+func appendOpeningTag(buf []byte, number uint8) []byte {
+	return append(buf, number<<4|0x0e)
+}
+
+func appendClosingTag(buf []byte, number uint8) []byte {
+	return append(buf, number<<4|0x0f)
+}
+
+// encodeUnsigned returns the minimal big-endian representation of the value
+func encodeUnsigned(v uint32) []byte {
+	switch {
+	case v < 1<<8:
+		return []byte{byte(v)}
+	case v < 1<<16:
+		return binary.BigEndian.AppendUint16(nil, uint16(v))
+	case v < 1<<24:
+		return []byte{byte(v >> 16), byte(v >> 8), byte(v)}
+	}
+	return binary.BigEndian.AppendUint32(nil, v)
+}
+
+func appendContextUnsigned(buf []byte, number uint8, v uint32) []byte {
+	data := encodeUnsigned(v)
+	buf = appendTag(buf, number, true, len(data))
+	return append(buf, data...)
+}
+
+func appendContextObjectID(buf []byte, number uint8, id objectID) []byte {
+	buf = appendTag(buf, number, true, 4)
+	return binary.BigEndian.AppendUint32(buf, id.encode())
+}
+
+func appendContextBoolean(buf []byte, number uint8, v bool) []byte {
+	buf = appendTag(buf, number, true, 1)
+	if v {
+		return append(buf, 1)
+	}
+	return append(buf, 0)
+}
+
+type tag struct {
+	number  uint8
+	context bool
+	opening bool
+	closing bool
+	// Length of the data or the value for application-tagged booleans
+	length uint32
+	data   []byte
+}
+
+// readTag decodes the tag at the beginning of the buffer and returns the
+// remaining buffer
+func readTag(buf []byte) (*tag, []byte, error) {
+	if len(buf) < 1 {
+		return nil, nil, errTruncated
+	}
+	header := buf[0]
+	buf = buf[1:]
+
+	t := &tag{
+		number:  header >> 4,
+		context: header&0x08 != 0,
+	}
+	if t.number == 15 {
+		if len(buf) < 1 {
+			return nil, nil, errTruncated
+		}
+		t.number = buf[0]
+		buf = buf[1:]
+	}
+
+	lvt := header & 0x07
+	if t.context {
+		switch lvt {
+		case 6:
+			t.opening = true
+			return t, buf, nil
+		case 7:
+			t.closing = true
+			return t, buf, nil
+		}
+	} else if t.number == tagBoolean {
+		// The value of application booleans is encoded in the tag itself
+		t.length = uint32(lvt)
+		return t, buf, nil
+	}
+
+	t.length = uint32(lvt)
+	if lvt == 5 {
+		if len(buf) < 1 {
+			return nil, nil, errTruncated
+		}
+		switch buf[0] {
+		case 254:
+			if len(buf) < 3 {
+				return nil, nil, errTruncated
+			}
+			t.length = uint32(binary.BigEndian.Uint16(buf[1:3]))
+			buf = buf[3:]
+		case 255:
+			if len(buf) < 5 {
+				return nil, nil, errTruncated
+			}
+			t.length = binary.BigEndian.Uint32(buf[1:5])
+			buf = buf[5:]
+		default:
+			t.length = uint32(buf[0])
+			buf = buf[1:]
+		}
+	}
+	if uint64(len(buf)) < uint64(t.length) {
+		return nil, nil, errTruncated
+	}
+	t.data = buf[:t.length]
+
+	return t, buf[t.length:], nil
+}
+
+// expectContext reads a context tag with the given number
+func expectContext(buf []byte, number uint8) (*tag, []byte, error) {
+	t, rest, err := readTag(buf)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !t.context || t.number != number || t.opening || t.closing {
+		return nil, nil, fmt.Errorf("expected context tag %d", number)
+	}
+	return t, rest, nil
+}
+
+func expectOpening(buf []byte, number uint8) ([]byte, error) {
+	t, rest, err := readTag(buf)
+	if err != nil {
+		return nil, err
+	}
+	if !t.opening || t.number != number {
+		return nil, fmt.Errorf("expected opening tag %d", number)
+	}
+	return rest, nil
+}
+
+// peekTag returns the next tag without consuming it
+func peekTag(buf []byte) *tag {
+	t, _, err := readTag(buf)
+	if err != nil {
+		return nil
+	}
+	return t
+}
+
+func decodeUnsigned(data []byte) (uint64, error) {
+	if len(data) == 0 || len(data) > 8 {
+		return 0, fmt.Errorf("invalid unsigned length %d", len(data))
+	}
+	var v uint64
+	for _, b := range data {
+		v = v<<8 | uint64(b)
+	}
+	return v, nil
+}
+
+func decodeSigned(data []byte) (int64, error) {
+	if len(data) == 0 || len(data) > 8 {
+		return 0, fmt.Errorf("invalid signed length %d", len(data))
+	}
+	v := int64(int8(data[0]))
+	for _, b := range data[1:] {
+		v = v<<8 | int64(b)
+	}
+	return v, nil
+}
+
+func (t *tag) unsigned() (uint32, error) {
+	v, err := decodeUnsigned(t.data)
+	if err != nil {
+		return 0, err
+	}
+	if v > math.MaxUint32 {
+		return 0, fmt.Errorf("value %d out of range", v)
+	}
+	return uint32(v), nil
+}
+
+// readValues decodes application-tagged values up to the closing tag with
+// the given number and returns the remaining buffer after the closing tag
+func readValues(buf []byte, closing uint8) ([]interface{}, []byte, error) {
+	var values []interface{}
+	for {
+		t, rest, err := readTag(buf)
+		if err != nil {
+			return nil, nil, err
+		}
+		buf = rest
+		if t.closing && t.number == closing {
+			return values, buf, nil
+		}
+		if t.context {
+			// Skip constructed values as we cannot interpret those
+			if t.opening {
+				if buf, err = skipConstructed(buf, t.number); err != nil {
+					return nil, nil, err
+				}
+			}
+			continue
+		}
+		v, err := decodeApplicationValue(t)
+		if err != nil {
+			return nil, nil, err
+		}
+		values = append(values, v)
+	}
+}
+
+func skipConstructed(buf []byte, number uint8) ([]byte, error) {
+	depth := 1
+	for depth > 0 {
+		t, rest, err := readTag(buf)
+		if err != nil {
+			return nil, err
+		}
+		buf = rest
+		switch {
+		case t.opening:
+			depth++
+		case t.closing:
+			depth--
+			if depth == 0 && t.number != number {
+				return nil, fmt.Errorf("unexpected closing tag %d", t.number)
+			}
+		}
+	}
+	return buf, nil
+}
+
+// decodeApplicationValue converts an application-tagged value into a Go type
+func decodeApplicationValue(t *tag) (interface{}, error) {
+	switch t.number {
+	case tagNull:
+		return nil, nil
+	case tagBoolean:
+		return t.length != 0, nil
+	case tagUnsigned:
+		return decodeUnsigned(t.data)
+	case tagSigned:
+		return decodeSigned(t.data)
+	case tagReal:
+		if len(t.data) != 4 {
+			return nil, fmt.Errorf("invalid real length %d", len(t.data))
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(t.data)), nil
+	case tagDouble:
+		if len(t.data) != 8 {
+			return nil, fmt.Errorf("invalid double length %d", len(t.data))
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(t.data)), nil
+	case tagOctetString:
+		return hex.EncodeToString(t.data), nil
+	case tagCharacterString:
+		if len(t.data) < 1 {
+			return nil, errTruncated
+		}
+		// We only handle UTF-8 (ANSI X3.4) encoded strings
+		if t.data[0] != 0 {
+			return nil, fmt.Errorf("unsupported character set %d", t.data[0])
+		}
+		return string(t.data[1:]), nil
+	case tagBitString:
+		if len(t.data) < 1 {
+			return nil, errTruncated
+		}
+		unused := int(t.data[0])
+		var bits strings.Builder
+		for i, b := range t.data[1:] {
+			n := 8
+			if i == len(t.data)-2 {
+				n -= unused
+			}
+			for j := 0; j < n; j++ {
+				if b&(0x80>>j) != 0 {
+					bits.WriteByte('1')
+				} else {
+					bits.WriteByte('0')
+				}
+			}
+		}
+		return bits.String(), nil
+	case tagEnumerated:
+		return decodeUnsigned(t.data)
+	case tagDate:
+		if len(t.data) != 4 {
+			return nil, fmt.Errorf("invalid date length %d", len(t.data))
+		}
+		return fmt.Sprintf("%04d-%02d-%02d", 1900+int(t.data[0]), t.data[1], t.data[2]), nil
+	case tagTime:
+		if len(t.data) != 4 {
+			return nil, fmt.Errorf("invalid time length %d", len(t.data))
+		}
+		return fmt.Sprintf("%02d:%02d:%02d.%02d", t.data[0], t.data[1], t.data[2], t.data[3]), nil
+	case tagObjectID:
+		if len(t.data) != 4 {
+			return nil, fmt.Errorf("invalid object identifier length %d", len(t.data))
+		}
+		return decodeObjectID(binary.BigEndian.Uint32(t.data)).String(), nil
+	}
+	return nil, fmt.Errorf("unknown application tag %d", t.number)
+}
+
+// encodeReadProperty creates the service data of a ReadProperty request
+func encodeReadProperty(obj objectID, property uint32) []byte {
+	buf := appendContextObjectID(nil, 0, obj)
+	return appendContextUnsigned(buf, 1, property)
+}
+
+// decodeReadPropertyAck decodes the values of a ReadProperty response
+func decodeReadPropertyAck(buf []byte) ([]interface{}, error) {
+	_, buf, err := expectContext(buf, 0)
+	if err != nil {
+		return nil, err
+	}
+	if _, buf, err = expectContext(buf, 1); err != nil {
+		return nil, err
+	}
+	// Skip the optional array index
+	if t := peekTag(buf); t != nil && t.context && t.number == 2 && !t.opening {
+		_, buf, _ = readTag(buf)
+	}
+	if buf, err = expectOpening(buf, 3); err != nil {
+		return nil, err
+	}
+	values, _, err := readValues(buf, 3)
+	return values, err
+}
+
+type propertyRef struct {
+	object   objectID
+	property uint32
+}
+
+// encodeReadPropertyMultiple creates the service data of a
+// ReadPropertyMultiple request for the given properties
+func encodeReadPropertyMultiple(refs []propertyRef) []byte {
+	var buf []byte
+	for i, ref := range refs {
+		if i == 0 || refs[i-1].object != ref.object {
+			if i > 0 {
+				buf = appendClosingTag(buf, 1)
+			}
+			buf = appendContextObjectID(buf, 0, ref.object)
+			buf = appendOpeningTag(buf, 1)
+		}
+		buf = appendContextUnsigned(buf, 0, ref.property)
+	}
+	if len(refs) > 0 {
+		buf = appendClosingTag(buf, 1)
+	}
+	return buf
+}
+
+type propertyResult struct {
+	values []interface{}
+	err    error
+}
+
+// decodeReadPropertyMultipleAck decodes the results of a ReadPropertyMultiple
+// response
+func decodeReadPropertyMultipleAck(buf []byte) (map[propertyRef]*propertyResult, error) {
+	results := make(map[propertyRef]*propertyResult)
+	for len(buf) > 0 {
+		t, rest, err := expectContext(buf, 0)
+		if err != nil {
+			return nil, err
+		}
+		if len(t.data) != 4 {
+			return nil, errors.New("invalid object identifier")
+		}
+		obj := decodeObjectID(binary.BigEndian.Uint32(t.data))
+		if buf, err = expectOpening(rest, 1); err != nil {
+			return nil, err
+		}
+
+		for {
+			t, rest, err := readTag(buf)
+			if err != nil {
+				return nil, err
+			}
+			if t.closing && t.number == 1 {
+				buf = rest
+				break
+			}
+			if !t.context || t.number != 2 {
+				return nil, errors.New("expected property identifier")
+			}
+			property, err := t.unsigned()
+			if err != nil {
+				return nil, err
+			}
+			buf = rest
+
+			// Skip the optional array index
+			if t := peekTag(buf); t != nil && t.context && t.number == 3 && !t.opening {
+				_, buf, _ = readTag(buf)
+			}
+
+			t, rest, err = readTag(buf)
+			if err != nil {
+				return nil, err
+			}
+			buf = rest
+			result := &propertyResult{}
+			switch {
+			case t.opening && t.number == 4:
+				result.values, buf, err = readValues(buf, 4)
+				if err != nil {
+					return nil, err
+				}
+			case t.opening && t.number == 5:
+				result.err, buf, err = decodeErrorSequence(buf)
+				if err != nil {
+					return nil, err
+				}
+				if buf, err = expectClosing(buf, 5); err != nil {
+					return nil, err
+				}
+			default:
+				return nil, errors.New("expected property value or error")
+			}
+			results[propertyRef{object: obj, property: property}] = result
+		}
+	}
+	return results, nil
+}
+
+func expectClosing(buf []byte, number uint8) ([]byte, error) {
+	t, rest, err := readTag(buf)
+	if err != nil {
+		return nil, err
+	}
+	if !t.closing || t.number != number {
+		return nil, fmt.Errorf("expected closing tag %d", number)
+	}
+	return rest, nil
+}
+
+// decodeErrorSequence decodes the error class and code as application
+// tagged enumerations
+func decodeErrorSequence(buf []byte) (*serviceError, []byte, error) {
+	var values [2]uint32
+	for i := range values {
+		t, rest, err := readTag(buf)
+		if err != nil {
+			return nil, nil, err
+		}
+		if t.context || t.number != tagEnumerated {
+			return nil, nil, errors.New("expected enumerated error value")
+		}
+		if values[i], err = t.unsigned(); err != nil {
+			return nil, nil, err
+		}
+		buf = rest
+	}
+	return &serviceError{class: values[0], code: values[1]}, buf, nil
+}
+
+// encodeSubscribeCOV creates the service data of a SubscribeCOV request,
+// a zero lifetime cancels the subscription
+func encodeSubscribeCOV(processID uint32, obj objectID, lifetime uint32) []byte {
+	buf := appendContextUnsigned(nil, 0, processID)
+	buf = appendContextObjectID(buf, 1, obj)
+	if lifetime == 0 {
+		return buf
+	}
+	buf = appendContextBoolean(buf, 2, false)
+	return appendContextUnsigned(buf, 3, lifetime)
+}
+
+type covNotification struct {
+	processID uint32
+	device    uint32
+	object    objectID
+	values    map[uint32][]interface{}
+}
+
+// decodeCOVNotification decodes the service data of confirmed and
+// unconfirmed COV notifications
+func decodeCOVNotification(buf []byte) (*covNotification, error) {
+	n := &covNotification{values: make(map[uint32][]interface{})}
+
+	t, buf, err := expectContext(buf, 0)
+	if err != nil {
+		return nil, err
+	}
+	if n.processID, err = t.unsigned(); err != nil {
+		return nil, err
+	}
+	if t, buf, err = expectContext(buf, 1); err != nil {
+		return nil, err
+	}
+	if len(t.data) != 4 {
+		return nil, errors.New("invalid device identifier")
+	}
+	n.device = decodeObjectID(binary.BigEndian.Uint32(t.data)).instance
+	if t, buf, err = expectContext(buf, 2); err != nil {
+		return nil, err
+	}
+	if len(t.data) != 4 {
+		return nil, errors.New("invalid object identifier")
+	}
+	n.object = decodeObjectID(binary.BigEndian.Uint32(t.data))
+	if _, buf, err = expectContext(buf, 3); err != nil {
+		return nil, err
+	}
+	if buf, err = expectOpening(buf, 4); err != nil {
+		return nil, err
+	}
+
+	for {
+		t, rest, err := readTag(buf)
+		if err != nil {
+			return nil, err
+		}
+		if t.closing && t.number == 4 {
+			return n, nil
+		}
+		if !t.context || t.number != 0 {
+			return nil, errors.New("expected property identifier")
+		}
+		property, err := t.unsigned()
+		if err != nil {
+			return nil, err
+		}
+		buf = rest
+
+		// Skip the optional array index
+		if t := peekTag(buf); t != nil && t.context && t.number == 1 && !t.opening {
+			_, buf, _ = readTag(buf)
+		}
+		if buf, err = expectOpening(buf, 2); err != nil {
+			return nil, err
+		}
+		values, rest, err := readValues(buf, 2)
+		if err != nil {
+			return nil, err
+		}
+		n.values[property] = values
+		buf = rest
+
+		// Skip the optional priority
+		if t := peekTag(buf); t != nil && t.context && t.number == 3 && !t.opening {
+			_, buf, _ = readTag(buf)
+		}
+	}
+}
+
+// decodeIAm returns the device instance announced in an I-Am request
+func decodeIAm(buf []byte) (uint32, error) {
+	t, _, err := readTag(buf)
+	if err != nil {
+		return 0, err
+	}
+	if t.context || t.number != tagObjectID || len(t.data) != 4 {
+		return 0, errors.New("expected device identifier")
+	}
+	obj := decodeObjectID(binary.BigEndian.Uint32(t.data))
+	if obj.typ != objectTypeDevice {
+		return 0, fmt.Errorf("unexpected object type %d", obj.typ)
+	}
+	return obj.instance, nil
+}
+
+// encodeWhoIs creates the service data of a Who-Is request for the given
+// device instance range
+func encodeWhoIs(low, high uint32) []byte {
+	buf := appendContextUnsigned(nil, 0, low)
+	return appendContextUnsigned(buf, 1, high)
+}
@@ -0,0 +1,433 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package bmp
+
+import (
+	"bufio"
+	_ "embed"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/inputs"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+type BMP struct {
+	ServiceAddress string          `toml:"service_address"`
+	MaxConnections int             `toml:"max_connections"`
+	MaxMessageSize config.Size     `toml:"max_message_size"`
+	PrefixEvents   bool            `toml:"prefix_events"`
+	Log            telegraf.Logger `toml:"-"`
+
+	acc      telegraf.Accumulator
+	listener net.Listener
+	sessions map[net.Conn]*session
+	mu       sync.Mutex
+	wg       sync.WaitGroup
+}
+
+// session holds the state of a single router connection
+type session struct {
+	source string
+	router string
+	peers  map[string]*peer
+	closed bool
+}
+
+// peer holds the state of a monitored BGP peer of a router
+type peer struct {
+	tags map[string]string
+	up   bool
+	ribs map[string]*ribCounters
+}
+
+type ribCounters struct {
+	updates   uint64
+	announced uint64
+	withdrawn uint64
+}
+
+func (*BMP) SampleConfig() string {
+	return sampleConfig
+}
+
+func (b *BMP) Init() error {
+	if b.ServiceAddress == "" {
+		b.ServiceAddress = "tcp://:11019"
+	}
+	u, err := url.Parse(b.ServiceAddress)
+	if err != nil {
+		return fmt.Errorf("invalid service address %q: %w", b.ServiceAddress, err)
+	}
+	switch u.Scheme {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return fmt.Errorf("invalid scheme %q, should be 'tcp', 'tcp4' or 'tcp6'", u.Scheme)
+	}
+
+	if b.MaxConnections < 0 {
+		return errors.New("'max_connections' cannot be negative")
+	}
+	if b.MaxMessageSize == 0 {
+		b.MaxMessageSize = config.Size(1024 * 1024)
+	}
+
+	b.sessions = make(map[net.Conn]*session)
+
+	return nil
+}
+
+func (b *BMP) Start(acc telegraf.Accumulator) error {
+	b.acc = acc
+
+	u, err := url.Parse(b.ServiceAddress)
+	if err != nil {
+		return err
+	}
+	listener, err := net.Listen(u.Scheme, u.Host)
+	if err != nil {
+		return err
+	}
+	b.listener = listener
+	b.Log.Infof("Listening on %s://%s", listener.Addr().Network(), listener.Addr().String())
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		b.accept()
+	}()
+
+	return nil
+}
+
+func (b *BMP) Gather(acc telegraf.Accumulator) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for conn, s := range b.sessions {
+		for key, p := range s.peers {
+			for rib, c := range p.ribs {
+				tags := s.tags(p.tags)
+				tags["rib"] = rib
+				fields := map[string]interface{}{
+					"updates":            c.updates,
+					"announced_prefixes": c.announced,
+					"withdrawn_prefixes": c.withdrawn,
+				}
+				acc.AddCounter("bmp_updates", fields, tags)
+			}
+
+			// Remove peers gone down after reporting their final state
+			if !p.up || s.closed {
+				delete(s.peers, key)
+			}
+		}
+		if s.closed {
+			delete(b.sessions, conn)
+		}
+	}
+
+	return nil
+}
+
+func (b *BMP) Stop() {
+	if b.listener != nil {
+		b.listener.Close()
+	}
+
+	b.mu.Lock()
+	for conn := range b.sessions {
+		conn.Close()
+	}
+	b.mu.Unlock()
+
+	b.wg.Wait()
+}
+
+func (b *BMP) accept() {
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				b.acc.AddError(fmt.Errorf("accepting connection failed: %w", err))
+			}
+			return
+		}
+
+		source, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+		if err != nil {
+			source = conn.RemoteAddr().String()
+		}
+
+		b.mu.Lock()
+		if b.MaxConnections > 0 && b.activeSessions() >= b.MaxConnections {
+			b.mu.Unlock()
+			b.Log.Errorf("Rejecting connection from %s: maximum number of connections reached", source)
+			conn.Close()
+			continue
+		}
+		s := &session{source: source, peers: make(map[string]*peer)}
+		b.sessions[conn] = s
+		b.mu.Unlock()
+
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+
+			b.Log.Debugf("Accepted BMP connection from %s", source)
+			if err := b.handleConnection(conn, s); err != nil {
+				b.acc.AddError(fmt.Errorf("connection from %s: %w", source, err))
+			}
+			b.Log.Debugf("Closed BMP connection from %s", source)
+
+			b.mu.Lock()
+			s.closed = true
+			b.mu.Unlock()
+			conn.Close()
+		}()
+	}
+}
+
+func (b *BMP) activeSessions() int {
+	var n int
+	for _, s := range b.sessions {
+		if !s.closed {
+			n++
+		}
+	}
+	return n
+}
+
+func (b *BMP) handleConnection(conn net.Conn, s *session) error {
+	reader := bufio.NewReader(conn)
+	header := make([]byte, commonHeaderLen)
+	for {
+		// Read and validate the common header, see RFC 7854 section 4.1
+		if _, err := io.ReadFull(reader, header); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		if header[0] != 3 {
+			return fmt.Errorf("unsupported BMP version %d", header[0])
+		}
+		length := binary.BigEndian.Uint32(header[1:5])
+		if length < commonHeaderLen {
+			return fmt.Errorf("invalid message length %d", length)
+		}
+		if length > uint32(b.MaxMessageSize) {
+			return fmt.Errorf("message size %d exceeds limit of %d", length, b.MaxMessageSize)
+		}
+
+		body := make([]byte, length-commonHeaderLen)
+		if _, err := io.ReadFull(reader, body); err != nil {
+			return fmt.Errorf("reading message failed: %w", err)
+		}
+
+		terminate, err := b.handleMessage(s, header[5], body)
+		if err != nil {
+			b.acc.AddError(fmt.Errorf("decoding message type %d from %s failed: %w", header[5], s.source, err))
+		}
+		if terminate {
+			return nil
+		}
+	}
+}
+
+func (b *BMP) handleMessage(s *session, typ uint8, body []byte) (bool, error) {
+	switch typ {
+	case msgInitiation:
+		info, err := parseInformation(body)
+		if err != nil {
+			return false, err
+		}
+		b.mu.Lock()
+		s.router = info[2]
+		b.mu.Unlock()
+		b.Log.Debugf("Router %s initiated session: name %q, description %q", s.source, info[2], info[1])
+		return false, nil
+	case msgTermination:
+		info, err := parseInformation(body)
+		if err != nil {
+			return true, err
+		}
+		b.Log.Debugf("Router %s terminated session: %q", s.source, info[0])
+		return true, nil
+	case msgRouteMirroring:
+		return false, nil
+	case msgRouteMonitoring, msgStatisticsReport, msgPeerDown, msgPeerUp:
+	default:
+		b.Log.Tracef("Ignoring unknown message type %d from %s", typ, s.source)
+		return false, nil
+	}
+
+	header, err := parsePeerHeader(body)
+	if err != nil {
+		return false, err
+	}
+	body = body[peerHeaderLen:]
+
+	timestamp := header.timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	key := strconv.FormatUint(header.distinguisher, 10) + "|" + header.address.String() + "|" + peerTypeName(header.peerType)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	p, found := s.peers[key]
+	if !found {
+		p = &peer{tags: header.tags(), up: true, ribs: make(map[string]*ribCounters)}
+		s.peers[key] = p
+	}
+
+	switch typ {
+	case msgPeerUp:
+		msg, err := parsePeerUp(body, header.isIPv6())
+		if err != nil {
+			return false, err
+		}
+		p.up = true
+		p.ribs = make(map[string]*ribCounters)
+
+		fields := map[string]interface{}{
+			"state":         "up",
+			"local_address": msg.localAddress.String(),
+			"local_port":    msg.localPort,
+			"remote_port":   msg.remotePort,
+			"hold_time":     msg.holdTime,
+		}
+		b.acc.AddMetric(metric.New("bmp_peer", s.tags(p.tags), fields, timestamp))
+	case msgPeerDown:
+		if len(body) < 1 {
+			return false, errors.New("peer-down notification too short")
+		}
+		p.up = false
+
+		fields := map[string]interface{}{
+			"state":  "down",
+			"reason": body[0],
+		}
+		b.acc.AddMetric(metric.New("bmp_peer", s.tags(p.tags), fields, timestamp))
+	case msgStatisticsReport:
+		stats, err := parseStatistics(body)
+		if err != nil {
+			return false, err
+		}
+		fields := make(map[string]interface{})
+		families := make(map[[2]uint16]map[string]interface{})
+		for _, stat := range stats {
+			if stat.afi == 0 {
+				fields[stat.name] = stat.value
+				continue
+			}
+			family := [2]uint16{stat.afi, uint16(stat.safi)}
+			if _, ok := families[family]; !ok {
+				families[family] = make(map[string]interface{})
+			}
+			families[family][stat.name] = stat.value
+		}
+		if len(fields) > 0 {
+			b.acc.AddMetric(metric.New("bmp_stats", s.tags(p.tags), fields, timestamp))
+		}
+		for family, fields := range families {
+			tags := s.tags(p.tags)
+			tags["afi"] = afiName(family[0])
+			tags["safi"] = safiName(uint8(family[1]))
+			b.acc.AddMetric(metric.New("bmp_stats", tags, fields, timestamp))
+		}
+	case msgRouteMonitoring:
+		msg, err := parseUpdate(body, header.as4())
+		if err != nil {
+			return false, err
+		}
+		rib := header.rib()
+		c, ok := p.ribs[rib]
+		if !ok {
+			c = &ribCounters{}
+			p.ribs[rib] = c
+		}
+		c.updates++
+		for _, r := range msg.routes {
+			if r.withdraw {
+				c.withdrawn++
+			} else {
+				c.announced++
+			}
+		}
+		if b.PrefixEvents {
+			b.addRouteEvents(s, p, rib, msg, timestamp)
+		}
+	}
+
+	return false, nil
+}
+
+func (b *BMP) addRouteEvents(s *session, p *peer, rib string, msg *update, timestamp time.Time) {
+	for _, r := range msg.routes {
+		tags := s.tags(p.tags)
+		tags["rib"] = rib
+		tags["afi"] = afiName(r.afi)
+		tags["safi"] = safiName(r.safi)
+		tags["prefix"] = r.prefix
+
+		if r.withdraw {
+			fields := map[string]interface{}{"action": "withdraw"}
+			b.acc.AddMetric(metric.New("bmp_route", tags, fields, timestamp))
+			continue
+		}
+
+		fields := map[string]interface{}{"action": "announce"}
+		if msg.nextHop != "" {
+			fields["next_hop"] = msg.nextHop
+		}
+		if msg.asPath != "" {
+			fields["as_path"] = msg.asPath
+		}
+		if msg.origin != "" {
+			fields["origin"] = msg.origin
+		}
+		if msg.localPref != nil {
+			fields["local_pref"] = *msg.localPref
+		}
+		if msg.med != nil {
+			fields["med"] = *msg.med
+		}
+		if msg.communities != "" {
+			fields["communities"] = msg.communities
+		}
+		b.acc.AddMetric(metric.New("bmp_route", tags, fields, timestamp))
+	}
+}
+
+// tags returns a copy of the given peer tags amended by the session tags
+func (s *session) tags(peerTags map[string]string) map[string]string {
+	tags := make(map[string]string, len(peerTags)+2)
+	for k, v := range peerTags {
+		tags[k] = v
+	}
+	tags["source"] = s.source
+	if s.router != "" {
+		tags["router"] = s.router
+	}
+	return tags
+}
+
+func init() {
+	inputs.Add("bmp", func() telegraf.Input {
+		return &BMP{}
+	})
+}
@@ -0,0 +1,165 @@
+package bmp
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/plugins/inputs"
+	"github.com/influxdata/telegraf/plugins/parsers/influx"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	plugin := &BMP{ServiceAddress: "udp://:11019", Log: &testutil.Logger{}}
+	require.ErrorContains(t, plugin.Init(), `invalid scheme "udp"`)
+
+	plugin = &BMP{MaxConnections: -1, Log: &testutil.Logger{}}
+	require.ErrorContains(t, plugin.Init(), "'max_connections' cannot be negative")
+}
+
+func TestCases(t *testing.T) {
+	// Get all directories in testdata
+	folders, err := os.ReadDir("testcases")
+	require.NoError(t, err)
+
+	// Register the plugin
+	inputs.Add("bmp", func() telegraf.Input {
+		return &BMP{}
+	})
+
+	// Prepare the influx parser for expectations
+	parser := &influx.Parser{}
+	require.NoError(t, parser.Init())
+
+	for _, f := range folders {
+		// Only handle folders
+		if !f.IsDir() {
+			continue
+		}
+		testcasePath := filepath.Join("testcases", f.Name())
+		configFilename := filepath.Join(testcasePath, "telegraf.conf")
+		inputFiles := filepath.Join(testcasePath, "*.bin")
+		expectedFilename := filepath.Join(testcasePath, "expected.out")
+
+		// Compare options, the update counters are timestamped at gathering
+		options := []cmp.Option{
+			testutil.SortMetrics(),
+			testutil.IgnoreTime(),
+			testutil.IgnoreType(),
+		}
+
+		t.Run(f.Name(), func(t *testing.T) {
+			// Read the recorded sessions
+			matches, err := filepath.Glob(inputFiles)
+			require.NoError(t, err)
+			require.NotEmpty(t, matches)
+			sort.Strings(matches)
+
+			// Read the expected output
+			expected, err := testutil.ParseMetricsFromFile(expectedFilename, parser)
+			require.NoError(t, err)
+
+			// Configure the plugin
+			cfg := config.NewConfig()
+			require.NoError(t, cfg.LoadConfig(configFilename))
+			require.Len(t, cfg.Inputs, 1)
+
+			// Setup and start the plugin
+			var acc testutil.Accumulator
+			plugin := cfg.Inputs[0].Input.(*BMP)
+			plugin.Log = &testutil.Logger{}
+			require.NoError(t, plugin.Init())
+			require.NoError(t, plugin.Start(&acc))
+			defer plugin.Stop()
+
+			// Replay the recorded sessions one after the other
+			for _, fn := range matches {
+				buf, err := os.ReadFile(fn)
+				require.NoError(t, err)
+
+				client, err := net.Dial("tcp", plugin.listener.Addr().String())
+				require.NoError(t, err)
+				_, err = client.Write(buf)
+				require.NoErrorf(t, err, "writing session from %q failed", fn)
+				require.NoError(t, client.Close())
+			}
+
+			// Wait for all sessions to be processed
+			require.Eventually(t, func() bool {
+				plugin.mu.Lock()
+				defer plugin.mu.Unlock()
+				for _, s := range plugin.sessions {
+					if !s.closed {
+						return false
+					}
+				}
+				return len(plugin.sessions) == len(matches)
+			}, 3*time.Second, 100*time.Millisecond)
+
+			// Collect the update counters
+			require.NoError(t, plugin.Gather(&acc))
+			require.Empty(t, acc.Errors)
+
+			testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), options...)
+		})
+	}
+}
+
+func TestPeerStateCleanup(t *testing.T) {
+	plugin := &BMP{
+		ServiceAddress: "tcp://127.0.0.1:0",
+		PrefixEvents:   true,
+		Log:            &testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	buf, err := os.ReadFile(filepath.Join("testcases", "prefix_events_ipv6", "session.bin"))
+	require.NoError(t, err)
+
+	// Keep the session open to check the counters are kept while the peer is up
+	client, err := net.Dial("tcp", plugin.listener.Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+	_, err = client.Write(buf)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return acc.NMetrics() >= 3
+	}, 3*time.Second, 100*time.Millisecond)
+
+	require.NoError(t, plugin.Gather(&acc))
+	require.NoError(t, plugin.Gather(&acc))
+	require.Len(t, acc.GetTelegrafMetrics(), 5)
+
+	// Signal the peer going down and check the counters are reported a last time
+	down := make([]byte, 0, commonHeaderLen+peerHeaderLen+1)
+	down = append(down, 3)
+	down = binary.BigEndian.AppendUint32(down, commonHeaderLen+peerHeaderLen+1)
+	down = append(down, msgPeerDown)
+	down = append(down, buf[commonHeaderLen:commonHeaderLen+peerHeaderLen]...)
+	down = append(down, 2)
+	_, err = client.Write(down)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return acc.NMetrics() >= 6
+	}, 3*time.Second, 100*time.Millisecond)
+
+	require.NoError(t, plugin.Gather(&acc))
+	require.NoError(t, plugin.Gather(&acc))
+	require.Len(t, acc.GetTelegrafMetrics(), 7)
+}
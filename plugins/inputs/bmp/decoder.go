@@ -0,0 +1,583 @@
+package bmp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BMP message types, see RFC 7854 section 4.1
+const (
+	msgRouteMonitoring  = 0
+	msgStatisticsReport = 1
+	msgPeerDown         = 2
+	msgPeerUp           = 3
+	msgInitiation       = 4
+	msgTermination      = 5
+	msgRouteMirroring   = 6
+)
+
+// Peer types, see RFC 7854 section 4.2 and RFC 9069 section 4.1
+const (
+	peerTypeGlobal = 0
+	peerTypeRD     = 1
+	peerTypeLocal  = 2
+	peerTypeLocRIB = 3
+)
+
+// Per-peer header flags, see RFC 7854 section 4.2
+const (
+	peerFlagIPv6       = 0x80
+	peerFlagPostPolicy = 0x40
+	peerFlagLegacyAS   = 0x20
+)
+
+const (
+	commonHeaderLen = 6
+	peerHeaderLen   = 42
+	bgpHeaderLen    = 19
+)
+
+var statisticsNames = map[uint16]string{
+	0:  "rejected_prefixes",
+	1:  "duplicate_prefix_advertisements",
+	2:  "duplicate_withdraws",
+	3:  "invalid_cluster_list_loop",
+	4:  "invalid_as_path_loop",
+	5:  "invalid_originator_id",
+	6:  "invalid_as_confed_loop",
+	7:  "adj_rib_in_routes",
+	8:  "loc_rib_routes",
+	9:  "adj_rib_in_routes",
+	10: "loc_rib_routes",
+	11: "updates_treated_as_withdraw",
+	12: "prefixes_treated_as_withdraw",
+	13: "duplicate_update_messages",
+	14: "adj_rib_out_pre_policy_routes",
+	15: "adj_rib_out_post_policy_routes",
+	16: "adj_rib_out_pre_policy_routes",
+	17: "adj_rib_out_post_policy_routes",
+}
+
+type peerHeader struct {
+	peerType      uint8
+	flags         uint8
+	distinguisher uint64
+	address       net.IP
+	as            uint32
+	bgpID         net.IP
+	timestamp     time.Time
+}
+
+type peerUp struct {
+	localAddress net.IP
+	localPort    uint16
+	remotePort   uint16
+	holdTime     uint16
+}
+
+type statistic struct {
+	name  string
+	afi   uint16
+	safi  uint8
+	value uint64
+}
+
+type route struct {
+	afi      uint16
+	safi     uint8
+	prefix   string
+	withdraw bool
+}
+
+type update struct {
+	routes      []route
+	nextHop     string
+	asPath      string
+	origin      string
+	localPref   *uint32
+	med         *uint32
+	communities string
+}
+
+func parsePeerHeader(buf []byte) (*peerHeader, error) {
+	if len(buf) < peerHeaderLen {
+		return nil, fmt.Errorf("per-peer header too short (%d bytes)", len(buf))
+	}
+
+	h := &peerHeader{
+		peerType:      buf[0],
+		flags:         buf[1],
+		distinguisher: binary.BigEndian.Uint64(buf[2:10]),
+		as:            binary.BigEndian.Uint32(buf[26:30]),
+		bgpID:         net.IP(append([]byte(nil), buf[30:34]...)),
+	}
+	if h.isIPv6() {
+		h.address = net.IP(append([]byte(nil), buf[10:26]...))
+	} else {
+		h.address = net.IP(append([]byte(nil), buf[22:26]...))
+	}
+
+	sec := binary.BigEndian.Uint32(buf[34:38])
+	usec := binary.BigEndian.Uint32(buf[38:42])
+	if sec != 0 || usec != 0 {
+		h.timestamp = time.Unix(int64(sec), int64(usec)*int64(time.Microsecond))
+	}
+
+	return h, nil
+}
+
+func (h *peerHeader) isIPv6() bool {
+	return h.peerType != peerTypeLocRIB && h.flags&peerFlagIPv6 != 0
+}
+
+func (h *peerHeader) as4() bool {
+	return h.peerType == peerTypeLocRIB || h.flags&peerFlagLegacyAS == 0
+}
+
+func (h *peerHeader) rib() string {
+	switch {
+	case h.peerType == peerTypeLocRIB:
+		return "loc-rib"
+	case h.flags&peerFlagPostPolicy != 0:
+		return "adj-rib-in-post"
+	}
+	return "adj-rib-in-pre"
+}
+
+func (h *peerHeader) tags() map[string]string {
+	tags := map[string]string{
+		"peer_type":   peerTypeName(h.peerType),
+		"peer_as":     strconv.FormatUint(uint64(h.as), 10),
+		"peer_bgp_id": h.bgpID.String(),
+	}
+	if h.peerType != peerTypeLocRIB {
+		tags["peer_address"] = h.address.String()
+	}
+	if h.distinguisher != 0 {
+		tags["peer_distinguisher"] = formatDistinguisher(h.distinguisher)
+	}
+	return tags
+}
+
+func parsePeerUp(buf []byte, ipv6 bool) (*peerUp, error) {
+	if len(buf) < 20 {
+		return nil, fmt.Errorf("peer-up notification too short (%d bytes)", len(buf))
+	}
+
+	// IPv4 local addresses are stored in the last four bytes of the field
+	local := net.IP(append([]byte(nil), buf[12:16]...))
+	if ipv6 {
+		local = net.IP(append([]byte(nil), buf[0:16]...))
+	}
+	msg := &peerUp{
+		localAddress: local,
+		localPort:    binary.BigEndian.Uint16(buf[16:18]),
+		remotePort:   binary.BigEndian.Uint16(buf[18:20]),
+	}
+
+	// Skip the sent OPEN message and extract the hold-time from the received one
+	sent, err := bgpMessageLength(buf[20:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid sent OPEN message: %w", err)
+	}
+	received := buf[20+sent:]
+	if _, err := bgpMessageLength(received); err != nil {
+		return nil, fmt.Errorf("invalid received OPEN message: %w", err)
+	}
+	if len(received) < bgpHeaderLen+5 {
+		return nil, errors.New("received OPEN message too short")
+	}
+	msg.holdTime = binary.BigEndian.Uint16(received[bgpHeaderLen+3 : bgpHeaderLen+5])
+
+	return msg, nil
+}
+
+func parseStatistics(buf []byte) ([]statistic, error) {
+	if len(buf) < 4 {
+		return nil, fmt.Errorf("statistics report too short (%d bytes)", len(buf))
+	}
+	count := binary.BigEndian.Uint32(buf[0:4])
+	buf = buf[4:]
+
+	stats := make([]statistic, 0, min(int(count), len(buf)/4))
+	for i := uint32(0); i < count; i++ {
+		if len(buf) < 4 {
+			return nil, fmt.Errorf("statistic #%d: truncated header", i)
+		}
+		typ := binary.BigEndian.Uint16(buf[0:2])
+		length := int(binary.BigEndian.Uint16(buf[2:4]))
+		if len(buf) < 4+length {
+			return nil, fmt.Errorf("statistic #%d: truncated value", i)
+		}
+		value := buf[4 : 4+length]
+		buf = buf[4+length:]
+
+		name, found := statisticsNames[typ]
+		if !found {
+			continue
+		}
+
+		switch typ {
+		case 9, 10, 16, 17:
+			// AFI/SAFI specific gauges
+			if length != 11 {
+				return nil, fmt.Errorf("statistic type %d: invalid length %d", typ, length)
+			}
+			stats = append(stats, statistic{
+				name:  name,
+				afi:   binary.BigEndian.Uint16(value[0:2]),
+				safi:  value[2],
+				value: binary.BigEndian.Uint64(value[3:11]),
+			})
+		default:
+			// Counters are 32-bit, gauges are 64-bit wide
+			switch length {
+			case 4:
+				stats = append(stats, statistic{name: name, value: uint64(binary.BigEndian.Uint32(value))})
+			case 8:
+				stats = append(stats, statistic{name: name, value: binary.BigEndian.Uint64(value)})
+			default:
+				return nil, fmt.Errorf("statistic type %d: invalid length %d", typ, length)
+			}
+		}
+	}
+
+	return stats, nil
+}
+
+// parseInformation decodes the information TLVs of initiation and termination
+// messages returning the string values by type
+func parseInformation(buf []byte) (map[uint16]string, error) {
+	info := make(map[uint16]string)
+	for len(buf) > 0 {
+		if len(buf) < 4 {
+			return nil, errors.New("truncated information TLV")
+		}
+		typ := binary.BigEndian.Uint16(buf[0:2])
+		length := int(binary.BigEndian.Uint16(buf[2:4]))
+		if len(buf) < 4+length {
+			return nil, fmt.Errorf("information TLV type %d: truncated value", typ)
+		}
+		info[typ] = string(buf[4 : 4+length])
+		buf = buf[4+length:]
+	}
+	return info, nil
+}
+
+func bgpMessageLength(buf []byte) (int, error) {
+	if len(buf) < bgpHeaderLen {
+		return 0, fmt.Errorf("message too short (%d bytes)", len(buf))
+	}
+	length := int(binary.BigEndian.Uint16(buf[16:18]))
+	if length < bgpHeaderLen || length > len(buf) {
+		return 0, fmt.Errorf("invalid message length %d", length)
+	}
+	return length, nil
+}
+
+// parseUpdate decodes a BGP UPDATE message, see RFC 4271 section 4.3
+func parseUpdate(buf []byte, as4 bool) (*update, error) {
+	length, err := bgpMessageLength(buf)
+	if err != nil {
+		return nil, err
+	}
+	if buf[18] != 2 {
+		return nil, fmt.Errorf("unexpected BGP message type %d", buf[18])
+	}
+	buf = buf[bgpHeaderLen:length]
+
+	// Withdrawn routes
+	if len(buf) < 2 {
+		return nil, errors.New("truncated withdrawn routes length")
+	}
+	withdrawnLen := int(binary.BigEndian.Uint16(buf[0:2]))
+	if len(buf) < 2+withdrawnLen+2 {
+		return nil, errors.New("truncated withdrawn routes")
+	}
+	withdrawn, err := parsePrefixes(buf[2:2+withdrawnLen], 1)
+	if err != nil {
+		return nil, fmt.Errorf("decoding withdrawn routes failed: %w", err)
+	}
+	buf = buf[2+withdrawnLen:]
+
+	u := &update{}
+	for _, p := range withdrawn {
+		u.routes = append(u.routes, route{afi: 1, safi: 1, prefix: p, withdraw: true})
+	}
+
+	// Path attributes
+	attrLen := int(binary.BigEndian.Uint16(buf[0:2]))
+	if len(buf) < 2+attrLen {
+		return nil, errors.New("truncated path attributes")
+	}
+	if err := u.parseAttributes(buf[2:2+attrLen], as4); err != nil {
+		return nil, err
+	}
+
+	// Network layer reachability information
+	announced, err := parsePrefixes(buf[2+attrLen:], 1)
+	if err != nil {
+		return nil, fmt.Errorf("decoding NLRI failed: %w", err)
+	}
+	for _, p := range announced {
+		u.routes = append(u.routes, route{afi: 1, safi: 1, prefix: p})
+	}
+
+	return u, nil
+}
+
+func (u *update) parseAttributes(buf []byte, as4 bool) error {
+	for len(buf) > 0 {
+		if len(buf) < 3 {
+			return errors.New("truncated path attribute header")
+		}
+		flags, typ := buf[0], buf[1]
+		var length, offset int
+		if flags&0x10 != 0 {
+			if len(buf) < 4 {
+				return errors.New("truncated path attribute header")
+			}
+			length = int(binary.BigEndian.Uint16(buf[2:4]))
+			offset = 4
+		} else {
+			length = int(buf[2])
+			offset = 3
+		}
+		if len(buf) < offset+length {
+			return fmt.Errorf("path attribute type %d: truncated value", typ)
+		}
+		value := buf[offset : offset+length]
+		buf = buf[offset+length:]
+
+		switch typ {
+		case 1: // ORIGIN
+			if length != 1 {
+				return fmt.Errorf("invalid ORIGIN length %d", length)
+			}
+			switch value[0] {
+			case 0:
+				u.origin = "igp"
+			case 1:
+				u.origin = "egp"
+			case 2:
+				u.origin = "incomplete"
+			}
+		case 2: // AS_PATH
+			path, err := parseASPath(value, as4)
+			if err != nil {
+				return err
+			}
+			u.asPath = path
+		case 3: // NEXT_HOP
+			if length != 4 {
+				return fmt.Errorf("invalid NEXT_HOP length %d", length)
+			}
+			u.nextHop = net.IP(value).String()
+		case 4: // MULTI_EXIT_DISC
+			if length != 4 {
+				return fmt.Errorf("invalid MULTI_EXIT_DISC length %d", length)
+			}
+			v := binary.BigEndian.Uint32(value)
+			u.med = &v
+		case 5: // LOCAL_PREF
+			if length != 4 {
+				return fmt.Errorf("invalid LOCAL_PREF length %d", length)
+			}
+			v := binary.BigEndian.Uint32(value)
+			u.localPref = &v
+		case 8: // COMMUNITIES
+			if length%4 != 0 {
+				return fmt.Errorf("invalid COMMUNITIES length %d", length)
+			}
+			communities := make([]string, 0, length/4)
+			for i := 0; i < length; i += 4 {
+				high := binary.BigEndian.Uint16(value[i : i+2])
+				low := binary.BigEndian.Uint16(value[i+2 : i+4])
+				communities = append(communities, fmt.Sprintf("%d:%d", high, low))
+			}
+			u.communities = strings.Join(communities, " ")
+		case 14: // MP_REACH_NLRI, see RFC 4760 section 3
+			if length < 5 {
+				return fmt.Errorf("invalid MP_REACH_NLRI length %d", length)
+			}
+			afi := binary.BigEndian.Uint16(value[0:2])
+			safi := value[2]
+			nhLen := int(value[3])
+			if length < 4+nhLen+1 {
+				return errors.New("truncated MP_REACH_NLRI next-hop")
+			}
+			// Use the global address in case a link-local address is present
+			switch nhLen {
+			case 4, 16:
+				u.nextHop = net.IP(value[4 : 4+nhLen]).String()
+			case 32:
+				u.nextHop = net.IP(value[4:20]).String()
+			}
+			if !supportedAddressFamily(afi, safi) {
+				continue
+			}
+			prefixes, err := parsePrefixes(value[4+nhLen+1:], afi)
+			if err != nil {
+				return fmt.Errorf("decoding MP_REACH_NLRI failed: %w", err)
+			}
+			for _, p := range prefixes {
+				u.routes = append(u.routes, route{afi: afi, safi: safi, prefix: p})
+			}
+		case 15: // MP_UNREACH_NLRI, see RFC 4760 section 4
+			if length < 3 {
+				return fmt.Errorf("invalid MP_UNREACH_NLRI length %d", length)
+			}
+			afi := binary.BigEndian.Uint16(value[0:2])
+			safi := value[2]
+			if !supportedAddressFamily(afi, safi) {
+				continue
+			}
+			prefixes, err := parsePrefixes(value[3:], afi)
+			if err != nil {
+				return fmt.Errorf("decoding MP_UNREACH_NLRI failed: %w", err)
+			}
+			for _, p := range prefixes {
+				u.routes = append(u.routes, route{afi: afi, safi: safi, prefix: p, withdraw: true})
+			}
+		}
+	}
+	return nil
+}
+
+func parseASPath(buf []byte, as4 bool) (string, error) {
+	size := 2
+	if as4 {
+		size = 4
+	}
+
+	segments := make([]string, 0)
+	for len(buf) > 0 {
+		if len(buf) < 2 {
+			return "", errors.New("truncated AS_PATH segment header")
+		}
+		typ, count := buf[0], int(buf[1])
+		if len(buf) < 2+count*size {
+			return "", errors.New("truncated AS_PATH segment")
+		}
+		asns := make([]string, 0, count)
+		for i := 0; i < count; i++ {
+			v := buf[2+i*size : 2+(i+1)*size]
+			if as4 {
+				asns = append(asns, strconv.FormatUint(uint64(binary.BigEndian.Uint32(v)), 10))
+			} else {
+				asns = append(asns, strconv.FormatUint(uint64(binary.BigEndian.Uint16(v)), 10))
+			}
+		}
+		buf = buf[2+count*size:]
+
+		switch typ {
+		case 1: // AS_SET
+			segments = append(segments, "{"+strings.Join(asns, ",")+"}")
+		case 2: // AS_SEQUENCE
+			segments = append(segments, strings.Join(asns, " "))
+		case 3: // AS_CONFED_SEQUENCE
+			segments = append(segments, "("+strings.Join(asns, " ")+")")
+		case 4: // AS_CONFED_SET
+			segments = append(segments, "["+strings.Join(asns, ",")+"]")
+		default:
+			return "", fmt.Errorf("invalid AS_PATH segment type %d", typ)
+		}
+	}
+	return strings.Join(segments, " "), nil
+}
+
+func parsePrefixes(buf []byte, afi uint16) ([]string, error) {
+	size := net.IPv4len
+	if afi == 2 {
+		size = net.IPv6len
+	}
+
+	var prefixes []string
+	for len(buf) > 0 {
+		bits := int(buf[0])
+		if bits > size*8 {
+			return nil, fmt.Errorf("invalid prefix length %d", bits)
+		}
+		n := (bits + 7) / 8
+		if len(buf) < 1+n {
+			return nil, errors.New("truncated prefix")
+		}
+		addr := make(net.IP, size)
+		copy(addr, buf[1:1+n])
+		buf = buf[1+n:]
+
+		prefix := net.IPNet{IP: addr, Mask: net.CIDRMask(bits, size*8)}
+		prefixes = append(prefixes, prefix.String())
+	}
+	return prefixes, nil
+}
+
+func supportedAddressFamily(afi uint16, safi uint8) bool {
+	return (afi == 1 || afi == 2) && (safi == 1 || safi == 2)
+}
+
+func peerTypeName(t uint8) string {
+	switch t {
+	case peerTypeGlobal:
+		return "global"
+	case peerTypeRD:
+		return "rd"
+	case peerTypeLocal:
+		return "local"
+	case peerTypeLocRIB:
+		return "loc-rib"
+	}
+	return strconv.FormatUint(uint64(t), 10)
+}
+
+func afiName(afi uint16) string {
+	switch afi {
+	case 1:
+		return "ipv4"
+	case 2:
+		return "ipv6"
+	case 25:
+		return "l2vpn"
+	}
+	return strconv.FormatUint(uint64(afi), 10)
+}
+
+func safiName(safi uint8) string {
+	switch safi {
+	case 1:
+		return "unicast"
+	case 2:
+		return "multicast"
+	case 4:
+		return "labeled_unicast"
+	case 70:
+		return "evpn"
+	case 128:
+		return "mpls_vpn"
+	case 133:
+		return "flowspec"
+	}
+	return strconv.FormatUint(uint64(safi), 10)
+}
+
+// formatDistinguisher formats the route distinguisher according to its type,
+// see RFC 4364 section 4.2
+func formatDistinguisher(rd uint64) string {
+	typ := rd >> 48
+	switch typ {
+	case 0:
+		return fmt.Sprintf("%d:%d", (rd>>32)&0xffff, rd&0xffffffff)
+	case 1:
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, uint32(rd>>16))
+		return fmt.Sprintf("%s:%d", ip, rd&0xffff)
+	case 2:
+		return fmt.Sprintf("%d:%d", (rd>>16)&0xffffffff, rd&0xffff)
+	}
+	return strconv.FormatUint(rd, 10)
+}
@@ -0,0 +1,323 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package kube_events
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/plugins/common/kube"
+	"github.com/influxdata/telegraf/plugins/common/tls"
+	"github.com/influxdata/telegraf/plugins/inputs"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+const measurement = "kubernetes_event"
+
+type KubernetesEvents struct {
+	URL             string          `toml:"url"`
+	BearerToken     string          `toml:"bearer_token"`
+	Namespace       string          `toml:"namespace"`
+	FieldSelector   string          `toml:"field_selector"`
+	LabelSelector   string          `toml:"label_selector"`
+	DedupInterval   config.Duration `toml:"dedup_interval"`
+	ResponseTimeout config.Duration `toml:"response_timeout"`
+	RetryInterval   config.Duration `toml:"retry_interval"`
+	Log             telegraf.Logger `toml:"-"`
+	tls.ClientConfig
+
+	client          kubernetes.Interface
+	resourceVersion string
+	lastSeen        map[string]time.Time
+	mu              sync.Mutex
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func (*KubernetesEvents) SampleConfig() string {
+	return sampleConfig
+}
+
+func (k *KubernetesEvents) Init() error {
+	if _, err := fields.ParseSelector(k.FieldSelector); err != nil {
+		return fmt.Errorf("invalid field selector: %w", err)
+	}
+	if _, err := labels.Parse(k.LabelSelector); err != nil {
+		return fmt.Errorf("invalid label selector: %w", err)
+	}
+	if k.DedupInterval < 0 {
+		return errors.New("dedup interval must not be negative")
+	}
+	if k.ResponseTimeout < config.Duration(time.Second) {
+		k.ResponseTimeout = config.Duration(5 * time.Second)
+	}
+	if k.RetryInterval <= 0 {
+		k.RetryInterval = config.Duration(5 * time.Second)
+	}
+
+	// If bearer_token is not provided, use the default service account.
+	if k.BearerToken == "" {
+		k.BearerToken = kube.DefaultServiceAccountPath
+	}
+
+	if k.client == nil {
+		client, err := kube.NewClientset(k.URL, k.BearerToken, k.ClientConfig)
+		if err != nil {
+			return fmt.Errorf("creating client failed: %w", err)
+		}
+		k.client = client
+	}
+	k.lastSeen = make(map[string]time.Time)
+
+	return nil
+}
+
+func (k *KubernetesEvents) GetState() interface{} {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.resourceVersion
+}
+
+func (k *KubernetesEvents) SetState(state interface{}) error {
+	version, ok := state.(string)
+	if !ok {
+		return fmt.Errorf("invalid type %T for state", state)
+	}
+	k.mu.Lock()
+	k.resourceVersion = version
+	k.mu.Unlock()
+
+	return nil
+}
+
+func (k *KubernetesEvents) Start(acc telegraf.Accumulator) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	k.cancel = cancel
+
+	k.wg.Add(1)
+	go func() {
+		defer k.wg.Done()
+		for {
+			if err := k.watch(ctx, acc); err != nil {
+				acc.AddError(err)
+				select {
+				case <-ctx.Done():
+				case <-time.After(time.Duration(k.RetryInterval)):
+				}
+			}
+			if ctx.Err() != nil {
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (k *KubernetesEvents) Gather(telegraf.Accumulator) error {
+	// Remove deduplication entries that cannot suppress any event anymore
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	threshold := time.Now().Add(-time.Duration(k.DedupInterval))
+	for key, last := range k.lastSeen {
+		if last.Before(threshold) {
+			delete(k.lastSeen, key)
+		}
+	}
+
+	return nil
+}
+
+func (k *KubernetesEvents) Stop() {
+	if k.cancel != nil {
+		k.cancel()
+	}
+	k.wg.Wait()
+}
+
+// watch streams the events starting at the last known resource version
+// until the watch is closed by the server
+func (k *KubernetesEvents) watch(ctx context.Context, acc telegraf.Accumulator) error {
+	k.mu.Lock()
+	version := k.resourceVersion
+	k.mu.Unlock()
+
+	// Without a resource version the API server sends all existing events, so
+	// only start from the current state to get new events
+	if version == "" {
+		listCtx, cancel := context.WithTimeout(ctx, time.Duration(k.ResponseTimeout))
+		list, err := k.client.CoreV1().Events(k.Namespace).List(listCtx, metav1.ListOptions{
+			FieldSelector: k.FieldSelector,
+			LabelSelector: k.LabelSelector,
+			Limit:         1,
+		})
+		cancel()
+		if err != nil {
+			return fmt.Errorf("listing events failed: %w", err)
+		}
+		version = list.ResourceVersion
+		k.setResourceVersion(version)
+	}
+
+	watcher, err := k.client.CoreV1().Events(k.Namespace).Watch(ctx, metav1.ListOptions{
+		FieldSelector:       k.FieldSelector,
+		LabelSelector:       k.LabelSelector,
+		ResourceVersion:     version,
+		AllowWatchBookmarks: true,
+	})
+	if err != nil {
+		return fmt.Errorf("watching events failed: %w", err)
+	}
+	defer watcher.Stop()
+
+	for {
+		var ev watch.Event
+		var ok bool
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev, ok = <-watcher.ResultChan():
+			if !ok {
+				return nil
+			}
+		}
+
+		switch ev.Type {
+		case watch.Added, watch.Modified:
+			event, ok := ev.Object.(*corev1.Event)
+			if !ok {
+				k.Log.Debugf("Ignoring unexpected object of type %T", ev.Object)
+				continue
+			}
+			k.add(acc, event)
+			k.setResourceVersion(event.ResourceVersion)
+		case watch.Bookmark:
+			obj, err := meta.Accessor(ev.Object)
+			if err != nil {
+				k.Log.Debugf("Ignoring invalid bookmark: %v", err)
+				continue
+			}
+			k.setResourceVersion(obj.GetResourceVersion())
+		case watch.Error:
+			err := apierrors.FromObject(ev.Object)
+			if apierrors.IsResourceExpired(err) || apierrors.IsGone(err) {
+				// The resource version is too old, restart from the current
+				// state accepting the loss of events in between.
+				k.Log.Warnf("Resource version %q expired, events might have been missed", version)
+				k.setResourceVersion("")
+				return nil
+			}
+			return fmt.Errorf("watch failed: %w", err)
+		}
+	}
+}
+
+func (k *KubernetesEvents) setResourceVersion(version string) {
+	k.mu.Lock()
+	k.resourceVersion = version
+	k.mu.Unlock()
+}
+
+func (k *KubernetesEvents) add(acc telegraf.Accumulator, event *corev1.Event) {
+	obj := event.InvolvedObject
+	namespace := obj.Namespace
+	if namespace == "" {
+		namespace = event.Namespace
+	}
+	timestamp := eventTime(event)
+
+	// Suppress repetitions of the same reason for the same object
+	if k.DedupInterval > 0 {
+		key := namespace + "/" + obj.Kind + "/" + obj.Name + "/" + event.Reason
+		k.mu.Lock()
+		last, found := k.lastSeen[key]
+		if found && timestamp.Before(last.Add(time.Duration(k.DedupInterval))) {
+			k.mu.Unlock()
+			k.Log.Tracef("Suppressing duplicate event %q for %s", event.Reason, key)
+			return
+		}
+		k.lastSeen[key] = timestamp
+		k.mu.Unlock()
+	}
+
+	tags := map[string]string{
+		"namespace": namespace,
+		"kind":      obj.Kind,
+		"name":      obj.Name,
+		"reason":    event.Reason,
+		"type":      event.Type,
+	}
+	if obj.FieldPath != "" {
+		tags["field_path"] = obj.FieldPath
+	}
+	component, host := event.Source.Component, event.Source.Host
+	if component == "" {
+		component = event.ReportingController
+	}
+	if host == "" {
+		host = event.ReportingInstance
+	}
+	if component != "" {
+		tags["source_component"] = component
+	}
+	if host != "" {
+		tags["source_host"] = host
+	}
+
+	count := int64(event.Count)
+	if event.Series != nil {
+		count = int64(event.Series.Count)
+	}
+	fields := map[string]interface{}{
+		"message": event.Message,
+		"count":   max(count, 1),
+		"uid":     string(event.UID),
+	}
+	if !event.FirstTimestamp.IsZero() {
+		fields["first_timestamp"] = event.FirstTimestamp.UnixNano()
+	}
+
+	acc.AddFields(measurement, fields, tags, timestamp)
+}
+
+// eventTime returns the time of the latest occurrence of the event
+func eventTime(event *corev1.Event) time.Time {
+	switch {
+	case event.Series != nil && !event.Series.LastObservedTime.IsZero():
+		return event.Series.LastObservedTime.Time
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	case !event.FirstTimestamp.IsZero():
+		return event.FirstTimestamp.Time
+	}
+	return event.CreationTimestamp.Time
+}
+
+func init() {
+	inputs.Add("kube_events", func() telegraf.Input {
+		return &KubernetesEvents{
+			DedupInterval:   config.Duration(5 * time.Minute),
+			ResponseTimeout: config.Duration(5 * time.Second),
+			RetryInterval:   config.Duration(5 * time.Second),
+		}
+	})
+}
@@ -0,0 +1,373 @@
+package kube_events
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+// fakeAPI wraps the fake clientset and hands out controllable watchers
+type fakeAPI struct {
+	*fake.Clientset
+	listVersion string
+	watchers    chan *watch.FakeWatcher
+
+	sync.Mutex
+	lists   []metav1.ListOptions
+	watches []metav1.ListOptions
+}
+
+func newFakeAPI(listVersion string) *fakeAPI {
+	api := &fakeAPI{
+		Clientset:   fake.NewClientset(),
+		listVersion: listVersion,
+		watchers:    make(chan *watch.FakeWatcher, 10),
+	}
+	api.PrependReactor("list", "events", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		api.Lock()
+		api.lists = append(api.lists, action.(k8stesting.ListActionImpl).ListOptions)
+		api.Unlock()
+		return true, &corev1.EventList{ListMeta: metav1.ListMeta{ResourceVersion: api.listVersion}}, nil
+	})
+	api.PrependWatchReactor("events", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		api.Lock()
+		api.watches = append(api.watches, action.(k8stesting.WatchActionImpl).ListOptions)
+		api.Unlock()
+		w := watch.NewFake()
+		api.watchers <- w
+		return true, w, nil
+	})
+	return api
+}
+
+func (api *fakeAPI) nextWatcher(t *testing.T) *watch.FakeWatcher {
+	select {
+	case w := <-api.watchers:
+		return w
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "watch not started")
+	}
+	return nil
+}
+
+func event(version, kind, name, reason, message string, ts time.Time) *corev1.Event {
+	return &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name + "." + version,
+			Namespace:       "default",
+			UID:             types.UID("uid-" + version),
+			ResourceVersion: version,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			Kind:      kind,
+			Namespace: "default",
+			Name:      name,
+		},
+		Reason:        reason,
+		Message:       message,
+		Type:          corev1.EventTypeWarning,
+		Source:        corev1.EventSource{Component: "kubelet", Host: "node-1"},
+		LastTimestamp: metav1.NewTime(ts),
+		Count:         1,
+	}
+}
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *KubernetesEvents
+		expected string
+	}{
+		{
+			name:     "invalid field selector",
+			plugin:   &KubernetesEvents{FieldSelector: "type"},
+			expected: "invalid field selector",
+		},
+		{
+			name:     "invalid label selector",
+			plugin:   &KubernetesEvents{LabelSelector: "app in (a"},
+			expected: "invalid label selector",
+		},
+		{
+			name:     "negative dedup interval",
+			plugin:   &KubernetesEvents{DedupInterval: -1},
+			expected: "dedup interval must not be negative",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.plugin.Log = testutil.Logger{}
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestEvents(t *testing.T) {
+	api := newFakeAPI("100")
+	plugin := &KubernetesEvents{
+		Namespace:     "default",
+		FieldSelector: "type=Warning",
+		LabelSelector: "app=web",
+		DedupInterval: config.Duration(5 * time.Minute),
+		Log:           testutil.Logger{},
+		client:        api,
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	now := time.Unix(1700000000, 0)
+	w := api.nextWatcher(t)
+	w.Add(event("101", "Pod", "web-1", "BackOff", "Back-off restarting failed container", now))
+	// Repetition within the deduplication interval
+	repeated := event("102", "Pod", "web-1", "BackOff", "Back-off restarting failed container", now.Add(time.Minute))
+	repeated.Count = 2
+	w.Modify(repeated)
+	w.Add(event("103", "Pod", "web-1", "OOMKilling", "Memory cgroup out of memory", now.Add(2*time.Minute)))
+	w.Add(event("104", "Pod", "web-2", "BackOff", "Back-off restarting failed container", now.Add(3*time.Minute)))
+	// Repetition after the deduplication interval
+	later := event("105", "Pod", "web-1", "BackOff", "Back-off restarting failed container", now.Add(6*time.Minute))
+	later.Count = 5
+	w.Modify(later)
+	w.Delete(event("106", "Pod", "web-3", "BackOff", "deleted", now))
+
+	expected := []telegraf.Metric{
+		metric.New(
+			"kubernetes_event",
+			map[string]string{
+				"namespace":        "default",
+				"kind":             "Pod",
+				"name":             "web-1",
+				"reason":           "BackOff",
+				"type":             "Warning",
+				"source_component": "kubelet",
+				"source_host":      "node-1",
+			},
+			map[string]interface{}{
+				"message": "Back-off restarting failed container",
+				"count":   int64(1),
+				"uid":     "uid-101",
+			},
+			now,
+		),
+		metric.New(
+			"kubernetes_event",
+			map[string]string{
+				"namespace":        "default",
+				"kind":             "Pod",
+				"name":             "web-1",
+				"reason":           "OOMKilling",
+				"type":             "Warning",
+				"source_component": "kubelet",
+				"source_host":      "node-1",
+			},
+			map[string]interface{}{
+				"message": "Memory cgroup out of memory",
+				"count":   int64(1),
+				"uid":     "uid-103",
+			},
+			now.Add(2*time.Minute),
+		),
+		metric.New(
+			"kubernetes_event",
+			map[string]string{
+				"namespace":        "default",
+				"kind":             "Pod",
+				"name":             "web-2",
+				"reason":           "BackOff",
+				"type":             "Warning",
+				"source_component": "kubelet",
+				"source_host":      "node-1",
+			},
+			map[string]interface{}{
+				"message": "Back-off restarting failed container",
+				"count":   int64(1),
+				"uid":     "uid-104",
+			},
+			now.Add(3*time.Minute),
+		),
+		metric.New(
+			"kubernetes_event",
+			map[string]string{
+				"namespace":        "default",
+				"kind":             "Pod",
+				"name":             "web-1",
+				"reason":           "BackOff",
+				"type":             "Warning",
+				"source_component": "kubelet",
+				"source_host":      "node-1",
+			},
+			map[string]interface{}{
+				"message": "Back-off restarting failed container",
+				"count":   int64(5),
+				"uid":     "uid-105",
+			},
+			now.Add(6*time.Minute),
+		),
+	}
+
+	require.Eventually(t, func() bool {
+		return acc.NMetrics() >= uint64(len(expected))
+	}, 5*time.Second, 10*time.Millisecond)
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics())
+	require.NoError(t, acc.FirstError())
+	require.Equal(t, "105", plugin.GetState())
+
+	// The watch should start at the current state with the configured selectors
+	api.Lock()
+	defer api.Unlock()
+	require.Len(t, api.lists, 1)
+	require.Equal(t, "type=Warning", api.lists[0].FieldSelector)
+	require.Equal(t, "app=web", api.lists[0].LabelSelector)
+	require.Len(t, api.watches, 1)
+	require.Equal(t, "100", api.watches[0].ResourceVersion)
+	require.Equal(t, "type=Warning", api.watches[0].FieldSelector)
+	require.Equal(t, "app=web", api.watches[0].LabelSelector)
+	require.True(t, api.watches[0].AllowWatchBookmarks)
+}
+
+func TestDedupDisabled(t *testing.T) {
+	api := newFakeAPI("100")
+	plugin := &KubernetesEvents{
+		Log:    testutil.Logger{},
+		client: api,
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	now := time.Unix(1700000000, 0)
+	w := api.nextWatcher(t)
+	w.Add(event("101", "Pod", "web-1", "BackOff", "first", now))
+	w.Modify(event("102", "Pod", "web-1", "BackOff", "second", now.Add(time.Second)))
+
+	require.Eventually(t, func() bool {
+		return acc.NMetrics() >= 2
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestResume(t *testing.T) {
+	api := newFakeAPI("100")
+	plugin := &KubernetesEvents{
+		Log:    testutil.Logger{},
+		client: api,
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.SetState("42"))
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	w := api.nextWatcher(t)
+	w.Action(watch.Bookmark, &corev1.Event{ObjectMeta: metav1.ObjectMeta{ResourceVersion: "57"}})
+	require.Eventually(t, func() bool {
+		return plugin.GetState() == "57"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Closing the watch should resume at the bookmark
+	w.Stop()
+	api.nextWatcher(t)
+
+	api.Lock()
+	defer api.Unlock()
+	require.Empty(t, api.lists)
+	require.Len(t, api.watches, 2)
+	require.Equal(t, "42", api.watches[0].ResourceVersion)
+	require.Equal(t, "57", api.watches[1].ResourceVersion)
+	require.Empty(t, acc.GetTelegrafMetrics())
+}
+
+func TestExpiredResourceVersion(t *testing.T) {
+	api := newFakeAPI("300")
+	plugin := &KubernetesEvents{
+		Log:    testutil.Logger{},
+		client: api,
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.SetState("42"))
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	w := api.nextWatcher(t)
+	w.Error(&metav1.Status{
+		Status:  metav1.StatusFailure,
+		Code:    http.StatusGone,
+		Reason:  metav1.StatusReasonExpired,
+		Message: "too old resource version: 42 (250)",
+	})
+	api.nextWatcher(t)
+
+	api.Lock()
+	defer api.Unlock()
+	require.Len(t, api.lists, 1)
+	require.Len(t, api.watches, 2)
+	require.Equal(t, "42", api.watches[0].ResourceVersion)
+	require.Equal(t, "300", api.watches[1].ResourceVersion)
+	require.NoError(t, acc.FirstError())
+}
+
+func TestWatchError(t *testing.T) {
+	api := newFakeAPI("100")
+	plugin := &KubernetesEvents{
+		RetryInterval: 1,
+		Log:           testutil.Logger{},
+		client:        api,
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	w := api.nextWatcher(t)
+	w.Error(&metav1.Status{
+		Status:  metav1.StatusFailure,
+		Code:    http.StatusForbidden,
+		Reason:  metav1.StatusReasonForbidden,
+		Message: "events is forbidden",
+	})
+	api.nextWatcher(t)
+
+	acc.WaitError(1)
+	require.ErrorContains(t, acc.FirstError(), "events is forbidden")
+}
+
+func TestCleanup(t *testing.T) {
+	plugin := &KubernetesEvents{
+		DedupInterval: config.Duration(5 * time.Minute),
+		Log:           testutil.Logger{},
+		client:        newFakeAPI(""),
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	plugin.add(&acc, event("1", "Pod", "old", "BackOff", "", time.Now().Add(-time.Hour)))
+	plugin.add(&acc, event("2", "Pod", "new", "BackOff", "", time.Now()))
+	require.Len(t, plugin.lastSeen, 2)
+
+	require.NoError(t, plugin.Gather(&acc))
+	require.Len(t, plugin.lastSeen, 1)
+	require.Contains(t, plugin.lastSeen, "default/Pod/new/BackOff")
+}
@@ -15,6 +15,7 @@ import (
 	"k8s.io/client-go/rest"
 
 	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/plugins/common/kube"
 	"github.com/influxdata/telegraf/plugins/common/tls"
 )
 
@@ -25,33 +26,7 @@ type client struct {
 }
 
 func newClient(baseURL, namespace, bearerTokenFile string, timeout time.Duration, tlsConfig tls.ClientConfig) (*client, error) {
-	var clientConfig *rest.Config
-	var err error
-
-	if baseURL == "" {
-		clientConfig, err = rest.InClusterConfig()
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		clientConfig = &rest.Config{
-			TLSClientConfig: rest.TLSClientConfig{
-				ServerName: tlsConfig.ServerName,
-				Insecure:   tlsConfig.InsecureSkipVerify,
-				CAFile:     tlsConfig.TLSCA,
-				CertFile:   tlsConfig.TLSCert,
-				KeyFile:    tlsConfig.TLSKey,
-			},
-			Host:          baseURL,
-			ContentConfig: rest.ContentConfig{},
-		}
-
-		if bearerTokenFile != "" {
-			clientConfig.BearerTokenFile = bearerTokenFile
-		}
-	}
-
-	c, err := kubernetes.NewForConfig(clientConfig)
+	c, err := kube.NewClientset(baseURL, bearerTokenFile, tlsConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -16,6 +16,7 @@ import (
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/config"
 	"github.com/influxdata/telegraf/filter"
+	"github.com/influxdata/telegraf/plugins/common/kube"
 	"github.com/influxdata/telegraf/plugins/common/tls"
 	"github.com/influxdata/telegraf/plugins/inputs"
 )
@@ -51,8 +52,6 @@ const (
 	statefulSetMeasurement           = "kubernetes_statefulset"
 	resourcequotaMeasurement         = "kubernetes_resourcequota"
 	certificateMeasurement           = "kubernetes_certificate"
-
-	defaultServiceAccountPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
 )
 
 type KubernetesInventory struct {
@@ -85,7 +84,7 @@ func (*KubernetesInventory) SampleConfig() string {
 func (ki *KubernetesInventory) Init() error {
 	// If bearer_token is not provided, use the default service account.
 	if ki.BearerToken == "" {
-		ki.BearerToken = defaultServiceAccountPath
+		ki.BearerToken = kube.DefaultServiceAccountPath
 	}
 
 	var err error
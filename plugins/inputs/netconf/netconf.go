@@ -0,0 +1,498 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package netconf
+
+import (
+	"context"
+	_ "embed"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/common/yangmodel"
+	"github.com/influxdata/telegraf/plugins/inputs"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+const (
+	capXPath        = "urn:ietf:params:netconf:capability:xpath:1.0"
+	capNotification = "urn:ietf:params:netconf:capability:notification:1.0"
+)
+
+type NETCONF struct {
+	Addresses            []string        `toml:"addresses"`
+	Username             config.Secret   `toml:"username"`
+	Password             config.Secret   `toml:"password"`
+	PrivateKey           string          `toml:"private_key"`
+	KnownHosts           string          `toml:"known_hosts"`
+	InsecureSkipVerify   bool            `toml:"insecure_skip_verify"`
+	Timeout              config.Duration `toml:"timeout"`
+	Redial               config.Duration `toml:"redial"`
+	MaxMsgSize           config.Size     `toml:"max_msg_size"`
+	KeyNames             []string        `toml:"key_names"`
+	PrefixTagKeyWithPath bool            `toml:"prefix_tag_key_with_path"`
+	YangModelPaths       []string        `toml:"yang_model_paths"`
+	Requests             []request       `toml:"request"`
+	Subscriptions        []subscription  `toml:"subscription"`
+	Log                  telegraf.Logger `toml:"-"`
+
+	// Internal state
+	devices         []*device
+	signer          ssh.Signer
+	hostKeyCallback ssh.HostKeyCallback
+	converter       *converter
+	cancel          context.CancelFunc
+	wg              sync.WaitGroup
+}
+
+type filter struct {
+	XPath      string            `toml:"xpath"`
+	Subtree    string            `toml:"subtree"`
+	Namespaces map[string]string `toml:"namespaces"`
+}
+
+type request struct {
+	Name      string `toml:"name"`
+	Operation string `toml:"operation"`
+	Datastore string `toml:"datastore"`
+	filter
+
+	base string
+	rpc  string
+}
+
+type subscription struct {
+	Name   string `toml:"name"`
+	Stream string `toml:"stream"`
+	filter
+
+	base string
+	rpc  string
+}
+
+// device holds the persistent session used for the requests
+type device struct {
+	address string
+	source  string
+	session *session
+}
+
+func (*NETCONF) SampleConfig() string {
+	return sampleConfig
+}
+
+func (n *NETCONF) Init() error {
+	// Check options
+	if len(n.Addresses) == 0 {
+		return errors.New("no addresses specified")
+	}
+	if len(n.Requests) == 0 && len(n.Subscriptions) == 0 {
+		return errors.New("neither requests nor subscriptions specified")
+	}
+	if n.Timeout <= 0 {
+		n.Timeout = config.Duration(10 * time.Second)
+	}
+	if n.Redial <= 0 {
+		n.Redial = config.Duration(10 * time.Second)
+	}
+	if n.MaxMsgSize == 0 {
+		n.MaxMsgSize = config.Size(64 * 1024 * 1024)
+	}
+	if len(n.KeyNames) == 0 {
+		n.KeyNames = []string{"name"}
+	}
+
+	// Prepare the requests and subscriptions
+	for i := range n.Requests {
+		r := &n.Requests[i]
+		if r.Name == "" {
+			return fmt.Errorf("empty 'name' found for request %d", i+1)
+		}
+		if err := r.init(); err != nil {
+			return fmt.Errorf("request %q: %w", r.Name, err)
+		}
+	}
+	for i := range n.Subscriptions {
+		s := &n.Subscriptions[i]
+		if s.Name == "" {
+			return fmt.Errorf("empty 'name' found for subscription %d", i+1)
+		}
+		if err := s.init(); err != nil {
+			return fmt.Errorf("subscription %q: %w", s.Name, err)
+		}
+	}
+
+	// Setup authentication
+	if n.PrivateKey != "" {
+		buf, err := os.ReadFile(n.PrivateKey)
+		if err != nil {
+			return fmt.Errorf("reading private key failed: %w", err)
+		}
+		signer, err := ssh.ParsePrivateKey(buf)
+		if err != nil {
+			return fmt.Errorf("parsing private key failed: %w", err)
+		}
+		n.signer = signer
+	}
+	if n.Username.Empty() {
+		return errors.New("'username' is required")
+	}
+	if n.Password.Empty() && n.signer == nil {
+		return errors.New("either 'password' or 'private_key' is required")
+	}
+
+	// Setup host-key verification
+	switch {
+	case n.InsecureSkipVerify:
+		//nolint:gosec // G106: Explicitly requested by the user
+		n.hostKeyCallback = ssh.InsecureIgnoreHostKey()
+	case n.KnownHosts != "":
+		callback, err := knownhosts.New(n.KnownHosts)
+		if err != nil {
+			return fmt.Errorf("reading known-hosts file failed: %w", err)
+		}
+		n.hostKeyCallback = callback
+	default:
+		return errors.New("either 'known_hosts' or 'insecure_skip_verify' is required")
+	}
+
+	// Prepare the devices
+	n.devices = make([]*device, 0, len(n.Addresses))
+	for _, addr := range n.Addresses {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			// Use the default NETCONF over SSH port
+			host = addr
+			addr = net.JoinHostPort(addr, "830")
+		}
+		n.devices = append(n.devices, &device{address: addr, source: host})
+	}
+
+	// Load the YANG models if specified by the user
+	n.converter = &converter{
+		keyNames:      make(map[string]bool, len(n.KeyNames)),
+		tagPathPrefix: n.PrefixTagKeyWithPath,
+		log:           n.Log,
+	}
+	for _, k := range n.KeyNames {
+		n.converter.keyNames[k] = true
+	}
+	if len(n.YangModelPaths) > 0 {
+		decoder, err := yangmodel.NewDecoder(n.YangModelPaths...)
+		if err != nil {
+			return fmt.Errorf("creating YANG model decoder failed: %w", err)
+		}
+		n.converter.decoder = decoder
+	}
+
+	return nil
+}
+
+func (n *NETCONF) Start(acc telegraf.Accumulator) error {
+	var ctx context.Context
+	ctx, n.cancel = context.WithCancel(context.Background())
+
+	// Create a goroutine for each device and subscription as each session
+	// can only carry a single notification subscription
+	for _, d := range n.devices {
+		for _, s := range n.Subscriptions {
+			n.wg.Add(1)
+			go func(d *device, s *subscription) {
+				defer n.wg.Done()
+				for ctx.Err() == nil {
+					if err := n.subscribe(ctx, acc, d, s); err != nil && ctx.Err() == nil {
+						acc.AddError(fmt.Errorf("subscription %q on %s: %w", s.Name, d.address, err))
+					}
+
+					select {
+					case <-ctx.Done():
+					case <-time.After(time.Duration(n.Redial)):
+					}
+				}
+			}(d, &s)
+		}
+	}
+
+	return nil
+}
+
+func (n *NETCONF) Gather(acc telegraf.Accumulator) error {
+	if len(n.Requests) == 0 {
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	for _, d := range n.devices {
+		wg.Add(1)
+		go func(d *device) {
+			defer wg.Done()
+			if err := n.query(acc, d); err != nil {
+				acc.AddError(fmt.Errorf("querying %s failed: %w", d.address, err))
+			}
+		}(d)
+	}
+	wg.Wait()
+
+	return nil
+}
+
+func (n *NETCONF) Stop() {
+	if n.cancel != nil {
+		n.cancel()
+	}
+	n.wg.Wait()
+
+	for _, d := range n.devices {
+		if d.session != nil {
+			d.session.close()
+			d.session = nil
+		}
+	}
+}
+
+func (n *NETCONF) connect(address string) (*session, error) {
+	username, err := n.Username.Get()
+	if err != nil {
+		return nil, fmt.Errorf("getting username failed: %w", err)
+	}
+	defer username.Destroy()
+
+	cfg := &ssh.ClientConfig{
+		User:            username.String(),
+		HostKeyCallback: n.hostKeyCallback,
+		Timeout:         time.Duration(n.Timeout),
+	}
+	if n.signer != nil {
+		cfg.Auth = append(cfg.Auth, ssh.PublicKeys(n.signer))
+	}
+	if !n.Password.Empty() {
+		password, err := n.Password.Get()
+		if err != nil {
+			return nil, fmt.Errorf("getting password failed: %w", err)
+		}
+		cfg.Auth = append(cfg.Auth, ssh.Password(password.String()))
+		password.Destroy()
+	}
+
+	return newSession(address, cfg, time.Duration(n.Timeout), int(n.MaxMsgSize))
+}
+
+func (n *NETCONF) query(acc telegraf.Accumulator, d *device) error {
+	if d.session == nil {
+		s, err := n.connect(d.address)
+		if err != nil {
+			return err
+		}
+		d.session = s
+	}
+
+	grouper := metric.NewSeriesGrouper()
+	for _, r := range n.Requests {
+		if r.XPath != "" && !d.session.hasCapability(capXPath) {
+			acc.AddError(fmt.Errorf("request %q on %s: device does not support XPath filters", r.Name, d.address))
+			continue
+		}
+
+		timestamp := time.Now()
+		reply, err := d.session.rpc(r.rpc, time.Duration(n.Timeout))
+		if err != nil {
+			var rerr *rpcError
+			if errors.As(err, &rerr) {
+				acc.AddError(fmt.Errorf("request %q on %s: %w", r.Name, d.address, err))
+				continue
+			}
+
+			// The session is broken, so reconnect on next gather
+			d.session.close()
+			d.session = nil
+			return fmt.Errorf("request %q: %w", r.Name, err)
+		}
+
+		data := reply.child("data")
+		if data == nil {
+			continue
+		}
+		tags := map[string]string{"source": d.source, "path": r.base}
+		n.converter.convert(grouper, r.Name, r.base, data.Children, tags, timestamp)
+	}
+
+	for _, m := range grouper.Metrics() {
+		acc.AddMetric(m)
+	}
+
+	return nil
+}
+
+func (n *NETCONF) subscribe(ctx context.Context, acc telegraf.Accumulator, d *device, s *subscription) error {
+	sess, err := n.connect(d.address)
+	if err != nil {
+		return err
+	}
+	defer sess.close()
+
+	// Close the session on shutdown to unblock the receiver
+	stop := context.AfterFunc(ctx, sess.close)
+	defer stop()
+
+	if !sess.hasCapability(capNotification) {
+		return errors.New("device does not support notifications")
+	}
+	if s.XPath != "" && !sess.hasCapability(capXPath) {
+		return errors.New("device does not support XPath filters")
+	}
+	if _, err := sess.rpc(s.rpc, time.Duration(n.Timeout)); err != nil {
+		return fmt.Errorf("creating subscription failed: %w", err)
+	}
+	n.Log.Debugf("Subscribed to stream %q on %s", s.Stream, d.address)
+
+	for {
+		buf, err := sess.receive()
+		if err != nil {
+			return err
+		}
+		var notification node
+		if err := xml.Unmarshal(buf, &notification); err != nil {
+			acc.AddError(fmt.Errorf("decoding notification from %s failed: %w", d.address, err))
+			continue
+		}
+		if notification.XMLName.Space != nsNotification || notification.XMLName.Local != "notification" {
+			n.Log.Debugf("Ignoring unexpected %q message from %s", notification.XMLName.Local, d.address)
+			continue
+		}
+
+		// Use the event time of the notification as timestamp if possible
+		timestamp := time.Now()
+		content := make([]*node, 0, len(notification.Children))
+		for _, c := range notification.Children {
+			if c.XMLName.Local != "eventTime" {
+				content = append(content, c)
+				continue
+			}
+			t, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(c.Content))
+			if err != nil {
+				n.Log.Debugf("Parsing event time %q failed: %v", c.Content, err)
+				continue
+			}
+			timestamp = t
+		}
+
+		grouper := metric.NewSeriesGrouper()
+		tags := map[string]string{"source": d.source, "path": s.base}
+		n.converter.convert(grouper, s.Name, s.base, content, tags, timestamp)
+		for _, m := range grouper.Metrics() {
+			acc.AddMetric(m)
+		}
+	}
+}
+
+func (f *filter) init() (string, error) {
+	if f.XPath != "" && f.Subtree != "" {
+		return "", errors.New("'xpath' and 'subtree' are mutually exclusive")
+	}
+	if len(f.Namespaces) > 0 && f.XPath == "" {
+		return "", errors.New("'namespaces' can only be used with 'xpath'")
+	}
+	if f.Subtree != "" {
+		return subtreeBase(f.Subtree)
+	}
+	return xpathBase(f.XPath), nil
+}
+
+// element returns the filter element using the given attribute for the filter type
+func (f *filter) element(typeAttr string) string {
+	switch {
+	case f.XPath != "":
+		var buf strings.Builder
+		buf.WriteString(`<filter ` + typeAttr + `="xpath" select="` + escape(f.XPath) + `"`)
+		prefixes := make([]string, 0, len(f.Namespaces))
+		for prefix := range f.Namespaces {
+			prefixes = append(prefixes, prefix)
+		}
+		sort.Strings(prefixes)
+		for _, prefix := range prefixes {
+			buf.WriteString(` xmlns:` + prefix + `="` + escape(f.Namespaces[prefix]) + `"`)
+		}
+		buf.WriteString(`/>`)
+		return buf.String()
+	case f.Subtree != "":
+		return `<filter ` + typeAttr + `="subtree">` + f.Subtree + `</filter>`
+	}
+	return ""
+}
+
+func (r *request) init() error {
+	base, err := r.filter.init()
+	if err != nil {
+		return err
+	}
+	r.base = base
+
+	switch r.Operation {
+	case "", "get":
+		if r.Datastore != "" {
+			return errors.New("'datastore' can only be used with 'get-config' operations")
+		}
+		r.Operation = "get"
+		r.rpc = "<get>" + r.element("type") + "</get>"
+	case "get-config":
+		switch r.Datastore {
+		case "":
+			r.Datastore = "running"
+		case "running", "candidate", "startup":
+		default:
+			return fmt.Errorf("invalid datastore %q", r.Datastore)
+		}
+		r.rpc = "<get-config><source><" + r.Datastore + "/></source>" + r.element("type") + "</get-config>"
+	default:
+		return fmt.Errorf("invalid operation %q", r.Operation)
+	}
+
+	return nil
+}
+
+func (s *subscription) init() error {
+	base, err := s.filter.init()
+	if err != nil {
+		return err
+	}
+	s.base = base
+
+	if s.Stream == "" {
+		s.Stream = "NETCONF"
+	}
+
+	// The filter type attribute belongs to the base namespace, see RFC 5277
+	// section 2.1.1
+	s.rpc = `<create-subscription xmlns="` + nsNotification + `" xmlns:nc="` + nsBase + `">` +
+		"<stream>" + escape(s.Stream) + "</stream>" +
+		s.element("nc:type") +
+		"</create-subscription>"
+
+	return nil
+}
+
+func escape(s string) string {
+	var buf strings.Builder
+	//nolint:errcheck // Writing to a string builder never fails
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+func init() {
+	inputs.Add("netconf", func() telegraf.Input {
+		return &NETCONF{}
+	})
+}
@@ -0,0 +1,583 @@
+package netconf
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/xml"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *NETCONF
+		expected string
+	}{
+		{
+			name:     "no addresses",
+			plugin:   &NETCONF{},
+			expected: "no addresses specified",
+		},
+		{
+			name:     "no requests",
+			plugin:   &NETCONF{Addresses: []string{"localhost"}},
+			expected: "neither requests nor subscriptions specified",
+		},
+		{
+			name: "empty name",
+			plugin: &NETCONF{
+				Addresses: []string{"localhost"},
+				Requests:  []request{{}},
+			},
+			expected: "empty 'name' found for request 1",
+		},
+		{
+			name: "invalid operation",
+			plugin: &NETCONF{
+				Addresses: []string{"localhost"},
+				Requests:  []request{{Name: "test", Operation: "edit-config"}},
+			},
+			expected: `invalid operation "edit-config"`,
+		},
+		{
+			name: "datastore for get",
+			plugin: &NETCONF{
+				Addresses: []string{"localhost"},
+				Requests:  []request{{Name: "test", Datastore: "running"}},
+			},
+			expected: "'datastore' can only be used with 'get-config' operations",
+		},
+		{
+			name: "multiple filters",
+			plugin: &NETCONF{
+				Addresses: []string{"localhost"},
+				Subscriptions: []subscription{
+					{Name: "test", filter: filter{XPath: "/interfaces", Subtree: "<interfaces/>"}},
+				},
+			},
+			expected: "'xpath' and 'subtree' are mutually exclusive",
+		},
+		{
+			name: "missing credentials",
+			plugin: &NETCONF{
+				Addresses: []string{"localhost"},
+				Username:  config.NewSecret([]byte("user")),
+				Requests:  []request{{Name: "test"}},
+			},
+			expected: "either 'password' or 'private_key' is required",
+		},
+		{
+			name: "missing host-key verification",
+			plugin: &NETCONF{
+				Addresses: []string{"localhost"},
+				Username:  config.NewSecret([]byte("user")),
+				Password:  config.NewSecret([]byte("secret")),
+				Requests:  []request{{Name: "test"}},
+			},
+			expected: "either 'known_hosts' or 'insecure_skip_verify' is required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.plugin.Log = &testutil.Logger{}
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestBasePath(t *testing.T) {
+	require.Equal(t, "/interfaces/interface/state", xpathBase("/if:interfaces/if:interface[if:name='a[0]']/if:state"))
+	require.Equal(t, "/interfaces/interface", xpathBase("/interfaces/interface[name=\"eth0\"]/*"))
+	require.Equal(t, "/", xpathBase("/a | /b"))
+	require.Equal(t, "/", xpathBase(""))
+
+	base, err := subtreeBase(`<interfaces xmlns="urn:example"><interface><name>eth0</name><state/></interface></interfaces>`)
+	require.NoError(t, err)
+	require.Equal(t, "/interfaces/interface/state", base)
+
+	base, err = subtreeBase(`<interfaces><interface><name/><state/></interface></interfaces>`)
+	require.NoError(t, err)
+	require.Equal(t, "/interfaces/interface", base)
+
+	_, err = subtreeBase(`<interfaces>`)
+	require.Error(t, err)
+}
+
+func TestGet(t *testing.T) {
+	for _, chunked := range []bool{true, false} {
+		name := "end-of-message framing"
+		if chunked {
+			name = "chunked framing"
+		}
+		t.Run(name, func(t *testing.T) {
+			data := `
+<interfaces xmlns="urn:example:interfaces">
+  <interface>
+    <name>eth0</name>
+    <description>uplink</description>
+    <state>
+      <in-octets>1234</in-octets>
+      <oper-status>up</oper-status>
+      <enabled>true</enabled>
+      <load>0.5</load>
+    </state>
+  </interface>
+  <interface>
+    <name>eth1</name>
+    <state>
+      <in-octets>42</in-octets>
+      <oper-status>down</oper-status>
+      <enabled>false</enabled>
+      <load>0</load>
+    </state>
+  </interface>
+</interfaces>`
+
+			server := newServer(t, chunked)
+			server.replies["get"] = "<data>" + data + "</data>"
+
+			plugin := &NETCONF{
+				Addresses:          []string{server.address},
+				Username:           config.NewSecret([]byte("telegraf")),
+				Password:           config.NewSecret([]byte("secret")),
+				InsecureSkipVerify: true,
+				Requests: []request{
+					{
+						Name: "interfaces",
+						filter: filter{
+							XPath:      "/if:interfaces/if:interface/if:state",
+							Namespaces: map[string]string{"if": "urn:example:interfaces"},
+						},
+					},
+				},
+				Log: &testutil.Logger{},
+			}
+			require.NoError(t, plugin.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, plugin.Start(&acc))
+			defer plugin.Stop()
+
+			// Run two gathers to check the session is reused
+			require.NoError(t, plugin.Gather(&acc))
+			require.NoError(t, plugin.Gather(&acc))
+			require.Empty(t, acc.Errors)
+
+			expected := []telegraf.Metric{
+				metric.New(
+					"interfaces",
+					map[string]string{
+						"source": "127.0.0.1",
+						"path":   "/interfaces/interface/state",
+						"name":   "eth0",
+					},
+					map[string]interface{}{
+						"description": "uplink",
+						"in_octets":   int64(1234),
+						"oper_status": "up",
+						"enabled":     true,
+						"load":        float64(0.5),
+					},
+					time.Unix(0, 0),
+				),
+				metric.New(
+					"interfaces",
+					map[string]string{
+						"source": "127.0.0.1",
+						"path":   "/interfaces/interface/state",
+						"name":   "eth1",
+					},
+					map[string]interface{}{
+						"in_octets":   int64(42),
+						"oper_status": "down",
+						"enabled":     false,
+						"load":        int64(0),
+					},
+					time.Unix(0, 0),
+				),
+			}
+			expected = append(expected, expected...)
+			testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.IgnoreTime(), testutil.SortMetrics())
+
+			// Check the request sent to the device
+			require.Equal(t, 1, server.connections())
+			rpcs := server.received()
+			require.Len(t, rpcs, 2)
+			require.Equal(t, "get", rpcs[0].Children[0].XMLName.Local)
+			filter := rpcs[0].Children[0].child("filter")
+			require.NotNil(t, filter)
+			require.Equal(t, "xpath", filter.attr("type"))
+			require.Equal(t, "/if:interfaces/if:interface/if:state", filter.attr("select"))
+		})
+	}
+}
+
+func TestGetConfigWithModel(t *testing.T) {
+	data := `
+<interfaces xmlns="urn:example:interfaces">
+  <interface>
+    <name>eth0</name>
+    <description>1234</description>
+    <in-octets>5678</in-octets>
+  </interface>
+</interfaces>`
+
+	server := newServer(t, true)
+	server.replies["get-config"] = "<data>" + data + "</data>"
+
+	plugin := &NETCONF{
+		Addresses:            []string{server.address},
+		Username:             config.NewSecret([]byte("telegraf")),
+		Password:             config.NewSecret([]byte("secret")),
+		InsecureSkipVerify:   true,
+		PrefixTagKeyWithPath: true,
+		YangModelPaths:       []string{"testdata/models"},
+		Requests: []request{
+			{
+				Name:      "config",
+				Operation: "get-config",
+				Datastore: "startup",
+				filter: filter{
+					Subtree: `<interfaces xmlns="urn:example:interfaces"><interface/></interfaces>`,
+				},
+			},
+		},
+		Log: &testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+	require.NoError(t, plugin.Gather(&acc))
+	require.Empty(t, acc.Errors)
+
+	expected := []telegraf.Metric{
+		metric.New(
+			"config",
+			map[string]string{
+				"source":         "127.0.0.1",
+				"path":           "/interfaces/interface",
+				"interface_name": "eth0",
+			},
+			map[string]interface{}{
+				"description": "1234",
+				"in_octets":   uint64(5678),
+			},
+			time.Unix(0, 0),
+		),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.IgnoreTime())
+
+	// Check the request sent to the device
+	rpcs := server.received()
+	require.Len(t, rpcs, 1)
+	op := rpcs[0].Children[0]
+	require.Equal(t, "get-config", op.XMLName.Local)
+	require.NotNil(t, op.child("source").child("startup"))
+	filter := op.child("filter")
+	require.NotNil(t, filter)
+	require.Equal(t, "subtree", filter.attr("type"))
+	require.NotNil(t, filter.child("interfaces"))
+}
+
+func TestRPCError(t *testing.T) {
+	server := newServer(t, true)
+	server.replies["get"] = `
+<rpc-error>
+  <error-type>application</error-type>
+  <error-tag>operation-not-supported</error-tag>
+  <error-severity>error</error-severity>
+  <error-message xml:lang="en">not today</error-message>
+</rpc-error>`
+
+	plugin := &NETCONF{
+		Addresses:          []string{server.address},
+		Username:           config.NewSecret([]byte("telegraf")),
+		Password:           config.NewSecret([]byte("secret")),
+		InsecureSkipVerify: true,
+		Requests:           []request{{Name: "test"}},
+		Log:                &testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+	require.NoError(t, plugin.Gather(&acc))
+	require.Len(t, acc.Errors, 1)
+	require.ErrorContains(t, acc.Errors[0], "application error operation-not-supported: not today")
+	require.Empty(t, acc.GetTelegrafMetrics())
+
+	// Errors reported by the device should not terminate the session
+	require.NoError(t, plugin.Gather(&acc))
+	require.Equal(t, 1, server.connections())
+}
+
+func TestAuthenticationFailure(t *testing.T) {
+	server := newServer(t, true)
+
+	plugin := &NETCONF{
+		Addresses:          []string{server.address},
+		Username:           config.NewSecret([]byte("telegraf")),
+		Password:           config.NewSecret([]byte("wrong")),
+		InsecureSkipVerify: true,
+		Requests:           []request{{Name: "test"}},
+		Log:                &testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+	require.NoError(t, plugin.Gather(&acc))
+	require.Len(t, acc.Errors, 1)
+	require.ErrorContains(t, acc.Errors[0], "unable to authenticate")
+}
+
+func TestNotifications(t *testing.T) {
+	server := newServer(t, true)
+	server.replies["create-subscription"] = "<ok/>"
+	server.notifications = []string{`
+<notification xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0">
+  <eventTime>2024-05-01T10:00:00.5Z</eventTime>
+  <link-down xmlns="urn:example:events">
+    <interface>
+      <name>eth3</name>
+      <reason>carrier-lost</reason>
+      <flaps>3</flaps>
+    </interface>
+  </link-down>
+</notification>`,
+	}
+
+	plugin := &NETCONF{
+		Addresses:          []string{server.address},
+		Username:           config.NewSecret([]byte("telegraf")),
+		Password:           config.NewSecret([]byte("secret")),
+		InsecureSkipVerify: true,
+		Subscriptions: []subscription{
+			{
+				Name:   "events",
+				Stream: "interfaces",
+				filter: filter{Subtree: `<link-down xmlns="urn:example:events"/>`},
+			},
+		},
+		Log: &testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	expected := []telegraf.Metric{
+		metric.New(
+			"events",
+			map[string]string{
+				"source": "127.0.0.1",
+				"path":   "/link-down",
+				"name":   "eth3",
+			},
+			map[string]interface{}{
+				"interface/reason": "carrier-lost",
+				"interface/flaps":  int64(3),
+			},
+			time.Date(2024, 5, 1, 10, 0, 0, 500000000, time.UTC),
+		),
+	}
+	require.Eventually(t, func() bool {
+		return acc.NMetrics() >= uint64(len(expected))
+	}, 3*time.Second, 100*time.Millisecond)
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics())
+
+	// Check the subscription request
+	rpcs := server.received()
+	require.Len(t, rpcs, 1)
+	op := rpcs[0].Children[0]
+	require.Equal(t, "create-subscription", op.XMLName.Local)
+	require.Equal(t, nsNotification, op.XMLName.Space)
+	require.Equal(t, "interfaces", op.childText("stream"))
+	require.NotNil(t, op.child("filter"))
+}
+
+// server is a minimal NETCONF over SSH server for testing
+type server struct {
+	address       string
+	chunked       bool
+	replies       map[string]string
+	notifications []string
+
+	config   *ssh.ServerConfig
+	listener net.Listener
+	rpcs     []*node
+	conns    int
+	mu       sync.Mutex
+	wg       sync.WaitGroup
+}
+
+func newServer(t *testing.T, chunked bool) *server {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(key)
+	require.NoError(t, err)
+
+	cfg := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if c.User() == "telegraf" && string(password) == "secret" {
+				return nil, nil
+			}
+			return nil, errors.New("access denied")
+		},
+	}
+	cfg.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := &server{
+		address:  listener.Addr().String(),
+		chunked:  chunked,
+		replies:  make(map[string]string),
+		config:   cfg,
+		listener: listener,
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				defer conn.Close()
+				s.handle(conn)
+			}()
+		}
+	}()
+
+	t.Cleanup(func() {
+		listener.Close()
+		s.wg.Wait()
+	})
+
+	return s
+}
+
+func (s *server) connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conns
+}
+
+func (s *server) received() []*node {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rpcs
+}
+
+func (s *server) handle(conn net.Conn) {
+	sshConn, chans, reqs, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		return
+	}
+	defer sshConn.Close()
+	go ssh.DiscardRequests(reqs)
+
+	s.mu.Lock()
+	s.conns++
+	s.mu.Unlock()
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			//nolint:errcheck // Ignore errors in test server
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			for req := range requests {
+				// The subsystem name is prefixed with its length
+				ok := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "netconf"
+				//nolint:errcheck // Ignore errors in test server
+				req.Reply(ok, nil)
+				if ok {
+					go s.serve(channel)
+				}
+			}
+		}()
+	}
+}
+
+func (s *server) serve(channel ssh.Channel) {
+	defer channel.Close()
+
+	// Reuse the session framing of the plugin
+	sess := &session{writer: channel, reader: bufio.NewReader(channel)}
+
+	capabilities := []string{capBase10, capNotification, capXPath}
+	if s.chunked {
+		capabilities = append(capabilities, capBase11)
+	}
+	msg, err := xml.Marshal(&hello{Capabilities: capabilities, SessionID: 4711})
+	if err != nil {
+		return
+	}
+	if err := sess.send(msg); err != nil {
+		return
+	}
+	if _, err := sess.receive(); err != nil {
+		return
+	}
+	sess.chunked = s.chunked
+
+	for {
+		buf, err := sess.receive()
+		if err != nil {
+			return
+		}
+		var rpc node
+		if err := xml.Unmarshal(buf, &rpc); err != nil || len(rpc.Children) == 0 {
+			return
+		}
+		s.mu.Lock()
+		s.rpcs = append(s.rpcs, &rpc)
+		s.mu.Unlock()
+
+		reply := `<rpc-reply message-id="` + rpc.attr("message-id") + `" xmlns="` + nsBase + `">` +
+			s.replies[rpc.Children[0].XMLName.Local] +
+			`</rpc-reply>`
+		if err := sess.send([]byte(reply)); err != nil {
+			return
+		}
+
+		if rpc.Children[0].XMLName.Local == "create-subscription" {
+			for _, n := range s.notifications {
+				if err := sess.send([]byte(n)); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
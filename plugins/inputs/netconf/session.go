@@ -0,0 +1,309 @@
+package netconf
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	nsBase         = "urn:ietf:params:xml:ns:netconf:base:1.0"
+	nsNotification = "urn:ietf:params:xml:ns:netconf:notification:1.0"
+
+	capBase10 = "urn:ietf:params:netconf:base:1.0"
+	capBase11 = "urn:ietf:params:netconf:base:1.1"
+
+	endOfMessage = "]]>]]>"
+)
+
+// session represents a NETCONF session over the SSH "netconf" subsystem
+// as defined in RFC 6242
+type session struct {
+	client       *ssh.Client
+	channel      *ssh.Session
+	writer       io.WriteCloser
+	reader       *bufio.Reader
+	maxSize      int
+	chunked      bool
+	capabilities []string
+	messageID    uint64
+
+	closeOnce sync.Once
+}
+
+// rpcError is returned if the device answered a request with an error
+type rpcError struct {
+	Type    string
+	Tag     string
+	Path    string
+	Message string
+}
+
+func (e *rpcError) Error() string {
+	msg := e.Tag
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if e.Path != "" {
+		msg += " (" + e.Path + ")"
+	}
+	return fmt.Sprintf("%s error %s", e.Type, msg)
+}
+
+type hello struct {
+	XMLName      xml.Name `xml:"urn:ietf:params:xml:ns:netconf:base:1.0 hello"`
+	Capabilities []string `xml:"capabilities>capability"`
+	SessionID    uint64   `xml:"session-id,omitempty"`
+}
+
+func newSession(address string, cfg *ssh.ClientConfig, timeout time.Duration, maxSize int) (*session, error) {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	// Limit the time for the SSH handshake and the NETCONF hello exchange
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, address, cfg)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("SSH handshake failed: %w", err)
+	}
+	client := ssh.NewClient(sshConn, chans, reqs)
+
+	channel, err := client.NewSession()
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("opening SSH session failed: %w", err)
+	}
+	writer, err := channel.StdinPipe()
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("getting SSH input stream failed: %w", err)
+	}
+	reader, err := channel.StdoutPipe()
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("getting SSH output stream failed: %w", err)
+	}
+	if err := channel.RequestSubsystem("netconf"); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("requesting netconf subsystem failed: %w", err)
+	}
+
+	s := &session{
+		client:  client,
+		channel: channel,
+		writer:  writer,
+		reader:  bufio.NewReader(reader),
+		maxSize: maxSize,
+	}
+	if err := s.exchangeHello(); err != nil {
+		s.close()
+		return nil, fmt.Errorf("hello exchange failed: %w", err)
+	}
+
+	// Remove the deadline for the session as notifications might arrive at
+	// any later point in time
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		s.close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *session) exchangeHello() error {
+	// Send our hello message advertising both framing versions
+	msg, err := xml.Marshal(&hello{Capabilities: []string{capBase10, capBase11}})
+	if err != nil {
+		return err
+	}
+	if err := s.send(msg); err != nil {
+		return err
+	}
+
+	// Receive the server's hello which is always end-of-message framed
+	buf, err := s.receive()
+	if err != nil {
+		return err
+	}
+	var h hello
+	if err := xml.Unmarshal(buf, &h); err != nil {
+		return fmt.Errorf("decoding server hello failed: %w", err)
+	}
+	if h.SessionID == 0 {
+		return errors.New("server hello does not contain a session-id")
+	}
+	for i, c := range h.Capabilities {
+		h.Capabilities[i] = strings.TrimSpace(c)
+	}
+	s.capabilities = h.Capabilities
+
+	// Use the chunked framing if both sides support NETCONF 1.1, see
+	// RFC 6242 section 4.1
+	for _, c := range s.capabilities {
+		if c == capBase11 {
+			s.chunked = true
+			break
+		}
+	}
+
+	return nil
+}
+
+func (s *session) hasCapability(capability string) bool {
+	for _, c := range s.capabilities {
+		if c == capability || strings.HasPrefix(c, capability+"?") {
+			return true
+		}
+	}
+	return false
+}
+
+// rpc sends the given operation and waits for the reply. The returned node
+// is the reply element.
+func (s *session) rpc(operation string, timeout time.Duration) (*node, error) {
+	s.messageID++
+	id := strconv.FormatUint(s.messageID, 10)
+
+	msg := `<rpc message-id="` + id + `" xmlns="` + nsBase + `">` + operation + `</rpc>`
+
+	// Close the session if the device does not answer in time to unblock
+	// the reader. The session is unusable afterwards anyway.
+	timer := time.AfterFunc(timeout, s.close)
+	defer timer.Stop()
+
+	if err := s.send([]byte(msg)); err != nil {
+		return nil, err
+	}
+
+	for {
+		buf, err := s.receive()
+		if err != nil {
+			return nil, err
+		}
+		var reply node
+		if err := xml.Unmarshal(buf, &reply); err != nil {
+			return nil, fmt.Errorf("decoding reply failed: %w", err)
+		}
+
+		// Skip notifications arriving in between
+		if reply.XMLName.Local != "rpc-reply" {
+			continue
+		}
+		if replyID := reply.attr("message-id"); replyID != id {
+			return nil, fmt.Errorf("unexpected message-id %q in reply, expected %q", replyID, id)
+		}
+
+		for _, e := range reply.Children {
+			if e.XMLName.Local != "rpc-error" {
+				continue
+			}
+			if e.childText("error-severity") == "warning" {
+				continue
+			}
+			return nil, &rpcError{
+				Type:    e.childText("error-type"),
+				Tag:     e.childText("error-tag"),
+				Path:    e.childText("error-path"),
+				Message: e.childText("error-message"),
+			}
+		}
+		return &reply, nil
+	}
+}
+
+func (s *session) send(msg []byte) error {
+	var buf bytes.Buffer
+	if s.chunked {
+		buf.Grow(len(msg) + 16)
+		buf.WriteString("\n#" + strconv.Itoa(len(msg)) + "\n")
+		buf.Write(msg)
+		buf.WriteString("\n##\n")
+	} else {
+		buf.Grow(len(msg) + len(endOfMessage))
+		buf.Write(msg)
+		buf.WriteString(endOfMessage)
+	}
+	_, err := s.writer.Write(buf.Bytes())
+	return err
+}
+
+func (s *session) receive() ([]byte, error) {
+	if s.chunked {
+		return s.receiveChunked()
+	}
+
+	var msg []byte
+	for {
+		part, err := s.reader.ReadSlice('>')
+		msg = append(msg, part...)
+		if err != nil && !errors.Is(err, bufio.ErrBufferFull) {
+			return nil, err
+		}
+		if bytes.HasSuffix(msg, []byte(endOfMessage)) {
+			return msg[:len(msg)-len(endOfMessage)], nil
+		}
+		if s.maxSize > 0 && len(msg) > s.maxSize {
+			return nil, fmt.Errorf("message exceeds maximum size of %d bytes", s.maxSize)
+		}
+	}
+}
+
+func (s *session) receiveChunked() ([]byte, error) {
+	var msg []byte
+	for {
+		// Each chunk starts with a "\n#" sequence
+		var marker [2]byte
+		if _, err := io.ReadFull(s.reader, marker[:]); err != nil {
+			return nil, err
+		}
+		if marker != [2]byte{'\n', '#'} {
+			return nil, fmt.Errorf("invalid chunk start %q", marker[:])
+		}
+
+		// Read the chunk size or the end-of-chunks marker
+		line, err := s.reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "#" {
+			return msg, nil
+		}
+		size, err := strconv.ParseUint(line, 10, 32)
+		if err != nil || size == 0 {
+			return nil, fmt.Errorf("invalid chunk size %q", line)
+		}
+		if s.maxSize > 0 && len(msg)+int(size) > s.maxSize {
+			return nil, fmt.Errorf("message exceeds maximum size of %d bytes", s.maxSize)
+		}
+
+		start := len(msg)
+		msg = append(msg, make([]byte, size)...)
+		if _, err := io.ReadFull(s.reader, msg[start:]); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (s *session) close() {
+	s.closeOnce.Do(func() {
+		s.channel.Close()
+		s.client.Close()
+	})
+}
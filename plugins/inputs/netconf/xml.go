@@ -0,0 +1,244 @@
+package netconf
+
+import (
+	"encoding/xml"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/common/yangmodel"
+)
+
+// node is a generic representation of an XML element
+type node struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Content  string     `xml:",chardata"`
+	Children []*node    `xml:",any"`
+}
+
+func (n *node) attr(name string) string {
+	for _, a := range n.Attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func (n *node) child(name string) *node {
+	for _, c := range n.Children {
+		if c.XMLName.Local == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func (n *node) childText(name string) string {
+	if c := n.child(name); c != nil {
+		return strings.TrimSpace(c.Content)
+	}
+	return ""
+}
+
+func (n *node) isLeaf() bool {
+	return len(n.Children) == 0
+}
+
+// converter turns XML data trees into metrics using the element path
+// relative to the base path as field name and list keys as tags
+type converter struct {
+	keyNames      map[string]bool
+	tagPathPrefix bool
+	decoder       *yangmodel.Decoder
+	log           telegraf.Logger
+}
+
+func (c *converter) convert(grouper *metric.SeriesGrouper, name, base string, elements []*node, tags map[string]string, ts time.Time) {
+	for _, e := range elements {
+		c.walk(grouper, name, base, e, "", tags, ts)
+	}
+}
+
+func (c *converter) walk(grouper *metric.SeriesGrouper, name, base string, n *node, parent string, parentTags map[string]string, ts time.Time) {
+	path := parent + "/" + n.XMLName.Local
+
+	if n.isLeaf() {
+		value := strings.TrimSpace(n.Content)
+		if value == "" {
+			// Empty containers or leafs of type "empty" do not carry a value
+			return
+		}
+		grouper.Add(name, parentTags, ts, fieldName(base, path), c.decode(n, value))
+		return
+	}
+
+	// Add the list keys of the element as tags
+	tags := parentTags
+	keys := make(map[*node]bool)
+	for _, child := range n.Children {
+		if !child.isLeaf() || !c.keyNames[child.XMLName.Local] {
+			continue
+		}
+		keys[child] = true
+		if len(keys) == 1 {
+			tags = make(map[string]string, len(parentTags)+1)
+			for k, v := range parentTags {
+				tags[k] = v
+			}
+		}
+
+		key := child.XMLName.Local
+		if c.tagPathPrefix {
+			key = n.XMLName.Local + "_" + key
+		}
+		key = strings.ReplaceAll(key, "-", "_")
+		value := strings.TrimSpace(child.Content)
+
+		// Use short-form of key if possible
+		if v, exists := tags[key]; exists && v != value {
+			key = path + "/" + key
+		}
+		tags[key] = value
+	}
+
+	for _, child := range n.Children {
+		if keys[child] {
+			continue
+		}
+		c.walk(grouper, name, base, child, path, tags, ts)
+	}
+}
+
+func (c *converter) decode(n *node, value string) interface{} {
+	// Use the YANG model if available to determine the type of the leaf
+	if c.decoder != nil {
+		if module, found := c.decoder.ModuleName(n.XMLName.Space); found {
+			v, err := c.decoder.DecodeLeafElement(module, n.XMLName.Local, value)
+			if err == nil {
+				return v
+			}
+			if !errors.Is(err, yangmodel.ErrNotFound) {
+				c.log.Debugf("Decoding %s:%s failed: %v", module, n.XMLName.Local, err)
+			}
+		}
+	}
+
+	// Guess the type of the value otherwise
+	if v, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return v
+	}
+	if v, err := strconv.ParseUint(value, 10, 64); err == nil {
+		return v
+	}
+	if strings.ContainsAny(value[:1], "+-.0123456789") {
+		if v, err := strconv.ParseFloat(value, 64); err == nil {
+			return v
+		}
+	}
+	switch value {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	return value
+}
+
+// fieldName returns the path relative to the base path or the last element of
+// the path if the path is not a child of the base path
+func fieldName(base, path string) string {
+	var key string
+	switch {
+	case base == "/":
+		key = strings.TrimPrefix(path, "/")
+	case strings.HasPrefix(path, base+"/"):
+		key = strings.TrimPrefix(path, base+"/")
+	default:
+		key = path[strings.LastIndex(path, "/")+1:]
+	}
+	return strings.ReplaceAll(key, "-", "_")
+}
+
+// xpathBase returns the element path selected by the given XPath expression
+// stripped from predicates and namespace prefixes. For expressions not
+// selecting a single path, the root is returned.
+func xpathBase(expr string) string {
+	if strings.Contains(expr, "|") || strings.Contains(expr, "//") {
+		return "/"
+	}
+
+	// Remove all predicates, note predicates might be nested or contain
+	// quoted brackets
+	var path strings.Builder
+	var depth int
+	var quote rune
+	for _, r := range expr {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case depth > 0 && (r == '\'' || r == '"'):
+			quote = r
+		case r == '[':
+			depth++
+		case r == ']':
+			depth--
+		case depth == 0:
+			path.WriteRune(r)
+		}
+	}
+
+	elements := make([]string, 0, strings.Count(path.String(), "/"))
+	for _, e := range strings.Split(path.String(), "/") {
+		if e == "" {
+			continue
+		}
+		if _, local, found := strings.Cut(e, ":"); found {
+			e = local
+		}
+		// Stop at wildcards, axes or functions
+		if e == "*" || e == "." || e == ".." || strings.ContainsAny(e, "()@") {
+			break
+		}
+		elements = append(elements, e)
+	}
+	return "/" + strings.Join(elements, "/")
+}
+
+// subtreeBase returns the element path selected by the given subtree filter by
+// following the selection and containment nodes as long as there is only
+// one of them on each level.
+func subtreeBase(subtree string) (string, error) {
+	var root node
+	if err := xml.Unmarshal([]byte("<filter>"+subtree+"</filter>"), &root); err != nil {
+		return "", err
+	}
+
+	var elements []string
+	current := &root
+	for {
+		// Content match nodes are used to select list entries, so we ignore
+		// them when determining the selected path
+		var next *node
+		var count int
+		for _, c := range current.Children {
+			if c.isLeaf() && strings.TrimSpace(c.Content) != "" {
+				continue
+			}
+			next = c
+			count++
+		}
+		if count != 1 {
+			break
+		}
+		elements = append(elements, next.XMLName.Local)
+		current = next
+	}
+	return "/" + strings.Join(elements, "/"), nil
+}
@@ -6,8 +6,11 @@ import (
 
 	"github.com/influxdata/influxdb-observability/common"
 	"github.com/influxdata/influxdb-observability/otel2influx"
+	"go.opentelemetry.io/collector/pdata/plog"
 	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+	"go.opentelemetry.io/collector/pdata/pmetric"
 	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+	"go.opentelemetry.io/collector/pdata/ptrace"
 	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
 )
 
@@ -33,9 +36,41 @@ func newTraceService(logger common.Logger, writer *writeToAccumulator, spanDimen
 }
 
 // Export processes and exports the trace data received in the request.
+// If the conversion of the request fails, the spans are converted one by one
+// and the failing spans are reported as rejected in the response.
 func (s *traceService) Export(ctx context.Context, req ptraceotlp.ExportRequest) (ptraceotlp.ExportResponse, error) {
-	err := s.exporter.WriteTraces(ctx, req.Traces())
-	return ptraceotlp.NewExportResponse(), err
+	if err := s.exporter.WriteTraces(ctx, req.Traces()); err == nil {
+		return ptraceotlp.NewExportResponse(), nil
+	}
+
+	var rejected int64
+	var lastErr error
+	resourceSpans := req.Traces().ResourceSpans()
+	for i := 0; i < resourceSpans.Len(); i++ {
+		rs := resourceSpans.At(i)
+		for j := 0; j < rs.ScopeSpans().Len(); j++ {
+			ss := rs.ScopeSpans().At(j)
+			for k := 0; k < ss.Spans().Len(); k++ {
+				td := ptrace.NewTraces()
+				nrs := td.ResourceSpans().AppendEmpty()
+				rs.Resource().CopyTo(nrs.Resource())
+				nss := nrs.ScopeSpans().AppendEmpty()
+				ss.Scope().CopyTo(nss.Scope())
+				ss.Spans().At(k).CopyTo(nss.Spans().AppendEmpty())
+				if err := s.exporter.WriteTraces(ctx, td); err != nil {
+					rejected++
+					lastErr = err
+				}
+			}
+		}
+	}
+
+	resp := ptraceotlp.NewExportResponse()
+	if rejected > 0 {
+		resp.PartialSuccess().SetRejectedSpans(rejected)
+		resp.PartialSuccess().SetErrorMessage(lastErr.Error())
+	}
+	return resp, nil
 }
 
 type metricsService struct {
@@ -70,9 +105,42 @@ func newMetricsService(logger common.Logger, writer *writeToAccumulator, schema
 }
 
 // Export processes and exports the metrics data received in the request.
+// If the conversion of the request fails, the metrics are converted one by
+// one and the data points of the failing metrics are reported as rejected in
+// the response.
 func (s *metricsService) Export(ctx context.Context, req pmetricotlp.ExportRequest) (pmetricotlp.ExportResponse, error) {
-	err := s.exporter.WriteMetrics(ctx, req.Metrics())
-	return pmetricotlp.NewExportResponse(), err
+	if err := s.exporter.WriteMetrics(ctx, req.Metrics()); err == nil {
+		return pmetricotlp.NewExportResponse(), nil
+	}
+
+	var rejected int64
+	var lastErr error
+	resourceMetrics := req.Metrics().ResourceMetrics()
+	for i := 0; i < resourceMetrics.Len(); i++ {
+		rm := resourceMetrics.At(i)
+		for j := 0; j < rm.ScopeMetrics().Len(); j++ {
+			sm := rm.ScopeMetrics().At(j)
+			for k := 0; k < sm.Metrics().Len(); k++ {
+				md := pmetric.NewMetrics()
+				nrm := md.ResourceMetrics().AppendEmpty()
+				rm.Resource().CopyTo(nrm.Resource())
+				nsm := nrm.ScopeMetrics().AppendEmpty()
+				sm.Scope().CopyTo(nsm.Scope())
+				sm.Metrics().At(k).CopyTo(nsm.Metrics().AppendEmpty())
+				if err := s.exporter.WriteMetrics(ctx, md); err != nil {
+					rejected += int64(md.DataPointCount())
+					lastErr = err
+				}
+			}
+		}
+	}
+
+	resp := pmetricotlp.NewExportResponse()
+	if rejected > 0 {
+		resp.PartialSuccess().SetRejectedDataPoints(rejected)
+		resp.PartialSuccess().SetErrorMessage(lastErr.Error())
+	}
+	return resp, nil
 }
 
 type logsService struct {
@@ -97,7 +165,39 @@ func newLogsService(logger common.Logger, writer *writeToAccumulator, logRecordD
 }
 
 // Export processes and exports the logs data received in the request.
+// If the conversion of the request fails, the log records are converted one by
+// one and the failing records are reported as rejected in the response.
 func (s *logsService) Export(ctx context.Context, req plogotlp.ExportRequest) (plogotlp.ExportResponse, error) {
-	err := s.converter.WriteLogs(ctx, req.Logs())
-	return plogotlp.NewExportResponse(), err
+	if err := s.converter.WriteLogs(ctx, req.Logs()); err == nil {
+		return plogotlp.NewExportResponse(), nil
+	}
+
+	var rejected int64
+	var lastErr error
+	resourceLogs := req.Logs().ResourceLogs()
+	for i := 0; i < resourceLogs.Len(); i++ {
+		rl := resourceLogs.At(i)
+		for j := 0; j < rl.ScopeLogs().Len(); j++ {
+			sl := rl.ScopeLogs().At(j)
+			for k := 0; k < sl.LogRecords().Len(); k++ {
+				ld := plog.NewLogs()
+				nrl := ld.ResourceLogs().AppendEmpty()
+				rl.Resource().CopyTo(nrl.Resource())
+				nsl := nrl.ScopeLogs().AppendEmpty()
+				sl.Scope().CopyTo(nsl.Scope())
+				sl.LogRecords().At(k).CopyTo(nsl.LogRecords().AppendEmpty())
+				if err := s.converter.WriteLogs(ctx, ld); err != nil {
+					rejected++
+					lastErr = err
+				}
+			}
+		}
+	}
+
+	resp := plogotlp.NewExportResponse()
+	if rejected > 0 {
+		resp.PartialSuccess().SetRejectedLogRecords(rejected)
+		resp.PartialSuccess().SetErrorMessage(lastErr.Error())
+	}
+	return resp, nil
 }
@@ -0,0 +1,179 @@
+package opentelemetry
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/influxdata/telegraf"
+)
+
+const (
+	contentTypeProtobuf = "application/x-protobuf"
+	contentTypeJSON     = "application/json"
+
+	// Default limit for request bodies equal to the gRPC default
+	defaultMaxMsgSize = 4 * 1024 * 1024
+)
+
+// otlpRequest is implemented by all OTLP export request types
+type otlpRequest interface {
+	UnmarshalProto(data []byte) error
+	UnmarshalJSON(data []byte) error
+}
+
+// otlpResponse is implemented by all OTLP export response types
+type otlpResponse interface {
+	MarshalProto() ([]byte, error)
+	MarshalJSON() ([]byte, error)
+}
+
+// newHTTPHandler creates a handler serving the OTLP/HTTP endpoints using the
+// same services as the gRPC server
+func newHTTPHandler(traces *traceService, metrics *metricsService, logs *logsService, maxSize int64, log telegraf.Logger) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/v1/traces", handleExport(ptraceotlp.NewExportRequest, traces.Export, maxSize, log))
+	mux.Handle("/v1/metrics", handleExport(pmetricotlp.NewExportRequest, metrics.Export, maxSize, log))
+	mux.Handle("/v1/logs", handleExport(plogotlp.NewExportRequest, logs.Export, maxSize, log))
+	return mux
+}
+
+func handleExport[Req otlpRequest, Resp otlpResponse](
+	newRequest func() Req,
+	export func(context.Context, Req) (Resp, error),
+	maxSize int64,
+	log telegraf.Logger,
+) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			res.Header().Set("Allow", http.MethodPost)
+			http.Error(res, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		contentType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+		if err != nil || (contentType != contentTypeProtobuf && contentType != contentTypeJSON) {
+			http.Error(res, "unsupported content-type", http.StatusUnsupportedMediaType)
+			return
+		}
+
+		body, err := readBody(res, req, maxSize)
+		if err != nil {
+			var maxErr *http.MaxBytesError
+			switch {
+			case errors.As(err, &maxErr), errors.Is(err, errBodyTooLarge):
+				writeStatus(res, contentType, http.StatusRequestEntityTooLarge, codes.InvalidArgument, err, log)
+			case errors.Is(err, errUnsupportedEncoding):
+				writeStatus(res, contentType, http.StatusUnsupportedMediaType, codes.InvalidArgument, err, log)
+			default:
+				writeStatus(res, contentType, http.StatusBadRequest, codes.InvalidArgument, err, log)
+			}
+			return
+		}
+
+		request := newRequest()
+		if contentType == contentTypeJSON {
+			err = request.UnmarshalJSON(body)
+		} else {
+			err = request.UnmarshalProto(body)
+		}
+		if err != nil {
+			writeStatus(res, contentType, http.StatusBadRequest, codes.InvalidArgument, fmt.Errorf("decoding request failed: %w", err), log)
+			return
+		}
+
+		response, err := export(req.Context(), request)
+		if err != nil {
+			writeStatus(res, contentType, http.StatusInternalServerError, codes.Internal, err, log)
+			return
+		}
+
+		var buf []byte
+		if contentType == contentTypeJSON {
+			buf, err = response.MarshalJSON()
+		} else {
+			buf, err = response.MarshalProto()
+		}
+		if err != nil {
+			writeStatus(res, contentType, http.StatusInternalServerError, codes.Internal, fmt.Errorf("encoding response failed: %w", err), log)
+			return
+		}
+
+		res.Header().Set("Content-Type", contentType)
+		res.WriteHeader(http.StatusOK)
+		if _, err := res.Write(buf); err != nil {
+			log.Debugf("Writing response failed: %v", err)
+		}
+	}
+}
+
+var (
+	errBodyTooLarge        = errors.New("request body too large")
+	errUnsupportedEncoding = errors.New("unsupported content-encoding")
+)
+
+// readBody reads the potentially compressed request body limiting the
+// compressed as well as the uncompressed size
+func readBody(res http.ResponseWriter, req *http.Request, maxSize int64) ([]byte, error) {
+	body := http.MaxBytesReader(res, req.Body, maxSize)
+	defer body.Close()
+
+	var reader io.Reader = body
+	switch req.Header.Get("Content-Encoding") {
+	case "", "identity":
+	case "gzip":
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, fmt.Errorf("creating gzip reader failed: %w", err)
+		}
+		defer gz.Close()
+		reader = gz
+	default:
+		return nil, errUnsupportedEncoding
+	}
+
+	buf, err := io.ReadAll(io.LimitReader(reader, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(buf)) > maxSize {
+		return nil, errBodyTooLarge
+	}
+	return buf, nil
+}
+
+// writeStatus responds with a google.rpc.Status message encoded according to
+// the request's content-type as required by the OTLP specification
+func writeStatus(res http.ResponseWriter, contentType string, httpCode int, code codes.Code, reason error, log telegraf.Logger) {
+	msg := status.New(code, reason.Error()).Proto()
+
+	var buf []byte
+	var err error
+	if contentType == contentTypeJSON {
+		buf, err = protojson.Marshal(msg)
+	} else {
+		buf, err = proto.Marshal(msg)
+	}
+	if err != nil {
+		http.Error(res, reason.Error(), httpCode)
+		return
+	}
+
+	res.Header().Set("Content-Type", contentType)
+	res.WriteHeader(httpCode)
+	if _, err := res.Write(buf); err != nil {
+		log.Debugf("Writing response failed: %v", err)
+	}
+}
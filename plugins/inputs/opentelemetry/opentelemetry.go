@@ -2,9 +2,12 @@
 package opentelemetry
 
 import (
+	"crypto/tls"
 	_ "embed"
+	"errors"
 	"fmt"
 	"net"
+	"net/http"
 	"sync"
 	"time"
 
@@ -18,7 +21,7 @@ import (
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/config"
-	"github.com/influxdata/telegraf/plugins/common/tls"
+	common_tls "github.com/influxdata/telegraf/plugins/common/tls"
 	"github.com/influxdata/telegraf/plugins/inputs"
 )
 
@@ -27,6 +30,7 @@ var sampleConfig string
 
 type OpenTelemetry struct {
 	ServiceAddress      string          `toml:"service_address"`
+	HTTPServiceAddress  string          `toml:"http_service_address"`
 	SpanDimensions      []string        `toml:"span_dimensions"`
 	LogRecordDimensions []string        `toml:"log_record_dimensions"`
 	ProfileDimensions   []string        `toml:"profile_dimensions"`
@@ -34,10 +38,12 @@ type OpenTelemetry struct {
 	MaxMsgSize          config.Size     `toml:"max_msg_size"`
 	Timeout             config.Duration `toml:"timeout"`
 	Log                 telegraf.Logger `toml:"-"`
-	tls.ServerConfig
+	common_tls.ServerConfig
 
-	listener   net.Listener // overridden in tests
-	grpcServer *grpc.Server
+	listener     net.Listener // overridden in tests
+	grpcServer   *grpc.Server
+	httpListener net.Listener
+	httpServer   *http.Server
 
 	wg sync.WaitGroup
 }
@@ -62,10 +68,13 @@ func (o *OpenTelemetry) Init() error {
 }
 
 func (o *OpenTelemetry) Start(acc telegraf.Accumulator) error {
-	var grpcOptions []grpc.ServerOption
-	if tlsConfig, err := o.ServerConfig.TLSConfig(); err != nil {
+	tlsConfig, err := o.ServerConfig.TLSConfig()
+	if err != nil {
 		return err
-	} else if tlsConfig != nil {
+	}
+
+	var grpcOptions []grpc.ServerOption
+	if tlsConfig != nil {
 		grpcOptions = append(grpcOptions, grpc.Creds(credentials.NewTLS(tlsConfig)))
 	}
 	if o.Timeout > 0 {
@@ -116,6 +125,38 @@ func (o *OpenTelemetry) Start(acc telegraf.Accumulator) error {
 		}
 	}()
 
+	if o.HTTPServiceAddress == "" {
+		return nil
+	}
+
+	maxSize := int64(defaultMaxMsgSize)
+	if o.MaxMsgSize > 0 {
+		maxSize = int64(o.MaxMsgSize)
+	}
+	o.httpServer = &http.Server{
+		Handler:           newHTTPHandler(traceSvc, metricsSvc, logsSvc, maxSize, o.Log),
+		ReadHeaderTimeout: time.Duration(o.Timeout),
+		ReadTimeout:       time.Duration(o.Timeout),
+		WriteTimeout:      time.Duration(o.Timeout),
+	}
+
+	o.httpListener, err = net.Listen("tcp", o.HTTPServiceAddress)
+	if err != nil {
+		o.Stop()
+		return err
+	}
+	if tlsConfig != nil {
+		o.httpListener = tls.NewListener(o.httpListener, tlsConfig)
+	}
+
+	o.wg.Add(1)
+	go func() {
+		defer o.wg.Done()
+		if err := o.httpServer.Serve(o.httpListener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			acc.AddError(fmt.Errorf("failed to stop OpenTelemetry HTTP service: %w", err))
+		}
+	}()
+
 	return nil
 }
 
@@ -129,6 +170,13 @@ func (o *OpenTelemetry) Stop() {
 	}
 	o.listener = nil
 
+	if o.httpServer != nil {
+		if err := o.httpServer.Close(); err != nil {
+			o.Log.Errorf("Closing HTTP service failed: %v", err)
+		}
+	}
+	o.httpListener = nil
+
 	o.wg.Wait()
 }
 
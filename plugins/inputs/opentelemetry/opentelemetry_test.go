@@ -1,8 +1,12 @@
 package opentelemetry
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"io"
 	"net"
+	"net/http"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -14,19 +18,27 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/influxdata/influxdb-observability/otel2influx"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
-	"go.opentelemetry.io/otel/sdk/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/metric/metricdata"
 	otlplogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
 	otlpmetrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
 	otlpprofiles "go.opentelemetry.io/proto/otlp/collector/profiles/v1experimental"
 	otlptrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/protobuf/encoding/protojson"
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
 	"github.com/influxdata/telegraf/plugins/inputs"
 	"github.com/influxdata/telegraf/plugins/parsers/influx"
 	"github.com/influxdata/telegraf/testutil"
@@ -58,10 +70,10 @@ func TestOpenTelemetry(t *testing.T) {
 	defer exporter.Shutdown(ctx) //nolint:errcheck // We cannot do anything if the shutdown fails
 
 	// Setup the metric to send
-	reader := metric.NewManualReader()
+	reader := sdkmetric.NewManualReader()
 	defer reader.Shutdown(ctx) //nolint:errcheck // We cannot do anything if the shutdown fails
 
-	provider := metric.NewMeterProvider(metric.WithReader(reader))
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
 	meter := provider.Meter("library-name")
 	counter, err := meter.Int64Counter("measurement-counter")
 	require.NoError(t, err)
@@ -254,3 +266,204 @@ func TestCases(t *testing.T) {
 		})
 	}
 }
+
+func TestHTTPMetrics(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		compress    bool
+	}{
+		{
+			name:        "protobuf",
+			contentType: "application/x-protobuf",
+		},
+		{
+			name:        "json",
+			contentType: "application/json",
+		},
+		{
+			name:        "protobuf gzip",
+			contentType: "application/x-protobuf",
+			compress:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup and start the plugin
+			plugin := &OpenTelemetry{
+				ServiceAddress:     "127.0.0.1:0",
+				HTTPServiceAddress: "127.0.0.1:0",
+				MetricsSchema:      "prometheus-v1",
+				Log:                &testutil.Logger{},
+			}
+			require.NoError(t, plugin.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, plugin.Start(&acc))
+			defer plugin.Stop()
+
+			// Create the request
+			md := pmetric.NewMetrics()
+			rm := md.ResourceMetrics().AppendEmpty()
+			rm.Resource().Attributes().PutStr("service.name", "test")
+			m := rm.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+			m.SetName("cpu_temp")
+			dp := m.SetEmptyGauge().DataPoints().AppendEmpty()
+			dp.SetDoubleValue(87.332)
+			dp.SetTimestamp(pcommon.NewTimestampFromTime(time.Unix(1700000000, 0)))
+			request := pmetricotlp.NewExportRequestFromMetrics(md)
+
+			var body []byte
+			var err error
+			if tt.contentType == "application/json" {
+				body, err = request.MarshalJSON()
+			} else {
+				body, err = request.MarshalProto()
+			}
+			require.NoError(t, err)
+
+			resp := postHTTP(t, "http://"+plugin.httpListener.Addr().String()+"/v1/metrics", tt.contentType, body, tt.compress)
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Equal(t, tt.contentType, resp.Header.Get("Content-Type"))
+
+			buf, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			response := pmetricotlp.NewExportResponse()
+			if tt.contentType == "application/json" {
+				require.NoError(t, response.UnmarshalJSON(buf))
+			} else {
+				require.NoError(t, response.UnmarshalProto(buf))
+			}
+			require.Zero(t, response.PartialSuccess().RejectedDataPoints())
+
+			expected := []telegraf.Metric{
+				metric.New(
+					"cpu_temp",
+					map[string]string{"service.name": "test"},
+					map[string]interface{}{"gauge": 87.332},
+					time.Unix(1700000000, 0),
+					telegraf.Gauge,
+				),
+			}
+			testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics())
+		})
+	}
+}
+
+func TestHTTPPartialSuccess(t *testing.T) {
+	// Setup and start the plugin
+	plugin := &OpenTelemetry{
+		ServiceAddress:     "127.0.0.1:0",
+		HTTPServiceAddress: "127.0.0.1:0",
+		SpanDimensions:     otel2influx.DefaultOtelTracesToLineProtocolConfig().SpanDimensions,
+		Log:                &testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	// Create a request with one valid span and one span without trace ID
+	td := ptrace.NewTraces()
+	spans := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans()
+	valid := spans.AppendEmpty()
+	valid.SetName("valid")
+	valid.SetTraceID(pcommon.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16})
+	valid.SetSpanID(pcommon.SpanID{1, 2, 3, 4, 5, 6, 7, 8})
+	valid.SetStartTimestamp(pcommon.NewTimestampFromTime(time.Unix(1700000000, 0)))
+	valid.SetEndTimestamp(pcommon.NewTimestampFromTime(time.Unix(1700000001, 0)))
+	invalid := spans.AppendEmpty()
+	invalid.SetName("invalid")
+	invalid.SetSpanID(pcommon.SpanID{8, 7, 6, 5, 4, 3, 2, 1})
+	invalid.SetStartTimestamp(pcommon.NewTimestampFromTime(time.Unix(1700000000, 0)))
+
+	body, err := ptraceotlp.NewExportRequestFromTraces(td).MarshalProto()
+	require.NoError(t, err)
+
+	resp := postHTTP(t, "http://"+plugin.httpListener.Addr().String()+"/v1/traces", "application/x-protobuf", body, true)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	buf, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	response := ptraceotlp.NewExportResponse()
+	require.NoError(t, response.UnmarshalProto(buf))
+	require.Equal(t, int64(1), response.PartialSuccess().RejectedSpans())
+	require.Contains(t, response.PartialSuccess().ErrorMessage(), "span has no trace ID")
+
+	// The valid span must still be accepted
+	require.Equal(t, uint64(1), acc.NMetrics())
+	m := acc.GetTelegrafMetrics()[0]
+	require.Equal(t, "spans", m.Name())
+	name, found := m.GetField("span.name")
+	require.True(t, found)
+	require.Equal(t, "valid", name)
+}
+
+func TestHTTPInvalidRequests(t *testing.T) {
+	// Setup and start the plugin
+	plugin := &OpenTelemetry{
+		ServiceAddress:     "127.0.0.1:0",
+		HTTPServiceAddress: "127.0.0.1:0",
+		MaxMsgSize:         config.Size(64),
+		Log:                &testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	addr := "http://" + plugin.httpListener.Addr().String()
+
+	// Unsupported content-type
+	resp := postHTTP(t, addr+"/v1/logs", "text/plain", []byte("foo"), false)
+	require.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+
+	// Invalid body
+	resp = postHTTP(t, addr+"/v1/logs", "application/json", []byte("{foo"), false)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	buf, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	var msg spb.Status
+	require.NoError(t, protojson.Unmarshal(buf, &msg))
+	require.Equal(t, int32(codes.InvalidArgument), msg.GetCode())
+
+	// Body exceeding the limit after decompression
+	resp = postHTTP(t, addr+"/v1/logs", "application/json", bytes.Repeat([]byte(" "), 1024), true)
+	require.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+
+	// Wrong method
+	r, err := http.Get(addr + "/v1/logs")
+	require.NoError(t, err)
+	r.Body.Close()
+	require.Equal(t, http.StatusMethodNotAllowed, r.StatusCode)
+
+	require.Empty(t, acc.GetTelegrafMetrics())
+}
+
+func postHTTP(t *testing.T, url, contentType string, body []byte, compress bool) *http.Response {
+	t.Helper()
+
+	if compress {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		_, err := gz.Write(body)
+		require.NoError(t, err)
+		require.NoError(t, gz.Close())
+		body = buf.Bytes()
+	}
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodPost, url, bytes.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", contentType)
+	if compress {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
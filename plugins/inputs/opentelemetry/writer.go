@@ -13,7 +13,7 @@ import (
 
 var (
 	_ otel2influx.InfluxWriter      = (*writeToAccumulator)(nil)
-	_ otel2influx.InfluxWriterBatch = (*writeToAccumulator)(nil)
+	_ otel2influx.InfluxWriterBatch = (*accumulatorBatch)(nil)
 )
 
 type writeToAccumulator struct {
@@ -22,11 +22,27 @@ type writeToAccumulator struct {
 
 // NewBatch creates a new batch for writing telemetry data.
 func (w *writeToAccumulator) NewBatch() otel2influx.InfluxWriterBatch {
-	return w
+	return &accumulatorBatch{accumulator: w.accumulator}
 }
 
-// EnqueuePoint adds a telemetry data point to the accumulator.
-func (w *writeToAccumulator) EnqueuePoint(
+type point struct {
+	measurement string
+	tags        map[string]string
+	fields      map[string]interface{}
+	ts          time.Time
+	vType       common.InfluxMetricValueType
+}
+
+// accumulatorBatch collects the points of a batch and only adds them to the
+// accumulator when writing the batch, so no data is added if the conversion
+// of the batch fails.
+type accumulatorBatch struct {
+	accumulator telegraf.Accumulator
+	points      []point
+}
+
+// EnqueuePoint adds a telemetry data point to the batch.
+func (b *accumulatorBatch) EnqueuePoint(
 	_ context.Context,
 	measurement string,
 	tags map[string]string,
@@ -35,23 +51,34 @@ func (w *writeToAccumulator) EnqueuePoint(
 	vType common.InfluxMetricValueType,
 ) error {
 	switch vType {
-	case common.InfluxMetricValueTypeUntyped:
-		w.accumulator.AddFields(measurement, fields, tags, ts)
-	case common.InfluxMetricValueTypeGauge:
-		w.accumulator.AddGauge(measurement, fields, tags, ts)
-	case common.InfluxMetricValueTypeSum:
-		w.accumulator.AddCounter(measurement, fields, tags, ts)
-	case common.InfluxMetricValueTypeHistogram:
-		w.accumulator.AddHistogram(measurement, fields, tags, ts)
-	case common.InfluxMetricValueTypeSummary:
-		w.accumulator.AddSummary(measurement, fields, tags, ts)
+	case common.InfluxMetricValueTypeUntyped,
+		common.InfluxMetricValueTypeGauge,
+		common.InfluxMetricValueTypeSum,
+		common.InfluxMetricValueTypeHistogram,
+		common.InfluxMetricValueTypeSummary:
 	default:
 		return fmt.Errorf("unrecognized InfluxMetricValueType %q", vType)
 	}
+	b.points = append(b.points, point{measurement, tags, fields, ts, vType})
 	return nil
 }
 
-// WriteBatch does nothing.
-func (*writeToAccumulator) WriteBatch(context.Context) error {
+// WriteBatch adds the collected data points to the accumulator.
+func (b *accumulatorBatch) WriteBatch(context.Context) error {
+	for _, p := range b.points {
+		switch p.vType {
+		case common.InfluxMetricValueTypeUntyped:
+			b.accumulator.AddFields(p.measurement, p.fields, p.tags, p.ts)
+		case common.InfluxMetricValueTypeGauge:
+			b.accumulator.AddGauge(p.measurement, p.fields, p.tags, p.ts)
+		case common.InfluxMetricValueTypeSum:
+			b.accumulator.AddCounter(p.measurement, p.fields, p.tags, p.ts)
+		case common.InfluxMetricValueTypeHistogram:
+			b.accumulator.AddHistogram(p.measurement, p.fields, p.tags, p.ts)
+		case common.InfluxMetricValueTypeSummary:
+			b.accumulator.AddSummary(p.measurement, p.fields, p.tags, p.ts)
+		}
+	}
+	b.points = nil
 	return nil
 }
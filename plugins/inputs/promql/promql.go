@@ -0,0 +1,344 @@
+//go:generate ../../../tools/config_includer/generator
+//go:generate ../../../tools/readme_config_includer/generator
+package promql
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/common/model"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	common_http "github.com/influxdata/telegraf/plugins/common/http"
+	"github.com/influxdata/telegraf/plugins/inputs"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+type PromQL struct {
+	URL      string                    `toml:"url"`
+	Username config.Secret             `toml:"username"`
+	Password config.Secret             `toml:"password"`
+	Token    config.Secret             `toml:"token"`
+	Headers  map[string]*config.Secret `toml:"headers"`
+	Queries  []query                   `toml:"query"`
+	Log      telegraf.Logger           `toml:"-"`
+	common_http.HTTPClientConfig
+
+	client *http.Client
+}
+
+type query struct {
+	Name  string          `toml:"name"`
+	Query string          `toml:"query"`
+	Type  string          `toml:"type"`
+	Range config.Duration `toml:"range"`
+	Step  config.Duration `toml:"step"`
+	Field string          `toml:"field"`
+}
+
+// apiResponse is the envelope of all responses of the Prometheus HTTP API,
+// see https://prometheus.io/docs/prometheus/latest/querying/api/#format-overview
+type apiResponse struct {
+	Status    string   `json:"status"`
+	Data      apiData  `json:"data"`
+	ErrorType string   `json:"errorType"`
+	Error     string   `json:"error"`
+	Warnings  []string `json:"warnings"`
+}
+
+type apiData struct {
+	ResultType string          `json:"resultType"`
+	Result     json.RawMessage `json:"result"`
+}
+
+func (*PromQL) SampleConfig() string {
+	return sampleConfig
+}
+
+func (p *PromQL) Init() error {
+	if p.URL == "" {
+		return errors.New("'url' cannot be empty")
+	}
+	if _, err := url.Parse(p.URL); err != nil {
+		return fmt.Errorf("parsing URL failed: %w", err)
+	}
+	p.URL = strings.TrimSuffix(p.URL, "/")
+
+	if !p.Token.Empty() && !(p.Username.Empty() && p.Password.Empty()) {
+		return errors.New("either use 'token' or 'username' and 'password' not both")
+	}
+
+	if len(p.Queries) == 0 {
+		return errors.New("no queries configured")
+	}
+	for i := range p.Queries {
+		q := &p.Queries[i]
+		if q.Query == "" {
+			return fmt.Errorf("query #%d: 'query' cannot be empty", i+1)
+		}
+		if q.Field == "" {
+			q.Field = "value"
+		}
+		switch q.Type {
+		case "":
+			q.Type = "instant"
+		case "instant":
+		case "range":
+			if q.Range <= 0 {
+				return fmt.Errorf("query #%d: 'range' must be positive for range queries", i+1)
+			}
+			if q.Step <= 0 {
+				q.Step = config.Duration(time.Minute)
+			}
+		default:
+			return fmt.Errorf("query #%d: invalid type %q", i+1, q.Type)
+		}
+	}
+
+	client, err := p.HTTPClientConfig.CreateClient(context.Background(), p.Log)
+	if err != nil {
+		return err
+	}
+	p.client = client
+
+	return nil
+}
+
+func (p *PromQL) Gather(acc telegraf.Accumulator) error {
+	now := time.Now()
+
+	var wg sync.WaitGroup
+	for _, q := range p.Queries {
+		wg.Add(1)
+		go func(q query) {
+			defer wg.Done()
+			if err := p.execute(acc, q, now); err != nil {
+				acc.AddError(fmt.Errorf("query %q failed: %w", q.Query, err))
+			}
+		}(q)
+	}
+	wg.Wait()
+
+	return nil
+}
+
+func (p *PromQL) execute(acc telegraf.Accumulator, q query, now time.Time) error {
+	params := url.Values{}
+	params.Set("query", q.Query)
+
+	var endpoint string
+	switch q.Type {
+	case "instant":
+		endpoint = p.URL + "/api/v1/query"
+		params.Set("time", formatTime(now))
+	case "range":
+		endpoint = p.URL + "/api/v1/query_range"
+		params.Set("start", formatTime(now.Add(-time.Duration(q.Range))))
+		params.Set("end", formatTime(now))
+		params.Set("step", strconv.FormatFloat(time.Duration(q.Step).Seconds(), 'f', -1, 64))
+	}
+
+	data, err := p.request(endpoint, params)
+	if err != nil {
+		return err
+	}
+
+	switch data.ResultType {
+	case "vector":
+		var vector model.Vector
+		if err := json.Unmarshal(data.Result, &vector); err != nil {
+			return fmt.Errorf("decoding vector failed: %w", err)
+		}
+		for _, sample := range vector {
+			if sample.Histogram != nil {
+				p.Log.Debugf("Skipping native histogram sample of %s", sample.Metric)
+				continue
+			}
+			if !isFinite(sample.Value) {
+				p.Log.Debugf("Skipping non-finite sample %v of %s", sample.Value, sample.Metric)
+				continue
+			}
+			name, tags := q.identity(sample.Metric)
+			fields := map[string]interface{}{q.Field: float64(sample.Value)}
+			acc.AddFields(name, fields, tags, sample.Timestamp.Time())
+		}
+	case "matrix":
+		var matrix model.Matrix
+		if err := json.Unmarshal(data.Result, &matrix); err != nil {
+			return fmt.Errorf("decoding matrix failed: %w", err)
+		}
+		for _, stream := range matrix {
+			if len(stream.Histograms) > 0 {
+				p.Log.Debugf("Skipping %d native histogram samples of %s", len(stream.Histograms), stream.Metric)
+			}
+			name, tags := q.identity(stream.Metric)
+			for _, v := range stream.Values {
+				if !isFinite(v.Value) {
+					p.Log.Debugf("Skipping non-finite sample %v of %s", v.Value, stream.Metric)
+					continue
+				}
+				fields := map[string]interface{}{q.Field: float64(v.Value)}
+				acc.AddFields(name, fields, tags, v.Timestamp.Time())
+			}
+		}
+	case "scalar":
+		var scalar model.Scalar
+		if err := json.Unmarshal(data.Result, &scalar); err != nil {
+			return fmt.Errorf("decoding scalar failed: %w", err)
+		}
+		if !isFinite(scalar.Value) {
+			p.Log.Debugf("Skipping non-finite scalar %v", scalar.Value)
+			return nil
+		}
+		name, tags := q.identity(nil)
+		fields := map[string]interface{}{q.Field: float64(scalar.Value)}
+		acc.AddFields(name, fields, tags, scalar.Timestamp.Time())
+	case "string":
+		var str model.String
+		if err := json.Unmarshal(data.Result, &str); err != nil {
+			return fmt.Errorf("decoding string failed: %w", err)
+		}
+		name, tags := q.identity(nil)
+		fields := map[string]interface{}{q.Field: str.Value}
+		acc.AddFields(name, fields, tags, str.Timestamp.Time())
+	default:
+		return fmt.Errorf("unsupported result type %q", data.ResultType)
+	}
+
+	return nil
+}
+
+func isFinite(v model.SampleValue) bool {
+	return !math.IsNaN(float64(v)) && !math.IsInf(float64(v), 0)
+}
+
+func (p *PromQL) request(endpoint string, params url.Values) (*apiData, error) {
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	for k, v := range p.Headers {
+		secret, err := v.Get()
+		if err != nil {
+			return nil, fmt.Errorf("getting header %q failed: %w", k, err)
+		}
+		if strings.EqualFold(k, "host") {
+			req.Host = secret.String()
+		} else {
+			req.Header.Add(k, secret.String())
+		}
+		secret.Destroy()
+	}
+
+	if err := p.setRequestAuth(req); err != nil {
+		return nil, err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading body failed: %w", err)
+	}
+
+	// The API returns a JSON error envelope for most non-2xx responses, so try
+	// to decode it first to provide a meaningful error message.
+	var response apiResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		if resp.StatusCode/100 != 2 {
+			return nil, fmt.Errorf("received status code %d (%s)", resp.StatusCode, http.StatusText(resp.StatusCode))
+		}
+		return nil, fmt.Errorf("decoding response failed: %w", err)
+	}
+	if response.Status != "success" {
+		return nil, fmt.Errorf("received status code %d (%s): %s: %s",
+			resp.StatusCode, http.StatusText(resp.StatusCode), response.ErrorType, response.Error)
+	}
+	for _, w := range response.Warnings {
+		p.Log.Warnf("Query warning for %q: %s", params.Get("query"), w)
+	}
+
+	return &response.Data, nil
+}
+
+func (p *PromQL) setRequestAuth(req *http.Request) error {
+	if !p.Token.Empty() {
+		token, err := p.Token.Get()
+		if err != nil {
+			return fmt.Errorf("getting token failed: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(token.String()))
+		token.Destroy()
+		return nil
+	}
+
+	if p.Username.Empty() && p.Password.Empty() {
+		return nil
+	}
+
+	username, err := p.Username.Get()
+	if err != nil {
+		return fmt.Errorf("getting username failed: %w", err)
+	}
+	defer username.Destroy()
+
+	password, err := p.Password.Get()
+	if err != nil {
+		return fmt.Errorf("getting password failed: %w", err)
+	}
+	defer password.Destroy()
+
+	req.SetBasicAuth(username.String(), password.String())
+
+	return nil
+}
+
+// identity determines the metric name and tags for the given series labels
+func (q *query) identity(labels model.Metric) (string, map[string]string) {
+	name := q.Name
+	tags := make(map[string]string, len(labels))
+	for k, v := range labels {
+		if k == model.MetricNameLabel {
+			if name == "" {
+				name = string(v)
+			}
+			continue
+		}
+		tags[string(k)] = string(v)
+	}
+	if name == "" {
+		name = "promql"
+	}
+	return name, tags
+}
+
+func formatTime(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixMilli())/1000.0, 'f', -1, 64)
+}
+
+func init() {
+	inputs.Add("promql", func() telegraf.Input {
+		return &PromQL{}
+	})
+}
@@ -0,0 +1,342 @@
+package promql
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *PromQL
+		expected string
+	}{
+		{
+			name:     "no url",
+			plugin:   &PromQL{},
+			expected: "'url' cannot be empty",
+		},
+		{
+			name:     "no queries",
+			plugin:   &PromQL{URL: "http://localhost:9090"},
+			expected: "no queries configured",
+		},
+		{
+			name: "empty query",
+			plugin: &PromQL{
+				URL:     "http://localhost:9090",
+				Queries: []query{{Name: "foo"}},
+			},
+			expected: "query #1: 'query' cannot be empty",
+		},
+		{
+			name: "invalid type",
+			plugin: &PromQL{
+				URL:     "http://localhost:9090",
+				Queries: []query{{Query: "up", Type: "foo"}},
+			},
+			expected: `query #1: invalid type "foo"`,
+		},
+		{
+			name: "range without range",
+			plugin: &PromQL{
+				URL:     "http://localhost:9090",
+				Queries: []query{{Query: "up", Type: "range"}},
+			},
+			expected: "query #1: 'range' must be positive for range queries",
+		},
+		{
+			name: "token and basic auth",
+			plugin: &PromQL{
+				URL:      "http://localhost:9090",
+				Token:    config.NewSecret([]byte("token")),
+				Username: config.NewSecret([]byte("user")),
+				Queries:  []query{{Query: "up"}},
+			},
+			expected: "either use 'token' or 'username' and 'password' not both",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.plugin.Log = &testutil.Logger{}
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestResultTypes(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    query
+		response string
+		expected []telegraf.Metric
+	}{
+		{
+			name:  "vector",
+			query: query{Query: "up"},
+			response: `{"status":"success","data":{"resultType":"vector","result":[
+				{"metric":{"__name__":"up","job":"prometheus","instance":"localhost:9090"},"value":[1700000000,"1"]},
+				{"metric":{"__name__":"up","job":"node","instance":"localhost:9100"},"value":[1700000000,"0"]}
+			]}}`,
+			expected: []telegraf.Metric{
+				metric.New(
+					"up",
+					map[string]string{"job": "prometheus", "instance": "localhost:9090"},
+					map[string]interface{}{"value": float64(1)},
+					time.Unix(1700000000, 0),
+				),
+				metric.New(
+					"up",
+					map[string]string{"job": "node", "instance": "localhost:9100"},
+					map[string]interface{}{"value": float64(0)},
+					time.Unix(1700000000, 0),
+				),
+			},
+		},
+		{
+			name:  "vector with name and field",
+			query: query{Name: "slo", Query: "sum by (job) (rate(errors[5m]))", Field: "burn_rate"},
+			response: `{"status":"success","data":{"resultType":"vector","result":[
+				{"metric":{"job":"api"},"value":[1700000000.5,"0.25"]}
+			]}}`,
+			expected: []telegraf.Metric{
+				metric.New(
+					"slo",
+					map[string]string{"job": "api"},
+					map[string]interface{}{"burn_rate": float64(0.25)},
+					time.UnixMilli(1700000000500),
+				),
+			},
+		},
+		{
+			name:  "matrix",
+			query: query{Query: "up", Type: "range", Range: config.Duration(time.Minute), Step: config.Duration(30 * time.Second)},
+			response: `{"status":"success","data":{"resultType":"matrix","result":[
+				{"metric":{"__name__":"up","job":"node"},"values":[[1700000000,"1"],[1700000030,"0"]]}
+			]}}`,
+			expected: []telegraf.Metric{
+				metric.New(
+					"up",
+					map[string]string{"job": "node"},
+					map[string]interface{}{"value": float64(1)},
+					time.Unix(1700000000, 0),
+				),
+				metric.New(
+					"up",
+					map[string]string{"job": "node"},
+					map[string]interface{}{"value": float64(0)},
+					time.Unix(1700000030, 0),
+				),
+			},
+		},
+		{
+			name:  "matrix with native histograms",
+			query: query{Query: "latency", Type: "range", Range: config.Duration(time.Minute), Step: config.Duration(30 * time.Second)},
+			response: `{"status":"success","data":{"resultType":"matrix","result":[
+				{"metric":{"__name__":"latency","job":"api"},"histograms":[[1700000000,{"count":"2","sum":"1.5","buckets":[[0,"0","1","2"]]}]]},
+				{"metric":{"__name__":"latency","job":"node"},"values":[[1700000000,"3"]]}
+			]}}`,
+			expected: []telegraf.Metric{
+				metric.New(
+					"latency",
+					map[string]string{"job": "node"},
+					map[string]interface{}{"value": float64(3)},
+					time.Unix(1700000000, 0),
+				),
+			},
+		},
+		{
+			name:  "vector with non-finite values",
+			query: query{Query: "errors / requests"},
+			response: `{"status":"success","data":{"resultType":"vector","result":[
+				{"metric":{"job":"api"},"value":[1700000000,"NaN"]},
+				{"metric":{"job":"db"},"value":[1700000000,"+Inf"]},
+				{"metric":{"job":"web"},"value":[1700000000,"-Inf"]},
+				{"metric":{"job":"node"},"value":[1700000000,"0.5"]}
+			]}}`,
+			expected: []telegraf.Metric{
+				metric.New(
+					"promql",
+					map[string]string{"job": "node"},
+					map[string]interface{}{"value": float64(0.5)},
+					time.Unix(1700000000, 0),
+				),
+			},
+		},
+		{
+			name:  "matrix with non-finite values",
+			query: query{Query: "errors / requests", Type: "range", Range: config.Duration(time.Minute), Step: config.Duration(30 * time.Second)},
+			response: `{"status":"success","data":{"resultType":"matrix","result":[
+				{"metric":{"job":"api"},"values":[[1700000000,"NaN"],[1700000030,"2"],[1700000060,"+Inf"]]}
+			]}}`,
+			expected: []telegraf.Metric{
+				metric.New(
+					"promql",
+					map[string]string{"job": "api"},
+					map[string]interface{}{"value": float64(2)},
+					time.Unix(1700000030, 0),
+				),
+			},
+		},
+		{
+			name:     "scalar with non-finite value",
+			query:    query{Query: "scalar(errors / requests)"},
+			response: `{"status":"success","data":{"resultType":"scalar","result":[1700000000,"NaN"]}}`,
+		},
+		{
+			name:     "scalar",
+			query:    query{Query: "scalar(up)"},
+			response: `{"status":"success","data":{"resultType":"scalar","result":[1700000000,"42"]}}`,
+			expected: []telegraf.Metric{
+				metric.New(
+					"promql",
+					map[string]string{},
+					map[string]interface{}{"value": float64(42)},
+					time.Unix(1700000000, 0),
+				),
+			},
+		},
+		{
+			name:     "string",
+			query:    query{Name: "info", Query: `"foo"`},
+			response: `{"status":"success","data":{"resultType":"string","result":[1700000000,"foo"]}}`,
+			expected: []telegraf.Metric{
+				metric.New(
+					"info",
+					map[string]string{},
+					map[string]interface{}{"value": "foo"},
+					time.Unix(1700000000, 0),
+				),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := r.ParseForm(); err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				if r.Form.Get("query") != tt.query.Query {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				if _, err := w.Write([]byte(tt.response)); err != nil {
+					t.Error(err)
+				}
+			}))
+			defer server.Close()
+
+			plugin := &PromQL{
+				URL:     server.URL,
+				Queries: []query{tt.query},
+				Log:     &testutil.Logger{},
+			}
+			require.NoError(t, plugin.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, acc.GatherError(plugin.Gather))
+			testutil.RequireMetricsEqual(t, tt.expected, acc.GetTelegrafMetrics(), testutil.SortMetrics())
+		})
+	}
+}
+
+func TestRequestParameters(t *testing.T) {
+	var instantPath, rangePath string
+	var instantTime, rangeStart, rangeEnd, rangeStep string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch r.Form.Get("query") {
+		case "instant":
+			instantPath = r.URL.Path
+			instantTime = r.Form.Get("time")
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+		case "range":
+			rangePath = r.URL.Path
+			rangeStart = r.Form.Get("start")
+			rangeEnd = r.Form.Get("end")
+			rangeStep = r.Form.Get("step")
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[]}}`))
+		}
+	}))
+	defer server.Close()
+
+	plugin := &PromQL{
+		URL: server.URL + "/",
+		Queries: []query{
+			{Query: "instant"},
+			{Query: "range", Type: "range", Range: config.Duration(5 * time.Minute), Step: config.Duration(15 * time.Second)},
+		},
+		Log: &testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, acc.GatherError(plugin.Gather))
+
+	require.Equal(t, "/api/v1/query", instantPath)
+	require.NotEmpty(t, instantTime)
+	require.Equal(t, "/api/v1/query_range", rangePath)
+	require.NotEmpty(t, rangeStart)
+	require.Equal(t, instantTime, rangeEnd)
+	require.Equal(t, "15", rangeStep)
+}
+
+func TestAuthentication(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer mytoken" || r.Header.Get("X-Scope-OrgID") != "tenant1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"scalar","result":[1700000000,"1"]}}`))
+	}))
+	defer server.Close()
+
+	tenant := config.NewSecret([]byte("tenant1"))
+	plugin := &PromQL{
+		URL:     server.URL,
+		Token:   config.NewSecret([]byte("mytoken")),
+		Headers: map[string]*config.Secret{"X-Scope-OrgID": &tenant},
+		Queries: []query{{Query: "scalar(up)"}},
+		Log:     &testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, acc.GatherError(plugin.Gather))
+	require.Len(t, acc.GetTelegrafMetrics(), 1)
+}
+
+func TestErrorResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"status":"error","errorType":"bad_data","error":"parse error"}`))
+	}))
+	defer server.Close()
+
+	plugin := &PromQL{
+		URL:     server.URL,
+		Queries: []query{{Query: "up{"}},
+		Log:     &testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.ErrorContains(t, acc.GatherError(plugin.Gather), "bad_data: parse error")
+	require.Empty(t, acc.GetTelegrafMetrics())
+}
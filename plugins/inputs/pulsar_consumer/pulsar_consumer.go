@@ -0,0 +1,273 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package pulsar_consumer
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal"
+	common_pulsar "github.com/influxdata/telegraf/plugins/common/pulsar"
+	"github.com/influxdata/telegraf/plugins/inputs"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+var subscriptionTypes = map[string]pulsar.SubscriptionType{
+	"exclusive":  pulsar.Exclusive,
+	"shared":     pulsar.Shared,
+	"failover":   pulsar.Failover,
+	"key_shared": pulsar.KeyShared,
+}
+
+var initialPositions = map[string]pulsar.SubscriptionInitialPosition{
+	"latest":   pulsar.SubscriptionPositionLatest,
+	"earliest": pulsar.SubscriptionPositionEarliest,
+}
+
+type empty struct{}
+
+type PulsarConsumer struct {
+	Topics                 []string        `toml:"topics"`
+	TopicsPattern          string          `toml:"topics_pattern"`
+	Subscription           string          `toml:"subscription"`
+	SubscriptionType       string          `toml:"subscription_type"`
+	InitialPosition        string          `toml:"initial_position"`
+	ConsumerName           string          `toml:"consumer_name"`
+	ReceiverQueueSize      int             `toml:"receiver_queue_size"`
+	NackRedeliveryDelay    config.Duration `toml:"nack_redelivery_delay"`
+	TopicTag               string          `toml:"topic_tag"`
+	MaxUndeliveredMessages int             `toml:"max_undelivered_messages"`
+	Log                    telegraf.Logger `toml:"-"`
+	common_pulsar.ClientConfig
+
+	client   pulsar.Client
+	consumer pulsar.Consumer
+	parser   telegraf.Parser
+	acc      telegraf.TrackingAccumulator
+	sem      chan empty
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+
+	// Messages passed to the accumulator but not yet delivered to an output
+	undelivered map[telegraf.TrackingID]pulsar.Message
+	sync.Mutex
+}
+
+func (*PulsarConsumer) SampleConfig() string {
+	return sampleConfig
+}
+
+func (p *PulsarConsumer) Init() error {
+	if p.URL == "" {
+		return errors.New("url required")
+	}
+	if len(p.Topics) == 0 && p.TopicsPattern == "" {
+		return errors.New("either topics or topics_pattern required")
+	}
+	if len(p.Topics) > 0 && p.TopicsPattern != "" {
+		return errors.New("topics and topics_pattern are mutually exclusive")
+	}
+	if p.Subscription == "" {
+		return errors.New("subscription required")
+	}
+	if p.MaxUndeliveredMessages < 1 {
+		return errors.New("max undelivered messages must be positive")
+	}
+
+	if p.SubscriptionType == "" {
+		p.SubscriptionType = "exclusive"
+	}
+	if _, found := subscriptionTypes[p.SubscriptionType]; !found {
+		return fmt.Errorf("invalid subscription type %q", p.SubscriptionType)
+	}
+
+	if p.InitialPosition == "" {
+		p.InitialPosition = "latest"
+	}
+	if _, found := initialPositions[p.InitialPosition]; !found {
+		return fmt.Errorf("invalid initial position %q", p.InitialPosition)
+	}
+
+	return nil
+}
+
+func (p *PulsarConsumer) SetParser(parser telegraf.Parser) {
+	p.parser = parser
+}
+
+func (p *PulsarConsumer) Start(acc telegraf.Accumulator) error {
+	client, err := p.ClientConfig.NewClient(p.Log)
+	if err != nil {
+		return &internal.StartupError{
+			Err:   fmt.Errorf("creating client failed: %w", err),
+			Retry: true,
+		}
+	}
+
+	consumer, err := client.Subscribe(pulsar.ConsumerOptions{
+		Topics:                      p.Topics,
+		TopicsPattern:               p.TopicsPattern,
+		SubscriptionName:            p.Subscription,
+		Type:                        subscriptionTypes[p.SubscriptionType],
+		SubscriptionInitialPosition: initialPositions[p.InitialPosition],
+		Name:                        p.ConsumerName,
+		ReceiverQueueSize:           p.ReceiverQueueSize,
+		NackRedeliveryDelay:         time.Duration(p.NackRedeliveryDelay),
+	})
+	if err != nil {
+		client.Close()
+		return &internal.StartupError{
+			Err:   fmt.Errorf("subscribing to %q failed: %w", p.Subscription, err),
+			Retry: true,
+		}
+	}
+	p.client = client
+	p.consumer = consumer
+
+	p.acc = acc.WithTracking(p.MaxUndeliveredMessages)
+	p.sem = make(chan empty, p.MaxUndeliveredMessages)
+	p.undelivered = make(map[telegraf.TrackingID]pulsar.Message, p.MaxUndeliveredMessages)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	p.wg.Add(2)
+	go func() {
+		defer p.wg.Done()
+		p.deliver(ctx)
+	}()
+	go func() {
+		defer p.wg.Done()
+		p.consume(ctx)
+	}()
+
+	return nil
+}
+
+func (*PulsarConsumer) Gather(telegraf.Accumulator) error {
+	return nil
+}
+
+func (p *PulsarConsumer) Stop() {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.wg.Wait()
+
+	// Undelivered messages are redelivered after reconnecting as they are
+	// not acknowledged
+	if p.consumer != nil {
+		p.consumer.Close()
+	}
+	if p.client != nil {
+		p.client.Close()
+	}
+}
+
+func (p *PulsarConsumer) consume(ctx context.Context) {
+	for {
+		// Wait for a free slot to limit the number of undelivered messages
+		select {
+		case <-ctx.Done():
+			return
+		case p.sem <- empty{}:
+		}
+
+		msg, err := p.consumer.Receive(ctx)
+		if err != nil {
+			<-p.sem
+			if ctx.Err() != nil {
+				return
+			}
+			p.acc.AddError(fmt.Errorf("receiving message failed: %w", err))
+			continue
+		}
+
+		// Messages that cannot be parsed will never succeed so drop them by
+		// acknowledging to avoid them being redelivered over and over
+		metrics, err := p.parser.Parse(msg.Payload())
+		if err != nil {
+			<-p.sem
+			p.Log.Errorf("Parsing message %v of topic %q failed, dropping message: %v", msg.ID(), msg.Topic(), err)
+			p.ack(msg)
+			continue
+		}
+		if len(metrics) == 0 {
+			<-p.sem
+			p.ack(msg)
+			continue
+		}
+		if p.TopicTag != "" {
+			for _, m := range metrics {
+				m.AddTag(p.TopicTag, msg.Topic())
+			}
+		}
+
+		p.Lock()
+		id := p.acc.AddTrackingMetricGroup(metrics)
+		p.undelivered[id] = msg
+		p.Unlock()
+	}
+}
+
+func (p *PulsarConsumer) deliver(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case track := <-p.acc.Delivered():
+			p.onDelivery(track)
+		}
+	}
+}
+
+func (p *PulsarConsumer) onDelivery(track telegraf.DeliveryInfo) {
+	p.Lock()
+	msg, found := p.undelivered[track.ID()]
+	delete(p.undelivered, track.ID())
+	p.Unlock()
+
+	if !found {
+		p.Log.Errorf("Could not mark message delivered: %d", track.ID())
+		return
+	}
+	<-p.sem
+
+	// Messages not delivered are redelivered after the nack redelivery delay
+	if track.Delivered() {
+		p.ack(msg)
+	} else {
+		p.consumer.Nack(msg)
+	}
+}
+
+func (p *PulsarConsumer) ack(msg pulsar.Message) {
+	if err := p.consumer.Ack(msg); err != nil {
+		p.acc.AddError(fmt.Errorf("acknowledging message %v of topic %q failed: %w", msg.ID(), msg.Topic(), err))
+	}
+}
+
+func init() {
+	inputs.Add("pulsar_consumer", func() telegraf.Input {
+		return &PulsarConsumer{
+			ClientConfig: common_pulsar.ClientConfig{
+				ConnectionTimeout: config.Duration(10 * time.Second),
+				OperationTimeout:  config.Duration(30 * time.Second),
+			},
+			Subscription:           "telegraf",
+			SubscriptionType:       "exclusive",
+			InitialPosition:        "latest",
+			ReceiverQueueSize:      1000,
+			NackRedeliveryDelay:    config.Duration(time.Minute),
+			MaxUndeliveredMessages: 1000,
+		}
+	})
+}
@@ -0,0 +1,172 @@
+package pulsar_consumer
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/docker/go-connections/nat"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go/wait"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	common_pulsar "github.com/influxdata/telegraf/plugins/common/pulsar"
+	"github.com/influxdata/telegraf/plugins/parsers/influx"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInit(t *testing.T) {
+	client := common_pulsar.ClientConfig{URL: "pulsar://localhost:6650"}
+
+	tests := []struct {
+		name     string
+		plugin   *PulsarConsumer
+		expected string
+	}{
+		{
+			name:     "missing url",
+			plugin:   &PulsarConsumer{Topics: []string{"telegraf"}},
+			expected: "url required",
+		},
+		{
+			name:     "missing topics",
+			plugin:   &PulsarConsumer{ClientConfig: client},
+			expected: "either topics or topics_pattern required",
+		},
+		{
+			name: "topics and pattern",
+			plugin: &PulsarConsumer{
+				ClientConfig:  client,
+				Topics:        []string{"telegraf"},
+				TopicsPattern: "telegraf-.*",
+			},
+			expected: "topics and topics_pattern are mutually exclusive",
+		},
+		{
+			name: "invalid subscription type",
+			plugin: &PulsarConsumer{
+				ClientConfig:           client,
+				Topics:                 []string{"telegraf"},
+				Subscription:           "telegraf",
+				SubscriptionType:       "broadcast",
+				MaxUndeliveredMessages: 10,
+			},
+			expected: `invalid subscription type "broadcast"`,
+		},
+		{
+			name: "invalid initial position",
+			plugin: &PulsarConsumer{
+				ClientConfig:           client,
+				Topics:                 []string{"telegraf"},
+				Subscription:           "telegraf",
+				InitialPosition:        "middle",
+				MaxUndeliveredMessages: 10,
+			},
+			expected: `invalid initial position "middle"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestInitDefaults(t *testing.T) {
+	plugin := &PulsarConsumer{
+		ClientConfig:           common_pulsar.ClientConfig{URL: "pulsar://localhost:6650"},
+		Topics:                 []string{"telegraf"},
+		Subscription:           "telegraf",
+		MaxUndeliveredMessages: 10,
+	}
+	require.NoError(t, plugin.Init())
+	require.Equal(t, "exclusive", plugin.SubscriptionType)
+	require.Equal(t, "latest", plugin.InitialPosition)
+}
+
+func TestConsumeIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	servicePort := "6650"
+	container := testutil.Container{
+		Image:        "apachepulsar/pulsar:4.0.5",
+		ExposedPorts: []string{servicePort, "8080"},
+		Cmd:          []string{"bin/pulsar", "standalone"},
+		WaitingFor: wait.ForAll(
+			wait.ForListeningPort(nat.Port(servicePort)),
+			wait.ForHTTP("/admin/v2/clusters").WithPort("8080"),
+		),
+	}
+	require.NoError(t, container.Start(), "failed to start container")
+	defer container.Terminate()
+	url := fmt.Sprintf("pulsar://%s:%s", container.Address, container.Ports[servicePort])
+	topic := "persistent://public/default/metrics"
+
+	// Publish messages before subscribing to check the initial position
+	client, err := pulsar.NewClient(pulsar.ClientOptions{URL: url})
+	require.NoError(t, err)
+	defer client.Close()
+	producer, err := client.CreateProducer(pulsar.ProducerOptions{Topic: topic})
+	require.NoError(t, err)
+	defer producer.Close()
+	for i := range 3 {
+		payload := fmt.Sprintf("test,source=pulsar value=%di %d\n", i, time.Unix(int64(i), 0).UnixNano())
+		_, err := producer.Send(t.Context(), &pulsar.ProducerMessage{Payload: []byte(payload)})
+		require.NoError(t, err)
+	}
+	// Publish a message that cannot be parsed
+	_, err = producer.Send(t.Context(), &pulsar.ProducerMessage{Payload: []byte("garbage")})
+	require.NoError(t, err)
+
+	parser := &influx.Parser{}
+	require.NoError(t, parser.Init())
+
+	plugin := &PulsarConsumer{
+		ClientConfig:           common_pulsar.ClientConfig{URL: url},
+		Topics:                 []string{topic},
+		Subscription:           "telegraf",
+		SubscriptionType:       "shared",
+		InitialPosition:        "earliest",
+		NackRedeliveryDelay:    config.Duration(100 * time.Millisecond),
+		TopicTag:               "topic",
+		MaxUndeliveredMessages: 10,
+		Log:                    &testutil.Logger{},
+	}
+	plugin.SetParser(parser)
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	require.Eventually(t, func() bool {
+		return acc.NMetrics() >= 3
+	}, 10*time.Second, 100*time.Millisecond)
+
+	expected := []telegraf.Metric{
+		metric.New("test", map[string]string{"source": "pulsar", "topic": topic}, map[string]interface{}{"value": int64(0)}, time.Unix(0, 0)),
+		metric.New("test", map[string]string{"source": "pulsar", "topic": topic}, map[string]interface{}{"value": int64(1)}, time.Unix(1, 0)),
+		metric.New("test", map[string]string{"source": "pulsar", "topic": topic}, map[string]interface{}{"value": int64(2)}, time.Unix(2, 0)),
+	}
+	actual := acc.GetTelegrafMetrics()
+	testutil.RequireMetricsEqual(t, expected, actual)
+
+	// Reject the first metric to check redelivery and accept the others
+	actual[0].Reject()
+	actual[1].Accept()
+	actual[2].Accept()
+
+	require.Eventually(t, func() bool {
+		return acc.NMetrics() >= 4
+	}, 10*time.Second, 100*time.Millisecond)
+	redelivered := acc.GetTelegrafMetrics()[3]
+	testutil.RequireMetricsEqual(t, expected[:1], []telegraf.Metric{redelivered})
+	redelivered.Accept()
+	require.Empty(t, acc.Errors)
+}
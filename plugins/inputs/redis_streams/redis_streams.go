@@ -0,0 +1,465 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package redis_streams
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/common/tls"
+	"github.com/influxdata/telegraf/plugins/inputs"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+type empty struct{}
+
+type RedisStreams struct {
+	Address                string          `toml:"address"`
+	Username               config.Secret   `toml:"username"`
+	Password               config.Secret   `toml:"password"`
+	Database               int             `toml:"database"`
+	Streams                []string        `toml:"streams"`
+	ConsumerGroup          string          `toml:"consumer_group"`
+	ConsumerName           string          `toml:"consumer_name"`
+	CreateGroup            bool            `toml:"create_group"`
+	StartPosition          string          `toml:"start_position"`
+	Field                  string          `toml:"field"`
+	StreamTag              string          `toml:"stream_tag"`
+	BatchSize              int             `toml:"batch_size"`
+	Block                  config.Duration `toml:"block"`
+	ClaimMinIdle           config.Duration `toml:"claim_min_idle"`
+	ClaimInterval          config.Duration `toml:"claim_interval"`
+	Timeout                config.Duration `toml:"timeout"`
+	MaxUndeliveredMessages int             `toml:"max_undelivered_messages"`
+	Log                    telegraf.Logger `toml:"-"`
+	tls.ClientConfig
+
+	client *redis.Client
+	parser telegraf.Parser
+	acc    telegraf.TrackingAccumulator
+	sem    chan empty
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	// Entries passed to the accumulator but not yet delivered to an output
+	undelivered map[telegraf.TrackingID]entry
+	inflight    map[entry]bool
+	sync.Mutex
+}
+
+// entry identifies a single entry in a stream
+type entry struct {
+	stream string
+	id     string
+}
+
+func (*RedisStreams) SampleConfig() string {
+	return sampleConfig
+}
+
+func (r *RedisStreams) Init() error {
+	if r.Address == "" {
+		return errors.New("address required")
+	}
+	if len(r.Streams) == 0 {
+		return errors.New("no streams specified")
+	}
+	if r.ConsumerGroup == "" {
+		return errors.New("consumer group required")
+	}
+	if r.Field == "" {
+		return errors.New("field required")
+	}
+	if r.BatchSize < 1 {
+		return errors.New("batch size must be positive")
+	}
+	if r.MaxUndeliveredMessages < 1 {
+		return errors.New("max undelivered messages must be positive")
+	}
+	if r.ClaimMinIdle > 0 && r.ClaimInterval <= 0 {
+		return errors.New("claim interval must be positive")
+	}
+
+	switch r.StartPosition {
+	case "":
+		r.StartPosition = "newest"
+	case "newest", "oldest":
+	default:
+		return fmt.Errorf("invalid start position %q", r.StartPosition)
+	}
+
+	if r.ConsumerName == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return fmt.Errorf("determining consumer name failed: %w", err)
+		}
+		r.ConsumerName = hostname
+	}
+
+	return nil
+}
+
+func (r *RedisStreams) SetParser(parser telegraf.Parser) {
+	r.parser = parser
+}
+
+func (r *RedisStreams) Start(acc telegraf.Accumulator) error {
+	username, err := r.Username.Get()
+	if err != nil {
+		return fmt.Errorf("getting username failed: %w", err)
+	}
+	defer username.Destroy()
+
+	password, err := r.Password.Get()
+	if err != nil {
+		return fmt.Errorf("getting password failed: %w", err)
+	}
+	defer password.Destroy()
+
+	tlsCfg, err := r.ClientConfig.TLSConfig()
+	if err != nil {
+		return fmt.Errorf("creating TLS config failed: %w", err)
+	}
+
+	r.client = redis.NewClient(&redis.Options{
+		Addr:         r.Address,
+		Username:     username.String(),
+		Password:     password.String(),
+		DB:           r.Database,
+		TLSConfig:    tlsCfg,
+		DialTimeout:  time.Duration(r.Timeout),
+		ReadTimeout:  time.Duration(r.Timeout),
+		WriteTimeout: time.Duration(r.Timeout),
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(r.Timeout))
+	defer cancel()
+	if err := r.client.Ping(ctx).Err(); err != nil {
+		r.client.Close()
+		return &internal.StartupError{
+			Err:   fmt.Errorf("connecting to %q failed: %w", r.Address, err),
+			Retry: true,
+		}
+	}
+
+	if r.CreateGroup {
+		if err := r.createGroups(ctx); err != nil {
+			r.client.Close()
+			return err
+		}
+	}
+
+	r.acc = acc.WithTracking(r.MaxUndeliveredMessages)
+	r.sem = make(chan empty, r.MaxUndeliveredMessages)
+	r.undelivered = make(map[telegraf.TrackingID]entry, r.MaxUndeliveredMessages)
+	r.inflight = make(map[entry]bool, r.MaxUndeliveredMessages)
+
+	ctx, r.cancel = context.WithCancel(context.Background())
+	r.wg.Add(2)
+	go func() {
+		defer r.wg.Done()
+		r.deliver(ctx)
+	}()
+	go func() {
+		defer r.wg.Done()
+		r.consume(ctx)
+	}()
+
+	return nil
+}
+
+func (*RedisStreams) Gather(telegraf.Accumulator) error {
+	return nil
+}
+
+func (r *RedisStreams) Stop() {
+	if r.cancel != nil {
+		r.cancel()
+	}
+	r.wg.Wait()
+
+	if r.client != nil {
+		if err := r.client.Close(); err != nil {
+			r.Log.Errorf("Closing connection failed: %v", err)
+		}
+	}
+}
+
+func (r *RedisStreams) createGroups(ctx context.Context) error {
+	start := "$"
+	if r.StartPosition == "oldest" {
+		start = "0"
+	}
+	for _, stream := range r.Streams {
+		err := r.client.XGroupCreateMkStream(ctx, stream, r.ConsumerGroup, start).Err()
+		if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+			return fmt.Errorf("creating consumer group for stream %q failed: %w", stream, err)
+		}
+	}
+	return nil
+}
+
+func (r *RedisStreams) consume(ctx context.Context) {
+	// Entries delivered to this consumer in a previous run but not
+	// acknowledged are only returned when explicitly reading the pending ones
+	for _, stream := range r.Streams {
+		if err := r.readPending(ctx, stream); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			r.acc.AddError(fmt.Errorf("reading pending entries of stream %q failed: %w", stream, err))
+		}
+	}
+
+	var lastClaim time.Time
+	for ctx.Err() == nil {
+		if r.ClaimMinIdle > 0 && time.Since(lastClaim) >= time.Duration(r.ClaimInterval) {
+			for _, stream := range r.Streams {
+				if err := r.claim(ctx, stream); err != nil && ctx.Err() == nil {
+					r.acc.AddError(fmt.Errorf("claiming entries of stream %q failed: %w", stream, err))
+				}
+			}
+			lastClaim = time.Now()
+		}
+
+		if err := r.read(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			r.acc.AddError(fmt.Errorf("reading entries failed: %w", err))
+
+			// Recreate the consumer group if the stream was deleted
+			if r.CreateGroup && strings.HasPrefix(err.Error(), "NOGROUP") {
+				if err := r.createGroups(ctx); err != nil {
+					r.acc.AddError(err)
+				}
+			}
+
+			// Avoid hammering the server in case of persistent errors
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+		}
+	}
+}
+
+// readPending processes the entries delivered to, but not acknowledged by
+// this consumer before
+func (r *RedisStreams) readPending(ctx context.Context, stream string) error {
+	start := "0"
+	for {
+		result, err := r.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    r.ConsumerGroup,
+			Consumer: r.ConsumerName,
+			Streams:  []string{stream, start},
+			Count:    int64(r.BatchSize),
+			Block:    -1,
+		}).Result()
+		if err != nil {
+			if errors.Is(err, redis.Nil) {
+				return nil
+			}
+			return err
+		}
+		if len(result) == 0 || len(result[0].Messages) == 0 {
+			return nil
+		}
+		for _, msg := range result[0].Messages {
+			if err := r.process(ctx, stream, msg); err != nil {
+				return err
+			}
+			start = msg.ID
+		}
+	}
+}
+
+// claim takes over the entries of other consumers pending for too long
+func (r *RedisStreams) claim(ctx context.Context, stream string) error {
+	start := "0-0"
+	for {
+		messages, next, err := r.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   stream,
+			Group:    r.ConsumerGroup,
+			Consumer: r.ConsumerName,
+			MinIdle:  time.Duration(r.ClaimMinIdle),
+			Start:    start,
+			Count:    int64(r.BatchSize),
+		}).Result()
+		if err != nil {
+			return err
+		}
+		if len(messages) > 0 {
+			r.Log.Debugf("Claimed %d pending entries of stream %q", len(messages), stream)
+		}
+		for _, msg := range messages {
+			if err := r.process(ctx, stream, msg); err != nil {
+				return err
+			}
+		}
+		if next == "0-0" || next == "" {
+			return nil
+		}
+		start = next
+	}
+}
+
+// read processes new entries not yet delivered to any consumer of the group
+func (r *RedisStreams) read(ctx context.Context) error {
+	streams := make([]string, 0, 2*len(r.Streams))
+	streams = append(streams, r.Streams...)
+	for range r.Streams {
+		streams = append(streams, ">")
+	}
+
+	result, err := r.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    r.ConsumerGroup,
+		Consumer: r.ConsumerName,
+		Streams:  streams,
+		Count:    int64(r.BatchSize),
+		Block:    time.Duration(r.Block),
+	}).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil
+		}
+		return err
+	}
+
+	for _, s := range result {
+		for _, msg := range s.Messages {
+			if err := r.process(ctx, s.Stream, msg); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *RedisStreams) process(ctx context.Context, stream string, msg redis.XMessage) error {
+	e := entry{stream: stream, id: msg.ID}
+
+	// Skip entries already being processed, e.g. when claiming entries
+	// that are waiting for the outputs longer than the claim idle time
+	r.Lock()
+	inflight := r.inflight[e]
+	r.Unlock()
+	if inflight {
+		return nil
+	}
+
+	// Entries that cannot be parsed will never succeed so drop them by
+	// acknowledging to avoid them being claimed over and over
+	raw, found := msg.Values[r.Field]
+	if !found {
+		r.Log.Errorf("Entry %q of stream %q does not contain field %q, dropping entry", msg.ID, stream, r.Field)
+		r.ack(e)
+		return nil
+	}
+	data, ok := raw.(string)
+	if !ok {
+		r.Log.Errorf("Unexpected type %T of entry %q in stream %q, dropping entry", raw, msg.ID, stream)
+		r.ack(e)
+		return nil
+	}
+
+	metrics, err := r.parser.Parse([]byte(data))
+	if err != nil {
+		r.Log.Errorf("Parsing entry %q of stream %q failed, dropping entry: %v", msg.ID, stream, err)
+		r.ack(e)
+		return nil
+	}
+	if len(metrics) == 0 {
+		r.ack(e)
+		return nil
+	}
+	if r.StreamTag != "" {
+		for _, m := range metrics {
+			m.AddTag(r.StreamTag, stream)
+		}
+	}
+
+	// Wait for a free slot to limit the number of undelivered entries
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case r.sem <- empty{}:
+	}
+
+	r.Lock()
+	id := r.acc.AddTrackingMetricGroup(metrics)
+	r.undelivered[id] = e
+	r.inflight[e] = true
+	r.Unlock()
+
+	return nil
+}
+
+func (r *RedisStreams) deliver(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case track := <-r.acc.Delivered():
+			r.onDelivery(track)
+		}
+	}
+}
+
+func (r *RedisStreams) onDelivery(track telegraf.DeliveryInfo) {
+	r.Lock()
+	e, found := r.undelivered[track.ID()]
+	delete(r.undelivered, track.ID())
+	delete(r.inflight, e)
+	r.Unlock()
+
+	if !found {
+		r.Log.Errorf("Could not mark entry delivered: %d", track.ID())
+		return
+	}
+	<-r.sem
+
+	// Entries not delivered remain pending and will be claimed again after
+	// the claim idle time
+	if track.Delivered() {
+		r.ack(e)
+	}
+}
+
+func (r *RedisStreams) ack(e entry) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(r.Timeout))
+	defer cancel()
+	if err := r.client.XAck(ctx, e.stream, r.ConsumerGroup, e.id).Err(); err != nil {
+		r.acc.AddError(fmt.Errorf("acknowledging entry %q of stream %q failed: %w", e.id, e.stream, err))
+	}
+}
+
+func init() {
+	inputs.Add("redis_streams", func() telegraf.Input {
+		return &RedisStreams{
+			ConsumerGroup:          "telegraf",
+			CreateGroup:            true,
+			StartPosition:          "newest",
+			Field:                  "data",
+			BatchSize:              100,
+			Block:                  config.Duration(time.Second),
+			ClaimMinIdle:           config.Duration(5 * time.Minute),
+			ClaimInterval:          config.Duration(30 * time.Second),
+			Timeout:                config.Duration(10 * time.Second),
+			MaxUndeliveredMessages: 1000,
+		}
+	})
+}
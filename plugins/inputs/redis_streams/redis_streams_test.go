@@ -0,0 +1,237 @@
+package redis_streams
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/docker/go-connections/nat"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go/wait"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/parsers/influx"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInit(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *RedisStreams
+		expected string
+	}{
+		{
+			name:     "missing address",
+			plugin:   &RedisStreams{Streams: []string{"telegraf"}},
+			expected: "address required",
+		},
+		{
+			name:     "missing streams",
+			plugin:   &RedisStreams{Address: "localhost:6379"},
+			expected: "no streams specified",
+		},
+		{
+			name: "invalid start position",
+			plugin: &RedisStreams{
+				Address:                "localhost:6379",
+				Streams:                []string{"telegraf"},
+				ConsumerGroup:          "telegraf",
+				Field:                  "data",
+				BatchSize:              10,
+				MaxUndeliveredMessages: 10,
+				StartPosition:          "middle",
+			},
+			expected: `invalid start position "middle"`,
+		},
+		{
+			name: "missing claim interval",
+			plugin: &RedisStreams{
+				Address:                "localhost:6379",
+				Streams:                []string{"telegraf"},
+				ConsumerGroup:          "telegraf",
+				Field:                  "data",
+				BatchSize:              10,
+				MaxUndeliveredMessages: 10,
+				ClaimMinIdle:           config.Duration(time.Minute),
+			},
+			expected: "claim interval must be positive",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestInitDefaults(t *testing.T) {
+	plugin := &RedisStreams{
+		Address:                "localhost:6379",
+		Streams:                []string{"telegraf"},
+		ConsumerGroup:          "telegraf",
+		Field:                  "data",
+		BatchSize:              10,
+		MaxUndeliveredMessages: 10,
+	}
+	require.NoError(t, plugin.Init())
+	require.Equal(t, "newest", plugin.StartPosition)
+	require.NotEmpty(t, plugin.ConsumerName)
+}
+
+func TestConsumeIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	address := launchTestContainer(t)
+	client := redis.NewClient(&redis.Options{Addr: address})
+	defer client.Close()
+
+	// Add entries before the group is created to check the start position
+	ctx := t.Context()
+	for i := range 3 {
+		payload := fmt.Sprintf("test,source=redis value=%di %d\n", i, time.Unix(int64(i), 0).UnixNano())
+		require.NoError(t, client.XAdd(ctx, &redis.XAddArgs{Stream: "metrics", Values: []interface{}{"data", payload}}).Err())
+	}
+	// Add an entry that cannot be parsed
+	require.NoError(t, client.XAdd(ctx, &redis.XAddArgs{Stream: "metrics", Values: []interface{}{"data", "garbage"}}).Err())
+
+	parser := &influx.Parser{}
+	require.NoError(t, parser.Init())
+
+	plugin := newPlugin(address)
+	plugin.StartPosition = "oldest"
+	plugin.StreamTag = "stream"
+	plugin.SetParser(parser)
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	require.Eventually(t, func() bool {
+		return acc.NMetrics() >= 3
+	}, 5*time.Second, 100*time.Millisecond)
+
+	expected := []telegraf.Metric{
+		metric.New("test", map[string]string{"source": "redis", "stream": "metrics"}, map[string]interface{}{"value": int64(0)}, time.Unix(0, 0)),
+		metric.New("test", map[string]string{"source": "redis", "stream": "metrics"}, map[string]interface{}{"value": int64(1)}, time.Unix(1, 0)),
+		metric.New("test", map[string]string{"source": "redis", "stream": "metrics"}, map[string]interface{}{"value": int64(2)}, time.Unix(2, 0)),
+	}
+	actual := acc.GetTelegrafMetrics()
+	testutil.RequireMetricsEqual(t, expected, actual)
+
+	// Only the unparsable entry is acknowledged before delivery
+	require.Eventually(t, func() bool {
+		pending, err := client.XPending(ctx, "metrics", "telegraf").Result()
+		return err == nil && pending.Count == 3
+	}, 5*time.Second, 100*time.Millisecond)
+
+	// Deliver the metrics and check that the entries are acknowledged
+	for _, m := range actual {
+		m.Accept()
+	}
+	require.Eventually(t, func() bool {
+		pending, err := client.XPending(ctx, "metrics", "telegraf").Result()
+		return err == nil && pending.Count == 0
+	}, 5*time.Second, 100*time.Millisecond)
+}
+
+func TestClaimIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	address := launchTestContainer(t)
+	client := redis.NewClient(&redis.Options{Addr: address})
+	defer client.Close()
+
+	// Simulate a consumer reading the entries and then dying without
+	// acknowledging them
+	ctx := t.Context()
+	require.NoError(t, client.XGroupCreateMkStream(ctx, "metrics", "telegraf", "0").Err())
+	for i := range 2 {
+		payload := fmt.Sprintf("test value=%di %d\n", i, time.Unix(int64(i), 0).UnixNano())
+		require.NoError(t, client.XAdd(ctx, &redis.XAddArgs{Stream: "metrics", Values: []interface{}{"data", payload}}).Err())
+	}
+	result, err := client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    "telegraf",
+		Consumer: "dead",
+		Streams:  []string{"metrics", ">"},
+		Block:    -1,
+	}).Result()
+	require.NoError(t, err)
+	require.Len(t, result[0].Messages, 2)
+
+	parser := &influx.Parser{}
+	require.NoError(t, parser.Init())
+
+	plugin := newPlugin(address)
+	plugin.ClaimMinIdle = config.Duration(time.Millisecond)
+	plugin.ClaimInterval = config.Duration(100 * time.Millisecond)
+	plugin.SetParser(parser)
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	require.Eventually(t, func() bool {
+		return acc.NMetrics() >= 2
+	}, 5*time.Second, 100*time.Millisecond)
+
+	// The entries must not be delivered twice even though they are idle
+	// longer than the claim time while waiting for the outputs
+	time.Sleep(300 * time.Millisecond)
+	require.Equal(t, uint64(2), acc.NMetrics())
+
+	for _, m := range acc.GetTelegrafMetrics() {
+		m.Accept()
+	}
+	require.Eventually(t, func() bool {
+		pending, err := client.XPending(ctx, "metrics", "telegraf").Result()
+		return err == nil && pending.Count == 0
+	}, 5*time.Second, 100*time.Millisecond)
+	require.Empty(t, acc.Errors)
+}
+
+func newPlugin(address string) *RedisStreams {
+	return &RedisStreams{
+		Address:                address,
+		Streams:                []string{"metrics"},
+		ConsumerGroup:          "telegraf",
+		ConsumerName:           "test",
+		CreateGroup:            true,
+		Field:                  "data",
+		BatchSize:              10,
+		Block:                  config.Duration(100 * time.Millisecond),
+		Timeout:                config.Duration(5 * time.Second),
+		MaxUndeliveredMessages: 10,
+		Log:                    &testutil.Logger{},
+	}
+}
+
+func launchTestContainer(t *testing.T) string {
+	t.Helper()
+
+	servicePort := "6379"
+	container := testutil.Container{
+		Image:        "redis:alpine",
+		ExposedPorts: []string{servicePort},
+		WaitingFor:   wait.ForListeningPort(nat.Port(servicePort)),
+	}
+	require.NoError(t, container.Start(), "failed to start container")
+	t.Cleanup(container.Terminate)
+
+	// Make sure the server is ready
+	client := redis.NewClient(&redis.Options{Addr: fmt.Sprintf("%s:%s", container.Address, container.Ports[servicePort])})
+	defer client.Close()
+	require.NoError(t, client.Ping(context.Background()).Err())
+
+	return fmt.Sprintf("%s:%s", container.Address, container.Ports[servicePort])
+}
@@ -0,0 +1,353 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package sqs_consumer
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal"
+	common_aws "github.com/influxdata/telegraf/plugins/common/aws"
+	"github.com/influxdata/telegraf/plugins/inputs"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+// Limit set by AWS for ReceiveMessage and ChangeMessageVisibilityBatch requests
+const maxEntriesPerRequest = 10
+
+type empty struct{}
+
+type SQSConsumer struct {
+	QueueURL               string          `toml:"queue_url"`
+	MaxMessages            int32           `toml:"max_messages"`
+	WaitTime               config.Duration `toml:"wait_time"`
+	VisibilityTimeout      config.Duration `toml:"visibility_timeout"`
+	Timeout                config.Duration `toml:"timeout"`
+	MaxUndeliveredMessages int             `toml:"max_undelivered_messages"`
+	Log                    telegraf.Logger `toml:"-"`
+	common_aws.CredentialConfig
+
+	client sqsAPI
+	parser telegraf.Parser
+	acc    telegraf.TrackingAccumulator
+	sem    chan empty
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	// Receipt handles of messages passed to the accumulator but not yet
+	// delivered to an output
+	undelivered map[telegraf.TrackingID]string
+	sync.Mutex
+}
+
+type sqsAPI interface {
+	ReceiveMessage(context.Context, *sqs.ReceiveMessageInput, ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error)
+	DeleteMessage(context.Context, *sqs.DeleteMessageInput, ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error)
+	ChangeMessageVisibilityBatch(
+		context.Context,
+		*sqs.ChangeMessageVisibilityBatchInput,
+		...func(*sqs.Options),
+	) (*sqs.ChangeMessageVisibilityBatchOutput, error)
+}
+
+func (*SQSConsumer) SampleConfig() string {
+	return sampleConfig
+}
+
+func (s *SQSConsumer) Init() error {
+	if s.QueueURL == "" {
+		return errors.New("queue_url required")
+	}
+	if s.MaxMessages < 1 || s.MaxMessages > maxEntriesPerRequest {
+		return fmt.Errorf("max_messages must be between 1 and %d", maxEntriesPerRequest)
+	}
+	if s.WaitTime < 0 || time.Duration(s.WaitTime) > 20*time.Second {
+		return errors.New("wait_time must be between 0s and 20s")
+	}
+	if time.Duration(s.VisibilityTimeout) < 2*time.Second || time.Duration(s.VisibilityTimeout) > 12*time.Hour {
+		return errors.New("visibility_timeout must be between 2s and 12h")
+	}
+	if s.MaxUndeliveredMessages < 1 {
+		return errors.New("max undelivered messages must be positive")
+	}
+
+	return nil
+}
+
+func (s *SQSConsumer) SetParser(parser telegraf.Parser) {
+	s.parser = parser
+}
+
+func (s *SQSConsumer) Start(acc telegraf.Accumulator) error {
+	cfg, err := s.CredentialConfig.Credentials()
+	if err != nil {
+		return &internal.StartupError{
+			Err:   fmt.Errorf("getting credentials failed: %w", err),
+			Retry: true,
+		}
+	}
+	s.client = sqs.NewFromConfig(cfg, func(o *sqs.Options) {
+		if s.EndpointURL != "" {
+			o.BaseEndpoint = &s.EndpointURL
+		}
+	})
+
+	s.acc = acc.WithTracking(s.MaxUndeliveredMessages)
+	s.sem = make(chan empty, s.MaxUndeliveredMessages)
+	s.undelivered = make(map[telegraf.TrackingID]string, s.MaxUndeliveredMessages)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.wg.Add(3)
+	go func() {
+		defer s.wg.Done()
+		s.deliver(ctx)
+	}()
+	go func() {
+		defer s.wg.Done()
+		s.extend(ctx)
+	}()
+	go func() {
+		defer s.wg.Done()
+		s.consume(ctx)
+	}()
+
+	return nil
+}
+
+func (*SQSConsumer) Gather(telegraf.Accumulator) error {
+	return nil
+}
+
+func (s *SQSConsumer) Stop() {
+	// Undelivered messages become visible again once their visibility
+	// timeout expires as they are not deleted
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+}
+
+func (s *SQSConsumer) consume(ctx context.Context) {
+	for {
+		n := s.acquire(ctx)
+		if n == 0 {
+			return
+		}
+
+		resp, err := s.client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:            aws.String(s.QueueURL),
+			MaxNumberOfMessages: int32(n),
+			WaitTimeSeconds:     int32(time.Duration(s.WaitTime).Seconds()),
+			VisibilityTimeout:   int32(time.Duration(s.VisibilityTimeout).Seconds()),
+		})
+		if err != nil {
+			s.release(n)
+			if ctx.Err() != nil {
+				return
+			}
+			s.acc.AddError(fmt.Errorf("receiving messages failed: %w", err))
+
+			// Back off to not hammer the service in case of persistent
+			// errors such as missing permissions
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		s.release(n - len(resp.Messages))
+
+		for _, msg := range resp.Messages {
+			// Messages that cannot be parsed will never succeed so drop them
+			// by deleting to avoid them being received over and over
+			metrics, err := s.parser.Parse([]byte(aws.ToString(msg.Body)))
+			if err != nil {
+				s.release(1)
+				s.Log.Errorf("Parsing message %q failed, dropping message: %v", aws.ToString(msg.MessageId), err)
+				s.delete(msg.ReceiptHandle)
+				continue
+			}
+			if len(metrics) == 0 {
+				s.release(1)
+				s.delete(msg.ReceiptHandle)
+				continue
+			}
+
+			s.Lock()
+			id := s.acc.AddTrackingMetricGroup(metrics)
+			s.undelivered[id] = aws.ToString(msg.ReceiptHandle)
+			s.Unlock()
+		}
+	}
+}
+
+// acquire waits for a free slot to limit the number of undelivered messages
+// and returns the number of slots available up to the maximum number of
+// messages received at once
+func (s *SQSConsumer) acquire(ctx context.Context) int {
+	select {
+	case <-ctx.Done():
+		return 0
+	case s.sem <- empty{}:
+	}
+
+	n := 1
+	for n < int(s.MaxMessages) {
+		select {
+		case s.sem <- empty{}:
+			n++
+		default:
+			return n
+		}
+	}
+	return n
+}
+
+func (s *SQSConsumer) release(n int) {
+	for range n {
+		<-s.sem
+	}
+}
+
+func (s *SQSConsumer) deliver(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case track := <-s.acc.Delivered():
+			s.onDelivery(track)
+		}
+	}
+}
+
+func (s *SQSConsumer) onDelivery(track telegraf.DeliveryInfo) {
+	s.Lock()
+	handle, found := s.undelivered[track.ID()]
+	delete(s.undelivered, track.ID())
+	s.Unlock()
+
+	if !found {
+		s.Log.Errorf("Could not mark message delivered: %d", track.ID())
+		return
+	}
+	s.release(1)
+
+	// Messages not delivered are not extended anymore and are received again
+	// after the visibility timeout
+	if track.Delivered() {
+		s.delete(&handle)
+	}
+}
+
+func (s *SQSConsumer) delete(handle *string) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(s.Timeout))
+	defer cancel()
+
+	_, err := s.client.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(s.QueueURL),
+		ReceiptHandle: handle,
+	})
+	if err != nil {
+		s.acc.AddError(fmt.Errorf("deleting message failed: %w", err))
+	}
+}
+
+// extend periodically resets the visibility timeout of the undelivered
+// messages to prevent them from being received again while being processed
+func (s *SQSConsumer) extend(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(s.VisibilityTimeout) / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.extendVisibility(ctx)
+		}
+	}
+}
+
+func (s *SQSConsumer) extendVisibility(ctx context.Context) {
+	s.Lock()
+	handles := make([]string, 0, len(s.undelivered))
+	for _, handle := range s.undelivered {
+		handles = append(handles, handle)
+	}
+	s.Unlock()
+
+	timeout := int32(time.Duration(s.VisibilityTimeout).Seconds())
+	for start := 0; start < len(handles); start += maxEntriesPerRequest {
+		chunk := handles[start:min(start+maxEntriesPerRequest, len(handles))]
+		entries := make([]types.ChangeMessageVisibilityBatchRequestEntry, 0, len(chunk))
+		for i, handle := range chunk {
+			entries = append(entries, types.ChangeMessageVisibilityBatchRequestEntry{
+				Id:                aws.String(strconv.Itoa(i)),
+				ReceiptHandle:     aws.String(handle),
+				VisibilityTimeout: timeout,
+			})
+		}
+
+		rctx, cancel := context.WithTimeout(ctx, time.Duration(s.Timeout))
+		resp, err := s.client.ChangeMessageVisibilityBatch(rctx, &sqs.ChangeMessageVisibilityBatchInput{
+			QueueUrl: aws.String(s.QueueURL),
+			Entries:  entries,
+		})
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			s.acc.AddError(fmt.Errorf("extending visibility timeout failed: %w", err))
+			continue
+		}
+		if len(resp.Failed) == 0 {
+			continue
+		}
+
+		// Messages delivered in the meantime are deleted so the extension
+		// is expected to fail for those
+		s.Lock()
+		pending := make(map[string]bool, len(s.undelivered))
+		for _, handle := range s.undelivered {
+			pending[handle] = true
+		}
+		s.Unlock()
+		for _, f := range resp.Failed {
+			idx, err := strconv.Atoi(aws.ToString(f.Id))
+			if err != nil || idx < 0 || idx >= len(chunk) || !pending[chunk[idx]] {
+				continue
+			}
+			s.acc.AddError(fmt.Errorf(
+				"extending visibility timeout of message failed: %s: %s",
+				aws.ToString(f.Code), aws.ToString(f.Message),
+			))
+		}
+	}
+}
+
+func init() {
+	inputs.Add("sqs_consumer", func() telegraf.Input {
+		return &SQSConsumer{
+			MaxMessages:            10,
+			WaitTime:               config.Duration(20 * time.Second),
+			VisibilityTimeout:      config.Duration(30 * time.Second),
+			Timeout:                config.Duration(10 * time.Second),
+			MaxUndeliveredMessages: 1000,
+		}
+	})
+}
@@ -0,0 +1,368 @@
+package sqs_consumer
+
+import (
+	"crypto/md5" //nolint:gosec // required by the SQS protocol
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf/config"
+	common_aws "github.com/influxdata/telegraf/plugins/common/aws"
+	"github.com/influxdata/telegraf/plugins/parsers/influx"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInit(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *SQSConsumer
+		expected string
+	}{
+		{
+			name:     "missing queue",
+			plugin:   &SQSConsumer{},
+			expected: "queue_url required",
+		},
+		{
+			name:     "too many messages",
+			plugin:   &SQSConsumer{QueueURL: "http://localhost/000000000000/telegraf", MaxMessages: 11},
+			expected: "max_messages must be between 1 and 10",
+		},
+		{
+			name: "wait time too long",
+			plugin: &SQSConsumer{
+				QueueURL:    "http://localhost/000000000000/telegraf",
+				MaxMessages: 10,
+				WaitTime:    config.Duration(time.Minute),
+			},
+			expected: "wait_time must be between 0s and 20s",
+		},
+		{
+			name: "visibility timeout too short",
+			plugin: &SQSConsumer{
+				QueueURL:          "http://localhost/000000000000/telegraf",
+				MaxMessages:       10,
+				VisibilityTimeout: config.Duration(time.Second),
+			},
+			expected: "visibility_timeout must be between 2s and 12h",
+		},
+		{
+			name: "no undelivered messages",
+			plugin: &SQSConsumer{
+				QueueURL:          "http://localhost/000000000000/telegraf",
+				MaxMessages:       10,
+				VisibilityTimeout: config.Duration(30 * time.Second),
+			},
+			expected: "max undelivered messages must be positive",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestConsume(t *testing.T) {
+	queue := newStubQueue(t)
+	queue.push("test value=1i 0")
+	queue.push("garbage")
+	queue.push("test value=2i 0")
+
+	plugin := newTestPlugin(t, queue.URL, 30*time.Second)
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	require.Eventually(t, func() bool {
+		return acc.NMetrics() >= 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The message that cannot be parsed is deleted immediately while the
+	// others are kept until delivery
+	require.Eventually(t, func() bool {
+		return len(queue.deletedMessages()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, []string{"garbage"}, queue.deletedMessages())
+
+	for _, m := range acc.GetTelegrafMetrics() {
+		m.Accept()
+	}
+	require.Eventually(t, func() bool {
+		return len(queue.deletedMessages()) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+	require.ElementsMatch(t, []string{"garbage", "test value=1i 0", "test value=2i 0"}, queue.deletedMessages())
+	require.Empty(t, acc.Errors)
+}
+
+func TestRedeliveryOnReject(t *testing.T) {
+	queue := newStubQueue(t)
+	queue.push("test value=1i 0")
+
+	plugin := newTestPlugin(t, queue.URL, 2*time.Second)
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	require.Eventually(t, func() bool {
+		return acc.NMetrics() >= 1
+	}, 5*time.Second, 10*time.Millisecond)
+	acc.GetTelegrafMetrics()[0].Reject()
+
+	// The message is not deleted but received again after the visibility
+	// timeout expired
+	require.Eventually(t, func() bool {
+		return acc.NMetrics() >= 2
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Empty(t, queue.deletedMessages())
+
+	acc.GetTelegrafMetrics()[1].Accept()
+	require.Eventually(t, func() bool {
+		return len(queue.deletedMessages()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Empty(t, acc.Errors)
+}
+
+func TestVisibilityExtension(t *testing.T) {
+	queue := newStubQueue(t)
+	queue.push("test value=1i 0")
+
+	plugin := newTestPlugin(t, queue.URL, 2*time.Second)
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	require.Eventually(t, func() bool {
+		return acc.NMetrics() >= 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The message must not be received again while being undelivered for
+	// longer than the visibility timeout
+	time.Sleep(3 * time.Second)
+	require.Equal(t, 1, queue.receiveCount())
+	require.GreaterOrEqual(t, queue.extensionCount(), 2)
+	require.Equal(t, 1, int(acc.NMetrics()))
+
+	acc.GetTelegrafMetrics()[0].Accept()
+	require.Eventually(t, func() bool {
+		return len(queue.deletedMessages()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Empty(t, acc.Errors)
+}
+
+func TestMaxUndeliveredMessages(t *testing.T) {
+	queue := newStubQueue(t)
+	for i := range 5 {
+		queue.push("test value=" + strconv.Itoa(i) + "i 0")
+	}
+
+	plugin := newTestPlugin(t, queue.URL, 30*time.Second)
+	plugin.MaxUndeliveredMessages = 2
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	require.Eventually(t, func() bool {
+		return acc.NMetrics() >= 2
+	}, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	require.Equal(t, 2, int(acc.NMetrics()))
+
+	// Delivering the messages frees the slots for further messages
+	for _, m := range acc.GetTelegrafMetrics() {
+		m.Accept()
+	}
+	require.Eventually(t, func() bool {
+		return acc.NMetrics() >= 4
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func newTestPlugin(t *testing.T, endpoint string, visibility time.Duration) *SQSConsumer {
+	t.Helper()
+
+	parser := &influx.Parser{}
+	require.NoError(t, parser.Init())
+
+	plugin := &SQSConsumer{
+		QueueURL:               endpoint + "/000000000000/telegraf",
+		MaxMessages:            10,
+		WaitTime:               config.Duration(time.Second),
+		VisibilityTimeout:      config.Duration(visibility),
+		Timeout:                config.Duration(5 * time.Second),
+		MaxUndeliveredMessages: 1000,
+		CredentialConfig: common_aws.CredentialConfig{
+			Region:      "us-east-1",
+			AccessKey:   "dummy",
+			SecretKey:   "dummy",
+			EndpointURL: endpoint,
+		},
+		Log: testutil.Logger{},
+	}
+	plugin.SetParser(parser)
+	require.NoError(t, plugin.Init())
+	return plugin
+}
+
+type stubMessage struct {
+	id        string
+	body      string
+	handle    string
+	invisible time.Time
+}
+
+// stubQueue implements the actions of the SQS JSON protocol used by the
+// plugin including the visibility timeout of received messages
+type stubQueue struct {
+	*httptest.Server
+
+	sync.Mutex
+	messages   []*stubMessage
+	deleted    []string
+	receipts   int
+	extensions int
+	handles    int
+}
+
+func newStubQueue(t *testing.T) *stubQueue {
+	t.Helper()
+
+	q := &stubQueue{}
+	q.Server = httptest.NewServer(http.HandlerFunc(q.handle))
+	t.Cleanup(q.Close)
+	return q
+}
+
+func (q *stubQueue) push(body string) {
+	q.Lock()
+	defer q.Unlock()
+	q.messages = append(q.messages, &stubMessage{id: strconv.Itoa(len(q.messages)), body: body})
+}
+
+func (q *stubQueue) deletedMessages() []string {
+	q.Lock()
+	defer q.Unlock()
+	return append([]string(nil), q.deleted...)
+}
+
+func (q *stubQueue) receiveCount() int {
+	q.Lock()
+	defer q.Unlock()
+	return q.receipts
+}
+
+func (q *stubQueue) extensionCount() int {
+	q.Lock()
+	defer q.Unlock()
+	return q.extensions
+}
+
+func (q *stubQueue) handle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+
+	var request struct {
+		MaxNumberOfMessages int
+		VisibilityTimeout   int
+		ReceiptHandle       string
+		Entries             []struct {
+			Id                string //nolint:revive // name given by the protocol
+			ReceiptHandle     string
+			VisibilityTimeout int
+		}
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var response interface{}
+	switch r.Header.Get("X-Amz-Target") {
+	case "AmazonSQS.ReceiveMessage":
+		response = q.receive(r, request.MaxNumberOfMessages, request.VisibilityTimeout)
+	case "AmazonSQS.DeleteMessage":
+		q.Lock()
+		for i, msg := range q.messages {
+			if msg.handle != "" && msg.handle == request.ReceiptHandle {
+				q.deleted = append(q.deleted, msg.body)
+				q.messages = append(q.messages[:i], q.messages[i+1:]...)
+				break
+			}
+		}
+		q.Unlock()
+		response = struct{}{}
+	case "AmazonSQS.ChangeMessageVisibilityBatch":
+		type result struct {
+			Id string `json:"Id"` //nolint:revive // name given by the protocol
+		}
+		var successful []result
+		q.Lock()
+		q.extensions++
+		for _, e := range request.Entries {
+			for _, msg := range q.messages {
+				if msg.handle == e.ReceiptHandle {
+					msg.invisible = time.Now().Add(time.Duration(e.VisibilityTimeout) * time.Second)
+				}
+			}
+			successful = append(successful, result{Id: e.Id})
+		}
+		q.Unlock()
+		response = map[string]interface{}{"Successful": successful, "Failed": []struct{}{}}
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+func (q *stubQueue) receive(r *http.Request, limit, visibility int) interface{} {
+	type message struct {
+		MessageId     string `json:"MessageId"` //nolint:revive // name given by the protocol
+		ReceiptHandle string `json:"ReceiptHandle"`
+		MD5OfBody     string `json:"MD5OfBody"`
+		Body          string `json:"Body"`
+	}
+
+	// Emulate long polling by waiting a bit if there are no messages
+	for range 10 {
+		var messages []message
+		q.Lock()
+		now := time.Now()
+		for _, msg := range q.messages {
+			if len(messages) >= limit {
+				break
+			}
+			if now.Before(msg.invisible) {
+				continue
+			}
+			q.handles++
+			q.receipts++
+			msg.handle = "handle-" + strconv.Itoa(q.handles)
+			msg.invisible = now.Add(time.Duration(visibility) * time.Second)
+			sum := md5.Sum([]byte(msg.body)) //nolint:gosec // required by the SQS protocol
+			messages = append(messages, message{
+				MessageId:     msg.id,
+				ReceiptHandle: msg.handle,
+				MD5OfBody:     hex.EncodeToString(sum[:]),
+				Body:          msg.body,
+			})
+		}
+		q.Unlock()
+		if len(messages) > 0 {
+			return map[string]interface{}{"Messages": messages}
+		}
+
+		select {
+		case <-r.Context().Done():
+			return struct{}{}
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+	return struct{}{}
+}
@@ -0,0 +1,5 @@
+//go:build !custom || outputs || outputs.clickhouse
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/outputs/clickhouse" // register plugin
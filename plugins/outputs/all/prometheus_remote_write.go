@@ -0,0 +1,5 @@
+//go:build !custom || outputs || outputs.prometheus_remote_write
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/outputs/prometheus_remote_write" // register plugin
@@ -0,0 +1,5 @@
+//go:build !custom || outputs || outputs.redis_streams
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/outputs/redis_streams" // register plugin
@@ -0,0 +1,454 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package clickhouse
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	ch "github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal"
+	common_tls "github.com/influxdata/telegraf/plugins/common/tls"
+	"github.com/influxdata/telegraf/plugins/outputs"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+// Exception codes of the server denoting invalid data, e.g. values failing to
+// parse or convert to the column type or violating constraints, where the
+// insert will never succeed
+var dataErrorCodes = []int32{
+	6,   // CANNOT_PARSE_TEXT
+	26,  // CANNOT_PARSE_QUOTED_STRING
+	27,  // CANNOT_PARSE_INPUT_ASSERTION_FAILED
+	38,  // CANNOT_PARSE_DATE
+	41,  // CANNOT_PARSE_DATETIME
+	53,  // TYPE_MISMATCH
+	69,  // ARGUMENT_OUT_OF_BOUND
+	70,  // CANNOT_CONVERT_TYPE
+	72,  // CANNOT_PARSE_NUMBER
+	117, // INCORRECT_DATA
+	321, // VALUE_IS_OUT_OF_RANGE_OF_DATA_TYPE
+	349, // CANNOT_INSERT_NULL_IN_ORDINARY_COLUMN
+	469, // VIOLATED_CONSTRAINT
+}
+
+type ClickHouse struct {
+	Addresses          []string        `toml:"addresses"`
+	Database           string          `toml:"database"`
+	Username           config.Secret   `toml:"username"`
+	Password           config.Secret   `toml:"password"`
+	Timeout            config.Duration `toml:"timeout"`
+	Compression        string          `toml:"compression"`
+	TableLayout        string          `toml:"table_layout"`
+	Table              string          `toml:"table"`
+	TimestampColumn    string          `toml:"timestamp_column"`
+	CreateTables       bool            `toml:"create_tables"`
+	AlterTables        bool            `toml:"alter_tables"`
+	Engine             string          `toml:"engine"`
+	OrderBy            []string        `toml:"order_by"`
+	PartitionBy        string          `toml:"partition_by"`
+	TTL                string          `toml:"ttl"`
+	LowCardinalityTags bool            `toml:"low_cardinality_tags"`
+	AsyncInsert        bool            `toml:"async_insert"`
+	Log                telegraf.Logger `toml:"-"`
+	common_tls.ClientConfig
+
+	conn    driver.Conn
+	options *ch.Options
+	tagType string
+	tables  map[string]*table
+	ignored map[string]bool
+}
+
+func (*ClickHouse) SampleConfig() string {
+	return sampleConfig
+}
+
+func (c *ClickHouse) Init() error {
+	// Check settings
+	if len(c.Addresses) == 0 {
+		c.Addresses = []string{"localhost:9000"}
+	}
+	if c.Database == "" {
+		c.Database = "default"
+	}
+	if c.TimestampColumn == "" {
+		c.TimestampColumn = "timestamp"
+	}
+	if c.Engine == "" {
+		c.Engine = "MergeTree()"
+	}
+
+	switch c.TableLayout {
+	case "":
+		c.TableLayout = "measurement"
+	case "measurement":
+	case "wide":
+		if c.Table == "" {
+			c.Table = "telegraf"
+		}
+		if c.TimestampColumn == measurementColumn || c.TimestampColumn == tagsColumn {
+			return fmt.Errorf("timestamp column %q collides with the columns of the wide layout", c.TimestampColumn)
+		}
+	default:
+		return fmt.Errorf("invalid table layout %q", c.TableLayout)
+	}
+
+	var compression *ch.Compression
+	switch c.Compression {
+	case "", "lz4":
+		compression = &ch.Compression{Method: ch.CompressionLZ4}
+	case "zstd":
+		compression = &ch.Compression{Method: ch.CompressionZSTD}
+	case "none":
+		compression = &ch.Compression{Method: ch.CompressionNone}
+	default:
+		return fmt.Errorf("invalid compression %q", c.Compression)
+	}
+
+	c.tagType = "String"
+	if c.LowCardinalityTags {
+		c.tagType = "LowCardinality(String)"
+	}
+
+	tlsCfg, err := c.ClientConfig.TLSConfig()
+	if err != nil {
+		return fmt.Errorf("creating TLS config failed: %w", err)
+	}
+
+	c.options = &ch.Options{
+		Addr:        c.Addresses,
+		Auth:        ch.Auth{Database: c.Database},
+		TLS:         tlsCfg,
+		Compression: compression,
+		DialTimeout: time.Duration(c.Timeout),
+		ReadTimeout: time.Duration(c.Timeout),
+	}
+	if c.AsyncInsert {
+		// Wait for the data to be flushed by the server to get an error in
+		// case the insert failed
+		c.options.Settings = ch.Settings{
+			"async_insert":          1,
+			"wait_for_async_insert": 1,
+		}
+	}
+
+	c.tables = make(map[string]*table)
+	c.ignored = make(map[string]bool)
+
+	return nil
+}
+
+func (c *ClickHouse) Connect() error {
+	options := *c.options
+	if !c.Username.Empty() {
+		username, err := c.Username.Get()
+		if err != nil {
+			return fmt.Errorf("getting username failed: %w", err)
+		}
+		options.Auth.Username = username.String()
+		username.Destroy()
+	}
+	if !c.Password.Empty() {
+		password, err := c.Password.Get()
+		if err != nil {
+			return fmt.Errorf("getting password failed: %w", err)
+		}
+		options.Auth.Password = password.String()
+		password.Destroy()
+	}
+
+	conn, err := ch.Open(&options)
+	if err != nil {
+		return fmt.Errorf("opening connection failed: %w", err)
+	}
+
+	ctx, cancel := c.context()
+	defer cancel()
+	if err := conn.Ping(ctx); err != nil {
+		conn.Close() //nolint:errcheck // Ignore the error as the connection is unusable anyway
+		return &internal.StartupError{
+			Err:   fmt.Errorf("connecting to server failed: %w", err),
+			Retry: !isPermanentError(err),
+		}
+	}
+	c.conn = conn
+
+	return nil
+}
+
+func (c *ClickHouse) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+func (c *ClickHouse) Write(metrics []telegraf.Metric) error {
+	writeErr := &internal.PartialWriteError{
+		MetricsAccept: make([]int, 0, len(metrics)),
+	}
+
+	// Group the metrics by their destination table
+	var names []string
+	groups := make(map[string][]int)
+	for i, m := range metrics {
+		name := c.Table
+		if c.TableLayout == "measurement" {
+			name = m.Name()
+		}
+		if _, found := groups[name]; !found {
+			names = append(names, name)
+		}
+		groups[name] = append(groups[name], i)
+	}
+
+	ctx, cancel := c.context()
+	defer cancel()
+
+	for _, name := range names {
+		rows, err := c.writeTable(ctx, name, metrics, groups[name], writeErr)
+		if err == nil {
+			writeErr.MetricsAccept = append(writeErr.MetricsAccept, rows...)
+			continue
+		}
+
+		// Force reloading the schema as the table might have been modified
+		delete(c.tables, name)
+
+		if !isPermanentError(err) {
+			return writeErr.Result(err)
+		}
+		for _, idx := range rows {
+			writeErr.Reject(idx, err)
+		}
+	}
+
+	return writeErr.Result(nil)
+}
+
+// writeTable inserts the given metrics into the table in a single batch.
+// Metrics which cannot be converted to the table schema are rejected
+// individually. The returned indices are the metrics inserted, or in case of
+// an error, the metrics affected by the error.
+func (c *ClickHouse) writeTable(ctx context.Context, name string, metrics []telegraf.Metric, indices []int, writeErr *internal.PartialWriteError) ([]int, error) {
+	// Collect the column definitions required by the metrics
+	tags := make(map[string]string)
+	fields := make(map[string]string)
+	if c.TableLayout == "measurement" {
+		for _, idx := range indices {
+			for _, tag := range metrics[idx].TagList() {
+				if !c.reserved(tag.Key) {
+					tags[tag.Key] = c.tagType
+				}
+			}
+		}
+	}
+	for _, idx := range indices {
+		for _, field := range metrics[idx].FieldList() {
+			if _, found := fields[field.Key]; found || c.reserved(field.Key) {
+				continue
+			}
+			if _, found := tags[field.Key]; found {
+				continue
+			}
+			if datatype, err := fieldType(field.Value); err == nil {
+				fields[field.Key] = datatype
+			}
+		}
+	}
+
+	t, err := c.getTable(ctx, name, tags, fields)
+	if err != nil {
+		return indices, err
+	}
+	if t == nil {
+		return indices, fmt.Errorf("table %q does not exist", name)
+	}
+
+	// Add the columns missing in the table
+	missing := make(map[string]string)
+	for _, columns := range []map[string]string{tags, fields} {
+		for colname, datatype := range columns {
+			if _, found := t.columns[colname]; !found {
+				missing[colname] = datatype
+			}
+		}
+	}
+	if len(missing) > 0 {
+		if c.AlterTables {
+			if err := c.addColumns(ctx, t, missing); err != nil {
+				return indices, err
+			}
+		} else {
+			for colname := range missing {
+				key := name + "." + colname
+				if !c.ignored[key] {
+					c.Log.Warnf("Ignoring values for column %q not existing in table %q", colname, name)
+					c.ignored[key] = true
+				}
+			}
+		}
+	}
+
+	// Setup the columns to insert
+	colnames := []string{c.TimestampColumn}
+	if c.TableLayout == "wide" {
+		colnames = append(colnames, measurementColumn, tagsColumn)
+	}
+	for _, colname := range append(sortedKeys(tags), sortedKeys(fields)...) {
+		if _, found := t.columns[colname]; found {
+			colnames = append(colnames, colname)
+		}
+	}
+	columns := make(map[string]column, len(colnames))
+	for _, colname := range colnames {
+		datatype, found := t.columns[colname]
+		if !found {
+			return indices, fmt.Errorf("column %q not found in table %q", colname, name)
+		}
+		col, err := newColumn(datatype)
+		if err != nil {
+			return indices, &internal.WriteError{
+				Err:       fmt.Errorf("column %q of table %q: %w", colname, name, err),
+				Permanent: true,
+			}
+		}
+		columns[colname] = col
+	}
+
+	// Convert the metrics to rows
+	rows := make([]int, 0, len(indices))
+	for _, idx := range indices {
+		row, err := c.convert(metrics[idx], columns)
+		if err != nil {
+			writeErr.Reject(idx, err)
+			continue
+		}
+		for _, colname := range colnames {
+			columns[colname].append(row[colname])
+		}
+		rows = append(rows, idx)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	// Send the data to the server
+	quoted := make([]string, 0, len(colnames))
+	for _, colname := range colnames {
+		quoted = append(quoted, quoteIdent(colname))
+	}
+	query := "INSERT INTO " + c.tableIdent(name) + " (" + strings.Join(quoted, ", ") + ")"
+	batch, err := c.conn.PrepareBatch(ctx, query)
+	if err != nil {
+		return rows, fmt.Errorf("preparing insert into table %q failed: %w", name, err)
+	}
+	for i, colname := range colnames {
+		if err := batch.Column(i).Append(columns[colname].values()); err != nil {
+			//nolint:errcheck // Abort the batch on a best-effort basis
+			batch.Abort()
+			return rows, &internal.WriteError{
+				Err:       fmt.Errorf("appending column %q for table %q failed: %w", colname, name, err),
+				Permanent: true,
+			}
+		}
+	}
+	if err := batch.Send(); err != nil {
+		return rows, fmt.Errorf("inserting into table %q failed: %w", name, err)
+	}
+
+	return rows, nil
+}
+
+// convert returns the values of the metric for the given columns converted
+// to the column types
+func (c *ClickHouse) convert(m telegraf.Metric, columns map[string]column) (map[string]interface{}, error) {
+	row := map[string]interface{}{c.TimestampColumn: m.Time()}
+
+	if c.TableLayout == "wide" {
+		row[measurementColumn] = m.Name()
+		row[tagsColumn] = m.Tags()
+	} else {
+		for _, tag := range m.TagList() {
+			if c.reserved(tag.Key) {
+				return nil, fmt.Errorf("tag %q collides with the timestamp column", tag.Key)
+			}
+			if _, found := columns[tag.Key]; found {
+				row[tag.Key] = tag.Value
+			}
+		}
+	}
+
+	for _, field := range m.FieldList() {
+		if c.reserved(field.Key) {
+			return nil, fmt.Errorf("field %q collides with a reserved column", field.Key)
+		}
+		if _, found := row[field.Key]; found {
+			return nil, fmt.Errorf("field %q collides with a tag", field.Key)
+		}
+		if _, found := columns[field.Key]; found {
+			row[field.Key] = field.Value
+		}
+	}
+
+	for colname, value := range row {
+		v, err := columns[colname].convert(value)
+		if err != nil {
+			return nil, fmt.Errorf("converting value of column %q failed: %w", colname, err)
+		}
+		row[colname] = v
+	}
+	return row, nil
+}
+
+// reserved checks if the given name is one of the columns of the layout
+func (c *ClickHouse) reserved(name string) bool {
+	if name == c.TimestampColumn {
+		return true
+	}
+	return c.TableLayout == "wide" && (name == measurementColumn || name == tagsColumn)
+}
+
+func (c *ClickHouse) context() (context.Context, context.CancelFunc) {
+	if c.Timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), time.Duration(c.Timeout))
+}
+
+// isPermanentError checks if the error is caused by the data of the metrics
+// so the insert will never succeed. All other errors, e.g. connection errors,
+// authentication failures or temporary conditions on the server, are retried.
+func isPermanentError(err error) bool {
+	var exception *ch.Exception
+	if errors.As(err, &exception) {
+		return slices.Contains(dataErrorCodes, exception.Code)
+	}
+
+	var werr *internal.WriteError
+	return errors.As(err, &werr) && werr.Permanent
+}
+
+func init() {
+	outputs.Add("clickhouse", func() telegraf.Output {
+		return &ClickHouse{
+			Timeout:            config.Duration(5 * time.Second),
+			CreateTables:       true,
+			AlterTables:        true,
+			LowCardinalityTags: true,
+		}
+	})
+}
@@ -0,0 +1,534 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	ch "github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/docker/go-connections/nat"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go/wait"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *ClickHouse
+		expected string
+	}{
+		{
+			name:     "invalid layout",
+			plugin:   &ClickHouse{TableLayout: "foo"},
+			expected: `invalid table layout "foo"`,
+		},
+		{
+			name:     "wide layout with colliding timestamp",
+			plugin:   &ClickHouse{TableLayout: "wide", Table: "telegraf", TimestampColumn: "tags"},
+			expected: `timestamp column "tags" collides with the columns of the wide layout`,
+		},
+		{
+			name:     "invalid compression",
+			plugin:   &ClickHouse{Compression: "gzip"},
+			expected: `invalid compression "gzip"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.plugin.Log = testutil.Logger{}
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestCreateTableStatement(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *ClickHouse
+		expected string
+	}{
+		{
+			name:   "measurement layout",
+			plugin: &ClickHouse{},
+			expected: "CREATE TABLE IF NOT EXISTS `default`.`cpu` (`timestamp` DateTime64(9), " +
+				"`cpu` LowCardinality(String), `host` LowCardinality(String), " +
+				"`usage` Nullable(Float64), `value` Nullable(Int64)) " +
+				"ENGINE = MergeTree() ORDER BY (`cpu`, `host`, `timestamp`)",
+		},
+		{
+			name: "custom table settings",
+			plugin: &ClickHouse{
+				Database:    "telegraf",
+				Engine:      "ReplacingMergeTree()",
+				OrderBy:     []string{"host", "timestamp"},
+				PartitionBy: "toYYYYMM(timestamp)",
+				TTL:         "toDateTime(timestamp) + INTERVAL 30 DAY",
+			},
+			expected: "CREATE TABLE IF NOT EXISTS `telegraf`.`cpu` (`timestamp` DateTime64(9), " +
+				"`cpu` LowCardinality(String), `host` LowCardinality(String), " +
+				"`usage` Nullable(Float64), `value` Nullable(Int64)) " +
+				"ENGINE = ReplacingMergeTree() PARTITION BY toYYYYMM(timestamp) " +
+				"ORDER BY (`host`, `timestamp`) TTL toDateTime(timestamp) + INTERVAL 30 DAY",
+		},
+		{
+			name:   "wide layout",
+			plugin: &ClickHouse{TableLayout: "wide", Table: "cpu", TimestampColumn: "time"},
+			expected: "CREATE TABLE IF NOT EXISTS `default`.`cpu` (`time` DateTime64(9), " +
+				"`measurement` LowCardinality(String), `tags` Map(LowCardinality(String), String), " +
+				"`usage` Nullable(Float64), `value` Nullable(Int64)) " +
+				"ENGINE = MergeTree() ORDER BY (`measurement`, `time`)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := tt.plugin
+			plugin.LowCardinalityTags = true
+			plugin.Log = testutil.Logger{}
+			require.NoError(t, plugin.Init())
+
+			var tags map[string]string
+			if plugin.TableLayout == "measurement" {
+				tags = map[string]string{"host": plugin.tagType, "cpu": plugin.tagType}
+			}
+			fields := map[string]string{"usage": "Nullable(Float64)", "value": "Nullable(Int64)"}
+			require.Equal(t, tt.expected, plugin.createTableStatement("cpu", tags, fields))
+		})
+	}
+}
+
+func TestWriteMeasurementLayout(t *testing.T) {
+	conn := newFakeConn()
+	plugin := &ClickHouse{
+		CreateTables:       true,
+		AlterTables:        true,
+		LowCardinalityTags: true,
+		Log:                testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	plugin.conn = conn
+
+	first := []telegraf.Metric{
+		metric.New(
+			"cpu",
+			map[string]string{"host": "a"},
+			map[string]interface{}{"usage": 42.5, "count": int64(3)},
+			time.Unix(1, 0),
+		),
+		metric.New(
+			"cpu",
+			map[string]string{},
+			map[string]interface{}{"usage": 23.0},
+			time.Unix(2, 0),
+		),
+		metric.New(
+			"mem",
+			map[string]string{"host": "a"},
+			map[string]interface{}{"free": uint64(1024), "swap": true},
+			time.Unix(3, 0),
+		),
+	}
+	require.NoError(t, plugin.Write(first))
+
+	require.Equal(t, []string{
+		"CREATE TABLE IF NOT EXISTS `default`.`cpu` (`timestamp` DateTime64(9), " +
+			"`host` LowCardinality(String), `count` Nullable(Int64), `usage` Nullable(Float64)) " +
+			"ENGINE = MergeTree() ORDER BY (`host`, `timestamp`)",
+		"CREATE TABLE IF NOT EXISTS `default`.`mem` (`timestamp` DateTime64(9), " +
+			"`host` LowCardinality(String), `free` Nullable(UInt64), `swap` Nullable(Bool)) " +
+			"ENGINE = MergeTree() ORDER BY (`host`, `timestamp`)",
+	}, conn.statements)
+
+	require.Equal(t, map[string]interface{}{
+		"timestamp": []time.Time{time.Unix(1, 0), time.Unix(2, 0)},
+		"host":      []string{"a", ""},
+		"count":     []*int64{ptr(int64(3)), nil},
+		"usage":     []*float64{ptr(42.5), ptr(23.0)},
+	}, conn.inserts["cpu"])
+	require.Equal(t, map[string]interface{}{
+		"timestamp": []time.Time{time.Unix(3, 0)},
+		"host":      []string{"a"},
+		"free":      []*uint64{ptr(uint64(1024))},
+		"swap":      []*bool{ptr(true)},
+	}, conn.inserts["mem"])
+
+	// Write metrics with a new tag and field requiring to alter the table
+	conn.statements = nil
+	second := []telegraf.Metric{
+		metric.New(
+			"cpu",
+			map[string]string{"host": "b", "cpu": "cpu0"},
+			map[string]interface{}{"usage": 12.5, "state": "idle"},
+			time.Unix(4, 0),
+		),
+	}
+	require.NoError(t, plugin.Write(second))
+	require.Equal(t, []string{
+		"ALTER TABLE `default`.`cpu` ADD COLUMN IF NOT EXISTS `cpu` LowCardinality(String), " +
+			"ADD COLUMN IF NOT EXISTS `state` Nullable(String)",
+	}, conn.statements)
+	require.Equal(t, map[string]interface{}{
+		"timestamp": []time.Time{time.Unix(4, 0)},
+		"cpu":       []string{"cpu0"},
+		"host":      []string{"b"},
+		"state":     []*string{ptr("idle")},
+		"usage":     []*float64{ptr(12.5)},
+	}, conn.inserts["cpu"])
+}
+
+func TestWriteWideLayout(t *testing.T) {
+	conn := newFakeConn()
+	plugin := &ClickHouse{
+		TableLayout:  "wide",
+		Table:        "telegraf",
+		CreateTables: true,
+		AlterTables:  true,
+		Log:          testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	plugin.conn = conn
+
+	metrics := []telegraf.Metric{
+		metric.New(
+			"cpu",
+			map[string]string{"host": "a", "cpu": "cpu0"},
+			map[string]interface{}{"usage": 42.5},
+			time.Unix(1, 0),
+		),
+		metric.New(
+			"mem",
+			map[string]string{"host": "a"},
+			map[string]interface{}{"free": int64(1024)},
+			time.Unix(2, 0),
+		),
+	}
+	require.NoError(t, plugin.Write(metrics))
+
+	require.Equal(t, []string{
+		"CREATE TABLE IF NOT EXISTS `default`.`telegraf` (`timestamp` DateTime64(9), " +
+			"`measurement` LowCardinality(String), `tags` Map(LowCardinality(String), String), " +
+			"`free` Nullable(Int64), `usage` Nullable(Float64)) " +
+			"ENGINE = MergeTree() ORDER BY (`measurement`, `timestamp`)",
+	}, conn.statements)
+	require.Equal(t, map[string]interface{}{
+		"timestamp":   []time.Time{time.Unix(1, 0), time.Unix(2, 0)},
+		"measurement": []string{"cpu", "mem"},
+		"tags": []map[string]string{
+			{"host": "a", "cpu": "cpu0"},
+			{"host": "a"},
+		},
+		"free":  []*int64{nil, ptr(int64(1024))},
+		"usage": []*float64{ptr(42.5), nil},
+	}, conn.inserts["telegraf"])
+}
+
+func TestWriteExistingTable(t *testing.T) {
+	conn := newFakeConn()
+	conn.tables["cpu"] = []columnInfo{
+		{Name: "time", Type: "DateTime"},
+		{Name: "host", Type: "String"},
+		{Name: "usage", Type: "Float32"},
+	}
+	plugin := &ClickHouse{
+		TimestampColumn: "time",
+		CreateTables:    true,
+		Log:             testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	plugin.conn = conn
+
+	metrics := []telegraf.Metric{
+		metric.New(
+			"cpu",
+			map[string]string{"host": "a"},
+			map[string]interface{}{"usage": int64(42), "unknown": "foo"},
+			time.Unix(1, 0),
+		),
+		metric.New(
+			"cpu",
+			map[string]string{"host": "b"},
+			map[string]interface{}{"usage": "not a number"},
+			time.Unix(2, 0),
+		),
+	}
+
+	// Columns not existing in the table must be ignored as altering the
+	// table is disabled and values must be converted to the column types.
+	// Metrics with values not convertible must be rejected.
+	err := plugin.Write(metrics)
+	var writeErr *internal.PartialWriteError
+	require.ErrorAs(t, err, &writeErr)
+	require.Equal(t, []int{0}, writeErr.MetricsAccept)
+	require.Equal(t, []int{1}, writeErr.MetricsReject)
+	require.ErrorContains(t, writeErr.MetricsRejectErrors[0], `converting value of column "usage" failed`)
+
+	require.Empty(t, conn.statements)
+	require.Equal(t, map[string]interface{}{
+		"time":  []time.Time{time.Unix(1, 0)},
+		"host":  []string{"a"},
+		"usage": []float32{42},
+	}, conn.inserts["cpu"])
+}
+
+func TestWriteTableMissing(t *testing.T) {
+	plugin := &ClickHouse{Log: testutil.Logger{}}
+	require.NoError(t, plugin.Init())
+	plugin.conn = newFakeConn()
+
+	metrics := []telegraf.Metric{
+		metric.New("cpu", map[string]string{}, map[string]interface{}{"value": 42.0}, time.Unix(1, 0)),
+	}
+
+	// The metrics must be kept as the table might be created later
+	err := plugin.Write(metrics)
+	var writeErr *internal.PartialWriteError
+	require.ErrorAs(t, err, &writeErr)
+	require.Empty(t, writeErr.MetricsAccept)
+	require.Empty(t, writeErr.MetricsReject)
+	require.ErrorContains(t, writeErr.Err, `table "cpu" does not exist`)
+}
+
+func TestWritePartialFailure(t *testing.T) {
+	conn := newFakeConn()
+	conn.sendErr = map[string]error{
+		"broken":   &ch.Exception{Code: 53, Name: "TYPE_MISMATCH", Message: "type mismatch"},
+		"overload": &ch.Exception{Code: 252, Name: "TOO_MANY_PARTS", Message: "too many parts"},
+	}
+	plugin := &ClickHouse{
+		CreateTables: true,
+		AlterTables:  true,
+		Log:          testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	plugin.conn = conn
+
+	metrics := []telegraf.Metric{
+		metric.New("ok", map[string]string{}, map[string]interface{}{"value": 1.0}, time.Unix(1, 0)),
+		metric.New("broken", map[string]string{}, map[string]interface{}{"value": 2.0}, time.Unix(2, 0)),
+		metric.New("ok", map[string]string{}, map[string]interface{}{"value": 3.0}, time.Unix(3, 0)),
+		metric.New("overload", map[string]string{}, map[string]interface{}{"value": 4.0}, time.Unix(4, 0)),
+		metric.New("other", map[string]string{}, map[string]interface{}{"value": 5.0}, time.Unix(5, 0)),
+	}
+
+	// Metrics of tables with permanent errors must be rejected while the
+	// ones affected by a temporary error must be kept including all metrics
+	// not written yet.
+	err := plugin.Write(metrics)
+	var writeErr *internal.PartialWriteError
+	require.ErrorAs(t, err, &writeErr)
+	require.Equal(t, []int{0, 2}, writeErr.MetricsAccept)
+	require.Equal(t, []int{1}, writeErr.MetricsReject)
+	require.ErrorContains(t, writeErr.MetricsRejectErrors[0], "code: 53")
+	require.ErrorContains(t, writeErr.Err, "code: 252")
+	require.NotContains(t, conn.inserts, "other")
+
+	// The schema of the failed tables must be reloaded on the next write
+	require.Contains(t, plugin.tables, "ok")
+	require.NotContains(t, plugin.tables, "broken")
+	require.NotContains(t, plugin.tables, "overload")
+}
+
+func TestPermanentErrors(t *testing.T) {
+	require.True(t, isPermanentError(&ch.Exception{Code: 53}))
+	require.True(t, isPermanentError(fmt.Errorf("inserting failed: %w", &ch.Exception{Code: 469})))
+	require.True(t, isPermanentError(&internal.WriteError{Err: errors.New("unsupported column type"), Permanent: true}))
+	require.False(t, isPermanentError(&ch.Exception{Code: 319}))
+	require.False(t, isPermanentError(&ch.Exception{Code: 394, Name: "QUERY_WAS_CANCELLED"}))
+	require.False(t, isPermanentError(&ch.Exception{Code: 516, Name: "AUTHENTICATION_FAILED"}))
+	require.False(t, isPermanentError(&ch.Exception{Code: 60, Name: "UNKNOWN_TABLE"}))
+	require.False(t, isPermanentError(fmt.Errorf("inserting failed: %w", context.DeadlineExceeded)))
+	require.False(t, isPermanentError(ch.ErrAcquireConnTimeout))
+	require.False(t, isPermanentError(errors.New("table does not exist")))
+}
+
+func TestIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	password := testutil.GetRandomString(16)
+	servicePort := "9000"
+	container := testutil.Container{
+		Image: "clickhouse/clickhouse-server",
+		Env: map[string]string{
+			"CLICKHOUSE_USER":     "telegraf",
+			"CLICKHOUSE_PASSWORD": password,
+		},
+		ExposedPorts: []string{servicePort, "8123"},
+		WaitingFor: wait.ForAll(
+			wait.NewHTTPStrategy("/").WithPort(nat.Port("8123")),
+			wait.ForListeningPort(nat.Port(servicePort)),
+			wait.ForLog("Ready for connections"),
+		),
+	}
+	require.NoError(t, container.Start(), "failed to start container")
+	defer container.Terminate()
+
+	for _, layout := range []string{"measurement", "wide"} {
+		t.Run(layout, func(t *testing.T) {
+			plugin := &ClickHouse{
+				Addresses:          []string{container.Address + ":" + container.Ports[servicePort]},
+				Username:           config.NewSecret([]byte("telegraf")),
+				Password:           config.NewSecret([]byte(password)),
+				Timeout:            config.Duration(10 * time.Second),
+				TableLayout:        layout,
+				Table:              "telegraf",
+				CreateTables:       true,
+				AlterTables:        true,
+				LowCardinalityTags: true,
+				AsyncInsert:        layout == "wide",
+				Log:                testutil.Logger{},
+			}
+			require.NoError(t, plugin.Init())
+			require.NoError(t, plugin.Connect())
+			defer plugin.Close()
+
+			metrics := []telegraf.Metric{
+				metric.New(
+					"cpu_"+layout,
+					map[string]string{"host": "a"},
+					map[string]interface{}{"usage": 42.5, "count": int64(3)},
+					time.Unix(1, 0),
+				),
+				metric.New(
+					"cpu_"+layout,
+					map[string]string{"host": "b", "cpu": "cpu0"},
+					map[string]interface{}{"usage": 23.0, "state": "idle", "active": true},
+					time.Unix(2, 0),
+				),
+			}
+			require.NoError(t, plugin.Write(metrics[:1]))
+			require.NoError(t, plugin.Write(metrics[1:]))
+
+			table := "cpu_" + layout
+			if layout == "wide" {
+				table = "telegraf"
+			}
+			var count uint64
+			query := "SELECT count() FROM " + plugin.tableIdent(table) + " WHERE usage > 20"
+			require.NoError(t, plugin.conn.QueryRow(t.Context(), query).Scan(&count))
+			require.Equal(t, uint64(2), count)
+		})
+	}
+}
+
+// fakeConn emulates the parts of a ClickHouse connection used by the plugin
+// keeping track of the tables, the executed statements and inserted data
+type fakeConn struct {
+	driver.Conn
+
+	tables     map[string][]columnInfo
+	statements []string
+	inserts    map[string]map[string]interface{}
+	sendErr    map[string]error
+}
+
+var (
+	reCreate = regexp.MustCompile("^CREATE TABLE IF NOT EXISTS `[^`]+`\\.`([^`]+)` \\((.*)\\) ENGINE")
+	reAlter  = regexp.MustCompile("^ALTER TABLE `[^`]+`\\.`([^`]+)` (.*)$")
+	reColumn = regexp.MustCompile("^`([^`]+)` (.*)$")
+	reInsert = regexp.MustCompile("^INSERT INTO `[^`]+`\\.`([^`]+)` \\((.*)\\)$")
+)
+
+func newFakeConn() *fakeConn {
+	return &fakeConn{
+		tables:  make(map[string][]columnInfo),
+		inserts: make(map[string]map[string]interface{}),
+	}
+}
+
+func (c *fakeConn) Select(_ context.Context, dest any, _ string, args ...any) error {
+	*dest.(*[]columnInfo) = slices.Clone(c.tables[args[1].(string)])
+	return nil
+}
+
+func (c *fakeConn) Exec(_ context.Context, query string, _ ...any) error {
+	c.statements = append(c.statements, query)
+
+	if match := reCreate.FindStringSubmatch(query); match != nil {
+		for _, def := range strings.Split(match[2], ", `") {
+			c.addColumn(match[1], strings.TrimPrefix(def, "`"))
+		}
+		return nil
+	}
+	if match := reAlter.FindStringSubmatch(query); match != nil {
+		for _, clause := range strings.Split(match[2], ", ") {
+			c.addColumn(match[1], strings.TrimPrefix(clause, "ADD COLUMN IF NOT EXISTS `"))
+		}
+		return nil
+	}
+	return fmt.Errorf("unexpected statement %q", query)
+}
+
+func (c *fakeConn) addColumn(table, def string) {
+	match := reColumn.FindStringSubmatch("`" + def)
+	c.tables[table] = append(c.tables[table], columnInfo{Name: match[1], Type: match[2]})
+}
+
+func (c *fakeConn) PrepareBatch(_ context.Context, query string, _ ...driver.PrepareBatchOption) (driver.Batch, error) {
+	match := reInsert.FindStringSubmatch(query)
+	if match == nil {
+		return nil, fmt.Errorf("unexpected insert %q", query)
+	}
+	columns := strings.Split(strings.ReplaceAll(match[2], "`", ""), ", ")
+	return &fakeBatch{
+		conn:    c,
+		table:   match[1],
+		columns: columns,
+		data:    make(map[string]interface{}, len(columns)),
+	}, nil
+}
+
+type fakeBatch struct {
+	driver.Batch
+
+	conn    *fakeConn
+	table   string
+	columns []string
+	data    map[string]interface{}
+}
+
+func (b *fakeBatch) Column(idx int) driver.BatchColumn {
+	return &fakeColumn{batch: b, name: b.columns[idx]}
+}
+
+func (*fakeBatch) Abort() error {
+	return nil
+}
+
+func (b *fakeBatch) Send() error {
+	if err := b.conn.sendErr[b.table]; err != nil {
+		return err
+	}
+	b.conn.inserts[b.table] = b.data
+	return nil
+}
+
+type fakeColumn struct {
+	driver.BatchColumn
+
+	batch *fakeBatch
+	name  string
+}
+
+func (c *fakeColumn) Append(v any) error {
+	c.batch.data[c.name] = v
+	return nil
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
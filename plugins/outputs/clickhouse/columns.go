@@ -0,0 +1,156 @@
+package clickhouse
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf/internal"
+)
+
+// column accumulates the values of a single column for a columnar insert
+type column interface {
+	// convert checks and converts the value to the type of the column
+	convert(v interface{}) (interface{}, error)
+	// append adds a converted value or nil for a missing value
+	append(v interface{})
+	// values returns the accumulated values to be sent to the server
+	values() interface{}
+}
+
+type typedColumn[T any] struct {
+	conv     func(interface{}) (T, error)
+	nullable bool
+	data     []T
+	ptrs     []*T
+}
+
+func (c *typedColumn[T]) convert(v interface{}) (interface{}, error) {
+	return c.conv(v)
+}
+
+func (c *typedColumn[T]) append(v interface{}) {
+	if c.nullable {
+		if v == nil {
+			c.ptrs = append(c.ptrs, nil)
+			return
+		}
+		value := v.(T)
+		c.ptrs = append(c.ptrs, &value)
+		return
+	}
+
+	// Use the default value of the type for missing values of non-nullable
+	// columns, e.g. for tags not present in all metrics
+	if v == nil {
+		var zero T
+		c.data = append(c.data, zero)
+		return
+	}
+	c.data = append(c.data, v.(T))
+}
+
+func (c *typedColumn[T]) values() interface{} {
+	if c.nullable {
+		return c.ptrs
+	}
+	return c.data
+}
+
+func newTypedColumn[T any](conv func(interface{}) (T, error), nullable bool) column {
+	return &typedColumn[T]{conv: conv, nullable: nullable}
+}
+
+// newColumn creates a column builder for the given ClickHouse data type
+func newColumn(datatype string) (column, error) {
+	base, nullable := baseType(datatype)
+
+	switch {
+	case base == "Int8":
+		return newTypedColumn(internal.ToInt8, nullable), nil
+	case base == "Int16":
+		return newTypedColumn(internal.ToInt16, nullable), nil
+	case base == "Int32":
+		return newTypedColumn(internal.ToInt32, nullable), nil
+	case base == "Int64":
+		return newTypedColumn(internal.ToInt64, nullable), nil
+	case base == "UInt8":
+		return newTypedColumn(internal.ToUint8, nullable), nil
+	case base == "UInt16":
+		return newTypedColumn(internal.ToUint16, nullable), nil
+	case base == "UInt32":
+		return newTypedColumn(internal.ToUint32, nullable), nil
+	case base == "UInt64":
+		return newTypedColumn(internal.ToUint64, nullable), nil
+	case base == "Float32":
+		return newTypedColumn(internal.ToFloat32, nullable), nil
+	case base == "Float64":
+		return newTypedColumn(internal.ToFloat64, nullable), nil
+	case base == "Bool":
+		return newTypedColumn(internal.ToBool, nullable), nil
+	case base == "String":
+		return newTypedColumn(internal.ToString, nullable), nil
+	case base == "DateTime" || strings.HasPrefix(base, "DateTime64("):
+		return newTypedColumn(toTime, nullable), nil
+	case strings.HasPrefix(base, "Map("):
+		key, value, found := strings.Cut(strings.TrimSuffix(strings.TrimPrefix(base, "Map("), ")"), ",")
+		if found {
+			key, _ = baseType(strings.TrimSpace(key))
+			value, _ = baseType(strings.TrimSpace(value))
+			if key == "String" && value == "String" {
+				return newTypedColumn(toStringMap, nullable), nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("unsupported column type %q", datatype)
+}
+
+// baseType strips the 'LowCardinality' and 'Nullable' wrappers from the given
+// data type and returns if the type is nullable
+func baseType(datatype string) (string, bool) {
+	if inner, found := unwrap(datatype, "LowCardinality"); found {
+		datatype = inner
+	}
+	if inner, found := unwrap(datatype, "Nullable"); found {
+		return inner, true
+	}
+	return datatype, false
+}
+
+func unwrap(datatype, wrapper string) (string, bool) {
+	if !strings.HasPrefix(datatype, wrapper+"(") || !strings.HasSuffix(datatype, ")") {
+		return datatype, false
+	}
+	return datatype[len(wrapper)+1 : len(datatype)-1], true
+}
+
+func toTime(v interface{}) (time.Time, error) {
+	if t, ok := v.(time.Time); ok {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("type %T cannot be converted to a timestamp", v)
+}
+
+func toStringMap(v interface{}) (map[string]string, error) {
+	if m, ok := v.(map[string]string); ok {
+		return m, nil
+	}
+	return nil, fmt.Errorf("type %T cannot be converted to a map", v)
+}
+
+// fieldType returns the ClickHouse data type used for new field columns
+func fieldType(v interface{}) (string, error) {
+	switch v.(type) {
+	case int64:
+		return "Nullable(Int64)", nil
+	case uint64:
+		return "Nullable(UInt64)", nil
+	case float64:
+		return "Nullable(Float64)", nil
+	case bool:
+		return "Nullable(Bool)", nil
+	case string:
+		return "Nullable(String)", nil
+	}
+	return "", fmt.Errorf("unsupported field type %T", v)
+}
@@ -0,0 +1,159 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Names of the fixed columns of the wide table layout
+const (
+	measurementColumn = "measurement"
+	tagsColumn        = "tags"
+)
+
+type columnInfo struct {
+	Name string `ch:"name"`
+	Type string `ch:"type"`
+}
+
+// table holds the known schema of a table in the database
+type table struct {
+	name    string
+	columns map[string]string
+}
+
+// quoteIdent quotes the given identifier for use in statements
+func quoteIdent(name string) string {
+	return "`" + strings.ReplaceAll(strings.ReplaceAll(name, `\`, `\\`), "`", "\\`") + "`"
+}
+
+func (c *ClickHouse) tableIdent(name string) string {
+	return quoteIdent(c.Database) + "." + quoteIdent(name)
+}
+
+// getTable returns the schema of the given table, creating the table if it
+// does not exist and table creation is enabled. The returned table is nil if
+// the table does not exist.
+func (c *ClickHouse) getTable(ctx context.Context, name string, tags, fields map[string]string) (*table, error) {
+	if t, found := c.tables[name]; found {
+		return t, nil
+	}
+
+	t, err := c.loadTable(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil && c.CreateTables {
+		if err := c.conn.Exec(ctx, c.createTableStatement(name, tags, fields)); err != nil {
+			return nil, fmt.Errorf("creating table %q failed: %w", name, err)
+		}
+		if t, err = c.loadTable(ctx, name); err != nil {
+			return nil, err
+		}
+	}
+	if t == nil {
+		return nil, nil
+	}
+	c.tables[name] = t
+	return t, nil
+}
+
+// loadTable reads the column definitions of the given table from the database
+func (c *ClickHouse) loadTable(ctx context.Context, name string) (*table, error) {
+	var infos []columnInfo
+	query := "SELECT name, type FROM system.columns WHERE database = ? AND table = ?"
+	if err := c.conn.Select(ctx, &infos, query, c.Database, name); err != nil {
+		return nil, fmt.Errorf("reading columns of table %q failed: %w", name, err)
+	}
+	if len(infos) == 0 {
+		return nil, nil
+	}
+
+	t := &table{name: name, columns: make(map[string]string, len(infos))}
+	for _, info := range infos {
+		t.columns[info.Name] = info.Type
+	}
+	return t, nil
+}
+
+// addColumns adds the given columns to the table if they do not exist yet
+// and updates the known schema
+func (c *ClickHouse) addColumns(ctx context.Context, t *table, columns map[string]string) error {
+	names := sortedKeys(columns)
+	clauses := make([]string, 0, len(names))
+	for _, name := range names {
+		clauses = append(clauses, "ADD COLUMN IF NOT EXISTS "+quoteIdent(name)+" "+columns[name])
+	}
+	stmt := "ALTER TABLE " + c.tableIdent(t.name) + " " + strings.Join(clauses, ", ")
+	if err := c.conn.Exec(ctx, stmt); err != nil {
+		return fmt.Errorf("adding columns to table %q failed: %w", t.name, err)
+	}
+
+	// Reload the schema as other instances might have added the columns with
+	// a different type in the meantime
+	updated, err := c.loadTable(ctx, t.name)
+	if err != nil {
+		return err
+	}
+	if updated != nil {
+		t.columns = updated.columns
+	}
+	return nil
+}
+
+// createTableStatement generates the statement to create a table with the
+// fixed columns of the layout as well as the given tag and field columns
+func (c *ClickHouse) createTableStatement(name string, tags, fields map[string]string) string {
+	defs := []string{quoteIdent(c.TimestampColumn) + " DateTime64(9)"}
+	if c.TableLayout == "wide" {
+		defs = append(defs,
+			quoteIdent(measurementColumn)+" LowCardinality(String)",
+			quoteIdent(tagsColumn)+" Map(LowCardinality(String), String)",
+		)
+	}
+	tagNames := sortedKeys(tags)
+	for _, colname := range tagNames {
+		defs = append(defs, quoteIdent(colname)+" "+tags[colname])
+	}
+	for _, colname := range sortedKeys(fields) {
+		defs = append(defs, quoteIdent(colname)+" "+fields[colname])
+	}
+
+	// Determine the sorting key
+	orderBy := c.OrderBy
+	if len(orderBy) == 0 {
+		if c.TableLayout == "wide" {
+			orderBy = []string{measurementColumn, c.TimestampColumn}
+		} else {
+			orderBy = append(tagNames, c.TimestampColumn)
+		}
+	}
+	keys := make([]string, 0, len(orderBy))
+	for _, k := range orderBy {
+		keys = append(keys, quoteIdent(k))
+	}
+
+	var stmt strings.Builder
+	stmt.WriteString("CREATE TABLE IF NOT EXISTS " + c.tableIdent(name))
+	stmt.WriteString(" (" + strings.Join(defs, ", ") + ")")
+	stmt.WriteString(" ENGINE = " + c.Engine)
+	if c.PartitionBy != "" {
+		stmt.WriteString(" PARTITION BY " + c.PartitionBy)
+	}
+	stmt.WriteString(" ORDER BY (" + strings.Join(keys, ", ") + ")")
+	if c.TTL != "" {
+		stmt.WriteString(" TTL " + c.TTL)
+	}
+	return stmt.String()
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -21,6 +21,7 @@ import (
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal"
 	"github.com/influxdata/telegraf/plugins/common/tls"
 	"github.com/influxdata/telegraf/plugins/outputs"
 )
@@ -367,22 +368,55 @@ func (a *Elasticsearch) Write(metrics []telegraf.Metric) error {
 		return fmt.Errorf("error sending bulk request to Elasticsearch: %w", err)
 	}
 
-	if res.Errors {
-		for id, err := range res.Failed() {
-			a.Log.Errorf(
-				"Elasticsearch indexing failure, id: %d, status: %d, error: %s, caused by: %s, %s",
-				id,
-				err.Status,
-				err.Error.Reason,
-				err.Error.CausedBy["reason"],
-				err.Error.CausedBy["type"],
-			)
-			break
-		}
+	if !res.Errors {
+		return nil
+	}
+
+	// The items are returned in the order of the requests, so we can map the
+	// result back to the metrics
+	if len(res.Items) != len(metrics) {
 		return fmt.Errorf("elasticsearch failed to index %d metrics", len(res.Failed()))
 	}
 
-	return nil
+	writeErr := &internal.PartialWriteError{
+		MetricsAccept: make([]int, 0, len(metrics)),
+	}
+	var failed int
+	for i, item := range res.Items {
+		for _, result := range item {
+			if result.Status >= 200 && result.Status <= 299 {
+				writeErr.MetricsAccept = append(writeErr.MetricsAccept, i)
+				continue
+			}
+
+			reason := "unknown"
+			failed++
+			if result.Error != nil {
+				reason = result.Error.Reason
+				if failed == 1 {
+					a.Log.Errorf(
+						"Elasticsearch indexing failure, id: %d, status: %d, error: %s, caused by: %s, %s",
+						i,
+						result.Status,
+						result.Error.Reason,
+						result.Error.CausedBy["reason"],
+						result.Error.CausedBy["type"],
+					)
+				}
+			}
+
+			// Keep the metrics failing due to overload or server errors for
+			// retrying, all other failures such as mapping errors will never
+			// succeed
+			if result.Status == http.StatusTooManyRequests || result.Status >= 500 {
+				continue
+			}
+			writeErr.Reject(i, fmt.Errorf("status %d: %s", result.Status, reason))
+		}
+	}
+	writeErr.Err = fmt.Errorf("elasticsearch failed to index %d metrics", failed)
+
+	return writeErr
 }
 
 func (a *Elasticsearch) manageTemplate(ctx context.Context) error {
@@ -8,10 +8,13 @@ import (
 	"crypto/sha256"
 	_ "embed"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -60,6 +63,9 @@ type HTTP struct {
 	awsCfg *aws.Config
 	common_aws.CredentialConfig
 
+	// Protects the serializer and the token for concurrent writes
+	mu sync.Mutex
+
 	// Google API Auth
 	CredentialsFile string `toml:"google_application_credentials"`
 	oauth2Token     *oauth2.Token
@@ -108,9 +114,14 @@ func (h *HTTP) Close() error {
 	return nil
 }
 
+// SupportsConcurrentWrites marks the output as safe for concurrent writes
+func (*HTTP) SupportsConcurrentWrites() {}
+
 func (h *HTTP) Write(metrics []telegraf.Metric) error {
 	if h.UseBatchFormat {
+		h.mu.Lock()
 		reqBody, err := h.serializer.SerializeBatch(metrics)
+		h.mu.Unlock()
 		if err != nil {
 			return err
 		}
@@ -118,17 +129,33 @@ func (h *HTTP) Write(metrics []telegraf.Metric) error {
 		return h.writeMetric(reqBody)
 	}
 
-	for _, metric := range metrics {
+	writeErr := &internal.PartialWriteError{
+		MetricsAccept: make([]int, 0, len(metrics)),
+	}
+	for i, metric := range metrics {
+		h.mu.Lock()
 		reqBody, err := h.serializer.Serialize(metric)
+		h.mu.Unlock()
 		if err != nil {
 			return err
 		}
 
 		if err := h.writeMetric(reqBody); err != nil {
-			return err
+			// Only reject the metric refused by the server and continue with
+			// the remaining ones
+			var werr *internal.WriteError
+			if errors.As(err, &werr) && werr.Permanent {
+				writeErr.Reject(i, err)
+				continue
+			}
+			if len(writeErr.MetricsAccept) == 0 && len(writeErr.MetricsReject) == 0 {
+				return err
+			}
+			return writeErr.Result(err)
 		}
+		writeErr.MetricsAccept = append(writeErr.MetricsAccept, i)
 	}
-	return nil
+	return writeErr.Result(nil)
 }
 
 func (h *HTTP) writeMetric(reqBody []byte) error {
@@ -236,14 +263,21 @@ func (h *HTTP) writeMetric(reqBody []byte) error {
 			errorLine = scanner.Text()
 		}
 
-		for _, nonRetryableStatusCode := range h.NonRetryableStatusCodes {
-			if resp.StatusCode == nonRetryableStatusCode {
-				h.Log.Errorf("Received non-retryable status %v. Metrics are lost. body: %s", resp.StatusCode, errorLine)
-				return nil
-			}
+		err := fmt.Errorf("when writing to [%s] received status code: %d. body: %s", h.URL, resp.StatusCode, errorLine)
+
+		// Reject the metrics as the server will never accept them
+		if slices.Contains(h.NonRetryableStatusCodes, resp.StatusCode) {
+			return &internal.WriteError{Err: err, Permanent: true}
 		}
 
-		return fmt.Errorf("when writing to [%s] received status code: %d. body: %s", h.URL, resp.StatusCode, errorLine)
+		// Honor the server's request to back off when being rate-limited or
+		// the service being unavailable
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+			if wait := internal.ParseRetryAfter(resp.Header.Get("Retry-After")); wait > 0 {
+				return &internal.WriteError{Err: err, RetryAfter: wait}
+			}
+		}
+		return err
 	}
 
 	_, err = io.ReadAll(resp.Body)
@@ -265,6 +299,9 @@ func init() {
 }
 
 func (h *HTTP) getAccessToken(ctx context.Context, audience string) (*oauth2.Token, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
 	if h.oauth2Token.Valid() {
 		return h.oauth2Token, nil
 	}
@@ -9,6 +9,7 @@ import (
 	"net/url"
 	"os"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -262,7 +263,24 @@ func TestStatusCode(t *testing.T) {
 			},
 			statusCode: http.StatusConflict,
 			errFunc: func(t *testing.T, err error) {
-				require.NoError(t, err)
+				var writeErr *internal.PartialWriteError
+				require.ErrorAs(t, err, &writeErr)
+				require.Empty(t, writeErr.MetricsAccept)
+				require.Equal(t, []int{0}, writeErr.MetricsReject)
+			},
+		},
+		{
+			name: "Reject batch on configured non-retryable statuscode",
+			plugin: &HTTP{
+				URL:                     u.String(),
+				UseBatchFormat:          true,
+				NonRetryableStatusCodes: []int{409},
+			},
+			statusCode: http.StatusConflict,
+			errFunc: func(t *testing.T, err error) {
+				var writeErr *internal.WriteError
+				require.ErrorAs(t, err, &writeErr)
+				require.True(t, writeErr.Permanent)
 			},
 		},
 	}
@@ -287,6 +305,63 @@ func TestStatusCode(t *testing.T) {
 	}
 }
 
+func TestRetryAfter(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer ts.Close()
+
+	serializer := &influx.Serializer{}
+	require.NoError(t, serializer.Init())
+
+	plugin := &HTTP{
+		URL: "http://" + ts.Listener.Addr().String(),
+		Log: testutil.Logger{},
+	}
+	plugin.SetSerializer(serializer)
+	require.NoError(t, plugin.Connect())
+
+	// The error should hint at the time to wait before retrying
+	err := plugin.Write([]telegraf.Metric{getMetric()})
+	var werr *internal.WriteError
+	require.ErrorAs(t, err, &werr)
+	require.False(t, werr.Permanent)
+	require.Equal(t, 2*time.Minute, werr.RetryAfter)
+}
+
+func TestNonRetryableStatusCodePartial(t *testing.T) {
+	var requests atomic.Int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if requests.Add(1) == 2 {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	serializer := &influx.Serializer{}
+	require.NoError(t, serializer.Init())
+
+	plugin := &HTTP{
+		URL:                     "http://" + ts.Listener.Addr().String(),
+		NonRetryableStatusCodes: []int{409},
+		Log:                     testutil.Logger{},
+	}
+	plugin.SetSerializer(serializer)
+	require.NoError(t, plugin.Connect())
+
+	// Only the metric refused by the server should be rejected
+	err := plugin.Write(getMetrics(3))
+	var writeErr *internal.PartialWriteError
+	require.ErrorAs(t, err, &writeErr)
+	require.Equal(t, []int{0, 2}, writeErr.MetricsAccept)
+	require.Equal(t, []int{1}, writeErr.MetricsReject)
+	require.Len(t, writeErr.MetricsRejectErrors, 1)
+	require.Equal(t, int64(3), requests.Load())
+}
+
 func TestContentType(t *testing.T) {
 	ts := httptest.NewServer(http.NotFoundHandler())
 	defer ts.Close()
@@ -125,19 +125,25 @@ func (k *Kafka) Close() error {
 }
 
 func (k *Kafka) Write(metrics []telegraf.Metric) error {
+	writeErr := &internal.PartialWriteError{
+		MetricsAccept: make([]int, 0, len(metrics)),
+	}
+
 	msgs := make([]*sarama.ProducerMessage, 0, len(metrics))
-	for _, metric := range metrics {
+	for i, metric := range metrics {
 		metric, topic := k.getTopicName(metric)
 
 		buf, err := k.serializer.Serialize(metric)
 		if err != nil {
 			k.Log.Debugf("Could not serialize metric: %v", err)
+			writeErr.Reject(i, fmt.Errorf("%w: %w", internal.ErrSerialization, err))
 			continue
 		}
 
 		m := &sarama.ProducerMessage{
-			Topic: topic,
-			Value: sarama.ByteEncoder(buf),
+			Topic:    topic,
+			Value:    sarama.ByteEncoder(buf),
+			Metadata: i,
 		}
 
 		if k.MetricNameHeader != "" {
@@ -156,7 +162,8 @@ func (k *Kafka) Write(metrics []telegraf.Metric) error {
 
 		key, err := k.routingKey(metric)
 		if err != nil {
-			return fmt.Errorf("could not generate routing key: %w", err)
+			writeErr.Reject(i, fmt.Errorf("could not generate routing key: %w", err))
+			continue
 		}
 
 		if key != "" {
@@ -165,29 +172,58 @@ func (k *Kafka) Write(metrics []telegraf.Metric) error {
 		msgs = append(msgs, m)
 	}
 
+	failed := make(map[int]bool)
 	if err := k.producer.SendMessages(msgs); err != nil {
-		// We could have many errors, return only the first encountered.
 		var errs sarama.ProducerErrors
-		if errors.As(err, &errs) && len(errs) > 0 {
-			// Just return the first error encountered
-			firstErr := errs[0]
-			if errors.Is(firstErr.Err, sarama.ErrMessageSizeTooLarge) {
-				k.Log.Error("Message too large, consider increasing `max_message_bytes`; dropping batch")
-				return nil
+		if !errors.As(err, &errs) || len(errs) == 0 {
+			// Keep all messages for retrying as we cannot tell which ones
+			// failed
+			writeErr.Err = err
+			return writeErr
+		}
+
+		var tooLarge, invalidTimestamp bool
+		for _, perr := range errs {
+			idx, ok := perr.Msg.Metadata.(int)
+			if !ok {
+				writeErr.Err = perr
+				return writeErr
 			}
-			if errors.Is(firstErr.Err, sarama.ErrInvalidTimestamp) {
-				k.Log.Error(
-					"The timestamp of the message is out of acceptable range, consider increasing broker `message.timestamp.difference.max.ms`; " +
-						"dropping batch",
-				)
-				return nil
+			failed[idx] = true
+
+			// Messages that will never be accepted by the broker are rejected,
+			// all others are kept for retrying
+			switch {
+			case errors.Is(perr.Err, sarama.ErrMessageSizeTooLarge):
+				tooLarge = true
+				writeErr.Reject(idx, perr)
+			case errors.Is(perr.Err, sarama.ErrInvalidTimestamp):
+				invalidTimestamp = true
+				writeErr.Reject(idx, perr)
+			default:
+				if writeErr.Err == nil {
+					writeErr.Err = perr
+				}
 			}
-			return firstErr
 		}
-		return err
+		if tooLarge {
+			k.Log.Error("Message too large, consider increasing `max_message_bytes`; dropping message(s)")
+		}
+		if invalidTimestamp {
+			k.Log.Error(
+				"The timestamp of the message is out of acceptable range, consider increasing broker `message.timestamp.difference.max.ms`; " +
+					"dropping message(s)",
+			)
+		}
 	}
 
-	return nil
+	for _, m := range msgs {
+		if idx := m.Metadata.(int); !failed[idx] {
+			writeErr.MetricsAccept = append(writeErr.MetricsAccept, idx)
+		}
+	}
+
+	return writeErr.Result(writeErr.Err)
 }
 
 func (k *Kafka) getTopicName(metric telegraf.Metric) (telegraf.Metric, string) {
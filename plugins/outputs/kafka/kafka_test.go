@@ -10,6 +10,7 @@ import (
 	kafkacontainer "github.com/testcontainers/testcontainers-go/modules/kafka"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
 	"github.com/influxdata/telegraf/metric"
 	"github.com/influxdata/telegraf/plugins/serializers/influx"
 	"github.com/influxdata/telegraf/testutil"
@@ -268,8 +269,57 @@ func TestTopicTag(t *testing.T) {
 	}
 }
 
+func TestWritePartialFailure(t *testing.T) {
+	// Setup the serializer
+	s := &influx.Serializer{}
+	require.NoError(t, s.Init())
+
+	// Setup the plugin under test
+	plugin := &Kafka{
+		Brokers:      []string{"127.0.0.1"},
+		Topic:        "telegraf",
+		TopicTag:     "topic",
+		Log:          testutil.Logger{},
+		producerFunc: newMockProducer,
+	}
+	plugin.SetSerializer(s)
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+
+	// Fail messages depending on the topic
+	producer, ok := plugin.producer.(*mockProducer)
+	require.True(t, ok, "invalid producer type")
+	producer.fail = func(msg *sarama.ProducerMessage) error {
+		switch msg.Topic {
+		case "too_large":
+			return sarama.ErrMessageSizeTooLarge
+		case "unavailable":
+			return sarama.ErrLeaderNotAvailable
+		}
+		return nil
+	}
+
+	input := []telegraf.Metric{
+		metric.New("cpu", map[string]string{}, map[string]interface{}{"value": 1}, time.Unix(0, 0)),
+		metric.New("cpu", map[string]string{"topic": "too_large"}, map[string]interface{}{"value": 2}, time.Unix(0, 0)),
+		metric.New("cpu", map[string]string{"topic": "unavailable"}, map[string]interface{}{"value": 3}, time.Unix(0, 0)),
+		metric.New("cpu", map[string]string{}, map[string]interface{}{"value": 4}, time.Unix(0, 0)),
+	}
+
+	// Successfully sent metrics should be accepted, oversized ones rejected
+	// and all others kept for retrying
+	err := plugin.Write(input)
+	require.ErrorIs(t, err, sarama.ErrLeaderNotAvailable)
+	var writeErr *internal.PartialWriteError
+	require.ErrorAs(t, err, &writeErr)
+	require.Equal(t, []int{0, 3}, writeErr.MetricsAccept)
+	require.Equal(t, []int{1}, writeErr.MetricsReject)
+	require.Len(t, producer.sent, 2)
+}
+
 type mockProducer struct {
 	sent []*sarama.ProducerMessage
+	fail func(*sarama.ProducerMessage) error
 	sarama.SyncProducer
 	sync.Mutex
 }
@@ -288,7 +338,20 @@ func (p *mockProducer) SendMessage(msg *sarama.ProducerMessage) (partition int32
 func (p *mockProducer) SendMessages(msgs []*sarama.ProducerMessage) error {
 	p.Lock()
 	defer p.Unlock()
-	p.sent = append(p.sent, msgs...)
+
+	var errs sarama.ProducerErrors
+	for _, msg := range msgs {
+		if p.fail != nil {
+			if err := p.fail(msg); err != nil {
+				errs = append(errs, &sarama.ProducerError{Msg: msg, Err: err})
+				continue
+			}
+		}
+		p.sent = append(p.sent, msg)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
 	return nil
 }
 
@@ -0,0 +1,8 @@
+package object_store
+
+import (
+	// Register backends
+	_ "github.com/rclone/rclone/backend/local"
+	_ "github.com/rclone/rclone/backend/s3"
+	_ "github.com/rclone/rclone/backend/sftp"
+)
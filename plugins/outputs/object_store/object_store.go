@@ -0,0 +1,420 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package object_store
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	rfs "github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/fspath"
+	"github.com/rclone/rclone/fs/operations"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/outputs"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+type ObjectStore struct {
+	Remote           config.Secret   `toml:"remote"`
+	PartitionPath    string          `toml:"partition_path"`
+	Format           string          `toml:"format"`
+	Compression      string          `toml:"compression"`
+	TimestampColumn  string          `toml:"timestamp_column"`
+	MaxFileSize      config.Size     `toml:"max_file_size"`
+	MaxFileAge       config.Duration `toml:"max_file_age"`
+	StagingDirectory string          `toml:"staging_directory"`
+	UploadTimeout    config.Duration `toml:"upload_timeout"`
+	Log              telegraf.Logger `toml:"-"`
+
+	partition *template.Template
+	extension string
+	remote    rfs.Fs
+	staging   rfs.Fs
+	stagedir  string
+	segments  map[string]*stagedSegment
+	pending   []string
+}
+
+// stagedSegment is a segment in progress with the path of the finished file
+// relative to the staging directory
+type stagedSegment struct {
+	segment
+	path string
+}
+
+func (*ObjectStore) SampleConfig() string {
+	return sampleConfig
+}
+
+func (o *ObjectStore) Init() error {
+	// Set defaults
+	if o.PartitionPath == "" {
+		o.PartitionPath = `measurement={{.Name}}/date={{.Time.UTC.Format "2006-01-02"}}/hour={{.Time.UTC.Format "15"}}`
+	}
+	if o.TimestampColumn == "" {
+		o.TimestampColumn = "timestamp"
+	}
+	if o.UploadTimeout <= 0 {
+		o.UploadTimeout = config.Duration(5 * time.Minute)
+	}
+
+	// Check the settings
+	switch o.Format {
+	case "", "ndjson":
+		o.Format = "ndjson"
+		switch o.Compression {
+		case "", "gzip":
+			o.Compression = "gzip"
+			o.extension = ".ndjson.gz"
+		case "zstd":
+			o.extension = ".ndjson.zst"
+		case "none":
+			o.extension = ".ndjson"
+		default:
+			return fmt.Errorf("invalid compression %q for format %q", o.Compression, o.Format)
+		}
+	case "parquet":
+		switch o.Compression {
+		case "":
+			o.Compression = "snappy"
+		case "snappy", "gzip", "zstd", "none":
+		default:
+			return fmt.Errorf("invalid compression %q for format %q", o.Compression, o.Format)
+		}
+		o.extension = ".parquet"
+	default:
+		return fmt.Errorf("invalid format %q", o.Format)
+	}
+
+	if o.MaxFileSize <= 0 && o.MaxFileAge <= 0 {
+		return errors.New("at least one of 'max_file_size' or 'max_file_age' must be set")
+	}
+
+	tmpl, err := template.New("partition").Funcs(template.FuncMap{"now": time.Now}).Parse(o.PartitionPath)
+	if err != nil {
+		return fmt.Errorf("parsing partition path template failed: %w", err)
+	}
+	o.partition = tmpl
+
+	rfs.LogOutput = func(level rfs.LogLevel, text string) {
+		o.Log.Tracef("[%s] %s", level.String(), text)
+	}
+
+	o.segments = make(map[string]*stagedSegment)
+
+	return nil
+}
+
+func (o *ObjectStore) Connect() error {
+	remote := "local:"
+	if !o.Remote.Empty() {
+		remoteRaw, err := o.Remote.Get()
+		if err != nil {
+			return fmt.Errorf("getting remote secret failed: %w", err)
+		}
+		remote = remoteRaw.String()
+		remoteRaw.Destroy()
+	}
+
+	// Use a staging directory unique for the remote and the partitioning
+	// to pick up the files left over from a previous run
+	o.stagedir = o.StagingDirectory
+	if o.stagedir == "" {
+		sum := sha256.Sum256([]byte(remote + "\n" + o.PartitionPath + "\n" + o.extension))
+		o.stagedir = filepath.Join(os.TempDir(), "telegraf-object-store-"+hex.EncodeToString(sum[:6]))
+	}
+	if err := os.MkdirAll(o.stagedir, 0750); err != nil {
+		return fmt.Errorf("creating staging directory failed: %w", err)
+	}
+
+	ctx := context.Background()
+	var err error
+	if o.remote, err = newFs(ctx, remote); err != nil {
+		return fmt.Errorf("creating remote failed: %w", err)
+	}
+	if o.staging, err = newFs(ctx, "local:"+o.stagedir); err != nil {
+		return fmt.Errorf("creating staging filesystem failed: %w", err)
+	}
+
+	// Force connection to make sure we actually can connect
+	if _, err := o.remote.List(ctx, ""); err != nil && !errors.Is(err, rfs.ErrorDirNotFound) {
+		return err
+	}
+	o.Log.Debugf("Connected to %s using staging directory %q", o.remote.String(), o.stagedir)
+
+	// Recover the files of a previous run and try to upload them
+	if err := o.recover(); err != nil {
+		return fmt.Errorf("recovering staged files failed: %w", err)
+	}
+	if err := o.upload(); err != nil {
+		o.Log.Errorf("Uploading staged files failed: %v", err)
+	}
+
+	return nil
+}
+
+func (o *ObjectStore) Close() error {
+	// Finish all files in progress and upload them
+	var errs []error
+	for _, p := range slices.Sorted(maps.Keys(o.segments)) {
+		if err := o.finish(p); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if o.remote != nil {
+		if err := o.upload(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(o.pending) > 0 {
+		o.Log.Warnf("%d files remain in staging directory %q for uploading on next start", len(o.pending), o.stagedir)
+	}
+
+	return errors.Join(errs...)
+}
+
+func (o *ObjectStore) Write(metrics []telegraf.Metric) error {
+	writeErr := &internal.PartialWriteError{
+		MetricsAccept: make([]int, 0, len(metrics)),
+	}
+
+	// Group the metrics by partition
+	var buf bytes.Buffer
+	var partitions []string
+	groups := make(map[string][]int)
+	for i, m := range metrics {
+		buf.Reset()
+		if err := o.partition.Execute(&buf, m); err != nil {
+			writeErr.Reject(i, fmt.Errorf("creating partition path failed: %w", err))
+			continue
+		}
+		p := path.Clean("/" + filepath.ToSlash(buf.String()))[1:]
+		if _, found := groups[p]; !found {
+			partitions = append(partitions, p)
+		}
+		groups[p] = append(groups[p], i)
+	}
+
+	// Add the metrics to the files of the partitions
+	for _, p := range partitions {
+		indices := groups[p]
+		batch := make([]telegraf.Metric, 0, len(indices))
+		for _, idx := range indices {
+			batch = append(batch, metrics[idx])
+		}
+
+		rejected, reasons, err := o.stage(p, batch)
+		if err != nil {
+			return writeErr.Result(fmt.Errorf("staging metrics of partition %q failed: %w", p, err))
+		}
+		for i, idx := range indices {
+			if j := slices.Index(rejected, i); j >= 0 {
+				writeErr.Reject(idx, reasons[j])
+				continue
+			}
+			writeErr.MetricsAccept = append(writeErr.MetricsAccept, idx)
+		}
+	}
+
+	// Roll the files exceeding the size or age limit
+	now := time.Now()
+	for _, p := range slices.Sorted(maps.Keys(o.segments)) {
+		s := o.segments[p]
+		exceedsSize := o.MaxFileSize > 0 && s.size() >= int64(o.MaxFileSize)
+		exceedsAge := o.MaxFileAge > 0 && now.Sub(s.created()) >= time.Duration(o.MaxFileAge)
+		if !exceedsSize && !exceedsAge {
+			continue
+		}
+		if err := o.finish(p); err != nil {
+			return writeErr.Result(err)
+		}
+	}
+
+	// Upload the finished files. The metrics are staged at this point so
+	// errors are only reported and uploading is retried on the next write.
+	if err := o.upload(); err != nil {
+		return writeErr.Result(err)
+	}
+
+	return writeErr.Result(nil)
+}
+
+// stage adds the metrics to the file in progress of the given partition.
+// A new file is started if there is none or the metrics do not fit the
+// schema of the current file.
+func (o *ObjectStore) stage(partition string, metrics []telegraf.Metric) ([]int, []error, error) {
+	var base *arrow.Schema
+	if s, found := o.segments[partition]; found && !s.compatible(metrics) {
+		if ps, ok := s.segment.(*parquetSegment); ok {
+			base = ps.schema
+		}
+		o.Log.Debugf("Schema of partition %q changed, rolling file", partition)
+		if err := o.finish(partition); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	s, found := o.segments[partition]
+	if !found {
+		var err error
+		if s, err = o.newSegment(partition, base, metrics); err != nil {
+			return nil, nil, err
+		}
+		o.segments[partition] = s
+	}
+
+	return s.write(metrics)
+}
+
+func (o *ObjectStore) newSegment(partition string, base *arrow.Schema, metrics []telegraf.Metric) (*stagedSegment, error) {
+	dir := filepath.Join(o.stagedir, filepath.FromSlash(partition))
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, fmt.Errorf("creating directory failed: %w", err)
+	}
+
+	var id [4]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, fmt.Errorf("generating file name failed: %w", err)
+	}
+	name := "part-" + time.Now().UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(id[:]) + o.extension
+	fn := filepath.Join(dir, name) + inprogressSuffix
+
+	var s segment
+	var err error
+	switch o.Format {
+	case "parquet":
+		s, err = newParquetSegment(fn, o.Compression, parquetSchema(base, o.TimestampColumn, metrics))
+	default:
+		compression := o.Compression
+		if compression == "none" {
+			compression = "identity"
+		}
+		s, err = newNDJSONSegment(fn, compression)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("creating file failed: %w", err)
+	}
+
+	return &stagedSegment{segment: s, path: path.Join(partition, name)}, nil
+}
+
+// finish completes the file in progress of the partition and schedules it
+// for uploading
+func (o *ObjectStore) finish(partition string) error {
+	s := o.segments[partition]
+	delete(o.segments, partition)
+
+	fn := filepath.Join(o.stagedir, filepath.FromSlash(s.path))
+	if err := s.finish(); err != nil {
+		return fmt.Errorf("finishing file %q failed: %w", s.path, err)
+	}
+	if err := os.Rename(fn+inprogressSuffix, fn); err != nil {
+		return fmt.Errorf("renaming file %q failed: %w", s.path, err)
+	}
+	o.pending = append(o.pending, s.path)
+
+	return nil
+}
+
+// upload transfers the finished files to the remote and removes them from
+// the staging directory. The remote backend only makes the object visible
+// after the transfer completed.
+func (o *ObjectStore) upload() error {
+	for len(o.pending) > 0 {
+		fn := o.pending[0]
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(o.UploadTimeout))
+		err := operations.CopyFile(ctx, o.remote, o.staging, fn, fn)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("uploading file %q failed: %w", fn, err)
+		}
+		o.Log.Debugf("Uploaded file %q", fn)
+
+		local := filepath.Join(o.stagedir, filepath.FromSlash(fn))
+		if err := os.Remove(local); err != nil {
+			o.Log.Errorf("Removing staged file %q failed: %v", fn, err)
+		}
+		// Cleanup empty partition directories
+		for dir := filepath.Dir(local); dir != o.stagedir && os.Remove(dir) == nil; {
+			dir = filepath.Dir(dir)
+		}
+
+		o.pending = o.pending[1:]
+	}
+	return nil
+}
+
+// recover schedules the files left in the staging directory by a previous
+// run for uploading. NDJSON files in progress are complete after each write
+// and can be recovered while Parquet files in progress lack the footer and
+// are removed.
+func (o *ObjectStore) recover() error {
+	return filepath.WalkDir(o.stagedir, func(fn string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(o.stagedir, fn)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if finished, found := strings.CutSuffix(fn, inprogressSuffix); found {
+			if strings.HasSuffix(finished, ".parquet") {
+				o.Log.Warnf("Removing incomplete file %q", rel)
+				return os.Remove(fn)
+			}
+			if err := os.Rename(fn, finished); err != nil {
+				return err
+			}
+			rel = strings.TrimSuffix(rel, inprogressSuffix)
+		}
+		o.Log.Debugf("Recovered staged file %q", rel)
+		o.pending = append(o.pending, rel)
+		return nil
+	})
+}
+
+// newFs creates a filesystem for the given remote location in the
+// '<backend type>[,<param>=<value>...]:[root]' notation
+func newFs(ctx context.Context, remote string) (rfs.Fs, error) {
+	parsed, err := fspath.Parse(remote)
+	if err != nil {
+		return nil, fmt.Errorf("parsing remote failed: %w", err)
+	}
+	info, err := rfs.Find(parsed.Name)
+	if err != nil {
+		return nil, fmt.Errorf("cannot find remote type %q: %w", parsed.Name, err)
+	}
+	return info.NewFs(ctx, parsed.Name, parsed.Path, rfs.ConfigMap(info.Prefix, info.Options, parsed.Name, parsed.Config))
+}
+
+func init() {
+	outputs.Add("object_store", func() telegraf.Output {
+		return &ObjectStore{
+			MaxFileSize: config.Size(128 * 1024 * 1024),
+			MaxFileAge:  config.Duration(15 * time.Minute),
+		}
+	})
+}
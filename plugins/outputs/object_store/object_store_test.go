@@ -0,0 +1,420 @@
+package object_store
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet/file"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *ObjectStore
+		expected string
+	}{
+		{
+			name:     "invalid format",
+			plugin:   &ObjectStore{Format: "csv", MaxFileSize: 1},
+			expected: `invalid format "csv"`,
+		},
+		{
+			name:     "invalid ndjson compression",
+			plugin:   &ObjectStore{Format: "ndjson", Compression: "snappy", MaxFileSize: 1},
+			expected: `invalid compression "snappy" for format "ndjson"`,
+		},
+		{
+			name:     "invalid parquet compression",
+			plugin:   &ObjectStore{Format: "parquet", Compression: "lz4", MaxFileSize: 1},
+			expected: `invalid compression "lz4" for format "parquet"`,
+		},
+		{
+			name:     "no roll limit",
+			plugin:   &ObjectStore{},
+			expected: "at least one of 'max_file_size' or 'max_file_age' must be set",
+		},
+		{
+			name:     "invalid template",
+			plugin:   &ObjectStore{PartitionPath: "{{.Name", MaxFileSize: 1},
+			expected: "parsing partition path template failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.plugin.Log = testutil.Logger{}
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestWriteNDJSON(t *testing.T) {
+	remote := t.TempDir()
+	staging := t.TempDir()
+
+	plugin := &ObjectStore{
+		Remote:           config.NewSecret([]byte("local:" + remote)),
+		StagingDirectory: staging,
+		MaxFileSize:      1,
+		Log:              testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	metrics := []telegraf.Metric{
+		metric.New(
+			"cpu",
+			map[string]string{"host": "a"},
+			map[string]interface{}{"usage": 42.5},
+			time.Date(2026, 10, 17, 9, 30, 0, 0, time.UTC),
+		),
+		metric.New(
+			"mem",
+			map[string]string{"host": "a"},
+			map[string]interface{}{"free": int64(1024)},
+			time.Date(2026, 10, 17, 9, 45, 0, 0, time.UTC),
+		),
+		metric.New(
+			"cpu",
+			map[string]string{"host": "b"},
+			map[string]interface{}{"usage": 23.0},
+			time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC),
+		),
+	}
+	require.NoError(t, plugin.Write(metrics))
+
+	// All files exceed the size limit and must be uploaded
+	files := listFiles(t, remote)
+	require.Len(t, files, 3)
+	expected := map[string][]string{
+		"measurement=cpu/date=2026-10-17/hour=09": {
+			`{"fields":{"usage":42.5},"name":"cpu","tags":{"host":"a"},"timestamp":1792229400000000000}`,
+		},
+		"measurement=mem/date=2026-10-17/hour=09": {
+			`{"fields":{"free":1024},"name":"mem","tags":{"host":"a"},"timestamp":1792230300000000000}`,
+		},
+		"measurement=cpu/date=2026-10-17/hour=10": {
+			`{"fields":{"usage":23},"name":"cpu","tags":{"host":"b"},"timestamp":1792231200000000000}`,
+		},
+	}
+	for _, fn := range files {
+		require.True(t, strings.HasSuffix(fn, ".ndjson.gz"), fn)
+		dir := filepath.ToSlash(filepath.Dir(fn))
+		require.Contains(t, expected, dir)
+		require.Equal(t, expected[dir], readNDJSON(t, filepath.Join(remote, fn)))
+	}
+
+	// The staging directory must be cleaned up
+	require.Empty(t, listFiles(t, staging))
+}
+
+func TestWriteRollByAge(t *testing.T) {
+	remote := t.TempDir()
+
+	plugin := &ObjectStore{
+		Remote:           config.NewSecret([]byte("local:" + remote)),
+		PartitionPath:    "{{.Name}}",
+		StagingDirectory: t.TempDir(),
+		MaxFileAge:       config.Duration(time.Hour),
+		Log:              testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	m := metric.New("cpu", map[string]string{}, map[string]interface{}{"value": 1.0}, time.Unix(0, 0))
+
+	// The file must not be visible before being rolled
+	require.NoError(t, plugin.Write([]telegraf.Metric{m}))
+	require.NoError(t, plugin.Write([]telegraf.Metric{m}))
+	require.Empty(t, listFiles(t, remote))
+
+	// Rolling must upload the file containing both writes
+	plugin.MaxFileAge = config.Duration(time.Nanosecond)
+	require.NoError(t, plugin.Write([]telegraf.Metric{m}))
+	files := listFiles(t, remote)
+	require.Len(t, files, 1)
+	require.Len(t, readNDJSON(t, filepath.Join(remote, files[0])), 3)
+}
+
+func TestWriteParquet(t *testing.T) {
+	remote := t.TempDir()
+
+	plugin := &ObjectStore{
+		Remote:           config.NewSecret([]byte("local:" + remote)),
+		PartitionPath:    "measurement={{.Name}}",
+		Format:           "parquet",
+		StagingDirectory: t.TempDir(),
+		MaxFileAge:       config.Duration(time.Hour),
+		Log:              testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+
+	first := []telegraf.Metric{
+		metric.New(
+			"cpu",
+			map[string]string{"host": "a"},
+			map[string]interface{}{"usage": int64(23)},
+			time.Unix(1, 0),
+		),
+	}
+	require.NoError(t, plugin.Write(first))
+
+	// A float value in an integer column must roll the file and widen the
+	// column instead of truncating the value
+	second := []telegraf.Metric{
+		metric.New(
+			"cpu",
+			map[string]string{"host": "b"},
+			map[string]interface{}{"usage": 3.7},
+			time.Unix(2, 0),
+		),
+	}
+	require.NoError(t, plugin.Write(second))
+	require.Len(t, listFiles(t, remote), 1)
+
+	// Integer values must fit into the float column
+	third := []telegraf.Metric{
+		metric.New(
+			"cpu",
+			map[string]string{"host": "c"},
+			map[string]interface{}{"usage": int64(5)},
+			time.Unix(3, 0),
+		),
+	}
+	require.NoError(t, plugin.Write(third))
+	require.Len(t, listFiles(t, remote), 1)
+
+	// A string value in a numeric column must roll the file instead of being
+	// dropped
+	fourth := []telegraf.Metric{
+		metric.New(
+			"cpu",
+			map[string]string{"host": "d"},
+			map[string]interface{}{"usage": "invalid"},
+			time.Unix(4, 0),
+		),
+	}
+	require.NoError(t, plugin.Write(fourth))
+	require.Len(t, listFiles(t, remote), 2)
+
+	// A new column must roll the file
+	fifth := []telegraf.Metric{
+		metric.New(
+			"cpu",
+			map[string]string{"host": "a"},
+			map[string]interface{}{"usage": "idle", "idle": true},
+			time.Unix(5, 0),
+		),
+	}
+	require.NoError(t, plugin.Write(fifth))
+	require.Len(t, listFiles(t, remote), 3)
+
+	require.NoError(t, plugin.Close())
+	files := listFiles(t, remote)
+	require.Len(t, files, 4)
+
+	// Check the file contents
+	var ints []int64
+	var floats []float64
+	var strs []string
+	var rows, withIdle int64
+	for _, fn := range files {
+		require.True(t, strings.HasPrefix(filepath.ToSlash(fn), "measurement=cpu/part-"), fn)
+		require.True(t, strings.HasSuffix(fn, ".parquet"), fn)
+
+		reader, err := file.OpenParquetFile(filepath.Join(remote, fn), false)
+		require.NoError(t, err)
+		defer reader.Close()
+
+		schema := reader.MetaData().Schema
+		columns := make([]string, 0, schema.NumColumns())
+		for i := range schema.NumColumns() {
+			columns = append(columns, schema.Column(i).Name())
+		}
+		if len(columns) == 4 {
+			require.Equal(t, []string{"timestamp", "host", "idle", "usage"}, columns)
+			withIdle += reader.NumRows()
+		} else {
+			require.Equal(t, []string{"timestamp", "host", "usage"}, columns)
+		}
+
+		arrowReader, err := pqarrow.NewFileReader(reader, pqarrow.ArrowReadProperties{}, memory.DefaultAllocator)
+		require.NoError(t, err)
+		table, err := arrowReader.ReadTable(context.Background())
+		require.NoError(t, err)
+		defer table.Release()
+		switch usage := table.Column(len(columns) - 1).Data().Chunk(0).(type) {
+		case *array.Int64:
+			ints = append(ints, usage.Int64Values()...)
+		case *array.Float64:
+			floats = append(floats, usage.Float64Values()...)
+		case *array.String:
+			for i := range usage.Len() {
+				strs = append(strs, usage.Value(i))
+			}
+		default:
+			require.Failf(t, "unexpected column type", "%T", usage)
+		}
+		rows += reader.NumRows()
+	}
+	require.Equal(t, int64(5), rows)
+	require.Equal(t, int64(1), withIdle)
+	require.Equal(t, []int64{23}, ints)
+	require.Equal(t, []float64{3.7, 5}, floats)
+	require.ElementsMatch(t, []string{"invalid", "idle"}, strs)
+}
+
+func TestWriteInvalidPartition(t *testing.T) {
+	plugin := &ObjectStore{
+		Remote:           config.NewSecret([]byte("local:" + t.TempDir())),
+		PartitionPath:    `{{len (.Field "value")}}`,
+		StagingDirectory: t.TempDir(),
+		MaxFileSize:      1,
+		Log:              testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	metrics := []telegraf.Metric{
+		metric.New("test", map[string]string{}, map[string]interface{}{"value": "foo"}, time.Unix(0, 0)),
+		metric.New("test", map[string]string{}, map[string]interface{}{"value": 42.0}, time.Unix(0, 0)),
+	}
+
+	err := plugin.Write(metrics)
+	var writeErr *internal.PartialWriteError
+	require.ErrorAs(t, err, &writeErr)
+	require.Equal(t, []int{0}, writeErr.MetricsAccept)
+	require.Equal(t, []int{1}, writeErr.MetricsReject)
+	require.ErrorContains(t, writeErr.MetricsRejectErrors[0], "creating partition path failed")
+}
+
+func TestRecoverStagedFiles(t *testing.T) {
+	remote := t.TempDir()
+	staging := t.TempDir()
+
+	// Simulate the files left by a previous run
+	content := map[string]string{
+		"measurement=cpu/part-1.ndjson":                     `{"name":"finished"}` + "\n",
+		"measurement=cpu/part-2.ndjson" + inprogressSuffix:  `{"name":"in progress"}` + "\n",
+		"measurement=cpu/part-3.parquet" + inprogressSuffix: "incomplete",
+	}
+	for fn, data := range content {
+		fn = filepath.Join(staging, filepath.FromSlash(fn))
+		require.NoError(t, os.MkdirAll(filepath.Dir(fn), 0750))
+		require.NoError(t, os.WriteFile(fn, []byte(data), 0600))
+	}
+
+	plugin := &ObjectStore{
+		Remote:           config.NewSecret([]byte("local:" + remote)),
+		Compression:      "none",
+		StagingDirectory: staging,
+		MaxFileSize:      1,
+		Log:              testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	// Complete files must be uploaded while incomplete Parquet files must be
+	// removed
+	require.ElementsMatch(t, []string{
+		filepath.FromSlash("measurement=cpu/part-1.ndjson"),
+		filepath.FromSlash("measurement=cpu/part-2.ndjson"),
+	}, listFiles(t, remote))
+	require.Empty(t, listFiles(t, staging))
+}
+
+func TestUploadFailure(t *testing.T) {
+	remote := filepath.Join(t.TempDir(), "remote")
+	staging := t.TempDir()
+
+	plugin := &ObjectStore{
+		Remote:           config.NewSecret([]byte("local:" + remote)),
+		PartitionPath:    "{{.Name}}",
+		StagingDirectory: staging,
+		MaxFileSize:      1,
+		Log:              testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	// Block the remote location by a file to make uploads fail
+	require.NoError(t, os.WriteFile(remote, nil, 0600))
+
+	// Staged metrics must be accepted even if the upload fails
+	m := metric.New("cpu", map[string]string{}, map[string]interface{}{"value": 1.0}, time.Unix(0, 0))
+	err := plugin.Write([]telegraf.Metric{m})
+	var writeErr *internal.PartialWriteError
+	require.ErrorAs(t, err, &writeErr)
+	require.Equal(t, []int{0}, writeErr.MetricsAccept)
+	require.Empty(t, writeErr.MetricsReject)
+	require.ErrorContains(t, writeErr.Err, "uploading file")
+	require.Len(t, listFiles(t, staging), 1)
+
+	// The file must be uploaded with the next write
+	require.NoError(t, os.Remove(remote))
+	require.NoError(t, plugin.Write([]telegraf.Metric{m}))
+	require.Len(t, listFiles(t, remote), 2)
+	require.Empty(t, listFiles(t, staging))
+}
+
+// listFiles returns all files in the directory relative to the directory
+func listFiles(t *testing.T, dir string) []string {
+	t.Helper()
+
+	var files []string
+	err := filepath.WalkDir(dir, func(fn string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, fn)
+		if err != nil {
+			return err
+		}
+		files = append(files, rel)
+		return nil
+	})
+	require.NoError(t, err)
+	return files
+}
+
+func readNDJSON(t *testing.T, fn string) []string {
+	t.Helper()
+
+	f, err := os.Open(fn)
+	require.NoError(t, err)
+	defer f.Close()
+
+	reader, err := gzip.NewReader(f)
+	require.NoError(t, err)
+
+	var lines []string
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	require.NoError(t, scanner.Err())
+	return lines
+}
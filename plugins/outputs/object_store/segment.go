@@ -0,0 +1,402 @@
+package object_store
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/compress"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal"
+	serializers_json "github.com/influxdata/telegraf/plugins/serializers/json"
+)
+
+// Suffix of files in the staging directory not yet finished
+const inprogressSuffix = ".inprogress"
+
+// segment is a file in the staging directory collecting the metrics of a
+// partition until the file is rolled and uploaded
+type segment interface {
+	// write appends the metrics to the file and returns the indices and
+	// errors of metrics that cannot be written
+	write(metrics []telegraf.Metric) ([]int, []error, error)
+	// size returns the number of bytes written to the file
+	size() int64
+	// created returns the time the segment was started
+	created() time.Time
+	// finish completes the file so it can be uploaded
+	finish() error
+	// compatible checks if the metrics can be added without changing the
+	// file's schema
+	compatible(metrics []telegraf.Metric) bool
+}
+
+// segmentFile is the common base of all segments writing to a file and
+// keeping track of the file size
+type segmentFile struct {
+	file    *os.File
+	written int64
+	start   time.Time
+}
+
+func newSegmentFile(filename string) (*segmentFile, error) {
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0640)
+	if err != nil {
+		return nil, err
+	}
+	return &segmentFile{file: file, start: time.Now()}, nil
+}
+
+func (s *segmentFile) Write(p []byte) (int, error) {
+	n, err := s.file.Write(p)
+	s.written += int64(n)
+	return n, err
+}
+
+func (s *segmentFile) Close() error {
+	return s.file.Close()
+}
+
+func (s *segmentFile) size() int64 {
+	return s.written
+}
+
+func (s *segmentFile) created() time.Time {
+	return s.start
+}
+
+// ndjsonSegment writes newline-delimited JSON compressed in independent
+// members per write. This keeps the file valid after each write so it can be
+// recovered after a crash.
+type ndjsonSegment struct {
+	*segmentFile
+	serializer *serializers_json.Serializer
+	encoder    internal.ContentEncoder
+}
+
+func newNDJSONSegment(filename, compression string) (*ndjsonSegment, error) {
+	serializer := &serializers_json.Serializer{TimestampUnits: config.Duration(time.Nanosecond)}
+	if err := serializer.Init(); err != nil {
+		return nil, fmt.Errorf("initializing serializer failed: %w", err)
+	}
+	encoder, err := internal.NewContentEncoder(compression)
+	if err != nil {
+		return nil, fmt.Errorf("creating encoder failed: %w", err)
+	}
+
+	f, err := newSegmentFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return &ndjsonSegment{segmentFile: f, serializer: serializer, encoder: encoder}, nil
+}
+
+func (s *ndjsonSegment) write(metrics []telegraf.Metric) ([]int, []error, error) {
+	var rejected []int
+	var reasons []error
+	var buf []byte
+	for i, m := range metrics {
+		serialized, err := s.serializer.Serialize(m)
+		if err != nil {
+			rejected = append(rejected, i)
+			reasons = append(reasons, fmt.Errorf("%w: %w", internal.ErrSerialization, err))
+			continue
+		}
+		buf = append(buf, serialized...)
+	}
+	if len(buf) == 0 {
+		return rejected, reasons, nil
+	}
+
+	encoded, err := s.encoder.Encode(buf)
+	if err != nil {
+		return nil, nil, fmt.Errorf("compressing data failed: %w", err)
+	}
+	if _, err := s.Write(encoded); err != nil {
+		return nil, nil, err
+	}
+	return rejected, reasons, nil
+}
+
+func (*ndjsonSegment) compatible([]telegraf.Metric) bool {
+	return true
+}
+
+func (s *ndjsonSegment) finish() error {
+	return s.file.Close()
+}
+
+// parquetSegment writes the metrics as Parquet file with a row group per
+// write. The schema is fixed per file with the timestamp being the first
+// column, so metrics with new columns or values not fitting the column type
+// require a new segment.
+type parquetSegment struct {
+	*segmentFile
+	schema  *arrow.Schema
+	columns map[string]arrow.DataType
+	writer  *pqarrow.FileWriter
+}
+
+func newParquetSegment(filename, compression string, schema *arrow.Schema) (*parquetSegment, error) {
+	var codec compress.Compression
+	switch compression {
+	case "snappy":
+		codec = compress.Codecs.Snappy
+	case "gzip":
+		codec = compress.Codecs.Gzip
+	case "zstd":
+		codec = compress.Codecs.Zstd
+	case "none":
+		codec = compress.Codecs.Uncompressed
+	default:
+		return nil, fmt.Errorf("invalid compression %q", compression)
+	}
+
+	f, err := newSegmentFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	writer, err := pqarrow.NewFileWriter(
+		schema,
+		f,
+		parquet.NewWriterProperties(parquet.WithCompression(codec)),
+		pqarrow.DefaultWriterProps(),
+	)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("creating parquet writer failed: %w", err)
+	}
+
+	columns := make(map[string]arrow.DataType, len(schema.Fields()))
+	for _, field := range schema.Fields() {
+		columns[field.Name] = field.Type
+	}
+
+	return &parquetSegment{segmentFile: f, schema: schema, columns: columns, writer: writer}, nil
+}
+
+func (s *parquetSegment) compatible(metrics []telegraf.Metric) bool {
+	for _, m := range metrics {
+		for _, field := range m.FieldList() {
+			datatype := arrowType(field.Value)
+			if datatype == nil {
+				continue
+			}
+			column, found := s.columns[field.Key]
+			if !found || !storable(datatype, column) {
+				return false
+			}
+		}
+		for _, tag := range m.TagList() {
+			if m.HasField(tag.Key) {
+				continue
+			}
+			column, found := s.columns[tag.Key]
+			if !found || !arrow.TypeEqual(column, arrow.BinaryTypes.String) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (s *parquetSegment) write(metrics []telegraf.Metric) ([]int, []error, error) {
+	var rejected []int
+	var reasons []error
+
+	builder := array.NewRecordBuilder(memory.DefaultAllocator, s.schema)
+	defer builder.Release()
+
+	fields := s.schema.Fields()
+	row := make([]interface{}, len(fields))
+	for i, m := range metrics {
+		// Convert the values first to not append partial rows
+		var err error
+		row[0] = arrow.Timestamp(m.Time().UnixNano())
+		for j, column := range fields[1:] {
+			if row[j+1], err = columnValue(m, column); err != nil {
+				break
+			}
+		}
+		if err != nil {
+			rejected = append(rejected, i)
+			reasons = append(reasons, fmt.Errorf("%w: %w", internal.ErrSerialization, err))
+			continue
+		}
+		for j, v := range row {
+			appendValue(builder.Field(j), v)
+		}
+	}
+
+	record := builder.NewRecord()
+	defer record.Release()
+	if record.NumRows() == 0 {
+		return rejected, reasons, nil
+	}
+	if err := s.writer.Write(record); err != nil {
+		return nil, nil, fmt.Errorf("writing row group failed: %w", err)
+	}
+	return rejected, reasons, nil
+}
+
+func (s *parquetSegment) finish() error {
+	// Closing the writer also closes the underlying file
+	return s.writer.Close()
+}
+
+// parquetSchema creates the schema for the metrics with a timestamp column
+// and a column per tag and field. The columns of the given base schema are
+// retained to avoid rolling files for metrics with alternating columns.
+// Columns with values of different types are widened to a type holding all
+// values.
+func parquetSchema(base *arrow.Schema, timestampColumn string, metrics []telegraf.Metric) *arrow.Schema {
+	columns := make(map[string]arrow.DataType)
+	if base != nil {
+		for _, field := range base.Fields() {
+			columns[field.Name] = field.Type
+		}
+	}
+	for _, m := range metrics {
+		for _, field := range m.FieldList() {
+			datatype := arrowType(field.Value)
+			if datatype == nil {
+				continue
+			}
+			if existing, found := columns[field.Key]; found {
+				datatype = widenType(existing, datatype)
+			}
+			columns[field.Key] = datatype
+		}
+		for _, tag := range m.TagList() {
+			if m.HasField(tag.Key) {
+				continue
+			}
+			datatype := arrow.DataType(arrow.BinaryTypes.String)
+			if existing, found := columns[tag.Key]; found {
+				datatype = widenType(existing, datatype)
+			}
+			columns[tag.Key] = datatype
+		}
+	}
+	delete(columns, timestampColumn)
+
+	names := make([]string, 0, len(columns))
+	for name := range columns {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	fields := make([]arrow.Field, 0, len(names)+1)
+	fields = append(fields, arrow.Field{Name: timestampColumn, Type: arrow.FixedWidthTypes.Timestamp_ns})
+	for _, name := range names {
+		fields = append(fields, arrow.Field{Name: name, Type: columns[name], Nullable: true})
+	}
+	return arrow.NewSchema(fields, nil)
+}
+
+func arrowType(value interface{}) arrow.DataType {
+	switch value.(type) {
+	case int64:
+		return arrow.PrimitiveTypes.Int64
+	case uint64:
+		return arrow.PrimitiveTypes.Uint64
+	case float64:
+		return arrow.PrimitiveTypes.Float64
+	case bool:
+		return arrow.FixedWidthTypes.Boolean
+	case string:
+		return arrow.BinaryTypes.String
+	}
+	return nil
+}
+
+// storable checks if values of the given type can be stored in a column
+// without losing information. Integers are allowed in floating-point columns
+// to not roll files for fields alternating between integer and float values.
+func storable(datatype, column arrow.DataType) bool {
+	if arrow.TypeEqual(datatype, column) {
+		return true
+	}
+	return arrow.TypeEqual(column, arrow.PrimitiveTypes.Float64) && isNumeric(datatype)
+}
+
+// widenType returns the type of a column holding values of both types.
+// Numeric values are stored as float and all other combinations as string.
+func widenType(a, b arrow.DataType) arrow.DataType {
+	switch {
+	case arrow.TypeEqual(a, b):
+		return a
+	case isNumeric(a) && isNumeric(b):
+		return arrow.PrimitiveTypes.Float64
+	}
+	return arrow.BinaryTypes.String
+}
+
+func isNumeric(datatype arrow.DataType) bool {
+	switch datatype.ID() {
+	case arrow.INT64, arrow.UINT64, arrow.FLOAT64:
+		return true
+	}
+	return false
+}
+
+// columnValue returns the value of the metric for the column converted to the
+// column type. Fields take precedence over tags of the same name.
+func columnValue(m telegraf.Metric, column arrow.Field) (interface{}, error) {
+	value, found := m.GetField(column.Name)
+	if !found {
+		if value, found = m.GetTag(column.Name); !found {
+			return nil, nil
+		}
+	}
+
+	var v interface{}
+	var err error
+	switch column.Type {
+	case arrow.PrimitiveTypes.Int64:
+		v, err = internal.ToInt64(value)
+	case arrow.PrimitiveTypes.Uint64:
+		v, err = internal.ToUint64(value)
+	case arrow.PrimitiveTypes.Float64:
+		v, err = internal.ToFloat64(value)
+	case arrow.FixedWidthTypes.Boolean:
+		v, err = internal.ToBool(value)
+	case arrow.BinaryTypes.String:
+		v, err = internal.ToString(value)
+	default:
+		return nil, fmt.Errorf("unsupported type %s of column %q", column.Type, column.Name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("converting value of column %q failed: %w", column.Name, err)
+	}
+	return v, nil
+}
+
+func appendValue(builder array.Builder, value interface{}) {
+	if value == nil {
+		builder.AppendNull()
+		return
+	}
+	switch b := builder.(type) {
+	case *array.TimestampBuilder:
+		b.Append(value.(arrow.Timestamp))
+	case *array.Int64Builder:
+		b.Append(value.(int64))
+	case *array.Uint64Builder:
+		b.Append(value.(uint64))
+	case *array.Float64Builder:
+		b.Append(value.(float64))
+	case *array.BooleanBuilder:
+		b.Append(value.(bool))
+	case *array.StringBuilder:
+		b.Append(value.(string))
+	}
+}
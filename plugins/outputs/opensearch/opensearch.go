@@ -13,6 +13,8 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"text/template"
 	"time"
 
@@ -22,6 +24,7 @@ import (
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal"
 	"github.com/influxdata/telegraf/internal/choice"
 	"github.com/influxdata/telegraf/plugins/common/tls"
 	"github.com/influxdata/telegraf/plugins/outputs"
@@ -220,14 +223,35 @@ func (o *Opensearch) Write(metrics []telegraf.Metric) error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(o.Timeout))
 	defer cancel()
 
-	for _, metric := range metrics {
+	// The failures of the items are reported asynchronously by the indexer
+	// workers, so protect the state. Metrics that neither failed nor were
+	// rejected are accepted if sending the bulk requests succeeded.
+	var mu sync.Mutex
+	var reported uint64
+	failed := make(map[int]bool)
+	writeErr := &internal.PartialWriteError{}
+	reject := func(idx int, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		failed[idx] = true
+		writeErr.Reject(idx, err)
+	}
+	keep := func(idx int) {
+		mu.Lock()
+		defer mu.Unlock()
+		failed[idx] = true
+	}
+
+	for i, metric := range metrics {
 		var name = metric.Name()
 
 		// index name has to be re-evaluated each time for telegraf
 		// to send the metric to the correct time-based index
 		indexName, err := o.GetIndexName(metric)
 		if err != nil {
-			return fmt.Errorf("generating indexname failed: %w", err)
+			o.Log.Errorf("Generating indexname failed: %v", err)
+			reject(i, err)
+			continue
 		}
 
 		// Handle NaN and inf field-values
@@ -258,7 +282,9 @@ func (o *Opensearch) Write(metrics []telegraf.Metric) error {
 
 		body, err := json.Marshal(m)
 		if err != nil {
-			return fmt.Errorf("failed to marshal body: %w", err)
+			o.Log.Errorf("Failed to marshal body: %v", err)
+			reject(i, fmt.Errorf("%w: %w", internal.ErrSerialization, err))
+			continue
 		}
 
 		bulkIndxrItem := opensearchutil.BulkIndexerItem{
@@ -266,7 +292,21 @@ func (o *Opensearch) Write(metrics []telegraf.Metric) error {
 			Index:     indexName,
 			Body:      strings.NewReader(string(body)),
 			OnSuccess: o.onSucc,
-			OnFailure: o.onFail,
+			OnFailure: func(ctx context.Context, item opensearchutil.BulkIndexerItem, res opensearchutil.BulkIndexerResponseItem, err error) {
+				if o.onFail != nil {
+					o.onFail(ctx, item, res, err)
+				}
+				atomic.AddUint64(&reported, 1)
+
+				// Keep the metrics failing due to connection issues, overload
+				// or server errors for retrying, all other failures such as
+				// mapping errors will never succeed
+				if err != nil || res.Status == http.StatusTooManyRequests || res.Status >= 500 {
+					keep(i)
+					return
+				}
+				reject(i, fmt.Errorf("status %d: %s: %s", res.Status, res.Error.Type, res.Error.Reason))
+			},
 		}
 		if o.ForceDocumentID {
 			bulkIndxrItem.DocumentID = getPointID(metric)
@@ -275,13 +315,16 @@ func (o *Opensearch) Write(metrics []telegraf.Metric) error {
 		if o.UsePipeline != "" {
 			pipelineName, err := o.getPipelineName(metric)
 			if err != nil {
-				return fmt.Errorf("failed to evaluate pipeline name: %w", err)
+				o.Log.Errorf("Failed to evaluate pipeline name: %v", err)
+				reject(i, err)
+				continue
 			}
 
 			if pipelineName != "" {
 				if indexers[pipelineName] != nil {
 					if err := indexers[pipelineName].Add(ctx, bulkIndxrItem); err != nil {
 						o.Log.Errorf("error adding metric entry to OpenSearch bulkIndexer: %v for pipeline %s", err, pipelineName)
+						keep(i)
 					}
 					continue
 				}
@@ -290,24 +333,42 @@ func (o *Opensearch) Write(metrics []telegraf.Metric) error {
 
 		if err := indexers["default"].Add(ctx, bulkIndxrItem); err != nil {
 			o.Log.Errorf("error adding metric entry to OpenSearch default bulkIndexer: %v", err)
+			keep(i)
 		}
 	}
 
+	var numFailed uint64
 	for _, bulkIndxr := range indexers {
 		if err := bulkIndxr.Close(ctx); err != nil {
-			return fmt.Errorf("error sending bulk request to OpenSearch: %w", err)
+			writeErr.Err = fmt.Errorf("error sending bulk request to OpenSearch: %w", err)
+			continue
 		}
 
 		// Report the indexer statistics
 		stats := bulkIndxr.Stats()
-		if stats.NumFailed > 0 {
-			return fmt.Errorf("failed to index [%d] documents", stats.NumFailed)
-		}
+		numFailed += stats.NumFailed
 
 		o.Log.Debugf("Successfully indexed [%d] documents", stats.NumAdded)
 	}
 
-	return nil
+	// We cannot tell which metrics were sent if a bulk request failed as a
+	// whole, so keep all metrics not rejected for retrying
+	if writeErr.Err == nil && numFailed > atomic.LoadUint64(&reported) {
+		writeErr.Err = fmt.Errorf("failed to index [%d] documents", numFailed)
+	}
+	if writeErr.Err == nil {
+		if len(failed) == 0 {
+			return nil
+		}
+		writeErr.Err = fmt.Errorf("failed to index [%d] documents", len(failed))
+		writeErr.MetricsAccept = make([]int, 0, len(metrics)-len(failed))
+		for i := range metrics {
+			if !failed[i] {
+				writeErr.MetricsAccept = append(writeErr.MetricsAccept, i)
+			}
+		}
+	}
+	return writeErr
 }
 
 // BulkIndexer supports pipeline at config level so separate indexer instance for each unique pipeline
@@ -11,7 +11,9 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/testcontainers/testcontainers-go/wait"
 
+	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal"
 	"github.com/influxdata/telegraf/testutil"
 )
 
@@ -359,3 +361,55 @@ func TestDisconnectedServerOnWrite(t *testing.T) {
 	err = e.Write(testutil.MockMetrics())
 	require.Error(t, err)
 }
+
+func TestWritePartialBulkFailure(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/_bulk":
+			response := `{
+				"errors": true,
+				"items": [
+					{"index": {"_index": "test", "status": 201}},
+					{"index": {"_index": "test", "status": 400, "error": {"type": "mapper_parsing_exception", "reason": "failed to parse"}}},
+					{"index": {"_index": "test", "status": 429, "error": {"type": "es_rejected_execution_exception", "reason": "rejected execution"}}}
+				]
+			}`
+			if _, err := w.Write([]byte(response)); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				t.Error(err)
+			}
+		default:
+			if _, err := w.Write([]byte(`{"version": {"number": "2.8.0"}}`)); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				t.Error(err)
+			}
+		}
+	}))
+	defer ts.Close()
+
+	e := &Opensearch{
+		URLs:      []string{"http://" + ts.Listener.Addr().String()},
+		IndexName: "test",
+		Timeout:   config.Duration(time.Second * 5),
+		Log:       testutil.Logger{},
+	}
+	var err error
+	e.indexTmpl, err = template.New("index").Parse(e.IndexName)
+	require.NoError(t, err)
+	require.NoError(t, e.Connect())
+
+	metrics := []telegraf.Metric{
+		testutil.TestMetric(1.0, "a"),
+		testutil.TestMetric(2.0, "b"),
+		testutil.TestMetric(3.0, "c"),
+	}
+
+	// The mapping error is rejected while the overloaded request is kept
+	err = e.Write(metrics)
+	var writeErr *internal.PartialWriteError
+	require.ErrorAs(t, err, &writeErr)
+	require.Equal(t, []int{0}, writeErr.MetricsAccept)
+	require.Equal(t, []int{1}, writeErr.MetricsReject)
+	require.Len(t, writeErr.MetricsRejectErrors, 1)
+	require.ErrorContains(t, writeErr.MetricsRejectErrors[0], "failed to parse")
+}
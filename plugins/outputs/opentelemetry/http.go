@@ -0,0 +1,136 @@
+package opentelemetry
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const maxErrMsgLen = 1024
+
+// errRejected indicates a request permanently rejected by the receiver which
+// must not be retried
+var errRejected = errors.New("request rejected")
+
+var contentTypes = map[string]string{
+	"protobuf": "application/x-protobuf",
+	"json":     "application/json",
+}
+
+// exportHTTP sends the request to the configured OTLP/HTTP endpoint
+func (o *OpenTelemetry) exportHTTP(ctx context.Context, request pmetricotlp.ExportRequest) (pmetricotlp.ExportResponse, error) {
+	response := pmetricotlp.NewExportResponse()
+
+	var body []byte
+	var err error
+	if o.Encoding == "json" {
+		body, err = request.MarshalJSON()
+	} else {
+		body, err = request.MarshalProto()
+	}
+	if err != nil {
+		return response, fmt.Errorf("encoding request failed: %w", err)
+	}
+
+	if o.Compression == "gzip" {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		if _, err := gz.Write(body); err != nil {
+			return response, fmt.Errorf("compressing request failed: %w", err)
+		}
+		if err := gz.Close(); err != nil {
+			return response, fmt.Errorf("compressing request failed: %w", err)
+		}
+		body = buf.Bytes()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.URL, bytes.NewReader(body))
+	if err != nil {
+		return response, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Content-Type", contentTypes[o.Encoding])
+	if o.Compression == "gzip" {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	for k, v := range o.Headers {
+		if strings.EqualFold(k, "host") {
+			req.Host = v
+		}
+		req.Header.Set(k, v)
+	}
+
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		return response, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		// The OTLP specification defines these codes as retryable
+		return response, fmt.Errorf("received status code %d: %s", resp.StatusCode, readErrorMessage(resp))
+	}
+	if resp.StatusCode/100 != 2 {
+		return response, fmt.Errorf("%w with status code %d: %s", errRejected, resp.StatusCode, readErrorMessage(resp))
+	}
+
+	buf, err := io.ReadAll(resp.Body)
+	if err != nil || len(buf) == 0 {
+		// Data was accepted by the receiver so ignore missing responses
+		return response, nil //nolint:nilerr // response is optional
+	}
+	contentType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return response, nil //nolint:nilerr // response is optional
+	}
+	switch contentType {
+	case contentTypes["json"]:
+		err = response.UnmarshalJSON(buf)
+	case contentTypes["protobuf"]:
+		err = response.UnmarshalProto(buf)
+	default:
+		return response, nil
+	}
+	if err != nil {
+		o.Log.Debugf("Decoding response failed: %v", err)
+	}
+
+	return response, nil
+}
+
+// readErrorMessage extracts the error message from the google.rpc.Status
+// returned by OTLP receivers falling back to the first line of the body
+func readErrorMessage(resp *http.Response) string {
+	buf, err := io.ReadAll(io.LimitReader(resp.Body, maxErrMsgLen))
+	if err != nil {
+		return ""
+	}
+
+	var msg spb.Status
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch contentType {
+	case contentTypes["json"]:
+		if err := protojson.Unmarshal(buf, &msg); err == nil {
+			return msg.GetMessage()
+		}
+	case contentTypes["protobuf"]:
+		if err := proto.Unmarshal(buf, &msg); err == nil {
+			return msg.GetMessage()
+		}
+	}
+
+	line, _, _ := bytes.Cut(buf, []byte("\n"))
+	return string(line)
+}
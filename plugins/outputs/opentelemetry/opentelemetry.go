@@ -5,6 +5,9 @@ import (
 	"context"
 	ntls "crypto/tls"
 	_ "embed"
+	"errors"
+	"fmt"
+	"net/http"
 	"sort"
 	"time"
 
@@ -20,8 +23,9 @@ import (
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/config"
 	"github.com/influxdata/telegraf/internal"
-	"github.com/influxdata/telegraf/plugins/common/tls"
+	common_http "github.com/influxdata/telegraf/plugins/common/http"
 	"github.com/influxdata/telegraf/plugins/outputs"
+	"github.com/influxdata/telegraf/selfstat"
 )
 
 var userAgent = internal.ProductToken()
@@ -31,13 +35,14 @@ var sampleConfig string
 
 type OpenTelemetry struct {
 	ServiceAddress string `toml:"service_address"`
+	URL            string `toml:"url"`
+	Encoding       string `toml:"encoding"`
 
-	tls.ClientConfig
-	Timeout     config.Duration   `toml:"timeout"`
 	Compression string            `toml:"compression"`
 	Headers     map[string]string `toml:"headers"`
 	Attributes  map[string]string `toml:"attributes"`
 	Coralogix   *CoralogixConfig  `toml:"coralogix"`
+	common_http.HTTPClientConfig
 
 	Log telegraf.Logger `toml:"-"`
 
@@ -45,6 +50,8 @@ type OpenTelemetry struct {
 	grpcClientConn       *grpc.ClientConn
 	metricsServiceClient pmetricotlp.GRPCClient
 	callOptions          []grpc.CallOption
+	httpClient           *http.Client
+	rejectedDataPoints   selfstat.Stat
 }
 
 type CoralogixConfig struct {
@@ -57,6 +64,23 @@ func (*OpenTelemetry) SampleConfig() string {
 	return sampleConfig
 }
 
+func (o *OpenTelemetry) Init() error {
+	switch o.Encoding {
+	case "":
+		o.Encoding = "protobuf"
+	case "protobuf", "json":
+	default:
+		return fmt.Errorf("invalid encoding %q", o.Encoding)
+	}
+	if o.Encoding != "protobuf" && o.URL == "" {
+		return errors.New("encoding can only be set for OTLP/HTTP")
+	}
+
+	o.rejectedDataPoints = selfstat.Register("opentelemetry", "rejected_data_points", make(map[string]string))
+
+	return nil
+}
+
 func (o *OpenTelemetry) Connect() error {
 	logger := &otelLogger{o.Log}
 
@@ -82,6 +106,16 @@ func (o *OpenTelemetry) Connect() error {
 	if err != nil {
 		return err
 	}
+	o.metricsConverter = metricsConverter
+
+	if o.URL != "" {
+		client, err := o.HTTPClientConfig.CreateClient(context.Background(), o.Log)
+		if err != nil {
+			return err
+		}
+		o.httpClient = client
+		return nil
+	}
 
 	var grpcTLSDialOption grpc.DialOption
 	if tlsConfig, err := o.ClientConfig.TLSConfig(); err != nil {
@@ -102,7 +136,6 @@ func (o *OpenTelemetry) Connect() error {
 
 	metricsServiceClient := pmetricotlp.NewGRPCClient(grpcClientConn)
 
-	o.metricsConverter = metricsConverter
 	o.grpcClientConn = grpcClientConn
 	o.metricsServiceClient = metricsServiceClient
 
@@ -114,6 +147,10 @@ func (o *OpenTelemetry) Connect() error {
 }
 
 func (o *OpenTelemetry) Close() error {
+	if o.httpClient != nil {
+		o.httpClient.CloseIdleConnections()
+		o.httpClient = nil
+	}
 	if o.grpcClientConn != nil {
 		err := o.grpcClientConn.Close()
 		o.grpcClientConn = nil
@@ -124,14 +161,14 @@ func (o *OpenTelemetry) Close() error {
 
 // Split metrics up by timestamp and send to Google Cloud Stackdriver
 func (o *OpenTelemetry) Write(metrics []telegraf.Metric) error {
-	metricBatch := make(map[int64][]telegraf.Metric)
+	metricBatch := make(map[int64][]int)
 	timestamps := make([]int64, 0, len(metrics))
-	for _, metric := range metrics {
+	for idx, metric := range metrics {
 		timestamp := metric.Time().UnixNano()
 		if existingSlice, ok := metricBatch[timestamp]; ok {
-			metricBatch[timestamp] = append(existingSlice, metric)
+			metricBatch[timestamp] = append(existingSlice, idx)
 		} else {
-			metricBatch[timestamp] = []telegraf.Metric{metric}
+			metricBatch[timestamp] = []int{idx}
 			timestamps = append(timestamps, timestamp)
 		}
 	}
@@ -140,12 +177,45 @@ func (o *OpenTelemetry) Write(metrics []telegraf.Metric) error {
 	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
 
 	o.Log.Debugf("Received %d metrics and split into %d groups by timestamp", len(metrics), len(metricBatch))
+	var accept, reject []int
+	var lastErr error
 	for _, timestamp := range timestamps {
-		if err := o.sendBatch(metricBatch[timestamp]); err != nil {
-			return err
+		indices := metricBatch[timestamp]
+		batch := make([]telegraf.Metric, 0, len(indices))
+		for _, idx := range indices {
+			batch = append(batch, metrics[idx])
+		}
+
+		err := o.sendBatch(batch)
+		if err == nil {
+			accept = append(accept, indices...)
+			continue
+		}
+
+		// Drop metrics permanently rejected by the receiver and keep the
+		// remaining ones for retrying with the next write
+		if !errors.Is(err, errRejected) {
+			if len(accept) == 0 && len(reject) == 0 {
+				return err
+			}
+			return &internal.PartialWriteError{
+				Err:           err,
+				MetricsAccept: accept,
+				MetricsReject: reject,
+			}
 		}
+		o.Log.Errorf("Dropping %d metrics: %v", len(indices), err)
+		reject = append(reject, indices...)
+		lastErr = err
 	}
 
+	if len(reject) > 0 {
+		return &internal.PartialWriteError{
+			Err:           lastErr,
+			MetricsAccept: accept,
+			MetricsReject: reject,
+		}
+	}
 	return nil
 }
 
@@ -189,13 +259,34 @@ func (o *OpenTelemetry) sendBatch(metrics []telegraf.Metric) error {
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(o.Timeout))
+	defer cancel()
 
-	if len(o.Headers) > 0 {
-		ctx = metadata.NewOutgoingContext(ctx, metadata.New(o.Headers))
+	var response pmetricotlp.ExportResponse
+	var err error
+	if o.httpClient != nil {
+		response, err = o.exportHTTP(ctx, md)
+	} else {
+		if len(o.Headers) > 0 {
+			ctx = metadata.NewOutgoingContext(ctx, metadata.New(o.Headers))
+		}
+		response, err = o.metricsServiceClient.Export(ctx, md, o.callOptions...)
 	}
-	defer cancel()
-	_, err := o.metricsServiceClient.Export(ctx, md, o.callOptions...)
-	return err
+	if err != nil {
+		return err
+	}
+
+	// The receiver accepted the request but might have dropped parts of it.
+	// Those data points must not be retried and the response does not tell
+	// which data points were rejected, so only report the rejection.
+	partial := response.PartialSuccess()
+	if n := partial.RejectedDataPoints(); n > 0 {
+		o.rejectedDataPoints.Incr(n)
+		o.Log.Warnf("Receiver rejected %d data points: %s", n, partial.ErrorMessage())
+	} else if partial.ErrorMessage() != "" {
+		o.Log.Warnf("Receiver accepted request with warning: %s", partial.ErrorMessage())
+	}
+
+	return nil
 }
 
 const (
@@ -207,9 +298,10 @@ const (
 func init() {
 	outputs.Add("opentelemetry", func() telegraf.Output {
 		return &OpenTelemetry{
-			ServiceAddress: defaultServiceAddress,
-			Timeout:        defaultTimeout,
-			Compression:    defaultCompression,
+			ServiceAddress:   defaultServiceAddress,
+			Encoding:         "protobuf",
+			Compression:      defaultCompression,
+			HTTPClientConfig: common_http.HTTPClientConfig{Timeout: defaultTimeout},
 		}
 	})
 }
@@ -1,8 +1,13 @@
 package opentelemetry
 
 import (
+	"compress/gzip"
 	"context"
+	"io"
 	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -19,6 +24,8 @@ import (
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal"
+	common_http "github.com/influxdata/telegraf/plugins/common/http"
 	"github.com/influxdata/telegraf/testutil"
 )
 
@@ -45,7 +52,7 @@ func TestOpenTelemetry(t *testing.T) {
 	require.NoError(t, err)
 	plugin := &OpenTelemetry{
 		ServiceAddress:       m.Address(),
-		Timeout:              config.Duration(time.Second),
+		HTTPClientConfig:     common_http.HTTPClientConfig{Timeout: config.Duration(time.Second)},
 		Headers:              map[string]string{"test": "header1"},
 		Attributes:           map[string]string{"attr-key": "attr-val"},
 		metricsConverter:     metricsConverter,
@@ -144,3 +151,188 @@ func (m *mockOtelService) Export(ctx context.Context, request pmetricotlp.Export
 	require.True(m.t, ok)
 	return pmetricotlp.NewExportResponse(), nil
 }
+
+func TestOpenTelemetryHTTP(t *testing.T) {
+	tests := []struct {
+		name        string
+		encoding    string
+		compression string
+		contentType string
+	}{
+		{
+			name:        "protobuf gzip",
+			encoding:    "protobuf",
+			compression: "gzip",
+			contentType: "application/x-protobuf",
+		},
+		{
+			name:        "json uncompressed",
+			encoding:    "json",
+			compression: "none",
+			contentType: "application/json",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var received pmetricotlp.ExportRequest
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/v1/metrics" || r.Header.Get("Content-Type") != tt.contentType || r.Header.Get("test") != "header1" {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+
+				var body io.Reader = r.Body
+				if r.Header.Get("Content-Encoding") == "gzip" {
+					gz, err := gzip.NewReader(r.Body)
+					if err != nil {
+						w.WriteHeader(http.StatusBadRequest)
+						return
+					}
+					defer gz.Close()
+					body = gz
+				}
+				buf, err := io.ReadAll(body)
+				if err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+
+				received = pmetricotlp.NewExportRequest()
+				if tt.encoding == "json" {
+					err = received.UnmarshalJSON(buf)
+				} else {
+					err = received.UnmarshalProto(buf)
+				}
+				if err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+
+				response := pmetricotlp.NewExportResponse()
+				response.PartialSuccess().SetRejectedDataPoints(1)
+				response.PartialSuccess().SetErrorMessage("invalid data point")
+				if tt.encoding == "json" {
+					buf, err = response.MarshalJSON()
+				} else {
+					buf, err = response.MarshalProto()
+				}
+				if err != nil {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				w.Header().Set("Content-Type", tt.contentType)
+				if _, err := w.Write(buf); err != nil {
+					t.Error(err)
+				}
+			}))
+			defer server.Close()
+
+			logger := &testutil.CaptureLogger{}
+			plugin := &OpenTelemetry{
+				URL:              server.URL + "/v1/metrics",
+				Encoding:         tt.encoding,
+				Compression:      tt.compression,
+				Headers:          map[string]string{"test": "header1"},
+				HTTPClientConfig: common_http.HTTPClientConfig{Timeout: config.Duration(time.Second)},
+				Log:              logger,
+			}
+			require.NoError(t, plugin.Init())
+			require.NoError(t, plugin.Connect())
+			defer plugin.Close()
+
+			input := testutil.MustMetric(
+				"cpu_temp",
+				map[string]string{"foo": "bar"},
+				map[string]interface{}{"gauge": 87.332},
+				time.Unix(0, 1622848686000000000),
+				telegraf.Gauge,
+			)
+			// The partially rejected request must be accepted as the receiver
+			// does not tell which data points were dropped
+			before := plugin.rejectedDataPoints.Get()
+			require.NoError(t, plugin.Write([]telegraf.Metric{input}))
+			require.Equal(t, int64(1), plugin.rejectedDataPoints.Get()-before)
+			require.Contains(t, logger.Warnings(), "W! [] Receiver rejected 1 data points: invalid data point")
+
+			metrics := received.Metrics()
+			require.Equal(t, 1, metrics.DataPointCount())
+			m := metrics.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0)
+			require.Equal(t, "cpu_temp", m.Name())
+			require.InDelta(t, 87.332, m.Gauge().DataPoints().At(0).DoubleValue(), 1e-9)
+		})
+	}
+}
+
+func TestOpenTelemetryHTTPErrors(t *testing.T) {
+	var code atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/x-protobuf" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		buf, err := io.ReadAll(gz)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		request := pmetricotlp.NewExportRequest()
+		if err := request.UnmarshalProto(buf); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		// Only fail for the second batch
+		ts := request.Metrics().ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Gauge().DataPoints().At(0).Timestamp()
+		if ts.AsTime().Unix() == 2 {
+			w.WriteHeader(int(code.Load()))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	plugin := &OpenTelemetry{
+		URL:              server.URL,
+		Compression:      "gzip",
+		HTTPClientConfig: common_http.HTTPClientConfig{Timeout: config.Duration(time.Second)},
+		Log:              &testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	input := []telegraf.Metric{
+		testutil.MustMetric("cpu", map[string]string{}, map[string]interface{}{"value": 1.0}, time.Unix(1, 0), telegraf.Gauge),
+		testutil.MustMetric("cpu", map[string]string{}, map[string]interface{}{"value": 2.0}, time.Unix(2, 0), telegraf.Gauge),
+		testutil.MustMetric("cpu", map[string]string{}, map[string]interface{}{"value": 3.0}, time.Unix(3, 0), telegraf.Gauge),
+	}
+
+	// Retryable errors should keep the failing and all following metrics
+	code.Store(http.StatusServiceUnavailable)
+	err := plugin.Write(input)
+	var writeErr *internal.PartialWriteError
+	require.ErrorAs(t, err, &writeErr)
+	require.Equal(t, []int{0}, writeErr.MetricsAccept)
+	require.Empty(t, writeErr.MetricsReject)
+
+	// Permanent errors should drop the rejected metrics only
+	code.Store(http.StatusBadRequest)
+	err = plugin.Write(input)
+	require.ErrorAs(t, err, &writeErr)
+	require.ErrorIs(t, err, errRejected)
+	require.Equal(t, []int{0, 2}, writeErr.MetricsAccept)
+	require.Equal(t, []int{1}, writeErr.MetricsReject)
+}
+
+func TestInitInvalidEncoding(t *testing.T) {
+	plugin := &OpenTelemetry{Encoding: "xml", URL: "http://localhost:4318/v1/metrics"}
+	require.ErrorContains(t, plugin.Init(), "invalid encoding")
+
+	plugin = &OpenTelemetry{Encoding: "json"}
+	require.ErrorContains(t, plugin.Init(), "only be set for OTLP/HTTP")
+}
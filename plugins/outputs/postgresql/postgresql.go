@@ -240,7 +240,7 @@ func (p *Postgresql) Write(metrics []telegraf.Metric) error {
 	if p.db.Stat().MaxConns() > 1 {
 		p.writeConcurrent(tableSources)
 	} else {
-		err = p.writeSequential(tableSources)
+		err = p.writeSequential(tableSources, metrics)
 	}
 	if err != nil {
 		var pgErr *pgconn.PgError
@@ -255,14 +255,19 @@ func (p *Postgresql) Write(metrics []telegraf.Metric) error {
 	return err
 }
 
-func (p *Postgresql) writeSequential(tableSources map[string]*TableSource) error {
+func (p *Postgresql) writeSequential(tableSources map[string]*TableSource, metrics []telegraf.Metric) error {
 	tx, err := p.db.Begin(p.dbContext)
 	if err != nil {
 		return fmt.Errorf("starting transaction: %w", err)
 	}
-	defer tx.Rollback(p.dbContext) //nolint:errcheck // In case of failure during commit, "err" from commit will be returned
+	defer func() {
+		tx.Rollback(p.dbContext) //nolint:errcheck // In case of failure during commit, "err" from commit will be returned
+	}()
 
-	for _, tableSource := range tableSources {
+	// Collect the metrics failing permanently by their position in the
+	// sub-batch to reject them as they will never succeed
+	rejected := make(map[string]map[int]error)
+	for name, tableSource := range tableSources {
 		sp := tx
 		if len(tableSources) > 1 {
 			// wrap each sub-batch in a savepoint so that if a permanent error is received, we can drop just that one sub-batch, and insert everything else.
@@ -272,28 +277,98 @@ func (p *Postgresql) writeSequential(tableSources map[string]*TableSource) error
 			}
 		}
 
-		err := p.writeMetricsFromMeasure(p.dbContext, sp, tableSource)
-		if err != nil {
-			if isTempError(err) {
-				// return so that telegraf will retry the whole batch
+		writeErr := p.writeMetricsFromMeasure(p.dbContext, sp, tableSource)
+		if writeErr == nil {
+			// savepoints do not need to be committed (released), so save the round trip and skip it
+			continue
+		}
+		if isTempError(writeErr) {
+			// return so that telegraf will retry the whole batch
+			return writeErr
+		}
+
+		// drop this one sub-batch, without a savepoint the whole transaction
+		// must be restarted
+		if len(tableSources) > 1 {
+			if err := sp.Rollback(p.dbContext); err != nil {
 				return err
 			}
-			p.Logger.Errorf("write error (permanent, dropping sub-batch): %v", err)
-			if len(tableSources) == 1 {
-				return nil
-			}
-			// drop this one sub-batch and continue trying the rest
-			if err := sp.Rollback(p.dbContext); err != nil {
+		} else {
+			if err := tx.Rollback(p.dbContext); err != nil {
 				return err
 			}
+			if tx, err = p.db.Begin(p.dbContext); err != nil {
+				return fmt.Errorf("starting transaction: %w", err)
+			}
 		}
-		// savepoints do not need to be committed (released), so save the round trip and skip it
+
+		if !isDataError(writeErr) {
+			p.Logger.Errorf("write error (permanent, dropping sub-batch): %v", writeErr)
+			failed := make(map[int]error, len(tableSource.metrics))
+			for i := range tableSource.metrics {
+				failed[i] = writeErr
+			}
+			rejected[name] = failed
+			continue
+		}
+
+		// retry the metrics one by one to only drop the offending ones
+		p.Logger.Errorf("write error (permanent, dropping failing metrics of sub-batch): %v", writeErr)
+		failed, err := p.writeIsolated(p.dbContext, tx, tableSource)
+		if err != nil {
+			return err
+		}
+		rejected[name] = failed
 	}
 
 	if err := tx.Commit(p.dbContext); err != nil {
 		return fmt.Errorf("committing transaction: %w", err)
 	}
-	return nil
+
+	writeErr := &internal.PartialWriteError{
+		MetricsAccept: make([]int, 0, len(metrics)),
+	}
+	positions := make(map[string]int, len(tableSources))
+	for i, m := range metrics {
+		pos := positions[m.Name()]
+		positions[m.Name()]++
+		if err, found := rejected[m.Name()][pos]; found {
+			writeErr.Reject(i, err)
+			continue
+		}
+		writeErr.MetricsAccept = append(writeErr.MetricsAccept, i)
+	}
+	return writeErr.Result(nil)
+}
+
+// writeIsolated writes the metrics of a sub-batch one by one, each in its own
+// (sub-)transaction, to only drop the metrics failing permanently. The errors
+// of the dropped metrics are returned by their position in the sub-batch.
+func (p *Postgresql) writeIsolated(ctx context.Context, db dbh, tableSource *TableSource) (map[int]error, error) {
+	failed := make(map[int]error)
+	for i, m := range tableSource.metrics {
+		single := NewTableSource(p, m.Name())
+		single.AddMetric(m)
+
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("starting transaction: %w", err)
+		}
+		if err := p.writeMetricsFromMeasure(ctx, tx, single); err != nil {
+			if errRollback := tx.Rollback(ctx); errRollback != nil {
+				return nil, errRollback
+			}
+			if isTempError(err) {
+				return nil, err
+			}
+			failed[i] = err
+			continue
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return failed, nil
 }
 
 func (p *Postgresql) writeConcurrent(tableSources map[string]*TableSource) {
@@ -315,7 +390,7 @@ func (p *Postgresql) writeWorker(ctx context.Context) {
 				return
 			}
 			if err := p.writeRetry(ctx, tableSource); err != nil {
-				p.Logger.Errorf("write error (permanent, dropping sub-batch): %v", err)
+				p.dropFailing(ctx, tableSource, err)
 			}
 		case <-p.dbContext.Done():
 			return
@@ -323,6 +398,52 @@ func (p *Postgresql) writeWorker(ctx context.Context) {
 	}
 }
 
+// dropFailing drops the metrics of a sub-batch failing permanently when
+// writing concurrently. The rejected metrics cannot be reported to telegraf
+// as the write already completed, so the metrics are only logged.
+func (p *Postgresql) dropFailing(ctx context.Context, tableSource *TableSource, err error) {
+	if !isDataError(err) {
+		p.Logger.Errorf("write error (permanent, dropping sub-batch): %v", err)
+		return
+	}
+
+	// retry the metrics one by one to only drop the offending ones
+	p.Logger.Errorf("write error (permanent, dropping failing metrics of sub-batch): %v", err)
+	failed, err := p.writeIsolated(ctx, p.db, tableSource)
+	if err != nil {
+		p.Logger.Errorf("write error (dropping remaining metrics of sub-batch): %v", err)
+		return
+	}
+	for _, err := range failed {
+		p.Logger.Errorf("write error (permanent, dropping metric of %q): %v", tableSource.Name(), err)
+	}
+}
+
+// isDataError reports whether a permanent error is caused by the values of
+// individual metrics, e.g. by violating a constraint, so writing the metrics
+// of the sub-batch one by one allows to only drop the offending metrics.
+func isDataError(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	switch pgErr.Code[:2] {
+	case "22": // Data Exception
+		return true
+	case "23": // Integrity Constraint Violation
+		return true
+	}
+	switch pgErr.Code {
+	case "42804": // datatype_mismatch
+		return true
+	case "57014": // query_cancelled
+		// Returned when PGX cancels the copy as it can't convert a value to
+		// the column's type, see isTempError.
+		return true
+	}
+	return false
+}
+
 // isTempError reports whether the error received during a metric write operation is temporary or permanent.
 // A temporary error is one that if the write were retried at a later time, that it might succeed.
 // Note however that this applies to the transaction as a whole, not the individual operation. Meaning for example a
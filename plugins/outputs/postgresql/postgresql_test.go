@@ -529,7 +529,11 @@ func TestWriteIntegration_sequentialPermError(t *testing.T) {
 		newMetric(t, "_a", MSS{}, MSI{"v": "a"}),
 		newMetric(t, "_b", MSS{}, MSI{"v": 3}),
 	}
-	require.NoError(t, p.Write(metrics))
+	err = p.Write(metrics)
+	var writeErr *internal.PartialWriteError
+	require.ErrorAs(t, err, &writeErr)
+	require.Equal(t, []int{1}, writeErr.MetricsAccept)
+	require.Equal(t, []int{0}, writeErr.MetricsReject)
 
 	dumpA := dbTableDump(t, p.db, "_a")
 	dumpB := dbTableDump(t, p.db, "_b")
@@ -546,7 +550,7 @@ func TestWriteIntegration_sequentialPermError(t *testing.T) {
 	require.True(t, haveError, "write error not found in log")
 }
 
-// Test that in a bach with only 1 sub-batch, that we don't return an error.
+// Test that in a batch with only 1 sub-batch, all metrics are rejected.
 func TestWriteIntegration_sequentialSinglePermError(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping integration test in short mode")
@@ -564,7 +568,11 @@ func TestWriteIntegration_sequentialSinglePermError(t *testing.T) {
 	metrics = []telegraf.Metric{
 		newMetric(t, "", MSS{}, MSI{"v": "a"}),
 	}
-	require.NoError(t, p.Write(metrics))
+	err = p.Write(metrics)
+	var writeErr *internal.PartialWriteError
+	require.ErrorAs(t, err, &writeErr)
+	require.Empty(t, writeErr.MetricsAccept)
+	require.Equal(t, []int{0}, writeErr.MetricsReject)
 }
 
 // Test that the bad metric is dropped, and the rest of the batch succeeds.
@@ -600,6 +608,77 @@ func TestWriteIntegration_concurrentPermError(t *testing.T) {
 	require.Len(t, dumpB, 1)
 }
 
+// Test that only the metric violating a constraint is rejected, and the rest of the sub-batch succeeds.
+func TestWriteIntegration_sequentialConstraintError(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	p, err := newPostgresqlTest(t)
+	require.NoError(t, err)
+	require.NoError(t, p.Connect())
+
+	metrics := []telegraf.Metric{
+		newMetric(t, "", MSS{}, MSI{"v": 1}),
+	}
+	require.NoError(t, p.Write(metrics))
+	_, err = p.db.Exec(ctx, "ALTER TABLE "+utils.QuoteIdentifier(t.Name())+" ADD CHECK (v < 10)")
+	require.NoError(t, err)
+
+	metrics = []telegraf.Metric{
+		newMetric(t, "", MSS{}, MSI{"v": 2}),
+		newMetric(t, "", MSS{}, MSI{"v": 100}),
+		newMetric(t, "", MSS{}, MSI{"v": 3}),
+	}
+	err = p.Write(metrics)
+	var writeErr *internal.PartialWriteError
+	require.ErrorAs(t, err, &writeErr)
+	require.Equal(t, []int{0, 2}, writeErr.MetricsAccept)
+	require.Equal(t, []int{1}, writeErr.MetricsReject)
+
+	dump := dbTableDump(t, p.db, "")
+	require.Len(t, dump, 3)
+	require.EqualValues(t, 1, dump[0]["v"])
+	require.EqualValues(t, 2, dump[1]["v"])
+	require.EqualValues(t, 3, dump[2]["v"])
+}
+
+// Test that only the metric violating a constraint is dropped, and the rest of the sub-batch succeeds.
+func TestWriteIntegration_concurrentConstraintError(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	p, err := newPostgresqlTest(t)
+	require.NoError(t, err)
+	p.dbConfig.MaxConns = 2
+	require.NoError(t, p.Connect())
+
+	metrics := []telegraf.Metric{
+		newMetric(t, "", MSS{}, MSI{"v": 1}),
+	}
+	require.NoError(t, p.Write(metrics))
+	p.Logger.WaitForCopy(t.Name(), false)
+	_, err = p.db.Exec(ctx, "ALTER TABLE "+utils.QuoteIdentifier(t.Name())+" ADD CHECK (v < 10)")
+	require.NoError(t, err)
+
+	metrics = []telegraf.Metric{
+		newMetric(t, "", MSS{}, MSI{"v": 2}),
+		newMetric(t, "", MSS{}, MSI{"v": 100}),
+		newMetric(t, "", MSS{}, MSI{"v": 3}),
+	}
+	require.NoError(t, p.Write(metrics))
+	p.Logger.WaitFor(func(l Log) bool {
+		return strings.Contains(l.String(), "dropping metric of")
+	}, false)
+
+	dump := dbTableDump(t, p.db, "")
+	require.Len(t, dump, 3)
+	require.EqualValues(t, 1, dump[0]["v"])
+	require.EqualValues(t, 2, dump[1]["v"])
+	require.EqualValues(t, 3, dump[2]["v"])
+}
+
 // Verify that in sequential mode, errors are returned allowing telegraf agent to handle & retry
 func TestWriteIntegration_sequentialTempError(t *testing.T) {
 	if testing.Short() {
@@ -842,7 +921,9 @@ func TestWriteIntegration_tagError_foreignConstraint(t *testing.T) {
 	metrics = []telegraf.Metric{
 		newMetric(t, "", MSS{"tag": "bar"}, MSI{"v": 2}),
 	}
-	require.NoError(t, p.Write(metrics))
+	var writeErr *internal.PartialWriteError
+	require.ErrorAs(t, p.Write(metrics), &writeErr)
+	require.Equal(t, []int{0}, writeErr.MetricsReject)
 	haveError := false
 	for _, l := range p.Logger.Logs() {
 		if strings.Contains(l.String(), "write error") {
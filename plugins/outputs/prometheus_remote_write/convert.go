@@ -0,0 +1,270 @@
+package prometheus_remote_write
+
+import (
+	"cmp"
+	"hash/fnv"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/prometheus/prompb"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/serializers/prometheus"
+)
+
+type sample struct {
+	timestamp int64 // milliseconds
+	value     float64
+}
+
+// series collects the samples of a single Prometheus time-series in a batch
+type series struct {
+	labels    []prompb.Label
+	valueType telegraf.ValueType
+	samples   []sample
+	hash      uint64
+	created   int64 // milliseconds, zero if unknown
+
+	// Indices of the batch metrics contributing to this series
+	indices []int
+
+	// Result of sending the series
+	status status
+}
+
+// convert transforms the metrics into Prometheus time-series with samples
+// sorted by time. The series are returned in order of their first occurrence.
+func (p *PrometheusRemoteWrite) convert(metrics []telegraf.Metric) []*series {
+	lut := make(map[string]*series)
+	result := make([]*series, 0, len(metrics))
+
+	var dropped int
+	var lastErr string
+	for idx, m := range metrics {
+		common := p.commonLabels(m)
+		created := createdTimestamp(m)
+		for _, field := range m.FieldList() {
+			// String fields are either used as labels or ignored
+			if _, ok := field.Value.(string); ok {
+				continue
+			}
+			// Created timestamps are sent as series metadata, not as samples
+			if isCreatedField(m, field.Key) {
+				continue
+			}
+			name, extra, value, reason := convertField(m, field)
+			if reason != "" {
+				dropped++
+				lastErr = reason
+				continue
+			}
+
+			labels := make([]prompb.Label, 0, len(common)+2)
+			labels = append(labels, common...)
+			if extra.Name != "" {
+				labels = append(labels, extra)
+			}
+			labels = append(labels, prompb.Label{Name: "__name__", Value: name})
+			slices.SortStableFunc(labels, func(a, b prompb.Label) int {
+				return strings.Compare(a.Name, b.Name)
+			})
+			// Sanitized names might collide, so keep the first occurrence only
+			labels = slices.CompactFunc(labels, func(a, b prompb.Label) bool {
+				return a.Name == b.Name
+			})
+
+			key := seriesKey(labels)
+			s, found := lut[key]
+			if !found {
+				h := fnv.New64a()
+				h.Write([]byte(key))
+				s = &series{labels: labels, valueType: m.Type(), hash: h.Sum64()}
+				lut[key] = s
+				result = append(result, s)
+			}
+			s.samples = append(s.samples, sample{timestamp: m.Time().UnixMilli(), value: value})
+			if created != 0 {
+				s.created = created
+			}
+			if len(s.indices) == 0 || s.indices[len(s.indices)-1] != idx {
+				s.indices = append(s.indices, idx)
+			}
+		}
+	}
+	if dropped > 0 {
+		p.Log.Debugf("Dropped %d field(s) that cannot be converted; last reason: %s", dropped, lastErr)
+	}
+
+	// Samples need to be in order and unique per timestamp; for duplicate
+	// timestamps the last sample in the batch wins
+	for _, s := range result {
+		slices.SortStableFunc(s.samples, func(a, b sample) int {
+			return cmp.Compare(a.timestamp, b.timestamp)
+		})
+		deduped := s.samples[:0]
+		for _, smpl := range s.samples {
+			if n := len(deduped); n > 0 && deduped[n-1].timestamp == smpl.timestamp {
+				deduped[n-1] = smpl
+				continue
+			}
+			deduped = append(deduped, smpl)
+		}
+		s.samples = deduped
+	}
+
+	return result
+}
+
+// isCreatedField returns true if the field contains the created timestamp of
+// a counter, histogram or summary as produced by the OpenMetrics parser
+func isCreatedField(m telegraf.Metric, key string) bool {
+	switch m.Type() {
+	case telegraf.Counter, telegraf.Histogram, telegraf.Summary:
+		return key == "created" || strings.HasSuffix(key, "_created")
+	}
+	return false
+}
+
+// createdTimestamp returns the created timestamp of the metric in
+// milliseconds or zero if the metric does not have a valid one. The field
+// contains the seconds since epoch.
+func createdTimestamp(m telegraf.Metric) int64 {
+	for _, field := range m.FieldList() {
+		if !isCreatedField(m, field.Key) {
+			continue
+		}
+		seconds, ok := prometheus.SampleValue(field.Value)
+		if !ok || seconds <= 0 || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+			continue
+		}
+		return int64(seconds * 1000)
+	}
+	return 0
+}
+
+func (p *PrometheusRemoteWrite) commonLabels(m telegraf.Metric) []prompb.Label {
+	labels := make([]prompb.Label, 0, len(m.TagList()))
+	for _, tag := range m.TagList() {
+		// Ignore special tags for histogram and summary types
+		if (m.Type() == telegraf.Histogram && tag.Key == "le") || (m.Type() == telegraf.Summary && tag.Key == "quantile") {
+			continue
+		}
+		name, ok := prometheus.SanitizeLabelName(tag.Key)
+		if !ok || tag.Value == "" {
+			continue
+		}
+		labels = append(labels, prompb.Label{Name: name, Value: tag.Value})
+	}
+
+	if !p.StringAsLabel {
+		return labels
+	}
+
+	for _, field := range m.FieldList() {
+		value, ok := field.Value.(string)
+		if !ok || value == "" {
+			continue
+		}
+		name, ok := prometheus.SanitizeLabelName(field.Key)
+		if !ok {
+			continue
+		}
+		// Prefer tags over string fields of the same name
+		if slices.ContainsFunc(labels, func(l prompb.Label) bool { return l.Name == name }) {
+			continue
+		}
+		labels = append(labels, prompb.Label{Name: name, Value: value})
+	}
+
+	return labels
+}
+
+// convertField determines the series name, the optional additional label
+// and the value of the given field. If the field cannot be converted, the
+// reason is returned.
+func convertField(m telegraf.Metric, field *telegraf.Field) (string, prompb.Label, float64, string) {
+	var extra prompb.Label
+
+	raw := prometheus.MetricName(m.Name(), field.Key, m.Type())
+	name, ok := prometheus.SanitizeMetricName(raw)
+	if !ok {
+		return "", extra, 0, "invalid metric name " + strconv.Quote(raw)
+	}
+
+	switch m.Type() {
+	case telegraf.Histogram:
+		switch {
+		case strings.HasSuffix(field.Key, "_bucket"):
+			le, ok := m.GetTag("le")
+			if !ok {
+				return "", extra, 0, "missing 'le' tag for " + strconv.Quote(name)
+			}
+			bound, err := strconv.ParseFloat(le, 64)
+			if err != nil {
+				return "", extra, 0, "invalid 'le' tag for " + strconv.Quote(name)
+			}
+			count, ok := prometheus.SampleCount(field.Value)
+			if !ok {
+				return "", extra, 0, "invalid bucket value for " + strconv.Quote(name)
+			}
+			extra = prompb.Label{Name: "le", Value: strconv.FormatFloat(bound, 'g', -1, 64)}
+			return name + "_bucket", extra, float64(count), ""
+		case strings.HasSuffix(field.Key, "_sum"):
+			sum, ok := prometheus.SampleSum(field.Value)
+			if !ok {
+				return "", extra, 0, "invalid sum value for " + strconv.Quote(name)
+			}
+			return name + "_sum", extra, sum, ""
+		case strings.HasSuffix(field.Key, "_count"):
+			count, ok := prometheus.SampleCount(field.Value)
+			if !ok {
+				return "", extra, 0, "invalid count value for " + strconv.Quote(name)
+			}
+			return name + "_count", extra, float64(count), ""
+		}
+		return "", extra, 0, "unexpected histogram field " + strconv.Quote(field.Key)
+	case telegraf.Summary:
+		switch {
+		case strings.HasSuffix(field.Key, "_sum"):
+			sum, ok := prometheus.SampleSum(field.Value)
+			if !ok {
+				return "", extra, 0, "invalid sum value for " + strconv.Quote(name)
+			}
+			return name + "_sum", extra, sum, ""
+		case strings.HasSuffix(field.Key, "_count"):
+			count, ok := prometheus.SampleCount(field.Value)
+			if !ok {
+				return "", extra, 0, "invalid count value for " + strconv.Quote(name)
+			}
+			return name + "_count", extra, float64(count), ""
+		}
+		quantile, ok := m.GetTag("quantile")
+		if !ok {
+			return "", extra, 0, "missing 'quantile' tag for " + strconv.Quote(name)
+		}
+		q, err := strconv.ParseFloat(quantile, 64)
+		if err != nil {
+			return "", extra, 0, "invalid 'quantile' tag for " + strconv.Quote(name)
+		}
+		extra = prompb.Label{Name: "quantile", Value: strconv.FormatFloat(q, 'g', -1, 64)}
+	}
+
+	value, ok := prometheus.SampleValue(field.Value)
+	if !ok {
+		return "", extra, 0, "invalid value for " + strconv.Quote(name)
+	}
+	return name, extra, value, ""
+}
+
+func seriesKey(labels []prompb.Label) string {
+	var b strings.Builder
+	for _, l := range labels {
+		b.WriteString(l.Name)
+		b.WriteByte(0)
+		b.WriteString(l.Value)
+		b.WriteByte(0)
+	}
+	return b.String()
+}
@@ -0,0 +1,127 @@
+package prometheus_remote_write
+
+import (
+	"fmt"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/prompb"
+	writev2 "github.com/prometheus/prometheus/prompb/io/prometheus/write/v2"
+
+	"github.com/influxdata/telegraf"
+)
+
+const (
+	messageV1 = "prometheus.WriteRequest"
+	messageV2 = "io.prometheus.write.v2.Request"
+)
+
+var contentTypes = map[string]string{
+	messageV1: "application/x-protobuf",
+	messageV2: "application/x-protobuf;proto=" + messageV2,
+}
+
+var protocolVersions = map[string]string{
+	messageV1: "0.1.0",
+	messageV2: "2.0.0",
+}
+
+// part references a consecutive range of samples of a series to be sent in
+// a single request
+type part struct {
+	series  *series
+	samples []sample
+}
+
+// split divides the series into requests containing at most the given number
+// of samples while keeping the sample order of each series
+func split(series []*series, limit int) [][]part {
+	var requests [][]part
+	var current []part
+	var n int
+	for _, s := range series {
+		samples := s.samples
+		for len(samples) > 0 {
+			size := min(len(samples), limit-n)
+			current = append(current, part{series: s, samples: samples[:size]})
+			samples = samples[size:]
+			n += size
+			if n >= limit {
+				requests = append(requests, current)
+				current, n = nil, 0
+			}
+		}
+	}
+	if len(current) > 0 {
+		requests = append(requests, current)
+	}
+	return requests
+}
+
+func encode(message string, parts []part) ([]byte, error) {
+	var data []byte
+	var err error
+	switch message {
+	case messageV1:
+		data, err = encodeV1(parts)
+	case messageV2:
+		data, err = encodeV2(parts)
+	default:
+		return nil, fmt.Errorf("unknown protobuf message %q", message)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("marshalling request failed: %w", err)
+	}
+	return snappy.Encode(nil, data), nil
+}
+
+func encodeV1(parts []part) ([]byte, error) {
+	req := &prompb.WriteRequest{Timeseries: make([]prompb.TimeSeries, 0, len(parts))}
+	for _, p := range parts {
+		ts := prompb.TimeSeries{
+			Labels:  p.series.labels,
+			Samples: make([]prompb.Sample, 0, len(p.samples)),
+		}
+		for _, s := range p.samples {
+			ts.Samples = append(ts.Samples, prompb.Sample{Timestamp: s.timestamp, Value: s.value})
+		}
+		req.Timeseries = append(req.Timeseries, ts)
+	}
+	return req.Marshal()
+}
+
+func encodeV2(parts []part) ([]byte, error) {
+	symbols := writev2.NewSymbolTable()
+	req := &writev2.Request{Timeseries: make([]writev2.TimeSeries, 0, len(parts))}
+	for _, p := range parts {
+		ts := writev2.TimeSeries{
+			LabelsRefs: make([]uint32, 0, 2*len(p.series.labels)),
+			Samples:    make([]writev2.Sample, 0, len(p.samples)),
+			Metadata:   writev2.Metadata{Type: metadataType(p.series.valueType)},
+
+			CreatedTimestamp: p.series.created,
+		}
+		for _, l := range p.series.labels {
+			ts.LabelsRefs = append(ts.LabelsRefs, symbols.Symbolize(l.Name), symbols.Symbolize(l.Value))
+		}
+		for _, s := range p.samples {
+			ts.Samples = append(ts.Samples, writev2.Sample{Timestamp: s.timestamp, Value: s.value})
+		}
+		req.Timeseries = append(req.Timeseries, ts)
+	}
+	req.Symbols = symbols.Symbols()
+	return req.Marshal()
+}
+
+func metadataType(t telegraf.ValueType) writev2.Metadata_MetricType {
+	switch t {
+	case telegraf.Counter:
+		return writev2.Metadata_METRIC_TYPE_COUNTER
+	case telegraf.Gauge:
+		return writev2.Metadata_METRIC_TYPE_GAUGE
+	case telegraf.Histogram:
+		return writev2.Metadata_METRIC_TYPE_HISTOGRAM
+	case telegraf.Summary:
+		return writev2.Metadata_METRIC_TYPE_SUMMARY
+	}
+	return writev2.Metadata_METRIC_TYPE_UNSPECIFIED
+}
@@ -0,0 +1,214 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package prometheus_remote_write
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal"
+	common_http "github.com/influxdata/telegraf/plugins/common/http"
+	"github.com/influxdata/telegraf/plugins/outputs"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+type PrometheusRemoteWrite struct {
+	URL               string                    `toml:"url"`
+	ProtobufMessage   string                    `toml:"protobuf_message"`
+	Username          config.Secret             `toml:"username"`
+	Password          config.Secret             `toml:"password"`
+	Headers           map[string]*config.Secret `toml:"headers"`
+	StringAsLabel     bool                      `toml:"string_as_label"`
+	Shards            int                       `toml:"shards"`
+	MaxSamplesPerSend int                       `toml:"max_samples_per_send"`
+	MaxRetries        int                       `toml:"max_retries"`
+	MinBackoff        config.Duration           `toml:"min_backoff"`
+	MaxBackoff        config.Duration           `toml:"max_backoff"`
+	RetryOnHTTP429    bool                      `toml:"retry_on_http_429"`
+	Log               telegraf.Logger           `toml:"-"`
+	common_http.HTTPClientConfig
+
+	client *http.Client
+	shards []*shard
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	// Protobuf message currently used, might change during negotiation
+	message string
+	sync.Mutex
+}
+
+func (*PrometheusRemoteWrite) SampleConfig() string {
+	return sampleConfig
+}
+
+func (p *PrometheusRemoteWrite) Init() error {
+	if p.URL == "" {
+		return errors.New("url required")
+	}
+
+	switch p.ProtobufMessage {
+	case "":
+		p.ProtobufMessage = messageV1
+		p.message = messageV1
+	case messageV1, messageV2:
+		p.message = p.ProtobufMessage
+	case "auto":
+		p.message = messageV2
+	default:
+		return fmt.Errorf("invalid protobuf message %q", p.ProtobufMessage)
+	}
+
+	if p.Shards < 1 {
+		return errors.New("number of shards must be positive")
+	}
+	if p.MaxSamplesPerSend < 1 {
+		return errors.New("maximum samples per send must be positive")
+	}
+	if p.MaxRetries < 0 {
+		return errors.New("maximum retries must not be negative")
+	}
+	if p.MinBackoff <= 0 || p.MaxBackoff < p.MinBackoff {
+		return errors.New("invalid backoff settings")
+	}
+
+	return nil
+}
+
+func (p *PrometheusRemoteWrite) Connect() error {
+	client, err := p.HTTPClientConfig.CreateClient(context.Background(), p.Log)
+	if err != nil {
+		return err
+	}
+	p.client = client
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	p.shards = make([]*shard, 0, p.Shards)
+	for i := range p.Shards {
+		s := &shard{
+			id:      i,
+			queue:   make(chan *job, 1),
+			backoff: time.Duration(p.MinBackoff),
+			plugin:  p,
+		}
+		p.shards = append(p.shards, s)
+
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			s.run(ctx)
+		}()
+	}
+
+	return nil
+}
+
+func (p *PrometheusRemoteWrite) Close() error {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.wg.Wait()
+
+	if p.client != nil {
+		p.client.CloseIdleConnections()
+	}
+
+	return nil
+}
+
+func (p *PrometheusRemoteWrite) Write(metrics []telegraf.Metric) error {
+	// Distribute the series to the shards based on their labels so that all
+	// samples of a series are always sent by the same shard in order
+	converted := p.convert(metrics)
+	assigned := make([][]*series, len(p.shards))
+	for _, s := range converted {
+		idx := s.hash % uint64(len(p.shards))
+		assigned[idx] = append(assigned[idx], s)
+	}
+
+	jobs := make([]*job, 0, len(p.shards))
+	for i, series := range assigned {
+		if len(series) == 0 {
+			continue
+		}
+		j := &job{series: series, done: make(chan error, 1)}
+		p.shards[i].queue <- j
+		jobs = append(jobs, j)
+	}
+
+	var errs []error
+	for _, j := range jobs {
+		if err := <-j.done; err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+
+	// Determine the state of each metric based on its series, metrics with at
+	// least one failed series are kept for retrying with the next write
+	states := make([]status, len(metrics))
+	for _, s := range converted {
+		for _, idx := range s.indices {
+			states[idx] = max(states[idx], s.status)
+		}
+	}
+	var accept, reject []int
+	for idx, state := range states {
+		switch state {
+		case statusSent:
+			accept = append(accept, idx)
+		case statusRejected:
+			reject = append(reject, idx)
+		}
+	}
+
+	return &internal.PartialWriteError{
+		Err:           errors.Join(errs...),
+		MetricsAccept: accept,
+		MetricsReject: reject,
+	}
+}
+
+// currentMessage returns the protobuf message to use for requests
+func (p *PrometheusRemoteWrite) currentMessage() string {
+	p.Lock()
+	defer p.Unlock()
+	return p.message
+}
+
+// fallback switches to the Remote-Write 1.0 protocol if the receiver does
+// not support version 2.0
+func (p *PrometheusRemoteWrite) fallback(reason string) {
+	p.Lock()
+	defer p.Unlock()
+	if p.message == messageV1 {
+		return
+	}
+	p.Log.Infof("Falling back to %q messages as %s", messageV1, reason)
+	p.message = messageV1
+}
+
+func init() {
+	outputs.Add("prometheus_remote_write", func() telegraf.Output {
+		return &PrometheusRemoteWrite{
+			ProtobufMessage:   messageV1,
+			Shards:            4,
+			MaxSamplesPerSend: 2000,
+			MaxRetries:        3,
+			MinBackoff:        config.Duration(100 * time.Millisecond),
+			MaxBackoff:        config.Duration(5 * time.Second),
+			RetryOnHTTP429:    true,
+		}
+	})
+}
@@ -0,0 +1,666 @@
+package prometheus_remote_write
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/prompb"
+	writev2 "github.com/prometheus/prometheus/prompb/io/prometheus/write/v2"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+type receivedSample struct {
+	timestamp int64
+	value     float64
+}
+
+// receiver is a stub Remote-Write receiver recording the samples per series
+// and checking their order
+type receiver struct {
+	supportV2 bool
+	reportV2  bool
+	delay     time.Duration
+	handler   func(w http.ResponseWriter, r *http.Request) bool
+	requests  atomic.Int64
+
+	sync.Mutex
+	series   map[string][]receivedSample
+	metadata map[string]writev2.Metadata_MetricType
+	errs     []error
+}
+
+func newReceiver() *receiver {
+	return &receiver{
+		supportV2: true,
+		reportV2:  true,
+		series:    make(map[string][]receivedSample),
+		metadata:  make(map[string]writev2.Metadata_MetricType),
+	}
+}
+
+func (rcv *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rcv.requests.Add(1)
+	if rcv.handler != nil && rcv.handler(w, r) {
+		return
+	}
+	if rcv.delay > 0 {
+		time.Sleep(rcv.delay)
+	}
+
+	if r.Header.Get("Content-Encoding") != "snappy" {
+		http.Error(w, "invalid encoding", http.StatusBadRequest)
+		return
+	}
+	compressed, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	buf, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch r.Header.Get("Content-Type") {
+	case "application/x-protobuf":
+		var req prompb.WriteRequest
+		if err := req.Unmarshal(buf); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, ts := range req.Timeseries {
+			labels := make([]string, 0, len(ts.Labels))
+			for _, l := range ts.Labels {
+				labels = append(labels, l.Name+"="+l.Value)
+			}
+			samples := make([]receivedSample, 0, len(ts.Samples))
+			for _, s := range ts.Samples {
+				samples = append(samples, receivedSample{s.Timestamp, s.Value})
+			}
+			rcv.add(strings.Join(labels, ","), samples)
+		}
+	case "application/x-protobuf;proto=io.prometheus.write.v2.Request":
+		if !rcv.supportV2 {
+			http.Error(w, "unsupported", http.StatusUnsupportedMediaType)
+			return
+		}
+		var req writev2.Request
+		if err := req.Unmarshal(buf); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var written int
+		for _, ts := range req.Timeseries {
+			labels := make([]string, 0, len(ts.LabelsRefs)/2)
+			for i := 0; i < len(ts.LabelsRefs); i += 2 {
+				labels = append(labels, req.Symbols[ts.LabelsRefs[i]]+"="+req.Symbols[ts.LabelsRefs[i+1]])
+			}
+			samples := make([]receivedSample, 0, len(ts.Samples))
+			for _, s := range ts.Samples {
+				samples = append(samples, receivedSample{s.Timestamp, s.Value})
+			}
+			key := strings.Join(labels, ",")
+			rcv.add(key, samples)
+			rcv.Lock()
+			rcv.metadata[key] = ts.Metadata.Type
+			rcv.Unlock()
+			written += len(samples)
+		}
+		if rcv.reportV2 {
+			w.Header().Set(samplesWrittenHeader, strconv.Itoa(written))
+		}
+	default:
+		http.Error(w, "unsupported content-type", http.StatusUnsupportedMediaType)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (rcv *receiver) add(key string, samples []receivedSample) {
+	rcv.Lock()
+	defer rcv.Unlock()
+	existing := rcv.series[key]
+	for _, s := range samples {
+		if n := len(existing); n > 0 && existing[n-1].timestamp >= s.timestamp {
+			rcv.errs = append(rcv.errs, fmt.Errorf("out-of-order sample %d for %q after %d", s.timestamp, key, existing[n-1].timestamp))
+			continue
+		}
+		existing = append(existing, s)
+	}
+	rcv.series[key] = existing
+}
+
+func (rcv *receiver) samples(key string) []receivedSample {
+	rcv.Lock()
+	defer rcv.Unlock()
+	return rcv.series[key]
+}
+
+func newPlugin(url string) *PrometheusRemoteWrite {
+	return &PrometheusRemoteWrite{
+		URL:               url,
+		ProtobufMessage:   messageV1,
+		Shards:            4,
+		MaxSamplesPerSend: 2000,
+		MaxRetries:        3,
+		MinBackoff:        config.Duration(time.Millisecond),
+		MaxBackoff:        config.Duration(10 * time.Millisecond),
+		RetryOnHTTP429:    true,
+		Log:               testutil.Logger{},
+	}
+}
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(*PrometheusRemoteWrite)
+		expected string
+	}{
+		{
+			name:     "no url",
+			modify:   func(p *PrometheusRemoteWrite) { p.URL = "" },
+			expected: "url required",
+		},
+		{
+			name:     "invalid message",
+			modify:   func(p *PrometheusRemoteWrite) { p.ProtobufMessage = "v3" },
+			expected: "invalid protobuf message",
+		},
+		{
+			name:     "no shards",
+			modify:   func(p *PrometheusRemoteWrite) { p.Shards = 0 },
+			expected: "number of shards must be positive",
+		},
+		{
+			name:     "invalid backoff",
+			modify:   func(p *PrometheusRemoteWrite) { p.MaxBackoff = 0 },
+			expected: "invalid backoff settings",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := newPlugin("http://localhost:9090/api/v1/write")
+			tt.modify(plugin)
+			require.ErrorContains(t, plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestConvert(t *testing.T) {
+	plugin := newPlugin("")
+	plugin.StringAsLabel = true
+
+	metrics := []telegraf.Metric{
+		metric.New(
+			"cpu",
+			map[string]string{"host": "a", "cpu": "cpu0"},
+			map[string]interface{}{"usage_idle": 42.0, "state": "ok"},
+			time.Unix(2, 0),
+		),
+		metric.New(
+			"cpu",
+			map[string]string{"cpu": "cpu0", "host": "a"},
+			map[string]interface{}{"usage_idle": 41.0},
+			time.Unix(1, 0),
+		),
+		metric.New(
+			"cpu",
+			map[string]string{"cpu": "cpu0", "host": "a"},
+			map[string]interface{}{"usage_idle": 43.0},
+			time.Unix(2, 0),
+		),
+		metric.New(
+			"prometheus",
+			map[string]string{"le": "+Inf"},
+			map[string]interface{}{"http_request_duration_seconds_bucket": uint64(10)},
+			time.Unix(1, 0),
+			telegraf.Histogram,
+		),
+		metric.New(
+			"prometheus",
+			map[string]string{"quantile": "0.5"},
+			map[string]interface{}{"rpc_duration_seconds": 0.25},
+			time.Unix(1, 0),
+			telegraf.Summary,
+		),
+	}
+
+	converted := plugin.convert(metrics)
+	require.Len(t, converted, 4)
+
+	// The idle series with the state label
+	require.Equal(t, []prompb.Label{
+		{Name: "__name__", Value: "cpu_usage_idle"},
+		{Name: "cpu", Value: "cpu0"},
+		{Name: "host", Value: "a"},
+		{Name: "state", Value: "ok"},
+	}, converted[0].labels)
+	require.Equal(t, []sample{{2000, 42.0}}, converted[0].samples)
+	require.Equal(t, []int{0}, converted[0].indices)
+
+	// Samples are sorted and deduplicated with the last one winning
+	require.Equal(t, []prompb.Label{
+		{Name: "__name__", Value: "cpu_usage_idle"},
+		{Name: "cpu", Value: "cpu0"},
+		{Name: "host", Value: "a"},
+	}, converted[1].labels)
+	require.Equal(t, []sample{{1000, 41.0}, {2000, 43.0}}, converted[1].samples)
+	require.Equal(t, []int{1, 2}, converted[1].indices)
+
+	require.Equal(t, []prompb.Label{
+		{Name: "__name__", Value: "http_request_duration_seconds_bucket"},
+		{Name: "le", Value: "+Inf"},
+	}, converted[2].labels)
+	require.Equal(t, []prompb.Label{
+		{Name: "__name__", Value: "rpc_duration_seconds"},
+		{Name: "quantile", Value: "0.5"},
+	}, converted[3].labels)
+}
+
+func TestCreatedTimestamp(t *testing.T) {
+	plugin := newPlugin("")
+
+	metrics := []telegraf.Metric{
+		metric.New(
+			"requests",
+			map[string]string{},
+			map[string]interface{}{"counter": 42.0, "created": 1.5},
+			time.Unix(10, 0),
+			telegraf.Counter,
+		),
+		metric.New(
+			"prometheus",
+			map[string]string{"le": "+Inf"},
+			map[string]interface{}{"latency_bucket": uint64(10), "latency_created": 2.0},
+			time.Unix(10, 0),
+			telegraf.Histogram,
+		),
+		// Gauges do not have a created timestamp
+		metric.New(
+			"temperature",
+			map[string]string{},
+			map[string]interface{}{"value": 21.0, "created": 3.0},
+			time.Unix(10, 0),
+			telegraf.Gauge,
+		),
+	}
+
+	// The created fields of counters and histograms are not sent as samples
+	converted := plugin.convert(metrics)
+	require.Len(t, converted, 4)
+	require.Equal(t, "requests_counter", converted[0].labels[0].Value)
+	require.Equal(t, int64(1500), converted[0].created)
+	require.Equal(t, "latency_bucket", converted[1].labels[0].Value)
+	require.Equal(t, int64(2000), converted[1].created)
+	require.Equal(t, "temperature_value", converted[2].labels[0].Value)
+	require.Zero(t, converted[2].created)
+	require.Equal(t, "temperature_created", converted[3].labels[0].Value)
+	require.Zero(t, converted[3].created)
+
+	// Remote-Write 2.0 sends the created timestamp with the series
+	requests := split(converted, 100)
+	require.Len(t, requests, 1)
+	body, err := encode(messageV2, requests[0])
+	require.NoError(t, err)
+	buf, err := snappy.Decode(nil, body)
+	require.NoError(t, err)
+	var req writev2.Request
+	require.NoError(t, req.Unmarshal(buf))
+	require.Len(t, req.Timeseries, 4)
+	require.Equal(t, int64(1500), req.Timeseries[0].CreatedTimestamp)
+	require.Equal(t, int64(2000), req.Timeseries[1].CreatedTimestamp)
+	require.Zero(t, req.Timeseries[2].CreatedTimestamp)
+}
+
+func TestSplit(t *testing.T) {
+	s1 := &series{samples: []sample{{1, 1}, {2, 2}, {3, 3}}}
+	s2 := &series{samples: []sample{{1, 1}, {2, 2}}}
+
+	requests := split([]*series{s1, s2}, 2)
+	require.Len(t, requests, 3)
+	require.Equal(t, []part{{s1, s1.samples[:2]}}, requests[0])
+	require.Equal(t, []part{{s1, s1.samples[2:]}, {s2, s2.samples[:1]}}, requests[1])
+	require.Equal(t, []part{{s2, s2.samples[1:]}}, requests[2])
+}
+
+func TestWriteOrdering(t *testing.T) {
+	for _, message := range []string{messageV1, messageV2} {
+		t.Run(message, func(t *testing.T) {
+			rcv := newReceiver()
+			server := httptest.NewServer(rcv)
+			defer server.Close()
+
+			plugin := newPlugin(server.URL)
+			plugin.ProtobufMessage = message
+			plugin.MaxSamplesPerSend = 7
+			require.NoError(t, plugin.Init())
+			require.NoError(t, plugin.Connect())
+			defer plugin.Close()
+
+			// Send multiple batches of many series each containing multiple
+			// samples in reverse order within the batch
+			const nseries, nsamples, nbatches = 50, 4, 3
+			for batch := range nbatches {
+				metrics := make([]telegraf.Metric, 0, nseries*nsamples)
+				for i := nsamples - 1; i >= 0; i-- {
+					ts := time.Unix(int64(batch*nsamples+i), 0)
+					for s := range nseries {
+						metrics = append(metrics, metric.New(
+							"test",
+							map[string]string{"series": strconv.Itoa(s)},
+							map[string]interface{}{"value": float64(ts.Unix())},
+							ts,
+							telegraf.Counter,
+						))
+					}
+				}
+				require.NoError(t, plugin.Write(metrics))
+			}
+
+			rcv.Lock()
+			require.Empty(t, rcv.errs)
+			require.Len(t, rcv.series, nseries)
+			rcv.Unlock()
+			for s := range nseries {
+				key := "__name__=test_value,series=" + strconv.Itoa(s)
+				samples := rcv.samples(key)
+				require.Len(t, samples, nsamples*nbatches, key)
+				for i, smpl := range samples {
+					require.Equal(t, int64(i)*1000, smpl.timestamp)
+					require.InDelta(t, float64(i), smpl.value, 0)
+				}
+			}
+			if message == messageV2 {
+				rcv.Lock()
+				require.Equal(t, writev2.Metadata_METRIC_TYPE_COUNTER, rcv.metadata["__name__=test_value,series=0"])
+				rcv.Unlock()
+			}
+		})
+	}
+}
+
+func TestShardsScaleThroughput(t *testing.T) {
+	rcv := newReceiver()
+	rcv.delay = 50 * time.Millisecond
+	server := httptest.NewServer(rcv)
+	defer server.Close()
+
+	metrics := make([]telegraf.Metric, 0, 64)
+	for i := range 64 {
+		metrics = append(metrics, metric.New(
+			"test",
+			map[string]string{"series": strconv.Itoa(i)},
+			map[string]interface{}{"value": 1.0},
+			time.Unix(0, 0),
+		))
+	}
+
+	durations := make(map[int]time.Duration)
+	for _, shards := range []int{1, 8} {
+		plugin := newPlugin(server.URL)
+		plugin.Shards = shards
+		plugin.MaxSamplesPerSend = 4
+		require.NoError(t, plugin.Init())
+		require.NoError(t, plugin.Connect())
+
+		start := time.Now()
+		require.NoError(t, plugin.Write(metrics))
+		durations[shards] = time.Since(start)
+		require.NoError(t, plugin.Close())
+	}
+
+	// 16 sequential requests for a single shard, at most 4 for most of the
+	// 8 shards with perfect distribution
+	require.Greater(t, durations[1], 2*durations[8])
+}
+
+func TestRetryAfter(t *testing.T) {
+	rcv := newReceiver()
+	var calls atomic.Int64
+	rcv.handler = func(w http.ResponseWriter, _ *http.Request) bool {
+		switch calls.Add(1) {
+		case 1:
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return true
+		case 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return true
+		}
+		return false
+	}
+	server := httptest.NewServer(rcv)
+	defer server.Close()
+
+	plugin := newPlugin(server.URL)
+	plugin.Shards = 1
+	plugin.MaxBackoff = config.Duration(5 * time.Second)
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	start := time.Now()
+	require.NoError(t, plugin.Write([]telegraf.Metric{
+		metric.New("test", map[string]string{}, map[string]interface{}{"value": 1.0}, time.Unix(1, 0)),
+	}))
+	require.GreaterOrEqual(t, time.Since(start), time.Second)
+	require.Equal(t, int64(3), calls.Load())
+	require.Len(t, rcv.samples("__name__=test_value"), 1)
+}
+
+func TestRetryAfterLimited(t *testing.T) {
+	rcv := newReceiver()
+	var calls atomic.Int64
+	rcv.handler = func(w http.ResponseWriter, _ *http.Request) bool {
+		calls.Add(1)
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+		return true
+	}
+	server := httptest.NewServer(rcv)
+	defer server.Close()
+
+	plugin := newPlugin(server.URL)
+	plugin.Shards = 1
+	plugin.MaxBackoff = config.Duration(50 * time.Millisecond)
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	start := time.Now()
+	err := plugin.Write([]telegraf.Metric{
+		metric.New("test", map[string]string{}, map[string]interface{}{"value": 1.0}, time.Unix(1, 0)),
+	})
+	var werr *internal.PartialWriteError
+	require.ErrorAs(t, err, &werr)
+	require.Empty(t, werr.MetricsAccept)
+	require.Empty(t, werr.MetricsReject)
+
+	// The retries must wait for the maximum backoff only
+	require.Less(t, time.Since(start), 5*time.Second)
+	require.Equal(t, int64(plugin.MaxRetries+1), calls.Load())
+}
+
+func TestRetriesExhausted(t *testing.T) {
+	rcv := newReceiver()
+	var fail atomic.Bool
+	fail.Store(true)
+	rcv.handler = func(w http.ResponseWriter, r *http.Request) bool {
+		// Fail for the series "0" only
+		if !fail.Load() {
+			return false
+		}
+		compressed, err := io.ReadAll(r.Body)
+		if err != nil {
+			return false
+		}
+		r.Body = io.NopCloser(strings.NewReader(string(compressed)))
+		buf, err := snappy.Decode(nil, compressed)
+		if err != nil {
+			return false
+		}
+		var req prompb.WriteRequest
+		if err := req.Unmarshal(buf); err != nil {
+			return false
+		}
+		for _, ts := range req.Timeseries {
+			for _, l := range ts.Labels {
+				if l.Name == "series" && l.Value == "0" {
+					w.WriteHeader(http.StatusInternalServerError)
+					return true
+				}
+			}
+		}
+		return false
+	}
+	server := httptest.NewServer(rcv)
+	defer server.Close()
+
+	plugin := newPlugin(server.URL)
+	plugin.MaxSamplesPerSend = 1
+	plugin.MaxRetries = 2
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	metrics := make([]telegraf.Metric, 0, 8)
+	for i := range 8 {
+		metrics = append(metrics, metric.New(
+			"test",
+			map[string]string{"series": strconv.Itoa(i)},
+			map[string]interface{}{"value": float64(i)},
+			time.Unix(1, 0),
+		))
+	}
+
+	err := plugin.Write(metrics)
+	var werr *internal.PartialWriteError
+	require.ErrorAs(t, err, &werr)
+	require.ErrorContains(t, err, "received status code 500")
+	require.Empty(t, werr.MetricsReject)
+	require.NotContains(t, werr.MetricsAccept, 0)
+	require.NotEmpty(t, werr.MetricsAccept)
+
+	// Series of the same shard sent after the failing one are retried too
+	expected := make([]int, 0, len(metrics))
+	for i := range metrics {
+		if len(rcv.samples("__name__=test_value,series="+strconv.Itoa(i))) > 0 {
+			expected = append(expected, i)
+		}
+	}
+	require.Equal(t, expected, werr.MetricsAccept)
+
+	// Retrying the remaining metrics should succeed
+	fail.Store(false)
+	remaining := make([]telegraf.Metric, 0, len(metrics))
+	for i, m := range metrics {
+		if !slices.Contains(werr.MetricsAccept, i) {
+			remaining = append(remaining, m)
+		}
+	}
+	require.NoError(t, plugin.Write(remaining))
+	for i := range metrics {
+		require.Len(t, rcv.samples("__name__=test_value,series="+strconv.Itoa(i)), 1)
+	}
+}
+
+func TestRejected(t *testing.T) {
+	rcv := newReceiver()
+	rcv.handler = func(w http.ResponseWriter, _ *http.Request) bool {
+		http.Error(w, "invalid labels", http.StatusBadRequest)
+		return true
+	}
+	server := httptest.NewServer(rcv)
+	defer server.Close()
+
+	plugin := newPlugin(server.URL)
+	plugin.Shards = 1
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	err := plugin.Write([]telegraf.Metric{
+		metric.New("test", map[string]string{}, map[string]interface{}{"value": 1.0}, time.Unix(1, 0)),
+		metric.New("test", map[string]string{}, map[string]interface{}{"value": 2.0}, time.Unix(2, 0)),
+	})
+	var werr *internal.PartialWriteError
+	require.ErrorAs(t, err, &werr)
+	require.ErrorContains(t, err, "invalid labels")
+	require.Empty(t, werr.MetricsAccept)
+	require.Equal(t, []int{0, 1}, werr.MetricsReject)
+
+	// No retries for client errors
+	require.Equal(t, int64(1), rcv.requests.Load())
+}
+
+func TestNegotiation(t *testing.T) {
+	tests := []struct {
+		name      string
+		supportV2 bool
+		reportV2  bool
+		expected  string
+		requests  int64
+	}{
+		{
+			name:      "receiver with 2.0 support",
+			supportV2: true,
+			reportV2:  true,
+			expected:  messageV2,
+			requests:  3,
+		},
+		{
+			name:     "unsupported content-type",
+			expected: messageV1,
+			requests: 4,
+		},
+		{
+			// The accepted request must not be resent to avoid duplicates
+			name:      "no written samples reported",
+			supportV2: true,
+			expected:  messageV1,
+			requests:  3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rcv := newReceiver()
+			rcv.supportV2 = tt.supportV2
+			rcv.reportV2 = tt.reportV2
+			server := httptest.NewServer(rcv)
+			defer server.Close()
+
+			plugin := newPlugin(server.URL)
+			plugin.ProtobufMessage = "auto"
+			require.NoError(t, plugin.Init())
+			require.NoError(t, plugin.Connect())
+			defer plugin.Close()
+
+			for i := range 3 {
+				require.NoError(t, plugin.Write([]telegraf.Metric{
+					metric.New("test", map[string]string{}, map[string]interface{}{"value": 1.0}, time.Unix(int64(i), 0)),
+				}))
+			}
+			require.Equal(t, tt.expected, plugin.currentMessage())
+			require.Len(t, rcv.samples("__name__=test_value"), 3)
+			require.Equal(t, tt.requests, rcv.requests.Load())
+			require.Empty(t, rcv.errs)
+		})
+	}
+}
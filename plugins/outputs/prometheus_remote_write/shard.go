@@ -0,0 +1,223 @@
+package prometheus_remote_write
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf/internal"
+)
+
+const maxErrMsgLen = 1024
+
+const samplesWrittenHeader = "X-Prometheus-Remote-Write-Samples-Written"
+
+type status int
+
+const (
+	statusSent status = iota
+	statusRejected
+	statusFailed
+)
+
+// job contains the series to send by a shard for a single write
+type job struct {
+	series []*series
+	done   chan error
+}
+
+// shard sends the queued series sequentially with its own backoff state
+type shard struct {
+	id      int
+	queue   chan *job
+	backoff time.Duration
+	plugin  *PrometheusRemoteWrite
+}
+
+// requestError is the error for requests rejected by the receiver
+type requestError struct {
+	code       int
+	body       string
+	retryAfter time.Duration
+}
+
+func (e *requestError) Error() string {
+	return fmt.Sprintf("received status code %d: %s", e.code, e.body)
+}
+
+func (s *shard) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case j := <-s.queue:
+			j.done <- s.process(ctx, j.series)
+		}
+	}
+}
+
+func (s *shard) process(ctx context.Context, series []*series) error {
+	var lastErr error
+	requests := split(series, s.plugin.MaxSamplesPerSend)
+	for i, parts := range requests {
+		err := s.sendWithRetry(ctx, parts)
+		if err == nil {
+			continue
+		}
+
+		if !s.retryable(err) {
+			// Continue with the remaining series as the receiver will never
+			// accept the rejected samples
+			s.plugin.Log.Errorf("Shard %d: dropping %d series rejected by the receiver: %v", s.id, len(parts), err)
+			for _, p := range parts {
+				p.series.status = statusRejected
+			}
+			lastErr = err
+			continue
+		}
+
+		// Stop sending to keep the sample order of the series when retrying
+		// with the next write
+		for _, r := range requests[i:] {
+			for _, p := range r {
+				p.series.status = statusFailed
+			}
+		}
+		return fmt.Errorf("shard %d: %w", s.id, err)
+	}
+	if lastErr != nil {
+		return fmt.Errorf("shard %d: %w", s.id, lastErr)
+	}
+	return nil
+}
+
+func (s *shard) sendWithRetry(ctx context.Context, parts []part) error {
+	for attempt := 0; ; attempt++ {
+		err := s.send(ctx, parts)
+		if err == nil {
+			s.backoff = time.Duration(s.plugin.MinBackoff)
+			return nil
+		}
+		if !s.retryable(err) || attempt >= s.plugin.MaxRetries {
+			return err
+		}
+
+		// Honor the delay requested by the receiver but never exceed the
+		// maximum backoff as the write blocks the flush of the output
+		delay := s.backoff
+		var rerr *requestError
+		if errors.As(err, &rerr) && rerr.retryAfter > 0 {
+			delay = min(rerr.retryAfter, time.Duration(s.plugin.MaxBackoff))
+		}
+		s.plugin.Log.Debugf("Shard %d: retrying in %s after error: %v", s.id, delay, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		s.backoff = min(2*s.backoff, time.Duration(s.plugin.MaxBackoff))
+	}
+}
+
+func (s *shard) retryable(err error) bool {
+	if errors.Is(err, internal.ErrSerialization) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var rerr *requestError
+	if !errors.As(err, &rerr) {
+		// Connection errors and timeouts
+		return true
+	}
+	if rerr.code == http.StatusTooManyRequests {
+		return s.plugin.RetryOnHTTP429
+	}
+	return rerr.code >= 500
+}
+
+func (s *shard) send(ctx context.Context, parts []part) error {
+	p := s.plugin
+	message := p.currentMessage()
+
+	body, err := encode(message, parts)
+	if err != nil {
+		return fmt.Errorf("%w: %w", internal.ErrSerialization, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", internal.ProductToken())
+	req.Header.Set("Content-Type", contentTypes[message])
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", protocolVersions[message])
+
+	if !p.Username.Empty() || !p.Password.Empty() {
+		username, err := p.Username.Get()
+		if err != nil {
+			return fmt.Errorf("getting username failed: %w", err)
+		}
+		password, err := p.Password.Get()
+		if err != nil {
+			username.Destroy()
+			return fmt.Errorf("getting password failed: %w", err)
+		}
+		req.SetBasicAuth(username.String(), password.String())
+		username.Destroy()
+		password.Destroy()
+	}
+
+	for k, v := range p.Headers {
+		secret, err := v.Get()
+		if err != nil {
+			return err
+		}
+		if strings.EqualFold(k, "host") {
+			req.Host = secret.String()
+		}
+		req.Header.Set(k, secret.String())
+		secret.Destroy()
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Negotiate the protocol version if requested, receivers not supporting
+	// version 2.0 either reject the content-type or do not report the number
+	// of written samples. Only rejected requests are resent using version 1.0
+	// as accepted requests would duplicate the samples; in the latter case
+	// the fallback applies to subsequent requests.
+	if p.ProtobufMessage == "auto" && message == messageV2 {
+		switch {
+		case resp.StatusCode == http.StatusUnsupportedMediaType:
+			p.fallback("the receiver rejected the content-type")
+			return s.send(ctx, parts)
+		case resp.StatusCode/100 == 2 && resp.Header.Get(samplesWrittenHeader) == "":
+			p.fallback("the receiver does not report written samples")
+		}
+	}
+
+	if resp.StatusCode/100 != 2 {
+		var msg string
+		scanner := bufio.NewScanner(io.LimitReader(resp.Body, maxErrMsgLen))
+		if scanner.Scan() {
+			msg = scanner.Text()
+		}
+		return &requestError{
+			code:       resp.StatusCode,
+			body:       msg,
+			retryAfter: internal.ParseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+	_, err = io.Copy(io.Discard, resp.Body)
+	return err
+}
@@ -0,0 +1,252 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package pulsar
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal"
+	common_pulsar "github.com/influxdata/telegraf/plugins/common/pulsar"
+	"github.com/influxdata/telegraf/plugins/outputs"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+var compressionTypes = map[string]pulsar.CompressionType{
+	"none": pulsar.NoCompression,
+	"lz4":  pulsar.LZ4,
+	"zlib": pulsar.ZLib,
+	"zstd": pulsar.ZSTD,
+}
+
+type Pulsar struct {
+	Topic                   string          `toml:"topic"`
+	Key                     string          `toml:"key"`
+	ProducerName            string          `toml:"producer_name"`
+	SendTimeout             config.Duration `toml:"send_timeout"`
+	Compression             string          `toml:"compression"`
+	Batching                bool            `toml:"batching"`
+	BatchingMaxMessages     uint            `toml:"batching_max_messages"`
+	BatchingMaxSize         config.Size     `toml:"batching_max_size"`
+	BatchingMaxPublishDelay config.Duration `toml:"batching_max_publish_delay"`
+	Log                     telegraf.Logger `toml:"-"`
+	common_pulsar.ClientConfig
+
+	client     pulsar.Client
+	producers  map[string]pulsar.Producer
+	serializer telegraf.Serializer
+	topic      *template.Template
+	key        *template.Template
+}
+
+func (*Pulsar) SampleConfig() string {
+	return sampleConfig
+}
+
+func (p *Pulsar) Init() error {
+	if p.URL == "" {
+		return errors.New("url required")
+	}
+	if p.Topic == "" {
+		return errors.New("topic required")
+	}
+
+	if p.Compression == "" {
+		p.Compression = "none"
+	}
+	if _, found := compressionTypes[p.Compression]; !found {
+		return fmt.Errorf("invalid compression %q", p.Compression)
+	}
+
+	tmpl, err := template.New("topic").Parse(p.Topic)
+	if err != nil {
+		return fmt.Errorf("parsing topic template failed: %w", err)
+	}
+	p.topic = tmpl
+
+	if p.Key != "" {
+		tmpl, err := template.New("key").Parse(p.Key)
+		if err != nil {
+			return fmt.Errorf("parsing key template failed: %w", err)
+		}
+		p.key = tmpl
+	}
+
+	return nil
+}
+
+func (p *Pulsar) SetSerializer(serializer telegraf.Serializer) {
+	p.serializer = serializer
+}
+
+func (p *Pulsar) Connect() error {
+	client, err := p.ClientConfig.NewClient(p.Log)
+	if err != nil {
+		return &internal.StartupError{
+			Err:   fmt.Errorf("creating client failed: %w", err),
+			Retry: true,
+		}
+	}
+	p.client = client
+	p.producers = make(map[string]pulsar.Producer)
+
+	return nil
+}
+
+func (p *Pulsar) Close() error {
+	for _, producer := range p.producers {
+		producer.Close()
+	}
+	p.producers = nil
+
+	if p.client != nil {
+		p.client.Close()
+	}
+	return nil
+}
+
+func (p *Pulsar) Write(metrics []telegraf.Metric) error {
+	writeErr := &internal.PartialWriteError{
+		MetricsAccept: make([]int, 0, len(metrics)),
+	}
+
+	// Queue all messages to make use of the producer batching and wait for
+	// the results afterwards
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var lastErr error
+	used := make(map[string]pulsar.Producer)
+	for i, m := range metrics {
+		msg, topic, err := p.message(m)
+		if err != nil {
+			p.Log.Errorf("Dropping metric %v: %v", m, err)
+			writeErr.Reject(i, err)
+			continue
+		}
+
+		producer, err := p.producer(topic)
+		if err != nil {
+			// Keep the metric for retrying as the topic might become
+			// available later
+			lastErr = fmt.Errorf("creating producer for topic %q failed: %w", topic, err)
+			continue
+		}
+		used[topic] = producer
+
+		wg.Add(1)
+		producer.SendAsync(context.Background(), msg, func(_ pulsar.MessageID, _ *pulsar.ProducerMessage, err error) {
+			defer wg.Done()
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				lastErr = fmt.Errorf("sending message to topic %q failed: %w", topic, err)
+				return
+			}
+			writeErr.MetricsAccept = append(writeErr.MetricsAccept, i)
+		})
+	}
+
+	// Send the pending batches immediately instead of waiting for the
+	// publish delay
+	for topic, producer := range used {
+		if err := producer.Flush(); err != nil {
+			p.Log.Debugf("Flushing producer for topic %q failed: %v", topic, err)
+		}
+	}
+	wg.Wait()
+
+	if lastErr == nil && len(writeErr.MetricsReject) == 0 {
+		return nil
+	}
+	writeErr.Err = lastErr
+	if writeErr.Err == nil {
+		writeErr.Err = errors.Join(writeErr.MetricsRejectErrors...)
+	}
+	return writeErr
+}
+
+// message creates the Pulsar message for the given metric and determines the
+// topic to send the message to
+func (p *Pulsar) message(m telegraf.Metric) (*pulsar.ProducerMessage, string, error) {
+	var buf strings.Builder
+	if err := p.topic.Execute(&buf, m.(telegraf.TemplateMetric)); err != nil {
+		return nil, "", fmt.Errorf("executing topic template failed: %w", err)
+	}
+	topic := buf.String()
+	if topic == "" {
+		return nil, "", errors.New("empty topic")
+	}
+
+	var key string
+	if p.key != nil {
+		buf.Reset()
+		if err := p.key.Execute(&buf, m.(telegraf.TemplateMetric)); err != nil {
+			return nil, "", fmt.Errorf("executing key template failed: %w", err)
+		}
+		key = buf.String()
+	}
+
+	payload, err := p.serializer.Serialize(m)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %w", internal.ErrSerialization, err)
+	}
+
+	msg := &pulsar.ProducerMessage{
+		Payload:   payload,
+		Key:       key,
+		EventTime: m.Time(),
+	}
+	return msg, topic, nil
+}
+
+// producer returns the producer for the given topic creating it if necessary
+func (p *Pulsar) producer(topic string) (pulsar.Producer, error) {
+	if producer, found := p.producers[topic]; found {
+		return producer, nil
+	}
+
+	producer, err := p.client.CreateProducer(pulsar.ProducerOptions{
+		Topic:                   topic,
+		Name:                    p.ProducerName,
+		SendTimeout:             time.Duration(p.SendTimeout),
+		CompressionType:         compressionTypes[p.Compression],
+		DisableBatching:         !p.Batching,
+		BatchingMaxMessages:     p.BatchingMaxMessages,
+		BatchingMaxSize:         uint(p.BatchingMaxSize),
+		BatchingMaxPublishDelay: time.Duration(p.BatchingMaxPublishDelay),
+	})
+	if err != nil {
+		return nil, err
+	}
+	p.producers[topic] = producer
+
+	return producer, nil
+}
+
+func init() {
+	outputs.Add("pulsar", func() telegraf.Output {
+		return &Pulsar{
+			ClientConfig: common_pulsar.ClientConfig{
+				ConnectionTimeout: config.Duration(10 * time.Second),
+				OperationTimeout:  config.Duration(30 * time.Second),
+			},
+			SendTimeout:             config.Duration(30 * time.Second),
+			Compression:             "none",
+			Batching:                true,
+			BatchingMaxMessages:     1000,
+			BatchingMaxSize:         config.Size(128 * 1024),
+			BatchingMaxPublishDelay: config.Duration(10 * time.Millisecond),
+		}
+	})
+}
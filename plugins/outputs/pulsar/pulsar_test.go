@@ -0,0 +1,197 @@
+package pulsar
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/docker/go-connections/nat"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go/wait"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/metric"
+	common_pulsar "github.com/influxdata/telegraf/plugins/common/pulsar"
+	"github.com/influxdata/telegraf/plugins/serializers/influx"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInit(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *Pulsar
+		expected string
+	}{
+		{
+			name:     "missing url",
+			plugin:   &Pulsar{Topic: "telegraf"},
+			expected: "url required",
+		},
+		{
+			name: "missing topic",
+			plugin: &Pulsar{
+				ClientConfig: common_pulsar.ClientConfig{URL: "pulsar://localhost:6650"},
+			},
+			expected: "topic required",
+		},
+		{
+			name: "invalid compression",
+			plugin: &Pulsar{
+				ClientConfig: common_pulsar.ClientConfig{URL: "pulsar://localhost:6650"},
+				Topic:        "telegraf",
+				Compression:  "snappy",
+			},
+			expected: `invalid compression "snappy"`,
+		},
+		{
+			name: "invalid topic template",
+			plugin: &Pulsar{
+				ClientConfig: common_pulsar.ClientConfig{URL: "pulsar://localhost:6650"},
+				Topic:        "{{ .Tag ",
+			},
+			expected: "parsing topic template failed",
+		},
+		{
+			name: "invalid key template",
+			plugin: &Pulsar{
+				ClientConfig: common_pulsar.ClientConfig{URL: "pulsar://localhost:6650"},
+				Topic:        "telegraf",
+				Key:          "{{ .Tag ",
+			},
+			expected: "parsing key template failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestWriteIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	url := launchTestContainer(t)
+
+	// Subscribe before writing to receive the messages
+	client, err := pulsar.NewClient(pulsar.ClientOptions{URL: url})
+	require.NoError(t, err)
+	defer client.Close()
+	consumer, err := client.Subscribe(pulsar.ConsumerOptions{
+		Topics:                      []string{"persistent://public/default/telegraf-a", "persistent://public/default/telegraf-b"},
+		SubscriptionName:            "test",
+		SubscriptionInitialPosition: pulsar.SubscriptionPositionEarliest,
+	})
+	require.NoError(t, err)
+	defer consumer.Close()
+
+	serializer := &influx.Serializer{}
+	require.NoError(t, serializer.Init())
+
+	plugin := &Pulsar{
+		ClientConfig:            common_pulsar.ClientConfig{URL: url},
+		Topic:                   `persistent://public/default/telegraf-{{ .Tag "host" }}`,
+		Key:                     `{{ .Name }}`,
+		Compression:             "zstd",
+		Batching:                true,
+		BatchingMaxMessages:     1000,
+		BatchingMaxPublishDelay: config.Duration(time.Second),
+		SendTimeout:             config.Duration(10 * time.Second),
+		Log:                     &testutil.Logger{},
+	}
+	plugin.SetSerializer(serializer)
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	metrics := []telegraf.Metric{
+		metric.New("cpu", map[string]string{"host": "a"}, map[string]interface{}{"value": 1.0}, time.Unix(1, 0)),
+		metric.New("cpu", map[string]string{"host": "a"}, map[string]interface{}{"value": 2.0}, time.Unix(2, 0)),
+		metric.New("mem", map[string]string{"host": "b"}, map[string]interface{}{"value": 3.0}, time.Unix(3, 0)),
+	}
+	require.NoError(t, plugin.Write(metrics))
+
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Second)
+	defer cancel()
+	received := make(map[string][]string)
+	keys := make(map[string]string)
+	for range metrics {
+		msg, err := consumer.Receive(ctx)
+		require.NoError(t, err)
+		received[msg.Topic()] = append(received[msg.Topic()], string(msg.Payload()))
+		keys[string(msg.Payload())] = msg.Key()
+		require.NoError(t, consumer.Ack(msg))
+	}
+
+	expected := map[string][]string{
+		"persistent://public/default/telegraf-a": {
+			"cpu,host=a value=1 1000000000\n",
+			"cpu,host=a value=2 2000000000\n",
+		},
+		"persistent://public/default/telegraf-b": {
+			"mem,host=b value=3 3000000000\n",
+		},
+	}
+	require.Equal(t, expected, received)
+	require.Equal(t, "mem", keys["mem,host=b value=3 3000000000\n"])
+}
+
+func TestWriteRejectEmptyTopicIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	url := launchTestContainer(t)
+
+	serializer := &influx.Serializer{}
+	require.NoError(t, serializer.Init())
+
+	plugin := &Pulsar{
+		ClientConfig: common_pulsar.ClientConfig{URL: url},
+		Topic:        `{{ .Tag "topic" }}`,
+		SendTimeout:  config.Duration(10 * time.Second),
+		Log:          &testutil.Logger{},
+	}
+	plugin.SetSerializer(serializer)
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	metrics := []telegraf.Metric{
+		metric.New("cpu", map[string]string{"topic": "telegraf"}, map[string]interface{}{"value": 1.0}, time.Unix(1, 0)),
+		metric.New("cpu", map[string]string{}, map[string]interface{}{"value": 2.0}, time.Unix(2, 0)),
+	}
+
+	// Metrics resulting in an empty topic are rejected
+	err := plugin.Write(metrics)
+	var writeErr *internal.PartialWriteError
+	require.ErrorAs(t, err, &writeErr)
+	require.Equal(t, []int{0}, writeErr.MetricsAccept)
+	require.Equal(t, []int{1}, writeErr.MetricsReject)
+}
+
+func launchTestContainer(t *testing.T) string {
+	t.Helper()
+
+	servicePort := "6650"
+	container := testutil.Container{
+		Image:        "apachepulsar/pulsar:4.0.5",
+		ExposedPorts: []string{servicePort, "8080"},
+		Cmd:          []string{"bin/pulsar", "standalone"},
+		WaitingFor: wait.ForAll(
+			wait.ForListeningPort(nat.Port(servicePort)),
+			wait.ForHTTP("/admin/v2/clusters").WithPort("8080"),
+		),
+	}
+	require.NoError(t, container.Start(), "failed to start container")
+	t.Cleanup(container.Terminate)
+
+	return fmt.Sprintf("pulsar://%s:%s", container.Address, container.Ports[servicePort])
+}
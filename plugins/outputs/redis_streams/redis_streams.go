@@ -0,0 +1,197 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package redis_streams
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/common/tls"
+	"github.com/influxdata/telegraf/plugins/outputs"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+type RedisStreams struct {
+	Address           string          `toml:"address"`
+	Username          config.Secret   `toml:"username"`
+	Password          config.Secret   `toml:"password"`
+	Database          int             `toml:"database"`
+	Stream            string          `toml:"stream"`
+	Field             string          `toml:"field"`
+	MaxLen            int64           `toml:"max_len"`
+	MaxLenApproximate bool            `toml:"max_len_approximate"`
+	Timeout           config.Duration `toml:"timeout"`
+	Log               telegraf.Logger `toml:"-"`
+	tls.ClientConfig
+
+	client     *redis.Client
+	serializer telegraf.Serializer
+	stream     *template.Template
+}
+
+func (*RedisStreams) SampleConfig() string {
+	return sampleConfig
+}
+
+func (r *RedisStreams) Init() error {
+	if r.Address == "" {
+		return errors.New("address required")
+	}
+	if r.Stream == "" {
+		return errors.New("stream required")
+	}
+	if r.Field == "" {
+		return errors.New("field required")
+	}
+	if r.MaxLen < 0 {
+		return errors.New("max_len must not be negative")
+	}
+
+	tmpl, err := template.New("stream").Parse(r.Stream)
+	if err != nil {
+		return fmt.Errorf("parsing stream template failed: %w", err)
+	}
+	r.stream = tmpl
+
+	return nil
+}
+
+func (r *RedisStreams) SetSerializer(serializer telegraf.Serializer) {
+	r.serializer = serializer
+}
+
+func (r *RedisStreams) Connect() error {
+	username, err := r.Username.Get()
+	if err != nil {
+		return fmt.Errorf("getting username failed: %w", err)
+	}
+	defer username.Destroy()
+
+	password, err := r.Password.Get()
+	if err != nil {
+		return fmt.Errorf("getting password failed: %w", err)
+	}
+	defer password.Destroy()
+
+	tlsCfg, err := r.ClientConfig.TLSConfig()
+	if err != nil {
+		return fmt.Errorf("creating TLS config failed: %w", err)
+	}
+
+	r.client = redis.NewClient(&redis.Options{
+		Addr:         r.Address,
+		Username:     username.String(),
+		Password:     password.String(),
+		DB:           r.Database,
+		TLSConfig:    tlsCfg,
+		DialTimeout:  time.Duration(r.Timeout),
+		ReadTimeout:  time.Duration(r.Timeout),
+		WriteTimeout: time.Duration(r.Timeout),
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(r.Timeout))
+	defer cancel()
+	if err := r.client.Ping(ctx).Err(); err != nil {
+		return &internal.StartupError{
+			Err:   fmt.Errorf("connecting to %q failed: %w", r.Address, err),
+			Retry: true,
+		}
+	}
+	return nil
+}
+
+func (r *RedisStreams) Close() error {
+	if r.client == nil {
+		return nil
+	}
+	return r.client.Close()
+}
+
+func (r *RedisStreams) Write(metrics []telegraf.Metric) error {
+	writeErr := &internal.PartialWriteError{
+		MetricsAccept: make([]int, 0, len(metrics)),
+	}
+
+	// Add all entries in a single round-trip, the metrics failing locally are
+	// rejected as they will never succeed
+	indices := make([]int, 0, len(metrics))
+	var buf strings.Builder
+	pipe := r.client.Pipeline()
+	for i, m := range metrics {
+		buf.Reset()
+		if err := r.stream.Execute(&buf, m.(telegraf.TemplateMetric)); err != nil {
+			r.Log.Errorf("Executing stream template for metric %v failed: %v", m, err)
+			writeErr.Reject(i, err)
+			continue
+		}
+		stream := buf.String()
+		if stream == "" {
+			r.Log.Errorf("Empty stream key for metric %v", m)
+			writeErr.Reject(i, errors.New("empty stream key"))
+			continue
+		}
+
+		payload, err := r.serializer.Serialize(m)
+		if err != nil {
+			r.Log.Errorf("Serializing metric %v failed: %v", m, err)
+			writeErr.Reject(i, fmt.Errorf("%w: %w", internal.ErrSerialization, err))
+			continue
+		}
+
+		pipe.XAdd(context.Background(), &redis.XAddArgs{
+			Stream: stream,
+			MaxLen: r.MaxLen,
+			Approx: r.MaxLen > 0 && r.MaxLenApproximate,
+			Values: []interface{}{r.Field, payload},
+		})
+		indices = append(indices, i)
+	}
+
+	if len(indices) > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(r.Timeout))
+		defer cancel()
+
+		// The returned error is the first failing command, so check each
+		// command individually to find the added entries
+		cmds, err := pipe.Exec(ctx)
+		if err != nil {
+			writeErr.Err = fmt.Errorf("adding entries failed: %w", err)
+		}
+		for i, cmd := range cmds {
+			if cmd.Err() == nil {
+				writeErr.MetricsAccept = append(writeErr.MetricsAccept, indices[i])
+			}
+		}
+	}
+
+	if writeErr.Err == nil && len(writeErr.MetricsReject) == 0 {
+		return nil
+	}
+	if writeErr.Err == nil {
+		writeErr.Err = errors.Join(writeErr.MetricsRejectErrors...)
+	}
+	return writeErr
+}
+
+func init() {
+	outputs.Add("redis_streams", func() telegraf.Output {
+		return &RedisStreams{
+			Stream:            "telegraf",
+			Field:             "data",
+			MaxLen:            100000,
+			MaxLenApproximate: true,
+			Timeout:           config.Duration(10 * time.Second),
+		}
+	})
+}
@@ -0,0 +1,151 @@
+package redis_streams
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/docker/go-connections/nat"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go/wait"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/serializers/influx"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInit(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *RedisStreams
+		expected string
+	}{
+		{
+			name:     "missing address",
+			plugin:   &RedisStreams{Stream: "telegraf", Field: "data"},
+			expected: "address required",
+		},
+		{
+			name:     "missing stream",
+			plugin:   &RedisStreams{Address: "localhost:6379", Field: "data"},
+			expected: "stream required",
+		},
+		{
+			name:     "negative max length",
+			plugin:   &RedisStreams{Address: "localhost:6379", Stream: "telegraf", Field: "data", MaxLen: -1},
+			expected: "max_len must not be negative",
+		},
+		{
+			name:     "invalid template",
+			plugin:   &RedisStreams{Address: "localhost:6379", Stream: "{{ .Tag ", Field: "data"},
+			expected: "parsing stream template failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestWriteIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	servicePort := "6379"
+	container := testutil.Container{
+		Image:        "redis:alpine",
+		ExposedPorts: []string{servicePort},
+		WaitingFor:   wait.ForListeningPort(nat.Port(servicePort)),
+	}
+	require.NoError(t, container.Start(), "failed to start container")
+	defer container.Terminate()
+	address := fmt.Sprintf("%s:%s", container.Address, container.Ports[servicePort])
+
+	serializer := &influx.Serializer{}
+	require.NoError(t, serializer.Init())
+
+	plugin := &RedisStreams{
+		Address: address,
+		Stream:  `telegraf:{{ .Tag "host" }}`,
+		Field:   "data",
+		MaxLen:  2,
+		Timeout: config.Duration(5 * time.Second),
+		Log:     &testutil.Logger{},
+	}
+	plugin.SetSerializer(serializer)
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	metrics := []telegraf.Metric{
+		metric.New("cpu", map[string]string{"host": "a"}, map[string]interface{}{"value": 1.0}, time.Unix(1, 0)),
+		metric.New("cpu", map[string]string{"host": "a"}, map[string]interface{}{"value": 2.0}, time.Unix(2, 0)),
+		metric.New("cpu", map[string]string{"host": "a"}, map[string]interface{}{"value": 3.0}, time.Unix(3, 0)),
+		metric.New("cpu", map[string]string{"host": "b"}, map[string]interface{}{"value": 4.0}, time.Unix(4, 0)),
+	}
+	require.NoError(t, plugin.Write(metrics))
+
+	client := redis.NewClient(&redis.Options{Addr: address})
+	defer client.Close()
+
+	// The stream of host "a" must be capped to the last two entries
+	entries, err := client.XRange(t.Context(), "telegraf:a", "-", "+").Result()
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, "cpu,host=a value=2 2000000000\n", entries[0].Values["data"])
+	require.Equal(t, "cpu,host=a value=3 3000000000\n", entries[1].Values["data"])
+
+	entries, err = client.XRange(t.Context(), "telegraf:b", "-", "+").Result()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "cpu,host=b value=4 4000000000\n", entries[0].Values["data"])
+}
+
+func TestWriteRejectEmptyStreamIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	servicePort := "6379"
+	container := testutil.Container{
+		Image:        "redis:alpine",
+		ExposedPorts: []string{servicePort},
+		WaitingFor:   wait.ForListeningPort(nat.Port(servicePort)),
+	}
+	require.NoError(t, container.Start(), "failed to start container")
+	defer container.Terminate()
+
+	serializer := &influx.Serializer{}
+	require.NoError(t, serializer.Init())
+
+	plugin := &RedisStreams{
+		Address: fmt.Sprintf("%s:%s", container.Address, container.Ports[servicePort]),
+		Stream:  `{{ .Tag "host" }}`,
+		Field:   "data",
+		Timeout: config.Duration(5 * time.Second),
+		Log:     &testutil.Logger{},
+	}
+	plugin.SetSerializer(serializer)
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	metrics := []telegraf.Metric{
+		metric.New("cpu", map[string]string{"host": "a"}, map[string]interface{}{"value": 1.0}, time.Unix(1, 0)),
+		metric.New("cpu", map[string]string{}, map[string]interface{}{"value": 2.0}, time.Unix(2, 0)),
+	}
+
+	// Metrics resulting in an empty stream key are rejected
+	err := plugin.Write(metrics)
+	var writeErr *internal.PartialWriteError
+	require.ErrorAs(t, err, &writeErr)
+	require.Equal(t, []int{0}, writeErr.MetricsAccept)
+	require.Equal(t, []int{1}, writeErr.MetricsReject)
+}
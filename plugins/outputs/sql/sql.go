@@ -5,6 +5,7 @@ import (
 	"cmp"
 	gosql "database/sql"
 	_ "embed"
+	"errors"
 	"fmt"
 	"iter"
 	"net/url"
@@ -13,15 +14,17 @@ import (
 	"strings"
 	"time"
 
-	_ "github.com/ClickHouse/clickhouse-go/v2"              // clickhouse
-	_ "github.com/go-sql-driver/mysql"                      // mysql
+	"github.com/ClickHouse/clickhouse-go/v2"                // clickhouse
+	"github.com/go-sql-driver/mysql"                        // mysql
+	"github.com/jackc/pgconn"                               // postgres errors
 	_ "github.com/jackc/pgx/v4/stdlib"                      // pgx (postgres)
 	_ "github.com/microsoft/go-mssqldb"                     // mssql (sql server)
 	_ "github.com/microsoft/go-mssqldb/integratedauth/krb5" // integrated auth for mssql
-	_ "github.com/snowflakedb/gosnowflake"                  // snowflake
+	"github.com/snowflakedb/gosnowflake"                    // snowflake
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal"
 	"github.com/influxdata/telegraf/plugins/outputs"
 )
 
@@ -429,14 +432,23 @@ func (p *SQL) sendBatch(sql string, values [][]interface{}) error {
 }
 
 func (p *SQL) Write(metrics []telegraf.Metric) error {
+	writeErr := &internal.PartialWriteError{
+		MetricsAccept: make([]int, 0, len(metrics)),
+	}
+
 	batchedQueries := make(map[string][][]interface{})
+	batchedIndices := make(map[string][]int)
 
-	for _, metric := range metrics {
+	for i, metric := range metrics {
 		tablename := metric.Name()
 		// create table if needed
 		if _, found := p.tables[tablename]; !found && !p.tableExists(tablename) {
 			if err := p.createTable(metric); err != nil {
-				return err
+				if !isPermanentError(err) {
+					return writeErr.Result(err)
+				}
+				writeErr.Reject(i, err)
+				continue
 			}
 		}
 		cacheKey, columns, values := p.processMetric(metric)
@@ -447,31 +459,122 @@ func (p *SQL) Write(metrics []telegraf.Metric) error {
 		}
 		// Modifying the table schema is opt-in
 		if p.TableUpdateTemplate != "" {
-			for i := range len(columns) {
-				if err := p.createColumn(tablename, columns[i], p.deriveDatatype(values[i])); err != nil {
-					return err
+			var err error
+			for j := range len(columns) {
+				if err = p.createColumn(tablename, columns[j], p.deriveDatatype(values[j])); err != nil {
+					break
+				}
+			}
+			if err != nil {
+				if !isPermanentError(err) {
+					return writeErr.Result(err)
 				}
+				writeErr.Reject(i, err)
+				continue
 			}
 		}
 		// Using BatchTx is opt-in
 		if p.BatchTx {
 			batchedQueries[sql] = append(batchedQueries[sql], values)
-		} else {
-			if err := p.sendIndividual(sql, values); err != nil {
-				return err
+			batchedIndices[sql] = append(batchedIndices[sql], i)
+			continue
+		}
+		if err := p.sendIndividual(sql, values); err != nil {
+			if !isPermanentError(err) {
+				return writeErr.Result(err)
 			}
+			writeErr.Reject(i, err)
+			continue
 		}
+		writeErr.MetricsAccept = append(writeErr.MetricsAccept, i)
 	}
 
-	if p.BatchTx {
-		for query, queryParams := range batchedQueries {
-			if err := p.sendBatch(query, queryParams); err != nil {
-				return fmt.Errorf("failed to send a batched tx: %w", err)
+	for query, queryParams := range batchedQueries {
+		indices := batchedIndices[query]
+		err := p.sendBatch(query, queryParams)
+		if err == nil {
+			writeErr.MetricsAccept = append(writeErr.MetricsAccept, indices...)
+			continue
+		}
+		if !isPermanentError(err) {
+			return writeErr.Result(fmt.Errorf("failed to send a batched tx: %w", err))
+		}
+
+		// The transaction was rolled back due to a permanent error, so send
+		// the rows individually to isolate the offending metrics
+		p.Log.Debugf("Sending batched tx failed, falling back to individual inserts: %v", err)
+		for j, values := range queryParams {
+			if err := p.sendIndividual(query, values); err != nil {
+				if !isPermanentError(err) {
+					return writeErr.Result(err)
+				}
+				writeErr.Reject(indices[j], err)
+				continue
 			}
+			writeErr.MetricsAccept = append(writeErr.MetricsAccept, indices[j])
 		}
 	}
 
-	return nil
+	return writeErr.Result(nil)
+}
+
+// Error numbers of MySQL denoting invalid data, e.g. NULL values, values out
+// of range or duplicate keys
+var mysqlDataErrors = []uint16{1048, 1062, 1264, 1265, 1292, 1366, 1406, 1451, 1452, 3819}
+
+// Error numbers of SQL Server denoting invalid data, e.g. NULL values,
+// constraint violations, truncation or conversion failures
+var mssqlDataErrors = []int32{220, 245, 515, 547, 2601, 2627, 2628, 8114, 8115, 8152}
+
+// Exception codes of ClickHouse denoting invalid data, e.g. values failing to
+// parse or convert to the column type or violating constraints
+var clickhouseDataErrors = []int32{6, 26, 27, 38, 41, 53, 69, 70, 72, 321, 349, 469}
+
+// isPermanentError checks if the error is caused by the data of the metric,
+// e.g. constraint violations or values not matching the column type, so
+// writing the metric will never succeed. All other errors including temporary
+// server conditions such as deadlocks or lock timeouts are retried.
+func isPermanentError(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// Class 22 are data exceptions, class 23 integrity constraint
+		// violations and 42804 denotes a datatype mismatch
+		return strings.HasPrefix(pgErr.Code, "22") || strings.HasPrefix(pgErr.Code, "23") || pgErr.Code == "42804"
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return slices.Contains(mysqlDataErrors, mysqlErr.Number)
+	}
+
+	var mssqlErr interface{ SQLErrorNumber() int32 }
+	if errors.As(err, &mssqlErr) {
+		return slices.Contains(mssqlDataErrors, mssqlErr.SQLErrorNumber())
+	}
+
+	var snowflakeErr *gosnowflake.SnowflakeError
+	if errors.As(err, &snowflakeErr) {
+		return strings.HasPrefix(snowflakeErr.SQLState, "22") || strings.HasPrefix(snowflakeErr.SQLState, "23")
+	}
+
+	var clickhouseErr *clickhouse.Exception
+	if errors.As(err, &clickhouseErr) {
+		return slices.Contains(clickhouseDataErrors, clickhouseErr.Code)
+	}
+
+	// The sqlite driver is only available on some platforms, so check the
+	// error code via the interface of the driver's error. The primary result
+	// codes SQLITE_TOOBIG, SQLITE_CONSTRAINT and SQLITE_MISMATCH denote
+	// invalid data.
+	var sqliteErr interface{ Code() int }
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.Code() & 0xff {
+		case 18, 19, 20:
+			return true
+		}
+	}
+
+	return false
 }
 
 // Convert a DSN possibly using v1 parameters to clickhouse-go v2 format
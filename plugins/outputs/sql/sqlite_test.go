@@ -4,6 +4,7 @@ package sql
 
 import (
 	gosql "database/sql"
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
@@ -11,7 +12,10 @@ import (
 
 	"github.com/stretchr/testify/require"
 
+	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/metric"
 	"github.com/influxdata/telegraf/testutil"
 )
 
@@ -300,3 +304,114 @@ func TestSqliteSendBatch(t *testing.T) {
 	require.Equal(t, "string2", k)
 	require.False(t, rows4.Next())
 }
+
+func TestSqlitePartialWrite(t *testing.T) {
+	for _, batch := range []bool{false, true} {
+		name := "individual"
+		if batch {
+			name = "batch"
+		}
+		t.Run(name, func(t *testing.T) {
+			address := filepath.Join(t.TempDir(), "db")
+
+			// Create a table with a constraint the second metric will violate
+			p := &SQL{
+				Driver:            "sqlite",
+				DataSourceName:    config.NewSecret([]byte(address)),
+				Convert:           defaultConvert,
+				TimestampColumn:   "timestamp",
+				InitSQL:           `CREATE TABLE "test"("timestamp" TIMESTAMP,"value" INT CHECK("value" < 10))`,
+				ConnectionMaxIdle: 2,
+				BatchTx:           batch,
+				Log:               testutil.Logger{},
+			}
+			require.NoError(t, p.Init())
+			require.NoError(t, p.Connect())
+			defer p.Close()
+
+			metrics := []telegraf.Metric{
+				metric.New("test", map[string]string{}, map[string]interface{}{"value": 1}, time.Unix(0, 0)),
+				metric.New("test", map[string]string{}, map[string]interface{}{"value": 100}, time.Unix(1, 0)),
+				metric.New("test", map[string]string{}, map[string]interface{}{"value": 2}, time.Unix(2, 0)),
+			}
+
+			err := p.Write(metrics)
+			require.ErrorContains(t, err, "dropped 1 metrics")
+			var writeErr *internal.PartialWriteError
+			require.True(t, errors.As(err, &writeErr))
+			require.ElementsMatch(t, []int{0, 2}, writeErr.MetricsAccept)
+			require.Equal(t, []int{1}, writeErr.MetricsReject)
+			require.Len(t, writeErr.MetricsRejectErrors, 1)
+
+			// Check the accepted metrics are in the database
+			db, err := gosql.Open("sqlite", address)
+			require.NoError(t, err)
+			defer db.Close()
+
+			var count int
+			require.NoError(t, db.QueryRow(`select count(*) from "test"`).Scan(&count))
+			require.Equal(t, 2, count)
+		})
+	}
+}
+
+func TestSqliteTemporaryError(t *testing.T) {
+	for _, batch := range []bool{false, true} {
+		name := "individual"
+		if batch {
+			name = "batch"
+		}
+		t.Run(name, func(t *testing.T) {
+			address := filepath.Join(t.TempDir(), "db")
+
+			p := &SQL{
+				Driver:            "sqlite",
+				DataSourceName:    config.NewSecret([]byte(address)),
+				Convert:           defaultConvert,
+				TimestampColumn:   "timestamp",
+				InitSQL:           `CREATE TABLE "test"("timestamp" TIMESTAMP,"value" INT)`,
+				ConnectionMaxIdle: 2,
+				BatchTx:           batch,
+				Log:               testutil.Logger{},
+			}
+			require.NoError(t, p.Init())
+			require.NoError(t, p.Connect())
+			defer p.Close()
+
+			// Mark the table as existing to not fail on checking the table
+			p.tables["test"] = make(map[string]bool)
+
+			// Lock the database so writing fails with SQLITE_BUSY
+			db, err := gosql.Open("sqlite", address)
+			require.NoError(t, err)
+			defer db.Close()
+			conn, err := db.Conn(t.Context())
+			require.NoError(t, err)
+			defer conn.Close()
+			_, err = conn.ExecContext(t.Context(), "BEGIN EXCLUSIVE")
+			require.NoError(t, err)
+
+			metrics := []telegraf.Metric{
+				metric.New("test", map[string]string{}, map[string]interface{}{"value": 1}, time.Unix(1, 0)),
+				metric.New("test", map[string]string{}, map[string]interface{}{"value": 2}, time.Unix(2, 0)),
+			}
+
+			// All metrics must be kept for retrying
+			err = p.Write(metrics)
+			require.ErrorContains(t, err, "database is locked")
+			var writeErr *internal.PartialWriteError
+			require.ErrorAs(t, err, &writeErr)
+			require.Empty(t, writeErr.MetricsAccept)
+			require.Empty(t, writeErr.MetricsReject)
+
+			// Writing succeeds after releasing the lock
+			_, err = conn.ExecContext(t.Context(), "COMMIT")
+			require.NoError(t, err)
+			require.NoError(t, p.Write(metrics))
+
+			var count int
+			require.NoError(t, db.QueryRow(`select count(*) from "test"`).Scan(&count))
+			require.Equal(t, 2, count)
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package sqs
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	sns_types "github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqs_types "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// entry is a single message of a batch request
+type entry struct {
+	id              string
+	body            string
+	groupID         string
+	deduplicationID string
+}
+
+// failure describes an entry of a batch request that was not sent
+type failure struct {
+	id          string
+	senderFault bool
+	err         error
+}
+
+// client sends a batch of up to ten entries and returns the failed entries
+type client interface {
+	send(ctx context.Context, entries []entry) ([]failure, error)
+}
+
+type sqsAPI interface {
+	SendMessageBatch(context.Context, *sqs.SendMessageBatchInput, ...func(*sqs.Options)) (*sqs.SendMessageBatchOutput, error)
+}
+
+type snsAPI interface {
+	PublishBatch(context.Context, *sns.PublishBatchInput, ...func(*sns.Options)) (*sns.PublishBatchOutput, error)
+}
+
+type sqsClient struct {
+	api      sqsAPI
+	queueURL string
+}
+
+func (c *sqsClient) send(ctx context.Context, entries []entry) ([]failure, error) {
+	request := make([]sqs_types.SendMessageBatchRequestEntry, 0, len(entries))
+	for _, e := range entries {
+		request = append(request, sqs_types.SendMessageBatchRequestEntry{
+			Id:                     aws.String(e.id),
+			MessageBody:            aws.String(e.body),
+			MessageGroupId:         optional(e.groupID),
+			MessageDeduplicationId: optional(e.deduplicationID),
+		})
+	}
+
+	resp, err := c.api.SendMessageBatch(ctx, &sqs.SendMessageBatchInput{
+		QueueUrl: aws.String(c.queueURL),
+		Entries:  request,
+	})
+	if err != nil {
+		return nil, err
+	}
+	failures := make([]failure, 0, len(resp.Failed))
+	for _, f := range resp.Failed {
+		failures = append(failures, failure{
+			id:          aws.ToString(f.Id),
+			senderFault: f.SenderFault,
+			err:         batchError(f.Code, f.Message),
+		})
+	}
+	return failures, nil
+}
+
+type snsClient struct {
+	api      snsAPI
+	topicARN string
+}
+
+func (c *snsClient) send(ctx context.Context, entries []entry) ([]failure, error) {
+	request := make([]sns_types.PublishBatchRequestEntry, 0, len(entries))
+	for _, e := range entries {
+		request = append(request, sns_types.PublishBatchRequestEntry{
+			Id:                     aws.String(e.id),
+			Message:                aws.String(e.body),
+			MessageGroupId:         optional(e.groupID),
+			MessageDeduplicationId: optional(e.deduplicationID),
+		})
+	}
+
+	resp, err := c.api.PublishBatch(ctx, &sns.PublishBatchInput{
+		TopicArn:                   aws.String(c.topicARN),
+		PublishBatchRequestEntries: request,
+	})
+	if err != nil {
+		return nil, err
+	}
+	failures := make([]failure, 0, len(resp.Failed))
+	for _, f := range resp.Failed {
+		failures = append(failures, failure{
+			id:          aws.ToString(f.Id),
+			senderFault: f.SenderFault,
+			err:         batchError(f.Code, f.Message),
+		})
+	}
+	return failures, nil
+}
+
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return aws.String(s)
+}
+
+func batchError(code, message *string) error {
+	if message == nil {
+		return errors.New(aws.ToString(code))
+	}
+	return errors.New(aws.ToString(code) + ": " + aws.ToString(message))
+}
@@ -0,0 +1,295 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package sqs
+
+import (
+	"context"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal"
+	common_aws "github.com/influxdata/telegraf/plugins/common/aws"
+	"github.com/influxdata/telegraf/plugins/outputs"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+// Limit set by AWS for SendMessageBatch and PublishBatch requests
+const maxEntriesPerRequest = 10
+
+type SQS struct {
+	QueueURL       string          `toml:"queue_url"`
+	TopicARN       string          `toml:"topic_arn"`
+	MessageGroupID string          `toml:"message_group_id"`
+	MaxMessageSize config.Size     `toml:"max_message_size"`
+	UseBatchFormat bool            `toml:"use_batch_format"`
+	Timeout        config.Duration `toml:"timeout"`
+	Log            telegraf.Logger `toml:"-"`
+	common_aws.CredentialConfig
+
+	client     client
+	serializer telegraf.Serializer
+	groupID    *template.Template
+	fifo       bool
+}
+
+// message is the payload of a single SQS message or SNS notification
+// together with the indices of the contained metrics
+type message struct {
+	body    []byte
+	groupID string
+	indices []int
+}
+
+func (*SQS) SampleConfig() string {
+	return sampleConfig
+}
+
+func (s *SQS) Init() error {
+	switch {
+	case s.QueueURL == "" && s.TopicARN == "":
+		return errors.New("either queue_url or topic_arn required")
+	case s.QueueURL != "" && s.TopicARN != "":
+		return errors.New("queue_url and topic_arn are mutually exclusive")
+	}
+	if s.MaxMessageSize <= 0 {
+		return errors.New("max_message_size must be positive")
+	}
+
+	// Ordering and deduplication only apply to FIFO queues and topics which
+	// are identified by their name suffix
+	s.fifo = strings.HasSuffix(s.QueueURL, ".fifo") || strings.HasSuffix(s.TopicARN, ".fifo")
+	if s.fifo {
+		if s.MessageGroupID == "" {
+			return errors.New("message_group_id required for FIFO queues and topics")
+		}
+		tmpl, err := template.New("message_group_id").Parse(s.MessageGroupID)
+		if err != nil {
+			return fmt.Errorf("parsing message group ID template failed: %w", err)
+		}
+		s.groupID = tmpl
+	}
+
+	return nil
+}
+
+func (s *SQS) SetSerializer(serializer telegraf.Serializer) {
+	s.serializer = serializer
+}
+
+func (s *SQS) Connect() error {
+	cfg, err := s.CredentialConfig.Credentials()
+	if err != nil {
+		return err
+	}
+
+	if s.QueueURL != "" {
+		s.client = &sqsClient{
+			api: sqs.NewFromConfig(cfg, func(o *sqs.Options) {
+				if s.EndpointURL != "" {
+					o.BaseEndpoint = &s.EndpointURL
+				}
+			}),
+			queueURL: s.QueueURL,
+		}
+		return nil
+	}
+
+	s.client = &snsClient{
+		api: sns.NewFromConfig(cfg, func(o *sns.Options) {
+			if s.EndpointURL != "" {
+				o.BaseEndpoint = &s.EndpointURL
+			}
+		}),
+		topicARN: s.TopicARN,
+	}
+	return nil
+}
+
+func (*SQS) Close() error {
+	return nil
+}
+
+func (s *SQS) Write(metrics []telegraf.Metric) error {
+	writeErr := &internal.PartialWriteError{
+		MetricsAccept: make([]int, 0, len(metrics)),
+	}
+
+	// Group the metrics by message group keeping the order of the metrics
+	// within each group. Non-FIFO targets use a single, empty group.
+	var order []string
+	groups := make(map[string][]int)
+	var buf strings.Builder
+	for i, m := range metrics {
+		var group string
+		if s.fifo {
+			buf.Reset()
+			if err := s.groupID.Execute(&buf, m.(telegraf.TemplateMetric)); err != nil {
+				s.Log.Errorf("Executing message group ID template for metric %v failed: %v", m, err)
+				writeErr.Reject(i, err)
+				continue
+			}
+			if group = buf.String(); group == "" {
+				s.Log.Errorf("Empty message group ID for metric %v", m)
+				writeErr.Reject(i, errors.New("empty message group ID"))
+				continue
+			}
+		}
+		if _, found := groups[group]; !found {
+			order = append(order, group)
+		}
+		groups[group] = append(groups[group], i)
+	}
+
+	var messages []message
+	for _, group := range order {
+		if s.UseBatchFormat {
+			messages = append(messages, s.packBatch(metrics, groups[group], group, writeErr.Reject)...)
+		} else {
+			messages = append(messages, s.pack(metrics, groups[group], group, writeErr.Reject)...)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(s.Timeout))
+	defer cancel()
+
+	// Send the messages in requests limited by the number of entries and the
+	// total payload size. For FIFO targets, messages of a group with a failed
+	// message are kept for the next write to preserve the order.
+	limit := int(s.MaxMessageSize)
+	blocked := make(map[string]bool)
+	for i := 0; i < len(messages); {
+		batch := make([]message, 0, maxEntriesPerRequest)
+		var size int
+		for ; i < len(messages) && len(batch) < maxEntriesPerRequest; i++ {
+			msg := messages[i]
+			if blocked[msg.groupID] {
+				continue
+			}
+			if size+len(msg.body) > limit {
+				break
+			}
+			size += len(msg.body)
+			batch = append(batch, msg)
+		}
+		if len(batch) == 0 {
+			continue
+		}
+
+		entries := make([]entry, 0, len(batch))
+		for j, msg := range batch {
+			e := entry{id: strconv.Itoa(j), body: string(msg.body)}
+			if s.fifo {
+				sum := sha256.Sum256(msg.body)
+				e.groupID = msg.groupID
+				e.deduplicationID = hex.EncodeToString(sum[:])
+			}
+			entries = append(entries, e)
+		}
+
+		failures, err := s.client.send(ctx, entries)
+		if err != nil {
+			writeErr.Err = fmt.Errorf("sending messages failed: %w", err)
+			break
+		}
+		failed := make(map[string]failure, len(failures))
+		for _, f := range failures {
+			failed[f.id] = f
+		}
+		for j, msg := range batch {
+			f, found := failed[strconv.Itoa(j)]
+			switch {
+			case !found:
+				writeErr.MetricsAccept = append(writeErr.MetricsAccept, msg.indices...)
+			case f.senderFault:
+				s.Log.Errorf("Message with %d metric(s) rejected: %v", len(msg.indices), f.err)
+				for _, idx := range msg.indices {
+					writeErr.Reject(idx, fmt.Errorf("message rejected: %w", f.err))
+				}
+			default:
+				if writeErr.Err == nil {
+					writeErr.Err = fmt.Errorf("sending message failed: %w", f.err)
+				}
+				if s.fifo {
+					blocked[msg.groupID] = true
+				}
+			}
+		}
+	}
+
+	return writeErr.Result(writeErr.Err)
+}
+
+// pack creates a message per metric
+func (s *SQS) pack(metrics []telegraf.Metric, indices []int, group string, reject func(int, error)) []message {
+	messages := make([]message, 0, len(indices))
+	for _, idx := range indices {
+		payload, err := s.serializer.Serialize(metrics[idx])
+		if err != nil {
+			s.Log.Errorf("Serializing metric %v failed: %v", metrics[idx], err)
+			reject(idx, fmt.Errorf("%w: %w", internal.ErrSerialization, err))
+			continue
+		}
+		if len(payload) > int(s.MaxMessageSize) {
+			s.Log.Errorf("Metric %v of %d bytes exceeds the maximum message size", metrics[idx], len(payload))
+			reject(idx, internal.ErrSizeLimitReached)
+			continue
+		}
+		messages = append(messages, message{body: payload, groupID: group, indices: []int{idx}})
+	}
+	return messages
+}
+
+// packBatch serializes the metrics into a single message and splits the
+// metrics in halves if the message exceeds the maximum size
+func (s *SQS) packBatch(metrics []telegraf.Metric, indices []int, group string, reject func(int, error)) []message {
+	batch := make([]telegraf.Metric, 0, len(indices))
+	for _, idx := range indices {
+		batch = append(batch, metrics[idx])
+	}
+
+	payload, err := s.serializer.SerializeBatch(batch)
+	if err == nil && len(payload) <= int(s.MaxMessageSize) {
+		return []message{{body: payload, groupID: group, indices: indices}}
+	}
+
+	// Serialization errors in a batch might be caused by a single metric,
+	// so try to narrow the error down to the offending metrics
+	if len(indices) == 1 {
+		if err != nil {
+			s.Log.Errorf("Serializing metric %v failed: %v", metrics[indices[0]], err)
+			reject(indices[0], fmt.Errorf("%w: %w", internal.ErrSerialization, err))
+		} else {
+			s.Log.Errorf("Metric %v of %d bytes exceeds the maximum message size", metrics[indices[0]], len(payload))
+			reject(indices[0], internal.ErrSizeLimitReached)
+		}
+		return nil
+	}
+	mid := len(indices) / 2
+	return append(
+		s.packBatch(metrics, indices[:mid], group, reject),
+		s.packBatch(metrics, indices[mid:], group, reject)...,
+	)
+}
+
+func init() {
+	outputs.Add("sqs", func() telegraf.Output {
+		return &SQS{
+			MessageGroupID: "{{.Name}}",
+			MaxMessageSize: config.Size(256 * 1024),
+			Timeout:        config.Duration(10 * time.Second),
+		}
+	})
+}
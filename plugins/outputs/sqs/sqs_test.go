@@ -0,0 +1,453 @@
+package sqs
+
+import (
+	"context"
+	"crypto/md5" //nolint:gosec // required by the SQS protocol
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	sns_types "github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/metric"
+	common_aws "github.com/influxdata/telegraf/plugins/common/aws"
+	"github.com/influxdata/telegraf/plugins/serializers/influx"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInit(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *SQS
+		expected string
+	}{
+		{
+			name:     "missing target",
+			plugin:   &SQS{MaxMessageSize: 1024},
+			expected: "either queue_url or topic_arn required",
+		},
+		{
+			name: "queue and topic",
+			plugin: &SQS{
+				QueueURL:       "http://localhost/000000000000/telegraf",
+				TopicARN:       "arn:aws:sns:us-east-1:000000000000:telegraf",
+				MaxMessageSize: 1024,
+			},
+			expected: "queue_url and topic_arn are mutually exclusive",
+		},
+		{
+			name:     "zero message size",
+			plugin:   &SQS{QueueURL: "http://localhost/000000000000/telegraf"},
+			expected: "max_message_size must be positive",
+		},
+		{
+			name:     "missing group ID",
+			plugin:   &SQS{QueueURL: "http://localhost/000000000000/telegraf.fifo", MaxMessageSize: 1024},
+			expected: "message_group_id required",
+		},
+		{
+			name: "invalid template",
+			plugin: &SQS{
+				QueueURL:       "http://localhost/000000000000/telegraf.fifo",
+				MessageGroupID: "{{ .Tag ",
+				MaxMessageSize: 1024,
+			},
+			expected: "parsing message group ID template failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	server := newStubServer(t)
+	plugin := newTestPlugin(t, server.URL, "telegraf", false)
+
+	metrics := make([]telegraf.Metric, 0, 12)
+	for i := range 12 {
+		metrics = append(metrics, metric.New(
+			"test",
+			map[string]string{},
+			map[string]interface{}{"value": i},
+			time.Unix(0, 0),
+		))
+	}
+	require.NoError(t, plugin.Write(metrics))
+
+	// Messages are sent in requests of at most ten entries
+	require.Equal(t, []int{10, 2}, server.requestSizes())
+	entries := server.received()
+	require.Len(t, entries, 12)
+	for i, e := range entries {
+		require.Equal(t, "test value="+strconv.Itoa(i)+"i 0\n", e.MessageBody)
+		require.Empty(t, e.MessageGroupId)
+		require.Empty(t, e.MessageDeduplicationId)
+	}
+}
+
+func TestWriteFIFO(t *testing.T) {
+	server := newStubServer(t)
+	plugin := &SQS{
+		QueueURL:       server.URL + "/000000000000/telegraf.fifo",
+		MessageGroupID: `{{.Tag "host"}}`,
+		MaxMessageSize: config.Size(256 * 1024),
+		Timeout:        config.Duration(5 * time.Second),
+		CredentialConfig: common_aws.CredentialConfig{
+			Region:      "us-east-1",
+			AccessKey:   "dummy",
+			SecretKey:   "dummy",
+			EndpointURL: server.URL,
+		},
+		Log: testutil.Logger{},
+	}
+	plugin.SetSerializer(newSerializer(t))
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	metrics := []telegraf.Metric{
+		metric.New("test", map[string]string{"host": "a"}, map[string]interface{}{"value": 1}, time.Unix(0, 0)),
+		metric.New("test", map[string]string{"host": "b"}, map[string]interface{}{"value": 2}, time.Unix(0, 0)),
+		metric.New("test", map[string]string{"host": "a"}, map[string]interface{}{"value": 3}, time.Unix(0, 0)),
+		metric.New("test", map[string]string{}, map[string]interface{}{"value": 4}, time.Unix(0, 0)),
+	}
+	err := plugin.Write(metrics)
+
+	// The metric without group ID is rejected
+	var writeErr *internal.PartialWriteError
+	require.ErrorAs(t, err, &writeErr)
+	require.ElementsMatch(t, []int{0, 1, 2}, writeErr.MetricsAccept)
+	require.Equal(t, []int{3}, writeErr.MetricsReject)
+
+	entries := server.received()
+	require.Len(t, entries, 3)
+	groups := make([]string, 0, len(entries))
+	bodies := make([]string, 0, len(entries))
+	for _, e := range entries {
+		groups = append(groups, e.MessageGroupId)
+		bodies = append(bodies, e.MessageBody)
+		require.Len(t, e.MessageDeduplicationId, 64)
+	}
+	require.Equal(t, []string{"a", "a", "b"}, groups)
+	require.Equal(t, []string{
+		"test,host=a value=1i 0\n",
+		"test,host=a value=3i 0\n",
+		"test,host=b value=2i 0\n",
+	}, bodies)
+}
+
+func TestWriteBatchFormatSplit(t *testing.T) {
+	server := newStubServer(t)
+	plugin := newTestPlugin(t, server.URL, "telegraf", true)
+	plugin.MaxMessageSize = 64
+
+	metrics := []telegraf.Metric{
+		metric.New("test", map[string]string{}, map[string]interface{}{"value": 1}, time.Unix(0, 0)),
+		metric.New("test", map[string]string{}, map[string]interface{}{"value": 2}, time.Unix(0, 0)),
+		metric.New("test", map[string]string{}, map[string]interface{}{"value": 3}, time.Unix(0, 0)),
+		metric.New("test", map[string]string{}, map[string]interface{}{"value": strings.Repeat("x", 64)}, time.Unix(0, 0)),
+		metric.New("test", map[string]string{}, map[string]interface{}{"value": 5}, time.Unix(0, 0)),
+	}
+	err := plugin.Write(metrics)
+
+	// The oversized metric is rejected while the others are split into
+	// messages within the size limit
+	var writeErr *internal.PartialWriteError
+	require.ErrorAs(t, err, &writeErr)
+	require.ElementsMatch(t, []int{0, 1, 2, 4}, writeErr.MetricsAccept)
+	require.Equal(t, []int{3}, writeErr.MetricsReject)
+	require.ErrorIs(t, writeErr.MetricsRejectErrors[0], internal.ErrSizeLimitReached)
+
+	var payload string
+	for _, e := range server.received() {
+		require.LessOrEqual(t, len(e.MessageBody), 64)
+		payload += e.MessageBody
+	}
+	require.Equal(t, "test value=1i 0\ntest value=2i 0\ntest value=3i 0\ntest value=5i 0\n", payload)
+	for _, size := range server.requestBytes() {
+		require.LessOrEqual(t, size, 64)
+	}
+}
+
+func TestWriteEntryFailures(t *testing.T) {
+	server := newStubServer(t)
+	server.fail = func(body string) (string, bool) {
+		switch {
+		case strings.Contains(body, "invalid"):
+			return "InvalidMessageContents", true
+		case strings.Contains(body, "throttled"):
+			return "RequestThrottled", false
+		}
+		return "", false
+	}
+	plugin := newTestPlugin(t, server.URL, "telegraf", false)
+
+	metrics := []telegraf.Metric{
+		metric.New("test", map[string]string{}, map[string]interface{}{"value": "ok"}, time.Unix(0, 0)),
+		metric.New("test", map[string]string{}, map[string]interface{}{"value": "invalid"}, time.Unix(0, 0)),
+		metric.New("test", map[string]string{}, map[string]interface{}{"value": "throttled"}, time.Unix(0, 0)),
+	}
+	err := plugin.Write(metrics)
+	require.ErrorContains(t, err, "RequestThrottled")
+
+	// Rejected messages are dropped while others are kept for retry
+	var writeErr *internal.PartialWriteError
+	require.ErrorAs(t, err, &writeErr)
+	require.Equal(t, []int{0}, writeErr.MetricsAccept)
+	require.Equal(t, []int{1}, writeErr.MetricsReject)
+	require.ErrorContains(t, writeErr.MetricsRejectErrors[0], "InvalidMessageContents")
+}
+
+func TestWriteFIFOKeepsOrder(t *testing.T) {
+	server := newStubServer(t)
+	server.fail = func(body string) (string, bool) {
+		if strings.Contains(body, "throttled") {
+			return "RequestThrottled", false
+		}
+		return "", false
+	}
+	plugin := newTestPlugin(t, server.URL, "telegraf.fifo", false)
+	plugin.MaxMessageSize = 24
+
+	metrics := []telegraf.Metric{
+		metric.New("a", map[string]string{}, map[string]interface{}{"value": "throttled"}, time.Unix(0, 0)),
+		metric.New("b", map[string]string{}, map[string]interface{}{"value": "ok"}, time.Unix(0, 0)),
+		metric.New("a", map[string]string{}, map[string]interface{}{"value": "ok"}, time.Unix(0, 0)),
+		metric.New("a", map[string]string{}, map[string]interface{}{"value": "ok"}, time.Unix(0, 0)),
+	}
+	err := plugin.Write(metrics)
+	require.ErrorContains(t, err, "RequestThrottled")
+
+	// Messages of group "a" following the failed message in a later request
+	// must not be sent to preserve the order
+	var writeErr *internal.PartialWriteError
+	require.ErrorAs(t, err, &writeErr)
+	require.Equal(t, []int{1}, writeErr.MetricsAccept)
+	require.Empty(t, writeErr.MetricsReject)
+	require.Equal(t, []int{1, 1}, server.requestSizes())
+}
+
+func TestWriteRequestError(t *testing.T) {
+	server := newStubServer(t)
+	server.unavailable = true
+	plugin := newTestPlugin(t, server.URL, "telegraf", false)
+
+	metrics := []telegraf.Metric{
+		metric.New("test", map[string]string{}, map[string]interface{}{"value": 1}, time.Unix(0, 0)),
+	}
+	err := plugin.Write(metrics)
+	require.ErrorContains(t, err, "QueueDoesNotExist")
+
+	var writeErr *internal.PartialWriteError
+	require.ErrorAs(t, err, &writeErr)
+	require.Empty(t, writeErr.MetricsAccept)
+	require.Empty(t, writeErr.MetricsReject)
+}
+
+func TestWriteSNS(t *testing.T) {
+	api := &mockSNS{}
+	plugin := &SQS{
+		TopicARN:       "arn:aws:sns:us-east-1:000000000000:telegraf.fifo",
+		MessageGroupID: "{{.Name}}",
+		MaxMessageSize: config.Size(256 * 1024),
+		Timeout:        config.Duration(5 * time.Second),
+		Log:            testutil.Logger{},
+	}
+	plugin.SetSerializer(newSerializer(t))
+	require.NoError(t, plugin.Init())
+	plugin.client = &snsClient{api: api, topicARN: plugin.TopicARN}
+
+	metrics := []telegraf.Metric{
+		metric.New("cpu", map[string]string{}, map[string]interface{}{"value": 1}, time.Unix(0, 0)),
+		metric.New("mem", map[string]string{}, map[string]interface{}{"value": 2}, time.Unix(0, 0)),
+		metric.New("bad", map[string]string{}, map[string]interface{}{"value": 3}, time.Unix(0, 0)),
+	}
+	err := plugin.Write(metrics)
+
+	var writeErr *internal.PartialWriteError
+	require.ErrorAs(t, err, &writeErr)
+	require.Equal(t, []int{0, 1}, writeErr.MetricsAccept)
+	require.Equal(t, []int{2}, writeErr.MetricsReject)
+
+	require.Len(t, api.requests, 1)
+	require.Equal(t, "arn:aws:sns:us-east-1:000000000000:telegraf.fifo", aws.ToString(api.requests[0].TopicArn))
+	entries := api.requests[0].PublishBatchRequestEntries
+	require.Len(t, entries, 3)
+	require.Equal(t, "cpu value=1i 0\n", aws.ToString(entries[0].Message))
+	require.Equal(t, "cpu", aws.ToString(entries[0].MessageGroupId))
+	require.NotEmpty(t, aws.ToString(entries[0].MessageDeduplicationId))
+}
+
+func newTestPlugin(t *testing.T, endpoint, queue string, batch bool) *SQS {
+	t.Helper()
+
+	plugin := &SQS{
+		QueueURL:       endpoint + "/000000000000/" + queue,
+		MessageGroupID: "{{.Name}}",
+		MaxMessageSize: config.Size(256 * 1024),
+		UseBatchFormat: batch,
+		Timeout:        config.Duration(5 * time.Second),
+		CredentialConfig: common_aws.CredentialConfig{
+			Region:      "us-east-1",
+			AccessKey:   "dummy",
+			SecretKey:   "dummy",
+			EndpointURL: endpoint,
+		},
+		Log: testutil.Logger{},
+	}
+	plugin.SetSerializer(newSerializer(t))
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	t.Cleanup(func() { plugin.Close() })
+	return plugin
+}
+
+func newSerializer(t *testing.T) telegraf.Serializer {
+	t.Helper()
+	serializer := &influx.Serializer{}
+	require.NoError(t, serializer.Init())
+	return serializer
+}
+
+// stubEntry is an entry of a SendMessageBatch request in the JSON protocol
+type stubEntry struct {
+	Id                     string //nolint:revive // name given by the protocol
+	MessageBody            string
+	MessageGroupId         string //nolint:revive // name given by the protocol
+	MessageDeduplicationId string //nolint:revive // name given by the protocol
+}
+
+// stubServer implements the SendMessageBatch action of the SQS JSON protocol
+type stubServer struct {
+	*httptest.Server
+	fail        func(body string) (code string, senderFault bool)
+	unavailable bool
+
+	sync.Mutex
+	entries []stubEntry
+	sizes   []int
+	bytes   []int
+}
+
+func newStubServer(t *testing.T) *stubServer {
+	t.Helper()
+
+	s := &stubServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *stubServer) handle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	if r.Header.Get("X-Amz-Target") != "AmazonSQS.SendMessageBatch" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if s.unavailable {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"__type":"com.amazonaws.sqs#QueueDoesNotExist","message":"queue does not exist"}`))
+		return
+	}
+
+	var request struct {
+		QueueUrl string //nolint:revive // name given by the protocol
+		Entries  []stubEntry
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	type result struct {
+		Id               string `json:"Id"`
+		MessageId        string `json:"MessageId,omitempty"`
+		MD5OfMessageBody string `json:"MD5OfMessageBody,omitempty"`
+		Code             string `json:"Code,omitempty"`
+		SenderFault      bool   `json:"SenderFault,omitempty"`
+	}
+	var response struct {
+		Successful []result `json:"Successful"`
+		Failed     []result `json:"Failed"`
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	var size int
+	for _, e := range request.Entries {
+		size += len(e.MessageBody)
+		if s.fail != nil {
+			if code, senderFault := s.fail(e.MessageBody); code != "" {
+				response.Failed = append(response.Failed, result{Id: e.Id, Code: code, SenderFault: senderFault})
+				continue
+			}
+		}
+		sum := md5.Sum([]byte(e.MessageBody)) //nolint:gosec // required by the SQS protocol
+		response.Successful = append(response.Successful, result{
+			Id:               e.Id,
+			MessageId:        e.Id,
+			MD5OfMessageBody: hex.EncodeToString(sum[:]),
+		})
+		s.entries = append(s.entries, e)
+	}
+	s.sizes = append(s.sizes, len(request.Entries))
+	s.bytes = append(s.bytes, size)
+
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+func (s *stubServer) received() []stubEntry {
+	s.Lock()
+	defer s.Unlock()
+	return append([]stubEntry(nil), s.entries...)
+}
+
+func (s *stubServer) requestSizes() []int {
+	s.Lock()
+	defer s.Unlock()
+	return append([]int(nil), s.sizes...)
+}
+
+func (s *stubServer) requestBytes() []int {
+	s.Lock()
+	defer s.Unlock()
+	return append([]int(nil), s.bytes...)
+}
+
+type mockSNS struct {
+	requests []*sns.PublishBatchInput
+}
+
+func (m *mockSNS) PublishBatch(_ context.Context, input *sns.PublishBatchInput, _ ...func(*sns.Options)) (*sns.PublishBatchOutput, error) {
+	m.requests = append(m.requests, input)
+
+	var output sns.PublishBatchOutput
+	for _, e := range input.PublishBatchRequestEntries {
+		if strings.HasPrefix(aws.ToString(e.Message), "bad") {
+			output.Failed = append(output.Failed, sns_types.BatchResultErrorEntry{
+				Id:          e.Id,
+				Code:        aws.String("InvalidParameter"),
+				SenderFault: true,
+			})
+			continue
+		}
+		output.Successful = append(output.Successful, sns_types.PublishBatchResultEntry{Id: e.Id})
+	}
+	return &output, nil
+}
@@ -0,0 +1,5 @@
+//go:build !custom || processors || processors.anomaly
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/processors/anomaly" // register plugin
@@ -0,0 +1,5 @@
+//go:build !custom || processors || processors.sketch_merge
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/processors/sketch_merge" // register plugin
@@ -0,0 +1,323 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package anomaly
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/filter"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/processors"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+// hoursPerWeek is the number of seasonal buckets for "hour_of_week"
+const hoursPerWeek = 7 * 24
+
+type Anomaly struct {
+	Fields        []string        `toml:"fields"`
+	Alpha         float64         `toml:"alpha"`
+	Threshold     float64         `toml:"threshold"`
+	MinSamples    uint64          `toml:"min_samples"`
+	Seasonality   string          `toml:"seasonality"`
+	Timezone      string          `toml:"timezone"`
+	Mode          string          `toml:"mode"`
+	MetricSuffix  string          `toml:"metric_suffix"`
+	SeriesTimeout config.Duration `toml:"series_timeout"`
+	Log           telegraf.Logger `toml:"-"`
+
+	fieldFilter filter.Filter
+	location    *time.Location
+	series      state
+	lastSeen    map[uint64]time.Time
+	lastCleanup time.Time
+}
+
+// state contains the baselines of all fields per series with the series
+// identified by the hash of the metric name and tags
+type state map[uint64]map[string]*baselines
+
+// baselines of a field with the seasonal baselines only being present if
+// seasonality is enabled
+type baselines struct {
+	Overall  baseline   `json:"overall"`
+	Seasonal []baseline `json:"seasonal,omitempty"`
+}
+
+// baseline is an exponentially weighted moving mean and variance
+type baseline struct {
+	Count    uint64  `json:"count"`
+	Mean     float64 `json:"mean"`
+	Variance float64 `json:"variance"`
+}
+
+func (*Anomaly) SampleConfig() string {
+	return sampleConfig
+}
+
+func (a *Anomaly) Init() error {
+	if len(a.Fields) == 0 {
+		return errors.New("no fields specified")
+	}
+
+	if a.Alpha <= 0 || a.Alpha > 1 {
+		return errors.New("alpha must be greater than 0 and at most 1")
+	}
+	if a.Threshold <= 0 {
+		return errors.New("threshold must be positive")
+	}
+	if a.SeriesTimeout < 0 {
+		return errors.New("series_timeout must not be negative")
+	}
+
+	switch a.Seasonality {
+	case "":
+		a.Seasonality = "none"
+	case "none", "hour_of_week":
+	default:
+		return fmt.Errorf("invalid seasonality %q", a.Seasonality)
+	}
+
+	switch a.Mode {
+	case "":
+		a.Mode = "annotate"
+	case "annotate", "emit":
+	default:
+		return fmt.Errorf("invalid mode %q", a.Mode)
+	}
+
+	var err error
+	if a.fieldFilter, err = filter.Compile(a.Fields); err != nil {
+		return fmt.Errorf("creating field filter failed: %w", err)
+	}
+	if a.location, err = time.LoadLocation(a.Timezone); err != nil {
+		return fmt.Errorf("loading timezone failed: %w", err)
+	}
+
+	a.series = make(state)
+	a.lastSeen = make(map[uint64]time.Time)
+	a.lastCleanup = time.Now()
+
+	return nil
+}
+
+func (a *Anomaly) GetState() interface{} {
+	return a.series
+}
+
+func (a *Anomaly) SetState(s interface{}) error {
+	series, ok := s.(state)
+	if !ok {
+		return fmt.Errorf("state has wrong type %T", s)
+	}
+	if series == nil {
+		series = make(state)
+	}
+
+	// Adapt the seasonal baselines to the current setting to be able to
+	// change the seasonality without discarding the overall baselines
+	for _, fields := range series {
+		for _, b := range fields {
+			switch {
+			case a.Seasonality == "none":
+				b.Seasonal = nil
+			case len(b.Seasonal) != hoursPerWeek:
+				b.Seasonal = make([]baseline, hoursPerWeek)
+			}
+		}
+	}
+	a.series = series
+
+	// Restored series count as seen now to give them the chance to receive
+	// new values before being expired
+	now := time.Now()
+	a.lastSeen = make(map[uint64]time.Time, len(series))
+	for id := range series {
+		a.lastSeen[id] = now
+	}
+
+	return nil
+}
+
+func (a *Anomaly) Apply(in ...telegraf.Metric) []telegraf.Metric {
+	now := time.Now()
+	a.expire(now)
+
+	out := make([]telegraf.Metric, 0, len(in))
+	for _, m := range in {
+		out = append(out, m)
+
+		id := m.HashID()
+		fields, found := a.series[id]
+		if !found {
+			fields = make(map[string]*baselines)
+			a.series[id] = fields
+		}
+		a.lastSeen[id] = now
+
+		for _, field := range m.FieldList() {
+			if a.fieldFilter != nil && !a.fieldFilter.Match(field.Key) {
+				continue
+			}
+			value, ok := convert(field.Value)
+			if !ok || math.IsNaN(value) || math.IsInf(value, 0) {
+				continue
+			}
+
+			b, found := fields[field.Key]
+			if !found {
+				b = &baselines{}
+				if a.Seasonality == "hour_of_week" {
+					b.Seasonal = make([]baseline, hoursPerWeek)
+				}
+				fields[field.Key] = b
+			}
+
+			// Score the value against the baseline before updating it with
+			// the value, preferring the seasonal baseline once it is warmed up
+			current := &b.Overall
+			var seasonal *baseline
+			if b.Seasonal != nil {
+				seasonal = &b.Seasonal[a.hourOfWeek(m.Time())]
+				if seasonal.Count >= a.MinSamples {
+					current = seasonal
+				}
+			}
+			result, scored := a.score(current, value)
+
+			b.Overall.update(value, a.Alpha)
+			if seasonal != nil {
+				seasonal.update(value, a.Alpha)
+			}
+
+			if !scored {
+				continue
+			}
+			switch a.Mode {
+			case "annotate":
+				m.AddField(field.Key+"_zscore", result.zscore)
+				m.AddField(field.Key+"_anomaly", result.anomaly)
+				m.AddField(field.Key+"_expected", result.expected)
+				m.AddField(field.Key+"_lower", result.lower)
+				m.AddField(field.Key+"_upper", result.upper)
+			case "emit":
+				tags := m.Tags()
+				tags["field"] = field.Key
+				out = append(out, metric.New(
+					m.Name()+a.MetricSuffix,
+					tags,
+					map[string]interface{}{
+						"value":    value,
+						"zscore":   result.zscore,
+						"anomaly":  result.anomaly,
+						"expected": result.expected,
+						"lower":    result.lower,
+						"upper":    result.upper,
+					},
+					m.Time(),
+				))
+			}
+		}
+	}
+	return out
+}
+
+// expire removes the baselines of series not seen for the series timeout.
+// To avoid iterating all series on each call, the check is done at most once
+// per timeout, so series are removed after being idle for one to two timeouts.
+func (a *Anomaly) expire(now time.Time) {
+	timeout := time.Duration(a.SeriesTimeout)
+	if timeout == 0 || now.Sub(a.lastCleanup) < timeout {
+		return
+	}
+	a.lastCleanup = now
+
+	for id, seen := range a.lastSeen {
+		if now.Sub(seen) >= timeout {
+			delete(a.series, id)
+			delete(a.lastSeen, id)
+		}
+	}
+}
+
+type result struct {
+	zscore   float64
+	anomaly  bool
+	expected float64
+	lower    float64
+	upper    float64
+}
+
+// score computes the z-score of the value and the expected band, values are
+// not scored during warm-up or if the baseline has no variance yet
+func (a *Anomaly) score(b *baseline, value float64) (result, bool) {
+	if b.Count < a.MinSamples || b.Count == 0 {
+		return result{}, false
+	}
+	stddev := math.Sqrt(b.Variance)
+	if stddev == 0 {
+		return result{}, false
+	}
+
+	zscore := (value - b.Mean) / stddev
+	return result{
+		zscore:   zscore,
+		anomaly:  math.Abs(zscore) > a.Threshold,
+		expected: b.Mean,
+		lower:    b.Mean - a.Threshold*stddev,
+		upper:    b.Mean + a.Threshold*stddev,
+	}, true
+}
+
+func (a *Anomaly) hourOfWeek(t time.Time) int {
+	t = t.In(a.location)
+	return int(t.Weekday())*24 + t.Hour()
+}
+
+// update adds the value to the exponentially weighted moving mean and
+// variance, the first value initializes the mean
+func (b *baseline) update(value, alpha float64) {
+	b.Count++
+	if b.Count == 1 {
+		b.Mean = value
+		b.Variance = 0
+		return
+	}
+	diff := value - b.Mean
+	incr := alpha * diff
+	b.Mean += incr
+	b.Variance = (1 - alpha) * (b.Variance + diff*incr)
+}
+
+func convert(in interface{}) (float64, bool) {
+	switch v := in.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
+
+func init() {
+	processors.Add("anomaly", func() telegraf.Processor {
+		return &Anomaly{
+			Alpha:         0.1,
+			Threshold:     3.0,
+			MinSamples:    10,
+			Timezone:      "UTC",
+			MetricSuffix:  "_anomaly",
+			SeriesTimeout: config.Duration(7 * 24 * time.Hour),
+		}
+	})
+}
@@ -0,0 +1,352 @@
+package anomaly
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/persister"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInit(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *Anomaly
+		expected string
+	}{
+		{
+			name:     "no fields",
+			plugin:   &Anomaly{Alpha: 0.1, Threshold: 3},
+			expected: "no fields specified",
+		},
+		{
+			name:     "invalid alpha",
+			plugin:   &Anomaly{Fields: []string{"value"}, Alpha: 1.5, Threshold: 3},
+			expected: "alpha must be greater than 0 and at most 1",
+		},
+		{
+			name:     "invalid threshold",
+			plugin:   &Anomaly{Fields: []string{"value"}, Alpha: 0.1},
+			expected: "threshold must be positive",
+		},
+		{
+			name:     "invalid seasonality",
+			plugin:   &Anomaly{Fields: []string{"value"}, Alpha: 0.1, Threshold: 3, Seasonality: "day_of_year"},
+			expected: `invalid seasonality "day_of_year"`,
+		},
+		{
+			name:     "invalid mode",
+			plugin:   &Anomaly{Fields: []string{"value"}, Alpha: 0.1, Threshold: 3, Mode: "drop"},
+			expected: `invalid mode "drop"`,
+		},
+		{
+			name:     "invalid timezone",
+			plugin:   &Anomaly{Fields: []string{"value"}, Alpha: 0.1, Threshold: 3, Timezone: "Mars/Olympus"},
+			expected: "loading timezone failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestAnnotate(t *testing.T) {
+	plugin := &Anomaly{
+		Fields:     []string{"usage_*"},
+		Alpha:      0.1,
+		Threshold:  3,
+		MinSamples: 3,
+		Log:        testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	for i, v := range []float64{90, 92, 91} {
+		m := metric.New(
+			"cpu",
+			map[string]string{"cpu": "cpu0"},
+			map[string]interface{}{"usage_idle": v, "count": 1},
+			time.Unix(int64(i), 0),
+		)
+		// Values are not scored during warm-up
+		actual := plugin.Apply(m)
+		require.Len(t, actual, 1)
+		require.Len(t, actual[0].FieldList(), 2)
+	}
+
+	input := []telegraf.Metric{
+		metric.New(
+			"cpu",
+			map[string]string{"cpu": "cpu0"},
+			map[string]interface{}{"usage_idle": int64(10), "count": 1},
+			time.Unix(3, 0),
+		),
+		// Series with differing tags have their own baseline
+		metric.New(
+			"cpu",
+			map[string]string{"cpu": "cpu1"},
+			map[string]interface{}{"usage_idle": int64(10), "count": 1},
+			time.Unix(3, 0),
+		),
+	}
+	expected := []telegraf.Metric{
+		metric.New(
+			"cpu",
+			map[string]string{"cpu": "cpu0"},
+			map[string]interface{}{
+				"usage_idle":          int64(10),
+				"count":               1,
+				"usage_idle_zscore":   -129.958048383402,
+				"usage_idle_anomaly":  true,
+				"usage_idle_expected": 90.28,
+				"usage_idle_lower":    88.42678657462234,
+				"usage_idle_upper":    92.13321342537766,
+			},
+			time.Unix(3, 0),
+		),
+		metric.New(
+			"cpu",
+			map[string]string{"cpu": "cpu1"},
+			map[string]interface{}{"usage_idle": int64(10), "count": 1},
+			time.Unix(3, 0),
+		),
+	}
+
+	actual := plugin.Apply(input...)
+	testutil.RequireMetricsEqual(t, expected, actual, cmpopts.EquateApprox(0, 1e-9))
+}
+
+func TestEmit(t *testing.T) {
+	plugin := &Anomaly{
+		Fields:       []string{"value"},
+		Alpha:        0.5,
+		Threshold:    2,
+		MinSamples:   2,
+		Mode:         "emit",
+		MetricSuffix: "_anomaly",
+		Log:          testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	for i, v := range []float64{10, 12} {
+		m := metric.New("test", map[string]string{"host": "a"}, map[string]interface{}{"value": v}, time.Unix(int64(i), 0))
+		require.Len(t, plugin.Apply(m), 1)
+	}
+
+	// Baseline after 10 and 12 with alpha 0.5 has a mean of 11 and a
+	// variance of 1
+	input := metric.New("test", map[string]string{"host": "a"}, map[string]interface{}{"value": 12.5}, time.Unix(2, 0))
+	expected := []telegraf.Metric{
+		metric.New("test", map[string]string{"host": "a"}, map[string]interface{}{"value": 12.5}, time.Unix(2, 0)),
+		metric.New(
+			"test_anomaly",
+			map[string]string{"host": "a", "field": "value"},
+			map[string]interface{}{
+				"value":    12.5,
+				"zscore":   1.5,
+				"anomaly":  false,
+				"expected": 11.0,
+				"lower":    9.0,
+				"upper":    13.0,
+			},
+			time.Unix(2, 0),
+		),
+	}
+
+	actual := plugin.Apply(input)
+	testutil.RequireMetricsEqual(t, expected, actual)
+}
+
+func TestWildcardFields(t *testing.T) {
+	plugin := &Anomaly{
+		Fields:     []string{"*"},
+		Alpha:      0.5,
+		Threshold:  2,
+		MinSamples: 2,
+		Log:        testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	for i, v := range []float64{10, 12} {
+		m := metric.New(
+			"test",
+			map[string]string{"host": "a"},
+			map[string]interface{}{"a": v, "b": int64(v), "status": "ok"},
+			time.Unix(int64(i), 0),
+		)
+		require.Len(t, plugin.Apply(m), 1)
+	}
+
+	// All numeric fields are scored, non-numeric fields are skipped
+	input := metric.New(
+		"test",
+		map[string]string{"host": "a"},
+		map[string]interface{}{"a": 12.5, "b": int64(5), "status": "ok"},
+		time.Unix(2, 0),
+	)
+	expected := []telegraf.Metric{
+		metric.New(
+			"test",
+			map[string]string{"host": "a"},
+			map[string]interface{}{
+				"a":          12.5,
+				"a_zscore":   1.5,
+				"a_anomaly":  false,
+				"a_expected": 11.0,
+				"a_lower":    9.0,
+				"a_upper":    13.0,
+				"b":          int64(5),
+				"b_zscore":   -6.0,
+				"b_anomaly":  true,
+				"b_expected": 11.0,
+				"b_lower":    9.0,
+				"b_upper":    13.0,
+				"status":     "ok",
+			},
+			time.Unix(2, 0),
+		),
+	}
+
+	actual := plugin.Apply(input)
+	testutil.RequireMetricsEqual(t, expected, actual)
+}
+
+func TestSeasonality(t *testing.T) {
+	plugin := &Anomaly{
+		Fields:      []string{"requests"},
+		Alpha:       0.5,
+		Threshold:   3,
+		MinSamples:  2,
+		Seasonality: "hour_of_week",
+		Log:         testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	// Two weeks of hourly data with high values on Monday 10:00 and low
+	// values otherwise. The values differ per week to have a variance.
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for h := 0; h < 2*hoursPerWeek; h++ {
+		ts := start.Add(time.Duration(h) * time.Hour)
+		v := 10.0 + float64(h/hoursPerWeek)
+		if ts.Weekday() == time.Monday && ts.Hour() == 10 {
+			v = 1000.0 + float64(h/hoursPerWeek)
+		}
+		plugin.Apply(metric.New("web", map[string]string{}, map[string]interface{}{"requests": v}, ts))
+	}
+
+	// The peak is expected on Monday 10:00 in the third week...
+	ts := start.Add(2*7*24*time.Hour + 10*time.Hour)
+	m := metric.New("web", map[string]string{}, map[string]interface{}{"requests": 1001.0}, ts)
+	plugin.Apply(m)
+	anomaly, found := m.GetField("requests_anomaly")
+	require.True(t, found)
+	require.Equal(t, false, anomaly)
+
+	// ...but not an hour later
+	m = metric.New("web", map[string]string{}, map[string]interface{}{"requests": 1001.0}, ts.Add(time.Hour))
+	plugin.Apply(m)
+	anomaly, found = m.GetField("requests_anomaly")
+	require.True(t, found)
+	require.Equal(t, true, anomaly)
+}
+
+func TestSeriesTimeout(t *testing.T) {
+	plugin := &Anomaly{
+		Fields:        []string{"value"},
+		Alpha:         0.1,
+		Threshold:     3,
+		SeriesTimeout: config.Duration(time.Hour),
+		Log:           testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	a := metric.New("test", map[string]string{"host": "a"}, map[string]interface{}{"value": 1.0}, time.Unix(0, 0))
+	b := metric.New("test", map[string]string{"host": "b"}, map[string]interface{}{"value": 1.0}, time.Unix(0, 0))
+	plugin.Apply(a, b)
+	require.Len(t, plugin.series, 2)
+
+	// Pretend series "a" was idle for longer than the timeout
+	past := time.Now().Add(-2 * time.Hour)
+	plugin.lastSeen[a.HashID()] = past
+	plugin.lastCleanup = past
+
+	plugin.Apply(metric.New("test", map[string]string{"host": "b"}, map[string]interface{}{"value": 2.0}, time.Unix(1, 0)))
+	require.Len(t, plugin.series, 1)
+	require.Contains(t, plugin.series, b.HashID())
+	require.Len(t, plugin.lastSeen, 1)
+}
+
+func TestStatePersistence(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "state.json")
+
+	newPlugin := func() *Anomaly {
+		plugin := &Anomaly{
+			Fields:      []string{"value"},
+			Alpha:       0.1,
+			Threshold:   3,
+			MinSamples:  5,
+			Seasonality: "hour_of_week",
+			Log:         testutil.Logger{},
+		}
+		require.NoError(t, plugin.Init())
+		return plugin
+	}
+
+	plugin := newPlugin()
+	for i := 0; i < 20; i++ {
+		plugin.Apply(metric.New(
+			"test",
+			map[string]string{"host": "a"},
+			map[string]interface{}{"value": float64(50 + i%3)},
+			time.Unix(int64(i), 0),
+		))
+	}
+	store := &persister.Persister{Filename: filename}
+	require.NoError(t, store.Init())
+	require.NoError(t, store.Register("anomaly", plugin))
+	require.NoError(t, store.Store())
+
+	// Restore the state in a new instance and make sure the baselines are
+	// kept and values are scored without a new warm-up phase
+	restored := newPlugin()
+	load := &persister.Persister{Filename: filename}
+	require.NoError(t, load.Init())
+	require.NoError(t, load.Register("anomaly", restored))
+	require.NoError(t, load.Load())
+	require.Equal(t, plugin.series, restored.series)
+
+	m := metric.New("test", map[string]string{"host": "a"}, map[string]interface{}{"value": 500.0}, time.Unix(20, 0))
+	restored.Apply(m)
+	anomaly, found := m.GetField("value_anomaly")
+	require.True(t, found)
+	require.Equal(t, true, anomaly)
+}
+
+func TestStateSeasonalityChange(t *testing.T) {
+	plugin := &Anomaly{
+		Fields:     []string{"value"},
+		Alpha:      0.1,
+		Threshold:  3,
+		MinSamples: 1,
+		Log:        testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	s := state{
+		42: {"value": {Overall: baseline{Count: 10, Mean: 5, Variance: 1}, Seasonal: make([]baseline, hoursPerWeek)}},
+	}
+	require.NoError(t, plugin.SetState(s))
+	require.Equal(t, baseline{Count: 10, Mean: 5, Variance: 1}, plugin.series[42]["value"].Overall)
+	require.Nil(t, plugin.series[42]["value"].Seasonal)
+
+	require.ErrorContains(t, plugin.SetState("invalid"), "state has wrong type string")
+}
@@ -0,0 +1,176 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package cel
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/models"
+	"github.com/influxdata/telegraf/plugins/processors"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+type CEL struct {
+	Fields map[string]string `toml:"fields"`
+	Tags   map[string]string `toml:"tags"`
+	Log    telegraf.Logger   `toml:"-"`
+
+	fields []*expression
+	tags   []*expression
+}
+
+// Result types of the expressions convertible to fields and tags
+var (
+	fieldKinds = []types.Kind{
+		types.BoolKind, types.DoubleKind, types.IntKind, types.StringKind, types.UintKind,
+		types.NullTypeKind, types.DynKind, types.AnyKind,
+	}
+	tagKinds = append([]types.Kind{types.BytesKind, types.DurationKind, types.TimestampKind}, fieldKinds...)
+)
+
+type expression struct {
+	key     string
+	program cel.Program
+}
+
+func (*CEL) SampleConfig() string {
+	return sampleConfig
+}
+
+func (c *CEL) Init() error {
+	if len(c.Fields) == 0 && len(c.Tags) == 0 {
+		return errors.New("no fields or tags specified")
+	}
+
+	// Use the same environment as for the metric filters
+	env, err := models.NewMetricEnvironment()
+	if err != nil {
+		return fmt.Errorf("creating environment failed: %w", err)
+	}
+
+	c.fields, err = compile(env, c.Fields, fieldKinds)
+	if err != nil {
+		return fmt.Errorf("compiling field %w", err)
+	}
+	c.tags, err = compile(env, c.Tags, tagKinds)
+	if err != nil {
+		return fmt.Errorf("compiling tag %w", err)
+	}
+
+	return nil
+}
+
+func (c *CEL) Apply(in ...telegraf.Metric) []telegraf.Metric {
+	for _, m := range in {
+		// The activation contains copies of the tags and fields so all
+		// expressions are evaluated on the original metric
+		vars := models.MetricActivation(m)
+
+		for _, e := range c.fields {
+			v, err := e.eval(vars, fieldValue)
+			if err != nil {
+				c.Log.Debugf("Evaluating field %q of metric %q failed: %v", e.key, m.Name(), err)
+				continue
+			}
+			if v == nil {
+				m.RemoveField(e.key)
+				continue
+			}
+			m.AddField(e.key, v)
+		}
+
+		for _, e := range c.tags {
+			v, err := e.eval(vars, tagValue)
+			if err != nil {
+				c.Log.Debugf("Evaluating tag %q of metric %q failed: %v", e.key, m.Name(), err)
+				continue
+			}
+			if v == nil {
+				m.RemoveTag(e.key)
+				continue
+			}
+			m.AddTag(e.key, v.(string))
+		}
+	}
+
+	return in
+}
+
+// compile the expressions sorted by key to get a deterministic order
+func compile(env *cel.Env, expressions map[string]string, kinds []types.Kind) ([]*expression, error) {
+	keys := make([]string, 0, len(expressions))
+	for k := range expressions {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	compiled := make([]*expression, 0, len(keys))
+	for _, key := range keys {
+		if key == "" {
+			return nil, errors.New("with empty name")
+		}
+		ast, issues := env.Compile(expressions[key])
+		if issues.Err() != nil {
+			return nil, fmt.Errorf("%q failed: %w", key, issues.Err())
+		}
+
+		// Reject expressions never returning a supported value
+		if !slices.Contains(kinds, ast.OutputType().Kind()) {
+			return nil, fmt.Errorf("%q failed: unsupported result type %s", key, ast.OutputType())
+		}
+
+		program, err := env.Program(ast, cel.EvalOptions(cel.OptOptimize))
+		if err != nil {
+			return nil, fmt.Errorf("%q failed: %w", key, err)
+		}
+		compiled = append(compiled, &expression{key: key, program: program})
+	}
+	return compiled, nil
+}
+
+// eval the expression and convert the result, a nil value indicates a null
+// result
+func (e *expression) eval(vars map[string]interface{}, convert func(ref.Val) (interface{}, error)) (interface{}, error) {
+	result, _, err := e.program.Eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	if result.Type() == types.NullType {
+		return nil, nil
+	}
+	return convert(result)
+}
+
+// fieldValue converts the result to a field value keeping the type
+func fieldValue(v ref.Val) (interface{}, error) {
+	switch x := v.Value().(type) {
+	case float64, int64, uint64, bool, string:
+		return x, nil
+	}
+	return nil, fmt.Errorf("unsupported field type %s", v.Type().TypeName())
+}
+
+// tagValue converts the result to its string representation
+func tagValue(v ref.Val) (interface{}, error) {
+	s := v.ConvertToType(types.StringType)
+	if types.IsError(s) {
+		return nil, fmt.Errorf("unsupported tag type %s", v.Type().TypeName())
+	}
+	return s.Value(), nil
+}
+
+func init() {
+	processors.Add("cel", func() telegraf.Processor {
+		return &CEL{}
+	})
+}
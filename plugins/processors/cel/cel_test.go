@@ -0,0 +1,182 @@
+package cel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInit(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *CEL
+		expected string
+	}{
+		{
+			name:     "no expressions",
+			plugin:   &CEL{},
+			expected: "no fields or tags specified",
+		},
+		{
+			name:     "invalid syntax",
+			plugin:   &CEL{Fields: map[string]string{"value": "fields.used +"}},
+			expected: `compiling field "value" failed`,
+		},
+		{
+			name:     "unknown variable",
+			plugin:   &CEL{Tags: map[string]string{"host": "hostname"}},
+			expected: `compiling tag "host" failed`,
+		},
+		{
+			name:     "non-scalar result",
+			plugin:   &CEL{Fields: map[string]string{"value": "[1, 2]"}},
+			expected: `compiling field "value" failed: unsupported result type list(int)`,
+		},
+		{
+			name:     "timestamp field",
+			plugin:   &CEL{Fields: map[string]string{"value": "time"}},
+			expected: `compiling field "value" failed: unsupported result type google.protobuf.Timestamp`,
+		},
+		{
+			name:     "empty name",
+			plugin:   &CEL{Tags: map[string]string{"": "name"}},
+			expected: "compiling tag with empty name",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestFields(t *testing.T) {
+	plugin := &CEL{
+		Fields: map[string]string{
+			"used_percent": "fields.used / fields.total * 100.0",
+			"used_mb":      "int(fields.used) / 1024",
+			"full":         "fields.used >= fields.total",
+			"host":         "tags.host.upperAscii()",
+			"total":        "null",
+			"blocks":       "uint(fields.used) / 256u",
+		},
+		Log: testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	input := []telegraf.Metric{
+		metric.New("mem", map[string]string{"host": "a"}, map[string]interface{}{"used": 512.0, "total": 2048.0}, time.Unix(0, 0)),
+	}
+	expected := []telegraf.Metric{
+		metric.New(
+			"mem",
+			map[string]string{"host": "a"},
+			map[string]interface{}{
+				"used":         512.0,
+				"used_percent": 25.0,
+				"used_mb":      int64(0),
+				"full":         false,
+				"host":         "A",
+				"blocks":       uint64(2),
+			},
+			time.Unix(0, 0),
+		),
+	}
+
+	actual := plugin.Apply(input...)
+	testutil.RequireMetricsEqual(t, expected, actual)
+}
+
+func TestTags(t *testing.T) {
+	plugin := &CEL{
+		Tags: map[string]string{
+			"severity": `fields.usage_idle < 10.0 ? "critical" : (fields.usage_idle < 30.0 ? "warning" : "ok")`,
+			"busy":     "fields.usage_idle < 50.0",
+			"cores":    "fields.cores",
+			"cpu":      `tags.cpu == "cpu-total" ? null : dyn(tags.cpu)`,
+		},
+		Log: testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	input := []telegraf.Metric{
+		metric.New("cpu", map[string]string{"cpu": "cpu0"}, map[string]interface{}{"usage_idle": 5.0, "cores": 4}, time.Unix(0, 0)),
+		metric.New("cpu", map[string]string{"cpu": "cpu-total"}, map[string]interface{}{"usage_idle": 75.0, "cores": 4}, time.Unix(0, 0)),
+	}
+	expected := []telegraf.Metric{
+		metric.New(
+			"cpu",
+			map[string]string{"cpu": "cpu0", "severity": "critical", "busy": "true", "cores": "4"},
+			map[string]interface{}{"usage_idle": 5.0, "cores": 4},
+			time.Unix(0, 0),
+		),
+		metric.New(
+			"cpu",
+			map[string]string{"severity": "ok", "busy": "false", "cores": "4"},
+			map[string]interface{}{"usage_idle": 75.0, "cores": 4},
+			time.Unix(0, 0),
+		),
+	}
+
+	actual := plugin.Apply(input...)
+	testutil.RequireMetricsEqual(t, expected, actual)
+}
+
+func TestOriginalValues(t *testing.T) {
+	// All expressions see the values of the metric before any modification
+	plugin := &CEL{
+		Fields: map[string]string{
+			"a": "fields.b",
+			"b": "fields.a",
+		},
+		Tags: map[string]string{
+			"doubled": "fields.a * 2",
+			"time":    "time",
+		},
+		Log: testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	input := []telegraf.Metric{
+		metric.New("test", map[string]string{}, map[string]interface{}{"a": 1, "b": 2}, time.Unix(0, 0)),
+	}
+	expected := []telegraf.Metric{
+		metric.New("test", map[string]string{"doubled": "2", "time": "1970-01-01T00:00:00Z"}, map[string]interface{}{"a": 2, "b": 1}, time.Unix(0, 0)),
+	}
+
+	actual := plugin.Apply(input...)
+	testutil.RequireMetricsEqual(t, expected, actual)
+}
+
+func TestEvaluationErrors(t *testing.T) {
+	plugin := &CEL{
+		Fields: map[string]string{
+			"ratio":  "fields.used / fields.total",
+			"bytes":  "dyn(b'abc')",
+			"status": `"ok"`,
+		},
+		Log: testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	// Missing fields, mismatching types and unsupported results are skipped
+	input := []telegraf.Metric{
+		metric.New("mem", map[string]string{}, map[string]interface{}{"used": 512.0}, time.Unix(0, 0)),
+		metric.New("mem", map[string]string{}, map[string]interface{}{"used": 512.0, "total": 1024}, time.Unix(0, 0)),
+		metric.New("mem", map[string]string{}, map[string]interface{}{"used": 512.0, "total": 1024.0}, time.Unix(0, 0)),
+	}
+	expected := []telegraf.Metric{
+		metric.New("mem", map[string]string{}, map[string]interface{}{"used": 512.0, "status": "ok"}, time.Unix(0, 0)),
+		metric.New("mem", map[string]string{}, map[string]interface{}{"used": 512.0, "total": 1024, "status": "ok"}, time.Unix(0, 0)),
+		metric.New("mem", map[string]string{}, map[string]interface{}{"used": 512.0, "total": 1024.0, "ratio": 0.5, "status": "ok"}, time.Unix(0, 0)),
+	}
+
+	actual := plugin.Apply(input...)
+	testutil.RequireMetricsEqual(t, expected, actual)
+}
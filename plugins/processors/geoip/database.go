@@ -0,0 +1,97 @@
+package geoip
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// record contains the data of all supported database types, e.g. City,
+// Country, ASN or ISP databases. Data not contained in a database is left
+// untouched on decoding, so a record can be filled from multiple databases.
+type record struct {
+	Continent struct {
+		Code string `maxminddb:"code"`
+	} `maxminddb:"continent"`
+	Country struct {
+		IsoCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"country"`
+	Subdivisions []struct {
+		IsoCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"subdivisions"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Postal struct {
+		Code string `maxminddb:"code"`
+	} `maxminddb:"postal"`
+	Location struct {
+		Latitude  *float64 `maxminddb:"latitude"`
+		Longitude *float64 `maxminddb:"longitude"`
+		TimeZone  string   `maxminddb:"time_zone"`
+	} `maxminddb:"location"`
+	ASN          uint   `maxminddb:"autonomous_system_number"`
+	ASOrg        string `maxminddb:"autonomous_system_organization"`
+	Organization string `maxminddb:"organization"`
+}
+
+// database is a MaxMind-format database file that can be reloaded when the
+// file on disk changes
+type database struct {
+	filename string
+	reader   *maxminddb.Reader
+	modified time.Time
+	size     int64
+}
+
+func openDatabase(filename string) (*database, error) {
+	db := &database{filename: filename}
+	if err := db.load(); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+func (db *database) load() error {
+	info, err := os.Stat(db.filename)
+	if err != nil {
+		return err
+	}
+	reader, err := maxminddb.Open(db.filename)
+	if err != nil {
+		return fmt.Errorf("opening database %q failed: %w", db.filename, err)
+	}
+
+	// Close the previous database only after successfully opening the new one
+	if db.reader != nil {
+		db.reader.Close()
+	}
+	db.reader = reader
+	db.modified = info.ModTime()
+	db.size = info.Size()
+	return nil
+}
+
+// changed checks if the file on disk differs from the loaded database
+func (db *database) changed() (bool, error) {
+	info, err := os.Stat(db.filename)
+	if err != nil {
+		return false, err
+	}
+	return !info.ModTime().Equal(db.modified) || info.Size() != db.size, nil
+}
+
+func (db *database) lookup(ip net.IP, r *record) error {
+	return db.reader.Lookup(ip, r)
+}
+
+func (db *database) close() {
+	if db.reader != nil {
+		db.reader.Close()
+	}
+}
@@ -0,0 +1,322 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package geoip
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/plugins/processors"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+// attribute extracts a value from the record, values of attributes marked as
+// field are added as fields instead of tags
+type attribute struct {
+	field bool
+	value func(r *record, language string) (interface{}, bool)
+}
+
+var attributes = map[string]attribute{
+	"continent_code": {value: func(r *record, _ string) (interface{}, bool) {
+		return r.Continent.Code, r.Continent.Code != ""
+	}},
+	"country_iso_code": {value: func(r *record, _ string) (interface{}, bool) {
+		return r.Country.IsoCode, r.Country.IsoCode != ""
+	}},
+	"country_name": {value: func(r *record, language string) (interface{}, bool) {
+		name, found := r.Country.Names[language]
+		return name, found && name != ""
+	}},
+	"subdivision_iso_code": {value: func(r *record, _ string) (interface{}, bool) {
+		if len(r.Subdivisions) == 0 {
+			return nil, false
+		}
+		return r.Subdivisions[0].IsoCode, r.Subdivisions[0].IsoCode != ""
+	}},
+	"subdivision_name": {value: func(r *record, language string) (interface{}, bool) {
+		if len(r.Subdivisions) == 0 {
+			return nil, false
+		}
+		name, found := r.Subdivisions[0].Names[language]
+		return name, found && name != ""
+	}},
+	"city": {value: func(r *record, language string) (interface{}, bool) {
+		name, found := r.City.Names[language]
+		return name, found && name != ""
+	}},
+	"postal_code": {value: func(r *record, _ string) (interface{}, bool) {
+		return r.Postal.Code, r.Postal.Code != ""
+	}},
+	"time_zone": {value: func(r *record, _ string) (interface{}, bool) {
+		return r.Location.TimeZone, r.Location.TimeZone != ""
+	}},
+	"latitude": {field: true, value: func(r *record, _ string) (interface{}, bool) {
+		if r.Location.Latitude == nil {
+			return nil, false
+		}
+		return *r.Location.Latitude, true
+	}},
+	"longitude": {field: true, value: func(r *record, _ string) (interface{}, bool) {
+		if r.Location.Longitude == nil {
+			return nil, false
+		}
+		return *r.Location.Longitude, true
+	}},
+	"asn": {value: func(r *record, _ string) (interface{}, bool) {
+		return strconv.FormatUint(uint64(r.ASN), 10), r.ASN != 0
+	}},
+	"organization": {value: func(r *record, _ string) (interface{}, bool) {
+		if r.ASOrg != "" {
+			return r.ASOrg, true
+		}
+		return r.Organization, r.Organization != ""
+	}},
+}
+
+type GeoIP struct {
+	Databases      []string        `toml:"databases"`
+	Lookups        []lookup        `toml:"lookup"`
+	Attributes     []string        `toml:"attributes"`
+	Language       string          `toml:"language"`
+	CacheSize      int             `toml:"cache_size"`
+	ReloadInterval config.Duration `toml:"reload_interval"`
+	Log            telegraf.Logger `toml:"-"`
+
+	databases []*database
+	cache     *lru.Cache[string, []value]
+	mu        sync.RWMutex // guards the databases against concurrent reloads
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+}
+
+type lookup struct {
+	Tag    string `toml:"tag"`
+	Field  string `toml:"field"`
+	Prefix string `toml:"prefix"`
+}
+
+// value is the value of an attribute for an IP address
+type value struct {
+	name  string
+	field bool
+	value interface{}
+}
+
+func (*GeoIP) SampleConfig() string {
+	return sampleConfig
+}
+
+func (g *GeoIP) Init() error {
+	if len(g.Databases) == 0 {
+		return errors.New("no databases specified")
+	}
+	if len(g.Lookups) == 0 {
+		return errors.New("no lookups specified")
+	}
+	for i, l := range g.Lookups {
+		switch {
+		case l.Tag == "" && l.Field == "":
+			return fmt.Errorf("either tag or field required for lookup %d", i+1)
+		case l.Tag != "" && l.Field != "":
+			return fmt.Errorf("tag and field are mutually exclusive for lookup %d", i+1)
+		}
+		if l.Prefix == "" {
+			g.Lookups[i].Prefix = l.Tag + l.Field + "_"
+		}
+	}
+	if len(g.Attributes) == 0 {
+		return errors.New("no attributes specified")
+	}
+	for _, name := range g.Attributes {
+		if _, found := attributes[name]; !found {
+			return fmt.Errorf("invalid attribute %q", name)
+		}
+	}
+	if g.Language == "" {
+		g.Language = "en"
+	}
+	if g.CacheSize < 1 {
+		return errors.New("cache size must be positive")
+	}
+
+	cache, err := lru.New[string, []value](g.CacheSize)
+	if err != nil {
+		return fmt.Errorf("creating cache failed: %w", err)
+	}
+	g.cache = cache
+
+	g.databases = make([]*database, 0, len(g.Databases))
+	for _, fn := range g.Databases {
+		db, err := openDatabase(fn)
+		if err != nil {
+			return err
+		}
+		g.databases = append(g.databases, db)
+	}
+
+	return nil
+}
+
+func (g *GeoIP) Start(telegraf.Accumulator) error {
+	if g.ReloadInterval <= 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	g.cancel = cancel
+
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+
+		ticker := time.NewTicker(time.Duration(g.ReloadInterval))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				g.reload()
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (g *GeoIP) Add(m telegraf.Metric, acc telegraf.Accumulator) error {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	for _, l := range g.Lookups {
+		var address string
+		if l.Tag != "" {
+			v, found := m.GetTag(l.Tag)
+			if !found {
+				continue
+			}
+			address = v
+		} else {
+			raw, found := m.GetField(l.Field)
+			if !found {
+				continue
+			}
+			v, ok := raw.(string)
+			if !ok {
+				g.Log.Debugf("Field %q of metric %q is not a string", l.Field, m.Name())
+				continue
+			}
+			address = v
+		}
+
+		values, err := g.lookup(address)
+		if err != nil {
+			g.Log.Debugf("Looking up %q failed: %v", address, err)
+			continue
+		}
+		for _, v := range values {
+			if v.field {
+				m.AddField(l.Prefix+v.name, v.value)
+			} else {
+				m.AddTag(l.Prefix+v.name, v.value.(string))
+			}
+		}
+	}
+	acc.AddMetric(m)
+
+	return nil
+}
+
+func (g *GeoIP) Stop() {
+	if g.cancel != nil {
+		g.cancel()
+		g.wg.Wait()
+	}
+
+	for _, db := range g.databases {
+		db.close()
+	}
+}
+
+func (g *GeoIP) lookup(address string) ([]value, error) {
+	if values, found := g.cache.Get(address); found {
+		return values, nil
+	}
+
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return nil, errors.New("invalid IP address")
+	}
+
+	// Later databases take precedence for data contained in multiple
+	// databases as decoding overwrites the existing data
+	var r record
+	for _, db := range g.databases {
+		if err := db.lookup(ip, &r); err != nil {
+			return nil, fmt.Errorf("looking up in %q failed: %w", db.filename, err)
+		}
+	}
+
+	values := make([]value, 0, len(g.Attributes))
+	for _, name := range g.Attributes {
+		attr := attributes[name]
+		if v, found := attr.value(&r, g.Language); found {
+			values = append(values, value{name: name, field: attr.field, value: v})
+		}
+	}
+	g.cache.Add(address, values)
+
+	return values, nil
+}
+
+// reload replaces the databases changed on disk and purges the cache
+func (g *GeoIP) reload() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var reloaded bool
+	for _, db := range g.databases {
+		changed, err := db.changed()
+		if err != nil {
+			g.Log.Errorf("Checking database %q failed: %v", db.filename, err)
+			continue
+		}
+		if !changed {
+			continue
+		}
+		// Keep using the previous database if the new one cannot be loaded,
+		// e.g. while the file is still being written
+		if err := db.load(); err != nil {
+			g.Log.Errorf("Reloading database failed: %v", err)
+			continue
+		}
+		g.Log.Infof("Reloaded database %q", db.filename)
+		reloaded = true
+	}
+	if reloaded {
+		g.cache.Purge()
+	}
+}
+
+func init() {
+	processors.AddStreaming("geoip", func() telegraf.StreamingProcessor {
+		return &GeoIP{
+			Attributes:     []string{"country_iso_code", "city", "asn", "organization"},
+			Language:       "en",
+			CacheSize:      10000,
+			ReloadInterval: config.Duration(time.Minute),
+		}
+	})
+}
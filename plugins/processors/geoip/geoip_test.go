@@ -0,0 +1,243 @@
+package geoip
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInit(t *testing.T) {
+	city := filepath.Join("testdata", "city.mmdb")
+	dir := t.TempDir()
+	invalid := filepath.Join(dir, "invalid.mmdb")
+	require.NoError(t, os.WriteFile(invalid, []byte("not a database"), 0600))
+
+	tests := []struct {
+		name     string
+		plugin   *GeoIP
+		expected string
+	}{
+		{
+			name:     "no databases",
+			plugin:   &GeoIP{},
+			expected: "no databases specified",
+		},
+		{
+			name:     "no lookups",
+			plugin:   &GeoIP{Databases: []string{city}},
+			expected: "no lookups specified",
+		},
+		{
+			name:     "tag and field",
+			plugin:   &GeoIP{Databases: []string{city}, Lookups: []lookup{{Tag: "src", Field: "src"}}},
+			expected: "tag and field are mutually exclusive for lookup 1",
+		},
+		{
+			name: "invalid attribute",
+			plugin: &GeoIP{
+				Databases:  []string{city},
+				Lookups:    []lookup{{Tag: "src"}},
+				Attributes: []string{"country_iso_code", "planet"},
+				CacheSize:  10,
+			},
+			expected: `invalid attribute "planet"`,
+		},
+		{
+			name: "missing database",
+			plugin: &GeoIP{
+				Databases:  []string{filepath.Join(dir, "missing.mmdb")},
+				Lookups:    []lookup{{Tag: "src"}},
+				Attributes: []string{"country_iso_code"},
+				CacheSize:  10,
+			},
+			expected: "missing.mmdb: no such file or directory",
+		},
+		{
+			name: "invalid database",
+			plugin: &GeoIP{
+				Databases:  []string{invalid},
+				Lookups:    []lookup{{Tag: "src"}},
+				Attributes: []string{"country_iso_code"},
+				CacheSize:  10,
+			},
+			expected: "opening database",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	city := filepath.Join("testdata", "city.mmdb")
+	asn := filepath.Join("testdata", "asn.mmdb")
+
+	plugin := &GeoIP{
+		Databases: []string{city, asn},
+		Lookups: []lookup{
+			{Tag: "src"},
+			{Field: "dst", Prefix: "dst_"},
+		},
+		Attributes: []string{"country_iso_code", "country_name", "city", "asn", "organization", "latitude", "longitude"},
+		CacheSize:  10,
+		Log:        testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	input := []telegraf.Metric{
+		metric.New(
+			"netflow",
+			map[string]string{"src": "81.2.69.142"},
+			map[string]interface{}{"dst": "2001:db8::1", "bytes": 1500},
+			time.Unix(0, 0),
+		),
+		// Unknown and invalid addresses are passed on unmodified
+		metric.New(
+			"netflow",
+			map[string]string{"src": "192.0.2.1"},
+			map[string]interface{}{"dst": "invalid", "bytes": 42},
+			time.Unix(0, 0),
+		),
+		metric.New(
+			"netflow",
+			map[string]string{},
+			map[string]interface{}{"dst": 1234},
+			time.Unix(0, 0),
+		),
+	}
+
+	expected := []telegraf.Metric{
+		metric.New(
+			"netflow",
+			map[string]string{
+				"src":                  "81.2.69.142",
+				"src_country_iso_code": "GB",
+				"src_country_name":     "United Kingdom",
+				"src_city":             "London",
+				"src_asn":              "20712",
+				"src_organization":     "Andrews & Arnold Ltd",
+				"dst_country_iso_code": "DE",
+				"dst_country_name":     "Germany",
+			},
+			map[string]interface{}{
+				"dst":           "2001:db8::1",
+				"bytes":         1500,
+				"src_latitude":  51.5142,
+				"src_longitude": -0.0931,
+			},
+			time.Unix(0, 0),
+		),
+		metric.New(
+			"netflow",
+			map[string]string{"src": "192.0.2.1"},
+			map[string]interface{}{"dst": "invalid", "bytes": 42},
+			time.Unix(0, 0),
+		),
+		metric.New(
+			"netflow",
+			map[string]string{},
+			map[string]interface{}{"dst": 1234},
+			time.Unix(0, 0),
+		),
+	}
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+	for _, m := range input {
+		require.NoError(t, plugin.Add(m, &acc))
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics())
+
+	// The results are cached
+	require.Equal(t, 3, plugin.cache.Len())
+}
+
+func TestCacheEviction(t *testing.T) {
+	city := filepath.Join("testdata", "city.mmdb")
+
+	plugin := &GeoIP{
+		Databases:  []string{city},
+		Lookups:    []lookup{{Tag: "src"}},
+		Attributes: []string{"country_iso_code"},
+		CacheSize:  2,
+		Log:        testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	for _, address := range []string{"81.2.69.142", "81.2.69.143", "2001:db8::1", "81.2.69.144"} {
+		m := metric.New("test", map[string]string{"src": address}, map[string]interface{}{"value": 1}, time.Unix(0, 0))
+		require.NoError(t, plugin.Add(m, &acc))
+		require.Contains(t, m.Tags(), "src_country_iso_code")
+	}
+	require.Equal(t, []string{"2001:db8::1", "81.2.69.144"}, plugin.cache.Keys())
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "city.mmdb")
+	copyDatabase(t, filepath.Join("testdata", "city.mmdb"), filename)
+
+	plugin := &GeoIP{
+		Databases:      []string{filename},
+		Lookups:        []lookup{{Tag: "src"}},
+		Attributes:     []string{"city"},
+		CacheSize:      10,
+		ReloadInterval: config.Duration(time.Millisecond),
+		Log:            testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	city := func() string {
+		m := metric.New("test", map[string]string{"src": "81.2.69.142"}, map[string]interface{}{"value": 1}, time.Unix(0, 0))
+		require.NoError(t, plugin.Add(m, &acc))
+		return m.Tags()["src_city"]
+	}
+	require.Equal(t, "London", city())
+
+	// Replace the database atomically and make sure the modification time
+	// changes even on file systems with coarse timestamps
+	replacement := filepath.Join(dir, "city.mmdb.tmp")
+	copyDatabase(t, filepath.Join("testdata", "city_updated.mmdb"), replacement)
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(replacement, future, future))
+	require.NoError(t, os.Rename(replacement, filename))
+
+	// The database should be reloaded in the background
+	require.Eventually(t, func() bool {
+		return city() == "Manchester"
+	}, time.Second, 10*time.Millisecond)
+
+	// A broken database is ignored and the previous version used
+	require.NoError(t, os.WriteFile(replacement, []byte("broken"), 0600))
+	require.NoError(t, os.Rename(replacement, filename))
+	plugin.reload()
+	require.Equal(t, "Manchester", city())
+}
+
+func copyDatabase(t *testing.T, src, dst string) {
+	t.Helper()
+
+	buf, err := os.ReadFile(src)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(dst, buf, 0600))
+}
@@ -0,0 +1,136 @@
+package lookup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"text/template"
+
+	"github.com/influxdata/telegraf"
+	common_http "github.com/influxdata/telegraf/plugins/common/http"
+)
+
+// httpSource requests a JSON endpoint either returning the complete table in
+// the format of the JSON files or the tags of a single key
+type httpSource struct {
+	URL     string            `toml:"url"`
+	Headers map[string]string `toml:"headers"`
+	common_http.HTTPClientConfig
+
+	tmpl   *template.Template
+	client *http.Client
+}
+
+func (h *httpSource) init(string) error {
+	if h.URL == "" {
+		return errors.New("missing HTTP url option")
+	}
+
+	tmpl, err := template.New("url").Parse(h.URL)
+	if err != nil {
+		return fmt.Errorf("creating URL template failed: %w", err)
+	}
+	h.tmpl = tmpl
+
+	return nil
+}
+
+func (h *httpSource) connect(ctx context.Context, log telegraf.Logger) error {
+	client, err := h.HTTPClientConfig.CreateClient(ctx, log)
+	if err != nil {
+		return err
+	}
+	h.client = client
+
+	return nil
+}
+
+func (h *httpSource) load(ctx context.Context) (map[string][]telegraf.Tag, error) {
+	var data map[string]map[string]string
+	found, err := h.request(ctx, h.URL, &data)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, errors.New("table not found")
+	}
+
+	table := make(map[string][]telegraf.Tag, len(data))
+	for key, values := range data {
+		table[key] = toTags(values)
+	}
+	return table, nil
+}
+
+func (h *httpSource) query(ctx context.Context, key string) ([]telegraf.Tag, bool, error) {
+	var buf bytes.Buffer
+	if err := h.tmpl.Execute(&buf, map[string]string{"Key": key}); err != nil {
+		return nil, false, fmt.Errorf("generating URL failed: %w", err)
+	}
+
+	var data map[string]string
+	found, err := h.request(ctx, buf.String(), &data)
+	if err != nil || !found {
+		return nil, false, err
+	}
+	return toTags(data), true, nil
+}
+
+func (h *httpSource) close() {
+	if h.client != nil {
+		h.client.CloseIdleConnections()
+	}
+}
+
+// request the given URL and decode the JSON response, a "not found" status
+// is not considered an error
+func (h *httpSource) request(ctx context.Context, url string, data interface{}) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "application/json")
+	for k, v := range h.Headers {
+		if k == "Host" {
+			req.Host = v
+			continue
+		}
+		req.Header.Set(k, v)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return false, fmt.Errorf("received status %d (%s): %s", resp.StatusCode, http.StatusText(resp.StatusCode), body)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(data); err != nil {
+		return false, fmt.Errorf("decoding response failed: %w", err)
+	}
+	return true, nil
+}
+
+// toTags converts the values into tags sorted by name
+func toTags(values map[string]string) []telegraf.Tag {
+	tags := make([]telegraf.Tag, 0, len(values))
+	for k, v := range values {
+		tags = append(tags, telegraf.Tag{Key: k, Value: v})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].Key < tags[j].Key
+	})
+	return tags
+}
@@ -3,6 +3,7 @@ package lookup
 
 import (
 	"bytes"
+	"context"
 	_ "embed"
 	"encoding/csv"
 	"encoding/json"
@@ -10,10 +11,16 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
 	"strings"
+	"sync"
 	"text/template"
+	"time"
+
+	"github.com/hashicorp/golang-lru/v2/expirable"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
 	"github.com/influxdata/telegraf/plugins/processors"
 )
 
@@ -21,13 +28,49 @@ import (
 var sampleConfig string
 
 type Processor struct {
-	Filenames   []string        `toml:"files"`
-	Fileformat  string          `toml:"format"`
-	KeyTemplate string          `toml:"key"`
-	Log         telegraf.Logger `toml:"-"`
+	Filenames       []string          `toml:"files"`
+	Fileformat      string            `toml:"format"`
+	KeyTemplate     string            `toml:"key"`
+	Default         map[string]string `toml:"default"`
+	Mode            string            `toml:"mode"`
+	RefreshInterval config.Duration   `toml:"refresh_interval"`
+	Timeout         config.Duration   `toml:"timeout"`
+	CacheSize       int               `toml:"cache_size"`
+	CacheTTL        config.Duration   `toml:"cache_ttl"`
+	CacheErrorTTL   config.Duration   `toml:"cache_error_ttl"`
+	SQL             *sqlSource        `toml:"sql"`
+	HTTP            *httpSource       `toml:"http"`
+	Redis           *redisSource      `toml:"redis"`
+	Log             telegraf.Logger   `toml:"-"`
+
+	tmpl        *template.Template
+	mappings    map[string][]telegraf.Tag
+	defaultTags []telegraf.Tag
 
-	tmpl     *template.Template
-	mappings map[string][]telegraf.Tag
+	source source
+	table  map[string][]telegraf.Tag
+	cache  *expirable.LRU[string, *cacheEntry]
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	sync.RWMutex
+}
+
+// source is a dynamic lookup source either loaded completely on each
+// refresh or queried for single keys
+type source interface {
+	init(mode string) error
+	connect(ctx context.Context, log telegraf.Logger) error
+	load(ctx context.Context) (map[string][]telegraf.Tag, error)
+	query(ctx context.Context, key string) ([]telegraf.Tag, bool, error)
+	close()
+}
+
+// cacheEntry contains the result of querying a single key including misses
+// and failed queries being retried after the given time
+type cacheEntry struct {
+	tags  []telegraf.Tag
+	found bool
+	retry time.Time
 }
 
 func (*Processor) SampleConfig() string {
@@ -35,7 +78,22 @@ func (*Processor) SampleConfig() string {
 }
 
 func (p *Processor) Init() error {
-	if len(p.Filenames) < 1 {
+	// Determine the dynamic source
+	var sources []source
+	if p.SQL != nil {
+		sources = append(sources, p.SQL)
+	}
+	if p.HTTP != nil {
+		sources = append(sources, p.HTTP)
+	}
+	if p.Redis != nil {
+		sources = append(sources, p.Redis)
+	}
+	if len(sources) > 1 {
+		return errors.New("only one of 'sql', 'http' or 'redis' can be specified")
+	}
+
+	if len(p.Filenames) < 1 && len(sources) == 0 {
 		return errors.New("missing 'files'")
 	}
 
@@ -49,6 +107,43 @@ func (p *Processor) Init() error {
 	}
 	p.tmpl = tmpl
 
+	// Sort the default tags to add them in a deterministic order
+	p.defaultTags = make([]telegraf.Tag, 0, len(p.Default))
+	for k, v := range p.Default {
+		p.defaultTags = append(p.defaultTags, telegraf.Tag{Key: k, Value: v})
+	}
+	sort.Slice(p.defaultTags, func(i, j int) bool {
+		return p.defaultTags[i].Key < p.defaultTags[j].Key
+	})
+
+	if len(sources) > 0 {
+		if p.Mode == "" {
+			p.Mode = "poll"
+		}
+
+		p.source = sources[0]
+		if err := p.source.init(p.Mode); err != nil {
+			return err
+		}
+
+		if p.Timeout <= 0 {
+			return errors.New("timeout must be positive")
+		}
+		switch p.Mode {
+		case "poll":
+			if p.RefreshInterval <= 0 {
+				return errors.New("refresh_interval must be positive")
+			}
+		case "on_miss":
+			if p.CacheSize <= 0 {
+				return errors.New("cache_size must be positive")
+			}
+			p.cache = expirable.NewLRU[string, *cacheEntry](p.CacheSize, nil, time.Duration(p.CacheTTL))
+		default:
+			return fmt.Errorf("invalid mode %q", p.Mode)
+		}
+	}
+
 	p.mappings = make(map[string][]telegraf.Tag)
 	switch strings.ToLower(p.Fileformat) {
 	case "", "json":
@@ -62,7 +157,63 @@ func (p *Processor) Init() error {
 	return fmt.Errorf("invalid format %q", p.Fileformat)
 }
 
+func (p *Processor) Start(telegraf.Accumulator) error {
+	if p.source == nil {
+		return nil
+	}
+
+	// Connecting only fails for invalid settings, unavailable sources are
+	// reported and connecting is retried on the next load or query
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.Timeout))
+	defer cancel()
+	if err := p.source.connect(ctx, p.Log); err != nil {
+		return fmt.Errorf("connecting to source failed: %w", err)
+	}
+
+	if p.Mode != "poll" {
+		return nil
+	}
+
+	// Load the table initially but do not fail if the source is unavailable
+	// to not block the pipeline, the load is retried on the next refresh
+	p.refresh()
+
+	ctx, p.cancel = context.WithCancel(context.Background())
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+
+		ticker := time.NewTicker(time.Duration(p.RefreshInterval))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				p.refresh()
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (p *Processor) Add(m telegraf.Metric, acc telegraf.Accumulator) error {
+	for _, out := range p.Apply(m) {
+		acc.AddMetric(out)
+	}
+	return nil
+}
+
+// Apply looks up the metrics in the static files and the dynamic source if
+// any. The plugin is a streaming processor only to connect to the dynamic
+// sources in Start and to close them in Stop.
 func (p *Processor) Apply(in ...telegraf.Metric) []telegraf.Metric {
+	// Limit the total time the metrics are blocked by querying the source
+	// instead of limiting each query to not block for multiple timeouts
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.Timeout))
+	defer cancel()
+
 	out := make([]telegraf.Metric, 0, len(in))
 	for _, raw := range in {
 		m := raw
@@ -74,7 +225,11 @@ func (p *Processor) Apply(in ...telegraf.Metric) []telegraf.Metric {
 		if err := p.tmpl.Execute(&buf, m); err != nil {
 			p.Log.Errorf("generating key failed: %v", err)
 			p.Log.Debugf("metric was %v", m)
-		} else if tags, found := p.mappings[buf.String()]; found {
+		} else {
+			tags, found := p.lookup(ctx, buf.String())
+			if !found {
+				tags = p.defaultTags
+			}
 			for _, tag := range tags {
 				m.AddTag(tag.Key, tag.Value)
 			}
@@ -84,6 +239,78 @@ func (p *Processor) Apply(in ...telegraf.Metric) []telegraf.Metric {
 	return out
 }
 
+func (p *Processor) Stop() {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.wg.Wait()
+
+	if p.source != nil {
+		p.source.close()
+	}
+}
+
+// lookup the key in the dynamic source first, falling back to the entries of
+// the static files
+func (p *Processor) lookup(ctx context.Context, key string) ([]telegraf.Tag, bool) {
+	switch p.Mode {
+	case "poll":
+		p.RLock()
+		tags, found := p.table[key]
+		p.RUnlock()
+		if found {
+			return tags, true
+		}
+	case "on_miss":
+		entry, found := p.cache.Get(key)
+		if !found || (!entry.retry.IsZero() && !time.Now().Before(entry.retry)) {
+			// Treat the key as miss without caching it if the time for
+			// querying is exhausted, so it is queried with the next metrics
+			if ctx.Err() != nil {
+				break
+			}
+			entry = p.query(ctx, key)
+			p.cache.Add(key, entry)
+		}
+		if entry.found {
+			return entry.tags, true
+		}
+	}
+
+	tags, found := p.mappings[key]
+	return tags, found
+}
+
+// query the source for the given key within the time left for the current
+// metrics. Failed queries are treated as misses until the error TTL expires to
+// avoid blocking the pipeline for each metric if the source is unavailable.
+func (p *Processor) query(ctx context.Context, key string) *cacheEntry {
+	tags, found, err := p.source.query(ctx, key)
+	if err != nil {
+		p.Log.Errorf("querying key %q failed: %v", key, err)
+		return &cacheEntry{retry: time.Now().Add(time.Duration(p.CacheErrorTTL))}
+	}
+	return &cacheEntry{tags: tags, found: found}
+}
+
+// refresh loads the complete table from the source keeping the previous
+// table if loading fails
+func (p *Processor) refresh() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.Timeout))
+	defer cancel()
+
+	table, err := p.source.load(ctx)
+	if err != nil {
+		p.Log.Errorf("refreshing lookup table failed: %v", err)
+		return
+	}
+
+	p.Lock()
+	p.table = table
+	p.Unlock()
+	p.Log.Debugf("Loaded %d entries", len(table))
+}
+
 func (p *Processor) loadJSONFiles() error {
 	for _, fn := range p.Filenames {
 		buf, err := os.ReadFile(fn)
@@ -210,7 +437,13 @@ func (p *Processor) loadCSVKeyValuesFile(fn string) error {
 }
 
 func init() {
-	processors.Add("lookup", func() telegraf.Processor {
-		return &Processor{}
+	processors.AddStreaming("lookup", func() telegraf.StreamingProcessor {
+		return &Processor{
+			RefreshInterval: config.Duration(5 * time.Minute),
+			Timeout:         config.Duration(5 * time.Second),
+			CacheSize:       10000,
+			CacheTTL:        config.Duration(10 * time.Minute),
+			CacheErrorTTL:   config.Duration(10 * time.Second),
+		}
 	})
 }
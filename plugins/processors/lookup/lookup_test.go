@@ -1,13 +1,21 @@
 package lookup
 
 import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/docker/go-connections/nat"
+	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go/wait"
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/config"
@@ -176,3 +184,421 @@ func TestCasesTracking(t *testing.T) {
 		})
 	}
 }
+
+func process(t *testing.T, plugin *Processor, input []telegraf.Metric) []telegraf.Metric {
+	t.Helper()
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+	for _, m := range input {
+		require.NoError(t, plugin.Add(m, &acc))
+	}
+	return acc.GetTelegrafMetrics()
+}
+
+func TestInitSources(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *Processor
+		expected string
+	}{
+		{
+			name: "multiple sources",
+			plugin: &Processor{
+				KeyTemplate: "{{.Name}}",
+				HTTP:        &httpSource{URL: "http://localhost"},
+				Redis:       &redisSource{Address: "localhost:6379"},
+			},
+			expected: "only one of 'sql', 'http' or 'redis' can be specified",
+		},
+		{
+			name: "invalid mode",
+			plugin: &Processor{
+				KeyTemplate: "{{.Name}}",
+				Mode:        "push",
+				Timeout:     config.Duration(time.Second),
+				HTTP:        &httpSource{URL: "http://localhost"},
+			},
+			expected: `invalid mode "push"`,
+		},
+		{
+			name: "missing timeout",
+			plugin: &Processor{
+				KeyTemplate: "{{.Name}}",
+				HTTP:        &httpSource{URL: "http://localhost"},
+			},
+			expected: "timeout must be positive",
+		},
+		{
+			name: "missing refresh interval",
+			plugin: &Processor{
+				KeyTemplate: "{{.Name}}",
+				Timeout:     config.Duration(time.Second),
+				HTTP:        &httpSource{URL: "http://localhost"},
+			},
+			expected: "refresh_interval must be positive",
+		},
+		{
+			name: "missing cache size",
+			plugin: &Processor{
+				KeyTemplate: "{{.Name}}",
+				Mode:        "on_miss",
+				Timeout:     config.Duration(time.Second),
+				HTTP:        &httpSource{URL: "http://localhost"},
+			},
+			expected: "cache_size must be positive",
+		},
+		{
+			name: "missing url",
+			plugin: &Processor{
+				KeyTemplate: "{{.Name}}",
+				HTTP:        &httpSource{},
+			},
+			expected: "missing HTTP url option",
+		},
+		{
+			name: "invalid driver",
+			plugin: &Processor{
+				KeyTemplate: "{{.Name}}",
+				SQL:         &sqlSource{Driver: "foo", Query: "SELECT 1"},
+			},
+			expected: `SQL driver "foo" not supported`,
+		},
+		{
+			name: "missing key query",
+			plugin: &Processor{
+				KeyTemplate: "{{.Name}}",
+				Mode:        "on_miss",
+				SQL:         &sqlSource{Driver: "foo", Query: "SELECT 1"},
+			},
+			expected: "missing SQL key_query option",
+		},
+		{
+			name: "missing redis address",
+			plugin: &Processor{
+				KeyTemplate: "{{.Name}}",
+				Redis:       &redisSource{},
+			},
+			expected: "missing Redis address option",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestHTTPPoll(t *testing.T) {
+	var mu sync.Mutex
+	table := map[string]map[string]string{
+		"cpu-server01": {"team": "storage", "cost_center": "1234"},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if err := json.NewEncoder(w).Encode(table); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	plugin := &Processor{
+		Filenames:       []string{"testcases/normal_lookup_json/lut.json"},
+		KeyTemplate:     `{{.Name}}-{{.Tag "host"}}`,
+		Default:         map[string]string{"team": "unknown"},
+		RefreshInterval: config.Duration(50 * time.Millisecond),
+		Timeout:         config.Duration(time.Second),
+		HTTP: &httpSource{
+			URL:     server.URL,
+			Headers: map[string]string{"Authorization": "Bearer token"},
+		},
+		Log: testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	input := []telegraf.Metric{
+		metric.New("cpu", map[string]string{"host": "server01"}, map[string]interface{}{"value": 42}, time.Unix(0, 0)),
+		metric.New("cpu", map[string]string{"host": "server02"}, map[string]interface{}{"value": 23}, time.Unix(0, 0)),
+		// Static entries are used if the key is not found in the source
+		metric.New("cpu", map[string]string{"host": "Hugin"}, map[string]interface{}{"value": 3}, time.Unix(0, 0)),
+	}
+	expected := []telegraf.Metric{
+		metric.New(
+			"cpu",
+			map[string]string{"host": "server01", "team": "storage", "cost_center": "1234"},
+			map[string]interface{}{"value": 42},
+			time.Unix(0, 0),
+		),
+		metric.New(
+			"cpu",
+			map[string]string{"host": "server02", "team": "unknown"},
+			map[string]interface{}{"value": 23},
+			time.Unix(0, 0),
+		),
+		metric.New(
+			"cpu",
+			map[string]string{"host": "Hugin", "location": "at home", "type": "desktop"},
+			map[string]interface{}{"value": 3},
+			time.Unix(0, 0),
+		),
+	}
+	for _, m := range input {
+		require.NoError(t, plugin.Add(m.Copy(), &acc))
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics())
+
+	// Changes in the source are picked up on refresh
+	mu.Lock()
+	table["cpu-server02"] = map[string]string{"team": "network"}
+	mu.Unlock()
+	require.Eventually(t, func() bool {
+		acc.ClearMetrics()
+		require.NoError(t, plugin.Add(input[1].Copy(), &acc))
+		return acc.GetTelegrafMetrics()[0].Tags()["team"] == "network"
+	}, 3*time.Second, 50*time.Millisecond)
+}
+
+func TestHTTPOnMiss(t *testing.T) {
+	var mu sync.Mutex
+	requests := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.URL.Query().Get("host")
+		mu.Lock()
+		requests[host]++
+		mu.Unlock()
+
+		switch host {
+		case "server 01":
+			if _, err := w.Write([]byte(`{"team": "storage"}`)); err != nil {
+				t.Error(err)
+			}
+		case "slow":
+			// Simulate a hanging source
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	plugin := &Processor{
+		KeyTemplate:   `{{.Tag "host"}}`,
+		Default:       map[string]string{"team": "unknown"},
+		Mode:          "on_miss",
+		Timeout:       config.Duration(100 * time.Millisecond),
+		CacheSize:     10,
+		CacheTTL:      config.Duration(time.Hour),
+		CacheErrorTTL: config.Duration(time.Hour),
+		HTTP:          &httpSource{URL: server.URL + "/hosts?host={{urlquery .Key}}"},
+		Log:           testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	for i := 0; i < 3; i++ {
+		for _, host := range []string{"server 01", "server02", "slow"} {
+			m := metric.New("cpu", map[string]string{"host": host}, map[string]interface{}{"value": i}, time.Unix(0, 0))
+			start := time.Now()
+			require.NoError(t, plugin.Add(m, &acc))
+			require.Less(t, time.Since(start), time.Second)
+		}
+	}
+
+	for _, m := range acc.GetTelegrafMetrics() {
+		expected := "unknown"
+		if m.Tags()["host"] == "server 01" {
+			expected = "storage"
+		}
+		require.Equal(t, expected, m.Tags()["team"])
+	}
+
+	// Hits, misses and failures are cached
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, map[string]int{"server 01": 1, "server02": 1, "slow": 1}, requests)
+}
+
+func TestHTTPOnMissErrorTTL(t *testing.T) {
+	var failed atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		// Fail the first request only
+		if failed.CompareAndSwap(false, true) {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if _, err := w.Write([]byte(`{"team": "storage"}`)); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	plugin := &Processor{
+		KeyTemplate:   `{{.Tag "host"}}`,
+		Mode:          "on_miss",
+		Timeout:       config.Duration(time.Second),
+		CacheSize:     10,
+		CacheTTL:      config.Duration(time.Hour),
+		CacheErrorTTL: config.Duration(50 * time.Millisecond),
+		HTTP:          &httpSource{URL: server.URL + "/hosts/{{.Key}}"},
+		Log:           testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	team := func() string {
+		m := metric.New("cpu", map[string]string{"host": "server01"}, map[string]interface{}{"value": 1}, time.Unix(0, 0))
+		require.NoError(t, plugin.Add(m, &acc))
+		return m.Tags()["team"]
+	}
+
+	// The failed query must only be cached for the error TTL
+	require.Empty(t, team())
+	require.Empty(t, team())
+	time.Sleep(100 * time.Millisecond)
+	require.Equal(t, "storage", team())
+}
+
+func TestHTTPOnMissTimeout(t *testing.T) {
+	var requests atomic.Int64
+	var available atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		// Block until the client gives up while the server is unavailable
+		if !available.Load() {
+			<-r.Context().Done()
+			return
+		}
+		if _, err := w.Write([]byte(`{"team": "storage"}`)); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	plugin := &Processor{
+		KeyTemplate:   `{{.Tag "host"}}`,
+		Mode:          "on_miss",
+		Timeout:       config.Duration(500 * time.Millisecond),
+		CacheSize:     10,
+		CacheTTL:      config.Duration(time.Hour),
+		CacheErrorTTL: config.Duration(time.Hour),
+		HTTP:          &httpSource{URL: server.URL + "/hosts/{{.Key}}"},
+		Log:           testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Start(nil))
+	defer plugin.Stop()
+
+	input := func() []telegraf.Metric {
+		return []telegraf.Metric{
+			metric.New("cpu", map[string]string{"host": "server01"}, map[string]interface{}{"value": 1}, time.Unix(0, 0)),
+			metric.New("cpu", map[string]string{"host": "server02"}, map[string]interface{}{"value": 2}, time.Unix(0, 0)),
+			metric.New("cpu", map[string]string{"host": "server03"}, map[string]interface{}{"value": 3}, time.Unix(0, 0)),
+		}
+	}
+
+	// The timeout must limit the time for all metrics and not per key
+	start := time.Now()
+	for _, m := range plugin.Apply(input()...) {
+		require.NotContains(t, m.Tags(), "team")
+	}
+	require.Less(t, time.Since(start), 3*time.Duration(plugin.Timeout))
+	require.Equal(t, int64(1), requests.Load())
+
+	// Keys not queried due to the timeout must be queried with later metrics
+	// while the failed key is cached for the error TTL
+	available.Store(true)
+	teams := make([]string, 0, 3)
+	for _, m := range plugin.Apply(input()...) {
+		teams = append(teams, m.Tags()["team"])
+	}
+	require.Equal(t, []string{"", "storage", "storage"}, teams)
+	require.Equal(t, int64(3), requests.Load())
+}
+
+func TestRedisIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	servicePort := "6379"
+	container := testutil.Container{
+		Image:        "redis:alpine",
+		ExposedPorts: []string{servicePort},
+		WaitingFor:   wait.ForListeningPort(nat.Port(servicePort)),
+	}
+	require.NoError(t, container.Start(), "failed to start container")
+	defer container.Terminate()
+	address := fmt.Sprintf("%s:%s", container.Address, container.Ports[servicePort])
+
+	client := redis.NewClient(&redis.Options{Addr: address})
+	defer client.Close()
+	require.NoError(t, client.HSet(t.Context(), "cmdb:server01", "team", "storage", "cost_center", "1234").Err())
+	require.NoError(t, client.Set(t.Context(), "cmdb:invalid", "no hash", 0).Err())
+
+	input := []telegraf.Metric{
+		metric.New("cpu", map[string]string{"host": "server01"}, map[string]interface{}{"value": 42}, time.Unix(0, 0)),
+		metric.New("cpu", map[string]string{"host": "server02"}, map[string]interface{}{"value": 23}, time.Unix(0, 0)),
+	}
+	expected := []telegraf.Metric{
+		metric.New(
+			"cpu",
+			map[string]string{"host": "server01", "team": "storage", "cost_center": "1234"},
+			map[string]interface{}{"value": 42},
+			time.Unix(0, 0),
+		),
+		metric.New(
+			"cpu",
+			map[string]string{"host": "server02", "team": "unknown"},
+			map[string]interface{}{"value": 23},
+			time.Unix(0, 0),
+		),
+	}
+
+	for _, mode := range []string{"poll", "on_miss"} {
+		t.Run(mode, func(t *testing.T) {
+			plugin := &Processor{
+				KeyTemplate:     `{{.Tag "host"}}`,
+				Default:         map[string]string{"team": "unknown"},
+				Mode:            mode,
+				RefreshInterval: config.Duration(time.Minute),
+				Timeout:         config.Duration(5 * time.Second),
+				CacheSize:       10,
+				CacheTTL:        config.Duration(time.Minute),
+				Redis: &redisSource{
+					Address:   address,
+					KeyPrefix: "cmdb:",
+				},
+				Log: testutil.Logger{},
+			}
+			require.NoError(t, plugin.Init())
+
+			var inputs []telegraf.Metric
+			for _, m := range input {
+				inputs = append(inputs, m.Copy())
+			}
+			actual := process(t, plugin, inputs)
+			testutil.RequireMetricsEqual(t, expected, actual)
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package lookup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/plugins/common/tls"
+)
+
+// redisSource uses Redis hashes named by the key prefix and the lookup key
+// with the hash fields being the tags
+type redisSource struct {
+	Address   string        `toml:"address"`
+	Username  config.Secret `toml:"username"`
+	Password  config.Secret `toml:"password"`
+	Database  int           `toml:"database"`
+	KeyPrefix string        `toml:"key_prefix"`
+	tls.ClientConfig
+
+	client *redis.Client
+}
+
+func (r *redisSource) init(string) error {
+	if r.Address == "" {
+		return errors.New("missing Redis address option")
+	}
+	return nil
+}
+
+func (r *redisSource) connect(ctx context.Context, log telegraf.Logger) error {
+	tlsCfg, err := r.ClientConfig.TLSConfig()
+	if err != nil {
+		return fmt.Errorf("creating TLS config failed: %w", err)
+	}
+
+	username, err := r.Username.Get()
+	if err != nil {
+		return fmt.Errorf("getting username failed: %w", err)
+	}
+	defer username.Destroy()
+	password, err := r.Password.Get()
+	if err != nil {
+		return fmt.Errorf("getting password failed: %w", err)
+	}
+	defer password.Destroy()
+
+	// The timeouts are controlled by the contexts of the individual requests
+	r.client = redis.NewClient(&redis.Options{
+		Addr:                  r.Address,
+		Username:              username.String(),
+		Password:              password.String(),
+		DB:                    r.Database,
+		TLSConfig:             tlsCfg,
+		ContextTimeoutEnabled: true,
+	})
+
+	// The client connects lazily, so an unavailable server is only reported
+	// and connecting is retried on the next request
+	if err := r.client.Ping(ctx).Err(); err != nil {
+		log.Warnf("Connecting to Redis failed, retrying on next request: %v", err)
+	}
+
+	return nil
+}
+
+func (r *redisSource) load(ctx context.Context) (map[string][]telegraf.Tag, error) {
+	table := make(map[string][]telegraf.Tag)
+
+	iter := r.client.Scan(ctx, 0, r.KeyPrefix+"*", 1000).Iterator()
+	var keys []string
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("scanning keys failed: %w", err)
+	}
+
+	// Get the hashes in batches to reduce the number of round-trips
+	for len(keys) > 0 {
+		batch := keys[:min(len(keys), 1000)]
+		keys = keys[len(batch):]
+
+		pipe := r.client.Pipeline()
+		cmds := make([]*redis.MapStringStringCmd, 0, len(batch))
+		for _, key := range batch {
+			cmds = append(cmds, pipe.HGetAll(ctx, key))
+		}
+		// Keys matching the prefix but not being hashes are ignored
+		if _, err := pipe.Exec(ctx); err != nil && !redis.HasErrorPrefix(err, "WRONGTYPE") {
+			return nil, fmt.Errorf("getting hashes failed: %w", err)
+		}
+		for i, cmd := range cmds {
+			values, err := cmd.Result()
+			if err != nil || len(values) == 0 {
+				continue
+			}
+			table[strings.TrimPrefix(batch[i], r.KeyPrefix)] = toTags(values)
+		}
+	}
+
+	return table, nil
+}
+
+func (r *redisSource) query(ctx context.Context, key string) ([]telegraf.Tag, bool, error) {
+	values, err := r.client.HGetAll(ctx, r.KeyPrefix+key).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	if len(values) == 0 {
+		return nil, false, nil
+	}
+	return toTags(values), true, nil
+}
+
+func (r *redisSource) close() {
+	if r.client != nil {
+		r.client.Close()
+	}
+}
@@ -0,0 +1,148 @@
+package lookup
+
+import (
+	"context"
+	dbsql "database/sql"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+)
+
+// sqlSource queries a database using the drivers of the SQL input plugin.
+// The first column of the query result is the key, all other columns are
+// added as tags named after the column. The complete table is loaded using
+// the query while single keys are looked up using the key-query.
+type sqlSource struct {
+	Driver   string        `toml:"driver"`
+	DSN      config.Secret `toml:"dsn"`
+	Query    string        `toml:"query"`
+	KeyQuery string        `toml:"key_query"`
+
+	driverName string
+	db         *dbsql.DB
+}
+
+func (s *sqlSource) init(mode string) error {
+	if s.Driver == "" {
+		return errors.New("missing SQL driver option")
+	}
+	if mode == "on_miss" {
+		if s.KeyQuery == "" {
+			return errors.New("missing SQL key_query option")
+		}
+	} else if s.Query == "" {
+		return errors.New("missing SQL query option")
+	}
+
+	// Use the same aliases as the SQL input plugin
+	aliases := map[string]string{
+		"cockroach": "pgx",
+		"tidb":      "mysql",
+		"mssql":     "sqlserver",
+		"maria":     "mysql",
+		"postgres":  "pgx",
+		"oracle":    "oracle",
+	}
+	s.driverName = s.Driver
+	if driver, ok := aliases[s.Driver]; ok {
+		s.driverName = driver
+	}
+	if !slices.Contains(dbsql.Drivers(), s.driverName) {
+		return fmt.Errorf("SQL driver %q not supported", s.Driver)
+	}
+
+	return nil
+}
+
+func (s *sqlSource) connect(ctx context.Context, log telegraf.Logger) error {
+	dsn, err := s.DSN.Get()
+	if err != nil {
+		return fmt.Errorf("getting DSN failed: %w", err)
+	}
+	defer dsn.Destroy()
+
+	// The connections of the pool are established lazily, so an unavailable
+	// database is only reported and connecting is retried on the next query
+	db, err := dbsql.Open(s.driverName, dsn.String())
+	if err != nil {
+		return err
+	}
+	if err := db.PingContext(ctx); err != nil {
+		log.Warnf("Connecting to database failed, retrying on next query: %v", err)
+	}
+	s.db = db
+
+	return nil
+}
+
+func (s *sqlSource) load(ctx context.Context) (map[string][]telegraf.Tag, error) {
+	rows, err := s.db.QueryContext(ctx, s.Query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	table := make(map[string][]telegraf.Tag)
+	for rows.Next() {
+		key, tags, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		table[key] = append(table[key], tags...)
+	}
+	return table, rows.Err()
+}
+
+func (s *sqlSource) query(ctx context.Context, key string) ([]telegraf.Tag, bool, error) {
+	rows, err := s.db.QueryContext(ctx, s.KeyQuery, key)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, false, rows.Err()
+	}
+	_, tags, err := scan(rows)
+	if err != nil {
+		return nil, false, err
+	}
+	return tags, true, nil
+}
+
+func (s *sqlSource) close() {
+	if s.db != nil {
+		s.db.Close()
+	}
+}
+
+// scan the current row into the key and the tags, NULL values are skipped
+func scan(rows *dbsql.Rows) (string, []telegraf.Tag, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", nil, err
+	}
+	if len(columns) < 2 {
+		return "", nil, errors.New("query has to return at least a key and a value column")
+	}
+
+	values := make([]dbsql.NullString, len(columns))
+	ptrs := make([]interface{}, len(columns))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	if err := rows.Scan(ptrs...); err != nil {
+		return "", nil, err
+	}
+
+	tags := make([]telegraf.Tag, 0, len(columns)-1)
+	for i, v := range values[1:] {
+		if v.Valid {
+			tags = append(tags, telegraf.Tag{Key: columns[i+1], Value: v.String})
+		}
+	}
+	return values[0].String, tags, nil
+}
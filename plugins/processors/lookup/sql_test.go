@@ -0,0 +1,142 @@
+//go:build !mips && !mipsle && !mips64 && !ppc64 && !riscv64 && !loong64 && !mips64le && !(windows && (386 || arm)) && !(freebsd && (386 || arm))
+
+package lookup
+
+import (
+	dbsql "database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestSQL(t *testing.T) {
+	// Create a database with the lookup table
+	dsn := filepath.Join(t.TempDir(), "cmdb.db")
+	db, err := dbsql.Open("sqlite", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec(`
+		CREATE TABLE hosts (hostname TEXT, team TEXT, cost_center INTEGER);
+		INSERT INTO hosts VALUES ('server01', 'storage', 1234), ('server02', 'network', NULL);
+	`)
+	require.NoError(t, err)
+
+	input := []telegraf.Metric{
+		metric.New("cpu", map[string]string{"host": "server01"}, map[string]interface{}{"value": 42}, time.Unix(0, 0)),
+		metric.New("cpu", map[string]string{"host": "server02"}, map[string]interface{}{"value": 23}, time.Unix(0, 0)),
+		metric.New("cpu", map[string]string{"host": "server03"}, map[string]interface{}{"value": 3}, time.Unix(0, 0)),
+	}
+	expected := []telegraf.Metric{
+		metric.New(
+			"cpu",
+			map[string]string{"host": "server01", "team": "storage", "cost_center": "1234"},
+			map[string]interface{}{"value": 42},
+			time.Unix(0, 0),
+		),
+		metric.New(
+			"cpu",
+			map[string]string{"host": "server02", "team": "network"},
+			map[string]interface{}{"value": 23},
+			time.Unix(0, 0),
+		),
+		metric.New(
+			"cpu",
+			map[string]string{"host": "server03", "team": "unknown"},
+			map[string]interface{}{"value": 3},
+			time.Unix(0, 0),
+		),
+	}
+
+	tests := []struct {
+		name string
+		mode string
+	}{
+		{
+			name: "poll",
+			mode: "poll",
+		},
+		{
+			name: "on miss",
+			mode: "on_miss",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := &Processor{
+				KeyTemplate:     `{{.Tag "host"}}`,
+				Default:         map[string]string{"team": "unknown"},
+				Mode:            tt.mode,
+				RefreshInterval: config.Duration(time.Minute),
+				Timeout:         config.Duration(5 * time.Second),
+				CacheSize:       10,
+				CacheTTL:        config.Duration(time.Minute),
+				SQL: &sqlSource{
+					Driver:   "sqlite",
+					DSN:      config.NewSecret([]byte(dsn)),
+					Query:    "SELECT hostname, team, cost_center FROM hosts",
+					KeyQuery: "SELECT hostname, team, cost_center FROM hosts WHERE hostname = ?",
+				},
+				Log: testutil.Logger{},
+			}
+			require.NoError(t, plugin.Init())
+
+			var inputs []telegraf.Metric
+			for _, m := range input {
+				inputs = append(inputs, m.Copy())
+			}
+			actual := process(t, plugin, inputs)
+			testutil.RequireMetricsEqual(t, expected, actual)
+		})
+	}
+}
+
+func TestSQLUnavailable(t *testing.T) {
+	// The database cannot be opened as the directory does not exist
+	dsn := filepath.Join(t.TempDir(), "missing", "cmdb.db")
+
+	input := metric.New("cpu", map[string]string{"host": "server01"}, map[string]interface{}{"value": 42}, time.Unix(0, 0))
+	expected := []telegraf.Metric{
+		metric.New(
+			"cpu",
+			map[string]string{"host": "server01", "team": "unknown"},
+			map[string]interface{}{"value": 42},
+			time.Unix(0, 0),
+		),
+	}
+
+	for _, mode := range []string{"poll", "on_miss"} {
+		t.Run(mode, func(t *testing.T) {
+			logger := &testutil.CaptureLogger{}
+			plugin := &Processor{
+				KeyTemplate:     `{{.Tag "host"}}`,
+				Default:         map[string]string{"team": "unknown"},
+				Mode:            mode,
+				RefreshInterval: config.Duration(time.Minute),
+				Timeout:         config.Duration(5 * time.Second),
+				CacheSize:       10,
+				CacheTTL:        config.Duration(time.Minute),
+				SQL: &sqlSource{
+					Driver:   "sqlite",
+					DSN:      config.NewSecret([]byte(dsn)),
+					Query:    "SELECT hostname, team FROM hosts",
+					KeyQuery: "SELECT hostname, team FROM hosts WHERE hostname = ?",
+				},
+				Log: logger,
+			}
+			require.NoError(t, plugin.Init())
+
+			// Starting must not fail if the database is unavailable
+			actual := process(t, plugin, []telegraf.Metric{input.Copy()})
+			testutil.RequireMetricsEqual(t, expected, actual)
+			require.NotEmpty(t, logger.Warnings())
+		})
+	}
+}
@@ -0,0 +1,266 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package rate
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/filter"
+	"github.com/influxdata/telegraf/plugins/processors"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+type Rate struct {
+	Fields      []string        `toml:"fields"`
+	Suffix      string          `toml:"suffix"`
+	CounterType string          `toml:"counter_type"`
+	MaxGap      config.Duration `toml:"max_gap"`
+	Log         telegraf.Logger `toml:"-"`
+
+	accept filter.Filter
+	cache  state
+}
+
+// state contains the last value of all fields per series with the series
+// identified by the hash of the metric name and tags
+type state map[uint64]*entry
+
+type entry struct {
+	Fields map[string]*value `json:"fields"`
+
+	seen time.Time
+}
+
+// value is the last value of a counter field, integer values are kept as
+// unsigned integers to compute exact differences for large counters
+type value struct {
+	Integer bool      `json:"integer,omitempty"`
+	Counter uint64    `json:"counter,omitempty"`
+	Float   float64   `json:"float,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+func (*Rate) SampleConfig() string {
+	return sampleConfig
+}
+
+func (r *Rate) Init() error {
+	if len(r.Fields) == 0 {
+		r.Fields = []string{"*"}
+	}
+	f, err := filter.Compile(r.Fields)
+	if err != nil {
+		return fmt.Errorf("failed to create new field filter: %w", err)
+	}
+	r.accept = f
+
+	switch r.CounterType {
+	case "":
+		r.CounterType = "auto"
+	case "auto", "counter32", "counter64", "none":
+	default:
+		return fmt.Errorf("invalid counter type %q", r.CounterType)
+	}
+
+	if r.MaxGap < 0 {
+		return errors.New("max_gap must not be negative")
+	}
+
+	r.cache = make(state)
+
+	return nil
+}
+
+func (r *Rate) GetState() interface{} {
+	return r.cache
+}
+
+func (r *Rate) SetState(s interface{}) error {
+	cache, ok := s.(state)
+	if !ok {
+		return fmt.Errorf("state has wrong type %T", s)
+	}
+	if cache == nil {
+		cache = make(state)
+	}
+
+	// Restored entries are considered to be seen on startup to not remove
+	// them before the first metrics arrive
+	now := time.Now()
+	maps.DeleteFunc(cache, func(_ uint64, e *entry) bool {
+		return e == nil || len(e.Fields) == 0
+	})
+	for _, e := range cache {
+		e.seen = now
+	}
+	r.cache = cache
+
+	return nil
+}
+
+func (r *Rate) Apply(in ...telegraf.Metric) []telegraf.Metric {
+	now := time.Now()
+
+	for _, m := range in {
+		id := m.HashID()
+		stored, ok := r.cache[id]
+		if !ok {
+			stored = &entry{Fields: make(map[string]*value)}
+			r.cache[id] = stored
+		}
+		stored.seen = now
+
+		// Removing fields modifies the field list, so collect the fields
+		// without a rate and remove them after the loop
+		var remove []string
+		for _, field := range m.FieldList() {
+			if r.accept != nil && !r.accept.Match(field.Key) {
+				continue
+			}
+
+			current, ok := convert(field.Value, m.Time())
+			if !ok {
+				r.Log.Tracef("Skipping field %q with value %v (%T) as it is not a number", field.Key, field.Value, field.Value)
+				continue
+			}
+
+			previous := stored.Fields[field.Key]
+			rate, ok := r.rate(field.Key, previous, current)
+
+			// Keep the newer value for out-of-order metrics
+			if previous == nil || !current.Time.Before(previous.Time) {
+				stored.Fields[field.Key] = current
+			}
+
+			switch {
+			case ok && r.Suffix == "":
+				m.AddField(field.Key, rate)
+			case ok:
+				m.AddField(field.Key+r.Suffix, rate)
+			case r.Suffix == "":
+				remove = append(remove, field.Key)
+			}
+		}
+		for _, key := range remove {
+			m.RemoveField(key)
+		}
+	}
+
+	// Cleanup cache entries that are too old
+	if r.MaxGap > 0 {
+		threshold := now.Add(-time.Duration(r.MaxGap))
+		maps.DeleteFunc(r.cache, func(_ uint64, e *entry) bool {
+			return e.seen.Before(threshold)
+		})
+	}
+
+	return in
+}
+
+// rate computes the per-second rate between the previous and the current
+// value of the field taking counter wraps and resets into account
+func (r *Rate) rate(field string, previous, current *value) (float64, bool) {
+	if previous == nil {
+		return 0, false
+	}
+
+	elapsed := current.Time.Sub(previous.Time)
+	if elapsed <= 0 {
+		return 0, false
+	}
+	if r.MaxGap > 0 && elapsed > time.Duration(r.MaxGap) {
+		r.Log.Debugf("Skipping rate of field %q due to a gap of %s", field, elapsed)
+		return 0, false
+	}
+
+	delta, ok := r.delta(previous, current)
+	if !ok {
+		r.Log.Debugf("Counter reset detected for field %q", field)
+		return 0, false
+	}
+	return delta / elapsed.Seconds(), true
+}
+
+// delta computes the increase between the previous and current value. A
+// decrease is treated as counter wrap if the difference modulo the counter
+// range is below half of the range and as counter reset otherwise.
+func (r *Rate) delta(previous, current *value) (float64, bool) {
+	if !previous.Integer || !current.Integer {
+		prev, cur := previous.float(), current.float()
+		if cur < prev {
+			return 0, false
+		}
+		return cur - prev, true
+	}
+
+	if current.Counter >= previous.Counter {
+		return float64(current.Counter - previous.Counter), true
+	}
+
+	counterType := r.CounterType
+	if counterType == "auto" {
+		counterType = "counter64"
+		if previous.Counter <= math.MaxUint32 {
+			counterType = "counter32"
+		}
+	}
+
+	switch counterType {
+	case "counter32":
+		if previous.Counter > math.MaxUint32 {
+			return 0, false
+		}
+		wrapped := uint32(current.Counter - previous.Counter)
+		if wrapped >= 1<<31 {
+			return 0, false
+		}
+		return float64(wrapped), true
+	case "counter64":
+		wrapped := current.Counter - previous.Counter
+		if wrapped >= 1<<63 {
+			return 0, false
+		}
+		return float64(wrapped), true
+	}
+	return 0, false
+}
+
+func (v *value) float() float64 {
+	if v.Integer {
+		return float64(v.Counter)
+	}
+	return v.Float
+}
+
+func convert(in interface{}, t time.Time) (*value, bool) {
+	switch v := in.(type) {
+	case int64:
+		if v < 0 {
+			return &value{Float: float64(v), Time: t}, true
+		}
+		return &value{Integer: true, Counter: uint64(v), Time: t}, true
+	case uint64:
+		return &value{Integer: true, Counter: v, Time: t}, true
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, false
+		}
+		return &value{Float: v, Time: t}, true
+	default:
+		return nil, false
+	}
+}
+
+func init() {
+	processors.Add("rate", func() telegraf.Processor {
+		return &Rate{Suffix: "_rate"}
+	})
+}
@@ -0,0 +1,273 @@
+package rate
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/persister"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInit(t *testing.T) {
+	plugin := &Rate{CounterType: "counter16"}
+	require.ErrorContains(t, plugin.Init(), `invalid counter type "counter16"`)
+
+	plugin = &Rate{MaxGap: config.Duration(-time.Second)}
+	require.ErrorContains(t, plugin.Init(), "max_gap must not be negative")
+}
+
+func TestRate(t *testing.T) {
+	plugin := &Rate{
+		Fields: []string{"bytes_*"},
+		Suffix: "_rate",
+		Log:    testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	input := []telegraf.Metric{
+		metric.New("net", map[string]string{"interface": "eth0"}, map[string]interface{}{"bytes_recv": 100, "errors": 1}, time.Unix(0, 0)),
+		metric.New("net", map[string]string{"interface": "eth1"}, map[string]interface{}{"bytes_recv": 200}, time.Unix(0, 0)),
+		metric.New("net", map[string]string{"interface": "eth0"}, map[string]interface{}{"bytes_recv": 600, "errors": 5}, time.Unix(10, 0)),
+		metric.New("net", map[string]string{"interface": "eth1"}, map[string]interface{}{"bytes_recv": 700.5}, time.Unix(5, 0)),
+		metric.New("net", map[string]string{"interface": "eth0"}, map[string]interface{}{"bytes_recv": uint64(700), "bytes_sent": "n/a"}, time.Unix(20, 0)),
+	}
+	expected := []telegraf.Metric{
+		metric.New("net", map[string]string{"interface": "eth0"}, map[string]interface{}{"bytes_recv": 100, "errors": 1}, time.Unix(0, 0)),
+		metric.New("net", map[string]string{"interface": "eth1"}, map[string]interface{}{"bytes_recv": 200}, time.Unix(0, 0)),
+		metric.New(
+			"net",
+			map[string]string{"interface": "eth0"},
+			map[string]interface{}{"bytes_recv": 600, "bytes_recv_rate": 50.0, "errors": 5},
+			time.Unix(10, 0),
+		),
+		metric.New(
+			"net",
+			map[string]string{"interface": "eth1"},
+			map[string]interface{}{"bytes_recv": 700.5, "bytes_recv_rate": 100.1},
+			time.Unix(5, 0),
+		),
+		metric.New(
+			"net",
+			map[string]string{"interface": "eth0"},
+			map[string]interface{}{"bytes_recv": uint64(700), "bytes_recv_rate": 10.0, "bytes_sent": "n/a"},
+			time.Unix(20, 0),
+		),
+	}
+
+	actual := plugin.Apply(input...)
+	testutil.RequireMetricsEqual(t, expected, actual)
+}
+
+func TestReplace(t *testing.T) {
+	plugin := &Rate{Log: testutil.Logger{}}
+	require.NoError(t, plugin.Init())
+
+	input := []telegraf.Metric{
+		metric.New("diskio", map[string]string{"name": "sda"}, map[string]interface{}{"reads": 10, "name": "sda"}, time.Unix(0, 0)),
+		metric.New("diskio", map[string]string{"name": "sda"}, map[string]interface{}{"reads": 30, "name": "sda"}, time.Unix(2, 0)),
+	}
+	expected := []telegraf.Metric{
+		metric.New("diskio", map[string]string{"name": "sda"}, map[string]interface{}{"name": "sda"}, time.Unix(0, 0)),
+		metric.New("diskio", map[string]string{"name": "sda"}, map[string]interface{}{"reads": 10.0, "name": "sda"}, time.Unix(2, 0)),
+	}
+
+	actual := plugin.Apply(input...)
+	testutil.RequireMetricsEqual(t, expected, actual)
+}
+
+func TestReplaceMultipleFields(t *testing.T) {
+	plugin := &Rate{Log: testutil.Logger{}}
+	require.NoError(t, plugin.Init())
+
+	// All counters of the first sample are removed as no rate can be computed
+	fields := map[string]interface{}{"reads": 10, "writes": 20, "read_bytes": 1000, "write_bytes": 2000, "name": "sda"}
+	input := []telegraf.Metric{
+		metric.New("diskio", map[string]string{"name": "sda"}, fields, time.Unix(0, 0)),
+		metric.New(
+			"diskio",
+			map[string]string{"name": "sda"},
+			map[string]interface{}{"reads": 30, "writes": 24, "read_bytes": 3000, "write_bytes": 2000, "name": "sda"},
+			time.Unix(2, 0),
+		),
+	}
+	expected := []telegraf.Metric{
+		metric.New("diskio", map[string]string{"name": "sda"}, map[string]interface{}{"name": "sda"}, time.Unix(0, 0)),
+		metric.New(
+			"diskio",
+			map[string]string{"name": "sda"},
+			map[string]interface{}{"reads": 10.0, "writes": 2.0, "read_bytes": 1000.0, "write_bytes": 0.0, "name": "sda"},
+			time.Unix(2, 0),
+		),
+	}
+
+	actual := plugin.Apply(input...)
+	testutil.RequireMetricsEqual(t, expected, actual, testutil.SortMetrics())
+}
+
+func TestWrapsAndResets(t *testing.T) {
+	tests := []struct {
+		name        string
+		counterType string
+		previous    interface{}
+		current     interface{}
+		expected    float64
+		valid       bool
+	}{
+		{
+			name:     "32-bit wrap",
+			previous: uint64(math.MaxUint32 - 99),
+			current:  uint64(100),
+			expected: 20,
+			valid:    true,
+		},
+		{
+			name:     "32-bit reset",
+			previous: uint64(1_000_000),
+			current:  uint64(10),
+		},
+		{
+			name:     "64-bit wrap",
+			previous: uint64(math.MaxUint64 - 99),
+			current:  uint64(100),
+			expected: 20,
+			valid:    true,
+		},
+		{
+			name:     "64-bit reset",
+			previous: uint64(10_000_000_000),
+			current:  uint64(10),
+		},
+		{
+			name:        "forced 64-bit counter",
+			counterType: "counter64",
+			previous:    uint64(math.MaxUint32 - 99),
+			current:     uint64(100),
+		},
+		{
+			name:        "forced 32-bit counter with large value",
+			counterType: "counter32",
+			previous:    uint64(math.MaxUint32 + 1),
+			current:     uint64(100),
+		},
+		{
+			name:        "no wraps",
+			counterType: "none",
+			previous:    uint64(math.MaxUint32 - 99),
+			current:     uint64(100),
+		},
+		{
+			name:     "float reset",
+			previous: 1000.5,
+			current:  0.5,
+		},
+		{
+			name:     "large 64-bit counter",
+			previous: uint64(math.MaxUint64 - 1000),
+			current:  uint64(math.MaxUint64),
+			expected: 100,
+			valid:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := &Rate{
+				Suffix:      "_rate",
+				CounterType: tt.counterType,
+				Log:         testutil.Logger{},
+			}
+			require.NoError(t, plugin.Init())
+
+			input := []telegraf.Metric{
+				metric.New("test", map[string]string{}, map[string]interface{}{"value": tt.previous}, time.Unix(0, 0)),
+				metric.New("test", map[string]string{}, map[string]interface{}{"value": tt.current}, time.Unix(10, 0)),
+			}
+			actual := plugin.Apply(input...)
+			require.Len(t, actual, 2)
+
+			rate, found := actual[1].GetField("value_rate")
+			require.Equal(t, tt.valid, found)
+			if tt.valid {
+				require.InDelta(t, tt.expected, rate, 1e-9)
+			}
+		})
+	}
+}
+
+func TestMaxGap(t *testing.T) {
+	plugin := &Rate{
+		Suffix: "_rate",
+		MaxGap: config.Duration(time.Minute),
+		Log:    testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	input := []telegraf.Metric{
+		metric.New("test", map[string]string{}, map[string]interface{}{"value": 0}, time.Unix(0, 0)),
+		metric.New("test", map[string]string{}, map[string]interface{}{"value": 600}, time.Unix(600, 0)),
+		metric.New("test", map[string]string{}, map[string]interface{}{"value": 660}, time.Unix(660, 0)),
+		// Out-of-order values neither produce a rate nor replace the newer value
+		metric.New("test", map[string]string{}, map[string]interface{}{"value": 630}, time.Unix(630, 0)),
+		metric.New("test", map[string]string{}, map[string]interface{}{"value": 690}, time.Unix(690, 0)),
+	}
+	expected := []telegraf.Metric{
+		metric.New("test", map[string]string{}, map[string]interface{}{"value": 0}, time.Unix(0, 0)),
+		metric.New("test", map[string]string{}, map[string]interface{}{"value": 600}, time.Unix(600, 0)),
+		metric.New("test", map[string]string{}, map[string]interface{}{"value": 660, "value_rate": 1.0}, time.Unix(660, 0)),
+		metric.New("test", map[string]string{}, map[string]interface{}{"value": 630}, time.Unix(630, 0)),
+		metric.New("test", map[string]string{}, map[string]interface{}{"value": 690, "value_rate": 1.0}, time.Unix(690, 0)),
+	}
+
+	actual := plugin.Apply(input...)
+	testutil.RequireMetricsEqual(t, expected, actual)
+
+	// Series not seen within the gap are removed
+	for _, e := range plugin.cache {
+		e.seen = time.Now().Add(-2 * time.Minute)
+	}
+	plugin.Apply(metric.New("other", map[string]string{}, map[string]interface{}{"value": 1}, time.Unix(0, 0)))
+	require.Len(t, plugin.cache, 1)
+}
+
+func TestStatePersistence(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "state.json")
+
+	plugin := &Rate{Suffix: "_rate", Log: testutil.Logger{}}
+	require.NoError(t, plugin.Init())
+	plugin.Apply(
+		metric.New("snmp", map[string]string{"agent": "a"}, map[string]interface{}{"in": uint64(math.MaxUint64 - 9)}, time.Unix(0, 0)),
+		metric.New("snmp", map[string]string{"agent": "b"}, map[string]interface{}{"in": 10.5}, time.Unix(0, 0)),
+	)
+
+	store := &persister.Persister{Filename: filename}
+	require.NoError(t, store.Init())
+	require.NoError(t, store.Register("rate", plugin))
+	require.NoError(t, store.Store())
+
+	// The first metrics after the restore already produce a rate
+	restored := &Rate{Suffix: "_rate", Log: testutil.Logger{}}
+	require.NoError(t, restored.Init())
+	load := &persister.Persister{Filename: filename}
+	require.NoError(t, load.Init())
+	require.NoError(t, load.Register("rate", restored))
+	require.NoError(t, load.Load())
+
+	input := []telegraf.Metric{
+		metric.New("snmp", map[string]string{"agent": "a"}, map[string]interface{}{"in": uint64(math.MaxUint64)}, time.Unix(10, 0)),
+		metric.New("snmp", map[string]string{"agent": "b"}, map[string]interface{}{"in": 20.5}, time.Unix(10, 0)),
+	}
+	expected := []telegraf.Metric{
+		metric.New("snmp", map[string]string{"agent": "a"}, map[string]interface{}{"in": uint64(math.MaxUint64), "in_rate": 0.9}, time.Unix(10, 0)),
+		metric.New("snmp", map[string]string{"agent": "b"}, map[string]interface{}{"in": 20.5, "in_rate": 1.0}, time.Unix(10, 0)),
+	}
+
+	actual := restored.Apply(input...)
+	testutil.RequireMetricsEqual(t, expected, actual)
+}
@@ -0,0 +1,65 @@
+package redact
+
+import (
+	"net/netip"
+	"regexp"
+	"strings"
+)
+
+// detector finds sensitive data within a string, matches are only redacted
+// if they pass the optional validation
+type detector struct {
+	re    *regexp.Regexp
+	valid func(string) bool
+}
+
+var detectors = map[string]detector{
+	"email": {
+		re: regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(?:\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}`),
+	},
+	"credit_card": {
+		re:    regexp.MustCompile(`\b(?:\d[ \-]?){12,18}\d\b`),
+		valid: luhn,
+	},
+	"jwt": {
+		re: regexp.MustCompile(`\beyJ[A-Za-z0-9_\-]+\.eyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]*`),
+	},
+	"ipv4": {
+		re: regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`),
+		valid: func(s string) bool {
+			addr, err := netip.ParseAddr(s)
+			return err == nil && addr.Is4()
+		},
+	},
+	"ipv6": {
+		re: regexp.MustCompile(`(?i)[0-9a-f]{0,4}(?::[0-9a-f]{0,4}){2,7}(?:\.\d{1,3}){0,3}`),
+		valid: func(s string) bool {
+			addr, err := netip.ParseAddr(s)
+			return err == nil && addr.Is6()
+		},
+	},
+}
+
+// luhn checks the number for a valid Luhn checksum as used by credit cards
+// to avoid redacting arbitrary numbers
+func luhn(s string) bool {
+	digits := strings.NewReplacer(" ", "", "-", "").Replace(s)
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+
+	var sum int
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,271 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"net/netip"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/filter"
+	"github.com/influxdata/telegraf/plugins/processors"
+	"github.com/influxdata/telegraf/selfstat"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+type Redact struct {
+	Key   config.Secret   `toml:"key"`
+	Rules []*rule         `toml:"rule"`
+	Log   telegraf.Logger `toml:"-"`
+
+	hmac hash.Hash
+}
+
+type rule struct {
+	Tags        []string `toml:"tags"`
+	Fields      []string `toml:"fields"`
+	Mode        string   `toml:"mode"`
+	Detectors   []string `toml:"detectors"`
+	HMACLength  int      `toml:"hmac_length"`
+	IPv4Prefix  int      `toml:"ipv4_prefix"`
+	IPv6Prefix  int      `toml:"ipv6_prefix"`
+	MaskChar    string   `toml:"mask_char"`
+	MaskKeep    int      `toml:"mask_keep"`
+	Replacement string   `toml:"replacement"`
+
+	tagFilter   filter.Filter
+	fieldFilter filter.Filter
+	detectors   []detector
+	mask        rune
+	redact      func(string) (string, bool)
+	redactions  selfstat.Stat
+}
+
+func (*Redact) SampleConfig() string {
+	return sampleConfig
+}
+
+func (r *Redact) Init() error {
+	if len(r.Rules) == 0 {
+		return errors.New("no rules specified")
+	}
+
+	for i, rl := range r.Rules {
+		if err := r.setup(rl); err != nil {
+			return fmt.Errorf("rule %d: %w", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+func (r *Redact) setup(rl *rule) error {
+	if len(rl.Tags) == 0 && len(rl.Fields) == 0 {
+		return errors.New("no tags or fields specified")
+	}
+
+	var err error
+	if rl.tagFilter, err = filter.Compile(rl.Tags); err != nil {
+		return fmt.Errorf("creating tag filter failed: %w", err)
+	}
+	if rl.fieldFilter, err = filter.Compile(rl.Fields); err != nil {
+		return fmt.Errorf("creating field filter failed: %w", err)
+	}
+
+	for _, name := range rl.Detectors {
+		d, found := detectors[name]
+		if !found {
+			return fmt.Errorf("invalid detector %q", name)
+		}
+		rl.detectors = append(rl.detectors, d)
+	}
+
+	switch rl.Mode {
+	case "hmac":
+		if r.hmac == nil {
+			if r.Key.Empty() {
+				return errors.New("key required for mode \"hmac\"")
+			}
+			key, err := r.Key.Get()
+			if err != nil {
+				return fmt.Errorf("getting key failed: %w", err)
+			}
+			r.hmac = hmac.New(sha256.New, key.Bytes())
+			key.Destroy()
+		}
+		if rl.HMACLength < 0 || rl.HMACLength > 2*sha256.Size {
+			return fmt.Errorf("hmac_length must be between 0 and %d", 2*sha256.Size)
+		}
+		rl.redact = func(value string) (string, bool) {
+			return r.pseudonymize(value, rl.HMACLength), true
+		}
+	case "ip_prefix":
+		if rl.IPv4Prefix == 0 {
+			rl.IPv4Prefix = 24
+		}
+		if rl.IPv6Prefix == 0 {
+			rl.IPv6Prefix = 48
+		}
+		if rl.IPv4Prefix < 0 || rl.IPv4Prefix > 32 {
+			return errors.New("ipv4_prefix must be between 0 and 32")
+		}
+		if rl.IPv6Prefix < 0 || rl.IPv6Prefix > 128 {
+			return errors.New("ipv6_prefix must be between 0 and 128")
+		}
+		rl.redact = rl.truncate
+	case "mask":
+		if rl.MaskChar == "" {
+			rl.MaskChar = "*"
+		}
+		if utf8.RuneCountInString(rl.MaskChar) != 1 {
+			return errors.New("mask_char must be a single character")
+		}
+		if rl.MaskKeep < 0 {
+			return errors.New("mask_keep must not be negative")
+		}
+		rl.mask, _ = utf8.DecodeRuneInString(rl.MaskChar)
+		rl.redact = rl.maskValue
+	case "replace":
+		if rl.Replacement == "" {
+			rl.Replacement = "[REDACTED]"
+		}
+		rl.redact = func(string) (string, bool) {
+			return rl.Replacement, true
+		}
+	case "":
+		return errors.New("mode required")
+	default:
+		return fmt.Errorf("invalid mode %q", rl.Mode)
+	}
+
+	rl.redactions = selfstat.Register("redact", "redactions", map[string]string{"mode": rl.Mode})
+
+	return nil
+}
+
+func (r *Redact) Apply(in ...telegraf.Metric) []telegraf.Metric {
+	for _, m := range in {
+		for _, rl := range r.Rules {
+			for _, tag := range m.TagList() {
+				if rl.tagFilter == nil || !rl.tagFilter.Match(tag.Key) {
+					continue
+				}
+				if v, changed := rl.apply(tag.Value); changed {
+					m.AddTag(tag.Key, v)
+				}
+			}
+			for _, field := range m.FieldList() {
+				if rl.fieldFilter == nil || !rl.fieldFilter.Match(field.Key) {
+					continue
+				}
+				s, ok := field.Value.(string)
+				if !ok {
+					continue
+				}
+				if v, changed := rl.apply(s); changed {
+					m.AddField(field.Key, v)
+				}
+			}
+		}
+	}
+	return in
+}
+
+// apply redacts the whole value or, if detectors are configured, the
+// detected occurrences within the value
+func (rl *rule) apply(value string) (string, bool) {
+	if len(rl.detectors) == 0 {
+		v, ok := rl.redact(value)
+		if ok {
+			rl.redactions.Incr(1)
+		}
+		return v, ok
+	}
+
+	var changed bool
+	for _, d := range rl.detectors {
+		value = d.re.ReplaceAllStringFunc(value, func(match string) string {
+			if d.valid != nil && !d.valid(match) {
+				return match
+			}
+			v, ok := rl.redact(match)
+			if !ok {
+				return match
+			}
+			rl.redactions.Incr(1)
+			changed = true
+			return v
+		})
+	}
+	return value, changed
+}
+
+// pseudonymize returns the hex-encoded HMAC-SHA256 of the value, optionally
+// truncated to the given length, resulting in stable pseudonyms for the key
+func (r *Redact) pseudonymize(value string, length int) string {
+	r.hmac.Reset()
+	r.hmac.Write([]byte(value))
+	encoded := hex.EncodeToString(r.hmac.Sum(nil))
+	if length > 0 {
+		return encoded[:length]
+	}
+	return encoded
+}
+
+// truncate sets the host bits of an IP address to zero, values not being IP
+// addresses are not modified
+func (rl *rule) truncate(value string) (string, bool) {
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return value, false
+	}
+
+	bits := rl.IPv6Prefix
+	if addr.Is4() || addr.Is4In6() {
+		bits = rl.IPv4Prefix
+		if addr.Is4In6() {
+			bits += 96
+		}
+	}
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return value, false
+	}
+	return prefix.Addr().String(), true
+}
+
+// maskValue replaces letters and digits by the mask character keeping the
+// last characters as configured, while punctuation and whitespace are kept
+// to preserve the format of the value
+func (rl *rule) maskValue(value string) (string, bool) {
+	runes := []rune(value)
+	keep := rl.MaskKeep
+	for i := len(runes) - 1; i >= 0; i-- {
+		if !unicode.IsLetter(runes[i]) && !unicode.IsDigit(runes[i]) {
+			continue
+		}
+		if keep > 0 {
+			keep--
+			continue
+		}
+		runes[i] = rl.mask
+	}
+	masked := string(runes)
+	return masked, masked != value
+}
+
+func init() {
+	processors.Add("redact", func() telegraf.Processor {
+		return &Redact{}
+	})
+}
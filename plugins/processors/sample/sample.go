@@ -0,0 +1,310 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package sample
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/plugins/processors"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+type Sample struct {
+	Method          string          `toml:"method"`
+	Tags            []string        `toml:"tags"`
+	Ratio           float64         `toml:"ratio"`
+	RateLimit       int64           `toml:"rate_limit"`
+	RateLimitPeriod config.Duration `toml:"rate_limit_period"`
+	ReservoirSize   int             `toml:"reservoir_size"`
+	Window          config.Duration `toml:"window"`
+	RateTag         string          `toml:"rate_tag"`
+	Log             telegraf.Logger `toml:"-"`
+
+	threshold  uint64
+	limited    map[uint64]*limitedSeries
+	cleanup    time.Time
+	reservoirs map[uint64]*reservoir
+
+	acc    telegraf.Accumulator
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	sync.Mutex
+}
+
+// limitedSeries tracks the rate-limit of a series and the metrics seen and
+// kept to estimate the sampling rate
+type limitedSeries struct {
+	periodStart time.Time
+	lastSeen    time.Time
+	seen        int64
+	kept        int64
+	rate        float64
+}
+
+// reservoir contains a uniform random sample of the metrics of a series
+// seen within the current window
+type reservoir struct {
+	seen    int64
+	samples []telegraf.Metric
+}
+
+func (*Sample) SampleConfig() string {
+	return sampleConfig
+}
+
+func (s *Sample) Init() error {
+	switch s.Method {
+	case "", "hash":
+		s.Method = "hash"
+		if s.Ratio <= 0 || s.Ratio > 1 {
+			return errors.New("ratio must be greater than 0 and at most 1")
+		}
+		if s.Ratio < 1 {
+			s.threshold = uint64(s.Ratio * math.Exp2(64))
+		} else {
+			s.threshold = math.MaxUint64
+		}
+	case "rate_limit":
+		if s.RateLimit <= 0 {
+			return errors.New("rate_limit must be positive")
+		}
+		if s.RateLimitPeriod <= 0 {
+			return errors.New("rate_limit_period must be positive")
+		}
+		s.limited = make(map[uint64]*limitedSeries)
+	case "reservoir":
+		if s.ReservoirSize <= 0 {
+			return errors.New("reservoir_size must be positive")
+		}
+		if s.Window <= 0 {
+			return errors.New("window must be positive")
+		}
+		s.reservoirs = make(map[uint64]*reservoir)
+	default:
+		return fmt.Errorf("invalid method %q", s.Method)
+	}
+
+	// Sort the tags to get a consistent series identity independent of the
+	// configured order
+	s.Tags = slices.Clone(s.Tags)
+	slices.Sort(s.Tags)
+
+	return nil
+}
+
+func (s *Sample) Start(acc telegraf.Accumulator) error {
+	s.acc = acc
+	if s.Method != "reservoir" {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(time.Duration(s.Window))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.flush()
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (s *Sample) Add(m telegraf.Metric, acc telegraf.Accumulator) error {
+	id := s.seriesID(m)
+	switch s.Method {
+	case "hash":
+		if s.threshold != math.MaxUint64 && mix(id) >= s.threshold {
+			m.Drop()
+			return nil
+		}
+		s.addRate(m, 1/s.Ratio)
+		acc.AddMetric(m)
+	case "rate_limit":
+		if rate, keep := s.limit(id, time.Now()); keep {
+			s.addRate(m, rate)
+			acc.AddMetric(m)
+		} else {
+			m.Drop()
+		}
+	case "reservoir":
+		s.sample(id, m)
+	}
+
+	return nil
+}
+
+func (s *Sample) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+
+	// Emit the samples of the incomplete window to avoid losing them
+	if s.Method == "reservoir" {
+		s.flush()
+	}
+}
+
+// seriesID computes the identity of the series using the configured tags or,
+// if no tags are configured, the metric name and all tags. Metrics without
+// any of the configured tags share the same identity.
+func (s *Sample) seriesID(m telegraf.Metric) uint64 {
+	if len(s.Tags) == 0 {
+		return m.HashID()
+	}
+
+	h := fnv.New64a()
+	for _, key := range s.Tags {
+		value, ok := m.GetTag(key)
+		if !ok {
+			continue
+		}
+		h.Write([]byte(key))
+		h.Write([]byte("\n"))
+		h.Write([]byte(value))
+		h.Write([]byte("\n"))
+	}
+	return h.Sum64()
+}
+
+// limit checks the rate-limit of the series and returns the sampling rate
+// estimated from the previous period
+func (s *Sample) limit(id uint64, now time.Time) (float64, bool) {
+	period := time.Duration(s.RateLimitPeriod)
+
+	// Remove series not seen for a while, their limit is reset anyway and
+	// the rate estimation only uses the previous period
+	if now.Sub(s.cleanup) >= period {
+		for k, ls := range s.limited {
+			if now.Sub(ls.lastSeen) >= 2*period {
+				delete(s.limited, k)
+			}
+		}
+		s.cleanup = now
+	}
+
+	ls, found := s.limited[id]
+	if !found {
+		ls = &limitedSeries{periodStart: now, rate: 1}
+		s.limited[id] = ls
+	}
+	ls.lastSeen = now
+
+	if delta := now.Sub(ls.periodStart); delta >= period {
+		// Only use the previous period for estimating the rate if it directly
+		// precedes the current period, otherwise there were no metrics
+		ls.rate = 1
+		if delta < 2*period && ls.kept > 0 {
+			ls.rate = float64(ls.seen) / float64(ls.kept)
+		}
+		ls.periodStart = ls.periodStart.Add(delta.Truncate(period))
+		ls.seen, ls.kept = 0, 0
+	}
+	ls.seen++
+
+	if ls.kept >= s.RateLimit {
+		return 0, false
+	}
+	ls.kept++
+
+	return ls.rate, true
+}
+
+// sample adds the metric to the reservoir of the series using Algorithm R,
+// replacing a random sample once the reservoir is full
+func (s *Sample) sample(id uint64, m telegraf.Metric) {
+	s.Lock()
+	defer s.Unlock()
+
+	r, found := s.reservoirs[id]
+	if !found {
+		r = &reservoir{samples: make([]telegraf.Metric, 0, s.ReservoirSize)}
+		s.reservoirs[id] = r
+	}
+	r.seen++
+
+	if len(r.samples) < s.ReservoirSize {
+		r.samples = append(r.samples, m)
+		return
+	}
+	if idx := rand.Int64N(r.seen); idx < int64(s.ReservoirSize) {
+		r.samples[idx].Drop()
+		r.samples[idx] = m
+		return
+	}
+	m.Drop()
+}
+
+// flush emits the samples of all series in the order of their timestamps
+// and starts a new window
+func (s *Sample) flush() {
+	s.Lock()
+	reservoirs := s.reservoirs
+	s.reservoirs = make(map[uint64]*reservoir, len(reservoirs))
+	s.Unlock()
+
+	for _, r := range reservoirs {
+		rate := float64(r.seen) / float64(len(r.samples))
+		slices.SortStableFunc(r.samples, func(a, b telegraf.Metric) int {
+			return a.Time().Compare(b.Time())
+		})
+		for _, m := range r.samples {
+			s.addRate(m, rate)
+			s.acc.AddMetric(m)
+		}
+	}
+}
+
+func (s *Sample) addRate(m telegraf.Metric, rate float64) {
+	if s.RateTag == "" {
+		return
+	}
+	rate = math.Round(rate*1000) / 1000
+	m.AddTag(s.RateTag, strconv.FormatFloat(rate, 'f', -1, 64))
+}
+
+// mix improves the distribution of the series hash using the finalizer of
+// MurmurHash3 as similar series, e.g. consecutive IP addresses, otherwise
+// result in similar hash values
+func mix(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+func init() {
+	processors.AddStreaming("sample", func() telegraf.StreamingProcessor {
+		return &Sample{
+			RateLimitPeriod: config.Duration(time.Minute),
+			Window:          config.Duration(time.Minute),
+			RateTag:         "sample_rate",
+		}
+	})
+}
@@ -0,0 +1,313 @@
+package sample
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInit(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *Sample
+		expected string
+	}{
+		{
+			name:     "invalid method",
+			plugin:   &Sample{Method: "random"},
+			expected: `invalid method "random"`,
+		},
+		{
+			name:     "missing ratio",
+			plugin:   &Sample{Method: "hash"},
+			expected: "ratio must be greater than 0 and at most 1",
+		},
+		{
+			name:     "missing rate limit",
+			plugin:   &Sample{Method: "rate_limit"},
+			expected: "rate_limit must be positive",
+		},
+		{
+			name: "missing rate limit period",
+			plugin: &Sample{
+				Method:    "rate_limit",
+				RateLimit: 10,
+			},
+			expected: "rate_limit_period must be positive",
+		},
+		{
+			name:     "missing reservoir size",
+			plugin:   &Sample{Method: "reservoir", Window: config.Duration(time.Minute)},
+			expected: "reservoir_size must be positive",
+		},
+		{
+			name:     "missing window",
+			plugin:   &Sample{Method: "reservoir", ReservoirSize: 10},
+			expected: "window must be positive",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestHash(t *testing.T) {
+	plugin := &Sample{
+		Tags:    []string{"src", "dst"},
+		Ratio:   0.1,
+		RateTag: "sample_rate",
+		Log:     testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	// Use multiple metrics per series with differing measurements to make
+	// sure the decision is consistent for the series
+	kept := make(map[string]int)
+	for i := 0; i < 3000; i++ {
+		m := metric.New(
+			fmt.Sprintf("measurement%d", i%3),
+			map[string]string{
+				"src":  fmt.Sprintf("192.0.2.%d", i/3%250),
+				"dst":  fmt.Sprintf("198.51.100.%d", i/3/250),
+				"host": fmt.Sprintf("host%d", i%7),
+			},
+			map[string]interface{}{"bytes": i},
+			time.Unix(int64(i), 0),
+		)
+		require.NoError(t, plugin.Add(m, &acc))
+	}
+
+	for _, m := range acc.GetTelegrafMetrics() {
+		require.Equal(t, "10", m.Tags()["sample_rate"])
+		kept[m.Tags()["src"]+"-"+m.Tags()["dst"]]++
+	}
+	// Every kept series must be kept for all of its metrics
+	for series, count := range kept {
+		require.Equalf(t, 3, count, "series %q", series)
+	}
+	// The number of series kept should roughly match the ratio
+	require.InDelta(t, 100, len(kept), 30)
+
+}
+
+func TestMissingTags(t *testing.T) {
+	plugin := &Sample{
+		Method:          "rate_limit",
+		Tags:            []string{"src", "dst"},
+		RateLimit:       2,
+		RateLimitPeriod: config.Duration(time.Hour),
+		Log:             testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	// Metrics without any of the tags must be sampled as a single series
+	for i := 0; i < 5; i++ {
+		m := metric.New(
+			fmt.Sprintf("measurement%d", i),
+			map[string]string{"host": fmt.Sprintf("host%d", i)},
+			map[string]interface{}{"value": i},
+			time.Unix(int64(i), 0),
+		)
+		require.NoError(t, plugin.Add(m, &acc))
+	}
+	require.Len(t, acc.GetTelegrafMetrics(), 2)
+}
+
+func TestHashConsistent(t *testing.T) {
+	input := metric.New(
+		"netflow",
+		map[string]string{"src": "192.0.2.1", "dst": "198.51.100.7"},
+		map[string]interface{}{"bytes": 1200},
+		time.Unix(0, 0),
+	)
+
+	// The decision must neither depend on the instance nor on the order of
+	// the configured tags
+	var results []int
+	for _, tags := range [][]string{{"src", "dst"}, {"dst", "src"}} {
+		plugin := &Sample{Tags: tags, Ratio: 0.5, Log: testutil.Logger{}}
+		require.NoError(t, plugin.Init())
+
+		var acc testutil.Accumulator
+		require.NoError(t, plugin.Start(&acc))
+		require.NoError(t, plugin.Add(input.Copy(), &acc))
+		plugin.Stop()
+		results = append(results, len(acc.GetTelegrafMetrics()))
+	}
+	require.Equal(t, results[0], results[1])
+}
+
+func TestRateLimit(t *testing.T) {
+	plugin := &Sample{
+		Method:          "rate_limit",
+		RateLimit:       2,
+		RateLimitPeriod: config.Duration(time.Hour),
+		RateTag:         "sample_rate",
+		Log:             testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	for i := 0; i < 5; i++ {
+		for _, host := range []string{"a", "b"} {
+			m := metric.New("test", map[string]string{"host": host}, map[string]interface{}{"value": i}, time.Unix(int64(i), 0))
+			require.NoError(t, plugin.Add(m, &acc))
+		}
+	}
+
+	expected := []telegraf.Metric{
+		metric.New("test", map[string]string{"host": "a", "sample_rate": "1"}, map[string]interface{}{"value": 0}, time.Unix(0, 0)),
+		metric.New("test", map[string]string{"host": "b", "sample_rate": "1"}, map[string]interface{}{"value": 0}, time.Unix(0, 0)),
+		metric.New("test", map[string]string{"host": "a", "sample_rate": "1"}, map[string]interface{}{"value": 1}, time.Unix(1, 0)),
+		metric.New("test", map[string]string{"host": "b", "sample_rate": "1"}, map[string]interface{}{"value": 1}, time.Unix(1, 0)),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics())
+}
+
+func TestRateLimitRateEstimation(t *testing.T) {
+	plugin := &Sample{
+		Method:          "rate_limit",
+		RateLimit:       2,
+		RateLimitPeriod: config.Duration(time.Minute),
+		Log:             testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	start := time.Now()
+	var kept int
+	for i := 0; i < 8; i++ {
+		if rate, keep := plugin.limit(42, start.Add(time.Duration(i)*time.Second)); keep {
+			require.InDelta(t, 1.0, rate, 1e-9)
+			kept++
+		}
+	}
+	require.Equal(t, 2, kept)
+
+	// The rate of the previous period is used in the next period
+	rate, keep := plugin.limit(42, start.Add(time.Minute))
+	require.True(t, keep)
+	require.InDelta(t, 4.0, rate, 1e-9)
+
+	// The rate is reset if the previous period did not contain any metrics
+	rate, keep = plugin.limit(42, start.Add(3*time.Minute))
+	require.True(t, keep)
+	require.InDelta(t, 1.0, rate, 1e-9)
+
+	// Series not seen for two periods are removed
+	_, keep = plugin.limit(43, start.Add(6*time.Minute))
+	require.True(t, keep)
+	require.NotContains(t, plugin.limited, uint64(42))
+	require.Contains(t, plugin.limited, uint64(43))
+}
+
+func TestReservoir(t *testing.T) {
+	plugin := &Sample{
+		Method:        "reservoir",
+		ReservoirSize: 3,
+		Window:        config.Duration(time.Hour),
+		RateTag:       "sample_rate",
+		Log:           testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+
+	for i := 0; i < 12; i++ {
+		m := metric.New("test", map[string]string{"host": "a"}, map[string]interface{}{"value": i}, time.Unix(int64(i), 0))
+		require.NoError(t, plugin.Add(m, &acc))
+	}
+	for i := 0; i < 2; i++ {
+		m := metric.New("test", map[string]string{"host": "b"}, map[string]interface{}{"value": i}, time.Unix(int64(i), 0))
+		require.NoError(t, plugin.Add(m, &acc))
+	}
+
+	// Metrics are only emitted at the end of the window
+	require.Empty(t, acc.GetTelegrafMetrics())
+	plugin.flush()
+
+	var samples []telegraf.Metric
+	for _, m := range acc.GetTelegrafMetrics() {
+		switch m.Tags()["host"] {
+		case "a":
+			require.Equal(t, "4", m.Tags()["sample_rate"])
+			samples = append(samples, m)
+		case "b":
+			require.Equal(t, "1", m.Tags()["sample_rate"])
+		}
+	}
+	require.Len(t, acc.GetTelegrafMetrics(), 5)
+	require.Len(t, samples, 3)
+
+	// Samples of a series are emitted in the order of their timestamp
+	for i := 1; i < len(samples); i++ {
+		require.True(t, samples[i-1].Time().Before(samples[i].Time()))
+	}
+
+	// Samples of an incomplete window are emitted on stop
+	acc.ClearMetrics()
+	m := metric.New("test", map[string]string{"host": "a"}, map[string]interface{}{"value": 42}, time.Unix(42, 0))
+	require.NoError(t, plugin.Add(m, &acc))
+	plugin.Stop()
+	require.Len(t, acc.GetTelegrafMetrics(), 1)
+}
+
+func TestTracking(t *testing.T) {
+	var mu sync.Mutex
+	delivered := make([]telegraf.DeliveryInfo, 0, 20)
+	notify := func(di telegraf.DeliveryInfo) {
+		mu.Lock()
+		defer mu.Unlock()
+		delivered = append(delivered, di)
+	}
+
+	plugin := &Sample{
+		Method:        "reservoir",
+		ReservoirSize: 5,
+		Window:        config.Duration(time.Hour),
+		Log:           testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+
+	for i := 0; i < 20; i++ {
+		m := metric.New("test", map[string]string{}, map[string]interface{}{"value": i}, time.Unix(int64(i), 0))
+		tm, _ := metric.WithTracking(m, notify)
+		require.NoError(t, plugin.Add(tm, &acc))
+	}
+	plugin.Stop()
+
+	// Dropped metrics must be accepted, kept ones once they are delivered
+	for _, m := range acc.GetTelegrafMetrics() {
+		m.Accept()
+	}
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(delivered) == 20
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,203 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package sketch_merge
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DataDog/sketches-go/ddsketch"
+	"github.com/axiomhq/hyperloglog"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	common "github.com/influxdata/telegraf/plugins/common/sketch"
+	"github.com/influxdata/telegraf/plugins/processors"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+type SketchMerge struct {
+	Period    config.Duration `toml:"period"`
+	MergeTags []string        `toml:"merge_tags"`
+	Quantiles []float64       `toml:"quantiles"`
+	Log       telegraf.Logger `toml:"-"`
+
+	suffixes []string
+	groups   map[uint64]*group
+
+	acc    telegraf.Accumulator
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	sync.Mutex
+}
+
+// group contains the merged sketches of all metrics of a series within the
+// current period
+type group struct {
+	name      string
+	tags      map[string]string
+	time      time.Time
+	quantiles map[string]*ddsketch.DDSketch
+	distinct  map[string]*hyperloglog.Sketch
+}
+
+func (*SketchMerge) SampleConfig() string {
+	return sampleConfig
+}
+
+func (s *SketchMerge) Init() error {
+	if s.Period <= 0 {
+		return errors.New("period must be positive")
+	}
+
+	suffixes, err := common.QuantileSuffixes(s.Quantiles)
+	if err != nil {
+		return err
+	}
+	s.suffixes = suffixes
+	s.groups = make(map[uint64]*group)
+
+	return nil
+}
+
+func (s *SketchMerge) Start(acc telegraf.Accumulator) error {
+	s.acc = acc
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(time.Duration(s.Period))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.flush()
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (s *SketchMerge) Add(m telegraf.Metric, acc telegraf.Accumulator) error {
+	// Decode the sketches first to pass on metrics without any sketch
+	quantiles := make(map[string]*ddsketch.DDSketch)
+	distinct := make(map[string]*hyperloglog.Sketch)
+	for _, field := range m.FieldList() {
+		v, ok := field.Value.(string)
+		if !ok {
+			continue
+		}
+		if key, found := strings.CutSuffix(field.Key, common.QuantileSuffix); found {
+			sketch, err := common.DecodeDDSketch(v)
+			if err != nil {
+				s.Log.Errorf("Decoding sketch of field %q failed: %v", field.Key, err)
+				continue
+			}
+			quantiles[key] = sketch
+		} else if key, found := strings.CutSuffix(field.Key, common.DistinctSuffix); found {
+			sketch, err := common.DecodeHLL(v)
+			if err != nil {
+				s.Log.Errorf("Decoding sketch of field %q failed: %v", field.Key, err)
+				continue
+			}
+			distinct[key] = sketch
+		}
+	}
+	if len(quantiles) == 0 && len(distinct) == 0 {
+		acc.AddMetric(m)
+		return nil
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	id := common.SeriesID(m, s.MergeTags)
+	g, found := s.groups[id]
+	if !found {
+		g = &group{
+			name:      m.Name(),
+			tags:      common.SeriesTags(m, s.MergeTags),
+			quantiles: make(map[string]*ddsketch.DDSketch),
+			distinct:  make(map[string]*hyperloglog.Sketch),
+		}
+		s.groups[id] = g
+	}
+	if m.Time().After(g.time) {
+		g.time = m.Time()
+	}
+
+	for key, sketch := range quantiles {
+		merged, found := g.quantiles[key]
+		if !found {
+			g.quantiles[key] = sketch
+			continue
+		}
+		if err := merged.MergeWith(sketch); err != nil {
+			s.Log.Errorf("Merging sketch of field %q failed: %v", key+common.QuantileSuffix, err)
+		}
+	}
+	for key, sketch := range distinct {
+		merged, found := g.distinct[key]
+		if !found {
+			g.distinct[key] = sketch
+			continue
+		}
+		if err := merged.Merge(sketch); err != nil {
+			s.Log.Errorf("Merging sketch of field %q failed: %v", key+common.DistinctSuffix, err)
+		}
+	}
+
+	// The metric is replaced by the merged one, so it is dropped instead of
+	// being passed on
+	m.Drop()
+
+	return nil
+}
+
+func (s *SketchMerge) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+
+	// Emit the sketches of the incomplete period to avoid losing them
+	s.flush()
+}
+
+// flush emits the merged sketches of all series and starts a new period
+func (s *SketchMerge) flush() {
+	s.Lock()
+	groups := s.groups
+	s.groups = make(map[uint64]*group, len(groups))
+	s.Unlock()
+
+	for _, g := range groups {
+		fields, err := common.Fields(g.quantiles, g.distinct, s.Quantiles, s.suffixes)
+		if err != nil {
+			s.acc.AddError(err)
+			continue
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		s.acc.AddFields(g.name, fields, g.tags, g.time)
+	}
+}
+
+func init() {
+	processors.AddStreaming("sketch_merge", func() telegraf.StreamingProcessor {
+		return &SketchMerge{Period: config.Duration(30 * time.Second)}
+	})
+}
@@ -0,0 +1,147 @@
+package sketch_merge
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/DataDog/sketches-go/ddsketch"
+	"github.com/axiomhq/hyperloglog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	common "github.com/influxdata/telegraf/plugins/common/sketch"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInit(t *testing.T) {
+	plugin := &SketchMerge{}
+	require.ErrorContains(t, plugin.Init(), "period must be positive")
+
+	plugin = &SketchMerge{Period: config.Duration(time.Second), Quantiles: []float64{-1}}
+	require.ErrorContains(t, plugin.Init(), "quantile -1 out of range")
+}
+
+func TestMerge(t *testing.T) {
+	plugin := &SketchMerge{
+		Period:    config.Duration(time.Hour),
+		MergeTags: []string{"host"},
+		Quantiles: []float64{0.5, 0.9},
+		Log:       testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+
+	// Host "a" sees values 1 to 50 and users 0 to 599, host "b" sees values
+	// 51 to 100 and users 400 to 999
+	input := []telegraf.Metric{
+		sketchMetric(t, "a", 1, 50, 0, 600, time.Unix(10, 0)),
+		sketchMetric(t, "b", 51, 100, 400, 1000, time.Unix(20, 0)),
+		metric.New("cpu", map[string]string{"host": "a"}, map[string]interface{}{"usage": 42.0}, time.Unix(10, 0)),
+	}
+	for _, m := range input {
+		require.NoError(t, plugin.Add(m, &acc))
+	}
+
+	// Metrics without sketches are passed on immediately
+	require.Len(t, acc.GetTelegrafMetrics(), 1)
+	plugin.Stop()
+
+	metrics := acc.GetTelegrafMetrics()
+	require.Len(t, metrics, 2)
+	testutil.RequireMetricEqual(t, input[2], metrics[0])
+
+	// The merged metric contains the sketches and recomputed values
+	merged := metrics[1]
+	require.Equal(t, "http", merged.Name())
+	require.Equal(t, map[string]string{"path": "/"}, merged.Tags())
+	require.Equal(t, time.Unix(20, 0), merged.Time())
+	require.Len(t, merged.FieldList(), 5)
+
+	median, found := merged.GetField("latency_050")
+	require.True(t, found)
+	require.InEpsilon(t, 50.0, median, 0.01)
+	p90, found := merged.GetField("latency_090")
+	require.True(t, found)
+	require.InEpsilon(t, 90.0, p90, 0.01)
+	distinct, found := merged.GetField("user_distinct")
+	require.True(t, found)
+	require.InEpsilon(t, 1000, distinct, 0.02)
+
+	// The merged sketches can be merged again
+	encoded, found := merged.GetField("latency_ddsketch")
+	require.True(t, found)
+	sketch, err := common.DecodeDDSketch(encoded.(string))
+	require.NoError(t, err)
+	require.InDelta(t, 100.0, sketch.GetCount(), 1e-9)
+}
+
+func TestInvalidSketch(t *testing.T) {
+	plugin := &SketchMerge{
+		Period: config.Duration(time.Hour),
+		Log:    testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+
+	// Metrics with invalid sketches only are passed on unmodified
+	input := metric.New("http", map[string]string{}, map[string]interface{}{"latency_ddsketch": "invalid"}, time.Unix(0, 0))
+	require.NoError(t, plugin.Add(input, &acc))
+	plugin.Stop()
+
+	testutil.RequireMetricsEqual(t, []telegraf.Metric{input}, acc.GetTelegrafMetrics())
+}
+
+func TestTracking(t *testing.T) {
+	plugin := &SketchMerge{
+		Period: config.Duration(time.Hour),
+		Log:    testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	var delivered int
+	input, _ := metric.WithTracking(sketchMetric(t, "a", 1, 10, 0, 10, time.Unix(0, 0)), func(telegraf.DeliveryInfo) {
+		delivered++
+	})
+
+	// Merged metrics are dropped immediately to finish their tracking
+	require.NoError(t, plugin.Add(input, &acc))
+	require.Equal(t, 1, delivered)
+}
+
+func sketchMetric(t *testing.T, host string, from, to, userFrom, userTo int, ts time.Time) telegraf.Metric {
+	t.Helper()
+
+	quantiles, err := ddsketch.LogCollapsingLowestDenseDDSketch(0.01, 2048)
+	require.NoError(t, err)
+	for i := from; i <= to; i++ {
+		require.NoError(t, quantiles.Add(float64(i)))
+	}
+	encodedQuantiles, err := common.EncodeDDSketch(quantiles)
+	require.NoError(t, err)
+
+	distinct := hyperloglog.New14()
+	for i := userFrom; i < userTo; i++ {
+		distinct.Insert([]byte("user" + strconv.Itoa(i)))
+	}
+	encodedDistinct, err := common.EncodeHLL(distinct)
+	require.NoError(t, err)
+
+	fields := map[string]interface{}{
+		"latency_ddsketch": encodedQuantiles,
+		"latency_050":      0.0,
+		"user_hll":         encodedDistinct,
+		"user_distinct":    distinct.Estimate(),
+	}
+	return metric.New("http", map[string]string{"host": host, "path": "/"}, fields, ts)
+}
@@ -0,0 +1,461 @@
+package wasm
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/tetratelabs/wazero/api"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+)
+
+// Status codes returned by the host functions, non-negative values indicate
+// success and may carry a length or handle
+const (
+	statusOK           int32 = 0
+	errInvalidHandle   int32 = -1
+	errNotFound        int32 = -2
+	errMemoryAccess    int32 = -3
+	errTypeMismatch    int32 = -4
+	errInvalidArgument int32 = -5
+)
+
+// Field types returned by the "field_type" host function
+const (
+	typeFloat int32 = iota + 1
+	typeInteger
+	typeUnsigned
+	typeBool
+	typeString
+)
+
+// call holds the metrics accessible by the module during a single call of the
+// process function with handle zero being the input metric
+type call struct {
+	handles []*handle
+}
+
+type handle struct {
+	metric  telegraf.Metric
+	emitted bool
+	dropped bool
+}
+
+// get returns the metric for the given handle if it is still accessible
+func (c *call) get(h uint32) telegraf.Metric {
+	if c == nil || h >= uint32(len(c.handles)) {
+		return nil
+	}
+	if c.handles[h].emitted || c.handles[h].dropped {
+		return nil
+	}
+	return c.handles[h].metric
+}
+
+func (c *call) add(m telegraf.Metric) int32 {
+	c.handles = append(c.handles, &handle{metric: m})
+	return int32(len(c.handles) - 1)
+}
+
+// discard all metrics created during the call
+func (c *call) discard() {
+	for _, h := range c.handles[1:] {
+		h.metric.Drop()
+	}
+}
+
+// read a string from the module's memory
+func read(mod api.Module, ptr, size uint32) (string, bool) {
+	mem := mod.Memory()
+	if mem == nil {
+		return "", false
+	}
+	buf, ok := mem.Read(ptr, size)
+	if !ok {
+		return "", false
+	}
+	return string(buf), true
+}
+
+// write the string to the given buffer of the module's memory. The length
+// of the string is returned and the buffer is left untouched if it is too
+// small to allow the module to retry with a sufficient buffer.
+func write(mod api.Module, ptr, size uint32, s string) int32 {
+	if uint64(len(s)) > uint64(size) {
+		return int32(len(s))
+	}
+	mem := mod.Memory()
+	if mem == nil || !mem.WriteString(ptr, s) {
+		return errMemoryAccess
+	}
+	return int32(len(s))
+}
+
+func writeUint64(mod api.Module, ptr uint32, v uint64) int32 {
+	mem := mod.Memory()
+	if mem == nil || !mem.WriteUint64Le(ptr, v) {
+		return errMemoryAccess
+	}
+	return statusOK
+}
+
+func (w *Wasm) instantiateHost(ctx context.Context) error {
+	builder := w.runtime.NewHostModuleBuilder("telegraf")
+	export := func(name string, fn interface{}) {
+		builder.NewFunctionBuilder().WithFunc(fn).Export(name)
+	}
+
+	// Metric handling
+	export("metric_new", func(_ context.Context, mod api.Module, ptr, size uint32) int32 {
+		if w.current == nil {
+			return errInvalidHandle
+		}
+		name, ok := read(mod, ptr, size)
+		if !ok {
+			return errMemoryAccess
+		}
+		if name == "" {
+			return errInvalidArgument
+		}
+		return w.current.add(metric.New(name, nil, nil, w.current.handles[0].metric.Time()))
+	})
+	export("metric_copy", func(_ context.Context, _ api.Module, h uint32) int32 {
+		m := w.current.get(h)
+		if m == nil {
+			return errInvalidHandle
+		}
+		return w.current.add(m.Copy())
+	})
+	export("metric_emit", func(_ context.Context, _ api.Module, h uint32) int32 {
+		// The input metric is passed on unless dropped
+		if h == 0 || w.current.get(h) == nil {
+			return errInvalidHandle
+		}
+		w.current.handles[h].emitted = true
+		return statusOK
+	})
+	export("metric_drop", func(_ context.Context, _ api.Module, h uint32) int32 {
+		if w.current.get(h) == nil {
+			return errInvalidHandle
+		}
+		w.current.handles[h].dropped = true
+		return statusOK
+	})
+
+	// Name and time
+	export("metric_name", func(_ context.Context, mod api.Module, h, ptr, size uint32) int32 {
+		m := w.current.get(h)
+		if m == nil {
+			return errInvalidHandle
+		}
+		return write(mod, ptr, size, m.Name())
+	})
+	export("metric_set_name", func(_ context.Context, mod api.Module, h, ptr, size uint32) int32 {
+		m := w.current.get(h)
+		if m == nil {
+			return errInvalidHandle
+		}
+		name, ok := read(mod, ptr, size)
+		if !ok {
+			return errMemoryAccess
+		}
+		if name == "" {
+			return errInvalidArgument
+		}
+		m.SetName(name)
+		return statusOK
+	})
+	export("metric_time", func(_ context.Context, mod api.Module, h, ptr uint32) int32 {
+		m := w.current.get(h)
+		if m == nil {
+			return errInvalidHandle
+		}
+		return writeUint64(mod, ptr, uint64(m.Time().UnixNano()))
+	})
+	export("metric_set_time", func(_ context.Context, _ api.Module, h uint32, ns int64) int32 {
+		m := w.current.get(h)
+		if m == nil {
+			return errInvalidHandle
+		}
+		m.SetTime(time.Unix(0, ns))
+		return statusOK
+	})
+
+	// Tags
+	export("tag_count", func(_ context.Context, _ api.Module, h uint32) int32 {
+		m := w.current.get(h)
+		if m == nil {
+			return errInvalidHandle
+		}
+		return int32(len(m.TagList()))
+	})
+	export("tag_key", func(_ context.Context, mod api.Module, h, idx, ptr, size uint32) int32 {
+		m := w.current.get(h)
+		if m == nil {
+			return errInvalidHandle
+		}
+		tags := m.TagList()
+		if idx >= uint32(len(tags)) {
+			return errNotFound
+		}
+		return write(mod, ptr, size, tags[idx].Key)
+	})
+	export("tag_get", func(_ context.Context, mod api.Module, h, kptr, klen, ptr, size uint32) int32 {
+		m := w.current.get(h)
+		if m == nil {
+			return errInvalidHandle
+		}
+		key, ok := read(mod, kptr, klen)
+		if !ok {
+			return errMemoryAccess
+		}
+		value, found := m.GetTag(key)
+		if !found {
+			return errNotFound
+		}
+		return write(mod, ptr, size, value)
+	})
+	export("tag_set", func(_ context.Context, mod api.Module, h, kptr, klen, vptr, vlen uint32) int32 {
+		m := w.current.get(h)
+		if m == nil {
+			return errInvalidHandle
+		}
+		key, ok := read(mod, kptr, klen)
+		if !ok {
+			return errMemoryAccess
+		}
+		value, ok := read(mod, vptr, vlen)
+		if !ok {
+			return errMemoryAccess
+		}
+		if key == "" {
+			return errInvalidArgument
+		}
+		m.AddTag(key, value)
+		return statusOK
+	})
+	export("tag_remove", func(_ context.Context, mod api.Module, h, kptr, klen uint32) int32 {
+		m := w.current.get(h)
+		if m == nil {
+			return errInvalidHandle
+		}
+		key, ok := read(mod, kptr, klen)
+		if !ok {
+			return errMemoryAccess
+		}
+		if !m.HasTag(key) {
+			return errNotFound
+		}
+		m.RemoveTag(key)
+		return statusOK
+	})
+
+	// Fields
+	export("field_count", func(_ context.Context, _ api.Module, h uint32) int32 {
+		m := w.current.get(h)
+		if m == nil {
+			return errInvalidHandle
+		}
+		return int32(len(m.FieldList()))
+	})
+	export("field_key", func(_ context.Context, mod api.Module, h, idx, ptr, size uint32) int32 {
+		m := w.current.get(h)
+		if m == nil {
+			return errInvalidHandle
+		}
+		fields := m.FieldList()
+		if idx >= uint32(len(fields)) {
+			return errNotFound
+		}
+		return write(mod, ptr, size, fields[idx].Key)
+	})
+	export("field_type", func(_ context.Context, mod api.Module, h, kptr, klen uint32) int32 {
+		v, status := w.field(mod, h, kptr, klen)
+		if status != statusOK {
+			return status
+		}
+		switch v.(type) {
+		case float64:
+			return typeFloat
+		case int64:
+			return typeInteger
+		case uint64:
+			return typeUnsigned
+		case bool:
+			return typeBool
+		case string:
+			return typeString
+		}
+		return errTypeMismatch
+	})
+	export("field_get_float", func(_ context.Context, mod api.Module, h, kptr, klen, ptr uint32) int32 {
+		v, status := w.field(mod, h, kptr, klen)
+		if status != statusOK {
+			return status
+		}
+		switch v := v.(type) {
+		case float64:
+			return writeUint64(mod, ptr, math.Float64bits(v))
+		case int64:
+			return writeUint64(mod, ptr, math.Float64bits(float64(v)))
+		case uint64:
+			return writeUint64(mod, ptr, math.Float64bits(float64(v)))
+		}
+		return errTypeMismatch
+	})
+	export("field_get_int", func(_ context.Context, mod api.Module, h, kptr, klen, ptr uint32) int32 {
+		v, status := w.field(mod, h, kptr, klen)
+		if status != statusOK {
+			return status
+		}
+		switch v := v.(type) {
+		case int64:
+			return writeUint64(mod, ptr, uint64(v))
+		case uint64:
+			if v <= math.MaxInt64 {
+				return writeUint64(mod, ptr, v)
+			}
+		}
+		return errTypeMismatch
+	})
+	export("field_get_uint", func(_ context.Context, mod api.Module, h, kptr, klen, ptr uint32) int32 {
+		v, status := w.field(mod, h, kptr, klen)
+		if status != statusOK {
+			return status
+		}
+		switch v := v.(type) {
+		case uint64:
+			return writeUint64(mod, ptr, v)
+		case int64:
+			if v >= 0 {
+				return writeUint64(mod, ptr, uint64(v))
+			}
+		}
+		return errTypeMismatch
+	})
+	export("field_get_bool", func(_ context.Context, mod api.Module, h, kptr, klen, ptr uint32) int32 {
+		v, status := w.field(mod, h, kptr, klen)
+		if status != statusOK {
+			return status
+		}
+		b, ok := v.(bool)
+		if !ok {
+			return errTypeMismatch
+		}
+		var value uint32
+		if b {
+			value = 1
+		}
+		mem := mod.Memory()
+		if mem == nil || !mem.WriteUint32Le(ptr, value) {
+			return errMemoryAccess
+		}
+		return statusOK
+	})
+	export("field_get_string", func(_ context.Context, mod api.Module, h, kptr, klen, ptr, size uint32) int32 {
+		v, status := w.field(mod, h, kptr, klen)
+		if status != statusOK {
+			return status
+		}
+		s, ok := v.(string)
+		if !ok {
+			return errTypeMismatch
+		}
+		return write(mod, ptr, size, s)
+	})
+	export("field_set_float", func(_ context.Context, mod api.Module, h, kptr, klen uint32, v float64) int32 {
+		return w.setField(mod, h, kptr, klen, v)
+	})
+	export("field_set_int", func(_ context.Context, mod api.Module, h, kptr, klen uint32, v int64) int32 {
+		return w.setField(mod, h, kptr, klen, v)
+	})
+	export("field_set_uint", func(_ context.Context, mod api.Module, h, kptr, klen uint32, v uint64) int32 {
+		return w.setField(mod, h, kptr, klen, v)
+	})
+	export("field_set_bool", func(_ context.Context, mod api.Module, h, kptr, klen, v uint32) int32 {
+		return w.setField(mod, h, kptr, klen, v != 0)
+	})
+	export("field_set_string", func(_ context.Context, mod api.Module, h, kptr, klen, vptr, vlen uint32) int32 {
+		v, ok := read(mod, vptr, vlen)
+		if !ok {
+			return errMemoryAccess
+		}
+		return w.setField(mod, h, kptr, klen, v)
+	})
+	export("field_remove", func(_ context.Context, mod api.Module, h, kptr, klen uint32) int32 {
+		m := w.current.get(h)
+		if m == nil {
+			return errInvalidHandle
+		}
+		key, ok := read(mod, kptr, klen)
+		if !ok {
+			return errMemoryAccess
+		}
+		if !m.HasField(key) {
+			return errNotFound
+		}
+		m.RemoveField(key)
+		return statusOK
+	})
+
+	// Logging using the levels error (0), warning (1), info (2), debug (3)
+	// and trace (4)
+	export("log", func(_ context.Context, mod api.Module, level, ptr, size uint32) int32 {
+		msg, ok := read(mod, ptr, size)
+		if !ok {
+			return errMemoryAccess
+		}
+		switch level {
+		case 0:
+			w.Log.Error(msg)
+		case 1:
+			w.Log.Warn(msg)
+		case 2:
+			w.Log.Info(msg)
+		case 3:
+			w.Log.Debug(msg)
+		case 4:
+			w.Log.Trace(msg)
+		default:
+			return errInvalidArgument
+		}
+		return statusOK
+	})
+
+	_, err := builder.Instantiate(ctx)
+	return err
+}
+
+func (w *Wasm) field(mod api.Module, h, kptr, klen uint32) (interface{}, int32) {
+	m := w.current.get(h)
+	if m == nil {
+		return nil, errInvalidHandle
+	}
+	key, ok := read(mod, kptr, klen)
+	if !ok {
+		return nil, errMemoryAccess
+	}
+	v, found := m.GetField(key)
+	if !found {
+		return nil, errNotFound
+	}
+	return v, statusOK
+}
+
+func (w *Wasm) setField(mod api.Module, h, kptr, klen uint32, v interface{}) int32 {
+	m := w.current.get(h)
+	if m == nil {
+		return errInvalidHandle
+	}
+	key, ok := read(mod, kptr, klen)
+	if !ok {
+		return errMemoryAccess
+	}
+	if key == "" {
+		return errInvalidArgument
+	}
+	m.AddField(key, v)
+	return statusOK
+}
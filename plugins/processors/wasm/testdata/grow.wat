@@ -0,0 +1,7 @@
+;; Grow the memory by 32 MiB and return an error if this fails
+(module
+  (memory (export "memory") 1)
+  (func (export "process") (result i32)
+    (if (i32.lt_s (memory.grow (i32.const 512)) (i32.const 0))
+      (then (return (i32.const 1))))
+    (i32.const 0)))
@@ -0,0 +1,5 @@
+;; Never return to test the execution time limit
+(module
+  (func (export "process") (result i32)
+    (loop $forever (br $forever))
+    (i32.const 0)))
@@ -0,0 +1,54 @@
+;; Prefix the metric name with "wasm_", add a "processed" tag, shift the time
+;; by one second and add a "doubled" field. Values above 100 emit an "alert"
+;; metric and metrics with a "drop" tag are dropped.
+(module
+  (import "telegraf" "metric_name" (func $metric_name (param i32 i32 i32) (result i32)))
+  (import "telegraf" "metric_set_name" (func $metric_set_name (param i32 i32 i32) (result i32)))
+  (import "telegraf" "metric_time" (func $metric_time (param i32 i32) (result i32)))
+  (import "telegraf" "metric_set_time" (func $metric_set_time (param i32 i64) (result i32)))
+  (import "telegraf" "tag_get" (func $tag_get (param i32 i32 i32 i32 i32) (result i32)))
+  (import "telegraf" "tag_set" (func $tag_set (param i32 i32 i32 i32 i32) (result i32)))
+  (import "telegraf" "field_get_float" (func $field_get_float (param i32 i32 i32 i32) (result i32)))
+  (import "telegraf" "field_set_float" (func $field_set_float (param i32 i32 i32 f64) (result i32)))
+  (import "telegraf" "metric_new" (func $metric_new (param i32 i32) (result i32)))
+  (import "telegraf" "metric_emit" (func $metric_emit (param i32) (result i32)))
+  (import "telegraf" "metric_drop" (func $metric_drop (param i32) (result i32)))
+
+  (memory (export "memory") 1)
+  (data (i32.const 16) "processed")
+  (data (i32.const 32) "wasm")
+  (data (i32.const 48) "value")
+  (data (i32.const 64) "doubled")
+  (data (i32.const 80) "drop")
+  (data (i32.const 96) "alert")
+  (data (i32.const 256) "wasm_")
+
+  (func (export "process") (result i32)
+    (local $len i32)
+    (local $value f64)
+    (local $handle i32)
+
+    ;; Drop the metric if the "drop" tag exists
+    (if (i32.ge_s (call $tag_get (i32.const 0) (i32.const 80) (i32.const 4) (i32.const 0) (i32.const 0)) (i32.const 0))
+      (then (return (call $metric_drop (i32.const 0)))))
+
+    ;; Read the name right after the prefix and set the combined name
+    (local.set $len (call $metric_name (i32.const 0) (i32.const 261) (i32.const 1024)))
+    (if (i32.gt_s (local.get $len) (i32.const 1024))
+      (then (return (i32.const 1))))
+    (drop (call $metric_set_name (i32.const 0) (i32.const 256) (i32.add (local.get $len) (i32.const 5))))
+
+    (drop (call $tag_set (i32.const 0) (i32.const 16) (i32.const 9) (i32.const 32) (i32.const 4)))
+    (drop (call $metric_time (i32.const 0) (i32.const 136)))
+    (drop (call $metric_set_time (i32.const 0) (i64.add (i64.load (i32.const 136)) (i64.const 1000000000))))
+
+    (if (i32.eqz (call $field_get_float (i32.const 0) (i32.const 48) (i32.const 5) (i32.const 128)))
+      (then
+        (local.set $value (f64.load (i32.const 128)))
+        (drop (call $field_set_float (i32.const 0) (i32.const 64) (i32.const 7) (f64.mul (local.get $value) (f64.const 2))))
+        (if (f64.gt (local.get $value) (f64.const 100))
+          (then
+            (local.set $handle (call $metric_new (i32.const 96) (i32.const 5)))
+            (drop (call $field_set_float (local.get $handle) (i32.const 48) (i32.const 5) (local.get $value)))
+            (drop (call $metric_emit (local.get $handle)))))))
+    (i32.const 0)))
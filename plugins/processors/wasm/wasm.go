@@ -0,0 +1,215 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package wasm
+
+import (
+	"context"
+	"crypto/rand"
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/plugins/processors"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+// Size of a WebAssembly memory page
+const pageSize = 64 * 1024
+
+type Wasm struct {
+	Module           string          `toml:"module"`
+	MaxMemory        config.Size     `toml:"max_memory"`
+	MaxExecutionTime config.Duration `toml:"max_execution_time"`
+	Log              telegraf.Logger `toml:"-"`
+
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+	instance api.Module
+	process  api.Function
+	current  *call
+}
+
+func (*Wasm) SampleConfig() string {
+	return sampleConfig
+}
+
+func (w *Wasm) Init() error {
+	if w.Module == "" {
+		return errors.New("missing module option")
+	}
+	if w.MaxMemory < pageSize || w.MaxMemory > 65536*pageSize {
+		return errors.New("max_memory must be between 64KiB and 4GiB")
+	}
+	if w.MaxExecutionTime <= 0 {
+		return errors.New("max_execution_time must be positive")
+	}
+
+	code, err := os.ReadFile(w.Module)
+	if err != nil {
+		return fmt.Errorf("reading module failed: %w", err)
+	}
+
+	// Interrupt the module if the execution time is exceeded. Memory beyond
+	// the limit cannot be allocated by the module.
+	ctx := context.Background()
+	cfg := wazero.NewRuntimeConfig().
+		WithCloseOnContextDone(true).
+		WithMemoryLimitPages(uint32(w.MaxMemory / pageSize))
+	w.runtime = wazero.NewRuntimeWithConfig(ctx, cfg)
+
+	if err := w.compile(ctx, code); err != nil {
+		w.runtime.Close(ctx)
+		return err
+	}
+
+	return nil
+}
+
+// compile the module after providing the imported host modules
+func (w *Wasm) compile(ctx context.Context, code []byte) error {
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, w.runtime); err != nil {
+		return fmt.Errorf("instantiating WASI failed: %w", err)
+	}
+	if err := w.instantiateHost(ctx); err != nil {
+		return fmt.Errorf("instantiating host module failed: %w", err)
+	}
+
+	compiled, err := w.runtime.CompileModule(ctx, code)
+	if err != nil {
+		return fmt.Errorf("compiling module failed: %w", err)
+	}
+	w.compiled = compiled
+
+	// Check the signature of the process function early
+	process, found := compiled.ExportedFunctions()["process"]
+	if !found {
+		return errors.New("module does not export a process function")
+	}
+	if len(process.ParamTypes()) != 0 || len(process.ResultTypes()) != 1 || process.ResultTypes()[0] != api.ValueTypeI32 {
+		return errors.New("process function must not take parameters and return an i32 status")
+	}
+
+	return nil
+}
+
+func (w *Wasm) Start(telegraf.Accumulator) error {
+	return w.instantiate()
+}
+
+func (w *Wasm) Add(m telegraf.Metric, acc telegraf.Accumulator) error {
+	// Instances are closed on errors as their state is undefined afterwards
+	if w.instance == nil {
+		if err := w.instantiate(); err != nil {
+			return err
+		}
+	}
+
+	w.current = &call{handles: []*handle{{metric: m}}}
+	defer func() { w.current = nil }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(w.MaxExecutionTime))
+	defer cancel()
+	results, err := w.process.Call(ctx)
+	if err != nil {
+		w.closeInstance()
+		w.current.discard()
+		return fmt.Errorf("calling process function failed: %w", err)
+	}
+	if status := api.DecodeI32(results[0]); status != 0 {
+		w.current.discard()
+		return fmt.Errorf("process function returned status %d", status)
+	}
+
+	// Pass on the input metric unless dropped, followed by the emitted ones
+	input := w.current.handles[0]
+	if input.dropped {
+		m.Drop()
+	} else {
+		acc.AddMetric(m)
+	}
+	for _, h := range w.current.handles[1:] {
+		if h.emitted {
+			acc.AddMetric(h.metric)
+		} else {
+			h.metric.Drop()
+		}
+	}
+
+	return nil
+}
+
+func (w *Wasm) Stop() {
+	if w.runtime != nil {
+		w.runtime.Close(context.Background())
+	}
+}
+
+// instantiate a new instance of the compiled module, reactor modules are
+// initialized by calling the "_initialize" function
+func (w *Wasm) instantiate() error {
+	cfg := wazero.NewModuleConfig().
+		WithName("").
+		WithStartFunctions().
+		WithStdout(&logWriter{log: w.Log.Info}).
+		WithStderr(&logWriter{log: w.Log.Error}).
+		WithSysWalltime().
+		WithSysNanotime().
+		WithRandSource(rand.Reader)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(w.MaxExecutionTime))
+	defer cancel()
+
+	instance, err := w.runtime.InstantiateModule(ctx, w.compiled, cfg)
+	if err != nil {
+		return fmt.Errorf("instantiating module failed: %w", err)
+	}
+	if initialize := instance.ExportedFunction("_initialize"); initialize != nil {
+		if _, err := initialize.Call(ctx); err != nil {
+			instance.Close(context.Background())
+			return fmt.Errorf("initializing module failed: %w", err)
+		}
+	}
+	w.instance = instance
+	w.process = instance.ExportedFunction("process")
+
+	return nil
+}
+
+func (w *Wasm) closeInstance() {
+	if w.instance != nil {
+		w.instance.Close(context.Background())
+	}
+	w.instance = nil
+	w.process = nil
+}
+
+// logWriter forwards the standard output and error of the module to the log
+type logWriter struct {
+	log func(args ...interface{})
+}
+
+func (l *logWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		l.log(line)
+	}
+	return len(p), nil
+}
+
+func init() {
+	processors.AddStreaming("wasm", func() telegraf.StreamingProcessor {
+		return &Wasm{
+			MaxMemory:        config.Size(16 * 1024 * 1024),
+			MaxExecutionTime: config.Duration(100 * time.Millisecond),
+		}
+	})
+}
@@ -0,0 +1,232 @@
+package wasm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInit(t *testing.T) {
+	// An empty but valid module
+	empty := filepath.Join(t.TempDir(), "empty.wasm")
+	require.NoError(t, os.WriteFile(empty, []byte("\x00asm\x01\x00\x00\x00"), 0o600))
+
+	tests := []struct {
+		name     string
+		plugin   *Wasm
+		expected string
+	}{
+		{
+			name:     "missing module",
+			plugin:   &Wasm{},
+			expected: "missing module option",
+		},
+		{
+			name: "memory limit too small",
+			plugin: &Wasm{
+				Module:           "testdata/transform.wasm",
+				MaxMemory:        config.Size(1024),
+				MaxExecutionTime: config.Duration(time.Second),
+			},
+			expected: "max_memory must be between 64KiB and 4GiB",
+		},
+		{
+			name: "missing execution time",
+			plugin: &Wasm{
+				Module:    "testdata/transform.wasm",
+				MaxMemory: config.Size(pageSize),
+			},
+			expected: "max_execution_time must be positive",
+		},
+		{
+			name: "non-existing module",
+			plugin: &Wasm{
+				Module:           "testdata/non-existing.wasm",
+				MaxMemory:        config.Size(pageSize),
+				MaxExecutionTime: config.Duration(time.Second),
+			},
+			expected: "reading module failed",
+		},
+		{
+			name: "invalid module",
+			plugin: &Wasm{
+				Module:           "testdata/transform.wat",
+				MaxMemory:        config.Size(pageSize),
+				MaxExecutionTime: config.Duration(time.Second),
+			},
+			expected: "compiling module failed",
+		},
+		{
+			name: "missing process function",
+			plugin: &Wasm{
+				Module:           empty,
+				MaxMemory:        config.Size(pageSize),
+				MaxExecutionTime: config.Duration(time.Second),
+			},
+			expected: "module does not export a process function",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestTransform(t *testing.T) {
+	plugin := &Wasm{
+		Module:           "testdata/transform.wasm",
+		MaxMemory:        config.Size(pageSize),
+		MaxExecutionTime: config.Duration(time.Second),
+		Log:              testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	input := []telegraf.Metric{
+		metric.New("cpu", map[string]string{"host": "a"}, map[string]interface{}{"value": 42.0}, time.Unix(0, 0)),
+		metric.New("cpu", map[string]string{"host": "b"}, map[string]interface{}{"value": int64(150)}, time.Unix(10, 0)),
+		metric.New("cpu", map[string]string{"drop": "true"}, map[string]interface{}{"value": 1.0}, time.Unix(20, 0)),
+		metric.New("mem", map[string]string{}, map[string]interface{}{"used": "n/a"}, time.Unix(30, 0)),
+	}
+	expected := []telegraf.Metric{
+		metric.New(
+			"wasm_cpu",
+			map[string]string{"host": "a", "processed": "wasm"},
+			map[string]interface{}{"value": 42.0, "doubled": 84.0},
+			time.Unix(1, 0),
+		),
+		metric.New(
+			"wasm_cpu",
+			map[string]string{"host": "b", "processed": "wasm"},
+			map[string]interface{}{"value": int64(150), "doubled": 300.0},
+			time.Unix(11, 0),
+		),
+		metric.New("alert", map[string]string{}, map[string]interface{}{"value": 150.0}, time.Unix(11, 0)),
+		metric.New("wasm_mem", map[string]string{"processed": "wasm"}, map[string]interface{}{"used": "n/a"}, time.Unix(31, 0)),
+	}
+
+	for _, m := range input {
+		require.NoError(t, plugin.Add(m, &acc))
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics())
+}
+
+func TestTracking(t *testing.T) {
+	plugin := &Wasm{
+		Module:           "testdata/transform.wasm",
+		MaxMemory:        config.Size(pageSize),
+		MaxExecutionTime: config.Duration(time.Second),
+		Log:              testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	var delivered []telegraf.DeliveryInfo
+	notify := func(di telegraf.DeliveryInfo) {
+		delivered = append(delivered, di)
+	}
+
+	// Dropped metrics are delivered immediately
+	input, _ := metric.WithTracking(
+		metric.New("cpu", map[string]string{"drop": "true"}, map[string]interface{}{"value": 1.0}, time.Unix(0, 0)),
+		notify,
+	)
+	require.NoError(t, plugin.Add(input, &acc))
+	require.Empty(t, acc.GetTelegrafMetrics())
+	require.Len(t, delivered, 1)
+
+	// Passed metrics are delivered once accepted by the output
+	input, _ = metric.WithTracking(
+		metric.New("cpu", map[string]string{}, map[string]interface{}{"value": 1.0}, time.Unix(0, 0)),
+		notify,
+	)
+	require.NoError(t, plugin.Add(input, &acc))
+	require.Len(t, delivered, 1)
+	for _, m := range acc.GetTelegrafMetrics() {
+		m.Accept()
+	}
+	require.Len(t, delivered, 2)
+}
+
+func TestExecutionTimeLimit(t *testing.T) {
+	plugin := &Wasm{
+		Module:           "testdata/loop.wasm",
+		MaxMemory:        config.Size(pageSize),
+		MaxExecutionTime: config.Duration(50 * time.Millisecond),
+		Log:              testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	// Interrupted instances are replaced for subsequent calls
+	for range 2 {
+		m := metric.New("cpu", map[string]string{}, map[string]interface{}{"value": 1.0}, time.Unix(0, 0))
+		require.ErrorContains(t, plugin.Add(m, &acc), "deadline exceeded")
+		require.Nil(t, plugin.instance)
+	}
+	require.Empty(t, acc.GetTelegrafMetrics())
+}
+
+func TestMemoryLimit(t *testing.T) {
+	// The module tries to grow its memory by 32MiB
+	tests := []struct {
+		name     string
+		limit    config.Size
+		expected string
+	}{
+		{
+			name:     "exceeded",
+			limit:    config.Size(16 * 1024 * 1024),
+			expected: "process function returned status 1",
+		},
+		{
+			name:  "sufficient",
+			limit: config.Size(64 * 1024 * 1024),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := &Wasm{
+				Module:           "testdata/grow.wasm",
+				MaxMemory:        tt.limit,
+				MaxExecutionTime: config.Duration(time.Second),
+				Log:              testutil.Logger{},
+			}
+			require.NoError(t, plugin.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, plugin.Start(&acc))
+			defer plugin.Stop()
+
+			m := metric.New("cpu", map[string]string{}, map[string]interface{}{"value": 1.0}, time.Unix(0, 0))
+			err := plugin.Add(m, &acc)
+			if tt.expected != "" {
+				require.ErrorContains(t, err, tt.expected)
+				require.Empty(t, acc.GetTelegrafMetrics())
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, acc.GetTelegrafMetrics(), 1)
+		})
+	}
+}